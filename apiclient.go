@@ -0,0 +1,111 @@
+package f5xc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Client is an HTTP client that is pre-configured to authenticate to F5 XC endpoints, like the client returned by
+// [NewClient], with methods that call the API using per-client defaults such as the namespace set with
+// [WithDefaultNamespace]. The credentials of a Client can be replaced while it is in use; e.g. to reload rotated
+// credentials when a daemon receives SIGHUP. Replacing a credential is safe for concurrent use: requests that are in
+// flight complete with the credential they started with, and later requests use the new credential. A Client is a
+// [Doer], and it or the embedded *http.Client can be passed to any function in the package.
+type Client struct {
+	*http.Client
+	credentials *credentialStore
+	// The namespace used when a namespace is not given or set on the context.
+	namespace string
+	// Guards the tenant, which is set with WithTenant or discovered when first requested.
+	mu     sync.Mutex
+	tenant string
+}
+
+// SecretsAPI describes the secret management methods of [Client], so that consumers can substitute a fake in tests.
+type SecretsAPI interface {
+	PublicKey(ctx context.Context, version *int) (*PublicKey, error)
+	SecretPolicyDocument(ctx context.Context, name, namespace string) (*SecretPolicyDocument, error)
+}
+
+var _ SecretsAPI = (*Client)(nil)
+
+// Use the namespace in the methods of [Client] that take a namespace, when the namespace is empty and one has not been
+// set on the context with [WithNamespace]. The option has no effect on clients returned by [NewClient].
+func WithDefaultNamespace(namespace string) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding default namespace", "namespace", namespace)
+		c.namespace = namespace
+		return nil
+	}
+}
+
+// NewAPIClient creates a new Client with the options; see [NewClient].
+func NewAPIClient(options ...Option) (*Client, error) {
+	cfg, err := newConfig(options...)
+	if err != nil {
+		return nil, err
+	}
+	client, credentials, err := cfg.newClient()
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		Client:      client,
+		credentials: credentials,
+		namespace:   cfg.namespace,
+		tenant:      cfg.tenant,
+	}, nil
+}
+
+// PublicKey returns the PublicKey with the version, or the current PublicKey if version is nil. Unlike
+// [GetPublicKey], an error that wraps [ErrNotFound] is returned if the version does not exist.
+func (c *Client) PublicKey(ctx context.Context, version *int) (*PublicKey, error) {
+	req, err := newPublicKeyRequest(ctx, c.credentials.logger, version)
+	if err != nil {
+		return nil, err
+	}
+	return StrictEnvelopeAPICall[PublicKey](c.Client, req)
+}
+
+// SecretPolicyDocument returns the named SecretPolicyDocument. If namespace is empty the namespace set on the context
+// with [WithNamespace] is used, or the default namespace of the client. Unlike [GetSecretPolicyDocument], an error that
+// wraps [ErrNotFound] is returned if the document does not exist.
+func (c *Client) SecretPolicyDocument(ctx context.Context, name, namespace string) (*SecretPolicyDocument, error) {
+	namespace = resolveNamespace(ctx, c.credentials.logger, namespace)
+	if namespace == "" {
+		namespace = c.namespace
+	}
+	req, err := newSecretPolicyDocumentRequest(ctx, c.credentials.logger, name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return StrictEnvelopeAPICall[SecretPolicyDocument](c.Client, req)
+}
+
+// SetAuthToken replaces the credential of the client with the API token, disabling certificate based authentication
+// and any token source.
+func (c *Client) SetAuthToken(token string) error {
+	if token == "" {
+		return fmt.Errorf("API token must not be empty: %w", ErrMissingAuthentication)
+	}
+	c.credentials.logger.Debug("Replacing client credential with authentication token")
+	c.credentials.setAuthToken(token)
+	return nil
+}
+
+// SetCertificate replaces the credential of the client with the client certificate, disabling token authentication.
+// Idle connections are closed so that new connections present the certificate; a connection that is in use when the
+// certificate is replaced keeps the identity of the certificate it was established with, and may be reused until it is
+// idle and closed.
+func (c *Client) SetCertificate(cert tls.Certificate) error {
+	if len(cert.Certificate) == 0 || cert.PrivateKey == nil {
+		return fmt.Errorf("client certificate and private key must be present: %w", ErrMissingAuthentication)
+	}
+	c.credentials.logger.Debug("Replacing client credential with certificate")
+	c.credentials.setCertificate(&cert)
+	c.CloseIdleConnections()
+	return nil
+}
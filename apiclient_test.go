@@ -0,0 +1,201 @@
+package f5xc_test
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/memes/f5xc"
+)
+
+// The credentials presented with a request.
+type testPresented struct {
+	authorization string
+	certificate   bool
+}
+
+// Verify that the credentials of a Client can be replaced while it is in use.
+func TestClient_SetCredentials(t *testing.T) {
+	t.Parallel()
+	presented := make(chan testPresented, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented <- testPresented{
+			authorization: r.Header.Get("Authorization"),
+			certificate:   len(r.TLS.PeerCertificates) > 0,
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert, MinVersion: tls.VersionTLS12}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	client, err := f5xc.NewAPIClient(testServerOptions(t, server)...)
+	if err != nil {
+		t.Fatalf("NewAPIClient raised an unexpected error: %v", err)
+	}
+	t.Cleanup(client.CloseIdleConnections)
+	cert, err := tls.LoadX509KeyPair(TestX509Certificate, TestX509Key)
+	if err != nil {
+		t.Fatalf("Failed to load test certificate: %v", err)
+	}
+	steps := []struct {
+		name     string
+		set      func() error
+		expected testPresented
+	}{
+		{
+			name:     "initial",
+			set:      func() error { return nil },
+			expected: testPresented{authorization: "APIToken test-token"},
+		},
+		{
+			name:     "token",
+			set:      func() error { return client.SetAuthToken("rotated-token") },
+			expected: testPresented{authorization: "APIToken rotated-token"},
+		},
+		{
+			name:     "certificate",
+			set:      func() error { return client.SetCertificate(cert) },
+			expected: testPresented{certificate: true},
+		},
+	}
+	for _, step := range steps {
+		if err := step.set(); err != nil {
+			t.Fatalf("%s: failed to set credential: %v", step.name, err)
+		}
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/api/test", nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: request raised an unexpected error: %v", step.name, err)
+		}
+		_ = resp.Body.Close()
+		if got := <-presented; got != step.expected {
+			t.Errorf("%s: expected %+v, got %+v", step.name, step.expected, got)
+		}
+	}
+	if err := client.SetAuthToken(""); !errors.Is(err, f5xc.ErrMissingAuthentication) {
+		t.Errorf("Expected SetAuthToken to raise %v, got %v", f5xc.ErrMissingAuthentication, err)
+	}
+	if err := client.SetCertificate(tls.Certificate{}); !errors.Is(err, f5xc.ErrMissingAuthentication) {
+		t.Errorf("Expected SetCertificate to raise %v, got %v", f5xc.ErrMissingAuthentication, err)
+	}
+}
+
+// Verify that the token of a Client can be replaced while requests are in flight.
+func TestClient_SetAuthToken_Concurrent(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	client, err := f5xc.NewAPIClient(testServerOptions(t, server)...)
+	if err != nil {
+		t.Fatalf("NewAPIClient raised an unexpected error: %v", err)
+	}
+	t.Cleanup(client.CloseIdleConnections)
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.SetAuthToken("token-" + strconv.Itoa(i)); err != nil {
+				t.Errorf("SetAuthToken raised an unexpected error: %v", err)
+			}
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/api/test", nil)
+			if err != nil {
+				t.Errorf("Failed to create request: %v", err)
+				return
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Errorf("Request raised an unexpected error: %v", err)
+				return
+			}
+			_ = resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+}
+
+// Verify that the methods of Client call the API with the namespace from the argument, the context, or the default
+// namespace of the client, and return ErrNotFound for missing resources.
+func TestClient_Methods(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case f5xc.PublicKeyURL:
+			if r.URL.Query().Get("key_version") == "99" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{Data: f5xc.PublicKey{KeyVersion: 1, Tenant: "test"}})
+		case fmt.Sprintf(f5xc.SecretPolicyDocumentURL, "argument", "test"),
+			fmt.Sprintf(f5xc.SecretPolicyDocumentURL, "context", "test"),
+			fmt.Sprintf(f5xc.SecretPolicyDocumentURL, "default", "test"):
+			_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.SecretPolicyDocument]{Data: f5xc.SecretPolicyDocument{PolicyID: r.URL.Path}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	client, err := f5xc.NewAPIClient(append(testServerOptions(t, server), f5xc.WithDefaultNamespace("default"))...)
+	if err != nil {
+		t.Fatalf("NewAPIClient raised an unexpected error: %v", err)
+	}
+	t.Cleanup(client.CloseIdleConnections)
+	var api f5xc.SecretsAPI = client
+
+	pubKey, err := api.PublicKey(context.Background(), nil)
+	if err != nil || pubKey.Tenant != "test" {
+		t.Errorf("PublicKey returned %v, %v", pubKey, err)
+	}
+	version := 99
+	if _, err := api.PublicKey(context.Background(), &version); !errors.Is(err, f5xc.ErrNotFound) {
+		t.Errorf("Expected PublicKey to raise %v, got %v", f5xc.ErrNotFound, err)
+	}
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		namespace string
+		expected  string
+	}{
+		{
+			name:      "argument",
+			ctx:       f5xc.WithNamespace(context.Background(), "context"),
+			namespace: "argument",
+			expected:  "argument",
+		},
+		{
+			name:     "context",
+			ctx:      f5xc.WithNamespace(context.Background(), "context"),
+			expected: "context",
+		},
+		{
+			name:     "default",
+			ctx:      context.Background(),
+			expected: "default",
+		},
+	}
+	for _, tst := range tests {
+		policyDoc, err := api.SecretPolicyDocument(tst.ctx, "test", tst.namespace)
+		if err != nil {
+			t.Errorf("%s: SecretPolicyDocument raised an unexpected error: %v", tst.name, err)
+			continue
+		}
+		if expected := fmt.Sprintf(f5xc.SecretPolicyDocumentURL, tst.expected, "test"); policyDoc.PolicyID != expected {
+			t.Errorf("%s: expected request to %s, got %s", tst.name, expected, policyDoc.PolicyID)
+		}
+	}
+	if _, err := api.SecretPolicyDocument(context.Background(), "missing", ""); !errors.Is(err, f5xc.ErrNotFound) {
+		t.Errorf("Expected SecretPolicyDocument to raise %v, got %v", f5xc.ErrNotFound, err)
+	}
+}
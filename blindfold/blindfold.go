@@ -20,6 +20,9 @@ import (
 // The default name to use when searching for vesctl.
 const VesctlExecutable = "vesctl"
 
+// The operation of an [f5xc.Error] returned when vesctl fails; vesctl failures are never retryable.
+const vesctlOp = "vesctl"
+
 // ErrVesctl indicates that vesctl process failed or exited with status code other than 0. It will usually contain a
 // wrapped error with specifics.
 var ErrVesctl = errors.New("failed to execute vesctl")
@@ -57,7 +60,7 @@ func RandomString(n int) string {
 // one or more of VES_P12_PASSWORD, VOLT_API_*, or VOLTERRA_TOKEN set to legitimate values. For that reason, vesctl will
 // be launched with a set of environment variables and command line options set to dummy/empty/random values to minimize
 // any accidental leak of information *except* for the parameters which are required for blindfold operation, which is
-// itself an offline function.
+// itself an offline function. If vesctl fails the error is an [*f5xc.Error] that wraps [ErrVesctl].
 func ExecuteVesctl(ctx context.Context, vesctl string, args []string, params map[string]string, stdOut, stdErr io.Writer) error {
 	logger := slog.With("vesctl", vesctl, "args", args, "params", params)
 	logger.Debug("Attempting to execute vesctl")
@@ -105,7 +108,11 @@ func ExecuteVesctl(ctx context.Context, vesctl string, args []string, params map
 	cmd.Stderr = stdErr
 	slog.Debug("About to execute vesctl", "finalArguments", finalArguments)
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failure while executing vesctl: %w: %w", err, ErrVesctl)
+		return &f5xc.Error{
+			Op:       vesctlOp,
+			Endpoint: vesctl,
+			Err:      fmt.Errorf("failure while executing vesctl: %w: %w", err, ErrVesctl),
+		}
 	}
 
 	return nil
@@ -176,6 +183,9 @@ func createTempYAMLEnvelope[T f5xc.EnvelopeAllowed](obj T, tmpDir string) (strin
 func SealFile(ctx context.Context, vesctl, plaintextPath string, pubKey *f5xc.PublicKey, policyDoc *f5xc.SecretPolicyDocument) ([]byte, error) {
 	logger := slog.With("vesctl", vesctl, "plaintextPath", plaintextPath)
 	logger.Debug("Preparing to blindfold")
+	if err := pubKey.Validate(); err != nil {
+		return nil, fmt.Errorf("public key cannot be used to blindfold: %w", err)
+	}
 	vesctlPath, err := FindVesctl(vesctl)
 	if err != nil {
 		return nil, fmt.Errorf("failed to locate vesctl(%q) %w", vesctl, err)
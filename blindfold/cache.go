@@ -0,0 +1,133 @@
+package blindfold
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/memes/f5xc"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// The name of the file in a cache directory that holds the current public key.
+	CurrentPublicKeyFile = "public-key.yaml"
+	// The name of the directory in a cache directory that holds secret policy documents, in a sub-directory per
+	// namespace.
+	PolicyDocumentDir = "policies"
+)
+
+var (
+	// ErrNotCached is returned when a public key or policy document is not present in a cache directory.
+	ErrNotCached = errors.New("not found in cache")
+	// ErrInvalidPolicyDocument is returned when a policy document cannot be cached because its name is unknown.
+	ErrInvalidPolicyDocument = errors.New("invalid policy document")
+)
+
+// Returns the path of the file that holds a public key in the cache directory; the current public key is returned for
+// version 0.
+func PublicKeyCachePath(dir string, version int) string {
+	if version == 0 {
+		return filepath.Join(dir, CurrentPublicKeyFile)
+	}
+	return filepath.Join(dir, fmt.Sprintf("public-key-v%d.yaml", version))
+}
+
+// Returns the path of the file that holds a secret policy document in the cache directory.
+func PolicyDocumentCachePath(dir, namespace, name string) string {
+	return filepath.Join(dir, PolicyDocumentDir, namespace, name+".yaml")
+}
+
+// Writes the public key to the cache directory as envelope YAML, in the same format that vesctl accepts. The key is
+// written to a file for its version, and also as the current public key if current is true.
+func WriteCachedPublicKey(dir string, pubKey *f5xc.PublicKey, current bool) error {
+	paths := []string{PublicKeyCachePath(dir, pubKey.KeyVersion)}
+	if current {
+		paths = append(paths, PublicKeyCachePath(dir, 0))
+	}
+	for _, path := range paths {
+		if err := writeCachedEnvelope(path, *pubKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads a public key from the cache directory; the current public key is returned for version 0.
+func ReadCachedPublicKey(dir string, version int) (*f5xc.PublicKey, error) {
+	return readCachedEnvelope[f5xc.PublicKey](PublicKeyCachePath(dir, version))
+}
+
+// Writes the secret policy document to the cache directory as envelope YAML, in the same format that vesctl accepts.
+// The namespace and name are taken from the document metadata if not provided.
+func WriteCachedPolicyDocument(dir, namespace, name string, policyDoc *f5xc.SecretPolicyDocument) error {
+	if policyDoc.Metadata != nil {
+		if namespace == "" {
+			namespace = policyDoc.Namespace
+		}
+		if name == "" {
+			name = policyDoc.Name
+		}
+	}
+	if namespace == "" || name == "" {
+		return fmt.Errorf("policy document must have a namespace and name: %w", ErrInvalidPolicyDocument)
+	}
+	return writeCachedEnvelope(PolicyDocumentCachePath(dir, namespace, name), *policyDoc)
+}
+
+// Reads a secret policy document from the cache directory.
+func ReadCachedPolicyDocument(dir, namespace, name string) (*f5xc.SecretPolicyDocument, error) {
+	return readCachedEnvelope[f5xc.SecretPolicyDocument](PolicyDocumentCachePath(dir, namespace, name))
+}
+
+// Executes vesctl to blindfold the supplied plaintext using a public key and secret policy document that have been
+// written to the cache directory, returning the Base64 encoded sealed data. No API calls are made, so sealing can be
+// performed offline and reproducibly. The current public key is used if keyVersion is 0.
+func SealFromCache(ctx context.Context, vesctl, dir string, plaintext []byte, keyVersion int, namespace, name string) ([]byte, error) {
+	pubKey, err := ReadCachedPublicKey(dir, keyVersion)
+	if err != nil {
+		return nil, err
+	}
+	policyDoc, err := ReadCachedPolicyDocument(dir, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return Seal(ctx, vesctl, plaintext, pubKey, policyDoc)
+}
+
+// Marshals an object to an Envelope and writes it to path, creating parent directories as needed.
+func writeCachedEnvelope[T f5xc.EnvelopeAllowed](path string, obj T) error {
+	slog.Debug("Writing envelope to cache", "path", path)
+	data, err := yaml.Marshal(f5xc.Envelope[T]{Data: obj})
+	if err != nil {
+		return fmt.Errorf("failed to marshal to YAML: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write cache file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Reads an Envelope from path and returns the object it contains.
+func readCachedEnvelope[T f5xc.EnvelopeAllowed](path string) (*T, error) {
+	slog.Debug("Reading envelope from cache", "path", path)
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("%s: %w", path, ErrNotCached)
+	case err != nil:
+		return nil, fmt.Errorf("failed to read cache file %s: %w", path, err)
+	}
+	envelope := &f5xc.Envelope[T]{}
+	if err := yaml.Unmarshal(data, envelope); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cache file %s: %w", path, err)
+	}
+	return &envelope.Data, nil
+}
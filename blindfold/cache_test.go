@@ -0,0 +1,68 @@
+package blindfold_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/blindfold"
+)
+
+// Verify that public keys and policy documents can be written to, and read from, a cache directory.
+func TestCache(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	current := &f5xc.PublicKey{KeyVersion: 2, ModulusBase64: "bW9kdWx1cw==", PublicExponentBase64: "AQAB", Tenant: "test"}
+	previous := &f5xc.PublicKey{KeyVersion: 1, ModulusBase64: "b2xk", PublicExponentBase64: "AQAB", Tenant: "test"}
+	if err := blindfold.WriteCachedPublicKey(dir, current, true); err != nil {
+		t.Fatalf("WriteCachedPublicKey raised an unexpected error: %v", err)
+	}
+	if err := blindfold.WriteCachedPublicKey(dir, previous, false); err != nil {
+		t.Fatalf("WriteCachedPublicKey raised an unexpected error: %v", err)
+	}
+	for version, expected := range map[int]*f5xc.PublicKey{0: current, 1: previous, 2: current} {
+		pubKey, err := blindfold.ReadCachedPublicKey(dir, version)
+		switch {
+		case err != nil:
+			t.Errorf("ReadCachedPublicKey(%d) raised an unexpected error: %v", version, err)
+		case *pubKey != *expected:
+			t.Errorf("Expected ReadCachedPublicKey(%d) to return %v, got %v", version, expected, pubKey)
+		}
+	}
+	if _, err := blindfold.ReadCachedPublicKey(dir, 3); !errors.Is(err, blindfold.ErrNotCached) {
+		t.Errorf("Expected ReadCachedPublicKey to raise %v, got %v", blindfold.ErrNotCached, err)
+	}
+	policyDoc := &f5xc.SecretPolicyDocument{
+		Metadata: &f5xc.Metadata{Name: "app", Namespace: "shared", Tenant: "test"},
+		PolicyID: "policy-1",
+		PolicyInfo: f5xc.SecretPolicyInfo{
+			Algo:  "FIRST_MATCH",
+			Rules: []f5xc.SecretPolicyRule{{Action: "ALLOW", ClientName: "app"}},
+		},
+	}
+	if err := blindfold.WriteCachedPolicyDocument(dir, "", "", policyDoc); err != nil {
+		t.Fatalf("WriteCachedPolicyDocument raised an unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, blindfold.PolicyDocumentDir, "shared", "app.yaml")); err != nil {
+		t.Errorf("Expected policy document to be written to namespace directory: %v", err)
+	}
+	cached, err := blindfold.ReadCachedPolicyDocument(dir, "shared", "app")
+	switch {
+	case err != nil:
+		t.Errorf("ReadCachedPolicyDocument raised an unexpected error: %v", err)
+	case cached.PolicyID != "policy-1" || cached.Name != "app" || len(cached.PolicyInfo.Rules) != 1:
+		t.Errorf("Unexpected cached policy document %+v", cached)
+	}
+	if err := blindfold.WriteCachedPolicyDocument(dir, "", "", &f5xc.SecretPolicyDocument{}); !errors.Is(err, blindfold.ErrInvalidPolicyDocument) {
+		t.Errorf("Expected WriteCachedPolicyDocument to raise %v, got %v", blindfold.ErrInvalidPolicyDocument, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if _, err := blindfold.SealFromCache(ctx, "", dir, []byte("plaintext"), 0, "shared", "missing"); !errors.Is(err, blindfold.ErrNotCached) {
+		t.Errorf("Expected SealFromCache to raise %v, got %v", blindfold.ErrNotCached, err)
+	}
+}
@@ -0,0 +1,317 @@
+package blindfold
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/memes/f5xc"
+)
+
+const (
+	// StatementType is the type of an in-toto v1 attestation statement.
+	StatementType = "https://in-toto.io/Statement/v1"
+	// SealPredicateType is the predicate type of a statement that describes how sealed artifacts were produced.
+	SealPredicateType = "https://github.com/memes/f5xc/attestation/seal/v1"
+	// PayloadType is the DSSE payload type of a signed in-toto statement.
+	PayloadType = "application/vnd.in-toto+json"
+)
+
+var (
+	// ErrUnsupportedKey is returned when a provenance signing or verification key is not an ECDSA, Ed25519, or RSA key.
+	ErrUnsupportedKey = errors.New("unsupported provenance key")
+	// ErrInvalidSignature is returned when a signed provenance statement cannot be verified with the public key.
+	ErrInvalidSignature = errors.New("invalid provenance signature")
+	// ErrMixedSealResults is returned when a statement is requested for results that were sealed with different public
+	// keys or secret policies.
+	ErrMixedSealResults = errors.New("sealed results have different sealing parameters")
+)
+
+// SealResult describes data that has been sealed with blindfold, and the public key and secret policy that sealed it.
+type SealResult struct {
+	// The base64 encoded sealed data.
+	Sealed []byte
+	// The tenant that owns the public key.
+	Tenant string
+	// The version of the public key.
+	KeyVersion int
+	// The fingerprint of the public key, as returned by [f5xc.PublicKey.Fingerprint], if known.
+	KeyFingerprint string
+	// The namespace of the secret policy, if known.
+	Namespace string
+	// The name of the secret policy, if known.
+	Policy string
+	// The identifier of the secret policy.
+	PolicyID string
+	// The time the data was sealed.
+	SealedAt time.Time
+}
+
+// NewSealResult returns a SealResult for data that has just been sealed with the public key and policy document, either
+// of which may be nil if unknown.
+func NewSealResult(sealed []byte, pubKey *f5xc.PublicKey, policyDoc *f5xc.SecretPolicyDocument) *SealResult {
+	result := &SealResult{
+		Sealed:   bytes.TrimSpace(sealed),
+		SealedAt: time.Now().UTC(),
+	}
+	if pubKey != nil {
+		result.Tenant = pubKey.Tenant
+		result.KeyVersion = pubKey.KeyVersion
+		if fingerprint, err := pubKey.Fingerprint(); err == nil {
+			result.KeyFingerprint = fingerprint
+		}
+	}
+	if policyDoc != nil {
+		result.PolicyID = policyDoc.PolicyID
+		if policyDoc.Metadata != nil {
+			result.Namespace = policyDoc.Namespace
+			result.Policy = policyDoc.Name
+		}
+	}
+	return result
+}
+
+// SealWithResult executes vesctl to blindfold the supplied plaintext, as [Seal] does, and returns a SealResult that
+// describes the sealed data.
+func SealWithResult(ctx context.Context, vesctl string, plaintext []byte, pubKey *f5xc.PublicKey, policyDoc *f5xc.SecretPolicyDocument) (*SealResult, error) {
+	sealed, err := Seal(ctx, vesctl, plaintext, pubKey, policyDoc)
+	if err != nil {
+		return nil, err
+	}
+	return NewSealResult(sealed, pubKey, policyDoc), nil
+}
+
+// Tool identifies the software that sealed the artifacts described by a statement.
+type Tool struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// Subject identifies a sealed artifact by name and the digests of its base64 encoded sealed data.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// SealPredicate records what sealed the subjects of a statement, with which public key and secret policy, and when. It
+// never includes any information derived from the plaintext.
+type SealPredicate struct {
+	Tool           Tool      `json:"tool"`
+	SealedAt       time.Time `json:"sealedAt"`
+	Tenant         string    `json:"tenant,omitempty"`
+	KeyVersion     int       `json:"keyVersion"`
+	KeyFingerprint string    `json:"keyFingerprint,omitempty"`
+	Namespace      string    `json:"namespace,omitempty"`
+	Policy         string    `json:"policy,omitempty"`
+	PolicyID       string    `json:"policyId,omitempty"`
+}
+
+// Statement is an in-toto v1 attestation statement with a [SealPredicate].
+type Statement struct {
+	Type          string        `json:"_type"`
+	Subject       []Subject     `json:"subject"`
+	PredicateType string        `json:"predicateType"`
+	Predicate     SealPredicate `json:"predicate"`
+}
+
+// NewStatement returns an in-toto statement whose subjects are the sealed results, keyed by artifact name, e.g. the
+// file or unseal target the sealed data is written to. Every result must have been sealed with the same public key and
+// secret policy; the statement records the time of the latest result.
+func NewStatement(tool Tool, results map[string]*SealResult) (*Statement, error) {
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	statement := &Statement{
+		Type:          StatementType,
+		Subject:       make([]Subject, 0, len(names)),
+		PredicateType: SealPredicateType,
+		Predicate:     SealPredicate{Tool: tool},
+	}
+	for i, name := range names {
+		result := results[name]
+		predicate := SealPredicate{
+			Tool:           tool,
+			SealedAt:       statement.Predicate.SealedAt,
+			Tenant:         result.Tenant,
+			KeyVersion:     result.KeyVersion,
+			KeyFingerprint: result.KeyFingerprint,
+			Namespace:      result.Namespace,
+			Policy:         result.Policy,
+			PolicyID:       result.PolicyID,
+		}
+		if i > 0 && predicate != statement.Predicate {
+			return nil, fmt.Errorf("%s: %w", name, ErrMixedSealResults)
+		}
+		if result.SealedAt.After(predicate.SealedAt) {
+			predicate.SealedAt = result.SealedAt
+		}
+		statement.Predicate = predicate
+		digest := sha256.Sum256(result.Sealed)
+		statement.Subject = append(statement.Subject, Subject{
+			Name:   name,
+			Digest: map[string]string{"sha256": hex.EncodeToString(digest[:])},
+		})
+	}
+	return statement, nil
+}
+
+// Attestation is a DSSE envelope that holds a signed in-toto statement; the payload and signatures are base64 encoded
+// when marshaled to JSON.
+type Attestation struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     []byte      `json:"payload"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+// Signature is a DSSE signature, with the hex encoded SHA-256 digest of the DER encoded public key as key identifier.
+type Signature struct {
+	KeyID string `json:"keyid"`
+	Sig   []byte `json:"sig"`
+}
+
+// Sign returns an attestation that holds the statement signed by the signer, which must be an ECDSA, Ed25519, or RSA
+// private key. ECDSA and RSA signatures are made over the SHA-256 digest of the DSSE pre-authentication encoding.
+func (s *Statement) Sign(signer crypto.Signer) (*Attestation, error) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal statement: %w", err)
+	}
+	keyID, err := publicKeyID(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	message := preAuthEncoding(PayloadType, payload)
+	var sig []byte
+	switch signer.(type) {
+	case ed25519.PrivateKey:
+		sig, err = signer.Sign(rand.Reader, message, crypto.Hash(0))
+	case *ecdsa.PrivateKey, *rsa.PrivateKey:
+		digest := sha256.Sum256(message)
+		sig, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	default:
+		return nil, fmt.Errorf("%T: %w", signer, ErrUnsupportedKey)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign statement: %w", err)
+	}
+	return &Attestation{
+		PayloadType: PayloadType,
+		Payload:     payload,
+		Signatures:  []Signature{{KeyID: keyID, Sig: sig}},
+	}, nil
+}
+
+// Verify returns the statement held by the attestation if it has a valid signature from the public key, which must be
+// an ECDSA, Ed25519, or RSA public key.
+func (a *Attestation) Verify(pub crypto.PublicKey) (*Statement, error) {
+	if a.PayloadType != PayloadType {
+		return nil, fmt.Errorf("unexpected payload type %q: %w", a.PayloadType, ErrInvalidSignature)
+	}
+	keyID, err := publicKeyID(pub)
+	if err != nil {
+		return nil, err
+	}
+	message := preAuthEncoding(a.PayloadType, a.Payload)
+	digest := sha256.Sum256(message)
+	verified := false
+	for _, sig := range a.Signatures {
+		if sig.KeyID != keyID {
+			continue
+		}
+		switch key := pub.(type) {
+		case ed25519.PublicKey:
+			verified = ed25519.Verify(key, message, sig.Sig)
+		case *ecdsa.PublicKey:
+			verified = ecdsa.VerifyASN1(key, digest[:], sig.Sig)
+		case *rsa.PublicKey:
+			verified = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig.Sig) == nil
+		}
+		if verified {
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("no signature from key %s: %w", keyID, ErrInvalidSignature)
+	}
+	statement := &Statement{}
+	if err := json.Unmarshal(a.Payload, statement); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal statement: %w", err)
+	}
+	return statement, nil
+}
+
+// ReadSigningKey reads a PEM encoded PKCS#8, SEC 1 EC, or PKCS#1 RSA private key from the file at path, for use with
+// [Statement.Sign].
+func ReadSigningKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM encoded key: %w", path, ErrUnsupportedKey)
+	}
+	var key any
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%T: %w", key, ErrUnsupportedKey)
+	}
+	return signer, nil
+}
+
+// Returns the hex encoded SHA-256 digest of the DER encoded public key.
+func publicKeyID(pub crypto.PublicKey) (string, error) {
+	switch pub.(type) {
+	case ed25519.PublicKey, *ecdsa.PublicKey, *rsa.PublicKey:
+	default:
+		return "", fmt.Errorf("%T: %w", pub, ErrUnsupportedKey)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	digest := sha256.Sum256(der)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// Returns the DSSE v1 pre-authentication encoding of the payload, which is the message that is signed.
+func preAuthEncoding(payloadType string, payload []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("DSSEv1 ")
+	buf.WriteString(strconv.Itoa(len(payloadType)))
+	buf.WriteByte(' ')
+	buf.WriteString(payloadType)
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.Itoa(len(payload)))
+	buf.WriteByte(' ')
+	buf.Write(payload)
+	return buf.Bytes()
+}
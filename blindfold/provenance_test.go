@@ -0,0 +1,192 @@
+package blindfold_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/blindfold"
+)
+
+// Verify that statements name every sealed result, and refuse results sealed with different parameters.
+func TestNewStatement(t *testing.T) {
+	t.Parallel()
+	pubKey := &f5xc.PublicKey{KeyVersion: 3, Tenant: "acme"}
+	policyDoc := &f5xc.SecretPolicyDocument{
+		Metadata: &f5xc.Metadata{Name: "app", Namespace: "shared", Tenant: "acme"},
+		PolicyID: "policy-1",
+	}
+	first := blindfold.NewSealResult([]byte("c2VhbGVk\n"), pubKey, policyDoc)
+	second := blindfold.NewSealResult([]byte("b3RoZXI="), pubKey, policyDoc)
+	second.SealedAt = first.SealedAt.Add(time.Second)
+	tool := blindfold.Tool{Name: "f5xc", Version: "1.2.3"}
+	statement, err := blindfold.NewStatement(tool, map[string]*blindfold.SealResult{"b.txt": second, "a.txt": first})
+	if err != nil {
+		t.Fatalf("NewStatement raised an unexpected error: %v", err)
+	}
+	digest := sha256.Sum256([]byte("c2VhbGVk"))
+	expected := blindfold.SealPredicate{
+		Tool:       tool,
+		SealedAt:   second.SealedAt,
+		Tenant:     "acme",
+		KeyVersion: 3,
+		Namespace:  "shared",
+		Policy:     "app",
+		PolicyID:   "policy-1",
+	}
+	switch {
+	case statement.Type != blindfold.StatementType || statement.PredicateType != blindfold.SealPredicateType:
+		t.Errorf("Unexpected statement types %q and %q", statement.Type, statement.PredicateType)
+	case len(statement.Subject) != 2 || statement.Subject[0].Name != "a.txt" || statement.Subject[1].Name != "b.txt":
+		t.Errorf("Unexpected subjects %v", statement.Subject)
+	case statement.Subject[0].Digest["sha256"] != hex.EncodeToString(digest[:]):
+		t.Errorf("Unexpected digest %v", statement.Subject[0].Digest)
+	case statement.Predicate != expected:
+		t.Errorf("Expected predicate %+v, got %+v", expected, statement.Predicate)
+	}
+	other := blindfold.NewSealResult([]byte("b3RoZXI="), &f5xc.PublicKey{KeyVersion: 4, Tenant: "acme"}, policyDoc)
+	if _, err := blindfold.NewStatement(tool, map[string]*blindfold.SealResult{"a.txt": first, "c.txt": other}); !errors.Is(err, blindfold.ErrMixedSealResults) {
+		t.Errorf("Expected NewStatement to raise %v, got %v", blindfold.ErrMixedSealResults, err)
+	}
+}
+
+// Verify that sealed results and statements record the fingerprint of a valid public key, and that results sealed
+// with different keys of the same version are refused.
+func TestNewStatement_KeyFingerprint(t *testing.T) {
+	t.Parallel()
+	newPubKey := func() *f5xc.PublicKey {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("Failed to generate RSA key: %v", err)
+		}
+		return &f5xc.PublicKey{
+			KeyVersion:           3,
+			ModulusBase64:        base64.StdEncoding.EncodeToString(key.N.Bytes()),
+			PublicExponentBase64: base64.StdEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			Tenant:               "acme",
+		}
+	}
+	pubKey := newPubKey()
+	fingerprint, err := pubKey.Fingerprint()
+	if err != nil {
+		t.Fatalf("Fingerprint raised an unexpected error: %v", err)
+	}
+	policyDoc := &f5xc.SecretPolicyDocument{PolicyID: "policy-1"}
+	result := blindfold.NewSealResult([]byte("c2VhbGVk"), pubKey, policyDoc)
+	if result.KeyFingerprint != fingerprint {
+		t.Errorf("Expected result fingerprint %q, got %q", fingerprint, result.KeyFingerprint)
+	}
+	statement, err := blindfold.NewStatement(blindfold.Tool{Name: "f5xc"}, map[string]*blindfold.SealResult{"a.txt": result})
+	switch {
+	case err != nil:
+		t.Errorf("NewStatement raised an unexpected error: %v", err)
+	case statement.Predicate.KeyFingerprint != fingerprint:
+		t.Errorf("Expected predicate fingerprint %q, got %q", fingerprint, statement.Predicate.KeyFingerprint)
+	}
+	other := blindfold.NewSealResult([]byte("b3RoZXI="), newPubKey(), policyDoc)
+	if _, err := blindfold.NewStatement(blindfold.Tool{Name: "f5xc"}, map[string]*blindfold.SealResult{"a.txt": result, "b.txt": other}); !errors.Is(err, blindfold.ErrMixedSealResults) {
+		t.Errorf("Expected NewStatement to raise %v, got %v", blindfold.ErrMixedSealResults, err)
+	}
+}
+
+// Verify that statements signed with each supported key type can be read back and verified, and that signatures from
+// another key are rejected.
+func TestStatementSign(t *testing.T) {
+	t.Parallel()
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	ecDER, err := x509.MarshalECPrivateKey(ecdsaKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal ECDSA key: %v", err)
+	}
+	pkcs8DER, err := x509.MarshalPKCS8PrivateKey(ed25519Key)
+	if err != nil {
+		t.Fatalf("Failed to marshal Ed25519 key: %v", err)
+	}
+	tests := []struct {
+		name  string
+		block *pem.Block
+		key   crypto.Signer
+	}{
+		{
+			name:  "ed25519-pkcs8",
+			block: &pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8DER},
+			key:   ed25519Key,
+		},
+		{
+			name:  "ecdsa-sec1",
+			block: &pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER},
+			key:   ecdsaKey,
+		},
+		{
+			name:  "rsa-pkcs1",
+			block: &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)},
+			key:   rsaKey,
+		},
+	}
+	statement, err := blindfold.NewStatement(blindfold.Tool{Name: "test"}, map[string]*blindfold.SealResult{
+		"a.txt": blindfold.NewSealResult([]byte("c2VhbGVk"), nil, nil),
+	})
+	if err != nil {
+		t.Fatalf("NewStatement raised an unexpected error: %v", err)
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "key.pem")
+			if err := os.WriteFile(path, pem.EncodeToMemory(tst.block), 0o600); err != nil {
+				t.Fatalf("Failed to write key: %v", err)
+			}
+			signer, err := blindfold.ReadSigningKey(path)
+			if err != nil {
+				t.Fatalf("ReadSigningKey raised an unexpected error: %v", err)
+			}
+			attestation, err := statement.Sign(signer)
+			if err != nil {
+				t.Fatalf("Sign raised an unexpected error: %v", err)
+			}
+			verified, err := attestation.Verify(tst.key.Public())
+			switch {
+			case err != nil:
+				t.Errorf("Verify raised an unexpected error: %v", err)
+			case len(verified.Subject) != 1 || verified.Subject[0].Name != "a.txt":
+				t.Errorf("Unexpected verified statement %+v", verified)
+			}
+			if _, err := attestation.Verify(ecdsaKey.Public()); tst.key != ecdsaKey && !errors.Is(err, blindfold.ErrInvalidSignature) {
+				t.Errorf("Expected Verify with another key to raise %v, got %v", blindfold.ErrInvalidSignature, err)
+			}
+			attestation.Payload = append(attestation.Payload, ' ')
+			if _, err := attestation.Verify(tst.key.Public()); !errors.Is(err, blindfold.ErrInvalidSignature) {
+				t.Errorf("Expected Verify of a modified payload to raise %v, got %v", blindfold.ErrInvalidSignature, err)
+			}
+		})
+	}
+	if _, err := blindfold.ReadSigningKey(filepath.Join("testdata", "missing.pem")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected ReadSigningKey to raise %v, got %v", os.ErrNotExist, err)
+	}
+}
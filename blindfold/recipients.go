@@ -0,0 +1,175 @@
+package blindfold
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/memes/f5xc"
+)
+
+var (
+	// ErrInvalidRecipient is returned when a recipient, or a line of a recipients file, cannot be parsed.
+	ErrInvalidRecipient = errors.New("invalid recipient")
+	// ErrRecipientNotFound is returned when a recipient cannot be selected from a recipients file.
+	ErrRecipientNotFound = errors.New("recipient not found")
+	// ErrRecipientMismatch is returned when a public key or secret policy document does not belong to the tenant, or
+	// have the key version, of a recipient.
+	ErrRecipientMismatch = errors.New("sealing parameters do not match recipient")
+)
+
+// Recipient identifies the tenant, public key version, and secret policy that plaintext is sealed for. As a string a
+// recipient has the form TENANT/NAMESPACE/POLICY, with an optional @KEY_VERSION suffix to pin the public key version,
+// e.g. acme/shared/app-secrets@3.
+type Recipient struct {
+	// An optional name that selects the recipient from a recipients file, e.g. prod or staging.
+	Name string
+	// The tenant that owns the public key and secret policy.
+	Tenant string
+	// The namespace of the secret policy.
+	Namespace string
+	// The name of the secret policy.
+	Policy string
+	// The version of the public key; 0 to use the current version.
+	KeyVersion int
+}
+
+// ParseRecipient parses a recipient from its string form, TENANT/NAMESPACE/POLICY[@KEY_VERSION].
+func ParseRecipient(s string) (*Recipient, error) {
+	ref, version, pinned := strings.Cut(s, "@")
+	r := &Recipient{}
+	if pinned {
+		var err error
+		if r.KeyVersion, err = strconv.Atoi(version); err != nil || r.KeyVersion <= 0 {
+			return nil, fmt.Errorf("%q: key version must be a positive integer: %w", s, ErrInvalidRecipient)
+		}
+	}
+	parts := strings.Split(ref, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("%q must be in the form TENANT/NAMESPACE/POLICY[@KEY_VERSION]: %w", s, ErrInvalidRecipient)
+	}
+	r.Tenant, r.Namespace, r.Policy = parts[0], parts[1], parts[2]
+	return r, nil
+}
+
+// String returns the recipient in the form TENANT/NAMESPACE/POLICY[@KEY_VERSION], without the name.
+func (r *Recipient) String() string {
+	s := r.Tenant + "/" + r.Namespace + "/" + r.Policy
+	if r.KeyVersion > 0 {
+		s += "@" + strconv.Itoa(r.KeyVersion)
+	}
+	return s
+}
+
+// Verify returns an error if the public key or secret policy document belong to a tenant other than the recipient
+// tenant, or if the recipient pins a key version that differs from the public key. A tenant that is not reported by
+// the public key or policy document is not checked.
+func (r *Recipient) Verify(pubKey *f5xc.PublicKey, policyDoc *f5xc.SecretPolicyDocument) error {
+	switch {
+	case pubKey != nil && pubKey.Tenant != "" && pubKey.Tenant != r.Tenant:
+		return fmt.Errorf("public key belongs to tenant %s, not %s: %w", pubKey.Tenant, r.Tenant, ErrRecipientMismatch)
+	case pubKey != nil && r.KeyVersion > 0 && pubKey.KeyVersion != r.KeyVersion:
+		return fmt.Errorf("public key has version %d, not %d: %w", pubKey.KeyVersion, r.KeyVersion, ErrRecipientMismatch)
+	case policyDoc != nil && policyDoc.Metadata != nil && policyDoc.Tenant != "" && policyDoc.Tenant != r.Tenant:
+		return fmt.Errorf("secret policy belongs to tenant %s, not %s: %w", policyDoc.Tenant, r.Tenant, ErrRecipientMismatch)
+	}
+	return nil
+}
+
+// ParseRecipients reads a recipients file, in the spirit of an age recipients file; each line holds a recipient,
+// optionally preceded by a name and whitespace, and blank lines and lines starting with # are ignored. Names must be
+// unique within the file.
+//
+//	# Production and staging tenants share a pipeline
+//	prod     acme/shared/app-secrets@3
+//	staging  acme-staging/shared/app-secrets
+func ParseRecipients(reader io.Reader) ([]*Recipient, error) {
+	var recipients []*Recipient
+	names := map[string]struct{}{}
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		var name string
+		switch len(fields) {
+		case 1:
+		case 2:
+			name = fields[0]
+		default:
+			return nil, fmt.Errorf("line %d: expected [NAME] RECIPIENT: %w", line, ErrInvalidRecipient)
+		}
+		r, err := ParseRecipient(fields[len(fields)-1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if name != "" {
+			if _, ok := names[name]; ok {
+				return nil, fmt.Errorf("line %d: recipient name %s is repeated: %w", line, name, ErrInvalidRecipient)
+			}
+			names[name] = struct{}{}
+		}
+		r.Name = name
+		recipients = append(recipients, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recipients: %w", err)
+	}
+	return recipients, nil
+}
+
+// ReadRecipientsFile reads the recipients from the file at path; see [ParseRecipients] for the format.
+func ReadRecipientsFile(path string) ([]*Recipient, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recipients file: %w", err)
+	}
+	defer f.Close()
+	recipients, err := ParseRecipients(f)
+	if err != nil {
+		return nil, fmt.Errorf("recipients file %s: %w", path, err)
+	}
+	return recipients, nil
+}
+
+// SelectRecipient returns the recipient with the name, or whose string form is name. If name is empty the only
+// recipient is returned, and it is an error for there to be more than one.
+func SelectRecipient(recipients []*Recipient, name string) (*Recipient, error) {
+	if name == "" {
+		if len(recipients) != 1 {
+			return nil, fmt.Errorf("a name is required to select one of %d recipients: %w", len(recipients), ErrRecipientNotFound)
+		}
+		return recipients[0], nil
+	}
+	for _, r := range recipients {
+		if r.Name == name || r.String() == name {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", name, ErrRecipientNotFound)
+}
+
+// SealForRecipient executes vesctl to blindfold the supplied plaintext for the recipient, using a public key and secret
+// policy document that have been written to the cache directory, and returns the Base64 encoded sealed data. The
+// cached public key and policy document must match the recipient tenant and any pinned key version.
+func SealForRecipient(ctx context.Context, vesctl, dir string, plaintext []byte, r *Recipient) ([]byte, error) {
+	pubKey, err := ReadCachedPublicKey(dir, r.KeyVersion)
+	if err != nil {
+		return nil, err
+	}
+	policyDoc, err := ReadCachedPolicyDocument(dir, r.Namespace, r.Policy)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Verify(pubKey, policyDoc); err != nil {
+		return nil, err
+	}
+	return Seal(ctx, vesctl, plaintext, pubKey, policyDoc)
+}
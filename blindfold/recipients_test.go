@@ -0,0 +1,206 @@
+package blindfold_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/blindfold"
+)
+
+// Verify that recipients are parsed from, and formatted to, their string form.
+func TestParseRecipient(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		value       string
+		expected    blindfold.Recipient
+		expectedErr error
+	}{
+		{
+			name:     "current",
+			value:    "acme/shared/app-secrets",
+			expected: blindfold.Recipient{Tenant: "acme", Namespace: "shared", Policy: "app-secrets"},
+		},
+		{
+			name:     "pinned",
+			value:    "acme/shared/app-secrets@3",
+			expected: blindfold.Recipient{Tenant: "acme", Namespace: "shared", Policy: "app-secrets", KeyVersion: 3},
+		},
+		{
+			name:        "missing-tenant",
+			value:       "shared/app-secrets",
+			expectedErr: blindfold.ErrInvalidRecipient,
+		},
+		{
+			name:        "empty-policy",
+			value:       "acme/shared/",
+			expectedErr: blindfold.ErrInvalidRecipient,
+		},
+		{
+			name:        "invalid-version",
+			value:       "acme/shared/app-secrets@0",
+			expectedErr: blindfold.ErrInvalidRecipient,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			r, err := blindfold.ParseRecipient(tst.value)
+			switch {
+			case tst.expectedErr != nil:
+				if !errors.Is(err, tst.expectedErr) {
+					t.Errorf("Expected ParseRecipient to raise %v, got %v", tst.expectedErr, err)
+				}
+			case err != nil:
+				t.Errorf("ParseRecipient raised an unexpected error: %v", err)
+			case *r != tst.expected:
+				t.Errorf("Expected %+v, got %+v", tst.expected, *r)
+			case r.String() != tst.value:
+				t.Errorf("Expected String to return %q, got %q", tst.value, r.String())
+			}
+		})
+	}
+}
+
+// Verify that recipients files are parsed, and that recipients can be selected from them.
+func TestParseRecipients(t *testing.T) {
+	t.Parallel()
+	recipients, err := blindfold.ParseRecipients(strings.NewReader(`# Tenants for the app pipeline
+
+prod     acme/shared/app-secrets@3
+staging  acme-staging/shared/app-secrets
+  acme-dev/dev/app-secrets
+`))
+	if err != nil {
+		t.Fatalf("ParseRecipients raised an unexpected error: %v", err)
+	}
+	if len(recipients) != 3 {
+		t.Fatalf("Expected 3 recipients, got %d", len(recipients))
+	}
+	tests := []struct {
+		name           string
+		selector       string
+		expectedTenant string
+		expectedErr    error
+	}{
+		{
+			name:           "by-name",
+			selector:       "staging",
+			expectedTenant: "acme-staging",
+		},
+		{
+			name:           "by-recipient",
+			selector:       "acme-dev/dev/app-secrets",
+			expectedTenant: "acme-dev",
+		},
+		{
+			name:        "unknown",
+			selector:    "test",
+			expectedErr: blindfold.ErrRecipientNotFound,
+		},
+		{
+			name:        "ambiguous",
+			expectedErr: blindfold.ErrRecipientNotFound,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			r, err := blindfold.SelectRecipient(recipients, tst.selector)
+			switch {
+			case tst.expectedErr != nil:
+				if !errors.Is(err, tst.expectedErr) {
+					t.Errorf("Expected SelectRecipient to raise %v, got %v", tst.expectedErr, err)
+				}
+			case err != nil:
+				t.Errorf("SelectRecipient raised an unexpected error: %v", err)
+			case r.Tenant != tst.expectedTenant:
+				t.Errorf("Expected tenant %s, got %s", tst.expectedTenant, r.Tenant)
+			}
+		})
+	}
+	for _, invalid := range []string{"prod acme/shared/a\nprod acme/shared/b\n", "prod acme/shared/a extra\n", "prod acme\n"} {
+		if _, err := blindfold.ParseRecipients(strings.NewReader(invalid)); !errors.Is(err, blindfold.ErrInvalidRecipient) {
+			t.Errorf("Expected ParseRecipients(%q) to raise %v, got %v", invalid, blindfold.ErrInvalidRecipient, err)
+		}
+	}
+}
+
+// Verify that sealing parameters from another tenant, or with another key version, are rejected.
+func TestRecipientVerify(t *testing.T) {
+	t.Parallel()
+	r := &blindfold.Recipient{Tenant: "acme", Namespace: "shared", Policy: "app", KeyVersion: 2}
+	policyDoc := &f5xc.SecretPolicyDocument{Metadata: &f5xc.Metadata{Name: "app", Namespace: "shared", Tenant: "acme"}}
+	tests := []struct {
+		name        string
+		pubKey      *f5xc.PublicKey
+		policyDoc   *f5xc.SecretPolicyDocument
+		expectedErr error
+	}{
+		{
+			name:      "match",
+			pubKey:    &f5xc.PublicKey{KeyVersion: 2, Tenant: "acme"},
+			policyDoc: policyDoc,
+		},
+		{
+			name:      "unknown-tenant",
+			pubKey:    &f5xc.PublicKey{KeyVersion: 2},
+			policyDoc: &f5xc.SecretPolicyDocument{},
+		},
+		{
+			name:        "key-tenant",
+			pubKey:      &f5xc.PublicKey{KeyVersion: 2, Tenant: "acme-staging"},
+			policyDoc:   policyDoc,
+			expectedErr: blindfold.ErrRecipientMismatch,
+		},
+		{
+			name:        "key-version",
+			pubKey:      &f5xc.PublicKey{KeyVersion: 3, Tenant: "acme"},
+			policyDoc:   policyDoc,
+			expectedErr: blindfold.ErrRecipientMismatch,
+		},
+		{
+			name:   "policy-tenant",
+			pubKey: &f5xc.PublicKey{KeyVersion: 2, Tenant: "acme"},
+			policyDoc: &f5xc.SecretPolicyDocument{
+				Metadata: &f5xc.Metadata{Name: "app", Namespace: "shared", Tenant: "acme-staging"},
+			},
+			expectedErr: blindfold.ErrRecipientMismatch,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			if err := r.Verify(tst.pubKey, tst.policyDoc); !errors.Is(err, tst.expectedErr) {
+				t.Errorf("Expected Verify to return %v, got %v", tst.expectedErr, err)
+			}
+		})
+	}
+}
+
+// Verify that cached sealing parameters for another tenant are rejected before vesctl is executed.
+func TestSealForRecipient_Mismatch(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	if err := blindfold.WriteCachedPublicKey(dir, &f5xc.PublicKey{KeyVersion: 1, Tenant: "acme-staging"}, true); err != nil {
+		t.Fatalf("WriteCachedPublicKey raised an unexpected error: %v", err)
+	}
+	policyDoc := &f5xc.SecretPolicyDocument{Metadata: &f5xc.Metadata{Name: "app", Namespace: "shared", Tenant: "acme-staging"}}
+	if err := blindfold.WriteCachedPolicyDocument(dir, "", "", policyDoc); err != nil {
+		t.Fatalf("WriteCachedPolicyDocument raised an unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	r := &blindfold.Recipient{Tenant: "acme", Namespace: "shared", Policy: "app"}
+	if _, err := blindfold.SealForRecipient(ctx, "", dir, []byte("plaintext"), r); !errors.Is(err, blindfold.ErrRecipientMismatch) {
+		t.Errorf("Expected SealForRecipient to raise %v, got %v", blindfold.ErrRecipientMismatch, err)
+	}
+	r.Policy = "missing"
+	if _, err := blindfold.SealForRecipient(ctx, "", dir, []byte("plaintext"), r); !errors.Is(err, blindfold.ErrNotCached) {
+		t.Errorf("Expected SealForRecipient to raise %v, got %v", blindfold.ErrNotCached, err)
+	}
+}
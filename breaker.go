@@ -0,0 +1,132 @@
+package f5xc
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by a client created with [WithCircuitBreaker] when requests are failing fast because the
+// API has failed too many times in a row.
+var ErrCircuitOpen = errors.New("circuit breaker is open, API requests are failing fast")
+
+// Stop sending requests to the API for the cooldown period after threshold consecutive requests fail with a transport
+// error or a 5xx status; while the breaker is open every request fails immediately with an error that wraps
+// [ErrCircuitOpen], so that callers are not blocked waiting on an unreachable endpoint. When the cooldown has elapsed a
+// single trial request is sent; the breaker closes if it succeeds, and stays open for another cooldown period if it
+// fails. Requests that are canceled by the caller are not counted as failures. When combined with [WithRetryPolicy]
+// every attempt is counted, and a request is not retried while the breaker is open.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding circuit breaker", "threshold", threshold, "cooldown", cooldown)
+		switch {
+		case threshold < 1:
+			return fmt.Errorf("circuit breaker threshold must be positive: %w", ErrInvalidOption)
+		case cooldown <= 0:
+			return fmt.Errorf("circuit breaker cooldown must be positive: %w", ErrInvalidOption)
+		}
+		c.breakerThreshold = threshold
+		c.breakerCooldown = cooldown
+		return nil
+	}
+}
+
+// Implements a RoundTripper that fails fast while the base RoundTripper is failing consistently.
+type breakerTransport struct {
+	// The RoundTripper that calls the API.
+	base http.RoundTripper
+	// The number of consecutive failures that open the breaker, and the time it stays open.
+	threshold int
+	cooldown  time.Duration
+	// Guards the state of the breaker.
+	mu sync.Mutex
+	// The number of consecutive failures.
+	failures int
+	// The time when the breaker opened, or zero if it is closed.
+	openedAt time.Time
+	// True while a trial request is in flight after the cooldown.
+	probing bool
+	// The logger of the client.
+	logger *slog.Logger
+}
+
+// Implements RoundTripper by sending the request with the base RoundTripper unless the breaker is open, and recording
+// whether it succeeded.
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.allow(); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	switch {
+	case err != nil && req.Context().Err() != nil:
+		// The caller gave up on the request, which says nothing about the health of the API
+		t.release()
+	case err != nil, resp.StatusCode >= http.StatusInternalServerError:
+		t.failure()
+	default:
+		t.success()
+	}
+	return resp, err //nolint:wrapcheck // Errors are returned unchanged by the circuit breaker transport
+}
+
+// Forward CloseIdleConnections to the base RoundTripper.
+func (t *breakerTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// Returns the base RoundTripper, so that the transport of the client can be found.
+func (t *breakerTransport) Unwrap() http.RoundTripper {
+	return t.base
+}
+
+// Returns nil if a request may be sent, or an error that wraps ErrCircuitOpen. Once the cooldown has elapsed the first
+// caller becomes the trial request, and others continue to fail until it completes.
+func (t *breakerTransport) allow() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.openedAt.IsZero() {
+		return nil
+	}
+	if remaining := t.cooldown - time.Since(t.openedAt); remaining > 0 || t.probing {
+		return fmt.Errorf("%d consecutive failures, retry in %v: %w", t.failures, max(remaining, 0).Round(time.Millisecond), ErrCircuitOpen)
+	}
+	t.logger.Debug("Circuit breaker cooldown has elapsed, sending trial request")
+	t.probing = true
+	return nil
+}
+
+// Records a failed request, opening the breaker if the threshold is reached or the trial request failed.
+func (t *breakerTransport) failure() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failures++
+	if t.probing || (t.openedAt.IsZero() && t.failures >= t.threshold) {
+		t.logger.Warn("Circuit breaker is open", "failures", t.failures, "cooldown", t.cooldown)
+		t.openedAt = time.Now()
+	}
+	t.probing = false
+}
+
+// Records a successful request, closing the breaker.
+func (t *breakerTransport) success() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.openedAt.IsZero() {
+		t.logger.Info("Circuit breaker is closed")
+	}
+	t.failures = 0
+	t.openedAt = time.Time{}
+	t.probing = false
+}
+
+// Records a request that did not complete, allowing another trial request.
+func (t *breakerTransport) release() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.probing = false
+}
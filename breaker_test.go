@@ -0,0 +1,93 @@
+package f5xc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+)
+
+// Verify that a client with a circuit breaker fails fast after consecutive failures, and recovers after the cooldown
+// when a trial request succeeds.
+func TestWithCircuitBreaker(t *testing.T) {
+	t.Parallel()
+	const cooldown = 100 * time.Millisecond
+	var requests atomic.Int32
+	var failing atomic.Bool
+	failing.Store(true)
+	client, serverURL := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}), f5xc.WithCircuitBreaker(2, cooldown))
+	send := func() (int, error) {
+		t.Helper()
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, serverURL+f5xc.PublicKeyURL, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	steps := []struct {
+		name             string
+		wait             bool
+		healthy          bool
+		expectedStatus   int
+		expectedRequests int32
+	}{
+		{name: "first-failure", expectedStatus: http.StatusServiceUnavailable, expectedRequests: 1},
+		{name: "second-failure", expectedStatus: http.StatusServiceUnavailable, expectedRequests: 2},
+		{name: "open", expectedRequests: 2},
+		{name: "failed-trial", wait: true, expectedStatus: http.StatusServiceUnavailable, expectedRequests: 3},
+		{name: "reopened", expectedRequests: 3},
+		{name: "successful-trial", wait: true, healthy: true, expectedStatus: http.StatusNoContent, expectedRequests: 4},
+		{name: "closed", healthy: true, expectedStatus: http.StatusNoContent, expectedRequests: 5},
+	}
+	for _, step := range steps {
+		if step.wait {
+			time.Sleep(cooldown)
+		}
+		failing.Store(!step.healthy)
+		status, err := send()
+		switch {
+		case step.expectedStatus == 0 && !errors.Is(err, f5xc.ErrCircuitOpen):
+			t.Errorf("%s: expected request to raise %v, got %v", step.name, f5xc.ErrCircuitOpen, err)
+		case step.expectedStatus != 0 && err != nil:
+			t.Errorf("%s: request raised an unexpected error: %v", step.name, err)
+		case status != step.expectedStatus:
+			t.Errorf("%s: expected status %d, got %d", step.name, step.expectedStatus, status)
+		}
+		if count := requests.Load(); count != step.expectedRequests {
+			t.Errorf("%s: expected %d requests, got %d", step.name, step.expectedRequests, count)
+		}
+	}
+}
+
+// Verify that invalid circuit breaker settings are rejected.
+func TestWithCircuitBreaker_Invalid(t *testing.T) {
+	t.Parallel()
+	for _, tst := range []struct {
+		threshold int
+		cooldown  time.Duration
+	}{
+		{threshold: 0, cooldown: time.Second},
+		{threshold: 1, cooldown: 0},
+	} {
+		_, err := f5xc.NewClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"), f5xc.WithCircuitBreaker(tst.threshold, tst.cooldown))
+		if !errors.Is(err, f5xc.ErrInvalidOption) {
+			t.Errorf("Expected NewClient to raise %v for %+v, got %v", f5xc.ErrInvalidOption, tst, err)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package f5xc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	// The header added to responses that were handled by the memory or disk cache of a client; the value is one of hit,
+	// stale, or miss.
+	CacheHeader = "X-F5xc-Cache"
+	// The default time that an expired disk cache entry can be returned while the API is unavailable.
+	DefaultDiskCacheMaxStale = 24 * time.Hour
+	// The name of the file in a disk cache directory that holds the generated integrity key.
+	diskCacheKeyFile = "cache.key"
+	// The size of a generated integrity key, and the minimum size of a supplied key.
+	diskCacheKeySize = 32
+	// The values of the CacheHeader.
+	cacheHit   = "hit"
+	cacheStale = "stale"
+	cacheMiss  = "miss"
+)
+
+// Store public keys and secret policy documents fetched by the client in the directory, and return them without calling
+// the API until they are older than ttl; e.g. so that short-lived CLI invocations do not fetch the same key and policy
+// on every execution. Each entry is protected by an HMAC, and an entry that fails verification is ignored. The HMAC key
+// is generated and stored in the directory unless one is set with [WithDiskCacheKey]. An expired entry is returned if
+// the API cannot be reached or responds with a retryable status, for up to [DefaultDiskCacheMaxStale] unless changed
+// with [WithDiskCacheMaxStale]. Entries are separated by API endpoint host and by the headers of the request and its
+// context, e.g. from [ContextWithHeaders], so a directory can be shared by tenants. Conditional requests are not
+// cached.
+func WithDiskCache(dir string, ttl time.Duration) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding disk cache", "dir", dir, "ttl", ttl)
+		switch {
+		case dir == "":
+			return fmt.Errorf("disk cache directory must not be empty: %w", ErrInvalidOption)
+		case ttl <= 0:
+			return fmt.Errorf("disk cache ttl must be positive: %w", ErrInvalidOption)
+		}
+		c.cacheDir = dir
+		c.cacheTTL = ttl
+		return nil
+	}
+}
+
+// Use the key to protect the integrity of disk cache entries instead of a key generated and stored in the cache
+// directory; the key must have at least 32 bytes. This option has no effect without [WithDiskCache].
+func WithDiskCacheKey(key []byte) Option {
+	return func(c *config) error {
+		if len(key) < diskCacheKeySize {
+			return fmt.Errorf("disk cache key must have at least %d bytes: %w", diskCacheKeySize, ErrInvalidOption)
+		}
+		c.cacheKey = key
+		return nil
+	}
+}
+
+// Set the time that an expired disk cache entry can be returned while the API is unavailable; zero disables the use of
+// expired entries. This option has no effect without [WithDiskCache].
+func WithDiskCacheMaxStale(maxStale time.Duration) Option {
+	return func(c *config) error {
+		if maxStale < 0 {
+			return fmt.Errorf("disk cache max stale must not be negative: %w", ErrInvalidOption)
+		}
+		c.cacheMaxStale = maxStale
+		return nil
+	}
+}
+
+// Implements a RoundTripper that returns public keys and secret policy documents from a directory when possible.
+type diskCache struct {
+	// The RoundTripper that calls the API.
+	base http.RoundTripper
+	// The directory that holds the entries.
+	dir string
+	// The key of the HMAC that protects each entry.
+	key []byte
+	// The time an entry is returned without calling the API.
+	ttl time.Duration
+	// The additional time an entry can be returned if the API is unavailable.
+	maxStale time.Duration
+	// The API endpoint host, which is part of the name of every entry.
+	host string
+	// The maximum number of bytes of a response body that are stored.
+	maxResponseSize int64
+	// The logger of the client.
+	logger *slog.Logger
+}
+
+// A disk cache entry as it is stored in a file.
+type diskCacheEntry struct {
+	// The time the response was stored.
+	StoredAt time.Time `json:"storedAt"`
+	// The body of the API response.
+	Body []byte `json:"body"`
+	// The HMAC of the entry name, time, and body.
+	MAC []byte `json:"mac"`
+}
+
+// Returns a disk cache that wraps the base RoundTripper, creating the cache directory and integrity key as needed.
+func newDiskCache(base http.RoundTripper, cfg *config) (*diskCache, error) {
+	if err := os.MkdirAll(cfg.cacheDir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create disk cache directory: %w", err)
+	}
+	key := cfg.cacheKey
+	if key == nil {
+		var err error
+		if key, err = readOrCreateDiskCacheKey(filepath.Join(cfg.cacheDir, diskCacheKeyFile), cfg.log()); err != nil {
+			return nil, err
+		}
+	}
+	return &diskCache{
+		base:            base,
+		dir:             cfg.cacheDir,
+		key:             key,
+		ttl:             cfg.cacheTTL,
+		maxStale:        cfg.cacheMaxStale,
+		host:            cfg.EndpointURL.Host,
+		maxResponseSize: cfg.maxResponseSize,
+		logger:          cfg.log(),
+	}, nil
+}
+
+// Returns the key stored in the file, or generates a key and writes it to the file if it does not exist.
+func readOrCreateDiskCacheKey(keyPath string, logger *slog.Logger) ([]byte, error) {
+	key, err := os.ReadFile(keyPath)
+	switch {
+	case err == nil && len(key) >= diskCacheKeySize:
+		return key, nil
+	case err == nil:
+		return nil, fmt.Errorf("disk cache key %s is too short: %w", keyPath, ErrInvalidOption)
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("failed to read disk cache key %s: %w", keyPath, err)
+	}
+	logger.Debug("Generating disk cache key", "path", keyPath)
+	key = make([]byte, diskCacheKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate disk cache key: %w", err)
+	}
+	file, err := os.OpenFile(keyPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		// Another process created the key first
+		return readOrCreateDiskCacheKey(keyPath, logger)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create disk cache key %s: %w", keyPath, err)
+	}
+	if _, err := file.Write(key); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to write disk cache key %s: %w", keyPath, err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write disk cache key %s: %w", keyPath, err)
+	}
+	return key, nil
+}
+
+// Returns true if the response to a GET request for the path can be cached.
+func isDiskCacheable(requestPath string) bool {
+	if requestPath == PublicKeyURL {
+		return true
+	}
+	matched, _ := path.Match(fmt.Sprintf(SecretPolicyDocumentURL, "*", "*"), requestPath)
+	return matched
+}
+
+// Returns true if the response to the request can be cached; conditional requests are always sent to the API, so that
+// the API can respond that the resource has not changed.
+func isCacheable(req *http.Request) bool {
+	return req.Method == http.MethodGet && isDiskCacheable(req.URL.Path) &&
+		req.Header.Get("If-None-Match") == "" && req.Header.Get("If-Modified-Since") == ""
+}
+
+// The request headers that do not change the response, and are not part of the cache key of a request.
+var cacheKeyIgnoredHeaders = map[string]bool{
+	"Baggage":       true,
+	"Content-Type":  true,
+	RequestIDHeader: true,
+	"Traceparent":   true,
+	"Tracestate":    true,
+	"User-Agent":    true,
+}
+
+// Returns the cache key of a request, which is the request URI and, if the request or its context has headers that may
+// change the response, e.g. the tenant of a client that serves several tenants, a digest of those headers so that their
+// values are not exposed in logs. Headers of the request replace headers of the context, as they do in the transport.
+func cacheKey(req *http.Request) string {
+	headers := HeadersFromContext(req.Context())
+	if headers == nil {
+		headers = http.Header{}
+	}
+	for key, values := range req.Header {
+		headers[http.CanonicalHeaderKey(key)] = values
+	}
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		if !cacheKeyIgnoredHeaders[key] {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return req.URL.RequestURI()
+	}
+	slices.Sort(keys)
+	digest := sha256.New()
+	for _, key := range keys {
+		for _, value := range headers[key] {
+			digest.Write([]byte(key + ":" + value + "\n"))
+		}
+	}
+	return req.URL.RequestURI() + "#" + hex.EncodeToString(digest.Sum(nil))
+}
+
+// Implements RoundTripper by returning an entry from the cache directory if it has not expired, or by calling the API
+// and storing a successful response in the cache directory. If the API is unavailable an expired entry is returned if
+// it is not older than the maximum stale time.
+func (c *diskCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isCacheable(req) {
+		return c.base.RoundTrip(req) //nolint:wrapcheck // Errors are returned unchanged by the cache
+	}
+	name := c.host + cacheKey(req)
+	entryPath := filepath.Join(c.dir, diskCacheEntryFile(name))
+	logger := c.logger.With("name", name, "path", entryPath)
+	entry := c.read(entryPath, name)
+	if entry != nil {
+		if age := time.Since(entry.StoredAt); age < c.ttl {
+			logger.Debug("Returning response from disk cache", "age", age)
+			return entry.response(req, cacheHit), nil
+		}
+		if time.Since(entry.StoredAt) >= c.ttl+c.maxStale {
+			entry = nil
+		}
+	}
+	resp, err := c.base.RoundTrip(req)
+	switch {
+	case err != nil && entry != nil && IsRetryable(err):
+		logger.Warn("API request failed, returning expired response from disk cache", "error", err)
+		return entry.response(req, cacheStale), nil
+	case err != nil:
+		return nil, err //nolint:wrapcheck // Errors are returned unchanged by the cache
+	case resp.StatusCode == http.StatusOK:
+		body, err := readAllLimit(resp.Body, c.maxResponseSize)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err //nolint:wrapcheck // Errors are returned unchanged by the cache
+		}
+		if err := c.write(entryPath, name, body); err != nil {
+			logger.Warn("Failed to write response to disk cache", "error", err)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		resp.Header.Set(CacheHeader, cacheMiss)
+	case entry != nil && RetryableStatus(resp.StatusCode):
+		logger.Warn("API is unavailable, returning expired response from disk cache", "statusCode", resp.StatusCode)
+		_ = resp.Body.Close()
+		return entry.response(req, cacheStale), nil
+	}
+	return resp, nil
+}
+
+// Forward CloseIdleConnections to the base RoundTripper.
+func (c *diskCache) CloseIdleConnections() {
+	if closer, ok := c.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// Returns the base RoundTripper, so that the transport of the client can be found.
+func (c *diskCache) Unwrap() http.RoundTripper {
+	return c.base
+}
+
+// Returns the name of the file that holds the entry with the name.
+func diskCacheEntryFile(name string) string {
+	digest := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(digest[:]) + ".json"
+}
+
+// Returns the HMAC of the entry name, time, and body.
+func (c *diskCache) mac(name string, storedAt time.Time, body []byte) []byte {
+	h := hmac.New(sha256.New, c.key)
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatInt(storedAt.UnixNano(), 10)))
+	h.Write([]byte{0})
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// Returns the entry stored in the file, or nil if there is no entry or it cannot be verified.
+func (c *diskCache) read(entryPath, name string) *diskCacheEntry {
+	data, err := os.ReadFile(entryPath)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			c.logger.Warn("Failed to read disk cache entry", "path", entryPath, "error", err)
+		}
+		return nil
+	}
+	entry := &diskCacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil || !hmac.Equal(entry.MAC, c.mac(name, entry.StoredAt, entry.Body)) {
+		c.logger.Warn("Ignoring disk cache entry that failed verification", "path", entryPath)
+		return nil
+	}
+	return entry
+}
+
+// Writes an entry for the body to the file, replacing any existing entry.
+func (c *diskCache) write(entryPath, name string, body []byte) error {
+	storedAt := time.Now().UTC()
+	data, err := json.Marshal(diskCacheEntry{
+		StoredAt: storedAt,
+		Body:     body,
+		MAC:      c.mac(name, storedAt, body),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal disk cache entry: %w", err)
+	}
+	file, err := os.CreateTemp(c.dir, ".entry-*")
+	if err != nil {
+		return fmt.Errorf("failed to create disk cache entry: %w", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write disk cache entry: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write disk cache entry: %w", err)
+	}
+	if err := os.Rename(file.Name(), entryPath); err != nil {
+		return fmt.Errorf("failed to replace disk cache entry: %w", err)
+	}
+	return nil
+}
+
+// Returns a response to the request that has the body of the entry.
+func (e *diskCacheEntry) response(req *http.Request, status string) *http.Response {
+	return cachedResponse(req, e.Body, status)
+}
+
+// Returns a successful JSON response to the request with the cached body, and the status of the cache in CacheHeader.
+func cachedResponse(req *http.Request, body []byte, status string) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}, CacheHeader: {status}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,233 @@
+package f5xc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+)
+
+// Returns a handler that counts requests, and returns a public key with the version of the request count or the
+// status code if it is not 0.
+func testCacheHandler(requests *atomic.Int32, status *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		count := requests.Add(1)
+		if code := status.Load(); code != 0 {
+			w.WriteHeader(int(code))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{
+			Data: f5xc.PublicKey{KeyVersion: int(count), Tenant: "test"},
+		})
+	})
+}
+
+// Returns the key version and cache header of a public key request made with the client.
+func testCachedPublicKey(t *testing.T, client *http.Client, serverURL string) (int, string, error) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, serverURL+f5xc.PublicKeyURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	envelope := f5xc.Envelope[f5xc.PublicKey]{}
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+	}
+	return envelope.Data.KeyVersion, resp.Header.Get(f5xc.CacheHeader), nil
+}
+
+// Returns a handler that counts requests, and returns a public key for the tenant of the x-volterra-apigw-tenant
+// header.
+func testTenantCacheHandler(requests *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{
+			Data: f5xc.PublicKey{KeyVersion: 1, Tenant: r.Header.Get("X-Volterra-Apigw-Tenant")},
+		})
+	})
+}
+
+// Verifies that a cache returns the public key of the tenant in the context headers of each request, and that a
+// conditional request is sent to the API.
+func testCacheTenants(t *testing.T, client *http.Client, serverURL string, requests *atomic.Int32) {
+	t.Helper()
+	for _, tenant := range []string{"tenant-a", "tenant-b", "tenant-a", "tenant-b"} {
+		ctx := f5xc.ContextWithHeaders(context.Background(), http.Header{"X-Volterra-Apigw-Tenant": {tenant}})
+		publicKey, err := f5xc.GetPublicKey(ctx, client, nil)
+		if err != nil || publicKey.Tenant != tenant {
+			t.Errorf("Expected public key for %s, got %+v: %v", tenant, publicKey, err)
+		}
+	}
+	if count := requests.Load(); count != 2 {
+		t.Errorf("Expected one API request for each tenant, got %d", count)
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, serverURL+f5xc.PublicKeyURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("If-None-Match", `"1"`)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Conditional request raised an unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if header := resp.Header.Get(f5xc.CacheHeader); header != "" || requests.Load() != 3 {
+		t.Errorf("Expected a conditional request to be sent to the API, got %q after %d requests", header, requests.Load())
+	}
+}
+
+// Verify that the disk cache returns stored responses until they expire, falls back to expired responses while the
+// API is unavailable, and ignores entries that fail verification.
+func TestWithDiskCache(t *testing.T) {
+	t.Parallel()
+	t.Run("hit", func(t *testing.T) {
+		t.Parallel()
+		var requests, status atomic.Int32
+		client, serverURL := testAPIClient(t, testCacheHandler(&requests, &status), f5xc.WithDiskCache(t.TempDir(), time.Hour))
+		for i, expected := range []string{"miss", "hit", "hit"} {
+			version, header, err := testCachedPublicKey(t, client, serverURL)
+			if err != nil || version != 1 || header != expected {
+				t.Errorf("Request %d: expected version 1 and %s, got %d and %q: %v", i, expected, version, header, err)
+			}
+		}
+		// GetPublicKey with a version has a different URL
+		version := 5
+		if pubKey, err := f5xc.GetPublicKey(context.Background(), client, &version); err != nil || pubKey.KeyVersion != 2 {
+			t.Errorf("Expected a new request for a key version, got %+v: %v", pubKey, err)
+		}
+		if count := requests.Load(); count != 2 {
+			t.Errorf("Expected 2 API requests, got %d", count)
+		}
+	})
+	t.Run("expired", func(t *testing.T) {
+		t.Parallel()
+		var requests, status atomic.Int32
+		client, serverURL := testAPIClient(t, testCacheHandler(&requests, &status), f5xc.WithDiskCache(t.TempDir(), time.Nanosecond), f5xc.WithDiskCacheMaxStale(0))
+		for i := range 2 {
+			if version, header, err := testCachedPublicKey(t, client, serverURL); err != nil || version != i+1 || header != "miss" {
+				t.Errorf("Request %d: expected version %d and miss, got %d and %q: %v", i, i+1, version, header, err)
+			}
+		}
+		status.Store(http.StatusServiceUnavailable)
+		if _, header, err := testCachedPublicKey(t, client, serverURL); err != nil || header != "" {
+			t.Errorf("Expected no expired response when max stale is 0, got %q: %v", header, err)
+		}
+	})
+	t.Run("stale", func(t *testing.T) {
+		t.Parallel()
+		var requests, status atomic.Int32
+		client, serverURL := testAPIClient(t, testCacheHandler(&requests, &status), f5xc.WithDiskCache(t.TempDir(), time.Nanosecond))
+		if _, _, err := testCachedPublicKey(t, client, serverURL); err != nil {
+			t.Fatalf("Request raised an unexpected error: %v", err)
+		}
+		status.Store(http.StatusServiceUnavailable)
+		if version, header, err := testCachedPublicKey(t, client, serverURL); err != nil || version != 1 || header != "stale" {
+			t.Errorf("Expected version 1 and stale, got %d and %q: %v", version, header, err)
+		}
+		status.Store(http.StatusForbidden)
+		if _, err := f5xc.GetPublicKey(context.Background(), client, nil); !errors.Is(err, f5xc.ErrForbidden) {
+			t.Errorf("Expected GetPublicKey to raise %v, got %v", f5xc.ErrForbidden, err)
+		}
+	})
+	t.Run("tenants", func(t *testing.T) {
+		t.Parallel()
+		var requests atomic.Int32
+		client, serverURL := testAPIClient(t, testTenantCacheHandler(&requests), f5xc.WithDiskCache(t.TempDir(), time.Hour))
+		testCacheTenants(t, client, serverURL, &requests)
+	})
+	t.Run("tampered", func(t *testing.T) {
+		t.Parallel()
+		var requests, status atomic.Int32
+		dir := t.TempDir()
+		client, serverURL := testAPIClient(t, testCacheHandler(&requests, &status), f5xc.WithDiskCache(dir, time.Hour))
+		if _, _, err := testCachedPublicKey(t, client, serverURL); err != nil {
+			t.Fatalf("Request raised an unexpected error: %v", err)
+		}
+		entries, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("Expected one cache entry, got %v: %v", entries, err)
+		}
+		data, err := os.ReadFile(entries[0])
+		if err != nil {
+			t.Fatalf("Failed to read cache entry: %v", err)
+		}
+		if err := os.WriteFile(entries[0], bytes.Replace(data, []byte(`"body":"ey`), []byte(`"body":"EY`), 1), 0o600); err != nil {
+			t.Fatalf("Failed to write cache entry: %v", err)
+		}
+		if version, header, err := testCachedPublicKey(t, client, serverURL); err != nil || version != 2 || header != "miss" {
+			t.Errorf("Expected version 2 and miss, got %d and %q: %v", version, header, err)
+		}
+	})
+	t.Run("other-key", func(t *testing.T) {
+		t.Parallel()
+		var requests, status atomic.Int32
+		dir := t.TempDir()
+		handler := testCacheHandler(&requests, &status)
+		client, serverURL := testAPIClient(t, handler, f5xc.WithDiskCache(dir, time.Hour), f5xc.WithDiskCacheKey(bytes.Repeat([]byte("a"), 32)))
+		if _, _, err := testCachedPublicKey(t, client, serverURL); err != nil {
+			t.Fatalf("Request raised an unexpected error: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "cache.key")); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Expected no generated key when a key is given: %v", err)
+		}
+		other, err := f5xc.NewClient(f5xc.WithAPIEndpoint(serverURL+"/api"), f5xc.WithAuthToken("test-token"), f5xc.WithDiskCache(dir, time.Hour), f5xc.WithDiskCacheKey(bytes.Repeat([]byte("b"), 32)))
+		if err != nil {
+			t.Fatalf("NewClient raised an unexpected error: %v", err)
+		}
+		t.Cleanup(other.CloseIdleConnections)
+		// The other client does not trust the server, so a request that is not answered from the cache will fail
+		if _, _, err := testCachedPublicKey(t, other, serverURL); err == nil {
+			t.Error("Expected an entry written with another key to be ignored")
+		}
+	})
+}
+
+// Verify that invalid disk cache options are rejected.
+func TestWithDiskCache_Invalid(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		option f5xc.Option
+	}{
+		{
+			name:   "empty-dir",
+			option: f5xc.WithDiskCache("", time.Hour),
+		},
+		{
+			name:   "zero-ttl",
+			option: f5xc.WithDiskCache(t.TempDir(), 0),
+		},
+		{
+			name:   "short-key",
+			option: f5xc.WithDiskCacheKey([]byte("short")),
+		},
+		{
+			name:   "negative-max-stale",
+			option: f5xc.WithDiskCacheMaxStale(-time.Second),
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := f5xc.NewClient(f5xc.WithAPIEndpoint("https://example.com/api"), f5xc.WithAuthToken("test-token"), tst.option)
+			if !errors.Is(err, f5xc.ErrInvalidOption) {
+				t.Errorf("Expected NewClient to raise %v, got %v", f5xc.ErrInvalidOption, err)
+			}
+		})
+	}
+}
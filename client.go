@@ -1,6 +1,7 @@
 package f5xc
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
@@ -8,10 +9,17 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/memes/f5xc/internal/bufpool"
+	"github.com/memes/f5xc/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 	"software.sslmate.com/src/go-pkcs12"
 )
 
@@ -28,18 +36,85 @@ var (
 	ErrUnauthorized = errors.New("authentication is required")
 	// Authorization failed and the client does not have permission to reach the endpoint.
 	ErrForbidden = errors.New("access to endpoint is denied")
-	// Returned by EnvelopeAPICall function when response status is not 200, 401 or 403.
+	// Returned by StrictEnvelopeAPICall function when the requested resource does not exist.
+	ErrNotFound = errors.New("resource not found")
+	// Returned by EnvelopeAPICall function when the response status is 304, i.e. the resource has not changed since the
+	// version identified by the If-None-Match header of the request, and a cached copy should be used.
+	ErrNotModified = errors.New("resource not modified")
+	// Returned by EnvelopeAPICall function when the response body is larger than the maximum response size; see
+	// WithMaxResponseSize.
+	ErrResponseTooLarge = errors.New("response body is too large")
+	// Returned by EnvelopeAPICall function when response status is not 200, 304, 401, 403 or 404.
 	ErrUnexpectedHTTPStatus = errors.New("endpoint returned an unexpected status code")
 	// Internal error that indicates a cast failure of DefaultTransport.
 	ErrCastTransport = errors.New("failed to cast DefaultTransport to *http.Transport")
+	// Returned by NewClient when an option value is not acceptable.
+	ErrInvalidOption = errors.New("invalid option")
 )
 
+// The authorization scheme of F5 XC API tokens.
+const apiTokenScheme = "APIToken"
+
 // Defines the configuration options for an F5 XC Client.
 type config struct {
 	EndpointURL *url.URL
 	caCertPool  *x509.CertPool
 	Cert        *tls.Certificate
 	AuthToken   string
+	// The optional source of an API token for each request, which replaces AuthToken, and the authorization scheme of
+	// its tokens.
+	tokenSource TokenSource
+	tokenScheme string
+	// The optional time that responses are kept in memory.
+	memoryCacheTTL time.Duration
+	// The directory, integrity key, and expiry times of the optional disk cache.
+	cacheDir      string
+	cacheKey      []byte
+	cacheTTL      time.Duration
+	cacheMaxStale time.Duration
+	// The optional policy for retrying idempotent requests.
+	retryPolicy *RetryPolicy
+	// The optional number of consecutive failures that open the circuit breaker, and the time it stays open.
+	breakerThreshold int
+	breakerCooldown  time.Duration
+	// The optional provider of tracers that record a span for each request.
+	tracerProvider trace.TracerProvider
+	// The optional metrics recorded for each request.
+	metrics *clientMetrics
+	// The optional deadline of requests that do not have one.
+	requestTimeout time.Duration
+	// The optional proxy that replaces a proxy from the environment.
+	proxyURL *url.URL
+	// The optional connection pool and protocol settings of the transport.
+	transportTuning *TransportTuning
+	// The optional dialer or function that establishes connections to the API server, and the IP addresses used
+	// instead of resolving host names.
+	dialer           *net.Dialer
+	dialContext      dialFunc
+	resolveOverrides resolveOverrides
+	// The User-Agent header value, and the headers added to every request.
+	userAgent string
+	headers   http.Header
+	// True if the API server certificate is not verified.
+	insecureSkipVerify bool
+	// The optional function that changes the TLS configuration of the transport.
+	tlsConfigOverride func(*tls.Config)
+	// The optional functions called with every request and response, in order.
+	requestInterceptors  []RequestInterceptor
+	responseInterceptors []ResponseInterceptor
+	// The optional period before the client certificate expires that a warning is given, and the function to call.
+	expiryWarning  time.Duration
+	expiryCallback CertificateExpiryFunc
+	// The namespace used by the methods of Client when a namespace is not given, and the tenant of Client.
+	namespace string
+	tenant    string
+	// The maximum number of bytes of a response body that are read.
+	maxResponseSize int64
+	// The optional logger of the client, which replaces the default logger.
+	logger *slog.Logger
+	// The optional logger of sanitized requests and responses, and whether bodies are logged.
+	debugLogger *slog.Logger
+	debugBodies bool
 }
 
 // Defines a configuration setting function.
@@ -48,7 +123,7 @@ type Option func(*config) error
 // Use the supplied endpoint URL for all requests to F5 XC when calling NewClient.
 func WithAPIEndpoint(apiEndpoint string) Option {
 	return func(c *config) error {
-		slog.Debug("Adding API URL", "apiURL", apiEndpoint)
+		c.log().Debug("Adding API URL", "apiURL", apiEndpoint)
 		baseURL, err := url.ParseRequestURI(apiEndpoint)
 		switch {
 		case err != nil:
@@ -67,67 +142,135 @@ func WithAPIEndpoint(apiEndpoint string) Option {
 // to the system when calling NewClient.
 func WithCACert(caCert string) Option {
 	return func(c *config) error {
-		logger := slog.With("caCert", caCert)
+		logger := c.log().With("caCert", caCert)
 		logger.Debug("Adding CA certificate to pool")
 		ca, err := os.ReadFile((caCert))
 		if err != nil {
 			return fmt.Errorf("failed to read from certificate file %s: %w", caCert, err)
 		}
-		if c.caCertPool == nil {
-			pool, err := x509.SystemCertPool()
-			if err != nil {
-				return fmt.Errorf("failed to build new CA cert pool from SystemCertPool: %w", err)
-			}
-			c.caCertPool = pool
+		if err := c.appendCACerts(ca); err != nil {
+			return fmt.Errorf("failed to process CA cert %s: %w", caCert, err)
 		}
-		if ok := c.caCertPool.AppendCertsFromPEM(ca); !ok {
-			return fmt.Errorf("failed to process CA cert %s: %w", caCert, ErrFailedToAppendCACert)
+		return nil
+	}
+}
+
+// Adds the PEM encoded x509 CA certificates to the set of CA certificates known to
+// the system when calling NewClient.
+func WithCACertBytes(caCertPEM []byte) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding CA certificate bytes to pool")
+		if err := c.appendCACerts(caCertPEM); err != nil {
+			return fmt.Errorf("failed to process CA cert bytes: %w", err)
 		}
 		return nil
 	}
 }
 
+// Trust only the CA certificates in the pool, instead of the CA certificates known to
+// the system, when calling NewClient; e.g. a pool built from a trust bundle that is
+// embedded in the program. The pool is copied, so CA certificates added by later
+// options do not change it.
+func WithCACertPool(pool *x509.CertPool) Option {
+	return func(c *config) error {
+		c.log().Debug("Replacing CA certificate pool")
+		if pool == nil {
+			return fmt.Errorf("CA cert pool must not be nil: %w", ErrInvalidOption)
+		}
+		c.caCertPool = pool.Clone()
+		return nil
+	}
+}
+
+// Adds the PEM encoded CA certificates to the CA pool, starting from the system CA
+// certificates if a pool has not been set.
+func (c *config) appendCACerts(caCertPEM []byte) error {
+	if c.caCertPool == nil {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			return fmt.Errorf("failed to build new CA cert pool from SystemCertPool: %w", err)
+		}
+		c.caCertPool = pool
+	}
+	if ok := c.caCertPool.AppendCertsFromPEM(caCertPEM); !ok {
+		return ErrFailedToAppendCACert
+	}
+	return nil
+}
+
 // Implements an Option that sets Client authentication to use the provided
 // PKCS#12 certificate, disabling token authentication.
 func WithP12Certificate(path, passphrase string) Option {
 	return func(c *config) error {
-		logger := slog.With("path", path)
+		logger := c.log().With("path", path)
 		logger.Debug("Adding PKCS#12 certificate as authenticator")
 		rawData, err := os.ReadFile(path)
 		if err != nil {
 			return fmt.Errorf("failed to read from P12 file %s: %w", path, err)
 		}
-		key, cert, caCerts, err := pkcs12.DecodeChain(rawData, passphrase)
-		if err != nil {
+		if err := c.setP12Certificate(rawData, passphrase); err != nil {
 			return fmt.Errorf("failed to decode P12 file %s: %w", path, err)
 		}
-		if len(caCerts) > 0 {
-			if c.caCertPool == nil {
-				pool, err := x509.SystemCertPool()
-				if err != nil {
-					return fmt.Errorf("failed to load system CA certs as pool: %w", err)
-				}
-				c.caCertPool = pool
-			}
-			for _, caCert := range caCerts {
-				c.caCertPool.AddCert(caCert)
+		return nil
+	}
+}
+
+// Implements an Option that sets Client authentication to use the PKCS#12 certificate
+// in data, disabling token authentication; e.g. for a bundle retrieved from a secret
+// store that should not be written to disk.
+func WithP12CertificateBytes(data []byte, passphrase string) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding PKCS#12 certificate bytes as authenticator")
+		if err := c.setP12Certificate(data, passphrase); err != nil {
+			return fmt.Errorf("failed to decode P12 data: %w", err)
+		}
+		return nil
+	}
+}
+
+// Decodes the PKCS#12 data and uses the certificate and key for authentication; any CA
+// certificates in the chain are added to the CA pool.
+func (c *config) setP12Certificate(data []byte, passphrase string) error {
+	cert, caCerts, err := decodeP12Certificate(data, passphrase)
+	if err != nil {
+		return err
+	}
+	if len(caCerts) > 0 {
+		if c.caCertPool == nil {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				return fmt.Errorf("failed to load system CA certs as pool: %w", err)
 			}
+			c.caCertPool = pool
 		}
-		c.Cert = &tls.Certificate{
-			Certificate: [][]byte{cert.Raw},
-			Leaf:        cert,
-			PrivateKey:  key,
+		for _, caCert := range caCerts {
+			c.caCertPool.AddCert(caCert)
 		}
-		c.AuthToken = ""
-		return nil
 	}
+	c.Cert = cert
+	c.AuthToken = ""
+	c.tokenSource = nil
+	return nil
+}
+
+// Decodes the PKCS#12 data into a TLS certificate and any CA certificates in the chain.
+func decodeP12Certificate(data []byte, passphrase string) (*tls.Certificate, []*x509.Certificate, error) {
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, passphrase)
+	if err != nil {
+		return nil, nil, err //nolint:wrapcheck // Callers add the source of the data to the error
+	}
+	return &tls.Certificate{
+		Certificate: [][]byte{cert.Raw},
+		Leaf:        cert,
+		PrivateKey:  key,
+	}, caCerts, nil
 }
 
 // Implements an Option that sets Client authentication to use the x509 certificate
 // and key pair, disabling token authentication.
 func WithCertKeyPair(certPath, keyPath string) Option {
 	return func(c *config) error {
-		logger := slog.With("certPath", certPath, "keyPath", keyPath)
+		logger := c.log().With("certPath", certPath, "keyPath", keyPath)
 		logger.Debug("Adding client certificate")
 		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
 		if err != nil {
@@ -135,6 +278,24 @@ func WithCertKeyPair(certPath, keyPath string) Option {
 		}
 		c.Cert = &cert
 		c.AuthToken = ""
+		c.tokenSource = nil
+		return nil
+	}
+}
+
+// Implements an Option that sets Client authentication to use the PEM encoded x509
+// certificate and key, disabling token authentication; e.g. for a certificate that is
+// provided in environment variables rather than files.
+func WithCertKeyPEM(certPEM, keyPEM []byte) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding PEM client certificate")
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return fmt.Errorf("failed to load PEM certificate and key: %w", err)
+		}
+		c.Cert = &cert
+		c.AuthToken = ""
+		c.tokenSource = nil
 		return nil
 	}
 }
@@ -143,47 +304,216 @@ func WithCertKeyPair(certPath, keyPath string) Option {
 // authentication token, disabling certificate based authentication.
 func WithAuthToken(token string) Option {
 	return func(c *config) error {
-		slog.Debug("Adding authentication token")
+		c.log().Debug("Adding authentication token")
 		c.AuthToken = token
+		c.tokenSource = nil
+		c.Cert = nil
+		return nil
+	}
+}
+
+// TokenSource returns the API token to use for a request; see [WithTokenSource].
+type TokenSource func(ctx context.Context) (string, error)
+
+// Implements an option that sets client authentication to use the token returned by
+// source for each request, disabling certificate based authentication; e.g. for API
+// tokens that are rotated by an external system without recreating the client. The
+// source is called with the request context for every request, including retries,
+// and should cache the token if it is expensive to retrieve. A request fails if the
+// source returns an error or an empty token.
+func WithTokenSource(source TokenSource) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding authentication token source")
+		if source == nil {
+			return fmt.Errorf("token source must not be nil: %w", ErrInvalidOption)
+		}
+		c.tokenSource = source
+		c.tokenScheme = apiTokenScheme
+		c.AuthToken = ""
 		c.Cert = nil
 		return nil
 	}
 }
 
+// Credentials holds the forms of authentication that may be configured together for a client, e.g. by the vesctl
+// environment variables or configuration file; see [WithCredentials].
+type Credentials struct {
+	// An API token.
+	APIToken string
+	// The path of a PKCS#12 bundle.
+	P12Bundle string
+	// The passphrase of the PKCS#12 bundle.
+	P12Passphrase string
+	// The path of a PEM client certificate; requires Key.
+	Cert string
+	// The path of the PEM private key of Cert.
+	Key string
+}
+
+// Implements an option that sets client authentication to the preferred form of the credentials that is configured:
+// an API token, then a PKCS#12 bundle, then a certificate and key pair. The option does nothing if none is configured.
+func WithCredentials(credentials Credentials) Option {
+	switch {
+	case credentials.APIToken != "":
+		return WithAuthToken(credentials.APIToken)
+	case credentials.P12Bundle != "":
+		return WithP12Certificate(credentials.P12Bundle, credentials.P12Passphrase)
+	case credentials.Cert != "" && credentials.Key != "":
+		return WithCertKeyPair(credentials.Cert, credentials.Key)
+	}
+	return func(c *config) error {
+		c.log().Debug("No credentials to add")
+		return nil
+	}
+}
+
+// Disable verification of the API server certificate and host name, so that the client can call lab or air-gapped
+// regional edges that use self-signed certificates.
+//
+// WARNING: this makes the client vulnerable to machine-in-the-middle attacks that can capture the API credentials and
+// return forged public keys and policy documents; it must never be used with a production tenant. Prefer
+// [WithCACert], [WithCACertBytes], or [WithCACertPool] to trust a private CA instead.
+func WithInsecureSkipVerify() Option {
+	return func(c *config) error {
+		c.log().Warn("TLS certificate verification of the F5 XC API is DISABLED; do not use with production tenants")
+		c.insecureSkipVerify = true
+		return nil
+	}
+}
+
+// Change the TLS configuration of the client before it is used, e.g. to set the minimum and maximum TLS versions,
+// cipher suites, or curve preferences required by FIPS or internal compliance policies. The function is called with
+// the configuration created by the client, which requires TLS 1.2 or later, verifies the API server with the CA
+// certificates of the client, and presents the client certificate; changing those settings may prevent the client
+// from authenticating.
+func WithTLSConfigOverride(override func(*tls.Config)) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding TLS configuration override")
+		if override == nil {
+			return fmt.Errorf("TLS configuration override must not be nil: %w", ErrInvalidOption)
+		}
+		c.tlsConfigOverride = override
+		return nil
+	}
+}
+
+// Identify the program that uses the client in the User-Agent header of API requests. The product is added before the
+// module identifier returned by [UserAgent], e.g. a product of mytool/1.2 results in a User-Agent of
+// "mytool/1.2 f5xc/v0.10.0". A request that sets its own User-Agent header is sent unchanged.
+func WithUserAgent(product string) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding user agent", "product", product)
+		if strings.TrimSpace(product) == "" {
+			return fmt.Errorf("user agent must not be empty: %w", ErrInvalidOption)
+		}
+		c.userAgent = strings.TrimSpace(product) + " " + UserAgent()
+		return nil
+	}
+}
+
+// Add the header to every API request that does not already have it; the option may be repeated, and a key that is
+// repeated will be sent with each value. Authorization and Content-Type headers are set by the client and cannot be
+// changed.
+func WithHeader(key, value string) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding header", "key", key)
+		key = http.CanonicalHeaderKey(key)
+		switch key {
+		case "":
+			return fmt.Errorf("header key must not be empty: %w", ErrInvalidOption)
+		case "Authorization", "Content-Type":
+			return fmt.Errorf("header %s is set by the client: %w", key, ErrInvalidOption)
+		}
+		if c.headers == nil {
+			c.headers = http.Header{}
+		}
+		c.headers.Add(key, value)
+		return nil
+	}
+}
+
 // The XC client may need to make changes to requests before sending to API
 // endpoints.
 type transport struct {
 	// The encapsulated http.Transport.
 	base *http.Transport
-	// Optional authentication token to add.
-	authToken string
+	// The authentication token or token source, and the client certificate.
+	credentials *credentialStore
 	// The endpoint to substitute for all F5 XC requests.
 	endpoint *url.URL
+	// The User-Agent header value.
+	userAgent string
+	// Headers to add to requests that do not have them.
+	headers http.Header
+	// The functions called with every request and response, in order.
+	requestInterceptors  []RequestInterceptor
+	responseInterceptors []ResponseInterceptor
+	// The maximum number of bytes of a response body that are read by the API functions.
+	maxResponseSize int64
+	// The logger of the client.
+	logger *slog.Logger
 }
 
 // Implements RoundTripper interface for F5 XC API calls; essentially it ensures that the authentication token is present
-// in the request *IF* it is a non-empty string. Most consumers of the module will be using the client with a valid
-// TLS certificate as identification, in which case this is essentially delegates unchanged requests to a standard library
+// in the request *IF* it is a non-empty string or is returned by the token source, identifies the module version with a
+// User-Agent header, and adds the request ID and headers from the request context and any configured headers, unless
+// the request already has those headers. Most consumers of the module will be using the client with a valid TLS
+// certificate as identification, in which case this is essentially delegates unchanged requests to a standard library
 // Transport implementation.
 func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	// All XC API requests should be set to JSON.
 	req.Header.Set("Content-Type", "application/json")
-	if t.authToken != "" {
-		slog.Debug("Adding authToken header")
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	// Headers from the context are added before the headers of the client, so that they take precedence
+	for _, headers := range []http.Header{HeadersFromContext(req.Context()), t.headers} {
+		for key, values := range headers {
+			if _, ok := req.Header[key]; !ok {
+				req.Header[key] = slices.Clone(values)
+			}
+		}
+	}
+	if id := RequestIDFromContext(req.Context()); id != "" && req.Header.Get(RequestIDHeader) == "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	authToken, scheme := t.credentials.authToken(), apiTokenScheme
+	if source, sourceScheme := t.credentials.tokenSource(); source != nil {
+		token, err := source(req.Context())
+		switch {
+		case err != nil:
+			return nil, fmt.Errorf("failed to get API token from token source: %w", err)
+		case token == "":
+			return nil, fmt.Errorf("token source returned an empty API token: %w", ErrMissingAuthentication)
+		}
+		authToken, scheme = token, sourceScheme
+	}
+	if authToken != "" {
+		t.logger.Debug("Adding authToken header")
 		// Brute-force approach since the XC API is expecting only oneAuthorization header and want to ensure it is the value set
 		// by the client configuration.
-		req.Header.Set("Authorization", "APIToken "+t.authToken)
+		req.Header.Set("Authorization", scheme+" "+authToken)
 	}
 	if t.endpoint != nil && req.URL.Host != t.endpoint.Host {
 		requestURL, err := t.endpoint.Parse(req.URL.RequestURI())
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse URL from request: %w", err)
 		}
-		slog.Debug("Modifying request URL and host", "url", requestURL, "host", t.endpoint.Host)
+		t.logger.Debug("Modifying request URL and host", "url", requestURL, "host", t.endpoint.Host)
 		req.URL = requestURL
 		req.Host = t.endpoint.Host
 	}
-	return t.base.RoundTrip(req) //nolint:wrapcheck // It is appropriate to return the http package error as-is
+	if err := t.interceptRequest(req); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // It is appropriate to return the http package error as-is
+	}
+	if err := t.interceptResponse(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
 }
 
 // Implement CloseIdleConnections to ensure that any underlying connections in base transport pool are closed as necessary.
@@ -191,9 +521,53 @@ func (t *transport) CloseIdleConnections() {
 	t.base.CloseIdleConnections()
 }
 
+// Returns the transport of a client from NewClient or NewAPIClient, found by unwrapping the RoundTrippers of the
+// client, or nil for any other client.
+func clientTransport(client Doer) *transport {
+	var httpClient *http.Client
+	switch c := client.(type) {
+	case *http.Client:
+		httpClient = c
+	case *Client:
+		if c != nil {
+			httpClient = c.Client
+		}
+	}
+	if httpClient == nil {
+		return nil
+	}
+	roundTripper := httpClient.Transport
+	for roundTripper != nil {
+		switch rt := roundTripper.(type) {
+		case *transport:
+			return rt
+		case interface{ Unwrap() http.RoundTripper }:
+			roundTripper = rt.Unwrap()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
 // Creates a new HTTP client that is pre-configured to authenticate to F5 XC endpoints.
 func NewClient(options ...Option) (*http.Client, error) {
-	cfg := &config{}
+	cfg, err := newConfig(options...)
+	if err != nil {
+		return nil, err
+	}
+	client, _, err := cfg.newClient()
+	return client, err
+}
+
+// Returns the configuration after applying the options, or an error if an option fails or the configuration is
+// incomplete.
+func newConfig(options ...Option) (*config, error) {
+	cfg := &config{
+		cacheMaxStale:   DefaultDiskCacheMaxStale,
+		maxResponseSize: DefaultMaxResponseSize,
+		userAgent:       UserAgent(),
+	}
 	for _, option := range options {
 		if err := option(cfg); err != nil {
 			return nil, err
@@ -202,62 +576,220 @@ func NewClient(options ...Option) (*http.Client, error) {
 	switch {
 	case cfg.EndpointURL == nil:
 		return nil, ErrMissingURL
-	case cfg.Cert == nil && cfg.AuthToken == "":
+	case cfg.Cert == nil && cfg.AuthToken == "" && cfg.tokenSource == nil:
 		return nil, ErrMissingAuthentication
 	}
+	return cfg, nil
+}
 
+// Creates a new HTTP client from the configuration, and returns it with the store of the credentials used by its
+// transport.
+func (cfg *config) newClient() (*http.Client, *credentialStore, error) {
 	tlsConfig := &tls.Config{
 		MinVersion: tls.VersionTLS12,
 		RootCAs:    cfg.caCertPool,
+		//nolint:gosec // Verification is only disabled when explicitly requested with WithInsecureSkipVerify
+		InsecureSkipVerify: cfg.insecureSkipVerify,
 	}
-	if cfg.Cert != nil {
-		tlsConfig.Certificates = []tls.Certificate{*cfg.Cert}
+	logger := cfg.log()
+	if cfg.insecureSkipVerify {
+		logger.Warn("Creating F5 XC API client that does not verify TLS certificates", "apiURL", cfg.EndpointURL.String())
+	}
+	// The client certificate is read from the store for each connection, so that it can be replaced
+	credentials := newCredentialStore(cfg.AuthToken, cfg.tokenSource, cfg.tokenScheme, cfg.Cert)
+	credentials.expiryWarning = cfg.expiryWarning
+	credentials.expiryCallback = cfg.expiryCallback
+	credentials.logger = logger
+	credentials.checkExpiry(credentials.certificate())
+	tlsConfig.GetClientCertificate = credentials.clientCertificate
+	if cfg.tlsConfigOverride != nil {
+		cfg.tlsConfigOverride(tlsConfig)
 	}
 	baseTransport, ok := http.DefaultTransport.(*http.Transport)
 	if !ok {
-		return nil, ErrCastTransport
+		return nil, nil, ErrCastTransport
 	}
 	baseTransport = baseTransport.Clone()
 	baseTransport.TLSClientConfig = tlsConfig
+	if cfg.proxyURL != nil {
+		baseTransport.Proxy = http.ProxyURL(cfg.proxyURL)
+	}
+	if cfg.transportTuning != nil {
+		cfg.transportTuning.apply(baseTransport)
+	}
+	switch {
+	case cfg.dialContext != nil:
+		baseTransport.DialContext = cfg.dialContext
+	case cfg.dialer != nil:
+		baseTransport.DialContext = cfg.dialer.DialContext
+	}
+	if len(cfg.resolveOverrides) > 0 {
+		baseTransport.DialContext = cfg.resolveOverrides.dialContext(baseTransport.DialContext, logger)
+	}
+	var roundTripper http.RoundTripper = &transport{
+		base:                 baseTransport,
+		credentials:          credentials,
+		endpoint:             cfg.EndpointURL,
+		userAgent:            cfg.userAgent,
+		headers:              cfg.headers,
+		requestInterceptors:  cfg.requestInterceptors,
+		responseInterceptors: cfg.responseInterceptors,
+		maxResponseSize:      cfg.maxResponseSize,
+		logger:               logger,
+	}
+	if cfg.debugLogger != nil {
+		roundTripper = &debugTransport{
+			base:   roundTripper,
+			logger: cfg.debugLogger,
+			bodies: cfg.debugBodies,
+		}
+	}
+	if cfg.tracerProvider != nil {
+		roundTripper = tracing.NewTransport(roundTripper, cfg.tracerProvider)
+	}
+	if cfg.metrics != nil {
+		roundTripper = &metricsTransport{
+			base:    roundTripper,
+			metrics: cfg.metrics,
+		}
+	}
+	if cfg.breakerThreshold > 0 {
+		roundTripper = &breakerTransport{
+			base:      roundTripper,
+			threshold: cfg.breakerThreshold,
+			cooldown:  cfg.breakerCooldown,
+			logger:    logger,
+		}
+	}
+	if cfg.retryPolicy != nil {
+		roundTripper = &retryTransport{
+			base:   roundTripper,
+			policy: *cfg.retryPolicy,
+			logger: logger,
+		}
+	}
+	if cfg.requestTimeout > 0 {
+		roundTripper = &timeoutTransport{
+			base:    roundTripper,
+			timeout: cfg.requestTimeout,
+		}
+	}
+	if cfg.cacheDir != "" {
+		cache, err := newDiskCache(roundTripper, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		roundTripper = cache
+	}
+	if cfg.memoryCacheTTL > 0 {
+		roundTripper = &memoryCache{
+			base:            roundTripper,
+			ttl:             cfg.memoryCacheTTL,
+			maxResponseSize: cfg.maxResponseSize,
+			logger:          logger,
+			entries:         map[string]*memoryCacheEntry{},
+		}
+	}
 	return &http.Client{
-		Transport: &transport{
-			base:      baseTransport,
-			authToken: cfg.AuthToken,
-			endpoint:  cfg.EndpointURL,
-		},
-	}, nil
+		Transport: roundTripper,
+	}, credentials, nil
+}
+
+// Doer sends HTTP requests; it is implemented by *http.Client and [*Client], and
+// is accepted by the API functions of the package so that callers can substitute
+// an instrumented client, or a fake in unit tests.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
 }
 
 // Helper method to make F5XC API requests where the response is expected to be
 // in an Envelope, returning the embedded resource or an error. This function
 // expects an HTTP status code of 200 as the only indicator of success; it will
-// return nil if HTTP status code is 404, or one of the f5xc package errors for
-// all other statuses.
-func EnvelopeAPICall[T EnvelopeAllowed](client *http.Client, req *http.Request) (*T, error) {
-	slog.Debug("Calling API")
+// return nil if HTTP status code is 404, or an [*Error] that wraps one of the
+// f5xc package errors for all other statuses and failures to send the request. The
+// package error of an error response is wrapped in an [*APIError] that holds the
+// code and message from the response body. New code should prefer
+// [StrictEnvelopeAPICall], which does not return a nil resource without an error.
+func EnvelopeAPICall[T EnvelopeAllowed](client Doer, req *http.Request) (*T, error) {
+	return envelopeAPICall[T](client, req, false)
+}
+
+// StrictEnvelopeAPICall is the same as [EnvelopeAPICall], except that an HTTP
+// status code of 404 returns an [*Error] that wraps [ErrNotFound], so that a nil
+// resource is never returned without an error.
+func StrictEnvelopeAPICall[T EnvelopeAllowed](client Doer, req *http.Request) (*T, error) {
+	return envelopeAPICall[T](client, req, true)
+}
+
+// Makes the API request and returns the resource in the Envelope of the response;
+// if strict is false a 404 response returns a nil resource and error.
+func envelopeAPICall[T EnvelopeAllowed](client Doer, req *http.Request, strict bool) (*T, error) {
+	envelope, err := apiCall[Envelope[T]](client, req, strict)
+	if envelope == nil {
+		return nil, err
+	}
+	return &envelope.Data, nil
+}
+
+// Makes the API request and returns the response body unmarshaled from JSON; if
+// strict is false a 404 response returns a nil response and error.
+func apiCall[R any](client Doer, req *http.Request, strict bool) (*R, error) {
+	result, _, err := apiCallWithHeader[R](client, req, strict)
+	return result, err
+}
+
+// Makes the API request and returns the response body unmarshaled from JSON, and
+// the headers of the response if one was received.
+func apiCallWithHeader[R any](client Doer, req *http.Request, strict bool) (*R, http.Header, error) {
+	clientLogger(client).Debug("Calling API")
+	apiErr := &Error{Op: req.Method, Endpoint: req.URL.Path}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failure making API call: %w", err)
+		apiErr.Err = fmt.Errorf("failure making API call: %w", err)
+		apiErr.Temporary = IsRetryable(err)
+		return nil, nil, apiErr
 	}
 	defer resp.Body.Close()
+	apiErr.StatusCode = resp.StatusCode
 	switch resp.StatusCode {
 	case http.StatusOK:
-		data, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read API response body: %w", err)
+		// The response is read into a pooled buffer; unmarshaling copies every value, so it can be reused
+		data := bufpool.Get()
+		defer bufpool.Put(data)
+		limit := clientMaxResponseSize(client)
+		if _, err := data.ReadFrom(io.LimitReader(resp.Body, limit+1)); err != nil {
+			apiErr.Err = fmt.Errorf("failed to read API response body: %w", err)
+			apiErr.Temporary = IsRetryable(err)
+			return nil, resp.Header, apiErr
 		}
-		envelope := &Envelope[T]{}
-		err = json.Unmarshal(data, envelope)
+		if int64(data.Len()) > limit {
+			apiErr.Err = fmt.Errorf("API response body exceeds %d bytes: %w", limit, ErrResponseTooLarge)
+			return nil, resp.Header, apiErr
+		}
+		result := new(R)
+		err = json.Unmarshal(data.Bytes(), result)
 		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+			apiErr.Err = fmt.Errorf("failed to unmarshal JSON: %w", err)
+			return nil, resp.Header, apiErr
 		}
-		return &envelope.Data, nil
+		return result, resp.Header, nil
+	case http.StatusNotModified:
+		apiErr.Err = ErrNotModified
+		return nil, resp.Header, apiErr
 	case http.StatusUnauthorized:
-		return nil, ErrUnauthorized
+		apiErr.Err = newAPIError(resp, ErrUnauthorized)
+		return nil, resp.Header, apiErr
 	case http.StatusForbidden:
-		return nil, ErrForbidden
+		apiErr.Err = newAPIError(resp, ErrForbidden)
+		return nil, resp.Header, apiErr
 	case http.StatusNotFound:
-		return nil, nil
+		if !strict {
+			return nil, resp.Header, nil
+		}
+		apiErr.Err = newAPIError(resp, ErrNotFound)
+		return nil, resp.Header, apiErr
 	}
-	return nil, fmt.Errorf("unexpected HTTP status code %d: %w", resp.StatusCode, ErrUnexpectedHTTPStatus)
+	apiErr.Err = newAPIError(resp, ErrUnexpectedHTTPStatus)
+	apiErr.Temporary = RetryableStatus(resp.StatusCode)
+	return nil, resp.Header, apiErr
 }
@@ -1,8 +1,21 @@
 package f5xc_test
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/memes/f5xc"
@@ -21,6 +34,34 @@ func TestMain(m *testing.M) {
 	goleak.VerifyTestMain(m)
 }
 
+// Returns the options that make a client trust and send requests to the started TLS server with a test token.
+func testServerOptions(t *testing.T, server *httptest.Server) []f5xc.Option {
+	t.Helper()
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600); err != nil {
+		t.Fatalf("Failed to write CA certificate: %v", err)
+	}
+	return []f5xc.Option{
+		f5xc.WithAPIEndpoint(server.URL + "/api"),
+		f5xc.WithCACert(caCert),
+		f5xc.WithAuthToken("test-token"),
+	}
+}
+
+// Starts a TLS server with the handler and returns a client from NewClient that trusts and sends requests to the
+// server with a test token, and the base URL of the server. Any options are applied after the test settings.
+func testAPIClient(t *testing.T, handler http.Handler, options ...f5xc.Option) (*http.Client, string) {
+	t.Helper()
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+	client, err := f5xc.NewClient(append(testServerOptions(t, server), options...)...)
+	if err != nil {
+		t.Fatalf("NewClient raised an unexpected error: %v", err)
+	}
+	t.Cleanup(client.CloseIdleConnections)
+	return client, server.URL
+}
+
 // Verify that a new http.Client can be created with specific API URL endpoint.
 func TestNewClient_WithAPIEndpoint(t *testing.T) {
 	t.Parallel()
@@ -108,6 +149,48 @@ func TestNewClient_WithP12Certificate(t *testing.T) {
 	}
 }
 
+// Verify that a PKCS#12 bundle can be provided as bytes.
+func TestNewClient_WithP12CertificateBytes(t *testing.T) {
+	t.Parallel()
+	data, err := os.ReadFile(TestPKCS12Certificate)
+	if err != nil {
+		t.Fatalf("Failed to read PKCS#12 bundle: %v", err)
+	}
+	tests := []struct {
+		name          string
+		data          []byte
+		passphrase    string
+		expectedError error
+	}{
+		{
+			name:       "test-p12",
+			data:       data,
+			passphrase: TestPKCS12Passphrase,
+		},
+		{
+			name:          "invalid-passphrase",
+			data:          data,
+			passphrase:    "abc",
+			expectedError: pkcs12.ErrIncorrectPassword,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := f5xc.NewClient(
+				f5xc.WithAPIEndpoint("https://f5xc.invalid/api"),
+				f5xc.WithP12CertificateBytes(tst.data, tst.passphrase),
+			)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("NewClient raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected NewClient to raise %v, got %v", tst.expectedError, err)
+			}
+		})
+	}
+}
+
 // Verify behaviour of PEM certificate and key handling.
 // NOTE: Requires test certificates in testdata which can be generated by Makefile.
 func TestNewClient_WithTestCertKeyPair(t *testing.T) {
@@ -153,3 +236,417 @@ func TestNewClient_WithTestCertKeyPair(t *testing.T) {
 		})
 	}
 }
+
+// Verify that a PEM certificate and key can be provided as bytes.
+func TestNewClient_WithCertKeyPEM(t *testing.T) {
+	t.Parallel()
+	certPEM, err := os.ReadFile(TestX509Certificate)
+	if err != nil {
+		t.Fatalf("Failed to read certificate: %v", err)
+	}
+	keyPEM, err := os.ReadFile(TestX509Key)
+	if err != nil {
+		t.Fatalf("Failed to read key: %v", err)
+	}
+	tests := []struct {
+		name        string
+		cert        []byte
+		key         []byte
+		expectError bool
+	}{
+		{
+			name: "test-pem",
+			cert: certPEM,
+			key:  keyPEM,
+		},
+		{
+			name:        "empty-key",
+			cert:        certPEM,
+			expectError: true,
+		},
+		{
+			name:        "swapped",
+			cert:        keyPEM,
+			key:         certPEM,
+			expectError: true,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := f5xc.NewClient(
+				f5xc.WithAPIEndpoint("https://f5xc.invalid/api"),
+				f5xc.WithCertKeyPEM(tst.cert, tst.key),
+			)
+			switch {
+			case !tst.expectError && err != nil:
+				t.Errorf("NewClient raised an unexpected error: %v", err)
+			case tst.expectError && err == nil:
+				t.Error("Expected NewClient to raise an error")
+			}
+		})
+	}
+}
+
+// Verify that CA certificates can be provided as PEM bytes or as a pool, and that invalid values are rejected.
+func TestNewClient_WithCACertBytesAndPool(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{Data: f5xc.PublicKey{KeyVersion: 1}})
+	}))
+	t.Cleanup(server.Close)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	tests := []struct {
+		name          string
+		option        f5xc.Option
+		expectedError error
+	}{
+		{
+			name:   "bytes",
+			option: f5xc.WithCACertBytes(caPEM),
+		},
+		{
+			name:   "pool",
+			option: f5xc.WithCACertPool(pool),
+		},
+		{
+			name:          "invalid-bytes",
+			option:        f5xc.WithCACertBytes([]byte("not a certificate")),
+			expectedError: f5xc.ErrFailedToAppendCACert,
+		},
+		{
+			name:          "nil-pool",
+			option:        f5xc.WithCACertPool(nil),
+			expectedError: f5xc.ErrInvalidOption,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			client, err := f5xc.NewClient(
+				f5xc.WithAPIEndpoint(server.URL+"/api"),
+				f5xc.WithAuthToken("test-token"),
+				tst.option,
+			)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Fatalf("NewClient raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Fatalf("Expected NewClient to raise %v, got %v", tst.expectedError, err)
+			case err != nil:
+				return
+			}
+			t.Cleanup(client.CloseIdleConnections)
+			if _, err := f5xc.GetPublicKey(context.Background(), client, nil); err != nil {
+				t.Errorf("GetPublicKey raised an unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// Verify that a client can call an API with an untrusted certificate only if verification is disabled.
+func TestNewClient_WithInsecureSkipVerify(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{Data: f5xc.PublicKey{KeyVersion: 1}})
+	}))
+	t.Cleanup(server.Close)
+	for name, insecure := range map[string]bool{"verified": false, "insecure": true} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			options := []f5xc.Option{f5xc.WithAPIEndpoint(server.URL + "/api"), f5xc.WithAuthToken("test-token")}
+			if insecure {
+				options = append(options, f5xc.WithInsecureSkipVerify())
+			}
+			client, err := f5xc.NewClient(options...)
+			if err != nil {
+				t.Fatalf("NewClient raised an unexpected error: %v", err)
+			}
+			t.Cleanup(client.CloseIdleConnections)
+			_, err = f5xc.GetPublicKey(context.Background(), client, nil)
+			switch {
+			case insecure && err != nil:
+				t.Errorf("GetPublicKey raised an unexpected error: %v", err)
+			case !insecure && err == nil:
+				t.Error("Expected GetPublicKey to fail certificate verification")
+			}
+		})
+	}
+}
+
+// Verify that the TLS configuration override changes the TLS versions that the client negotiates with the API server.
+func TestNewClient_WithTLSConfigOverride(t *testing.T) {
+	t.Parallel()
+	tlsVersions := make(chan uint16, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tlsVersions <- r.TLS.Version
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{Data: f5xc.PublicKey{KeyVersion: 1}})
+	}))
+	server.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	tests := []struct {
+		name        string
+		override    func(*tls.Config)
+		expected    uint16
+		expectedErr bool
+	}{
+		{
+			name:     "default",
+			expected: tls.VersionTLS13,
+		},
+		{
+			name: "tls12",
+			override: func(config *tls.Config) {
+				config.MaxVersion = tls.VersionTLS12
+				config.CipherSuites = []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}
+			},
+			expected: tls.VersionTLS12,
+		},
+		{
+			name: "unsupported",
+			override: func(config *tls.Config) {
+				config.MaxVersion = tls.VersionTLS11
+			},
+			expectedErr: true,
+		},
+	}
+	for _, tst := range tests {
+		options := testServerOptions(t, server)
+		if tst.override != nil {
+			options = append(options, f5xc.WithTLSConfigOverride(tst.override))
+		}
+		client, err := f5xc.NewClient(options...)
+		if err != nil {
+			t.Fatalf("%s: NewClient raised an unexpected error: %v", tst.name, err)
+		}
+		_, err = f5xc.GetPublicKey(context.Background(), client, nil)
+		client.CloseIdleConnections()
+		switch {
+		case tst.expectedErr:
+			if err == nil {
+				t.Errorf("%s: expected GetPublicKey to fail the TLS handshake", tst.name)
+			}
+		case err != nil:
+			t.Errorf("%s: GetPublicKey raised an unexpected error: %v", tst.name, err)
+		default:
+			if version := <-tlsVersions; version != tst.expected {
+				t.Errorf("%s: expected TLS version %s, got %s", tst.name, tls.VersionName(tst.expected), tls.VersionName(version))
+			}
+		}
+	}
+	if _, err := f5xc.NewClient(f5xc.WithAPIEndpoint(server.URL+"/api"), f5xc.WithAuthToken("test-token"), f5xc.WithTLSConfigOverride(nil)); !errors.Is(err, f5xc.ErrInvalidOption) {
+		t.Errorf("Expected NewClient to raise %v for a nil override, got %v", f5xc.ErrInvalidOption, err)
+	}
+}
+
+// Verify that a client with a token source adds the current token to each request, and fails requests when the source
+// fails.
+func TestNewClient_WithTokenSource(t *testing.T) {
+	t.Parallel()
+	errSource := errors.New("token source failed")
+	var calls atomic.Int32
+	authorization := make(chan string, 1)
+	client, serverURL := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization <- r.Header.Get("Authorization")
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{Data: f5xc.PublicKey{KeyVersion: 1}})
+	}), f5xc.WithTokenSource(func(_ context.Context) (string, error) {
+		switch call := calls.Add(1); call {
+		case 3:
+			return "", errSource
+		case 4:
+			return "", nil
+		default:
+			return fmt.Sprintf("token-%d", call), nil
+		}
+	}))
+	tests := []struct {
+		expectedHeader string
+		expectedErr    error
+	}{
+		{expectedHeader: "APIToken token-1"},
+		{expectedHeader: "APIToken token-2"},
+		{expectedErr: errSource},
+		{expectedErr: f5xc.ErrMissingAuthentication},
+	}
+	for i, tst := range tests {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, serverURL+f5xc.PublicKeyURL, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		_, err = f5xc.EnvelopeAPICall[f5xc.PublicKey](client, req)
+		switch {
+		case tst.expectedErr != nil:
+			if !errors.Is(err, tst.expectedErr) {
+				t.Errorf("%d: expected EnvelopeAPICall to raise %v, got %v", i, tst.expectedErr, err)
+			}
+		case err != nil:
+			t.Errorf("%d: EnvelopeAPICall raised an unexpected error: %v", i, err)
+		default:
+			if header := <-authorization; header != tst.expectedHeader {
+				t.Errorf("%d: expected Authorization header %q, got %q", i, tst.expectedHeader, header)
+			}
+		}
+	}
+	if _, err := f5xc.NewClient(f5xc.WithAPIEndpoint(serverURL+"/api"), f5xc.WithTokenSource(nil)); !errors.Is(err, f5xc.ErrInvalidOption) {
+		t.Errorf("Expected NewClient to raise %v for a nil token source, got %v", f5xc.ErrInvalidOption, err)
+	}
+}
+
+// Verify that a client with credentials authenticates with the preferred form that is configured, and ignores the
+// others; the test token of the client is kept if no form is configured.
+// NOTE: Requires test certificates in testdata which can be generated by Makefile.
+func TestNewClient_WithCredentials(t *testing.T) {
+	t.Parallel()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{Data: f5xc.PublicKey{Tenant: r.Header.Get("Authorization")}})
+	})
+	tests := []struct {
+		name           string
+		credentials    f5xc.Credentials
+		expectedHeader string
+	}{
+		{
+			name: "token",
+			credentials: f5xc.Credentials{
+				APIToken:  "preferred-token",
+				P12Bundle: "testdata/missing.p12",
+				Cert:      "testdata/missing.pem",
+				Key:       "testdata/missing-key.pem",
+			},
+			expectedHeader: "APIToken preferred-token",
+		},
+		{
+			name: "p12",
+			credentials: f5xc.Credentials{
+				P12Bundle:     TestPKCS12Certificate,
+				P12Passphrase: TestPKCS12Passphrase,
+				Cert:          "testdata/missing.pem",
+				Key:           "testdata/missing-key.pem",
+			},
+		},
+		{
+			name:        "cert-key",
+			credentials: f5xc.Credentials{Cert: TestX509Certificate, Key: TestX509Key},
+		},
+		{
+			name:           "cert-without-key",
+			credentials:    f5xc.Credentials{Cert: TestX509Certificate},
+			expectedHeader: "APIToken test-token",
+		},
+		{
+			name:           "none",
+			expectedHeader: "APIToken test-token",
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			client, _ := testAPIClient(t, handler, f5xc.WithCredentials(tst.credentials))
+			publicKey, err := f5xc.GetPublicKey(context.Background(), client, nil)
+			switch {
+			case err != nil:
+				t.Errorf("GetPublicKey raised an unexpected error: %v", err)
+			case publicKey.Tenant != tst.expectedHeader:
+				t.Errorf("Expected Authorization header %q, got %q", tst.expectedHeader, publicKey.Tenant)
+			}
+		})
+	}
+}
+
+// Implements f5xc.Doer with a function, so that API functions can be tested without a server.
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Verify that the API functions accept a fake Doer, and a Client.
+func TestEnvelopeAPICall_Doer(t *testing.T) {
+	t.Parallel()
+	var paths []string
+	fake := doerFunc(func(req *http.Request) (*http.Response, error) {
+		paths = append(paths, req.URL.Path)
+		body := `{"data": {"key_version": 3, "policy_id": "test-policy"}}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+	publicKey, err := f5xc.GetPublicKey(context.Background(), fake, nil)
+	if err != nil || publicKey == nil || publicKey.KeyVersion != 3 {
+		t.Errorf("Expected GetPublicKey to return key version 3, got %+v, %v", publicKey, err)
+	}
+	policyDoc, err := f5xc.GetSecretPolicyDocument(context.Background(), fake, "test", "shared")
+	if err != nil || policyDoc == nil || policyDoc.PolicyID != "test-policy" {
+		t.Errorf("Expected GetSecretPolicyDocument to return test-policy, got %+v, %v", policyDoc, err)
+	}
+	expected := []string{f5xc.PublicKeyURL, fmt.Sprintf(f5xc.SecretPolicyDocumentURL, "shared", "test")}
+	if !slices.Equal(paths, expected) {
+		t.Errorf("Expected requests for %v, got %v", expected, paths)
+	}
+	var client f5xc.Doer
+	client, err = f5xc.NewAPIClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"))
+	if err != nil {
+		t.Fatalf("NewAPIClient raised an unexpected error: %v", err)
+	}
+	if _, ok := client.(*f5xc.Client); !ok {
+		t.Errorf("Expected a Client to be a Doer")
+	}
+}
+
+// Verify that a response that is not valid JSON is returned as an Error with the status code of the response.
+func TestEnvelopeAPICall_MalformedResponse(t *testing.T) {
+	t.Parallel()
+	fake := doerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"data": `)),
+			Request:    req,
+		}, nil
+	})
+	_, err := f5xc.GetPublicKey(context.Background(), fake, nil)
+	var apiErr *f5xc.Error
+	switch {
+	case !errors.As(err, &apiErr):
+		t.Errorf("Expected GetPublicKey to raise an Error, got %T: %v", err, err)
+	case apiErr.StatusCode != http.StatusOK || apiErr.Temporary:
+		t.Errorf("Expected a permanent Error with status %d, got %+v", http.StatusOK, apiErr)
+	}
+}
+
+// Benchmarks EnvelopeAPICall with a policy document response, to measure the allocations made to read and unmarshal
+// the response.
+func BenchmarkEnvelopeAPICall(b *testing.B) {
+	rules := make([]f5xc.SecretPolicyRule, 0, 50)
+	for i := range 50 {
+		rules = append(rules, f5xc.SecretPolicyRule{Action: "ALLOW", ClientName: fmt.Sprintf("client-%d", i)})
+	}
+	response, err := json.Marshal(f5xc.Envelope[f5xc.SecretPolicyDocument]{
+		Data: f5xc.SecretPolicyDocument{PolicyID: "test-policy", PolicyInfo: f5xc.SecretPolicyInfo{Algo: "FIRST_MATCH", Rules: rules}},
+	})
+	if err != nil {
+		b.Fatalf("Failed to marshal response: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(response)
+	}))
+	b.Cleanup(server.Close)
+	client := server.Client()
+	b.Cleanup(client.CloseIdleConnections)
+	b.ReportAllocs()
+	for range b.N {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/api/test", nil)
+		if err != nil {
+			b.Fatalf("Failed to create request: %v", err)
+		}
+		if _, err := f5xc.EnvelopeAPICall[f5xc.SecretPolicyDocument](client, req); err != nil {
+			b.Fatalf("EnvelopeAPICall raised an unexpected error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Blindfold-csi-provider is a Kubernetes Secrets Store CSI driver provider that mounts blindfold sealed data into pods
+// after unsealing it through Wingman. It is deployed as a DaemonSet alongside the driver, and listens on a unix socket
+// in the driver's providers directory.
+//
+// Usage:
+//
+//	blindfold-csi-provider [--endpoint PATH] [--wingman-url URL] [--log-level LEVEL]
+//
+// where PATH defaults to /etc/kubernetes/secrets-store-csi-providers/blindfold.sock, and URL is the Wingman endpoint
+// to use when a SecretProviderClass does not set one; the default can also be set through CSI_WINGMAN_URL.
+//
+// A SecretProviderClass selects the provider and lists the sealed objects to mount in the objects parameter. Each
+// objectName is the path of a file relative to the volume, and the optional mode overrides the file permissions set by
+// the driver. The wingmanURL parameter can be used to direct unseal requests to a different Wingman endpoint.
+//
+//	apiVersion: secrets-store.csi.x-k8s.io/v1
+//	kind: SecretProviderClass
+//	metadata:
+//	  name: example
+//	spec:
+//	  provider: blindfold
+//	  parameters:
+//	    wingmanURL: http://wingman.example.svc:8070
+//	    objects: |
+//	      - objectName: db/password
+//	        sealed: "... base64 encoded sealed data ..."
+//	        mode: "0400"
+//	      - objectName: config.ini
+//	        sealed: "... base64 encoded sealed data ..."
+package main
+
+import (
+	"os"
+
+	"github.com/memes/f5xc/internal/csi"
+)
+
+// The version of blindfold-csi-provider, which is set at build time.
+var version = "" //nolint:gochecknoglobals // Set by the linker at build time
+
+func main() {
+	os.Exit(csi.Run(version, os.Args[1:]))
+}
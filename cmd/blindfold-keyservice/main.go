@@ -0,0 +1,36 @@
+// Blindfold-keyservice is a SOPS keyservice that uses F5 Distributed Cloud blindfold as a key management service. Data
+// keys are sealed with the tenant public key and a secret policy when SOPS encrypts a file, and unsealed through
+// Wingman when SOPS decrypts it, so existing SOPS workflows can protect secrets with blindfold without a fork of SOPS.
+//
+// Usage:
+//
+//	blindfold-keyservice [--address URL] [--policy NAME] [--namespace NAMESPACE] [--key-version N] [--cache-dir DIR]
+//	  [--vesctl PATH] [--wingman-url URL] [--log-level LEVEL]
+//
+// where URL is tcp://HOST:PORT (default tcp://127.0.0.1:5000) or unix://PATH. Sealing is performed by vesctl, with the
+// public key and policy document retrieved from the API using the VOLT_API_URL, VOLTERRA_TOKEN, VOLT_API_P12_FILE and
+// VES_P12_PASSWORD, VOLT_API_CERT, VOLT_API_KEY, and VOLT_API_CA_CERT environment variables, or read from a directory
+// populated by f5xc key pull when --cache-dir is set. Unsealing requires a Wingman endpoint whose workload identity is
+// allowed by the secret policy.
+//
+// SOPS has no blindfold key type, so a HashiCorp Vault transit key stands in for the secret policy; the engine path is
+// the namespace and the key name is the secret policy name. Any other key type is sealed with the --policy secret
+// policy. Because SOPS would otherwise try to contact the Vault server, the local keyservice must be disabled:
+//
+//	sops encrypt --enable-local-keyservice=false --keyservice tcp://127.0.0.1:5000 \
+//	  --hc-vault-transit https://f5xc.invalid/v1/shared/keys/app-secrets secrets.yaml > secrets.enc.yaml
+//	sops decrypt --enable-local-keyservice=false --keyservice tcp://127.0.0.1:5000 secrets.enc.yaml
+//
+// The keyservice does not authenticate its clients, and will unseal any data key that Wingman allows; listen on a
+// loopback address or a unix socket with restrictive permissions.
+package main
+
+import (
+	"os"
+
+	"github.com/memes/f5xc/internal/keyservice"
+)
+
+func main() {
+	os.Exit(keyservice.Run(os.Args[1:]))
+}
@@ -0,0 +1,38 @@
+// Blindfold-transit is an HTTP server that exposes a minimal HashiCorp Vault transit secrets engine API backed by F5
+// Distributed Cloud blindfold, so that applications written against the Vault API can run unmodified in vk8s. Encrypt
+// requests are sealed with the tenant public key and the secret policy named by the transit key, and decrypt requests
+// are unsealed through Wingman.
+//
+// Usage:
+//
+//	blindfold-transit [--address HOST:PORT] [--mount PATH] [--tls-cert FILE --tls-key FILE] [--namespace NAMESPACE]
+//	  [--key-version N] [--cache-dir DIR] [--vesctl PATH] [--wingman-url URL] [--log-level LEVEL]
+//
+// The server listens on 127.0.0.1:8200 by default, and serves the following endpoints where MOUNT defaults to transit
+// and NAME is the name of a secret policy:
+//
+//	POST /v1/MOUNT/encrypt/NAME  {"plaintext": BASE64} or {"batch_input": [{"plaintext": BASE64}, ...]}
+//	POST /v1/MOUNT/decrypt/NAME  {"ciphertext": "vault:v1:..."} or {"batch_input": [{"ciphertext": ...}, ...]}
+//	GET  /v1/sys/health
+//
+// Secret policies are looked up in the --namespace namespace (default shared), unless a request sets the Vault
+// Enterprise X-Vault-Namespace header. Sealing is performed by vesctl, with the public key and policy document
+// retrieved from the API using the VOLT_API_URL, VOLTERRA_TOKEN, VOLT_API_P12_FILE and VES_P12_PASSWORD,
+// VOLT_API_CERT, VOLT_API_KEY, and VOLT_API_CA_CERT environment variables, or read from a directory populated by
+// f5xc key pull when --cache-dir is set. Unsealing requires the Wingman sidecar of the pod, whose workload identity
+// must be allowed by the secret policy.
+//
+// Only the encrypt and decrypt operations are implemented, and Vault tokens are not checked; run the server as a
+// sidecar listening on a loopback address and point the application at it, e.g. VAULT_ADDR=http://127.0.0.1:8200.
+// Ciphertexts always declare key version 1 as blindfold does not expose the public key version of sealed data.
+package main
+
+import (
+	"os"
+
+	"github.com/memes/f5xc/internal/transit"
+)
+
+func main() {
+	os.Exit(transit.Run(os.Args[1:]))
+}
@@ -0,0 +1,46 @@
+// Docker-credential-f5xc is a Docker credential helper that stores registry credentials as blindfold sealed data, so
+// that CE hosts can pull from private registries without keeping plaintext registry secrets on disk. Secrets are
+// sealed when stored, and unsealed through Wingman each time Docker requests them.
+//
+// Usage:
+//
+//	docker-credential-f5xc <store|get|erase|list|version>
+//
+// The helper implements the Docker credential helper protocol and is not usually invoked directly; instead add it to
+// the Docker configuration file, e.g. ~/.docker/config.json:
+//
+//	{
+//	  "credHelpers": {
+//	    "registry.example.com": "f5xc"
+//	  }
+//	}
+//
+// The helper is configured through environment variables:
+//
+//	DOCKER_CREDENTIAL_F5XC_DIR         The directory of sealed credentials, default ~/.docker/f5xc-credentials
+//	DOCKER_CREDENTIAL_F5XC_KEEPER_URL  The f5xc:// keeper URL used to seal and unseal, default f5xc://
+//	DOCKER_CREDENTIAL_F5XC_TIMEOUT     The maximum time to wait for sealing or unsealing, default 30s
+//
+// The default keeper URL unseals through the Wingman sidecar at its default address but cannot seal; credentials can
+// be stored on a CE host by setting a keeper URL that names the secret policy and a cache directory populated by
+// `f5xc key pull`, or by storing the credentials on a workstation and copying the sealed files to the host.
+//
+// Example:
+//
+//	echo '{"ServerURL":"registry.example.com","Username":"user","Secret":"password"}' | \
+//	  DOCKER_CREDENTIAL_F5XC_KEEPER_URL='f5xc://shared/my-policy?cache_dir=/var/cache/f5xc' \
+//	  docker-credential-f5xc store
+package main
+
+import (
+	"os"
+
+	"github.com/memes/f5xc/internal/credhelper"
+)
+
+// The version of docker-credential-f5xc, which is set at build time.
+var version = "" //nolint:gochecknoglobals // Set by the linker at build time
+
+func main() {
+	os.Exit(credhelper.Run(version, os.Args[1:], os.Stdin, os.Stdout, os.Getenv))
+}
@@ -0,0 +1,56 @@
+// F5xc is a command line utility that combines the seal and unseal utilities with commands to retrieve and inspect the
+// public keys and secret policy documents used to seal data in F5 Distributed Cloud.
+//
+// Usage:
+//
+//	f5xc [--api-url URL] [--api-token TOKEN | --p12-bundle PATH | --cert PATH --key PATH] COMMAND [ARG...]
+//
+// where COMMAND is one of:
+//
+//	seal     Seal files or standard input with the public key and a named secret policy
+//	reseal   Reseal sealed files and specifications in place after a key or policy rotation
+//	scan     Find plaintext secrets in files, and optionally seal them in place; suitable for a pre-commit hook
+//	unseal   Unseal specifications of sealed data through Wingman; this is identical to the unseal utility
+//	inspect  Describe a sealed value, or summarize a secret policy and compare it with a sealed value
+//	policy   Retrieve secret policy documents, e.g. f5xc policy get --namespace shared NAME
+//	key      Retrieve the tenant public key, or pull keys and policies to a cache directory for offline sealing
+//	selftest Seal a random nonce and unseal it through Wingman, reporting the stage that fails; api, policy, seal,
+//	         wingman, unseal, or decode
+//	version  Print the version of f5xc; --output=yaml or --output=json adds the build of the f5xc module
+//
+// Commands that call the F5 Distributed Cloud API share the client flags, which can also be set through the environment
+// variables used by vesctl; VOLT_API_URL, VOLTERRA_TOKEN, VOLT_API_P12_FILE with the passphrase in VES_P12_PASSWORD,
+// VOLT_API_CERT, VOLT_API_KEY, and VOLT_API_CA_CERT. The unseal command ignores the client flags and talks only to
+// Wingman. Structured output is written as YAML unless --output=json is given.
+//
+// Set --api-cache-dir, or F5XC_API_CACHE_DIR, to cache the public keys and policy documents retrieved from the API
+// for --api-cache-ttl so that repeated invocations, e.g. in CI jobs, do not call the API every time; each entry is
+// protected by an HMAC with a key generated in the directory, and expired entries are used for up to a day if the API
+// is unavailable.
+//
+// Each API request must complete within --api-timeout, 30s by default, unless it is set to 0. Requests use the proxy
+// from HTTPS_PROXY and NO_PROXY unless --api-proxy is set to the URL of a proxy, which may include credentials.
+//
+// The repository provides a pre-commit hook that runs the scan command on staged files, failing the commit if
+// plaintext secrets are found:
+//
+//	repos:
+//	  - repo: https://github.com/memes/f5xc
+//	    rev: vX.Y.Z
+//	    hooks:
+//	      - id: f5xc-scan
+//	        args: [--fix, --policy, app-secrets, --cache-dir, .f5xc]
+package main
+
+import (
+	"os"
+
+	"github.com/memes/f5xc/internal/cli"
+)
+
+// The version of f5xc, which is set at build time.
+var version = "" //nolint:gochecknoglobals // Set by the linker at build time
+
+func main() {
+	os.Exit(cli.Execute(version, os.Args[1:]))
+}
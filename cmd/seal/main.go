@@ -0,0 +1,76 @@
+// Seal is a utility that will blindfold files, or standard input, using the public key and a named secret policy of an
+// F5 Distributed Cloud tenant, and write the base64 encoded sealed data to standard output. It is the companion to
+// unseal; the output can be used as a sealed value, or as an unseal specification.
+//
+// Usage:
+//
+//	seal --policy NAME [--namespace NAMESPACE] [--output base64|spec] [TARGET=]FILE [...[TARGET=]FILE]
+//	seal [--recipients-file PATH] [--recipient NAME|TENANT/NAMESPACE/POLICY[@KEY_VERSION]] [TARGET=]FILE [...]
+//
+// where FILE is the path to a file containing the plaintext, or - to read the plaintext from standard input. If no FILE
+// is given and standard input is not a terminal, standard input will be sealed.
+//
+// By default each FILE is sealed and the base64 encoded sealed data is written to standard output, one value per line
+// in the order given. Setting --output=spec will instead write a JSON unseal specification that maps each TARGET to the
+// sealed data of FILE; if TARGET is omitted the absolute path of FILE is used, so standard input must always be given a
+// target.
+//
+//	seal --policy app-secrets --output spec /etc/app/db.pass=db.pass /etc/app/tls.key=- < tls.key > unseal.json
+//
+// Sealing is performed offline by vesctl, which must be on the PATH or given by --vesctl, but the public key and policy
+// document are retrieved from the F5 Distributed Cloud API at --api-url. Authentication uses an API token given by
+// --api-token, a PKCS#12 bundle given by --p12-bundle with the passphrase in the VES_P12_PASSWORD environment variable,
+// or a certificate and key pair given by --cert and --key. Each of these can also be set through the environment
+// variables used by vesctl; VOLT_API_URL, VOLTERRA_TOKEN, VOLT_API_P12_FILE, VOLT_API_CERT, and VOLT_API_KEY, and an
+// additional CA certificate can be trusted with --ca-cert or VOLT_API_CA_CERT. The current version of the public key is
+// used unless --key-version is set.
+//
+// A pipeline that seals for several environments can name each target in a recipients file instead of repeating the
+// policy, namespace, and key version flags. Each line of the file holds a recipient, TENANT/NAMESPACE/POLICY with an
+// optional @KEY_VERSION suffix, preceded by an optional name; blank lines and lines starting with # are ignored. The
+// --recipient flag selects a recipient by name, and may be omitted if the file holds a single recipient; without a
+// file it gives the recipient directly. Sealing fails if the public key or secret policy document belong to a tenant
+// other than the recipient tenant, so that credentials for the wrong environment are caught before anything is sealed.
+//
+//	# NAME   RECIPIENT
+//	prod     acme/shared/app-secrets@3
+//	staging  acme-staging/shared/app-secrets
+//
+//	seal --recipients-file recipients.txt --recipient "$ENVIRONMENT" db.pass
+//
+// For supply-chain audits --provenance writes a signed attestation of the sealed data to a file, signed with the PEM
+// encoded ECDSA, Ed25519, or RSA private key given by --provenance-key. The attestation is a DSSE envelope holding an
+// in-toto statement; each subject is a FILE, or its TARGET in spec output, with the SHA-256 digest of its sealed data,
+// and the predicate records the seal version, the time, and the tenant, public key version, and secret policy used.
+// Nothing derived from the plaintext is recorded. Attestations can be checked with the Verify method of
+// blindfold.Attestation, or by any DSSE verifier given the public key.
+//
+//	seal --recipient acme/shared/app-secrets --provenance db.pass.intoto.json --provenance-key signing.pem db.pass
+//
+// The --terraform-external flag implements the Terraform external data source protocol; the JSON object query is read
+// from standard input and a JSON object mapping each key to the sealed data of its value is written to standard output.
+//
+//	data "external" "sealed" {
+//	  program = ["seal", "--policy", "app-secrets", "--terraform-external"]
+//	  query   = { db_password = var.db_password }
+//	}
+//
+// The --manifests flag transforms Kubernetes manifests read from standard input, as a Helm post-renderer or kustomize
+// KRM function; the values of each Secret annotated with seal.f5xc/seal are replaced with sealed data, so that rendered
+// manifests can be committed to a GitOps repository without plaintext secrets.
+//
+//	helm template app ./chart --post-renderer seal --post-renderer-args=--manifests \
+//	  --post-renderer-args=--policy=app-secrets
+//
+// Seal is equivalent to the seal subcommand of f5xc; the client flags may be given before or after the seal flags.
+package main
+
+import (
+	"os"
+
+	"github.com/memes/f5xc/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.Execute("", append([]string{"seal"}, os.Args[1:]...)))
+}
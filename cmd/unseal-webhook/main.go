@@ -0,0 +1,45 @@
+// Unseal-webhook is a Kubernetes mutating admission webhook that adds the unseal init container to pods that declare
+// blindfold sealed data in their annotations, so that application manifests do not need to be edited by hand.
+//
+// Usage:
+//
+//	unseal-webhook --image IMAGE --tls-cert PATH --tls-key PATH [--address ADDR] [--wingman-url URL] [--log-level LEVEL]
+//
+// where IMAGE is a container image that provides the unseal binary, and the TLS certificate must be trusted through the
+// caBundle of the MutatingWebhookConfiguration. Admission requests are served on /mutate, and /healthz can be used for
+// liveness and readiness probes.
+//
+// A pod requests injection by declaring one or more unseal specifications in annotations:
+//
+//	unseal.f5xc/spec            An inline JSON or YAML specification
+//	unseal.f5xc/spec-configmap  The name of a ConfigMap in the pod's namespace containing specifications
+//	unseal.f5xc/spec-url        A comma-separated list of http(s) URLs of specifications
+//
+// The webhook adds an in-memory volume, mounted read-only in every existing container at /var/run/secrets/unseal, and
+// an unseal init container that runs before any other init container and writes the unsealed files to the volume. The
+// specification entries should therefore write files beneath the mount path. Injection can be customized with:
+//
+//	unseal.f5xc/mount-path   Mount the volume of unsealed files at this path instead
+//	unseal.f5xc/wingman-url  Use this Wingman URL instead of the webhook default
+//	unseal.f5xc/refresh      Add a sidecar that refreshes the unsealed files at this interval, e.g. 5m
+//
+// Injected pods are annotated with unseal.f5xc/injected and will not be injected again. Pods with invalid annotations
+// are rejected.
+//
+// Example:
+//
+//	metadata:
+//	  annotations:
+//	    unseal.f5xc/spec: |
+//	      /var/run/secrets/unseal/db-password: "... base64 encoded sealed data ..."
+package main
+
+import (
+	"os"
+
+	"github.com/memes/f5xc/internal/webhook"
+)
+
+func main() {
+	os.Exit(webhook.Run(os.Args[1:]))
+}
@@ -1,11 +1,24 @@
-// Unseal is a utility that will read a JSON input of blindfold data, send each embedded data value to a Wingman
+// Unseal is a utility that will read a JSON or YAML input of blindfold data, send each embedded data value to a Wingman
 // endpoint to be unsealed, writing the unsealed data to the filepath given as a key.
 //
 // Usage:
 //
-//	unseal FILE [...FILE]
+//	unseal [--daemon [--interval DURATION]] [--watch [--debounce DURATION]] FILE [...FILE]
+//	unseal --exec [--daemon ...] [--watch ...] [FILE...] -- COMMAND [ARG...]
+//	unseal [--env-file PATH] [--export] FILE [...FILE]
+//	unseal --raw FILE_OR_B64
+//	unseal [--daemon ...] [--watch ...] --template SRC:DEST[:COMMAND] [...--template SRC:DEST[:COMMAND]] [FILE...]
+//	unseal --version
 //
-// where FILE is a JSON document containing a map of files to be written to base64 encoded sealed data.
+// where FILE is a JSON or YAML document containing a map of files to be written to base64 encoded sealed data. If FILE
+// is -, or no FILE is given and standard input is not a terminal, the document will be read from standard input. The
+// format is determined by the file extension (.json, .yaml, or .yml), or by examining the content when reading from
+// standard input or other files.
+//
+// Each FILE may also be a directory or a glob pattern, e.g. /etc/unseal.d or '/etc/unseal.d/*.json'; directories are
+// expanded to the .json, .yaml, and .yml files they contain. Matching files are processed in lexical order so that
+// packaging systems can drop specification fragments into a directory, and in daemon or watch mode new fragments are
+// picked up on the next refresh.
 //
 // Example JSON: This will lead to the creation or refreshing of /var/lib/foo/bar.yaml and /etc/foo.ini.
 //
@@ -13,101 +26,229 @@
 //	  "/var/lib/foo/bar.yaml": "... base64 encoded sealed data ...",
 //	  "/etc/foo.ini": "... base64 encoded sealed data ..."
 //	}
+//
+// Example YAML: equivalent to the JSON example above.
+//
+//	/var/lib/foo/bar.yaml: "... base64 encoded sealed data ..."
+//	/etc/foo.ini: "... base64 encoded sealed data ..."
+//
+// Files are written with 0640 permissions by default, which can be changed by setting --file-mode to an octal value.
+// Each value may instead be an object with the base64 encoded sealed data and optional file attributes; mode and
+// dirMode are octal strings, owner and group may be names or numeric ids, and dirMode is used to create any missing
+// parent directories.
+//
+// Files are replaced atomically by writing to a temporary file in the same directory and renaming it over the target;
+// files that already contain the unsealed data are not rewritten.
+// Missing parent directories are created with 0750 permissions unless overridden by the entry's dirMode, or by setting
+// --dir-mode to an octal value. Setting --backup will preserve the previous content of a replaced file alongside it,
+// e.g. foo.ini.bak, for quick rollback if the new content is bad; --backup=SUFFIX will use a different suffix. Setting
+// --umask to an octal value replaces the process umask, which applies to created directories, hooks, and executed
+// commands, and clears the masked bits from the permissions of every written file, e.g. --umask 077 ensures that
+// unsealed files are only accessible to their owner regardless of the modes declared in the specification.
+//
+//	{
+//	  "/etc/nginx/tls/server.key": {
+//	    "data": "... base64 encoded sealed data ...",
+//	    "mode": "0600",
+//	    "owner": "nginx",
+//	    "group": "nginx",
+//	    "dirMode": "0750"
+//	  }
+//	}
+//
+// Instead of data an entry may declare a Go text/template file and a set of named sealed inputs; the inputs are
+// unsealed and the rendered template is written to the file, so that configuration files can embed secrets without a
+// second templating pass. Template paths are relative to the working directory, and referencing an undeclared input is
+// an error.
+//
+//	{
+//	  "/etc/app/app.yaml": {
+//	    "template": "/etc/app/app.yaml.tmpl",
+//	    "inputs": {
+//	      "password": "... base64 encoded sealed data ..."
+//	    }
+//	  }
+//	}
+//
+// Where app.yaml.tmpl contains e.g. database: postgres://app:{{ .password }}@db:5432/app.
+//
+// A template can also unseal values itself with the blindfold function, which reads base64 encoded sealed data from a
+// file, resolved relative to the directory containing the template, e.g. password: {{ blindfold "db.b64" }}. Such
+// templates do not need inputs, and can be declared on the command line without a specification in the consul-template
+// form --template SRC:DEST[:COMMAND], which may be repeated; the rendered DEST is rewritten only when its content
+// changes, and then the optional COMMAND is run with the system shell. Combined with --daemon or --watch, where changes
+// to the SRC templates also trigger a refresh, this lets Nomad tasks and systemd units on CE hosts keep rendered
+// configuration current without Kubernetes. Paths containing a colon cannot be declared this way.
+//
+//	unseal --daemon --template /etc/app/app.yaml.tmpl:/etc/app/app.yaml:'systemctl reload app'
+//
+// By default the files are processed once and the utility exits. When --daemon is set the utility will continue to run,
+// re-reading and re-processing the specification files every --interval (default 5m) until terminated, so that resealed
+// data is refreshed without restarting the container. Similarly, --watch will keep the utility running and re-process
+// the specification files as soon as they change, e.g. when a projected ConfigMap is updated; changes are debounced so
+// that a burst of file events triggers a single refresh. The two modes can be combined, and in both modes a failed
+// refresh will be retried with exponential backoff. In either mode sending SIGHUP to unseal will re-read every
+// specification and refresh the files immediately.
+//
+// In exec mode the specification files are processed and then COMMAND is executed with ARGs. Entries that declare an
+// env name are exported as environment variables of COMMAND rather than written to a file; other entries are written
+// as usual. Unless daemon or watch mode is also requested unseal is replaced by COMMAND, which inherits the process id
+// and receives signals directly. When combined with daemon or watch mode COMMAND is started as a child process, signals
+// are forwarded to it, files continue to be refreshed, and unseal exits with the child's exit code when it completes;
+// environment variables cannot be refreshed in a running process. SIGHUP is forwarded to COMMAND only after the
+// triggered refresh has completed, so that COMMAND reloads the refreshed files.
+//
+//	{
+//	  "DATABASE_PASSWORD": {
+//	    "data": "... base64 encoded sealed data ...",
+//	    "env": "DATABASE_PASSWORD"
+//	  }
+//	}
+//
+// Entries that declare an env name can also be rendered as KEY="value" lines to a dotenv file by setting --env-file,
+// which is rewritten on every refresh in daemon or watch mode, or printed to standard output as shell export statements
+// by setting --export, e.g. eval "$(unseal --export spec.json)". In both cases the env entries are not written to
+// files.
+//
+// Many daemons need to be told to reload after a secret changes, so an entry may declare an onChange hook; a command
+// and arguments that will be executed, without a shell, once processing is complete if the file was written with
+// changed content. Hooks shared by several entries are run once, a hook that does not complete within its timeout (or
+// --hook-timeout, default 30s) is killed, and a hook failure will fail the run unless ignoreFailure is set. A shell
+// command given by --on-change will be run after any file, including the env file, is changed.
+//
+//	{
+//	  "/etc/nginx/tls/server.key": {
+//	    "data": "... base64 encoded sealed data ...",
+//	    "onChange": {
+//	      "command": ["nginx", "-s", "reload"],
+//	      "timeout": "10s",
+//	      "ignoreFailure": false
+//	    }
+//	  }
+//	}
+//
+// Instead of a command a hook may send a signal to a running process, identified by the process id in a pid file; one
+// of SIGHUP, SIGINT, SIGQUIT, SIGTERM, SIGUSR1, or SIGUSR2. Signal hooks are not supported on Windows.
+//
+//	"onChange": {
+//	  "signal": "SIGHUP",
+//	  "pidFile": "/run/haproxy.pid"
+//	}
+//
+// Every specification is validated against an embedded JSON Schema before any entry is processed, and each problem is
+// reported with the entry it was found in, e.g. an unknown field, a sealed value that is not valid base64, or a target
+// path that is not absolute. Only entries that declare an env name may use a relative key, and in Kubernetes Secret
+// and systemd credentials modes keys are not paths.
+//
+// Large sealed values do not need to be inlined into the specification; any sealed value, including template inputs,
+// may instead be a file reference such as file:///etc/unseal/db.b64, or file://db.b64 which is resolved relative to
+// the directory containing the specification. The referenced file must contain the base64 encoded sealed data.
+//
+//	{
+//	  "/etc/app/tls.key": "file://tls.key.b64"
+//	}
+//
+// Specification sources may also be http or https URLs, so that a sealed manifest can be pulled from an artifact
+// service. A bearer token can be sent with the request by setting --spec-token, or --spec-token-file which is re-read
+// on every fetch, and a URL fragment of the form #sha256=HEX will verify the checksum of the fetched specification,
+// e.g. https://artifacts.example.com/app/unseal.json#sha256=9f86d0...
+//
+// For the common case of a single secret, --raw will unseal one value and write the plaintext to standard output with
+// no trailing newline, e.g. psql "$(unseal --raw db_pass.b64)". The argument may be a file containing the base64
+// encoded sealed value, - to read it from standard input, or the base64 encoded sealed value itself.
+//
+// Specifications can be checked before deployment by setting --validate, which parses every specification, checks that
+// sealed values are valid base64, templates parse, owners resolve, and target paths are writable, and confirms that
+// Wingman is reachable, all without unsealing; every problem found is reported. Setting --dry-run will unseal every
+// entry but log the files that would be written instead of writing them.
+//
+// Long-lived hosts can be audited by setting --verify, which unseals every entry and compares the result with the
+// content and permissions of the file on disk without writing anything. A JSON summary is printed to standard output
+// with matching files listed as unchanged and drifted, or missing, files listed as failed, and the exit status is 1 if
+// any file has drifted.
+//
+// The Wingman endpoint can be changed by setting --wingman-url; an http(s) URL will use the Wingman REST API, whereas a
+// grpc(s) URL will use the gRPC API, if supported by Wingman. A comma-separated list of URLs will be tried in order
+// until one succeeds, so that hosts with both a sidecar and a node-local Wingman can fall back automatically; a policy
+// denial is not retried with another endpoint. Each unseal request can be bounded by setting --timeout, and the logging
+// level changed with --log-level. By default entries are unsealed one at a time; setting --parallel to a value greater
+// than one will unseal up to that many entries concurrently, which can significantly reduce start up time for large
+// specifications. Transient failures, such as Wingman reporting that it is unavailable or a connection being reset, can
+// be retried for each entry by setting --retries, with an initial delay of --retry-delay that doubles after each
+// attempt; otherwise the first failure stops processing.
+//
+// The --version flag prints the version of the f5xc module that unseal was built from, and exits.
+//
+// Logs are written to standard error as JSON with the source location by default; --log-format=text will write plain
+// text logs that are easier to read interactively, and --log-output will append the logs to a file instead.
+//
+// When --keep-going is set a failure to process an entry, or to read a specification, does not stop processing of the
+// remaining entries. Once complete a JSON summary of the written, unchanged, exported, and failed entries is printed to
+// standard output, and the exit status is 0 if every entry succeeded, 2 if some entries failed, or 1 if every entry
+// failed. In daemon or watch mode a failed entry will trigger a retry of the refresh.
+//
+// Before processing, unseal polls Wingman every --ready-interval (default 10s) until it reports ready. By default it
+// will wait indefinitely; setting --ready-timeout will bound the wait, and unseal will exit with status 3 if Wingman is
+// not ready in time. Setting --no-wait skips the check entirely, for environments where Wingman is known to be ready.
+//
+// Workloads that cannot mount files can receive the unsealed entries in a Kubernetes Secret instead, by setting
+// --to-k8s-secret to namespace/name. Each entry becomes a key of the Secret, named by the entry's env name if set, or
+// the base name of the entry path, and the Secret is created or updated through the in-cluster API server using the
+// pod's service account, which must be allowed to get, create, and patch Secrets in the namespace. Labels can be
+// added with --k8s-secret-label key=value, and owner references with --k8s-owner-ref apiVersion/kind/name/uid so that
+// the Secret is garbage collected with its owner; both may be repeated. The Secret is only updated when its content
+// changes, and is not updated if any entry fails; an update patches the data, labels, and owner references, leaving
+// other fields of the Secret unchanged.
+//
+//	unseal --to-k8s-secret app/db-credentials --k8s-owner-ref apps/v1/Deployment/app/UID spec.json
+//
+// Bare-metal services managed by systemd can receive the unsealed entries as credentials by setting --systemd-creds.
+// Each entry is written to the /run/credstore credential store, or the directory given by --systemd-creds=DIR, as a
+// credential named by the base name of the entry key, with 0400 permissions in a 0700 directory. A service declares the
+// credential with LoadCredential=NAME and reads it from $CREDENTIALS_DIRECTORY, or with systemd-creds cat NAME, and the
+// store is populated by a unit that runs before the service:
+//
+//	# unseal-creds.service
+//	[Unit]
+//	Before=app.service
+//	[Service]
+//	Type=oneshot
+//	ExecStart=/usr/local/bin/unseal --systemd-creds /etc/unseal/app.yaml
+//
+//	# app.service
+//	[Unit]
+//	Requires=unseal-creds.service
+//	After=unseal-creds.service
+//	[Service]
+//	LoadCredential=db-password
+//	ExecStart=/usr/bin/app --password-file=${CREDENTIALS_DIRECTORY}/db-password
+//
+// In daemon or watch mode setting --health-address, e.g. :8080, will serve /healthz and /metrics so that unseal can be
+// probed and monitored like any other container. The health endpoint returns 200 only if the most recent refresh
+// succeeded and Wingman reports ready, with a JSON description of the status, and the metrics endpoint reports
+// Prometheus counters of unseal requests, failures, and files and bytes written.
+//
+// When unseal receives SIGINT or SIGTERM in daemon or watch mode it stops starting new refreshes, but allows a refresh
+// that is in progress, and its unseal requests, up to --shutdown-timeout (default 10s) to complete so that a rollout
+// does not leave files partially updated. Connections to Wingman are closed once the requests have completed.
+//
+// Every flag can also be set through an environment variable named with an UNSEAL_ prefix, e.g. UNSEAL_WINGMAN_URL,
+// UNSEAL_LOG_LEVEL, or UNSEAL_DIR_MODE, or in a JSON or YAML configuration file given by --config or UNSEAL_CONFIG
+// that maps flag names to values. Flags on the command line take precedence over environment variables, which take
+// precedence over the configuration file.
+//
+//	wingman-url: grpc://localhost:8071
+//	log-level: DEBUG
+//	daemon: true
+//	interval: 1m
 package main
 
 import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"log/slog"
-	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
-
-	"github.com/memes/f5xc/wingman"
-)
 
-const (
-	// The environment variable name that can be set to override the default wingman base URL.
-	EnvWingmanURL = "UNSEAL_WINGMAN_URL"
-	// The environment variable name that can be set to change the default [log/slog] logging level.
-	EnvLogLevel = "UNSEAL_LOG_LEVEL"
+	"github.com/memes/f5xc/internal/unseal"
 )
 
 func main() {
-	wingmanURL := os.Getenv(EnvWingmanURL)
-	if wingmanURL == "" {
-		wingmanURL = wingman.DefaultWingmanURL
-	}
-	retCode := 0
-	defer func() {
-		os.Exit(retCode)
-	}()
-	level := slog.LevelVar{}
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
-		AddSource: true,
-		Level:     &level,
-	}).WithAttrs([]slog.Attr{
-		{
-			Key:   "wingmanURL",
-			Value: slog.StringValue(wingmanURL),
-		},
-	})))
-	if ll := os.Getenv(EnvLogLevel); ll != "" {
-		if err := level.UnmarshalText([]byte(ll)); err != nil {
-			slog.Warn("Failed to parse requested log level", EnvLogLevel, ll)
-		}
-	}
-
-	if len(os.Args) <= 1 {
-		slog.Error("No JSON files provided")
-		retCode = 1
-		return
-	}
-
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
-	client := http.DefaultClient
-	defer client.CloseIdleConnections()
-	if err := wingman.WaitForReady(ctx, client, wingmanURL+wingman.StatusEndpoint, 10*time.Second); err != nil {
-		slog.Error("Wingman failed to reach ready status")
-		retCode = 1
-		return
-	}
-	for _, sourceFile := range os.Args[1:] {
-		logger := slog.With("sourceFile", sourceFile)
-		logger.Debug("Attempting to retrieve file data")
-		data, err := os.ReadFile(sourceFile)
-		if err != nil {
-			logger.Error("Error reading JSON specification from file", "error", err)
-			retCode = 1
-			return
-		}
-		if err := process(ctx, client, wingmanURL+wingman.UnsealEndpoint, data); err != nil {
-			slog.Error("Processing failed", "error", err)
-			retCode = 1
-			return
-		}
-	}
-}
-
-func process(ctx context.Context, client *http.Client, endpoint string, payload []byte) error {
-	slog.Debug("Processing JSON payload")
-	var spec map[string]string
-	if err := json.Unmarshal(payload, &spec); err != nil {
-		return fmt.Errorf("failed to parse as JSON: %w", err)
-	}
-	for path, sealed := range spec {
-		slog.Debug("Processing entry", "path", path, "sealed", sealed)
-		unsealed, err := wingman.UnsealEncoded(ctx, client, endpoint, []byte(sealed))
-		if err != nil {
-			return fmt.Errorf("wingman unseal error: %w", err)
-		}
-		if err := os.WriteFile(path, unsealed, 0o640); err != nil { //nolint:gosec // File permissions should include group read
-			return fmt.Errorf("failed to open/truncate file for writing: %w", err)
-		}
-	}
-	return nil
+	os.Exit(unseal.Run(os.Args[1:]))
 }
@@ -0,0 +1,81 @@
+package f5xc
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+// The header that carries the request ID set with [WithRequestID], so that API requests can be correlated with the
+// logs of the caller.
+const RequestIDHeader = "X-Request-Id"
+
+// The type of the keys of values stored in a context by this package.
+type contextKey int
+
+const (
+	// The key of the namespace set by WithNamespace.
+	namespaceKey contextKey = iota
+	// The key of the request ID set by WithRequestID.
+	requestIDKey
+	// The key of the headers set by ContextWithHeaders.
+	headersKey
+)
+
+// WithNamespace returns a copy of ctx that carries the namespace, which is used by API functions when a namespace is
+// not given, e.g. by [GetSecretPolicyDocument] when namespace is empty.
+func WithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, namespaceKey, namespace)
+}
+
+// NamespaceFromContext returns the namespace set by [WithNamespace], or an empty string if one has not been set.
+func NamespaceFromContext(ctx context.Context) string {
+	namespace, _ := ctx.Value(namespaceKey).(string)
+	return namespace
+}
+
+// WithRequestID returns a copy of ctx that carries the request ID, which is sent in the [RequestIDHeader] of every API
+// request made with the context by a client from [NewClient], unless the request already has the header.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestIDFromContext returns the request ID set by [WithRequestID], or an empty string if one has not been set.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// ContextWithHeaders returns a copy of ctx that carries the headers, which are added to every API request made with the
+// context by a client from [NewClient], e.g. to send an x-volterra-apigw-tenant header when one client is used for
+// several tenants. The headers are merged with headers already in ctx, replacing the values of the same header, and
+// replace the headers set by [WithHeader] but not headers that are already in the request.
+func ContextWithHeaders(ctx context.Context, headers http.Header) context.Context {
+	merged := HeadersFromContext(ctx)
+	if merged == nil {
+		merged = make(http.Header, len(headers))
+	}
+	for key, values := range headers {
+		merged[http.CanonicalHeaderKey(key)] = slices.Clone(values)
+	}
+	return context.WithValue(ctx, headersKey, merged)
+}
+
+// HeadersFromContext returns a copy of the headers set by [ContextWithHeaders], or nil if headers have not been set.
+func HeadersFromContext(ctx context.Context) http.Header {
+	headers, _ := ctx.Value(headersKey).(http.Header)
+	return headers.Clone()
+}
+
+// Returns the namespace if it is not empty, or the namespace from the context.
+func resolveNamespace(ctx context.Context, logger *slog.Logger, namespace string) string {
+	if namespace != "" {
+		return namespace
+	}
+	namespace = NamespaceFromContext(ctx)
+	if namespace != "" {
+		logger.Debug("Using namespace from context", "namespace", namespace)
+	}
+	return namespace
+}
@@ -0,0 +1,128 @@
+package f5xc_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/memes/f5xc"
+)
+
+// Verify that the namespace and request ID are stored in and retrieved from a context.
+func TestContextValues(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	if namespace := f5xc.NamespaceFromContext(ctx); namespace != "" {
+		t.Errorf("Expected no namespace, got %q", namespace)
+	}
+	if id := f5xc.RequestIDFromContext(ctx); id != "" {
+		t.Errorf("Expected no request ID, got %q", id)
+	}
+	ctx = f5xc.WithRequestID(f5xc.WithNamespace(ctx, "app"), "req-1")
+	if namespace := f5xc.NamespaceFromContext(ctx); namespace != "app" {
+		t.Errorf("Expected namespace app, got %q", namespace)
+	}
+	if id := f5xc.RequestIDFromContext(ctx); id != "req-1" {
+		t.Errorf("Expected request ID req-1, got %q", id)
+	}
+}
+
+// Verify that GetSecretPolicyDocument uses the namespace from the context when one is not given, and that the request
+// ID from the context is sent with the request.
+func TestGetSecretPolicyDocument_Context(t *testing.T) {
+	t.Parallel()
+	type request struct {
+		path      string
+		requestID string
+	}
+	requests := make(chan request, 1)
+	client, _ := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- request{path: r.URL.Path, requestID: r.Header.Get(f5xc.RequestIDHeader)}
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.SecretPolicyDocument]{
+			Data: f5xc.SecretPolicyDocument{PolicyID: "test-policy"},
+		})
+	}))
+	tests := []struct {
+		name              string
+		ctx               context.Context
+		namespace         string
+		expectedNamespace string
+		expectedRequestID string
+	}{
+		{
+			name:              "namespace",
+			ctx:               context.Background(),
+			namespace:         "shared",
+			expectedNamespace: "shared",
+		},
+		{
+			name:              "context-namespace",
+			ctx:               f5xc.WithNamespace(context.Background(), "app"),
+			expectedNamespace: "app",
+		},
+		{
+			name:              "namespace-overrides-context",
+			ctx:               f5xc.WithNamespace(context.Background(), "app"),
+			namespace:         "shared",
+			expectedNamespace: "shared",
+		},
+		{
+			name:              "request-id",
+			ctx:               f5xc.WithRequestID(f5xc.WithNamespace(context.Background(), "app"), "req-1"),
+			expectedNamespace: "app",
+			expectedRequestID: "req-1",
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			policyDoc, err := f5xc.GetSecretPolicyDocument(tst.ctx, client, "test", tst.namespace)
+			if err != nil {
+				t.Fatalf("GetSecretPolicyDocument raised an unexpected error: %v", err)
+			}
+			if policyDoc == nil || policyDoc.PolicyID != "test-policy" {
+				t.Errorf("Expected policy document test-policy, got %+v", policyDoc)
+			}
+			req := <-requests
+			if expected := fmt.Sprintf(f5xc.SecretPolicyDocumentURL, tst.expectedNamespace, "test"); req.path != expected {
+				t.Errorf("Expected request for %s, got %s", expected, req.path)
+			}
+			if req.requestID != tst.expectedRequestID {
+				t.Errorf("Expected request ID %q, got %q", tst.expectedRequestID, req.requestID)
+			}
+		})
+	}
+}
+
+// Verify that headers from the context are merged, replace the headers of the client, and do not replace headers that
+// are set on the request.
+func TestContextWithHeaders(t *testing.T) {
+	t.Parallel()
+	if headers := f5xc.HeadersFromContext(context.Background()); headers != nil {
+		t.Errorf("Expected no headers, got %v", headers)
+	}
+	headers := make(chan http.Header, 1)
+	client, serverURL := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+		w.WriteHeader(http.StatusNoContent)
+	}), f5xc.WithHeader("X-Client", "client"), f5xc.WithHeader("X-Volterra-Apigw-Tenant", "client-tenant"))
+	ctx := f5xc.ContextWithHeaders(context.Background(), http.Header{"x-volterra-apigw-tenant": {"first-tenant"}, "X-Request": {"context"}})
+	ctx = f5xc.ContextWithHeaders(ctx, http.Header{"X-Volterra-Apigw-Tenant": {"second-tenant"}})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, serverURL+"/api/test", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("X-Request", "request")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request raised an unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	received := <-headers
+	for key, expected := range map[string]string{"X-Client": "client", "X-Volterra-Apigw-Tenant": "second-tenant", "X-Request": "request"} {
+		if values := received.Values(key); len(values) != 1 || values[0] != expected {
+			t.Errorf("Expected %s header %q, got %v", key, expected, values)
+		}
+	}
+}
@@ -0,0 +1,490 @@
+package f5xc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// The partial URL to create API credentials in F5 Distributed Cloud.
+	APICredentialsURL = "/api/web/namespaces/system/api_credentials"
+	// The partial URL to revoke API credentials in F5 Distributed Cloud.
+	APICredentialsRevokeURL = "/api/web/namespaces/system/revoke/api_credentials"
+	// The type of an API credential that is an API token.
+	APICredentialToken = "API_TOKEN"
+	// The type of an API credential that is a client certificate in a PKCS#12 bundle.
+	APICredentialCertificate = "API_CERTIFICATE"
+	// The lifetime of a renewed API credential, unless changed in the [RenewalPolicy].
+	DefaultCredentialExpirationDays = 90
+	// The time before a credential expires that it is renewed, unless changed in the [RenewalPolicy].
+	DefaultCredentialRenewBefore = 7 * 24 * time.Hour
+	// The delay before a failed renewal is attempted again, unless changed in the [RenewalPolicy].
+	DefaultCredentialRetryInterval = 5 * time.Minute
+)
+
+// ErrCredentialRenewal is returned when a renewed API credential cannot be used.
+var ErrCredentialRenewal = errors.New("failed to renew API credential")
+
+// Holds the API token, token source, and client certificate used by the transport of a client, so that they can be
+// replaced while the client is in use. At most one of the token, token source, and certificate is set.
+type credentialStore struct {
+	token  atomic.Pointer[string]
+	source atomic.Pointer[schemedTokenSource]
+	cert   atomic.Pointer[tls.Certificate]
+	// The period before a certificate expires that a warning is given, and the optional function to call.
+	expiryWarning  time.Duration
+	expiryCallback CertificateExpiryFunc
+	// The logger of the client.
+	logger *slog.Logger
+}
+
+// A token source, and the authorization scheme of the tokens it returns.
+type schemedTokenSource struct {
+	source TokenSource
+	scheme string
+}
+
+// Returns a store that holds the token, if not empty, or the token source and its authorization scheme, if not nil, or
+// the certificate.
+func newCredentialStore(token string, source TokenSource, scheme string, cert *tls.Certificate) *credentialStore {
+	store := &credentialStore{}
+	switch {
+	case token != "":
+		store.setAuthToken(token)
+	case source != nil:
+		store.source.Store(&schemedTokenSource{source: source, scheme: scheme})
+	default:
+		store.cert.Store(cert)
+	}
+	return store
+}
+
+// Returns the API token, or an empty string if the client authenticates with a certificate.
+func (s *credentialStore) authToken() string {
+	if token := s.token.Load(); token != nil {
+		return *token
+	}
+	return ""
+}
+
+// Returns the token source and the authorization scheme of its tokens, or nil if the client does not use one.
+func (s *credentialStore) tokenSource() (TokenSource, string) {
+	if source := s.source.Load(); source != nil {
+		return source.source, source.scheme
+	}
+	return nil, ""
+}
+
+// Returns the client certificate, or nil if the client authenticates with an API token.
+func (s *credentialStore) certificate() *tls.Certificate {
+	return s.cert.Load()
+}
+
+// Replaces the credentials with the API token.
+func (s *credentialStore) setAuthToken(token string) {
+	s.token.Store(&token)
+	s.source.Store(nil)
+	s.cert.Store(nil)
+}
+
+// Replaces the credentials with the client certificate, warning if it expires soon.
+func (s *credentialStore) setCertificate(cert *tls.Certificate) {
+	s.cert.Store(cert)
+	s.token.Store(nil)
+	s.source.Store(nil)
+	s.checkExpiry(cert)
+}
+
+// Implements the GetClientCertificate function of tls.Config, returning the current client certificate or an empty
+// certificate so that none is sent.
+func (s *credentialStore) clientCertificate(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if cert := s.certificate(); cert != nil {
+		return cert, nil
+	}
+	return &tls.Certificate{}, nil
+}
+
+// RenewalPolicy defines when a [CredentialManager] renews the API credential of its client, and how the replacement
+// credential is created.
+type RenewalPolicy struct {
+	// The prefix of the names of replacement credentials, which must be a valid F5XC object name; the UTC time of the
+	// renewal and a random suffix are appended to make the name unique.
+	Name string
+	// The lifetime of a replacement credential in days; the default is [DefaultCredentialExpirationDays].
+	ExpirationDays int
+	// The time before a credential expires that it is replaced; the default is [DefaultCredentialRenewBefore].
+	RenewBefore time.Duration
+	// The delay before a failed renewal is attempted again; the default is [DefaultCredentialRetryInterval].
+	RetryInterval time.Duration
+	// The expiry of the initial API token of the client, which cannot be learned from the token itself; if zero the token
+	// is replaced as soon as the manager runs. The expiry of a client certificate is read from the certificate.
+	TokenExpiry time.Time
+	// The time after a credential has been replaced that it is revoked by [CredentialManager.Run]; if zero, the
+	// default, replaced credentials are left to expire. Connections that were established with a replaced client
+	// certificate continue to use it until they are closed, so the period must be longer than such connections are kept
+	// open, e.g. by long-running requests or streams.
+	RevokeAfter time.Duration
+	// The name of the initial API credential of the client, which is revoked like the credentials created by the manager
+	// when RevokeAfter is set; if empty the initial credential is left to expire.
+	InitialName string
+	// An optional function that is called with every replacement credential, e.g. to store it so that a restarted
+	// process can continue to use it.
+	OnRenew func(credential APICredential)
+}
+
+// APICredential is a replacement API credential created by a [CredentialManager].
+type APICredential struct {
+	// The name of the credential.
+	Name string
+	// The type of the credential, either [APICredentialToken] or [APICredentialCertificate].
+	Type string
+	// The API token, or the PKCS#12 bundle of a client certificate.
+	Data []byte
+	// The passphrase of the PKCS#12 bundle, or empty for an API token.
+	Passphrase string
+	// The time when the credential expires.
+	Expiry time.Time
+}
+
+// The request body to create an API credential.
+type createCredentialRequest struct {
+	Name      string               `json:"name"`
+	Namespace string               `json:"namespace"`
+	Spec      createCredentialSpec `json:"spec"`
+}
+
+// The specification of an API credential to create.
+type createCredentialSpec struct {
+	Type           string `json:"type"`
+	Password       string `json:"password,omitempty"`
+	ExpirationDays int    `json:"expiration_days"`
+}
+
+// The response to a request to create an API credential.
+type createCredentialResponse struct {
+	Data                string    `json:"data"`
+	ExpirationTimestamp time.Time `json:"expiration_timestamp"`
+}
+
+// The request body to revoke an API credential.
+type revokeCredentialRequest struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// The response to a request to revoke an API credential, which has no fields.
+type revokeCredentialResponse struct{}
+
+// CredentialManager keeps the API credential of a client valid by replacing it before it expires, so that long-lived
+// processes do not fail when their credential reaches the end of its lifetime. The replacement credential is created by
+// the API credentials endpoint with the current credential, and is of the same type; the client uses it for every new
+// connection and request, and requests that are in flight are not interrupted. Replaced credentials can be revoked once
+// they are no longer in use, so that only the current credential remains valid; see [RenewalPolicy.RevokeAfter]. A
+// client that uses a token source cannot be managed.
+type CredentialManager struct {
+	client      *http.Client
+	credentials *credentialStore
+	policy      RenewalPolicy
+	// Serializes renewals and revocations, and guards expiry, the name of the current credential, and the replaced
+	// credentials that have not been revoked.
+	mu      sync.Mutex
+	expiry  time.Time
+	current string
+	revoke  []replacedCredential
+	// Wakes Run after a renewal, so that it schedules the revocation of the replaced credential.
+	renewed chan struct{}
+}
+
+// A replaced API credential that is waiting to be revoked.
+type replacedCredential struct {
+	name     string
+	revokeAt time.Time
+}
+
+// NewCredentialManager creates a client with the options, and returns a manager that renews its credential according to
+// the policy when [CredentialManager.Run] is called.
+func NewCredentialManager(policy RenewalPolicy, options ...Option) (*CredentialManager, error) {
+	if policy.ExpirationDays == 0 {
+		policy.ExpirationDays = DefaultCredentialExpirationDays
+	}
+	if policy.RenewBefore == 0 {
+		policy.RenewBefore = DefaultCredentialRenewBefore
+	}
+	if policy.RetryInterval == 0 {
+		policy.RetryInterval = DefaultCredentialRetryInterval
+	}
+	switch {
+	case policy.Name == "":
+		return nil, fmt.Errorf("renewal policy name must not be empty: %w", ErrInvalidOption)
+	case policy.ExpirationDays < 0 || policy.RenewBefore < 0 || policy.RetryInterval < 0 || policy.RevokeAfter < 0:
+		return nil, fmt.Errorf("renewal policy durations must not be negative: %w", ErrInvalidOption)
+	case policy.RenewBefore >= time.Duration(policy.ExpirationDays)*24*time.Hour:
+		return nil, fmt.Errorf("renewal policy must renew credentials after they are created: %w", ErrInvalidOption)
+	}
+	cfg, err := newConfig(options...)
+	if err != nil {
+		return nil, err
+	}
+	client, credentials, err := cfg.newClient()
+	if err != nil {
+		return nil, err
+	}
+	manager := &CredentialManager{
+		client:      client,
+		credentials: credentials,
+		policy:      policy,
+		expiry:      policy.TokenExpiry,
+		current:     policy.InitialName,
+		renewed:     make(chan struct{}, 1),
+	}
+	if cert := credentials.certificate(); cert != nil {
+		leaf, err := certificateLeaf(cert)
+		if err != nil {
+			return nil, err
+		}
+		manager.expiry = leaf.NotAfter
+	} else if credentials.authToken() == "" {
+		return nil, fmt.Errorf("a client that uses a token source cannot be managed: %w", ErrInvalidOption)
+	}
+	return manager, nil
+}
+
+// Client returns the client that uses the managed credential.
+func (m *CredentialManager) Client() *http.Client {
+	return m.client
+}
+
+// Expiry returns the time when the current credential expires, or zero if the expiry of an API token is not known.
+func (m *CredentialManager) Expiry() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.expiry
+}
+
+// Run renews the credential when it is within the renew before period of the policy, and revokes replaced credentials
+// once the revoke after period of the policy has passed, retrying failures at the retry interval of the policy until
+// the context is canceled. Run blocks, and returns nil when the context is canceled; failures are logged and retried,
+// since the current credential may remain valid for some time.
+func (m *CredentialManager) Run(ctx context.Context) error {
+	logger := m.credentials.logger.With("name", m.policy.Name)
+	logger.Debug("Managing API credential")
+	wait := m.untilNext(m.untilRenewal())
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logger.Debug("API credential manager is exiting")
+			return nil
+		case <-m.renewed:
+			timer.Stop()
+		case <-timer.C:
+		}
+		m.revokeReplaced(ctx)
+		if m.untilRenewal() > 0 {
+			wait = m.untilNext(m.untilRenewal())
+			continue
+		}
+		if err := m.Renew(ctx); err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			logger.Warn("Failed to renew API credential, will retry", "error", err, "retryInterval", m.policy.RetryInterval)
+			wait = m.untilNext(m.policy.RetryInterval)
+			continue
+		}
+		wait = m.untilNext(m.untilRenewal())
+	}
+}
+
+// Returns the time until the credential should be renewed, which is zero if the expiry is not known.
+func (m *CredentialManager) untilRenewal() time.Duration {
+	expiry := m.Expiry()
+	if expiry.IsZero() {
+		return 0
+	}
+	return max(time.Until(expiry.Add(-m.policy.RenewBefore)), 0)
+}
+
+// Returns the shorter of wait and the time until the next replaced credential should be revoked.
+func (m *CredentialManager) untilNext(wait time.Duration) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, replaced := range m.revoke {
+		wait = min(wait, max(time.Until(replaced.revokeAt), 0))
+	}
+	return wait
+}
+
+// Renew replaces the credential of the client immediately with a new credential of the same type, and closes idle
+// connections so that new connections use it. If the policy sets a revoke after period, the replaced credential is
+// revoked by [CredentialManager.Run] once the period has passed.
+func (m *CredentialManager) Renew(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	credential := APICredential{
+		Type: APICredentialToken,
+	}
+	suffix, err := randomNameSuffix()
+	if err != nil {
+		return err
+	}
+	credential.Name = m.policy.Name + "-" + time.Now().UTC().Format("20060102-150405") + "-" + suffix
+	logger := m.credentials.logger.With("name", credential.Name)
+	logger.Debug("Renewing API credential")
+	if m.credentials.certificate() != nil {
+		credential.Type = APICredentialCertificate
+		passphrase, err := randomPassphrase()
+		if err != nil {
+			return err
+		}
+		credential.Passphrase = passphrase
+	}
+	body, err := json.Marshal(createCredentialRequest{
+		Name:      credential.Name,
+		Namespace: "system",
+		Spec: createCredentialSpec{
+			Type:           credential.Type,
+			Password:       credential.Passphrase,
+			ExpirationDays: m.policy.ExpirationDays,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal API credential request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, APICredentialsURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request for API credential: %w", err)
+	}
+	resp, err := apiCall[createCredentialResponse](m.client, req, true)
+	if err != nil {
+		return fmt.Errorf("failed to create API credential %s: %w", credential.Name, err)
+	}
+	if resp.Data == "" {
+		return fmt.Errorf("API credential %s has no data: %w", credential.Name, ErrCredentialRenewal)
+	}
+	credential.Expiry = resp.ExpirationTimestamp
+	if credential.Expiry.IsZero() {
+		credential.Expiry = time.Now().AddDate(0, 0, m.policy.ExpirationDays)
+	}
+	switch credential.Type {
+	case APICredentialCertificate:
+		data, err := base64.StdEncoding.DecodeString(resp.Data)
+		if err != nil {
+			return fmt.Errorf("failed to decode API credential %s: %w: %w", credential.Name, err, ErrCredentialRenewal)
+		}
+		cert, _, err := decodeP12Certificate(data, credential.Passphrase)
+		if err != nil {
+			return fmt.Errorf("failed to decode API credential %s: %w: %w", credential.Name, err, ErrCredentialRenewal)
+		}
+		credential.Data = data
+		m.credentials.setCertificate(cert)
+	default:
+		credential.Data = []byte(resp.Data)
+		m.credentials.setAuthToken(resp.Data)
+	}
+	m.expiry = credential.Expiry
+	m.client.CloseIdleConnections()
+	logger.Info("Renewed API credential", "type", credential.Type, "expiry", credential.Expiry)
+	if m.current != "" && m.policy.RevokeAfter > 0 {
+		m.revoke = append(m.revoke, replacedCredential{name: m.current, revokeAt: time.Now().Add(m.policy.RevokeAfter)})
+	}
+	m.current = credential.Name
+	select {
+	case m.renewed <- struct{}{}:
+	default:
+	}
+	if m.policy.OnRenew != nil {
+		m.policy.OnRenew(credential)
+	}
+	return nil
+}
+
+// Revokes the replaced credentials that are due with the current credential; credentials that could not be revoked are
+// attempted again after the retry interval of the policy.
+func (m *CredentialManager) revokeReplaced(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pending := m.revoke[:0]
+	for _, replaced := range m.revoke {
+		if time.Now().Before(replaced.revokeAt) {
+			pending = append(pending, replaced)
+			continue
+		}
+		logger := m.credentials.logger.With("name", replaced.name)
+		if err := revokeCredential(ctx, m.client, replaced.name); err != nil {
+			logger.Warn("Failed to revoke replaced API credential, will retry", "error", err, "retryInterval", m.policy.RetryInterval)
+			replaced.revokeAt = time.Now().Add(m.policy.RetryInterval)
+			pending = append(pending, replaced)
+			continue
+		}
+		logger.Info("Revoked replaced API credential")
+	}
+	m.revoke = pending
+}
+
+// Revokes the named API credential.
+func revokeCredential(ctx context.Context, client Doer, name string) error {
+	body, err := json.Marshal(revokeCredentialRequest{
+		Name:      name,
+		Namespace: "system",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal API credential revoke request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, APICredentialsRevokeURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request to revoke API credential: %w", err)
+	}
+	if _, err := apiCall[revokeCredentialResponse](client, req, true); err != nil {
+		return fmt.Errorf("failed to revoke API credential %s: %w", name, err)
+	}
+	return nil
+}
+
+// Returns the parsed leaf certificate of the TLS certificate.
+func certificateLeaf(cert *tls.Certificate) (*x509.Certificate, error) {
+	if cert.Leaf != nil {
+		return cert.Leaf, nil
+	}
+	if len(cert.Certificate) == 0 {
+		return nil, fmt.Errorf("client certificate is empty: %w", ErrInvalidOption)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse client certificate: %w", err)
+	}
+	return leaf, nil
+}
+
+// Returns a random passphrase for a PKCS#12 bundle.
+func randomPassphrase() (string, error) {
+	data := make([]byte, 24)
+	if _, err := rand.Read(data); err != nil {
+		return "", fmt.Errorf("failed to generate passphrase: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Returns a random suffix for the name of a replacement credential, so that renewals within the same second do not
+// create credentials with the same name; the suffix is lowercase so that the name remains a valid F5XC object name.
+func randomNameSuffix() (string, error) {
+	data := make([]byte, 4)
+	if _, err := rand.Read(data); err != nil {
+		return "", fmt.Errorf("failed to generate credential name: %w", err)
+	}
+	return hex.EncodeToString(data), nil
+}
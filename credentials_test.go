@@ -0,0 +1,325 @@
+package f5xc_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// The request body sent by a CredentialManager to create an API credential.
+type testCredentialRequest struct {
+	Name string `json:"name"`
+	Spec struct {
+		Type           string `json:"type"`
+		Password       string `json:"password"`
+		ExpirationDays int    `json:"expiration_days"`
+	} `json:"spec"`
+}
+
+// Returns a PKCS#12 bundle with a new self-signed client certificate with the serial number, encrypted with passphrase.
+func testP12Bundle(t *testing.T, serial int64, passphrase string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "renewed"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	data, err := pkcs12.Modern.Encode(key, cert, nil, passphrase)
+	if err != nil {
+		t.Fatalf("Failed to encode PKCS#12 bundle: %v", err)
+	}
+	return data
+}
+
+// Verify that a CredentialManager replaces an API token with a new token created with the current token, and that the
+// client uses the new token.
+func TestCredentialManager_Token(t *testing.T) {
+	t.Parallel()
+	expiry := time.Now().Add(90 * 24 * time.Hour).UTC().Truncate(time.Second)
+	authorization := make(chan string, 1)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != f5xc.APICredentialsURL {
+			authorization <- r.Header.Get("Authorization")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		var body testCredentialRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Spec.Type != f5xc.APICredentialToken || body.Spec.ExpirationDays != 30 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") != "APIToken test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": "renewed-token", "expiration_timestamp": expiry})
+	}))
+	t.Cleanup(server.Close)
+	renewed := make(chan f5xc.APICredential, 1)
+	manager, err := f5xc.NewCredentialManager(f5xc.RenewalPolicy{
+		Name:           "test",
+		ExpirationDays: 30,
+		RenewBefore:    24 * time.Hour,
+		OnRenew:        func(credential f5xc.APICredential) { renewed <- credential },
+	}, testServerOptions(t, server)...)
+	if err != nil {
+		t.Fatalf("NewCredentialManager raised an unexpected error: %v", err)
+	}
+	t.Cleanup(manager.Client().CloseIdleConnections)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- manager.Run(ctx) }()
+
+	credential := <-renewed
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run raised an unexpected error: %v", err)
+	}
+	if credential.Type != f5xc.APICredentialToken || string(credential.Data) != "renewed-token" || !credential.Expiry.Equal(expiry) {
+		t.Errorf("Unexpected renewed credential: %+v", credential)
+	}
+	if !manager.Expiry().Equal(expiry) {
+		t.Errorf("Expected expiry %v, got %v", expiry, manager.Expiry())
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/api/test", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp, err := manager.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Request raised an unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if header := <-authorization; header != "APIToken renewed-token" {
+		t.Errorf("Expected renewed token to be sent, got %q", header)
+	}
+}
+
+// Verify that a CredentialManager replaces a client certificate with a new certificate, and that new connections
+// present the new certificate.
+func TestCredentialManager_Certificate(t *testing.T) {
+	t.Parallel()
+	serials := make(chan int64, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != f5xc.APICredentialsURL {
+			if len(r.TLS.PeerCertificates) > 0 {
+				serials <- r.TLS.PeerCertificates[0].SerialNumber.Int64()
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		var body testCredentialRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Spec.Type != f5xc.APICredentialCertificate || body.Spec.Password == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": base64.StdEncoding.EncodeToString(testP12Bundle(t, 42, body.Spec.Password))})
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert, MinVersion: tls.VersionTLS12}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	manager, err := f5xc.NewCredentialManager(f5xc.RenewalPolicy{Name: "test"},
+		append(testServerOptions(t, server), f5xc.WithP12Certificate(TestPKCS12Certificate, TestPKCS12Passphrase))...)
+	if err != nil {
+		t.Fatalf("NewCredentialManager raised an unexpected error: %v", err)
+	}
+	t.Cleanup(manager.Client().CloseIdleConnections)
+	initialExpiry := manager.Expiry()
+	if initialExpiry.IsZero() {
+		t.Error("Expected expiry of the initial certificate")
+	}
+	if err := manager.Renew(context.Background()); err != nil {
+		t.Fatalf("Renew raised an unexpected error: %v", err)
+	}
+	if manager.Expiry().Equal(initialExpiry) {
+		t.Error("Expected expiry to change after renewal")
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/api/test", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp, err := manager.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Request raised an unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if serial := <-serials; serial != 42 {
+		t.Errorf("Expected renewed certificate with serial 42, got %d", serial)
+	}
+}
+
+// Verify that a CredentialManager revokes each replaced credential, including the initial credential, so that only the
+// current credential remains live, and that a failed revocation is attempted again by the next renewal.
+func TestCredentialManager_Revoke(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	live := map[string]bool{"initial": true}
+	tokens := map[string]string{"test-token": "initial"}
+	var revokeFailures atomic.Int32
+	revokeFailures.Store(1)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if name := tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "APIToken ")]; !live[name] {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body testCredentialRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Name == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case f5xc.APICredentialsURL:
+			live[body.Name] = true
+			tokens["token-"+body.Name] = body.Name
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": "token-" + body.Name})
+		case f5xc.APICredentialsRevokeURL:
+			if revokeFailures.Add(-1) >= 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			delete(live, body.Name)
+			_, _ = io.WriteString(w, "{}")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	liveCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(live)
+	}
+	// Without a revoke after period replaced credentials are left to expire, and renewals within the same second create
+	// distinct credentials
+	manager, err := f5xc.NewCredentialManager(f5xc.RenewalPolicy{Name: "test", InitialName: "initial"}, testServerOptions(t, server)...)
+	if err != nil {
+		t.Fatalf("NewCredentialManager raised an unexpected error: %v", err)
+	}
+	t.Cleanup(manager.Client().CloseIdleConnections)
+	for i := range 2 {
+		if err := manager.Renew(context.Background()); err != nil {
+			t.Fatalf("Renew %d raised an unexpected error: %v", i, err)
+		}
+	}
+	if count := liveCount(); count != 3 {
+		t.Errorf("Expected 3 live credentials, got %d", count)
+	}
+	// With a revoke after period the replaced credential remains live until the period has passed, and the failed
+	// revocation is retried
+	manager, err = f5xc.NewCredentialManager(f5xc.RenewalPolicy{
+		Name:          "test",
+		InitialName:   "initial",
+		RevokeAfter:   200 * time.Millisecond,
+		RetryInterval: 50 * time.Millisecond,
+	}, testServerOptions(t, server)...)
+	if err != nil {
+		t.Fatalf("NewCredentialManager raised an unexpected error: %v", err)
+	}
+	t.Cleanup(manager.Client().CloseIdleConnections)
+	if err := manager.Renew(context.Background()); err != nil {
+		t.Fatalf("Renew raised an unexpected error: %v", err)
+	}
+	if count := liveCount(); count != 4 {
+		t.Errorf("Expected 4 live credentials after renewal, got %d", count)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- manager.Run(ctx)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for liveCount() != 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run raised an unexpected error: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if live["initial"] || len(live) != 3 {
+		t.Errorf("Expected the initial credential to be revoked, got %v", live)
+	}
+	if revokeFailures.Load() >= 0 {
+		t.Errorf("Expected the failed revocation to be retried")
+	}
+}
+
+// Verify that invalid renewal policies and clients that cannot be managed are rejected.
+func TestNewCredentialManager_Invalid(t *testing.T) {
+	t.Parallel()
+	options := []f5xc.Option{f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token")}
+	tests := []struct {
+		name    string
+		policy  f5xc.RenewalPolicy
+		options []f5xc.Option
+	}{
+		{
+			name:    "no-name",
+			options: options,
+		},
+		{
+			name:    "negative",
+			policy:  f5xc.RenewalPolicy{Name: "test", RetryInterval: -time.Second},
+			options: options,
+		},
+		{
+			name:    "negative-revoke-after",
+			policy:  f5xc.RenewalPolicy{Name: "test", RevokeAfter: -time.Second},
+			options: options,
+		},
+		{
+			name:    "renew-before-too-long",
+			policy:  f5xc.RenewalPolicy{Name: "test", ExpirationDays: 1, RenewBefore: 48 * time.Hour},
+			options: options,
+		},
+		{
+			name:    "token-source",
+			policy:  f5xc.RenewalPolicy{Name: "test"},
+			options: append(options, f5xc.WithTokenSource(func(context.Context) (string, error) { return "token", nil })),
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := f5xc.NewCredentialManager(tst.policy, tst.options...); !errors.Is(err, f5xc.ErrInvalidOption) {
+				t.Errorf("Expected NewCredentialManager to raise %v, got %v", f5xc.ErrInvalidOption, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package f5xc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// The value that replaces secrets in debug logs.
+	redacted = "REDACTED"
+	// The maximum number of bytes of a request or response body that are logged by a client created with
+	// [WithDebugLogBodies].
+	maxDebugBodySize = 16 << 10
+)
+
+// Headers that are always redacted from debug logs.
+var redactedHeaders = []string{ //nolint:gochecknoglobals // Constant list of headers that must never be logged
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// Fragments of JSON field and query parameter names whose values are redacted from debug logs.
+var sensitiveNames = []string{ //nolint:gochecknoglobals // Constant list of names that must never be logged
+	"password",
+	"passphrase",
+	"secret",
+	"token",
+	"private",
+	"sealed",
+	"p12",
+	"credential",
+	"blindfold",
+}
+
+// Log the method, URL, and headers of every request sent by the client, and the status, headers, and latency of every
+// response, at debug level with the logger. Authorization headers and any query parameters that may hold secrets are
+// always redacted; credentials such as P12 passphrases are never added to requests, so they cannot be logged. Use
+// [WithDebugLogBodies] to also log request and response bodies.
+func WithDebugLogging(logger *slog.Logger) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding debug logging")
+		if logger == nil {
+			return fmt.Errorf("debug logger must not be nil: %w", ErrInvalidOption)
+		}
+		c.debugLogger = logger
+		return nil
+	}
+}
+
+// Log up to 16 KiB of the JSON body of every request and response when debug logging is enabled with
+// [WithDebugLogging]. The values of JSON fields with names that suggest a secret, a passphrase, a token, or a sealed or
+// unsealed payload are redacted, and bodies that are not JSON, or are too large to parse, are not logged at all.
+func WithDebugLogBodies() Option {
+	return func(c *config) error {
+		c.log().Debug("Adding debug logging of bodies")
+		c.debugBodies = true
+		return nil
+	}
+}
+
+// Implements a RoundTripper that logs sanitized requests and responses sent by the base RoundTripper.
+type debugTransport struct {
+	// The RoundTripper that calls the API.
+	base http.RoundTripper
+	// The logger that receives the debug records.
+	logger *slog.Logger
+	// True if request and response bodies are logged.
+	bodies bool
+}
+
+// Implements RoundTripper by logging the request, sending it with the base RoundTripper, and logging the response.
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	attrs := []any{
+		"method", req.Method,
+		"url", redactURL(req.URL),
+		"headers", redactHeaders(req.Header),
+	}
+	if t.bodies && req.Body != nil && req.Body != http.NoBody {
+		var body []byte
+		body, req.Body = peekBody(req.Body)
+		attrs = append(attrs, "body", redactBody(body))
+	}
+	t.logger.DebugContext(ctx, "Sending API request", attrs...)
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.logger.DebugContext(ctx, "API request failed", "method", req.Method, "url", redactURL(req.URL), "duration", time.Since(start), "error", err)
+		return nil, err //nolint:wrapcheck // Errors are returned unchanged by the debug transport
+	}
+	// The response records the request as it was sent, after the client has added headers and the API endpoint
+	sent := req
+	if resp.Request != nil {
+		sent = resp.Request
+	}
+	attrs = []any{
+		"method", sent.Method,
+		"url", redactURL(sent.URL),
+		"requestHeaders", redactHeaders(sent.Header),
+		"status", resp.StatusCode,
+		"duration", time.Since(start),
+		"headers", redactHeaders(resp.Header),
+	}
+	if t.bodies && resp.Body != nil && resp.Body != http.NoBody {
+		var body []byte
+		body, resp.Body = peekBody(resp.Body)
+		attrs = append(attrs, "body", redactBody(body))
+	}
+	t.logger.DebugContext(ctx, "Received API response", attrs...)
+	return resp, nil
+}
+
+// Forward CloseIdleConnections to the base RoundTripper.
+func (t *debugTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// Returns the base RoundTripper, so that the transport of the client can be found.
+func (t *debugTransport) Unwrap() http.RoundTripper {
+	return t.base
+}
+
+// Returns true if a field or parameter with the name may hold a secret.
+func isSensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, fragment := range sensitiveNames {
+		if strings.Contains(name, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the URL as a string with any password and the values of sensitive query parameters redacted.
+func redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Redacted()
+	}
+	query := u.Query()
+	for name := range query {
+		if isSensitive(name) {
+			query.Set(name, redacted)
+		}
+	}
+	clone := *u
+	clone.RawQuery = query.Encode()
+	return clone.Redacted()
+}
+
+// Returns a copy of the headers with the values of authorization and cookie headers redacted.
+func redactHeaders(headers http.Header) http.Header {
+	clone := headers.Clone()
+	for _, key := range redactedHeaders {
+		if _, ok := clone[key]; ok {
+			clone.Set(key, redacted)
+		}
+	}
+	return clone
+}
+
+// Reads up to maxDebugBodySize bytes of the body, and returns them with a body that replays them before the unread
+// remainder of the original body.
+func peekBody(body io.ReadCloser) ([]byte, io.ReadCloser) {
+	// A read error is returned again to the consumer of the body when the original body is read after the peeked bytes
+	data, _ := io.ReadAll(io.LimitReader(body, maxDebugBodySize))
+	return data, struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: io.MultiReader(bytes.NewReader(data), body),
+		Closer: body,
+	}
+}
+
+// Returns the JSON body with the values of sensitive fields redacted, or a placeholder if the body is not valid JSON,
+// which includes bodies that were truncated.
+func redactBody(body []byte) any {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ""
+	}
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Sprintf("%s (%d bytes of non-JSON or truncated body)", redacted, len(body))
+	}
+	return redactValue(value)
+}
+
+// Recursively redacts the values of sensitive fields of JSON objects.
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if isSensitive(key) {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redactValue(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,94 @@
+package f5xc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/memes/f5xc"
+)
+
+// Verify that a client with debug logging logs requests and responses without secrets, and does not change the bodies
+// that are sent and received.
+func TestWithDebugLogging(t *testing.T) {
+	t.Parallel()
+	const (
+		requestBody  = `{"name":"test","passphrase":"hunter2","nested":[{"sealed_data":"c2VhbGVk"}]}`
+		responseBody = `{"data":{"tenant":"test","private_key":"cHJpdmF0ZQ=="}}`
+	)
+	tests := []struct {
+		name       string
+		options    []f5xc.Option
+		logsBodies bool
+	}{
+		{
+			name:    "metadata",
+			options: []f5xc.Option{},
+		},
+		{
+			name:       "bodies",
+			options:    []f5xc.Option{f5xc.WithDebugLogBodies()},
+			logsBodies: true,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			received := make(chan string, 1)
+			client, serverURL := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				received <- string(body)
+				_, _ = io.WriteString(w, responseBody)
+			}), append(tst.options, f5xc.WithDebugLogging(logger))...)
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, serverURL+"/api/test?token=secret-token&name=test", strings.NewReader(requestBody))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Request raised an unexpected error: %v", err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if err != nil {
+				t.Fatalf("Failed to read response body: %v", err)
+			}
+			if string(body) != responseBody {
+				t.Errorf("Expected response body %s, got %s", responseBody, body)
+			}
+			if body := <-received; body != requestBody {
+				t.Errorf("Expected request body %s, got %s", requestBody, body)
+			}
+			output := logs.String()
+			for _, expected := range []string{"Sending API request", "Received API response", "REDACTED", "name=test"} {
+				if !strings.Contains(output, expected) {
+					t.Errorf("Expected debug log to contain %q: %s", expected, output)
+				}
+			}
+			for _, secret := range []string{"test-token", "secret-token", "hunter2", "c2VhbGVk", "cHJpdmF0ZQ=="} {
+				if strings.Contains(output, secret) {
+					t.Errorf("Expected debug log to redact %q: %s", secret, output)
+				}
+			}
+			if logged := strings.Contains(output, `"tenant":"test"`); logged != tst.logsBodies {
+				t.Errorf("Expected logging of bodies to be %t: %s", tst.logsBodies, output)
+			}
+		})
+	}
+}
+
+// Verify that a nil debug logger is rejected.
+func TestWithDebugLogging_Invalid(t *testing.T) {
+	t.Parallel()
+	_, err := f5xc.NewClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"), f5xc.WithDebugLogging(nil))
+	if !errors.Is(err, f5xc.ErrInvalidOption) {
+		t.Errorf("Expected NewClient to raise %v, got %v", f5xc.ErrInvalidOption, err)
+	}
+}
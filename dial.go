@@ -0,0 +1,143 @@
+package f5xc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// The time allowed to establish a connection to the API server, unless changed with [WithDialTimeout].
+	DefaultDialTimeout = 30 * time.Second
+	// The period between keep-alive probes of connections to the API server, unless changed with [WithKeepAlive].
+	DefaultKeepAlive = 30 * time.Second
+)
+
+// Use the dialer to establish connections to the API server, e.g. to set the local address, the timeout, which includes
+// resolving the name of the API server, and the keep-alive period of connections. The dialer is copied; later changes
+// to it have no effect on the client.
+func WithDialer(dialer *net.Dialer) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding dialer")
+		if dialer == nil {
+			return fmt.Errorf("dialer must not be nil: %w", ErrInvalidOption)
+		}
+		clone := *dialer
+		c.dialer = &clone
+		return nil
+	}
+}
+
+// Limit the time allowed to resolve the name of the API server and establish a connection to it, e.g. to fail fast on
+// lossy links instead of spending the deadline of an operation on a single connection attempt. The default is
+// [DefaultDialTimeout].
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding dial timeout", "timeout", timeout)
+		if timeout <= 0 {
+			return fmt.Errorf("dial timeout must be positive: %w", ErrInvalidOption)
+		}
+		c.configDialer().Timeout = timeout
+		return nil
+	}
+}
+
+// Set the period between keep-alive probes of connections to the API server, so that a connection that is silently
+// dropped is detected; a negative period disables keep-alive probes. The default is [DefaultKeepAlive].
+func WithKeepAlive(period time.Duration) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding keep-alive period", "period", period)
+		if period == 0 {
+			return fmt.Errorf("keep-alive period must not be zero: %w", ErrInvalidOption)
+		}
+		c.configDialer().KeepAlive = period
+		return nil
+	}
+}
+
+// Returns the dialer of the configuration, creating one with the default settings if necessary.
+func (c *config) configDialer() *net.Dialer {
+	if c.dialer == nil {
+		c.dialer = &net.Dialer{
+			Timeout:   DefaultDialTimeout,
+			KeepAlive: DefaultKeepAlive,
+		}
+	}
+	return c.dialer
+}
+
+// Use the function to establish connections to the API server, e.g. to connect through a Unix socket or an SSH tunnel
+// to a bastion host. The function is called with the network and address of the API server, or of the proxy if one is
+// used, and replaces the dialer set by [WithDialer], [WithDialTimeout], and [WithKeepAlive]. TLS is negotiated over
+// the returned connection, and the certificate of the API server is verified with the host name of the endpoint.
+func WithDialContext(dial func(ctx context.Context, network, address string) (net.Conn, error)) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding dial function")
+		if dial == nil {
+			return fmt.Errorf("dial function must not be nil: %w", ErrInvalidOption)
+		}
+		c.dialContext = dial
+		return nil
+	}
+}
+
+// Connect to the IP addresses instead of resolving the host name, e.g. to reach the tenant API through a private path
+// from a site where public DNS for the tenant is blocked. The addresses are tried in order until a connection is
+// established. The name of the host is still used to verify the certificate of the API server.
+func WithResolveOverride(host string, addrs []string) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding resolve override", "host", host, "addrs", addrs)
+		if host == "" || len(addrs) == 0 {
+			return fmt.Errorf("resolve override requires a host and addresses: %w", ErrInvalidOption)
+		}
+		for _, addr := range addrs {
+			if net.ParseIP(addr) == nil {
+				return fmt.Errorf("resolve override address %q is not an IP address: %w", addr, ErrInvalidOption)
+			}
+		}
+		if c.resolveOverrides == nil {
+			c.resolveOverrides = resolveOverrides{}
+		}
+		c.resolveOverrides[strings.ToLower(host)] = slices.Clone(addrs)
+		return nil
+	}
+}
+
+// The signature of a function that establishes a connection, e.g. net.Dialer.DialContext.
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// The IP addresses to use for host names, keyed by lowercase host name.
+type resolveOverrides map[string][]string
+
+// Returns a function that establishes connections with dial, replacing a host name that has an override with each of
+// its IP addresses in turn.
+func (overrides resolveOverrides) dialContext(dial dialFunc, logger *slog.Logger) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return dial(ctx, network, address)
+		}
+		addrs, ok := overrides[strings.ToLower(host)]
+		if !ok {
+			return dial(ctx, network, address)
+		}
+		errs := make([]error, 0, len(addrs))
+		for _, addr := range addrs {
+			logger.Debug("Dialing override address", "host", host, "addr", addr)
+			conn, err := dial(ctx, network, net.JoinHostPort(addr, port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, fmt.Errorf("failed to connect to override addresses of %s: %w", host, errors.Join(errs...))
+	}
+}
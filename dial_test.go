@@ -0,0 +1,208 @@
+package f5xc_test
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+)
+
+// Verify that connections to the API server are made with the dialer, and that the dial timeout is applied.
+func TestWithDialer(t *testing.T) {
+	t.Parallel()
+	var dials atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	tests := []struct {
+		name          string
+		options       []f5xc.Option
+		expectedDials int32
+		expectTimeout bool
+	}{
+		{
+			name: "dialer",
+			options: []f5xc.Option{f5xc.WithDialer(&net.Dialer{
+				Timeout: time.Second,
+				Control: func(_, _ string, _ syscall.RawConn) error {
+					dials.Add(1)
+					return nil
+				},
+			}), f5xc.WithKeepAlive(-1)},
+			expectedDials: 1,
+		},
+		{
+			name:          "dial-timeout",
+			options:       []f5xc.Option{f5xc.WithKeepAlive(time.Minute), f5xc.WithDialTimeout(time.Nanosecond)},
+			expectTimeout: true,
+		},
+	}
+	for _, tst := range tests {
+		dials.Store(0)
+		client, serverURL := testAPIClient(t, handler, tst.options...)
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, serverURL+"/api/test", nil)
+		if err != nil {
+			t.Fatalf("%s: failed to create request: %v", tst.name, err)
+		}
+		resp, err := client.Do(req)
+		var netErr net.Error
+		switch {
+		case tst.expectTimeout:
+			if !errors.As(err, &netErr) || !netErr.Timeout() {
+				t.Errorf("%s: expected request to raise a timeout, got %v", tst.name, err)
+			}
+		case err != nil:
+			t.Errorf("%s: request raised an unexpected error: %v", tst.name, err)
+		default:
+			_ = resp.Body.Close()
+		}
+		if count := dials.Load(); count != tst.expectedDials {
+			t.Errorf("%s: expected %d dials, got %d", tst.name, tst.expectedDials, count)
+		}
+	}
+}
+
+// Verify that invalid dialer options are rejected.
+func TestWithDialer_Invalid(t *testing.T) {
+	t.Parallel()
+	for _, option := range []f5xc.Option{f5xc.WithDialer(nil), f5xc.WithDialTimeout(0), f5xc.WithDialTimeout(-time.Second), f5xc.WithKeepAlive(0)} {
+		if _, err := f5xc.NewClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"), option); !errors.Is(err, f5xc.ErrInvalidOption) {
+			t.Errorf("Expected NewClient to raise %v, got %v", f5xc.ErrInvalidOption, err)
+		}
+	}
+}
+
+// Verify that a resolve override connects to the first reachable address, and that the certificate of the API server
+// is verified with the host name.
+func TestWithResolveOverride(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS.ServerName != "example.com" {
+			t.Errorf("Expected TLS server name example.com, got %q", r.TLS.ServerName)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to get server port: %v", err)
+	}
+	tests := []struct {
+		name        string
+		host        string
+		addrs       []string
+		expectedErr bool
+	}{
+		{
+			name:  "override",
+			host:  "example.com",
+			addrs: []string{"127.0.0.1"},
+		},
+		{
+			name:  "fallback",
+			host:  "EXAMPLE.com",
+			addrs: []string{"127.0.0.2", "127.0.0.1"},
+		},
+		{
+			name:        "unverified-host",
+			host:        "f5xc.invalid",
+			addrs:       []string{"127.0.0.1"},
+			expectedErr: true,
+		},
+	}
+	for _, tst := range tests {
+		options := append(testServerOptions(t, server),
+			f5xc.WithAPIEndpoint("https://"+net.JoinHostPort(strings.ToLower(tst.host), port)+"/api"),
+			f5xc.WithResolveOverride(tst.host, tst.addrs),
+		)
+		client, err := f5xc.NewClient(options...)
+		if err != nil {
+			t.Fatalf("%s: NewClient raised an unexpected error: %v", tst.name, err)
+		}
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/api/test", nil)
+		if err != nil {
+			t.Fatalf("%s: failed to create request: %v", tst.name, err)
+		}
+		resp, err := client.Do(req)
+		client.CloseIdleConnections()
+		var certErr *tls.CertificateVerificationError
+		switch {
+		case tst.expectedErr:
+			if !errors.As(err, &certErr) {
+				t.Errorf("%s: expected request to raise a certificate verification error, got %v", tst.name, err)
+			}
+		case err != nil:
+			t.Errorf("%s: request raised an unexpected error: %v", tst.name, err)
+		default:
+			_ = resp.Body.Close()
+		}
+	}
+	for _, addrs := range [][]string{nil, {"example.com"}} {
+		if _, err := f5xc.NewClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"), f5xc.WithResolveOverride("f5xc.invalid", addrs)); !errors.Is(err, f5xc.ErrInvalidOption) {
+			t.Errorf("Expected NewClient to raise %v for %v, got %v", f5xc.ErrInvalidOption, addrs, err)
+		}
+	}
+}
+
+// Verify that connections to the API server are established by the dial function, e.g. through a Unix socket.
+func TestWithDialContext(t *testing.T) {
+	t.Parallel()
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to listen on Unix socket: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	_ = server.Listener.Close()
+	server.Listener = listener
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	var dials atomic.Int32
+	// The URL of a server with a Unix socket is not a valid endpoint, so the test server options cannot be used
+	client, err := f5xc.NewClient(
+		f5xc.WithAPIEndpoint("https://example.com/api"),
+		f5xc.WithCACertBytes(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})),
+		f5xc.WithAuthToken("test-token"),
+		f5xc.WithDialTimeout(time.Nanosecond),
+		f5xc.WithDialContext(func(ctx context.Context, _, address string) (net.Conn, error) {
+			if address != "example.com:443" {
+				t.Errorf("Expected dial of example.com:443, got %s", address)
+			}
+			dials.Add(1)
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient raised an unexpected error: %v", err)
+	}
+	t.Cleanup(client.CloseIdleConnections)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "https://example.com/api/test", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request raised an unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if count := dials.Load(); count != 1 {
+		t.Errorf("Expected 1 dial, got %d", count)
+	}
+	if _, err := f5xc.NewClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"), f5xc.WithDialContext(nil)); !errors.Is(err, f5xc.ErrInvalidOption) {
+		t.Errorf("Expected NewClient to raise %v for a nil dial function, got %v", f5xc.ErrInvalidOption, err)
+	}
+}
@@ -0,0 +1,191 @@
+package f5xc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+)
+
+const (
+	// The maximum number of bytes of an API error response body that are read.
+	maxErrorBodySize = 64 << 10
+	// The maximum length of an API error message taken from a response body that is not JSON.
+	maxErrorMessageSize = 512
+)
+
+// Retryable is implemented by errors that report whether the operation that failed may succeed if it is repeated
+// unchanged, e.g. because a service was temporarily unavailable or a connection was reset.
+type Retryable interface {
+	Retryable() bool
+}
+
+// Error describes an operation that failed against an F5 Distributed Cloud API endpoint, a Wingman endpoint, or a
+// vesctl executable. Error wraps the cause, which is usually one of the sentinel errors of the package that returned
+// it, so that [errors.Is] can test for a specific failure and [errors.As] can retrieve the details. The f5xc,
+// blindfold, and wingman packages return an *Error for every failure to communicate with a service or executable.
+type Error struct {
+	// The operation that failed, e.g. GET for an API call, unseal, or vesctl.
+	Op string
+	// The API path, Wingman URL, or vesctl executable that the operation was performed against.
+	Endpoint string
+	// The HTTP status code of the response, or 0 if a response was not received or the protocol is not HTTP.
+	StatusCode int
+	// True if the operation may succeed if it is repeated unchanged.
+	Temporary bool
+	// The cause of the failure.
+	Err error
+}
+
+// Error returns a description of the failure in the form OP ENDPOINT: status CODE: CAUSE.
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Op)
+	if e.Endpoint != "" {
+		b.WriteByte(' ')
+		b.WriteString(e.Endpoint)
+	}
+	if e.StatusCode != 0 {
+		b.WriteString(": status ")
+		b.WriteString(strconv.Itoa(e.StatusCode))
+	}
+	if e.Err != nil {
+		b.WriteString(": ")
+		b.WriteString(e.Err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns the cause of the failure.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Retryable returns true if the operation may succeed if it is repeated unchanged.
+func (e *Error) Retryable() bool {
+	return e.Temporary
+}
+
+// APIError describes an error response from the F5 Distributed Cloud API, with the code and message from the JSON
+// body of the response, e.g. {"code": 3, "message": "invalid namespace", "details": []}, and the request ID that the
+// API can use to find the request in its logs. APIError wraps the package error that describes the status, e.g.
+// [ErrForbidden] or [ErrUnexpectedHTTPStatus], and is itself wrapped by the [*Error] returned from [EnvelopeAPICall],
+// so that [errors.Is] and [errors.As] can be used to test for either.
+type APIError struct {
+	// The code from the response body, usually a gRPC status code such as 3 for an invalid argument; 0 if the body did
+	// not have a code.
+	Code int `json:"code"`
+	// The message from the response body, or the text of the body if it was not JSON.
+	Message string `json:"message"`
+	// Any details from the response body, unparsed.
+	Details []json.RawMessage `json:"details,omitempty"`
+	// The request ID from the X-Request-Id header of the response or, if absent, the request.
+	RequestID string `json:"-"`
+	// The package error that describes the status of the response.
+	Err error `json:"-"`
+}
+
+// Error returns a description of the error response in the form MESSAGE (code CODE, request ID ID): CAUSE, omitting
+// any parts that are not known.
+func (e *APIError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Message)
+	var details []string
+	if e.Code != 0 {
+		details = append(details, "code "+strconv.Itoa(e.Code))
+	}
+	if e.RequestID != "" {
+		details = append(details, "request ID "+e.RequestID)
+	}
+	if len(details) > 0 {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString("(" + strings.Join(details, ", ") + ")")
+	}
+	if e.Err != nil {
+		if b.Len() > 0 {
+			b.WriteString(": ")
+		}
+		b.WriteString(e.Err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns the package error that describes the status of the response.
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Returns an APIError that wraps the cause, populated from the body and headers of the error response.
+func newAPIError(resp *http.Response, cause error) *APIError {
+	apiErr := &APIError{Err: cause}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	body = bytes.TrimSpace(body)
+	if err := json.Unmarshal(body, apiErr); err != nil {
+		apiErr.Code = 0
+		apiErr.Details = nil
+		apiErr.Message = truncateMessage(string(body))
+	}
+	apiErr.RequestID = resp.Header.Get(RequestIDHeader)
+	if apiErr.RequestID == "" && resp.Request != nil {
+		apiErr.RequestID = resp.Request.Header.Get(RequestIDHeader)
+	}
+	return apiErr
+}
+
+// Returns the message limited to maxErrorMessageSize bytes, without splitting a UTF-8 character.
+func truncateMessage(message string) string {
+	if len(message) <= maxErrorMessageSize {
+		return message
+	}
+	end := maxErrorMessageSize
+	for end > 0 && !utf8.RuneStart(message[end]) {
+		end--
+	}
+	return message[:end] + "..."
+}
+
+// IsRetryable returns true if the operation that returned err may succeed if it is repeated unchanged. The first error
+// in the tree of err that implements [Retryable] decides; otherwise timeouts, refused and reset connections, and
+// truncated responses are retryable. A canceled context is never retryable.
+func IsRetryable(err error) bool {
+	var retryable Retryable
+	var netErr net.Error
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+		return false
+	case errors.As(err, &retryable):
+		return retryable.Retryable()
+	case errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.As(err, &netErr):
+		return netErr.Timeout()
+	}
+	return false
+}
+
+// RetryableStatus returns true if an HTTP response with the status code may succeed if the request is repeated
+// unchanged; i.e. 408 Request Timeout, 429 Too Many Requests, 502 Bad Gateway, 503 Service Unavailable, and 504
+// Gateway Timeout.
+func RetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,264 @@
+package f5xc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"syscall"
+	"testing"
+
+	"github.com/memes/f5xc"
+)
+
+// Verify that an Error describes the failure and wraps its cause.
+func TestError(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		err      *f5xc.Error
+		expected string
+	}{
+		{
+			name:     "status",
+			err:      &f5xc.Error{Op: "GET", Endpoint: f5xc.PublicKeyURL, StatusCode: http.StatusForbidden, Err: f5xc.ErrForbidden},
+			expected: "GET " + f5xc.PublicKeyURL + ": status 403: access to endpoint is denied",
+		},
+		{
+			name:     "no-status",
+			err:      &f5xc.Error{Op: "vesctl", Endpoint: "/usr/bin/vesctl", Err: io.ErrUnexpectedEOF},
+			expected: "vesctl /usr/bin/vesctl: unexpected EOF",
+		},
+		{
+			name:     "op-only",
+			err:      &f5xc.Error{Op: "unseal"},
+			expected: "unseal",
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			if tst.err.Error() != tst.expected {
+				t.Errorf("Expected %q, got %q", tst.expected, tst.err.Error())
+			}
+			if tst.err.Err != nil && !errors.Is(tst.err, tst.err.Err) {
+				t.Errorf("Expected Error to wrap %v", tst.err.Err)
+			}
+		})
+	}
+}
+
+// Verify that EnvelopeAPICall parses an error response into an APIError that wraps the package error of the status.
+func TestEnvelopeAPICall_APIError(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name              string
+		status            int
+		requestID         string
+		body              string
+		expectedCode      int
+		expectedMessage   string
+		expectedRequestID string
+		expectedErr       error
+	}{
+		{
+			name:              "json",
+			status:            http.StatusBadRequest,
+			requestID:         "abc-123",
+			body:              `{"code": 3, "message": "invalid namespace", "details": []}`,
+			expectedCode:      3,
+			expectedMessage:   "invalid namespace",
+			expectedRequestID: "abc-123",
+			expectedErr:       f5xc.ErrUnexpectedHTTPStatus,
+		},
+		{
+			name:            "unauthorized",
+			status:          http.StatusUnauthorized,
+			body:            `{"code": 16, "message": "token expired"}`,
+			expectedCode:    16,
+			expectedMessage: "token expired",
+			expectedErr:     f5xc.ErrUnauthorized,
+		},
+		{
+			name:            "forbidden-text",
+			status:          http.StatusForbidden,
+			body:            "access denied\n",
+			expectedMessage: "access denied",
+			expectedErr:     f5xc.ErrForbidden,
+		},
+		{
+			name:        "empty",
+			status:      http.StatusBadGateway,
+			expectedErr: f5xc.ErrUnexpectedHTTPStatus,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			client, serverURL := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if tst.requestID != "" {
+					w.Header().Set(f5xc.RequestIDHeader, tst.requestID)
+				}
+				w.WriteHeader(tst.status)
+				_, _ = io.WriteString(w, tst.body)
+			}))
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, serverURL+f5xc.PublicKeyURL, nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			_, err = f5xc.EnvelopeAPICall[f5xc.PublicKey](client, req)
+			if !errors.Is(err, tst.expectedErr) {
+				t.Errorf("Expected EnvelopeAPICall to raise %v, got %v", tst.expectedErr, err)
+			}
+			var apiErr *f5xc.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Expected EnvelopeAPICall to raise an APIError, got %v", err)
+			}
+			if apiErr.Code != tst.expectedCode {
+				t.Errorf("Expected code %d, got %d", tst.expectedCode, apiErr.Code)
+			}
+			if apiErr.Message != tst.expectedMessage {
+				t.Errorf("Expected message %q, got %q", tst.expectedMessage, apiErr.Message)
+			}
+			if apiErr.RequestID != tst.expectedRequestID {
+				t.Errorf("Expected request ID %q, got %q", tst.expectedRequestID, apiErr.RequestID)
+			}
+		})
+	}
+}
+
+// Verify that StrictEnvelopeAPICall returns an error that wraps ErrNotFound for a 404 response, where EnvelopeAPICall
+// returns a nil resource and error.
+func TestStrictEnvelopeAPICall_NotFound(t *testing.T) {
+	t.Parallel()
+	client, serverURL := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"code": 5, "message": "secret_policy not found"}`)
+	}))
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, serverURL+fmt.Sprintf(f5xc.SecretPolicyDocumentURL, "shared", "missing"), nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	policyDoc, err := f5xc.EnvelopeAPICall[f5xc.SecretPolicyDocument](client, req)
+	if policyDoc != nil || err != nil {
+		t.Errorf("Expected EnvelopeAPICall to return nil, nil; got %v, %v", policyDoc, err)
+	}
+	policyDoc, err = f5xc.StrictEnvelopeAPICall[f5xc.SecretPolicyDocument](client, req)
+	if policyDoc != nil {
+		t.Errorf("Expected StrictEnvelopeAPICall to return a nil policy document, got %v", policyDoc)
+	}
+	if !errors.Is(err, f5xc.ErrNotFound) {
+		t.Errorf("Expected StrictEnvelopeAPICall to raise %v, got %v", f5xc.ErrNotFound, err)
+	}
+	var apiErr *f5xc.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Temporary {
+		t.Errorf("Expected StrictEnvelopeAPICall to raise a permanent Error with status 404, got %v", err)
+	}
+}
+
+// Verify that an APIError describes the error response and its cause.
+func TestAPIError(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		err      *f5xc.APIError
+		expected string
+	}{
+		{
+			name:     "full",
+			err:      &f5xc.APIError{Code: 3, Message: "invalid namespace", RequestID: "abc-123", Err: f5xc.ErrUnexpectedHTTPStatus},
+			expected: "invalid namespace (code 3, request ID abc-123): endpoint returned an unexpected status code",
+		},
+		{
+			name:     "message",
+			err:      &f5xc.APIError{Message: "access denied", Err: f5xc.ErrForbidden},
+			expected: "access denied: access to endpoint is denied",
+		},
+		{
+			name:     "cause-only",
+			err:      &f5xc.APIError{Err: f5xc.ErrForbidden},
+			expected: "access to endpoint is denied",
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			if tst.err.Error() != tst.expected {
+				t.Errorf("Expected %q, got %q", tst.expected, tst.err.Error())
+			}
+			if !errors.Is(tst.err, tst.err.Err) {
+				t.Errorf("Expected APIError to wrap %v", tst.err.Err)
+			}
+		})
+	}
+}
+
+// Verify that retryable errors are identified through wrapping.
+func TestIsRetryable(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "sentinel",
+			err:  f5xc.ErrUnauthorized,
+		},
+		{
+			name:     "temporary",
+			err:      fmt.Errorf("wrapped: %w", &f5xc.Error{Op: "GET", StatusCode: http.StatusServiceUnavailable, Temporary: true}),
+			expected: true,
+		},
+		{
+			name: "permanent-wrapping-transient",
+			err:  &f5xc.Error{Op: "GET", Err: syscall.ECONNRESET},
+		},
+		{
+			name:     "connection-refused",
+			err:      fmt.Errorf("dial: %w", syscall.ECONNREFUSED),
+			expected: true,
+		},
+		{
+			name:     "deadline",
+			err:      context.DeadlineExceeded,
+			expected: true,
+		},
+		{
+			name: "canceled",
+			err:  fmt.Errorf("%w: %w", context.Canceled, &f5xc.Error{Op: "GET", Temporary: true}),
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			if result := f5xc.IsRetryable(tst.err); result != tst.expected {
+				t.Errorf("Expected IsRetryable to return %t, got %t", tst.expected, result)
+			}
+		})
+	}
+}
+
+// Verify that only throttling, timeout, and gateway status codes are retryable.
+func TestRetryableStatus(t *testing.T) {
+	t.Parallel()
+	for statusCode, expected := range map[int]bool{
+		http.StatusOK:                  false,
+		http.StatusBadRequest:          false,
+		http.StatusNotFound:            false,
+		http.StatusRequestTimeout:      true,
+		http.StatusTooManyRequests:     true,
+		http.StatusInternalServerError: false,
+		http.StatusBadGateway:          true,
+		http.StatusServiceUnavailable:  true,
+		http.StatusGatewayTimeout:      true,
+	} {
+		if result := f5xc.RetryableStatus(statusCode); result != expected {
+			t.Errorf("Expected RetryableStatus(%d) to return %t, got %t", statusCode, expected, result)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package f5xc
+
+import (
+	"net/http"
+)
+
+// ConditionalEnvelopeAPICall is the same as [EnvelopeAPICall], except that the
+// request is only answered with the resource if it has changed since the version
+// identified by etag, and the ETag of the returned resource is returned with it.
+// If etag is not empty it is sent in the If-None-Match header of the request, and
+// an unchanged resource returns an [*Error] that wraps [ErrNotModified], so that a
+// poller can keep its cached copy without downloading the resource again. The
+// returned ETag is empty if the API does not identify the version of the resource.
+func ConditionalEnvelopeAPICall[T EnvelopeAllowed](client Doer, req *http.Request, etag string) (*T, string, error) {
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	envelope, header, err := apiCallWithHeader[Envelope[T]](client, req, false)
+	if envelope == nil {
+		return nil, "", err
+	}
+	return &envelope.Data, header.Get("ETag"), nil
+}
@@ -0,0 +1,89 @@
+package f5xc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/memes/f5xc"
+)
+
+// Returns a handler that serves a policy document with the ETag of the current version, and responds with 304 Not
+// Modified to requests for the current version, which are counted.
+func testETagHandler(version *atomic.Value, notModified *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current, _ := version.Load().(string)
+		etag := `"` + current + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.SecretPolicyDocument]{
+			Data: f5xc.SecretPolicyDocument{PolicyID: current},
+		})
+	})
+}
+
+// Verify that ConditionalEnvelopeAPICall returns the resource and its ETag, and an error that wraps ErrNotModified when
+// the resource has not changed.
+func TestConditionalEnvelopeAPICall(t *testing.T) {
+	t.Parallel()
+	var version atomic.Value
+	var notModified atomic.Int32
+	version.Store("policy-1")
+	client, serverURL := testAPIClient(t, testETagHandler(&version, &notModified))
+	tests := []struct {
+		name             string
+		version          string
+		etag             string
+		expectedPolicyID string
+		expectedETag     string
+		expectedErr      error
+	}{
+		{
+			name:             "unconditional",
+			version:          "policy-1",
+			expectedPolicyID: "policy-1",
+			expectedETag:     `"policy-1"`,
+		},
+		{
+			name:        "not-modified",
+			version:     "policy-1",
+			etag:        `"policy-1"`,
+			expectedErr: f5xc.ErrNotModified,
+		},
+		{
+			name:             "modified",
+			version:          "policy-2",
+			etag:             `"policy-1"`,
+			expectedPolicyID: "policy-2",
+			expectedETag:     `"policy-2"`,
+		},
+	}
+	for _, tst := range tests {
+		version.Store(tst.version)
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, serverURL+"/api/secret_management/namespaces/shared/secret_policys/test/get_policy_document", nil)
+		if err != nil {
+			t.Fatalf("%s: failed to create request: %v", tst.name, err)
+		}
+		policyDoc, etag, err := f5xc.ConditionalEnvelopeAPICall[f5xc.SecretPolicyDocument](client, req, tst.etag)
+		switch {
+		case tst.expectedErr != nil:
+			if !errors.Is(err, tst.expectedErr) || policyDoc != nil {
+				t.Errorf("%s: expected ConditionalEnvelopeAPICall to raise %v, got %+v, %v", tst.name, tst.expectedErr, policyDoc, err)
+			}
+		case err != nil:
+			t.Errorf("%s: ConditionalEnvelopeAPICall raised an unexpected error: %v", tst.name, err)
+		case policyDoc == nil || policyDoc.PolicyID != tst.expectedPolicyID || etag != tst.expectedETag:
+			t.Errorf("%s: expected policy %s with ETag %s, got %+v with ETag %s", tst.name, tst.expectedPolicyID, tst.expectedETag, policyDoc, etag)
+		}
+	}
+	if count := notModified.Load(); count != 1 {
+		t.Errorf("Expected 1 not modified response, got %d", count)
+	}
+}
@@ -0,0 +1,66 @@
+package f5xc
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+)
+
+// CertificateExpiryFunc is called with the expiry of a client certificate that is within the warning period set by
+// [WithCertificateExpiryWarning], or that has expired.
+type CertificateExpiryFunc func(notAfter time.Time)
+
+// Warn when the client certificate expires within the period, e.g. 30 days, when the client is created and whenever
+// the certificate of a [Client] is replaced. The warning is logged, and the optional callback is called with the expiry
+// of the certificate so that it can be reported elsewhere, e.g. in a metric or an alert. Certificates that have
+// already expired are always logged.
+func WithCertificateExpiryWarning(within time.Duration, callback CertificateExpiryFunc) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding certificate expiry warning", "within", within)
+		if within <= 0 {
+			return fmt.Errorf("certificate expiry warning period must be positive: %w", ErrInvalidOption)
+		}
+		c.expiryWarning = within
+		c.expiryCallback = callback
+		return nil
+	}
+}
+
+// CredentialExpiry returns the time when the client certificate expires, or zero if the client authenticates with an
+// API token.
+func (c *Client) CredentialExpiry() time.Time {
+	return certificateExpiry(c.credentials.certificate())
+}
+
+// Returns the expiry of the certificate, or zero if it is nil or cannot be parsed.
+func certificateExpiry(cert *tls.Certificate) time.Time {
+	if cert == nil {
+		return time.Time{}
+	}
+	leaf, err := certificateLeaf(cert)
+	if err != nil {
+		return time.Time{}
+	}
+	return leaf.NotAfter
+}
+
+// Logs a warning and calls the callback of the store if the certificate has expired, or expires within the warning
+// period of the store.
+func (s *credentialStore) checkExpiry(cert *tls.Certificate) {
+	notAfter := certificateExpiry(cert)
+	if notAfter.IsZero() {
+		return
+	}
+	remaining := time.Until(notAfter)
+	switch {
+	case remaining <= 0:
+		s.logger.Warn("Client certificate has expired", "notAfter", notAfter)
+	case remaining <= s.expiryWarning:
+		s.logger.Warn("Client certificate expires soon", "notAfter", notAfter, "remaining", remaining.Round(time.Minute))
+	default:
+		return
+	}
+	if s.expiryCallback != nil {
+		s.expiryCallback(notAfter)
+	}
+}
@@ -0,0 +1,125 @@
+package f5xc_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+)
+
+// Returns a new self-signed client certificate and key in PEM format that expire at notAfter.
+func testCertKeyPEM(t *testing.T, notAfter time.Time) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+// Verify that the expiry of a client certificate is reported, and that the warning callback is called for certificates
+// that expire within the warning period.
+func TestWithCertificateExpiryWarning(t *testing.T) {
+	t.Parallel()
+	soon := time.Now().Add(10 * 24 * time.Hour).Truncate(time.Second)
+	later := time.Now().Add(90 * 24 * time.Hour).Truncate(time.Second)
+	expired := time.Now().Add(-time.Hour).Truncate(time.Second)
+	tests := []struct {
+		name     string
+		notAfter time.Time
+		warned   bool
+	}{
+		{
+			name:     "soon",
+			notAfter: soon,
+			warned:   true,
+		},
+		{
+			name:     "later",
+			notAfter: later,
+		},
+		{
+			name:     "expired",
+			notAfter: expired,
+			warned:   true,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			var warnings []time.Time
+			certPEM, keyPEM := testCertKeyPEM(t, tst.notAfter)
+			client, err := f5xc.NewAPIClient(
+				f5xc.WithAPIEndpoint("https://f5xc.invalid/api"),
+				f5xc.WithCertKeyPEM(certPEM, keyPEM),
+				f5xc.WithCertificateExpiryWarning(30*24*time.Hour, func(notAfter time.Time) { warnings = append(warnings, notAfter) }),
+			)
+			if err != nil {
+				t.Fatalf("NewAPIClient raised an unexpected error: %v", err)
+			}
+			t.Cleanup(client.CloseIdleConnections)
+			if expiry := client.CredentialExpiry(); !expiry.Equal(tst.notAfter) {
+				t.Errorf("Expected credential expiry %v, got %v", tst.notAfter, expiry)
+			}
+			if warned := len(warnings) == 1 && warnings[0].Equal(tst.notAfter); warned != tst.warned {
+				t.Errorf("Expected warning to be %t, got %v", tst.warned, warnings)
+			}
+
+			// Replacing the certificate checks the expiry of the new certificate
+			warnings = nil
+			certPEM, keyPEM = testCertKeyPEM(t, soon)
+			cert, err := tls.X509KeyPair(certPEM, keyPEM)
+			if err != nil {
+				t.Fatalf("Failed to load certificate: %v", err)
+			}
+			if err := client.SetCertificate(cert); err != nil {
+				t.Fatalf("SetCertificate raised an unexpected error: %v", err)
+			}
+			if len(warnings) != 1 || !warnings[0].Equal(soon) || !client.CredentialExpiry().Equal(soon) {
+				t.Errorf("Expected replaced certificate to warn of expiry at %v, got %v", soon, warnings)
+			}
+		})
+	}
+}
+
+// Verify that a client that authenticates with a token has no credential expiry, and that an invalid warning period is
+// rejected.
+func TestWithCertificateExpiryWarning_Token(t *testing.T) {
+	t.Parallel()
+	client, err := f5xc.NewAPIClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"))
+	if err != nil {
+		t.Fatalf("NewAPIClient raised an unexpected error: %v", err)
+	}
+	t.Cleanup(client.CloseIdleConnections)
+	if expiry := client.CredentialExpiry(); !expiry.IsZero() {
+		t.Errorf("Expected no credential expiry, got %v", expiry)
+	}
+	_, err = f5xc.NewAPIClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"), f5xc.WithCertificateExpiryWarning(0, nil))
+	if !errors.Is(err, f5xc.ErrInvalidOption) {
+		t.Errorf("Expected NewAPIClient to raise %v, got %v", f5xc.ErrInvalidOption, err)
+	}
+}
@@ -0,0 +1,46 @@
+package f5xcsecrets
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/memes/f5xc"
+)
+
+// The environment variables used by vesctl to configure the F5 Distributed Cloud API client.
+const (
+	EnvAPIURL      = "VOLT_API_URL"
+	EnvAPIToken    = "VOLTERRA_TOKEN"
+	EnvP12Bundle   = "VOLT_API_P12_FILE"
+	EnvP12Password = "VES_P12_PASSWORD"
+	EnvCert        = "VOLT_API_CERT"
+	EnvKey         = "VOLT_API_KEY"
+	EnvCACert      = "VOLT_API_CA_CERT"
+)
+
+// Returns an F5 Distributed Cloud API client configured from the vesctl environment variables. If more than one form
+// of authentication is configured the preferred form is used; see [f5xc.WithCredentials].
+func clientFromEnv(getenv func(string) string) (*http.Client, error) {
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	options := []f5xc.Option{
+		f5xc.WithAPIEndpoint(getenv(EnvAPIURL)),
+	}
+	if caCert := getenv(EnvCACert); caCert != "" {
+		options = append(options, f5xc.WithCACert(caCert))
+	}
+	options = append(options, f5xc.WithCredentials(f5xc.Credentials{
+		APIToken:      getenv(EnvAPIToken),
+		P12Bundle:     getenv(EnvP12Bundle),
+		P12Passphrase: getenv(EnvP12Password),
+		Cert:          getenv(EnvCert),
+		Key:           getenv(EnvKey),
+	}))
+	client, err := f5xc.NewClient(options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API client from environment: %w", err)
+	}
+	return client, nil
+}
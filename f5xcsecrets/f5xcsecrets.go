@@ -0,0 +1,307 @@
+// Package f5xcsecrets provides a [gocloud.dev/secrets] driver that encrypts by sealing plaintext with blindfold, and
+// decrypts by having Wingman unseal the ciphertext. Use [NewKeeper] to construct a *secrets.Keeper, or open one from
+// a URL with the f5xc scheme; see [URLOpener] for the URL format.
+//
+// Encryption is performed offline by vesctl with the tenant public key and a secret policy document, which can be
+// retrieved from the F5 Distributed Cloud API or read from a cache directory written by f5xc key pull. Blindfold
+// ciphertext can only be decrypted by Wingman, within a workload that satisfies the secret policy; there is no local
+// decryption. A Keeper opened without a secret policy can decrypt but not encrypt.
+//
+// # As
+//
+// f5xcsecrets does not support any types for As.
+package f5xcsecrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/blindfold"
+	"github.com/memes/f5xc/wingman"
+	"gocloud.dev/gcerrors"
+	"gocloud.dev/secrets"
+)
+
+//nolint:gochecknoinits // Registering URL openers with the default mux is the gocloud.dev driver convention
+func init() {
+	secrets.DefaultURLMux().RegisterKeeper(Scheme, &URLOpener{})
+}
+
+const (
+	// Scheme is the URL scheme f5xcsecrets registers its URLOpener under on secrets.DefaultURLMux.
+	Scheme = "f5xc"
+	// The namespace of the secret policy when a URL does not include one.
+	DefaultNamespace = "shared"
+)
+
+var (
+	// ErrInvalidURL is returned when a keeper URL cannot be used to open a Keeper.
+	ErrInvalidURL = errors.New("invalid f5xc keeper URL")
+	// ErrNoSecretPolicy is returned by Encrypt when the Keeper was opened without a secret policy.
+	ErrNoSecretPolicy = errors.New("keeper does not have a secret policy and cannot encrypt")
+	// ErrNotFound is returned by Encrypt when the API does not have the public key or secret policy document.
+	ErrNotFound = errors.New("public key or secret policy document not found")
+)
+
+// KeeperOptions sets options for a Keeper.
+type KeeperOptions struct {
+	// The name or path of the vesctl executable used to seal plaintext; the default is [blindfold.VesctlExecutable].
+	Vesctl string
+	// The Wingman endpoint used to unseal ciphertext; the default is [wingman.DefaultWingmanURL].
+	WingmanURL string
+	// Options to use when creating the Wingman client.
+	WingmanOptions []wingman.Option
+}
+
+// ParamsFunc returns the public key and secret policy document to use when sealing plaintext.
+type ParamsFunc func(ctx context.Context) (*f5xc.PublicKey, *f5xc.SecretPolicyDocument, error)
+
+// NewKeeper returns a *secrets.Keeper that seals plaintext with the public key and secret policy document, and unseals
+// ciphertext through Wingman. The public key and policy document may both be nil to create a Keeper that can only
+// decrypt.
+func NewKeeper(pubKey *f5xc.PublicKey, policyDoc *f5xc.SecretPolicyDocument, opts *KeeperOptions) (*secrets.Keeper, error) {
+	var params ParamsFunc
+	switch {
+	case pubKey != nil && policyDoc != nil:
+		params = func(context.Context) (*f5xc.PublicKey, *f5xc.SecretPolicyDocument, error) {
+			return pubKey, policyDoc, nil
+		}
+	case pubKey != nil || policyDoc != nil:
+		return nil, fmt.Errorf("public key and secret policy document must be provided together: %w", ErrNoSecretPolicy)
+	}
+	return NewKeeperWithParams(params, opts)
+}
+
+// NewKeeperWithParams returns a *secrets.Keeper that calls params the first time plaintext is encrypted, retrying on
+// later calls until it succeeds, and seals with the returned public key and secret policy document. Ciphertext is
+// unsealed through Wingman. If params is nil the Keeper can only decrypt.
+func NewKeeperWithParams(params ParamsFunc, opts *KeeperOptions) (*secrets.Keeper, error) {
+	if opts == nil {
+		opts = &KeeperOptions{}
+	}
+	wingmanURL := opts.WingmanURL
+	if wingmanURL == "" {
+		wingmanURL = wingman.DefaultWingmanURL
+	}
+	client, err := wingman.NewClient(wingmanURL, opts.WingmanOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wingman client: %w", err)
+	}
+	vesctl := opts.Vesctl
+	if vesctl == "" {
+		vesctl = blindfold.VesctlExecutable
+	}
+	return secrets.NewKeeper(&keeper{
+		vesctl:  vesctl,
+		wingman: client,
+		params:  params,
+	}), nil
+}
+
+// Implements driver.Keeper.
+type keeper struct {
+	vesctl    string
+	wingman   wingman.Client
+	params    ParamsFunc
+	mu        sync.Mutex
+	pubKey    *f5xc.PublicKey
+	policyDoc *f5xc.SecretPolicyDocument
+}
+
+// Returns the sealing parameters, calling the params function if they have not been retrieved yet.
+func (k *keeper) sealingParams(ctx context.Context) (*f5xc.PublicKey, *f5xc.SecretPolicyDocument, error) {
+	if k.params == nil {
+		return nil, nil, ErrNoSecretPolicy
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.pubKey == nil {
+		pubKey, policyDoc, err := k.params(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		k.pubKey, k.policyDoc = pubKey, policyDoc
+	}
+	return k.pubKey, k.policyDoc, nil
+}
+
+// Encrypt seals the plaintext, returning base64 encoded blindfold data.
+func (k *keeper) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	pubKey, policyDoc, err := k.sealingParams(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := blindfold.Seal(ctx, k.vesctl, plaintext, pubKey, policyDoc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal plaintext: %w", err)
+	}
+	return bytes.TrimSpace(sealed), nil
+}
+
+// Decrypt has Wingman unseal the base64 encoded blindfold ciphertext.
+func (k *keeper) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	plaintext, err := k.wingman.UnsealEncoded(ctx, bytes.TrimSpace(ciphertext))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unseal ciphertext: %w", err)
+	}
+	return plaintext, nil
+}
+
+// Close releases the Wingman client.
+func (k *keeper) Close() error {
+	return k.wingman.Close() //nolint:wrapcheck // Error is returned as-is
+}
+
+// ErrorAs implements driver.Keeper.ErrorAs; no types are supported.
+func (k *keeper) ErrorAs(error, any) bool {
+	return false
+}
+
+// ErrorCode implements driver.Keeper.ErrorCode.
+func (k *keeper) ErrorCode(err error) gcerrors.ErrorCode {
+	switch {
+	case errors.Is(err, wingman.ErrDeniedByPolicy), errors.Is(err, f5xc.ErrForbidden), errors.Is(err, f5xc.ErrUnauthorized):
+		return gcerrors.PermissionDenied
+	case errors.Is(err, ErrNoSecretPolicy), errors.Is(err, wingman.ErrNotReady):
+		return gcerrors.FailedPrecondition
+	case errors.Is(err, ErrNotFound), errors.Is(err, blindfold.ErrNotCached):
+		return gcerrors.NotFound
+	case errors.Is(err, context.Canceled):
+		return gcerrors.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return gcerrors.DeadlineExceeded
+	}
+	return gcerrors.Unknown
+}
+
+// URLOpener opens f5xc URLs like "f5xc://NAMESPACE/POLICY". The secret policy is used for encryption, and the
+// namespace defaults to shared if the URL host is empty, e.g. "f5xc:///POLICY". The URL "f5xc://" opens a Keeper that
+// can only decrypt.
+//
+// The following query parameters are supported:
+//
+//   - key_version: the version of the public key to seal with; the current version is used if not set
+//   - cache_dir: read the public key and policy document from this directory, written by f5xc key pull, instead of
+//     calling the API
+//   - vesctl: the name or path of the vesctl executable
+//   - wingman_url: the Wingman endpoint used to decrypt
+//
+// Unless cache_dir is set, the public key and policy document are retrieved from the API when the Keeper first
+// encrypts. The API client is Client if set, or is created from the environment variables used by vesctl;
+// VOLT_API_URL, VOLTERRA_TOKEN, VOLT_API_P12_FILE with the passphrase in VES_P12_PASSWORD, VOLT_API_CERT,
+// VOLT_API_KEY, and VOLT_API_CA_CERT.
+type URLOpener struct {
+	// The F5 Distributed Cloud API client used to retrieve sealing parameters.
+	Client *http.Client
+	// Options specifies the default options to pass to NewKeeperWithParams; query parameters take precedence.
+	Options KeeperOptions
+	// Returns the value of an environment variable; the default is os.Getenv.
+	Getenv func(string) string
+}
+
+// OpenKeeperURL opens a Keeper based on u.
+func (o *URLOpener) OpenKeeperURL(_ context.Context, u *url.URL) (*secrets.Keeper, error) {
+	opts := o.Options
+	query := u.Query()
+	keyVersion := 0
+	var cacheDir string
+	for param, values := range query {
+		value := values[0]
+		switch param {
+		case "key_version":
+			var err error
+			if keyVersion, err = strconv.Atoi(value); err != nil || keyVersion < 0 {
+				return nil, fmt.Errorf("open keeper %v: key_version must be a non-negative integer: %w", u, ErrInvalidURL)
+			}
+		case "cache_dir":
+			cacheDir = value
+		case "vesctl":
+			opts.Vesctl = value
+		case "wingman_url":
+			opts.WingmanURL = value
+		default:
+			return nil, fmt.Errorf("open keeper %v: invalid query parameter %q: %w", u, param, ErrInvalidURL)
+		}
+	}
+	policy := strings.TrimPrefix(u.Path, "/")
+	namespace := u.Host
+	switch {
+	case policy == "" && namespace != "":
+		return nil, fmt.Errorf("open keeper %v: a secret policy name must follow the namespace: %w", u, ErrInvalidURL)
+	case strings.Contains(policy, "/"):
+		return nil, fmt.Errorf("open keeper %v: the secret policy name must not contain /: %w", u, ErrInvalidURL)
+	case namespace == "":
+		namespace = DefaultNamespace
+	}
+	var params ParamsFunc
+	switch {
+	case policy == "":
+		slog.Debug("Opening decrypt only keeper", "url", u.String())
+	case cacheDir != "":
+		params = cachedParams(cacheDir, keyVersion, namespace, policy)
+	default:
+		params = o.apiParams(keyVersion, namespace, policy)
+	}
+	keeper, err := NewKeeperWithParams(params, &opts)
+	if err != nil {
+		return nil, fmt.Errorf("open keeper %v: %w", u, err)
+	}
+	return keeper, nil
+}
+
+// Returns a ParamsFunc that reads the public key and policy document from the cache directory.
+func cachedParams(dir string, keyVersion int, namespace, policy string) ParamsFunc {
+	return func(context.Context) (*f5xc.PublicKey, *f5xc.SecretPolicyDocument, error) {
+		pubKey, err := blindfold.ReadCachedPublicKey(dir, keyVersion)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read public key from cache: %w", err)
+		}
+		policyDoc, err := blindfold.ReadCachedPolicyDocument(dir, namespace, policy)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read secret policy document from cache: %w", err)
+		}
+		return pubKey, policyDoc, nil
+	}
+}
+
+// Returns a ParamsFunc that retrieves the public key and policy document from the F5 Distributed Cloud API.
+func (o *URLOpener) apiParams(keyVersion int, namespace, policy string) ParamsFunc {
+	return func(ctx context.Context) (*f5xc.PublicKey, *f5xc.SecretPolicyDocument, error) {
+		client := o.Client
+		if client == nil {
+			var err error
+			if client, err = clientFromEnv(o.Getenv); err != nil {
+				return nil, nil, err
+			}
+			defer client.CloseIdleConnections()
+		}
+		var version *int
+		if keyVersion > 0 {
+			version = &keyVersion
+		}
+		pubKey, err := f5xc.GetPublicKey(ctx, client, version)
+		switch {
+		case err != nil:
+			return nil, nil, fmt.Errorf("failed to retrieve public key: %w", err)
+		case pubKey == nil:
+			return nil, nil, fmt.Errorf("public key version %d: %w", keyVersion, ErrNotFound)
+		}
+		policyDoc, err := f5xc.GetSecretPolicyDocument(ctx, client, policy, namespace)
+		switch {
+		case err != nil:
+			return nil, nil, fmt.Errorf("failed to retrieve secret policy document: %w", err)
+		case policyDoc == nil:
+			return nil, nil, fmt.Errorf("secret policy %s/%s: %w", namespace, policy, ErrNotFound)
+		}
+		return pubKey, policyDoc, nil
+	}
+}
@@ -0,0 +1,125 @@
+package f5xcsecrets_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/f5xcsecrets"
+	"github.com/memes/f5xc/f5xctest"
+	"go.uber.org/goleak"
+	"gocloud.dev/gcerrors"
+	"gocloud.dev/secrets"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m, f5xctest.IgnoreOpenCensus())
+}
+
+// Verify that keepers can be opened from URLs, and that they decrypt through wingman.
+func TestOpenKeeper(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(f5xctest.ROT13WingmanHandler(t))
+	t.Cleanup(server.Close)
+	wingmanURL := url.QueryEscape(server.URL)
+	cacheDir := url.QueryEscape(t.TempDir())
+	tests := []struct {
+		name                string
+		url                 string
+		expectedOpenError   error
+		expectedEncryptCode gcerrors.ErrorCode
+	}{
+		{
+			name:                "decrypt-only",
+			url:                 "f5xc://?wingman_url=" + wingmanURL,
+			expectedEncryptCode: gcerrors.FailedPrecondition,
+		},
+		{
+			name:                "empty-cache",
+			url:                 "f5xc://shared/test?key_version=2&cache_dir=" + cacheDir + "&wingman_url=" + wingmanURL,
+			expectedEncryptCode: gcerrors.NotFound,
+		},
+		{
+			name:                "default-namespace",
+			url:                 "f5xc:///test?cache_dir=" + cacheDir + "&wingman_url=" + wingmanURL,
+			expectedEncryptCode: gcerrors.NotFound,
+		},
+		{
+			name:              "missing-policy",
+			url:               "f5xc://shared",
+			expectedOpenError: f5xcsecrets.ErrInvalidURL,
+		},
+		{
+			name:              "nested-policy",
+			url:               "f5xc://shared/a/b",
+			expectedOpenError: f5xcsecrets.ErrInvalidURL,
+		},
+		{
+			name:              "invalid-key-version",
+			url:               "f5xc://shared/test?key_version=-1",
+			expectedOpenError: f5xcsecrets.ErrInvalidURL,
+		},
+		{
+			name:              "unknown-parameter",
+			url:               "f5xc://shared/test?region=us",
+			expectedOpenError: f5xcsecrets.ErrInvalidURL,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			keeper, err := secrets.OpenKeeper(ctx, tst.url)
+			switch {
+			case tst.expectedOpenError == nil && err != nil:
+				t.Fatalf("OpenKeeper raised an unexpected error: %v", err)
+			case tst.expectedOpenError != nil && !errors.Is(err, tst.expectedOpenError):
+				t.Fatalf("Expected OpenKeeper to raise %v, got %v", tst.expectedOpenError, err)
+			case err != nil:
+				return
+			}
+			t.Cleanup(func() {
+				_ = keeper.Close()
+			})
+			// spell-checker: disable-next-line
+			plaintext, err := keeper.Decrypt(ctx, []byte("ZnZ6Y3lyLndmYmE=\n"))
+			if err != nil {
+				t.Fatalf("Decrypt raised an unexpected error: %v", err)
+			}
+			if string(plaintext) != "simple.json" {
+				t.Errorf("Expected plaintext simple.json, got %q", string(plaintext))
+			}
+			if _, err := keeper.Encrypt(ctx, []byte("test")); gcerrors.Code(err) != tst.expectedEncryptCode {
+				t.Errorf("Expected Encrypt to fail with %v, got %v: %v", tst.expectedEncryptCode, gcerrors.Code(err), err)
+			}
+		})
+	}
+}
+
+// Verify that NewKeeper requires both sealing parameters or neither.
+func TestNewKeeper(t *testing.T) {
+	t.Parallel()
+	if _, err := f5xcsecrets.NewKeeper(&f5xc.PublicKey{}, nil, nil); !errors.Is(err, f5xcsecrets.ErrNoSecretPolicy) {
+		t.Errorf("Expected NewKeeper to raise %v, got %v", f5xcsecrets.ErrNoSecretPolicy, err)
+	}
+	keeper, err := f5xcsecrets.NewKeeper(nil, nil, &f5xcsecrets.KeeperOptions{WingmanURL: "ftp://localhost"})
+	if err == nil {
+		_ = keeper.Close()
+		t.Error("Expected NewKeeper to raise an error for an unsupported wingman URL")
+	}
+	keeper, err = f5xcsecrets.NewKeeper(&f5xc.PublicKey{}, &f5xc.SecretPolicyDocument{}, &f5xcsecrets.KeeperOptions{Vesctl: "/nonexistent/vesctl"})
+	if err != nil {
+		t.Fatalf("NewKeeper raised an unexpected error: %v", err)
+	}
+	defer keeper.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := keeper.Encrypt(ctx, []byte("test")); err == nil {
+		t.Error("Expected Encrypt to fail without vesctl")
+	}
+}
@@ -0,0 +1,292 @@
+// Package f5xctest provides a fake F5 Distributed Cloud API and a fake Wingman, backed by the same generated RSA key
+// pair, so that code which seals data with a public key and secret policy from the API, stores it, and unseals it with
+// Wingman can be tested offline.
+//
+// The fakes do not implement blindfold; data must be sealed with [Seal], which has the same signature as
+// [blindfold.Seal] without the vesctl executable, and can only be unsealed by the fake Wingman of the [Server] that
+// published the public key.
+//
+//	server := f5xctest.NewServer(t)
+//	client := server.Client(t)
+//	pubKey, _ := f5xc.GetPublicKey(ctx, client, nil)
+//	policyDoc, _ := f5xc.GetSecretPolicyDocument(ctx, client, f5xctest.DefaultPolicy, f5xctest.DefaultNamespace)
+//	sealed, _ := f5xctest.Seal(ctx, []byte("secret"), pubKey, policyDoc)
+//	unsealed, _ := wingman.UnsealEncoded(ctx, http.DefaultClient, server.WingmanURL()+wingman.UnsealEndpoint, sealed)
+package f5xctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/wingman"
+)
+
+const (
+	// The tenant that owns the public key and secret policies of a Server, unless changed with [WithTenant].
+	DefaultTenant = "test"
+	// The API token accepted by a Server, unless changed with [WithAuthToken].
+	DefaultAuthToken = "test-token"
+	// The namespace of the default secret policy of a Server.
+	DefaultNamespace = "shared"
+	// The name of the default secret policy of a Server.
+	DefaultPolicy = "test"
+	// The identifier of the default secret policy of a Server.
+	DefaultPolicyID = "test-policy"
+	// The version of the public key of a Server, unless changed with [WithKeyVersion].
+	DefaultKeyVersion = 1
+	// The size of the generated RSA key.
+	keyBits = 2048
+	// The prefix of the location of sealed data in a Wingman unseal request.
+	locationPrefix = "string:///"
+)
+
+// ErrInvalidOption is returned when an option value is not acceptable; it is the same error as [f5xc.ErrInvalidOption].
+var ErrInvalidOption = f5xc.ErrInvalidOption
+
+// Defines the configuration of a Server.
+type config struct {
+	tenant      string
+	token       string
+	keyVersion  int
+	policyDocs  []*f5xc.SecretPolicyDocument
+	defaultDocs bool
+}
+
+// Defines a configuration setting function for NewServer.
+type Option func(*config) error
+
+// Set the tenant that owns the public key and the default secret policy; the default is [DefaultTenant].
+func WithTenant(tenant string) Option {
+	return func(c *config) error {
+		if tenant == "" {
+			return fmt.Errorf("tenant must not be empty: %w", ErrInvalidOption)
+		}
+		c.tenant = tenant
+		return nil
+	}
+}
+
+// Set the API token that must be presented to the fake API; the default is [DefaultAuthToken].
+func WithAuthToken(token string) Option {
+	return func(c *config) error {
+		if token == "" {
+			return fmt.Errorf("auth token must not be empty: %w", ErrInvalidOption)
+		}
+		c.token = token
+		return nil
+	}
+}
+
+// Set the version of the public key; the default is [DefaultKeyVersion].
+func WithKeyVersion(version int) Option {
+	return func(c *config) error {
+		if version < 1 {
+			return fmt.Errorf("key version must be positive: %w", ErrInvalidOption)
+		}
+		c.keyVersion = version
+		return nil
+	}
+}
+
+// Serve the secret policy document, which must have a name, namespace, and policy ID, instead of the default policy.
+// The option can be repeated to serve more than one policy. Data sealed with a policy that is not served by the Server
+// is denied by the fake Wingman.
+func WithPolicyDocument(policyDoc *f5xc.SecretPolicyDocument) Option {
+	return func(c *config) error {
+		if policyDoc == nil || policyDoc.Metadata == nil || policyDoc.Name == "" || policyDoc.Namespace == "" || policyDoc.PolicyID == "" {
+			return fmt.Errorf("policy document must have a name, namespace, and policy ID: %w", ErrInvalidOption)
+		}
+		c.policyDocs = append(c.policyDocs, policyDoc)
+		c.defaultDocs = false
+		return nil
+	}
+}
+
+// Server is a fake F5 Distributed Cloud API and Wingman that share a generated RSA key pair. The API serves the public
+// key and secret policy documents to clients that present the API token, and Wingman unseals data that was sealed by
+// [Seal] with the public key and one of the policies.
+type Server struct {
+	// The fake F5 Distributed Cloud API, which uses TLS.
+	API *httptest.Server
+	// The fake Wingman, which does not use TLS.
+	Wingman *httptest.Server
+	// The tenant that owns the public key.
+	Tenant string
+	// The API token accepted by the fake API.
+	AuthToken string
+	// The version of the public key.
+	KeyVersion int
+	key        *rsa.PrivateKey
+	policyDocs []*f5xc.SecretPolicyDocument
+}
+
+// NewServer generates an RSA key pair and starts a fake API and Wingman that use it, which will be closed when the test
+// completes. The test fails if an option is invalid or the key cannot be generated.
+func NewServer(tb testing.TB, options ...Option) *Server {
+	tb.Helper()
+	cfg := &config{
+		tenant:      DefaultTenant,
+		token:       DefaultAuthToken,
+		keyVersion:  DefaultKeyVersion,
+		defaultDocs: true,
+	}
+	for _, option := range options {
+		if err := option(cfg); err != nil {
+			tb.Fatalf("f5xctest: %v", err)
+		}
+	}
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		tb.Fatalf("f5xctest: failed to generate RSA key: %v", err)
+	}
+	policyDocs := cfg.policyDocs
+	if cfg.defaultDocs {
+		policyDocs = []*f5xc.SecretPolicyDocument{
+			{
+				Metadata: &f5xc.Metadata{Name: DefaultPolicy, Namespace: DefaultNamespace, Tenant: cfg.tenant},
+				PolicyID: DefaultPolicyID,
+				PolicyInfo: f5xc.SecretPolicyInfo{
+					Algo:  "FIRST_MATCH",
+					Rules: []f5xc.SecretPolicyRule{{Action: "ALLOW", ClientNameMatcher: &f5xc.MatcherType{RegexValues: []string{".*"}}}},
+				},
+			},
+		}
+	}
+	s := &Server{
+		Tenant:     cfg.tenant,
+		AuthToken:  cfg.token,
+		KeyVersion: cfg.keyVersion,
+		key:        key,
+		policyDocs: policyDocs,
+	}
+	s.API = httptest.NewTLSServer(s.apiHandler())
+	tb.Cleanup(s.API.Close)
+	s.Wingman = httptest.NewServer(s.wingmanHandler())
+	tb.Cleanup(s.Wingman.Close)
+	return s
+}
+
+// APIEndpoint returns the URL of the fake API, for use with [f5xc.WithAPIEndpoint].
+func (s *Server) APIEndpoint() string {
+	return s.API.URL + "/api"
+}
+
+// WingmanURL returns the base URL of the fake Wingman, for use with [wingman.NewClient].
+func (s *Server) WingmanURL() string {
+	return s.Wingman.URL
+}
+
+// Client returns a client from [f5xc.NewClient] that trusts the fake API and authenticates with its API token. The
+// CA certificate of the fake API is written to a temporary directory of the test.
+func (s *Server) Client(tb testing.TB) *http.Client {
+	tb.Helper()
+	caCert := filepath.Join(tb.TempDir(), "ca.pem")
+	if err := os.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.API.Certificate().Raw}), 0o600); err != nil {
+		tb.Fatalf("f5xctest: failed to write CA certificate: %v", err)
+	}
+	client, err := f5xc.NewClient(
+		f5xc.WithAPIEndpoint(s.APIEndpoint()),
+		f5xc.WithCACert(caCert),
+		f5xc.WithAuthToken(s.AuthToken),
+	)
+	if err != nil {
+		tb.Fatalf("f5xctest: failed to create client: %v", err)
+	}
+	tb.Cleanup(client.CloseIdleConnections)
+	return client
+}
+
+// PublicKey returns the public key served by the fake API.
+func (s *Server) PublicKey() *f5xc.PublicKey {
+	return &f5xc.PublicKey{
+		KeyVersion:           s.KeyVersion,
+		ModulusBase64:        base64.StdEncoding.EncodeToString(s.key.N.Bytes()),
+		PublicExponentBase64: base64.StdEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		Tenant:               s.Tenant,
+	}
+}
+
+// PolicyDocument returns the named secret policy document served by the fake API, or nil if it is not served.
+func (s *Server) PolicyDocument(namespace, name string) *f5xc.SecretPolicyDocument {
+	for _, policyDoc := range s.policyDocs {
+		if policyDoc.Namespace == namespace && policyDoc.Name == name {
+			return policyDoc
+		}
+	}
+	return nil
+}
+
+// Returns the handler of the fake API.
+func (s *Server) apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+f5xc.PublicKeyURL, func(w http.ResponseWriter, r *http.Request) {
+		if query := r.URL.Query().Get("key_version"); query != "" && query != strconv.Itoa(s.KeyVersion) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeEnvelope(w, s.PublicKey())
+	})
+	mux.HandleFunc("GET "+fmt.Sprintf(f5xc.SecretPolicyDocumentURL, "{namespace}", "{name}"), func(w http.ResponseWriter, r *http.Request) {
+		policyDoc := s.PolicyDocument(r.PathValue("namespace"), r.PathValue("name"))
+		if policyDoc == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeEnvelope(w, policyDoc)
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "APIToken "+s.AuthToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// Writes the data as a JSON API envelope.
+func writeEnvelope[T f5xc.EnvelopeAllowed](w http.ResponseWriter, data *T) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(f5xc.Envelope[T]{Data: *data})
+}
+
+// Returns the handler of the fake Wingman.
+func (s *Server) wingmanHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+wingman.StatusEndpoint, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("READY"))
+	})
+	mux.HandleFunc("POST "+wingman.UnsealEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Type     string `json:"type"`
+			Location string `json:"location"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Type != "blindfold" || !strings.HasPrefix(payload.Location, locationPrefix) {
+			http.Error(w, "invalid unseal request", http.StatusBadRequest)
+			return
+		}
+		plaintext, err := s.open(strings.TrimPrefix(payload.Location, locationPrefix))
+		switch {
+		case errors.Is(err, errPolicyDenied):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			_, _ = w.Write([]byte(base64.StdEncoding.EncodeToString(plaintext)))
+		}
+	})
+	return mux
+}
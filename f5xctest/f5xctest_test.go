@@ -0,0 +1,161 @@
+package f5xctest_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/f5xctest"
+	"github.com/memes/f5xc/wingman"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+// Verify that data sealed with the public key and policy retrieved from the fake API can be unsealed by the fake
+// Wingman, through both the REST functions and a Client.
+func TestServer_RoundTrip(t *testing.T) {
+	t.Parallel()
+	server := f5xctest.NewServer(t, f5xctest.WithKeyVersion(3))
+	client := server.Client(t)
+	ctx := context.Background()
+	pubKey, err := f5xc.GetPublicKey(ctx, client, nil)
+	if err != nil {
+		t.Fatalf("GetPublicKey raised an unexpected error: %v", err)
+	}
+	if pubKey == nil || *pubKey != *server.PublicKey() {
+		t.Fatalf("Expected public key %+v, got %+v", server.PublicKey(), pubKey)
+	}
+	policyDoc, err := f5xc.GetSecretPolicyDocument(ctx, client, f5xctest.DefaultPolicy, f5xctest.DefaultNamespace)
+	if err != nil {
+		t.Fatalf("GetSecretPolicyDocument raised an unexpected error: %v", err)
+	}
+	if policyDoc == nil || policyDoc.PolicyID != f5xctest.DefaultPolicyID {
+		t.Fatalf("Expected policy document %s, got %+v", f5xctest.DefaultPolicyID, policyDoc)
+	}
+	sealed, err := f5xctest.Seal(ctx, []byte("secret"), pubKey, policyDoc)
+	if err != nil {
+		t.Fatalf("Seal raised an unexpected error: %v", err)
+	}
+	unsealed, err := wingman.UnsealEncoded(ctx, http.DefaultClient, server.WingmanURL()+wingman.UnsealEndpoint, sealed)
+	if err != nil {
+		t.Fatalf("UnsealEncoded raised an unexpected error: %v", err)
+	}
+	if string(unsealed) != "secret" {
+		t.Errorf("Expected unsealed data secret, got %q", unsealed)
+	}
+	wingmanClient, err := wingman.NewClient(server.WingmanURL())
+	if err != nil {
+		t.Fatalf("NewClient raised an unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = wingmanClient.Close() })
+	if err := wingmanClient.Ready(ctx); err != nil {
+		t.Errorf("Ready raised an unexpected error: %v", err)
+	}
+	if unsealed, err := wingmanClient.UnsealEncoded(ctx, sealed); err != nil || string(unsealed) != "secret" {
+		t.Errorf("Expected Client to unseal secret, got %q: %v", unsealed, err)
+	}
+}
+
+// Verify that the fake API rejects unauthenticated requests and reports missing keys and policies, and that the fake
+// Wingman refuses data sealed with another key or an unknown policy.
+func TestServer_Errors(t *testing.T) {
+	t.Parallel()
+	policyDoc := &f5xc.SecretPolicyDocument{
+		Metadata: &f5xc.Metadata{Name: "app", Namespace: "system", Tenant: "acme"},
+		PolicyID: "app-policy",
+	}
+	server := f5xctest.NewServer(t, f5xctest.WithTenant("acme"), f5xctest.WithAuthToken("secret-token"), f5xctest.WithPolicyDocument(policyDoc))
+	other := f5xctest.NewServer(t)
+	ctx := context.Background()
+	if _, err := f5xc.GetPublicKey(ctx, other.Client(t), nil); err != nil {
+		t.Errorf("GetPublicKey raised an unexpected error: %v", err)
+	}
+	client := server.Client(t)
+	version := 2
+	if pubKey, err := f5xc.GetPublicKey(ctx, client, &version); err != nil || pubKey != nil {
+		t.Errorf("Expected no public key for version 2, got %+v: %v", pubKey, err)
+	}
+	if doc, err := f5xc.GetSecretPolicyDocument(ctx, client, f5xctest.DefaultPolicy, f5xctest.DefaultNamespace); err != nil || doc != nil {
+		t.Errorf("Expected no default policy document, got %+v: %v", doc, err)
+	}
+	if doc, err := f5xc.GetSecretPolicyDocument(ctx, client, "app", "system"); err != nil || doc == nil || doc.PolicyID != "app-policy" {
+		t.Errorf("Expected policy document app-policy, got %+v: %v", doc, err)
+	}
+	unauthenticated := *server
+	unauthenticated.AuthToken = "wrong-token"
+	if _, err := f5xc.GetPublicKey(ctx, unauthenticated.Client(t), nil); !errors.Is(err, f5xc.ErrUnauthorized) {
+		t.Errorf("Expected GetPublicKey to raise %v, got %v", f5xc.ErrUnauthorized, err)
+	}
+
+	endpoint := server.WingmanURL() + wingman.UnsealEndpoint
+	tests := []struct {
+		name          string
+		pubKey        *f5xc.PublicKey
+		policyDoc     *f5xc.SecretPolicyDocument
+		expectedError error
+	}{
+		{
+			name:          "unknown-policy",
+			pubKey:        server.PublicKey(),
+			policyDoc:     &f5xc.SecretPolicyDocument{PolicyID: f5xctest.DefaultPolicyID},
+			expectedError: wingman.ErrDeniedByPolicy,
+		},
+		{
+			name:          "other-key",
+			pubKey:        other.PublicKey(),
+			policyDoc:     policyDoc,
+			expectedError: wingman.ErrUnexpectedHTTPStatus,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			sealed, err := f5xctest.Seal(ctx, []byte("secret"), tst.pubKey, tst.policyDoc)
+			if err != nil {
+				t.Fatalf("Seal raised an unexpected error: %v", err)
+			}
+			if _, err := wingman.UnsealEncoded(ctx, http.DefaultClient, endpoint, sealed); !errors.Is(err, tst.expectedError) {
+				t.Errorf("Expected UnsealEncoded to raise %v, got %v", tst.expectedError, err)
+			}
+		})
+	}
+}
+
+// Verify that Seal refuses invalid public keys and policy documents.
+func TestSeal_Invalid(t *testing.T) {
+	t.Parallel()
+	server := f5xctest.NewServer(t)
+	policyDoc := server.PolicyDocument(f5xctest.DefaultNamespace, f5xctest.DefaultPolicy)
+	if _, err := f5xctest.Seal(context.Background(), []byte("secret"), &f5xc.PublicKey{}, policyDoc); !errors.Is(err, f5xc.ErrInvalidPublicKey) {
+		t.Errorf("Expected Seal to raise %v, got %v", f5xc.ErrInvalidPublicKey, err)
+	}
+	if _, err := f5xctest.Seal(context.Background(), []byte("secret"), server.PublicKey(), nil); !errors.Is(err, f5xctest.ErrInvalidPolicyDocument) {
+		t.Errorf("Expected Seal to raise %v, got %v", f5xctest.ErrInvalidPolicyDocument, err)
+	}
+}
+
+// Verify that the ROT13 fake Wingman unseals data that was sealed with ROT13, and rejects invalid requests.
+func TestROT13WingmanHandler(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(f5xctest.ROT13WingmanHandler(t))
+	t.Cleanup(server.Close)
+	endpoint := server.URL + wingman.UnsealEndpoint
+	sealed := []byte(base64.StdEncoding.EncodeToString(f5xctest.ROT13([]byte("Hunter2!"))))
+	unsealed, err := wingman.UnsealEncoded(context.Background(), http.DefaultClient, endpoint, sealed)
+	switch {
+	case err != nil:
+		t.Errorf("UnsealEncoded raised an unexpected error: %v", err)
+	case string(unsealed) != "Hunter2!":
+		t.Errorf("Expected Hunter2!, got %q", unsealed)
+	}
+	if _, err := wingman.UnsealEncoded(context.Background(), http.DefaultClient, endpoint, []byte("not-base64")); !errors.Is(err, wingman.ErrUnexpectedHTTPStatus) {
+		t.Errorf("Expected UnsealEncoded to raise %v, got %v", wingman.ErrUnexpectedHTTPStatus, err)
+	}
+}
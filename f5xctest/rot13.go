@@ -0,0 +1,62 @@
+package f5xctest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.uber.org/goleak"
+)
+
+// ROT13WingmanHandler returns the handler of a fake Wingman for tests that do not need a key pair: the location of an
+// unseal request is decoded from base64, transformed with ROT13, and returned encoded as base64, so data is sealed by
+// applying ROT13 before it is encoded. A request that deviates from the expected structure returns 400 status.
+func ROT13WingmanHandler(tb testing.TB) http.Handler {
+	tb.Helper()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Location string `json:"location"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			tb.Logf("failed to unmarshal request JSON: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		b64, ok := strings.CutPrefix(payload.Location, locationPrefix)
+		if !ok {
+			tb.Logf("payload location did not match expected scheme: %s", payload.Location)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(base64.StdEncoding.EncodeToString(ROT13(data))))
+	})
+}
+
+// ROT13 returns a copy of data with every ASCII letter rotated by 13 places; applying it twice returns the original.
+func ROT13(data []byte) []byte {
+	result := make([]byte, len(data))
+	for i, b := range data {
+		switch {
+		case b >= 'A' && b <= 'Z':
+			result[i] = (b-'A'+13)%26 + 'A'
+		case b >= 'a' && b <= 'z':
+			result[i] = (b-'a'+13)%26 + 'a'
+		default:
+			result[i] = b
+		}
+	}
+	return result
+}
+
+// IgnoreOpenCensus returns a goleak option for the TestMain of packages that import the gocloud.dev secrets package,
+// which depends on OpenCensus; OpenCensus starts a worker when it is imported that never exits.
+func IgnoreOpenCensus() goleak.Option {
+	return goleak.IgnoreTopFunction("go.opencensus.io/stats/view.(*worker).start")
+}
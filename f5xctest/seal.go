@@ -0,0 +1,137 @@
+package f5xctest
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/memes/f5xc"
+)
+
+// The size of the AES key that encrypts sealed data.
+const dataKeySize = 32
+
+var (
+	// ErrInvalidPolicyDocument is returned by [Seal] when the secret policy document does not have a policy ID.
+	ErrInvalidPolicyDocument = errors.New("invalid secret policy document")
+	// Returned when the fake Wingman cannot decode or decrypt sealed data.
+	errInvalidSealedData = errors.New("invalid sealed data")
+	// Returned when the fake Wingman does not serve the policy that sealed data.
+	errPolicyDenied = errors.New("denied by security policy")
+)
+
+// Seal encrypts the plaintext with the public key so that it can be unsealed by the fake Wingman of the [Server] that
+// published the key, as long as the Server still serves the secret policy. Seal has the same signature as
+// [blindfold.Seal] without the vesctl executable, so that it can replace it in tests, and returns base64 encoded data.
+//
+// The sealed data has the same header as blindfold data that is recognized by f5xc inspect, a length prefixed policy
+// ID followed by the key version, but the ciphertext is an AES-GCM encryption of the plaintext with a random key that
+// is encrypted with RSA-OAEP; it cannot be unsealed by a real Wingman.
+func Seal(_ context.Context, plaintext []byte, pubKey *f5xc.PublicKey, policyDoc *f5xc.SecretPolicyDocument) ([]byte, error) {
+	if err := pubKey.Validate(); err != nil {
+		return nil, fmt.Errorf("public key cannot be used to seal: %w", err)
+	}
+	rsaKey, err := pubKey.RSAPublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("public key cannot be used to seal: %w", err)
+	}
+	if policyDoc == nil || policyDoc.PolicyID == "" {
+		return nil, fmt.Errorf("policy document must have a policy ID: %w", ErrInvalidPolicyDocument)
+	}
+	header := binary.BigEndian.AppendUint32(nil, uint32(len(policyDoc.PolicyID))) //nolint:gosec // Policy IDs are short
+	header = append(header, policyDoc.PolicyID...)
+	header = binary.BigEndian.AppendUint32(header, uint32(pubKey.KeyVersion)) //nolint:gosec // Key versions are small
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, rsaKey, dataKey, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(slices.Concat(header, wrapped, nonce), nonce, plaintext, header)
+	return []byte(base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// Returns the plaintext of base64 encoded data sealed by Seal with the public key of the Server, or an error that wraps
+// errPolicyDenied if the Server does not serve the policy that sealed the data.
+func (s *Server) open(encoded string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sealed data: %w: %w", err, errInvalidSealedData)
+	}
+	if len(data) < 4 {
+		return nil, fmt.Errorf("sealed data is truncated: %w", errInvalidSealedData)
+	}
+	policyIDLength := int(binary.BigEndian.Uint32(data))
+	if policyIDLength > len(data)-8 {
+		return nil, fmt.Errorf("sealed data is truncated: %w", errInvalidSealedData)
+	}
+	header, data := data[:8+policyIDLength], data[8+policyIDLength:]
+	policyID := string(header[4 : 4+policyIDLength])
+	if keyVersion := int(binary.BigEndian.Uint32(header[4+policyIDLength:])); keyVersion != s.KeyVersion {
+		return nil, fmt.Errorf("sealed with key version %d, but the key version is %d: %w", keyVersion, s.KeyVersion, errInvalidSealedData)
+	}
+	if !s.servesPolicy(policyID) {
+		return nil, fmt.Errorf("policy ID %s: %w", policyID, errPolicyDenied)
+	}
+	if len(data) < s.key.Size() {
+		return nil, fmt.Errorf("sealed data is truncated: %w", errInvalidSealedData)
+	}
+	dataKey, err := rsa.DecryptOAEP(sha256.New(), nil, s.key, data[:s.key.Size()], header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w: %w", err, errInvalidSealedData)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	data = data[s.key.Size():]
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed data is truncated: %w", errInvalidSealedData)
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt sealed data: %w: %w", err, errInvalidSealedData)
+	}
+	return plaintext, nil
+}
+
+// Returns true if the Server serves a secret policy with the identifier.
+func (s *Server) servesPolicy(policyID string) bool {
+	for _, policyDoc := range s.policyDocs {
+		if policyDoc.PolicyID == policyID {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns an AES-GCM cipher that uses the key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return aead, nil
+}
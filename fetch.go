@@ -0,0 +1,87 @@
+package f5xc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// The maximum number of requests that FetchAll sends concurrently.
+const DefaultFetchConcurrency = 8
+
+// FetchRequest is a request for a resource that is sent by [FetchAll]; see [Fetch], [FetchPublicKey], and
+// [FetchSecretPolicyDocument].
+type FetchRequest func(ctx context.Context, client Doer) error
+
+// Fetch returns a FetchRequest that sends the request with [StrictEnvelopeAPICall] and stores the resource in result
+// when it succeeds. The request is sent with the context given to [FetchAll].
+func Fetch[T EnvelopeAllowed](req *http.Request, result **T) FetchRequest {
+	return func(ctx context.Context, client Doer) error {
+		resource, err := StrictEnvelopeAPICall[T](client, req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		*result = resource
+		return nil
+	}
+}
+
+// FetchPublicKey returns a FetchRequest for the PublicKey with the version, or the current PublicKey if version is
+// nil, that stores the PublicKey in result.
+func FetchPublicKey(result **PublicKey, version *int) FetchRequest {
+	return func(ctx context.Context, client Doer) error {
+		req, err := newPublicKeyRequest(ctx, clientLogger(client), version)
+		if err != nil {
+			return err
+		}
+		return Fetch(req, result)(ctx, client)
+	}
+}
+
+// FetchSecretPolicyDocument returns a FetchRequest for the named SecretPolicyDocument that stores it in result. If
+// namespace is empty the namespace set on the context with [WithNamespace] is used.
+func FetchSecretPolicyDocument(result **SecretPolicyDocument, name, namespace string) FetchRequest {
+	return func(ctx context.Context, client Doer) error {
+		logger := clientLogger(client)
+		req, err := newSecretPolicyDocumentRequest(ctx, logger, name, resolveNamespace(ctx, logger, namespace))
+		if err != nil {
+			return err
+		}
+		return Fetch(req, result)(ctx, client)
+	}
+}
+
+// FetchAll sends the requests concurrently with the client, with at most [DefaultFetchConcurrency] requests in flight,
+// e.g. to retrieve the public key and several secret policy documents needed to seal secrets. Every request is sent
+// even if others fail, unless the context is canceled, and the errors of all failed requests are returned together.
+func FetchAll(ctx context.Context, client Doer, requests ...FetchRequest) error {
+	return FetchAllLimit(ctx, client, DefaultFetchConcurrency, requests...)
+}
+
+// FetchAllLimit is the same as [FetchAll], except that at most limit requests are in flight.
+func FetchAllLimit(ctx context.Context, client Doer, limit int, requests ...FetchRequest) error {
+	if limit < 1 {
+		return fmt.Errorf("fetch concurrency limit must be positive: %w", ErrInvalidOption)
+	}
+	clientLogger(client).Debug("Fetching resources", "requests", len(requests), "limit", limit)
+	// The error of each request is recorded instead of returned to the group, so that a failure does not stop the
+	// remaining requests
+	errs := make([]error, len(requests))
+	var group errgroup.Group
+	group.SetLimit(limit)
+	for i, request := range requests {
+		if err := ctx.Err(); err != nil {
+			errs[i] = fmt.Errorf("fetch canceled with %d requests unsent: %w", len(requests)-i, err)
+			break
+		}
+		group.Go(func() error {
+			errs[i] = request(ctx, client)
+			return nil
+		})
+	}
+	_ = group.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,110 @@
+package f5xc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+)
+
+// Verify that FetchAll retrieves every resource with no more than the limit of concurrent requests.
+func TestFetchAllLimit(t *testing.T) {
+	t.Parallel()
+	var inFlight, maxInFlight, requests atomic.Int32
+	client, _ := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			observed := maxInFlight.Load()
+			if current <= observed || maxInFlight.CompareAndSwap(observed, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if r.URL.Path == f5xc.PublicKeyURL {
+			_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{Data: f5xc.PublicKey{KeyVersion: 1}})
+			return
+		}
+		parts := strings.Split(r.URL.Path, "/")
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.SecretPolicyDocument]{
+			Data: f5xc.SecretPolicyDocument{PolicyID: parts[len(parts)-2]},
+		})
+	}))
+	var publicKey *f5xc.PublicKey
+	policyDocs := make([]*f5xc.SecretPolicyDocument, 5)
+	fetches := []f5xc.FetchRequest{f5xc.FetchPublicKey(&publicKey, nil)}
+	for i, name := range []string{"a", "b", "c", "d", "e"} {
+		fetches = append(fetches, f5xc.FetchSecretPolicyDocument(&policyDocs[i], name, "shared"))
+	}
+	if err := f5xc.FetchAllLimit(context.Background(), client, 2, fetches...); err != nil {
+		t.Fatalf("FetchAllLimit raised an unexpected error: %v", err)
+	}
+	if publicKey == nil || publicKey.KeyVersion != 1 {
+		t.Errorf("Expected public key version 1, got %+v", publicKey)
+	}
+	for i, name := range []string{"a", "b", "c", "d", "e"} {
+		if policyDocs[i] == nil || policyDocs[i].PolicyID != name {
+			t.Errorf("Expected policy document %s, got %+v", name, policyDocs[i])
+		}
+	}
+	if count := requests.Load(); count != 6 {
+		t.Errorf("Expected 6 requests, got %d", count)
+	}
+	if count := maxInFlight.Load(); count > 2 {
+		t.Errorf("Expected at most 2 concurrent requests, got %d", count)
+	}
+	if err := f5xc.FetchAllLimit(context.Background(), client, 0); !errors.Is(err, f5xc.ErrInvalidOption) {
+		t.Errorf("Expected FetchAllLimit to raise %v, got %v", f5xc.ErrInvalidOption, err)
+	}
+}
+
+// Verify that FetchAll sends every request when some fail and returns all of the errors, and that requests are not sent
+// when the context is canceled.
+func TestFetchAll_Errors(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	client, serverURL := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch {
+		case strings.Contains(r.URL.Path, "/missing/"):
+			w.WriteHeader(http.StatusNotFound)
+		case strings.Contains(r.URL.Path, "/denied/"):
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{Data: f5xc.PublicKey{KeyVersion: 2}})
+		}
+	}))
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, serverURL+f5xc.PublicKeyURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	var publicKey *f5xc.PublicKey
+	var missing, denied *f5xc.SecretPolicyDocument
+	err = f5xc.FetchAll(context.Background(), client,
+		f5xc.Fetch(req, &publicKey),
+		f5xc.FetchSecretPolicyDocument(&missing, "missing", "shared"),
+		f5xc.FetchSecretPolicyDocument(&denied, "denied", "shared"),
+	)
+	if !errors.Is(err, f5xc.ErrNotFound) || !errors.Is(err, f5xc.ErrForbidden) {
+		t.Errorf("Expected FetchAll to raise %v and %v, got %v", f5xc.ErrNotFound, f5xc.ErrForbidden, err)
+	}
+	if publicKey == nil || publicKey.KeyVersion != 2 || missing != nil || denied != nil {
+		t.Errorf("Expected only the public key to be fetched, got %+v, %+v, %+v", publicKey, missing, denied)
+	}
+	requests.Store(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := f5xc.FetchAll(ctx, client, f5xc.FetchPublicKey(&publicKey, nil)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected FetchAll to raise %v, got %v", context.Canceled, err)
+	}
+	if count := requests.Load(); count != 0 {
+		t.Errorf("Expected no requests, got %d", count)
+	}
+}
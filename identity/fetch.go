@@ -0,0 +1,48 @@
+package identity
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+
+	"github.com/memes/f5xc/wingman"
+)
+
+// FileFetcher returns a Fetcher that reads a PEM encoded certificate chain and private key from files, which are read
+// again on every renewal so that certificates rotated on disk are picked up.
+func FileFetcher(certFile, keyFile string) Fetcher {
+	return func(context.Context) (*tls.Certificate, error) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load workload certificate: %w", err)
+		}
+		return &cert, nil
+	}
+}
+
+// SealedKeyFetcher returns a Fetcher that reads a PEM encoded certificate chain from a file, and a base64 encoded
+// blindfolded PEM private key from another file which is unsealed through the Wingman client. Both files are read
+// again on every renewal.
+func SealedKeyFetcher(client wingman.Client, certFile, sealedKeyFile string) Fetcher {
+	return func(ctx context.Context) (*tls.Certificate, error) {
+		certPEM, err := os.ReadFile(certFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read workload certificate: %w", err)
+		}
+		sealed, err := os.ReadFile(sealedKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sealed private key: %w", err)
+		}
+		keyPEM, err := client.UnsealEncoded(ctx, bytes.TrimSpace(sealed))
+		if err != nil {
+			return nil, fmt.Errorf("failed to unseal private key: %w", err)
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load workload certificate: %w", err)
+		}
+		return &cert, nil
+	}
+}
@@ -0,0 +1,348 @@
+// Package identity turns the workload certificates of an F5 Distributed Cloud workload into an automatically rotating
+// source of TLS credentials, in the style of a SPIFFE X.509 SVID source, so that services can authenticate each other
+// with mutual TLS using their F5XC identity.
+//
+// The Wingman client does not expose a certificate issuing API, so certificates are obtained from a [Fetcher]:
+// [FileFetcher] reads a certificate and private key that are provisioned to the workload as files, and
+// [SealedKeyFetcher] reads a certificate and a blindfolded private key that is unsealed through Wingman, so the key is
+// only available to workloads allowed by its secret policy. A [Source] fetches the certificate when created, and again
+// as it approaches expiry, notifying callbacks when the certificate is rotated; TLS configurations created by the
+// Source always present the current certificate.
+package identity
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// The default fraction of a certificate's lifetime that remains when it is renewed.
+	DefaultRenewFraction = 0.5
+	// The default time to wait before retrying a failed or premature renewal.
+	DefaultRetryInterval = 30 * time.Second
+)
+
+var (
+	// ErrInvalidOption is returned when an option value is not acceptable.
+	ErrInvalidOption = errors.New("invalid option")
+	// ErrNoCertificate is returned when a fetched certificate chain is empty or cannot be parsed.
+	ErrNoCertificate = errors.New("no workload certificate")
+	// ErrExpired is returned when a fetched certificate is not currently valid.
+	ErrExpired = errors.New("workload certificate is not valid at the current time")
+	// ErrNoTrustRoots is returned when a TLS configuration that verifies peers is requested without trust roots.
+	ErrNoTrustRoots = errors.New("trust roots are required to verify peers")
+	// ErrUnauthorized is returned when a verified peer certificate is rejected by an [Authorizer].
+	ErrUnauthorized = errors.New("peer is not authorized")
+)
+
+// Fetcher returns the current certificate chain and private key of the workload.
+type Fetcher func(ctx context.Context) (*tls.Certificate, error)
+
+// Authorizer accepts or rejects the verified leaf certificate of a peer, returning an error that wraps
+// [ErrUnauthorized] if the peer is rejected.
+type Authorizer func(peer *x509.Certificate) error
+
+// Defines the configuration options for a Source.
+type config struct {
+	renewFraction float64
+	retryInterval time.Duration
+	roots         *x509.CertPool
+	callbacks     []func(*tls.Certificate)
+}
+
+// Defines a configuration setting function for NewSource.
+type Option func(*config) error
+
+// Renew the certificate when the given fraction of its lifetime remains; e.g. a value of 0.25 with a 24 hour
+// certificate will renew 6 hours before expiry. The fraction must be greater than 0 and less than 1; the default is
+// [DefaultRenewFraction].
+func WithRenewFraction(fraction float64) Option {
+	return func(c *config) error {
+		if fraction <= 0 || fraction >= 1 {
+			return fmt.Errorf("renew fraction must be between 0 and 1 exclusive: %w", ErrInvalidOption)
+		}
+		c.renewFraction = fraction
+		return nil
+	}
+}
+
+// Wait for the given interval before retrying a renewal that failed, or that fetched the certificate that is already
+// in use; the default is [DefaultRetryInterval].
+func WithRetryInterval(interval time.Duration) Option {
+	return func(c *config) error {
+		if interval <= 0 {
+			return fmt.Errorf("retry interval must be positive: %w", ErrInvalidOption)
+		}
+		c.retryInterval = interval
+		return nil
+	}
+}
+
+// Verify peer certificates against the supplied trust roots, typically the CA certificates that issue F5XC workload
+// certificates. Trust roots are required by [Source.ServerTLSConfig] and [Source.ClientTLSConfig].
+func WithRoots(roots *x509.CertPool) Option {
+	return func(c *config) error {
+		c.roots = roots
+		return nil
+	}
+}
+
+// Call the function with the new certificate whenever the certificate is rotated. Callbacks are called sequentially
+// from the renewal goroutine and should not block.
+func WithRotationCallback(callback func(*tls.Certificate)) Option {
+	return func(c *config) error {
+		if callback == nil {
+			return fmt.Errorf("rotation callback must not be nil: %w", ErrInvalidOption)
+		}
+		c.callbacks = append(c.callbacks, callback)
+		return nil
+	}
+}
+
+// Source provides the current workload certificate, renewing it before expiry until closed. A Source is safe for
+// concurrent use.
+type Source struct {
+	fetch  Fetcher
+	cfg    *config
+	cancel context.CancelFunc
+	done   chan struct{}
+	// Guards cert.
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// NewSource fetches the workload certificate and returns a Source that will renew it in the background until
+// [Source.Close] is called. An error is returned if the initial certificate cannot be fetched or is not valid.
+func NewSource(ctx context.Context, fetch Fetcher, options ...Option) (*Source, error) {
+	cfg := &config{
+		renewFraction: DefaultRenewFraction,
+		retryInterval: DefaultRetryInterval,
+	}
+	for _, option := range options {
+		if err := option(cfg); err != nil {
+			return nil, err
+		}
+	}
+	cert, err := fetchValid(ctx, fetch)
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("Fetched workload certificate", "subject", cert.Leaf.Subject.String(), "notAfter", cert.Leaf.NotAfter)
+	renewCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	s := &Source{
+		fetch:  fetch,
+		cfg:    cfg,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		cert:   cert,
+	}
+	go s.renew(renewCtx)
+	return s, nil
+}
+
+// Fetches a certificate and verifies that the leaf is parsed and currently valid.
+func fetchValid(ctx context.Context, fetch Fetcher) (*tls.Certificate, error) {
+	cert, err := fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch workload certificate: %w", err)
+	}
+	if cert == nil || len(cert.Certificate) == 0 {
+		return nil, ErrNoCertificate
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrNoCertificate, err)
+		}
+	}
+	if now := time.Now(); now.Before(cert.Leaf.NotBefore) || now.After(cert.Leaf.NotAfter) {
+		return nil, fmt.Errorf("certificate valid from %s to %s: %w", cert.Leaf.NotBefore, cert.Leaf.NotAfter, ErrExpired)
+	}
+	return cert, nil
+}
+
+// Returns the time to wait until the certificate should be renewed, which may be negative if renewal is overdue.
+func (s *Source) renewIn(cert *tls.Certificate) time.Duration {
+	lifetime := cert.Leaf.NotAfter.Sub(cert.Leaf.NotBefore)
+	renewAt := cert.Leaf.NotAfter.Add(-time.Duration(float64(lifetime) * s.cfg.renewFraction))
+	return time.Until(renewAt)
+}
+
+// Renews the certificate as it approaches expiry until the context is done. A certificate that cannot be fetched, or
+// that has not changed, is retried after the retry interval; the current certificate is kept until a valid replacement
+// is fetched.
+func (s *Source) renew(ctx context.Context) {
+	defer close(s.done)
+	timer := time.NewTimer(s.renewIn(s.Certificate()))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		next := s.cfg.retryInterval
+		rotated, err := s.rotate(ctx)
+		switch {
+		case err != nil:
+			slog.Warn("Failed to renew workload certificate", "error", err)
+		case rotated:
+			if wait := s.renewIn(s.Certificate()); wait > 0 {
+				next = wait
+			}
+		default:
+			slog.Debug("Workload certificate has not been reissued")
+		}
+		timer.Reset(next)
+	}
+}
+
+// Fetches the certificate and replaces the current certificate if it has changed, calling any rotation callbacks.
+func (s *Source) rotate(ctx context.Context) (bool, error) {
+	cert, err := fetchValid(ctx, s.fetch)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	if bytes.Equal(s.cert.Certificate[0], cert.Certificate[0]) {
+		s.mu.Unlock()
+		return false, nil
+	}
+	s.cert = cert
+	s.mu.Unlock()
+	slog.Info("Rotated workload certificate", "subject", cert.Leaf.Subject.String(), "notAfter", cert.Leaf.NotAfter)
+	for _, callback := range s.cfg.callbacks {
+		callback(cert)
+	}
+	return true, nil
+}
+
+// Certificate returns the current workload certificate.
+func (s *Source) Certificate() *tls.Certificate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cert
+}
+
+// GetCertificate implements the tls.Config callback of the same name, returning the current workload certificate.
+func (s *Source) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return s.Certificate(), nil
+}
+
+// GetClientCertificate implements the tls.Config callback of the same name, returning the current workload
+// certificate.
+func (s *Source) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return s.Certificate(), nil
+}
+
+// ServerTLSConfig returns a TLS configuration for a server that presents the workload certificate, and requires
+// clients to present a certificate that is verified against the trust roots and accepted by the authorizer.
+func (s *Source) ServerTLSConfig(authorize Authorizer) (*tls.Config, error) {
+	if s.cfg.roots == nil {
+		return nil, ErrNoTrustRoots
+	}
+	return &tls.Config{
+		MinVersion:            tls.VersionTLS12,
+		GetCertificate:        s.GetCertificate,
+		ClientAuth:            tls.RequireAnyClientCert,
+		VerifyPeerCertificate: s.verifyPeer(authorize),
+	}, nil
+}
+
+// ClientTLSConfig returns a TLS configuration for a client that presents the workload certificate, and requires the
+// server certificate to be verified against the trust roots and accepted by the authorizer. Workload certificates
+// identify workloads rather than hosts, so the server name is not verified; the authorizer should be used to check the
+// identity of the server.
+func (s *Source) ClientTLSConfig(authorize Authorizer) (*tls.Config, error) {
+	if s.cfg.roots == nil {
+		return nil, ErrNoTrustRoots
+	}
+	return &tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		GetClientCertificate: s.GetClientCertificate,
+		// Verification is performed by VerifyPeerCertificate, which ignores the server name.
+		InsecureSkipVerify:    true, //nolint:gosec // The peer certificate is verified against the trust roots
+		VerifyPeerCertificate: s.verifyPeer(authorize),
+	}, nil
+}
+
+// Returns a function that verifies the peer certificate chain against the trust roots, and then applies the authorizer
+// to the leaf certificate.
+func (s *Source) verifyPeer(authorize Authorizer) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("peer did not present a certificate: %w", ErrUnauthorized)
+		}
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return fmt.Errorf("failed to parse peer certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		if _, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         s.cfg.roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}); err != nil {
+			return fmt.Errorf("failed to verify peer certificate: %w", err)
+		}
+		if authorize == nil {
+			return nil
+		}
+		return authorize(certs[0])
+	}
+}
+
+// Close stops renewing the certificate; the current certificate remains available.
+func (s *Source) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// AuthorizeAny returns an Authorizer that accepts any peer with a verified certificate.
+func AuthorizeAny() Authorizer {
+	return func(*x509.Certificate) error {
+		return nil
+	}
+}
+
+// AuthorizeID returns an Authorizer that accepts peers whose certificate has a URI or DNS subject alternative name, or
+// a subject common name, that matches one of the identities.
+func AuthorizeID(ids ...string) Authorizer {
+	return func(peer *x509.Certificate) error {
+		for _, id := range IDs(peer) {
+			if slices.Contains(ids, id) {
+				return nil
+			}
+		}
+		return fmt.Errorf("peer identities %v: %w", IDs(peer), ErrUnauthorized)
+	}
+}
+
+// IDs returns the identities declared by a certificate; the URI subject alternative names, followed by the DNS subject
+// alternative names and the subject common name.
+func IDs(cert *x509.Certificate) []string {
+	ids := make([]string, 0, len(cert.URIs)+len(cert.DNSNames)+1)
+	for _, uri := range cert.URIs {
+		ids = append(ids, uri.String())
+	}
+	ids = append(ids, cert.DNSNames...)
+	if cert.Subject.CommonName != "" {
+		ids = append(ids, cert.Subject.CommonName)
+	}
+	return ids
+}
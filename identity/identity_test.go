@@ -0,0 +1,443 @@
+package identity_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc/identity"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+// Implements a wingman.Client that unseals by decoding base64 data.
+type testClient struct{}
+
+func (testClient) Ready(context.Context) error { return nil }
+
+func (testClient) Unseal(_ context.Context, sealed []byte) ([]byte, error) { return sealed, nil }
+
+func (testClient) UnsealEncoded(_ context.Context, sealed []byte) ([]byte, error) {
+	return base64.StdEncoding.AppendDecode(nil, sealed) //nolint:wrapcheck // Test client
+}
+
+func (testClient) Close() error { return nil }
+
+func (testClient) Shutdown(context.Context) error { return nil }
+
+// A test certificate authority that issues workload certificates.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+// Returns a new self-signed certificate authority.
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse CA certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// Issues a workload certificate for the URI identity that is valid for the lifetime, starting now.
+func (ca *testCA) issue(t *testing.T, id string, lifetime time.Duration) *tls.Certificate {
+	t.Helper()
+	certPEM, keyPEM := ca.issuePEM(t, id, time.Now().Add(-time.Second), lifetime)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("failed to load issued certificate: %v", err)
+	}
+	return &cert
+}
+
+// Issues a PEM encoded workload certificate and private key for the URI identity.
+func (ca *testCA) issuePEM(t *testing.T, id string, notBefore time.Time, lifetime time.Duration) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	uri, err := url.Parse(id)
+	if err != nil {
+		t.Fatalf("failed to parse identity: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("failed to generate serial: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(lifetime),
+		URIs:         []*url.URL{uri},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+// Returns a Fetcher that always returns the certificate.
+func staticFetcher(cert *tls.Certificate) identity.Fetcher {
+	return func(context.Context) (*tls.Certificate, error) {
+		return cert, nil
+	}
+}
+
+// Verify that a Source is only created with valid options and a currently valid certificate.
+func TestNewSource(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t)
+	expiredPEM, expiredKeyPEM := ca.issuePEM(t, "spiffe://test/expired", time.Now().Add(-2*time.Hour), time.Hour)
+	expired, err := tls.X509KeyPair(expiredPEM, expiredKeyPEM)
+	if err != nil {
+		t.Fatalf("failed to load expired certificate: %v", err)
+	}
+	errFetch := errors.New("fetch failed")
+	tests := []struct {
+		name          string
+		fetch         identity.Fetcher
+		options       []identity.Option
+		expectedError error
+	}{
+		{
+			name:  "valid",
+			fetch: staticFetcher(ca.issue(t, "spiffe://test/valid", time.Hour)),
+			options: []identity.Option{
+				identity.WithRenewFraction(0.25),
+				identity.WithRetryInterval(time.Second),
+				identity.WithRoots(ca.pool),
+			},
+		},
+		{
+			name:          "invalid-renew-fraction",
+			fetch:         staticFetcher(ca.issue(t, "spiffe://test/valid", time.Hour)),
+			options:       []identity.Option{identity.WithRenewFraction(1)},
+			expectedError: identity.ErrInvalidOption,
+		},
+		{
+			name:          "invalid-retry-interval",
+			fetch:         staticFetcher(ca.issue(t, "spiffe://test/valid", time.Hour)),
+			options:       []identity.Option{identity.WithRetryInterval(0)},
+			expectedError: identity.ErrInvalidOption,
+		},
+		{
+			name:          "nil-callback",
+			fetch:         staticFetcher(ca.issue(t, "spiffe://test/valid", time.Hour)),
+			options:       []identity.Option{identity.WithRotationCallback(nil)},
+			expectedError: identity.ErrInvalidOption,
+		},
+		{
+			name:          "expired",
+			fetch:         staticFetcher(&expired),
+			expectedError: identity.ErrExpired,
+		},
+		{
+			name:          "empty",
+			fetch:         staticFetcher(&tls.Certificate{}),
+			expectedError: identity.ErrNoCertificate,
+		},
+		{
+			name: "fetch-error",
+			fetch: func(context.Context) (*tls.Certificate, error) {
+				return nil, errFetch
+			},
+			expectedError: errFetch,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			source, err := identity.NewSource(context.Background(), tst.fetch, tst.options...)
+			if err == nil {
+				_ = source.Close()
+			}
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("NewSource raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected NewSource to raise %v, got %v", tst.expectedError, err)
+			}
+		})
+	}
+}
+
+// Verify that the certificate is renewed before expiry, and that callbacks are notified of the new certificate.
+func TestRotation(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t)
+	var fetches atomic.Int32
+	fetch := func(context.Context) (*tls.Certificate, error) {
+		// Fail one renewal to verify that the current certificate is kept and renewal is retried.
+		if fetches.Add(1) == 2 {
+			return nil, errors.New("temporary failure")
+		}
+		return ca.issue(t, "spiffe://test/workload", 2*time.Second), nil
+	}
+	rotated := make(chan *tls.Certificate, 1)
+	source, err := identity.NewSource(context.Background(), fetch,
+		identity.WithRenewFraction(0.75),
+		identity.WithRetryInterval(50*time.Millisecond),
+		identity.WithRotationCallback(func(cert *tls.Certificate) {
+			select {
+			case rotated <- cert:
+			default:
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewSource raised an unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = source.Close()
+	})
+	initial := source.Certificate()
+	select {
+	case cert := <-rotated:
+		if cert == initial || cert.Leaf.SerialNumber.Cmp(initial.Leaf.SerialNumber) == 0 {
+			t.Errorf("Expected a new certificate")
+		}
+		if current, _ := source.GetCertificate(nil); current == initial {
+			t.Errorf("Expected GetCertificate to return a rotated certificate")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for certificate rotation")
+	}
+	if fetches.Load() < 3 {
+		t.Errorf("Expected a failed renewal to be retried, got %d fetches", fetches.Load())
+	}
+}
+
+// Verify that clients and servers with workload certificates authenticate each other.
+func TestMutualTLS(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t)
+	other := newTestCA(t)
+	tests := []struct {
+		name            string
+		clientCert      *tls.Certificate
+		serverAuthorize identity.Authorizer
+		clientAuthorize identity.Authorizer
+		expectError     bool
+	}{
+		{
+			name:            "authorized",
+			clientCert:      ca.issue(t, "spiffe://test/client", time.Hour),
+			serverAuthorize: identity.AuthorizeID("spiffe://test/client"),
+			clientAuthorize: identity.AuthorizeID("spiffe://test/server"),
+		},
+		{
+			name:            "any",
+			clientCert:      ca.issue(t, "spiffe://test/client", time.Hour),
+			serverAuthorize: identity.AuthorizeAny(),
+			clientAuthorize: identity.AuthorizeAny(),
+		},
+		{
+			name:            "unauthorized-client",
+			clientCert:      ca.issue(t, "spiffe://test/client", time.Hour),
+			serverAuthorize: identity.AuthorizeID("spiffe://test/other"),
+			clientAuthorize: identity.AuthorizeAny(),
+			expectError:     true,
+		},
+		{
+			name:            "unauthorized-server",
+			clientCert:      ca.issue(t, "spiffe://test/client", time.Hour),
+			serverAuthorize: identity.AuthorizeAny(),
+			clientAuthorize: identity.AuthorizeID("spiffe://test/other"),
+			expectError:     true,
+		},
+		{
+			name:            "untrusted-client",
+			clientCert:      other.issue(t, "spiffe://test/client", time.Hour),
+			serverAuthorize: identity.AuthorizeAny(),
+			clientAuthorize: identity.AuthorizeAny(),
+			expectError:     true,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			serverSource, err := identity.NewSource(context.Background(), staticFetcher(ca.issue(t, "spiffe://test/server", time.Hour)), identity.WithRoots(ca.pool))
+			if err != nil {
+				t.Fatalf("NewSource raised an unexpected error: %v", err)
+			}
+			t.Cleanup(func() {
+				_ = serverSource.Close()
+			})
+			clientSource, err := identity.NewSource(context.Background(), staticFetcher(tst.clientCert), identity.WithRoots(ca.pool))
+			if err != nil {
+				t.Fatalf("NewSource raised an unexpected error: %v", err)
+			}
+			t.Cleanup(func() {
+				_ = clientSource.Close()
+			})
+			serverConfig, err := serverSource.ServerTLSConfig(tst.serverAuthorize)
+			if err != nil {
+				t.Fatalf("ServerTLSConfig raised an unexpected error: %v", err)
+			}
+			clientConfig, err := clientSource.ClientTLSConfig(tst.clientAuthorize)
+			if err != nil {
+				t.Fatalf("ClientTLSConfig raised an unexpected error: %v", err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("failed to listen: %v", err)
+			}
+			t.Cleanup(func() {
+				_ = listener.Close()
+			})
+			serverErr := make(chan error, 1)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					serverErr <- err
+					return
+				}
+				server := tls.Server(conn, serverConfig)
+				serverErr <- server.HandshakeContext(ctx)
+				_ = server.Close()
+			}()
+			conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", listener.Addr().String())
+			if err != nil {
+				t.Fatalf("failed to dial: %v", err)
+			}
+			client := tls.Client(conn, clientConfig)
+			clientErr := client.HandshakeContext(ctx)
+			_ = client.Close()
+			err = errors.Join(clientErr, <-serverErr)
+			switch {
+			case tst.expectError && err == nil:
+				t.Errorf("Expected handshake to fail")
+			case !tst.expectError && err != nil:
+				t.Errorf("Handshake raised an unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// Verify that TLS configurations that verify peers require trust roots.
+func TestNoTrustRoots(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t)
+	source, err := identity.NewSource(context.Background(), staticFetcher(ca.issue(t, "spiffe://test/workload", time.Hour)))
+	if err != nil {
+		t.Fatalf("NewSource raised an unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = source.Close()
+	})
+	if _, err := source.ServerTLSConfig(identity.AuthorizeAny()); !errors.Is(err, identity.ErrNoTrustRoots) {
+		t.Errorf("Expected ServerTLSConfig to raise %v, got %v", identity.ErrNoTrustRoots, err)
+	}
+	if _, err := source.ClientTLSConfig(identity.AuthorizeAny()); !errors.Is(err, identity.ErrNoTrustRoots) {
+		t.Errorf("Expected ClientTLSConfig to raise %v, got %v", identity.ErrNoTrustRoots, err)
+	}
+}
+
+// Verify that certificates are loaded from files, with an optional blindfolded private key.
+func TestFetchers(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issuePEM(t, "spiffe://test/workload", time.Now().Add(-time.Second), time.Hour)
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	sealedKeyFile := filepath.Join(dir, "tls.key.sealed")
+	for name, data := range map[string][]byte{
+		certFile:      certPEM,
+		keyFile:       keyPEM,
+		sealedKeyFile: []byte(base64.StdEncoding.EncodeToString(keyPEM) + "\n"),
+	} {
+		if err := os.WriteFile(name, data, 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	tests := []struct {
+		name        string
+		fetch       identity.Fetcher
+		expectError bool
+	}{
+		{
+			name:  "file",
+			fetch: identity.FileFetcher(certFile, keyFile),
+		},
+		{
+			name:  "sealed-key",
+			fetch: identity.SealedKeyFetcher(testClient{}, certFile, sealedKeyFile),
+		},
+		{
+			name:        "missing-file",
+			fetch:       identity.FileFetcher(certFile, filepath.Join(dir, "missing")),
+			expectError: true,
+		},
+		{
+			name:        "unsealed-key",
+			fetch:       identity.SealedKeyFetcher(testClient{}, certFile, keyFile),
+			expectError: true,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			cert, err := tst.fetch(context.Background())
+			switch {
+			case tst.expectError && err == nil:
+				t.Errorf("Expected fetch to fail")
+			case !tst.expectError && err != nil:
+				t.Errorf("Fetch raised an unexpected error: %v", err)
+			case !tst.expectError && identity.IDs(cert.Leaf)[0] != "spiffe://test/workload":
+				t.Errorf("Unexpected identities %v", identity.IDs(cert.Leaf))
+			}
+		})
+	}
+}
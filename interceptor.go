@@ -0,0 +1,66 @@
+package f5xc
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// RequestInterceptor is called with each API request made by a client from [NewClient] after the client has added its
+// headers and credentials, and before the request is sent, e.g. to audit or sign the request, or to change its
+// headers. The request may be changed, but its body must not be read unless it is replaced. An error fails the request.
+type RequestInterceptor func(req *http.Request) error
+
+// ResponseInterceptor is called with each response to an API request made by a client from [NewClient] before the
+// response is returned to the caller, e.g. to audit the response or change its headers. An error fails the request
+// after the response body has been closed.
+type ResponseInterceptor func(resp *http.Response) error
+
+// Add an interceptor that is called with every request sent by the client; interceptors are called in the order they
+// were added. Interceptors are called for every attempt of a request, including retries, after the client has added
+// its headers and credentials.
+func WithRequestInterceptor(interceptor RequestInterceptor) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding request interceptor")
+		if interceptor == nil {
+			return fmt.Errorf("request interceptor must not be nil: %w", ErrInvalidOption)
+		}
+		c.requestInterceptors = append(c.requestInterceptors, interceptor)
+		return nil
+	}
+}
+
+// Add an interceptor that is called with every response received by the client; interceptors are called in the order
+// they were added. Interceptors are called for the response to every attempt of a request, including retries, before
+// the response is checked by the retry policy, circuit breaker, or disk cache.
+func WithResponseInterceptor(interceptor ResponseInterceptor) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding response interceptor")
+		if interceptor == nil {
+			return fmt.Errorf("response interceptor must not be nil: %w", ErrInvalidOption)
+		}
+		c.responseInterceptors = append(c.responseInterceptors, interceptor)
+		return nil
+	}
+}
+
+// Calls the request interceptors of the transport in order, stopping at the first error.
+func (t *transport) interceptRequest(req *http.Request) error {
+	for _, interceptor := range t.requestInterceptors {
+		if err := interceptor(req); err != nil {
+			return fmt.Errorf("request interceptor failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// Calls the response interceptors of the transport in order, stopping at the first error, which closes the response
+// body.
+func (t *transport) interceptResponse(resp *http.Response) error {
+	for _, interceptor := range t.responseInterceptors {
+		if err := interceptor(resp); err != nil {
+			_ = resp.Body.Close()
+			return fmt.Errorf("response interceptor failed: %w", err)
+		}
+	}
+	return nil
+}
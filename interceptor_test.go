@@ -0,0 +1,100 @@
+package f5xc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/memes/f5xc"
+)
+
+// Verify that request and response interceptors are called in order with every request, and that an interceptor
+// error fails the request.
+func TestWithInterceptors(t *testing.T) {
+	t.Parallel()
+	errRejected := errors.New("rejected by interceptor")
+	var calls []string
+	client, serverURL := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Signature", r.Header.Get("X-Signature"))
+		w.WriteHeader(http.StatusNoContent)
+	}),
+		f5xc.WithRequestInterceptor(func(req *http.Request) error {
+			calls = append(calls, "request-1")
+			if req.Header.Get("Authorization") == "" {
+				t.Error("Expected request interceptor to be called after credentials are added")
+			}
+			if req.URL.Query().Get("reject") == "request" {
+				return errRejected
+			}
+			req.Header.Set("X-Signature", "signed")
+			return nil
+		}),
+		f5xc.WithRequestInterceptor(func(_ *http.Request) error {
+			calls = append(calls, "request-2")
+			return nil
+		}),
+		f5xc.WithResponseInterceptor(func(resp *http.Response) error {
+			calls = append(calls, "response-1")
+			if resp.Request.URL.Query().Get("reject") == "response" {
+				return errRejected
+			}
+			resp.Header.Set("X-Audited", "true")
+			return nil
+		}),
+	)
+	tests := []struct {
+		name          string
+		query         string
+		expectedCalls []string
+		expectedErr   error
+	}{
+		{
+			name:          "accepted",
+			expectedCalls: []string{"request-1", "request-2", "response-1"},
+		},
+		{
+			name:          "request-rejected",
+			query:         "?reject=request",
+			expectedCalls: []string{"request-1"},
+			expectedErr:   errRejected,
+		},
+		{
+			name:          "response-rejected",
+			query:         "?reject=response",
+			expectedCalls: []string{"request-1", "request-2", "response-1"},
+			expectedErr:   errRejected,
+		},
+	}
+	for _, tst := range tests {
+		calls = nil
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, serverURL+"/api/test"+tst.query, nil)
+		if err != nil {
+			t.Fatalf("%s: failed to create request: %v", tst.name, err)
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		switch {
+		case tst.expectedErr != nil:
+			if !errors.Is(err, tst.expectedErr) {
+				t.Errorf("%s: expected request to raise %v, got %v", tst.name, tst.expectedErr, err)
+			}
+		case err != nil:
+			t.Errorf("%s: request raised an unexpected error: %v", tst.name, err)
+		case resp.Header.Get("X-Signature") != "signed" || resp.Header.Get("X-Audited") != "true":
+			t.Errorf("%s: expected signed and audited response, got headers %v", tst.name, resp.Header)
+		}
+		if !slices.Equal(calls, tst.expectedCalls) {
+			t.Errorf("%s: expected interceptor calls %v, got %v", tst.name, tst.expectedCalls, calls)
+		}
+	}
+	if _, err := f5xc.NewClient(f5xc.WithAPIEndpoint(serverURL+"/api"), f5xc.WithAuthToken("test-token"), f5xc.WithRequestInterceptor(nil)); !errors.Is(err, f5xc.ErrInvalidOption) {
+		t.Errorf("Expected NewClient to raise %v for a nil request interceptor, got %v", f5xc.ErrInvalidOption, err)
+	}
+	if _, err := f5xc.NewClient(f5xc.WithAPIEndpoint(serverURL+"/api"), f5xc.WithAuthToken("test-token"), f5xc.WithResponseInterceptor(nil)); !errors.Is(err, f5xc.ErrInvalidOption) {
+		t.Errorf("Expected NewClient to raise %v for a nil response interceptor, got %v", f5xc.ErrInvalidOption, err)
+	}
+}
@@ -0,0 +1,32 @@
+package bufpool_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/memes/f5xc/internal/bufpool"
+)
+
+// Verify that buffers from the pool are empty, and that oversized and nil buffers are not returned to the pool.
+func TestGetPut(t *testing.T) {
+	t.Parallel()
+	buf := bufpool.Get()
+	if buf.Len() != 0 {
+		t.Errorf("Expected an empty buffer, got %d bytes", buf.Len())
+	}
+	buf.WriteString("data")
+	bufpool.Put(buf)
+	if buf.Len() != 0 {
+		t.Errorf("Expected Put to reset the buffer, got %d bytes", buf.Len())
+	}
+	large := bytes.NewBuffer(make([]byte, 0, bufpool.MaxSize+1))
+	large.WriteString("data")
+	bufpool.Put(large)
+	if large.Len() != 4 {
+		t.Errorf("Expected an oversized buffer to be left unchanged, got %d bytes", large.Len())
+	}
+	bufpool.Put(nil)
+	if buf := bufpool.Get(); buf.Len() != 0 {
+		t.Errorf("Expected an empty buffer, got %d bytes", buf.Len())
+	}
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/memes/f5xc"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// The environment variable that holds the passphrase of a PKCS#12 bundle.
+	EnvP12Password = "VES_P12_PASSWORD"
+	// The environment variable that provides the default value of the api-cache-dir flag.
+	EnvAPICacheDir = "F5XC_API_CACHE_DIR"
+	// The default time that public keys and policy documents are cached when an API cache directory is set.
+	DefaultAPICacheTTL = time.Hour
+	// The default time allowed for each API request.
+	DefaultAPITimeout = 30 * time.Second
+	// A warning is logged when the client certificate expires within this time.
+	CertExpiryWarning = 30 * 24 * time.Hour
+)
+
+// Defines the F5 Distributed Cloud API client settings that are shared by every subcommand that calls the API.
+type clientConfig struct {
+	apiURL      string
+	apiToken    string
+	p12Bundle   string
+	p12Password string
+	cert        string
+	key         string
+	caCert      string
+	apiCacheDir string
+	apiCacheTTL time.Duration
+	apiTimeout  time.Duration
+	apiProxy    string
+}
+
+// Returns the environment variable that provides the default value for each client flag; these are the same variables
+// that are used by vesctl.
+func clientEnvNames() map[string]string {
+	return map[string]string{
+		"api-url":    "VOLT_API_URL",
+		"api-token":  "VOLTERRA_TOKEN",
+		"p12-bundle": "VOLT_API_P12_FILE",
+		"cert":       "VOLT_API_CERT",
+		"key":        "VOLT_API_KEY",
+		"ca-cert":    "VOLT_API_CA_CERT",
+	}
+}
+
+// Adds the client flags to the flag set.
+func (c *clientConfig) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&c.apiURL, "api-url", "", "The F5 Distributed Cloud API URL, e.g. https://tenant.console.ves.volterra.io/api")
+	flags.StringVar(&c.apiToken, "api-token", "", "Authenticate with this API token")
+	flags.StringVar(&c.p12Bundle, "p12-bundle", "", "Authenticate with the certificate in this PKCS#12 bundle; the passphrase is read from "+EnvP12Password)
+	flags.StringVar(&c.cert, "cert", "", "Authenticate with the certificate in this PEM file; requires --key")
+	flags.StringVar(&c.key, "key", "", "The PEM file containing the private key of --cert")
+	flags.StringVar(&c.caCert, "ca-cert", "", "Trust the CA certificate in this PEM file in addition to the system CA certificates")
+	flags.StringVar(&c.apiCacheDir, "api-cache-dir", "", "Cache public keys and policy documents from the API in this directory; defaults to "+EnvAPICacheDir)
+	flags.DurationVar(&c.apiCacheTTL, "api-cache-ttl", DefaultAPICacheTTL, "The time that cached public keys and policy documents are used without calling the API")
+	flags.StringVar(&c.apiProxy, "api-proxy", "", "Send API requests through the proxy at this URL instead of a proxy from HTTPS_PROXY")
+	flags.DurationVar(&c.apiTimeout, "api-timeout", DefaultAPITimeout, "The maximum time to wait for each API request; 0 to wait indefinitely")
+}
+
+// Sets each client setting that was not given on the command line from the matching vesctl environment variable, and
+// returns an error if the settings are inconsistent.
+func (c *clientConfig) complete(flags *pflag.FlagSet, getenv func(string) string) error {
+	for name, env := range clientEnvNames() {
+		if flags.Changed(name) {
+			continue
+		}
+		if value := getenv(env); value != "" {
+			if err := flags.Set(name, value); err != nil {
+				return fmt.Errorf("invalid value for %s from %s: %w", name, env, err)
+			}
+		}
+	}
+	if value := getenv(EnvAPICacheDir); value != "" && !flags.Changed("api-cache-dir") {
+		c.apiCacheDir = value
+	}
+	c.p12Password = getenv(EnvP12Password)
+	if (c.cert == "") != (c.key == "") {
+		return fmt.Errorf("a certificate and key must be provided together: %w", ErrInvalidArguments)
+	}
+	return nil
+}
+
+// Returns an F5 Distributed Cloud API client that is configured from the settings.
+func (c *clientConfig) newClient() (*http.Client, error) {
+	options := []f5xc.Option{
+		f5xc.WithAPIEndpoint(c.apiURL),
+		f5xc.WithCertificateExpiryWarning(CertExpiryWarning, nil),
+	}
+	if c.caCert != "" {
+		options = append(options, f5xc.WithCACert(c.caCert))
+	}
+	if c.apiCacheDir != "" {
+		options = append(options, f5xc.WithDiskCache(c.apiCacheDir, c.apiCacheTTL))
+	}
+	if c.apiProxy != "" {
+		options = append(options, f5xc.WithProxyURL(c.apiProxy))
+	}
+	if c.apiTimeout > 0 {
+		options = append(options, f5xc.WithRequestTimeout(c.apiTimeout))
+	}
+	options = append(options, f5xc.WithCredentials(f5xc.Credentials{
+		APIToken:      c.apiToken,
+		P12Bundle:     c.p12Bundle,
+		P12Passphrase: c.p12Password,
+		Cert:          c.cert,
+		Key:           c.key,
+	}))
+	client, err := f5xc.NewClient(options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	return client, nil
+}
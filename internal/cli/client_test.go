@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// Verify that the API cache directory is read from the environment unless it is given as a flag, and that a client
+// with a cache directory retrieves the public key from the API once.
+func TestClientConfig_APICache(t *testing.T) {
+	t.Parallel()
+	var keyQuery string
+	var requests atomic.Int32
+	handler := testAPIHandler(t, &keyQuery)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	caCert := testCACert(t, server)
+	dir := t.TempDir()
+	getenv := func(name string) string {
+		switch name {
+		case EnvAPICacheDir:
+			return dir
+		case "VOLT_API_URL":
+			return server.URL + "/api"
+		case "VOLTERRA_TOKEN":
+			return "test-token"
+		case "VOLT_API_CA_CERT":
+			return caCert
+		}
+		return ""
+	}
+	cfg := &clientConfig{}
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	cfg.addFlags(flags)
+	if err := cfg.complete(flags, getenv); err != nil {
+		t.Fatalf("complete raised an unexpected error: %v", err)
+	}
+	if cfg.apiCacheDir != dir || cfg.apiCacheTTL != DefaultAPICacheTTL {
+		t.Errorf("Expected API cache %s for %v, got %s for %v", dir, DefaultAPICacheTTL, cfg.apiCacheDir, cfg.apiCacheTTL)
+	}
+	client, err := cfg.newClient()
+	if err != nil {
+		t.Fatalf("newClient raised an unexpected error: %v", err)
+	}
+	t.Cleanup(client.CloseIdleConnections)
+	for range 2 {
+		if _, err := fetchPublicKey(context.Background(), client, 0); err != nil {
+			t.Errorf("fetchPublicKey raised an unexpected error: %v", err)
+		}
+	}
+	if count := requests.Load(); count != 1 {
+		t.Errorf("Expected 1 API request, got %d", count)
+	}
+
+	flagCfg := &clientConfig{}
+	flags = pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flagCfg.addFlags(flags)
+	if err := flags.Parse([]string{"--api-cache-dir", "flag-dir"}); err != nil {
+		t.Fatalf("Parse raised an unexpected error: %v", err)
+	}
+	if err := flagCfg.complete(flags, getenv); err != nil {
+		t.Fatalf("complete raised an unexpected error: %v", err)
+	}
+	if flagCfg.apiCacheDir != "flag-dir" {
+		t.Errorf("Expected API cache flag-dir, got %s", flagCfg.apiCacheDir)
+	}
+}
@@ -0,0 +1,236 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/memes/f5xc"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// The sealed data is a JSON envelope that describes the key version and policy.
+	sealedFormatJSON = "json"
+	// The sealed data has a binary header of a length prefixed policy ID followed by the key version.
+	sealedFormatBinary = "binary"
+	// The sealed data does not have a recognized header.
+	sealedFormatOpaque = "opaque"
+	// The longest policy ID that will be accepted in a binary header.
+	maxPolicyIDLength = 256
+)
+
+// ErrInvalidSealedData is returned when a sealed value is not valid base64 encoded data.
+var ErrInvalidSealedData = errors.New("invalid sealed data")
+
+// Describes a sealed value. The key version, policy ID, tenant, and algorithm are only known if the sealed data has a
+// recognized header.
+type sealedInfo struct {
+	// The recognized format of the decoded sealed data; one of json, binary, or opaque.
+	Format     string `json:"format" yaml:"format"`
+	KeyVersion int    `json:"keyVersion,omitempty" yaml:"keyVersion,omitempty"`
+	PolicyID   string `json:"policyId,omitempty" yaml:"policyId,omitempty"`
+	Tenant     string `json:"tenant,omitempty" yaml:"tenant,omitempty"`
+	Algorithm  string `json:"algorithm,omitempty" yaml:"algorithm,omitempty"`
+	// The length of the base64 encoded sealed value.
+	EncodedSize int `json:"encodedSize" yaml:"encodedSize"`
+	// The length of the decoded sealed value.
+	Size int `json:"size" yaml:"size"`
+}
+
+// Summarizes a secret policy document, with a readable description of each rule.
+type policySummary struct {
+	Name      string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Namespace string   `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Tenant    string   `json:"tenant,omitempty" yaml:"tenant,omitempty"`
+	PolicyID  string   `json:"policyId" yaml:"policyId"`
+	Algorithm string   `json:"algorithm" yaml:"algorithm"`
+	Rules     []string `json:"rules" yaml:"rules"`
+}
+
+// The output of the inspect command.
+type inspection struct {
+	Sealed *sealedInfo    `json:"sealed,omitempty" yaml:"sealed,omitempty"`
+	Policy *policySummary `json:"policy,omitempty" yaml:"policy,omitempty"`
+	// Problems that are likely to cause Wingman to deny an unseal request.
+	Warnings []string `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+}
+
+// Returns the base64 encoded sealed value from source, which may be "-" to read from stdin, the path to a file
+// containing the sealed value, or the sealed value itself. Surrounding whitespace is removed.
+func readSealedValue(source string, stdin io.Reader) ([]byte, error) {
+	var data []byte
+	var err error
+	if source == stdinInput {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(source)
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+			// Not a file; treat the value as inline sealed data
+			data, err = []byte(source), nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sealed value from %s: %w", source, err)
+	}
+	return bytes.TrimSpace(data), nil
+}
+
+// Returns a description of the base64 encoded sealed value, including the header fields if the format is recognized.
+func inspectSealed(sealed []byte) (*sealedInfo, error) {
+	decoded, err := base64.StdEncoding.DecodeString(string(sealed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sealed value: %w: %w", err, ErrInvalidSealedData)
+	}
+	info := &sealedInfo{
+		Format:      sealedFormatOpaque,
+		EncodedSize: len(sealed),
+		Size:        len(decoded),
+	}
+	var envelope struct {
+		KeyVersion int    `json:"key_version"`
+		PolicyID   string `json:"policy_id"`
+		Tenant     string `json:"tenant"`
+		Algo       string `json:"algo"`
+	}
+	switch {
+	case json.Unmarshal(decoded, &envelope) == nil && (envelope.PolicyID != "" || envelope.KeyVersion != 0):
+		info.Format = sealedFormatJSON
+		info.KeyVersion = envelope.KeyVersion
+		info.PolicyID = envelope.PolicyID
+		info.Tenant = envelope.Tenant
+		info.Algorithm = envelope.Algo
+	case len(decoded) > 8:
+		length := binary.BigEndian.Uint32(decoded)
+		if length == 0 || length > maxPolicyIDLength || uint64(len(decoded)) < 8+uint64(length) {
+			break
+		}
+		policyID := string(decoded[4 : 4+length])
+		if strings.IndexFunc(policyID, func(r rune) bool { return !unicode.IsPrint(r) }) >= 0 {
+			break
+		}
+		info.Format = sealedFormatBinary
+		info.PolicyID = policyID
+		info.KeyVersion = int(binary.BigEndian.Uint32(decoded[4+length:])) //nolint:gosec // Key versions are small integers
+	}
+	return info, nil
+}
+
+// Returns a readable description of the secret policy document and its rules.
+func summarizePolicy(policyDoc *f5xc.SecretPolicyDocument) *policySummary {
+	summary := &policySummary{
+		PolicyID:  policyDoc.PolicyID,
+		Algorithm: policyDoc.PolicyInfo.Algo,
+		Rules:     make([]string, 0, len(policyDoc.PolicyInfo.Rules)),
+	}
+	if policyDoc.Metadata != nil {
+		summary.Name = policyDoc.Name
+		summary.Namespace = policyDoc.Namespace
+		summary.Tenant = policyDoc.Tenant
+	}
+	for _, rule := range policyDoc.PolicyInfo.Rules {
+		summary.Rules = append(summary.Rules, summarizeRule(rule))
+	}
+	return summary
+}
+
+// Returns a readable description of a secret policy rule, e.g. "ALLOW client name is app-1".
+func summarizeRule(rule f5xc.SecretPolicyRule) string {
+	var conditions []string
+	if rule.ClientName != "" {
+		conditions = append(conditions, "client name is "+rule.ClientName)
+	}
+	if matcher := rule.ClientNameMatcher; matcher != nil {
+		if len(matcher.ExactValues) > 0 {
+			conditions = append(conditions, "client name is one of ["+strings.Join(matcher.ExactValues, ", ")+"]")
+		}
+		if len(matcher.RegexValues) > 0 {
+			conditions = append(conditions, "client name matches one of ["+strings.Join(matcher.RegexValues, ", ")+"]")
+		}
+	}
+	if selector := rule.ClientSelector; selector != nil && len(selector.Expressions) > 0 {
+		conditions = append(conditions, "client labels match ["+strings.Join(selector.Expressions, ", ")+"]")
+	}
+	if len(conditions) == 0 {
+		return rule.Action + " any client"
+	}
+	return rule.Action + " " + strings.Join(conditions, " and ")
+}
+
+// Returns warnings for differences between the sealed value and the policy that is expected to allow it to be
+// unsealed.
+func compareSealed(info *sealedInfo, policy *policySummary) []string {
+	if info == nil || policy == nil {
+		return nil
+	}
+	var warnings []string
+	if info.PolicyID != "" && info.PolicyID != policy.PolicyID {
+		warnings = append(warnings, fmt.Sprintf("sealed with policy ID %s, but the policy has ID %s", info.PolicyID, policy.PolicyID))
+	}
+	if info.Tenant != "" && policy.Tenant != "" && info.Tenant != policy.Tenant {
+		warnings = append(warnings, fmt.Sprintf("sealed for tenant %s, but the policy belongs to tenant %s", info.Tenant, policy.Tenant))
+	}
+	if len(policy.Rules) == 0 {
+		warnings = append(warnings, "the policy has no rules, so no client will be allowed to unseal")
+	}
+	return warnings
+}
+
+// Returns the inspect command.
+func newInspectCommand(cfg *clientConfig, getenv func(string) string) *cobra.Command {
+	var policy, namespace, output string
+	cmd := &cobra.Command{
+		Use:   "inspect [--policy NAME] [FILE_OR_B64]",
+		Short: "Describe a sealed value or secret policy",
+		Long: `Describe a sealed value, including the key version and policy ID when the sealed data has a recognized header;
+the argument may be a file containing the base64 encoded sealed value, - to read it from standard input, or the
+base64 encoded sealed value itself. When --policy is given the policy document is retrieved from the API and
+summarized with a description of each rule, and any difference between the policy and the sealed value that would
+cause Wingman to deny the unseal request is reported as a warning.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && policy == "" {
+				return fmt.Errorf("a sealed value or policy must be provided: %w", ErrInvalidArguments)
+			}
+			result := &inspection{}
+			if len(args) > 0 {
+				sealed, err := readSealedValue(args[0], cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				if result.Sealed, err = inspectSealed(sealed); err != nil {
+					return err
+				}
+			}
+			if policy != "" {
+				if err := cfg.complete(cmd.Flags(), getenv); err != nil {
+					return err
+				}
+				client, err := cfg.newClient()
+				if err != nil {
+					return err
+				}
+				defer client.CloseIdleConnections()
+				policyDoc, err := fetchPolicyDocument(cmd.Context(), client, namespace, policy)
+				if err != nil {
+					return err
+				}
+				result.Policy = summarizePolicy(policyDoc)
+				result.Warnings = compareSealed(result.Sealed, result.Policy)
+			}
+			return writeObject(cmd.OutOrStdout(), output, result)
+		},
+	}
+	cmd.Flags().StringVar(&policy, "policy", "", "Retrieve and summarize this secret policy, comparing it with the sealed value")
+	cmd.Flags().StringVar(&namespace, "namespace", DefaultNamespace, "The namespace of the secret policy")
+	cmd.Flags().StringVar(&output, "output", outputYAML, "The output format; one of yaml or json")
+	return cmd
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/memes/f5xc"
+)
+
+// Returns base64 encoded sealed data with a binary header for the policy ID and key version.
+func testSealedHeader(policyID string, keyVersion uint32) string {
+	data := binary.BigEndian.AppendUint32(nil, uint32(len(policyID))) //nolint:gosec // Test policy IDs are short
+	data = append(data, policyID...)
+	data = binary.BigEndian.AppendUint32(data, keyVersion)
+	data = append(data, "ciphertext"...)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestInspectSealed(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		sealed      string
+		expected    sealedInfo
+		expectedErr error
+	}{
+		{
+			name:     "json",
+			sealed:   base64.StdEncoding.EncodeToString([]byte(`{"key_version":3,"policy_id":"policy-1","tenant":"test","algo":"RSA"}`)),
+			expected: sealedInfo{Format: sealedFormatJSON, KeyVersion: 3, PolicyID: "policy-1", Tenant: "test", Algorithm: "RSA", EncodedSize: 92, Size: 69},
+		},
+		{
+			name:     "binary",
+			sealed:   testSealedHeader("policy-1", 2),
+			expected: sealedInfo{Format: sealedFormatBinary, KeyVersion: 2, PolicyID: "policy-1", EncodedSize: 36, Size: 26},
+		},
+		{
+			name: "opaque",
+			// spell-checker: disable-next-line
+			sealed:   "ZnZ6Y3lyLndmYmE=",
+			expected: sealedInfo{Format: sealedFormatOpaque, EncodedSize: 16, Size: 11},
+		},
+		{
+			name:        "invalid",
+			sealed:      "not base64!",
+			expectedErr: ErrInvalidSealedData,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			info, err := inspectSealed([]byte(tst.sealed))
+			switch {
+			case tst.expectedErr != nil:
+				if !errors.Is(err, tst.expectedErr) {
+					t.Errorf("Expected inspectSealed to raise %v, got %v", tst.expectedErr, err)
+				}
+			case err != nil:
+				t.Errorf("inspectSealed raised an unexpected error: %v", err)
+			case *info != tst.expected:
+				t.Errorf("Expected %+v, got %+v", tst.expected, *info)
+			}
+		})
+	}
+}
+
+func TestSummarizeRule(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		rule     f5xc.SecretPolicyRule
+		expected string
+	}{
+		{
+			rule:     f5xc.SecretPolicyRule{Action: "DENY"},
+			expected: "DENY any client",
+		},
+		{
+			rule: f5xc.SecretPolicyRule{
+				Action:            "ALLOW",
+				ClientNameMatcher: &f5xc.MatcherType{ExactValues: []string{"a", "b"}, RegexValues: []string{"^app-.*$"}},
+			},
+			expected: "ALLOW client name is one of [a, b] and client name matches one of [^app-.*$]",
+		},
+		{
+			rule: f5xc.SecretPolicyRule{
+				Action:         "ALLOW",
+				ClientSelector: &f5xc.LabelSelectorType{Expressions: []string{"app in (web)"}},
+			},
+			expected: "ALLOW client labels match [app in (web)]",
+		},
+	}
+	for _, tst := range tests {
+		if summary := summarizeRule(tst.rule); summary != tst.expected {
+			t.Errorf("Expected %q, got %q", tst.expected, summary)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/blindfold"
+	"github.com/spf13/cobra"
+)
+
+// ErrPublicKeyNotFound is returned when the API does not have the requested public key.
+var ErrPublicKeyNotFound = errors.New("public key not found")
+
+// Retrieves the public key from the API, returning [ErrPublicKeyNotFound] if it does not exist. The current version of
+// the key is returned if version is 0.
+func fetchPublicKey(ctx context.Context, client *http.Client, version int) (*f5xc.PublicKey, error) {
+	var keyVersion *int
+	if version > 0 {
+		keyVersion = &version
+	}
+	pubKey, err := f5xc.GetPublicKey(ctx, client, keyVersion)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("failed to retrieve public key: %w", err)
+	case pubKey == nil:
+		return nil, ErrPublicKeyNotFound
+	}
+	return pubKey, nil
+}
+
+// Returns the key command and its subcommands.
+func newKeyCommand(cfg *clientConfig, getenv func(string) string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "key",
+		Aliases: []string{"keys"},
+		Short:   "Retrieve the tenant public key",
+	}
+	var version int
+	var output string
+	get := &cobra.Command{
+		Use:   "get",
+		Short: "Print the public key used to seal data",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if version < 0 {
+				return fmt.Errorf("key version must not be negative: %w", ErrInvalidArguments)
+			}
+			if err := cfg.complete(cmd.Flags(), getenv); err != nil {
+				return err
+			}
+			client, err := cfg.newClient()
+			if err != nil {
+				return err
+			}
+			defer client.CloseIdleConnections()
+			pubKey, err := fetchPublicKey(cmd.Context(), client, version)
+			if err != nil {
+				return err
+			}
+			return writeObject(cmd.OutOrStdout(), output, pubKey)
+		},
+	}
+	get.Flags().IntVar(&version, "key-version", 0, "The version of the public key to print; 0 to print the current version")
+	get.Flags().StringVar(&output, "output", outputYAML, "The output format; one of yaml or json")
+	cmd.AddCommand(get, newKeyPullCommand(cfg, getenv))
+	return cmd
+}
+
+// Defines the options of the key pull command.
+type pullOptions struct {
+	dir       string
+	versions  []int
+	policies  []string
+	namespace string
+}
+
+// Returns the key pull command, which writes public keys and policy documents to a cache directory for offline
+// sealing.
+func newKeyPullCommand(cfg *clientConfig, getenv func(string) string) *cobra.Command {
+	opts := &pullOptions{}
+	cmd := &cobra.Command{
+		Use:   "pull --dir DIR [--key-version N]... [--policy [NAMESPACE/]NAME]...",
+		Short: "Download public keys and policy documents to a cache directory for offline sealing",
+		Long: `Download the current public key, any additional versions given by --key-version, and the secret policy documents
+given by --policy, and write them as envelope YAML to the cache directory. The files can be passed directly to vesctl,
+or used by f5xc seal --cache-dir to seal without calling the API.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := cfg.complete(cmd.Flags(), getenv); err != nil {
+				return err
+			}
+			client, err := cfg.newClient()
+			if err != nil {
+				return err
+			}
+			defer client.CloseIdleConnections()
+			return opts.pull(cmd.Context(), client)
+		},
+	}
+	cmd.Flags().StringVar(&opts.dir, "dir", "", "The cache directory to write to")
+	cmd.Flags().IntSliceVar(&opts.versions, "key-version", nil, "An additional version of the public key to download; may be repeated")
+	cmd.Flags().StringArrayVar(&opts.policies, "policy", nil, "A secret policy to download, as NAME or NAMESPACE/NAME; may be repeated")
+	cmd.Flags().StringVar(&opts.namespace, "namespace", DefaultNamespace, "The namespace of policies that are given without one")
+	_ = cmd.MarkFlagRequired("dir")
+	return cmd
+}
+
+// Downloads the public keys and policy documents, writing each to the cache directory.
+func (o *pullOptions) pull(ctx context.Context, client *http.Client) error {
+	current, err := fetchPublicKey(ctx, client, 0)
+	if err != nil {
+		return err
+	}
+	if err := blindfold.WriteCachedPublicKey(o.dir, current, true); err != nil {
+		return fmt.Errorf("failed to cache public key: %w", err)
+	}
+	slog.Info("Cached current public key", "keyVersion", current.KeyVersion, "fingerprint", keyFingerprint(current))
+	for _, version := range o.versions {
+		if version <= 0 {
+			return fmt.Errorf("key version must be greater than zero: %w", ErrInvalidArguments)
+		}
+		pubKey, err := fetchPublicKey(ctx, client, version)
+		if err != nil {
+			return err
+		}
+		if err := blindfold.WriteCachedPublicKey(o.dir, pubKey, false); err != nil {
+			return fmt.Errorf("failed to cache public key: %w", err)
+		}
+		slog.Info("Cached public key", "keyVersion", pubKey.KeyVersion, "fingerprint", keyFingerprint(pubKey))
+	}
+	for _, policy := range o.policies {
+		namespace, name, ok := strings.Cut(policy, "/")
+		if !ok {
+			namespace, name = o.namespace, policy
+		}
+		if namespace == "" || name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("policy %q must be NAME or NAMESPACE/NAME: %w", policy, ErrInvalidArguments)
+		}
+		policyDoc, err := fetchPolicyDocument(ctx, client, namespace, name)
+		if err != nil {
+			return err
+		}
+		if err := blindfold.WriteCachedPolicyDocument(o.dir, namespace, name, policyDoc); err != nil {
+			return fmt.Errorf("failed to cache secret policy document: %w", err)
+		}
+		slog.Info("Cached secret policy document", "namespace", namespace, "name", name, "policyID", policyDoc.PolicyID)
+	}
+	return nil
+}
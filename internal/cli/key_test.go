@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/memes/f5xc/blindfold"
+)
+
+// Verify that keys pull writes the public key and policy documents to the cache directory, and that a sealer can be
+// created from the cache without the API.
+func TestKeyPull(t *testing.T) {
+	t.Parallel()
+	var keyQuery string
+	server := httptest.NewTLSServer(testAPIHandler(t, &keyQuery))
+	t.Cleanup(server.Close)
+	dir := t.TempDir()
+	cmd := newRootCommand("", func(string) string { return "" })
+	cmd.SetArgs([]string{
+		"--api-url", server.URL + "/api", "--api-token", "test-token", "--ca-cert", testCACert(t, server),
+		"keys", "pull", "--dir", dir, "--key-version", "2", "--policy", "shared/test",
+	})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute raised an unexpected error: %v", err)
+	}
+	if keyQuery != "key_version=2" {
+		t.Errorf("Expected public key version 2 to be requested, got query %q", keyQuery)
+	}
+	if pubKey, err := blindfold.ReadCachedPublicKey(dir, 0); err != nil || pubKey.KeyVersion != 2 {
+		t.Errorf("Expected current public key to be cached, got %v: %v", pubKey, err)
+	}
+	if _, err := newCachedSealer(&sealOptions{cacheDir: dir, policy: "test", namespace: DefaultNamespace}); err != nil {
+		t.Errorf("newCachedSealer raised an unexpected error: %v", err)
+	}
+	if _, err := newCachedSealer(&sealOptions{cacheDir: dir, policy: "missing", namespace: DefaultNamespace}); !errors.Is(err, blindfold.ErrNotCached) {
+		t.Errorf("Expected newCachedSealer to raise %v, got %v", blindfold.ErrNotCached, err)
+	}
+}
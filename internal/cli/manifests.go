@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// The annotation that requests the values of a Secret be sealed; either true to seal every value, or a
+	// comma-separated list of the keys to seal.
+	annotationSeal = "seal.f5xc/seal"
+	// The annotation added to a Secret once its values have been sealed, recording the secret policy.
+	annotationSealed = "seal.f5xc/sealed"
+	// The kind of a KRM function input and output.
+	resourceListKind = "ResourceList"
+)
+
+// The Kubernetes manifests read in manifests mode; either a stream of YAML documents, as given to a Helm post-renderer,
+// or the items of a KRM function ResourceList.
+type manifests struct {
+	// The ResourceList that contained the items, or nil if the manifests were a stream of documents.
+	resourceList map[string]any
+	// The manifests to transform.
+	items []map[string]any
+}
+
+// Reads a stream of YAML documents; if the stream is a single ResourceList its items are returned as the manifests.
+func readManifests(r io.Reader) (*manifests, error) {
+	decoder := yaml.NewDecoder(r)
+	m := &manifests{}
+	for {
+		var doc map[string]any
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse manifests: %w: %w", err, ErrInvalidInput)
+		}
+		if doc != nil {
+			m.items = append(m.items, doc)
+		}
+	}
+	if len(m.items) != 1 || m.items[0]["kind"] != resourceListKind {
+		return m, nil
+	}
+	m.resourceList = m.items[0]
+	items, ok := m.resourceList["items"].([]any)
+	if !ok && m.resourceList["items"] != nil {
+		return nil, fmt.Errorf("resource list items must be a list: %w", ErrInvalidInput)
+	}
+	m.items = make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("resource list items must be objects: %w", ErrInvalidInput)
+		}
+		m.items = append(m.items, obj)
+	}
+	return m, nil
+}
+
+// Returns the data of the ResourceList functionConfig, which is expected to be a ConfigMap, or nil.
+func (m *manifests) functionConfig() map[string]any {
+	config, _ := m.resourceList["functionConfig"].(map[string]any)
+	data, _ := config["data"].(map[string]any)
+	return data
+}
+
+// Writes the manifests as a stream of YAML documents, or as a ResourceList if they were read from one.
+func (m *manifests) write(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	docs := make([]any, 0, len(m.items))
+	for _, item := range m.items {
+		docs = append(docs, item)
+	}
+	if m.resourceList != nil {
+		m.resourceList["items"] = docs
+		docs = []any{m.resourceList}
+	}
+	for _, doc := range docs {
+		if err := encoder.Encode(doc); err != nil {
+			return fmt.Errorf("failed to write manifests: %w", err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to write manifests: %w", err)
+	}
+	return nil
+}
+
+// Sets the seal options from the KRM functionConfig data for each flag that was not given on the command line, so
+// that the command can be configured when run as a KRM function without arguments.
+func (o *sealOptions) applyFunctionConfig(flags *pflag.FlagSet) error {
+	for name, value := range o.resources.functionConfig() {
+		switch name {
+		case "policy", "namespace", "key-version", "cache-dir", "vesctl":
+		default:
+			return fmt.Errorf("unknown function config setting %q: %w", name, ErrInvalidArguments)
+		}
+		if flags.Changed(name) {
+			continue
+		}
+		if err := flags.Set(name, fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("invalid function config value for %s: %w: %w", name, err, ErrInvalidArguments)
+		}
+	}
+	return nil
+}
+
+// Seals the annotated Secrets in the manifests, then writes the transformed manifests. The policy is recorded in the
+// sealed annotation of each Secret.
+func (s *sealer) sealManifests(ctx context.Context, m *manifests, policy string, stdout io.Writer) error {
+	for _, obj := range m.items {
+		if err := s.sealSecret(ctx, obj, policy); err != nil {
+			return err
+		}
+	}
+	return m.write(stdout)
+}
+
+// Seals the requested values of a Secret that has the seal annotation, replacing them with the base64 encoded sealed
+// data in stringData so that the Secret can be mounted and referenced by unseal specifications. The seal annotation is
+// replaced by the sealed annotation, and any other object is left unchanged.
+func (s *sealer) sealSecret(ctx context.Context, obj map[string]any, policy string) error {
+	if obj["kind"] != "Secret" || obj["apiVersion"] != "v1" {
+		return nil
+	}
+	metadata, _ := obj["metadata"].(map[string]any)
+	annotations, _ := metadata["annotations"].(map[string]any)
+	request, ok := annotations[annotationSeal]
+	if !ok {
+		return nil
+	}
+	name := fmt.Sprint(metadata["name"])
+	logger := slog.With("secret", name)
+	values, err := secretValues(obj)
+	if err != nil {
+		return fmt.Errorf("secret %s: %w", name, err)
+	}
+	keys, err := sealKeys(fmt.Sprint(request), values)
+	if err != nil {
+		return fmt.Errorf("secret %s: %w", name, err)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	data, _ := obj["data"].(map[string]any)
+	stringData, _ := obj["stringData"].(map[string]any)
+	if stringData == nil {
+		stringData = map[string]any{}
+	}
+	for _, key := range keys {
+		sealed, err := s.seal(ctx, values[key])
+		if err != nil {
+			return fmt.Errorf("failed to seal %s of secret %s: %w", key, name, err)
+		}
+		logger.Debug("Sealed secret value", "key", key)
+		delete(data, key)
+		stringData[key] = string(bytes.TrimSpace(sealed))
+	}
+	if data != nil && len(data) == 0 {
+		delete(obj, "data")
+	}
+	obj["stringData"] = stringData
+	delete(annotations, annotationSeal)
+	annotations[annotationSealed] = policy
+	return nil
+}
+
+// Returns the plaintext values of a Secret, keyed by name; values in stringData take precedence over data, as they do
+// when the Secret is written.
+func secretValues(obj map[string]any) (map[string][]byte, error) {
+	values := map[string][]byte{}
+	data, _ := obj["data"].(map[string]any)
+	for key, value := range data {
+		decoded, err := base64.StdEncoding.DecodeString(fmt.Sprint(value))
+		if err != nil {
+			return nil, fmt.Errorf("data %s is not valid base64: %w: %w", key, err, ErrInvalidInput)
+		}
+		values[key] = decoded
+	}
+	stringData, _ := obj["stringData"].(map[string]any)
+	for key, value := range stringData {
+		values[key] = []byte(fmt.Sprint(value))
+	}
+	return values, nil
+}
+
+// Returns the sorted keys to seal for the value of a seal annotation; every key if the value is true, or the listed
+// keys, which must be present in the Secret.
+func sealKeys(request string, values map[string][]byte) ([]string, error) {
+	if all, err := strconv.ParseBool(request); err == nil {
+		if !all {
+			return nil, nil
+		}
+		return slices.Sorted(maps.Keys(values)), nil
+	}
+	var keys []string
+	for _, key := range strings.Split(request, ",") {
+		key = strings.TrimSpace(key)
+		if _, ok := values[key]; !ok {
+			return nil, fmt.Errorf("key %q requested by %s is not present: %w", key, annotationSeal, ErrInvalidInput)
+		}
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
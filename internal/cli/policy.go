@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/memes/f5xc"
+	"github.com/spf13/cobra"
+)
+
+// The default namespace of secret policies.
+const DefaultNamespace = "shared"
+
+// ErrPolicyNotFound is returned when the API does not have the named secret policy.
+var ErrPolicyNotFound = errors.New("secret policy not found")
+
+// Retrieves the named secret policy document from the API, returning [ErrPolicyNotFound] if it does not exist.
+func fetchPolicyDocument(ctx context.Context, client *http.Client, namespace, name string) (*f5xc.SecretPolicyDocument, error) {
+	policyDoc, err := f5xc.GetSecretPolicyDocument(ctx, client, name, namespace)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("failed to retrieve secret policy document: %w", err)
+	case policyDoc == nil:
+		return nil, fmt.Errorf("%s/%s: %w", namespace, name, ErrPolicyNotFound)
+	}
+	return policyDoc, nil
+}
+
+// Returns the policy command and its subcommands.
+func newPolicyCommand(cfg *clientConfig, getenv func(string) string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policy",
+		Short: "Retrieve secret policy documents",
+	}
+	var namespace, output string
+	get := &cobra.Command{
+		Use:   "get NAME",
+		Short: "Print the named secret policy document",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cfg.complete(cmd.Flags(), getenv); err != nil {
+				return err
+			}
+			client, err := cfg.newClient()
+			if err != nil {
+				return err
+			}
+			defer client.CloseIdleConnections()
+			policyDoc, err := fetchPolicyDocument(cmd.Context(), client, namespace, args[0])
+			if err != nil {
+				return err
+			}
+			return writeObject(cmd.OutOrStdout(), output, policyDoc)
+		},
+	}
+	get.Flags().StringVar(&namespace, "namespace", DefaultNamespace, "The namespace of the secret policy")
+	get.Flags().StringVar(&output, "output", outputYAML, "The output format; one of yaml or json")
+	cmd.AddCommand(get)
+	return cmd
+}
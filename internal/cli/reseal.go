@@ -0,0 +1,346 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/memes/f5xc/blindfold"
+	"github.com/memes/f5xc/internal/unseal"
+	"github.com/memes/f5xc/wingman"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// The file extension of base64 encoded sealed data that will be resealed when walking a directory.
+const sealedExtension = ".b64"
+
+// The prefix of a sealed value in a specification that references a file containing the sealed data.
+const fileRefPrefix = "file://"
+
+// ErrNoPaths is returned when no files or directories are provided to reseal.
+var ErrNoPaths = errors.New("no files or directories provided")
+
+// Defines the options of the reseal command.
+type resealOptions struct {
+	policy     string
+	namespace  string
+	vesctl     string
+	wingmanURL string
+	dryRun     bool
+	timeout    time.Duration
+}
+
+// Unseals sealed data through Wingman and seals the plaintext again with the current public key and a target policy.
+type resealer struct {
+	unseal func(ctx context.Context, sealed []byte) ([]byte, error)
+	seal   func(ctx context.Context, plaintext []byte) ([]byte, error)
+	// If true, sealed data is resealed but files are not rewritten.
+	dryRun bool
+	// The number of sealed values that have been resealed.
+	count int
+}
+
+// Returns the reseal command, which rewrites sealed files and specifications in place with data sealed by the current
+// public key and a target policy.
+func newResealCommand(cfg *clientConfig, getenv func(string) string) *cobra.Command {
+	opts := &resealOptions{}
+	cmd := &cobra.Command{
+		Use:   "reseal --policy NAME [flags] PATH...",
+		Short: "Reseal files and specifications with the current public key and a secret policy",
+		Long: `Reseal sealed data in place after a key or policy rotation. Each PATH may be a file of base64 encoded sealed data,
+an unseal specification (.json, .yaml, or .yml), or a directory that is walked for specifications and .b64 files.
+Every sealed value is unsealed through Wingman and sealed again with the current public key and the named policy;
+file:// references in specifications are left unchanged, so reseal the referenced files directly. YAML comments and
+ordering are preserved, but JSON specifications are rewritten with sorted keys. Set --dry-run to reseal without
+rewriting any file.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch {
+			case len(args) == 0:
+				return ErrNoPaths
+			case opts.policy == "":
+				return ErrMissingPolicy
+			case opts.timeout < 0:
+				return fmt.Errorf("timeout must not be negative: %w", ErrInvalidArguments)
+			}
+			if !cmd.Flags().Changed("wingman-url") {
+				if value := getenv(unseal.EnvWingmanURL); value != "" {
+					opts.wingmanURL = value
+				}
+			}
+			if err := cfg.complete(cmd.Flags(), getenv); err != nil {
+				return err
+			}
+			return opts.run(cmd.Context(), cfg, args)
+		},
+	}
+	cmd.Flags().StringVar(&opts.policy, "policy", "", "The name of the secret policy that will be allowed to unseal the resealed data")
+	cmd.Flags().StringVar(&opts.namespace, "namespace", DefaultNamespace, "The namespace of the secret policy")
+	cmd.Flags().StringVar(&opts.vesctl, "vesctl", blindfold.VesctlExecutable, "The name or path of the vesctl executable")
+	cmd.Flags().StringVar(&opts.wingmanURL, "wingman-url", wingman.DefaultWingmanURL, "The base URL of the Wingman service that will unseal the existing data; defaults to "+unseal.EnvWingmanURL+" if set")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Reseal the data but do not rewrite any file")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 0, "The maximum time to wait for the reseal to complete; 0 to wait indefinitely")
+	return cmd
+}
+
+// Creates the API and Wingman clients, then reseals every path.
+func (o *resealOptions) run(ctx context.Context, cfg *clientConfig, paths []string) error {
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+	client, err := cfg.newClient()
+	if err != nil {
+		return err
+	}
+	defer client.CloseIdleConnections()
+	s, err := newSealer(ctx, client, &sealOptions{
+		policy:    o.policy,
+		namespace: o.namespace,
+		vesctl:    o.vesctl,
+	})
+	if err != nil {
+		return err
+	}
+	wingmanClient, err := wingman.NewClient(o.wingmanURL)
+	if err != nil {
+		return fmt.Errorf("failed to create wingman client: %w", err)
+	}
+	defer wingmanClient.Close()
+	r := &resealer{
+		unseal: wingmanClient.UnsealEncoded,
+		seal:   s.seal,
+		dryRun: o.dryRun,
+	}
+	if err := r.resealPaths(ctx, paths); err != nil {
+		return err
+	}
+	slog.Info("Resealed data", "count", r.count, "dryRun", o.dryRun)
+	return nil
+}
+
+// Returns true if the path has the extension of an unseal specification.
+func isSpecFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// Expands directories to the specifications and sealed data files they contain, at any depth. Files that are named
+// explicitly are returned as-is.
+func expandResealPaths(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(name string, d fs.DirEntry, err error) error {
+			switch {
+			case err != nil:
+				return err
+			case d.Type().IsRegular() && (isSpecFile(name) || strings.EqualFold(filepath.Ext(name), sealedExtension)):
+				files = append(files, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk %s: %w", path, err)
+		}
+	}
+	return files, nil
+}
+
+// Reseals every file found in the paths; a failure to reseal one file is logged and does not stop the remaining files
+// from being resealed.
+func (r *resealer) resealPaths(ctx context.Context, paths []string) error {
+	files, err := expandResealPaths(paths)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, path := range files {
+		if isSpecFile(path) {
+			err = r.resealSpec(ctx, path)
+		} else {
+			err = r.resealBlob(ctx, path)
+		}
+		if err != nil {
+			slog.Error("Failed to reseal", "path", path, "error", err)
+			errs = append(errs, fmt.Errorf("failed to reseal %s: %w", path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Returns the base64 encoded sealed value resealed with the current public key and target policy.
+func (r *resealer) resealValue(ctx context.Context, sealed string) (string, error) {
+	plaintext, err := r.unseal(ctx, []byte(strings.TrimSpace(sealed)))
+	if err != nil {
+		return "", fmt.Errorf("failed to unseal: %w", err)
+	}
+	resealed, err := r.seal(ctx, plaintext)
+	if err != nil {
+		return "", fmt.Errorf("failed to seal: %w", err)
+	}
+	r.count++
+	return string(bytes.TrimSpace(resealed)), nil
+}
+
+// Reseals a file containing base64 encoded sealed data, preserving a trailing newline.
+func (r *resealer) resealBlob(ctx context.Context, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	resealed, err := r.resealValue(ctx, string(data))
+	if err != nil {
+		return err
+	}
+	if bytes.HasSuffix(data, []byte("\n")) {
+		resealed += "\n"
+	}
+	return r.replaceFile(path, []byte(resealed))
+}
+
+// Reseals every inline sealed value in an unseal specification; the data of each entry and the inputs of template
+// entries.
+func (r *resealer) resealSpec(ctx context.Context, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse specification: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	spec := doc.Content[0]
+	if spec.Kind != yaml.MappingNode {
+		return fmt.Errorf("specification must be a map: %w", ErrInvalidInput)
+	}
+	for _, value := range mappingValues(spec) {
+		switch value.Kind {
+		case yaml.ScalarNode:
+			err = r.resealNode(ctx, value)
+		case yaml.MappingNode:
+			err = r.resealEntry(ctx, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	var buf bytes.Buffer
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var value any
+		if err := doc.Decode(&value); err != nil {
+			return fmt.Errorf("failed to convert specification: %w", err)
+		}
+		encoder := json.NewEncoder(&buf)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(value)
+	} else {
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err = encoder.Encode(&doc); err == nil {
+			err = encoder.Close()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode specification: %w", err)
+	}
+	return r.replaceFile(path, buf.Bytes())
+}
+
+// Reseals the data and template inputs of a specification entry that is an object.
+func (r *resealer) resealEntry(ctx context.Context, e *yaml.Node) error {
+	for i := 0; i+1 < len(e.Content); i += 2 {
+		key, value := e.Content[i], e.Content[i+1]
+		switch {
+		case key.Value == "data" && value.Kind == yaml.ScalarNode:
+			if err := r.resealNode(ctx, value); err != nil {
+				return fmt.Errorf("failed to reseal data: %w", err)
+			}
+		case key.Value == "inputs" && value.Kind == yaml.MappingNode:
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				if err := r.resealNode(ctx, value.Content[j+1]); err != nil {
+					return fmt.Errorf("failed to reseal input %s: %w", value.Content[j].Value, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Reseals the sealed value of a scalar node in place; file references are left unchanged.
+func (r *resealer) resealNode(ctx context.Context, node *yaml.Node) error {
+	if node.Value == "" || strings.HasPrefix(node.Value, fileRefPrefix) {
+		return nil
+	}
+	resealed, err := r.resealValue(ctx, node.Value)
+	if err != nil {
+		return err
+	}
+	node.Value = resealed
+	return nil
+}
+
+// Returns the value nodes of a mapping node.
+func mappingValues(node *yaml.Node) []*yaml.Node {
+	values := make([]*yaml.Node, 0, len(node.Content)/2)
+	for i := 1; i < len(node.Content); i += 2 {
+		values = append(values, node.Content[i])
+	}
+	return values
+}
+
+// Atomically replaces the content of the file at path, preserving its permissions, unless this is a dry run.
+func (r *resealer) replaceFile(path string, data []byte) error {
+	logger := slog.With("path", path)
+	if r.dryRun {
+		logger.Info("Dry run: not rewriting resealed file")
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, info.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+	logger.Debug("Rewrote resealed file")
+	return nil
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns a resealer that treats base64 encoded plaintext as sealed data, and seals by adding a prefix to the
+// plaintext before encoding it, so that resealed values can be recognized.
+func testResealer(dryRun bool) *resealer {
+	return &resealer{
+		unseal: func(_ context.Context, sealed []byte) ([]byte, error) {
+			return base64.StdEncoding.DecodeString(string(sealed)) //nolint:wrapcheck // Test function
+		},
+		seal: func(_ context.Context, plaintext []byte) ([]byte, error) {
+			return []byte(base64.StdEncoding.EncodeToString(append([]byte("new:"), plaintext...)) + "\n"), nil
+		},
+		dryRun: dryRun,
+	}
+}
+
+// Returns the test encoding of sealed plaintext.
+func testSealed(plaintext string) string {
+	return base64.StdEncoding.EncodeToString([]byte(plaintext))
+}
+
+// Verify that blobs and specifications found in a directory are resealed in place, preserving YAML comments and file
+// references, and ignoring unrelated files.
+func TestResealPaths(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	nested := filepath.Join(tmpDir, "nested")
+	if err := os.Mkdir(nested, 0o700); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	files := map[string]string{
+		filepath.Join(tmpDir, "password.b64"): testSealed("password") + "\n",
+		filepath.Join(nested, "spec.yaml"): "# Application secrets\n/etc/app/key: " + testSealed("key") +
+			"\n/etc/app/config:\n  template: config.tmpl\n  inputs:\n    token: " + testSealed("token") +
+			"\n/etc/app/cert: file://cert.b64\n",
+		filepath.Join(nested, "spec.json"): `{"/etc/app/key": {"data": "` + testSealed("key") + `", "mode": "0600"}}`,
+		filepath.Join(tmpDir, "README.md"): "not sealed",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o640); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	r := testResealer(false)
+	if err := r.resealPaths(ctx, []string{tmpDir}); err != nil {
+		t.Fatalf("resealPaths raised an unexpected error: %v", err)
+	}
+	if r.count != 4 {
+		t.Errorf("Expected 4 values to be resealed, got %d", r.count)
+	}
+	read := func(path string) string {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		return string(data)
+	}
+	if blob := read(filepath.Join(tmpDir, "password.b64")); blob != testSealed("new:password")+"\n" {
+		t.Errorf("Unexpected resealed blob %q", blob)
+	}
+	yamlSpec := read(filepath.Join(nested, "spec.yaml"))
+	for _, expected := range []string{"# Application secrets", testSealed("new:key"), testSealed("new:token"), "file://cert.b64", "template: config.tmpl"} {
+		if !strings.Contains(yamlSpec, expected) {
+			t.Errorf("Expected YAML specification to contain %q, got %q", expected, yamlSpec)
+		}
+	}
+	var jsonSpec map[string]map[string]string
+	if err := json.Unmarshal([]byte(read(filepath.Join(nested, "spec.json"))), &jsonSpec); err != nil {
+		t.Fatalf("Failed to parse JSON specification: %v", err)
+	}
+	if entry := jsonSpec["/etc/app/key"]; entry["data"] != testSealed("new:key") || entry["mode"] != "0600" {
+		t.Errorf("Unexpected resealed JSON entry %v", entry)
+	}
+	if readme := read(filepath.Join(tmpDir, "README.md")); readme != "not sealed" {
+		t.Errorf("Expected unrelated file to be unchanged, got %q", readme)
+	}
+	if info, err := os.Stat(filepath.Join(tmpDir, "password.b64")); err != nil || info.Mode().Perm() != 0o640 {
+		t.Errorf("Expected file mode to be preserved, got %v: %v", info.Mode(), err)
+	}
+}
+
+// Verify that a dry run does not rewrite files, and that failures are reported without stopping other files.
+func TestResealPaths_DryRunAndFailures(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	good := filepath.Join(tmpDir, "good.b64")
+	bad := filepath.Join(tmpDir, "bad.b64")
+	for path, content := range map[string]string{good: testSealed("good"), bad: "not base64!"} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	r := testResealer(true)
+	err := r.resealPaths(ctx, []string{bad, good})
+	var decodeErr base64.CorruptInputError
+	if !errors.As(err, &decodeErr) {
+		t.Errorf("Expected resealPaths to raise %T, got %v", decodeErr, err)
+	}
+	if r.count != 1 {
+		t.Errorf("Expected 1 value to be resealed, got %d", r.count)
+	}
+	if data, err := os.ReadFile(good); err != nil || string(data) != testSealed("good") {
+		t.Errorf("Expected dry run to leave file unchanged, got %q: %v", data, err)
+	}
+}
@@ -0,0 +1,169 @@
+// Package cli implements the f5xc command line utility, which combines the seal and unseal utilities with commands to
+// retrieve and inspect public keys and secret policy documents. Subcommands that call the F5 Distributed Cloud API
+// share the client flags of the root command, which default to the environment variables used by vesctl.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/memes/f5xc"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Write structured output as YAML.
+	outputYAML = "yaml"
+	// Write structured output as JSON.
+	outputJSON = "json"
+	// The exit code returned when a command fails.
+	exitFailure = 1
+)
+
+// ErrInvalidArguments is returned when the command line arguments are incomplete or inconsistent.
+var ErrInvalidArguments = errors.New("invalid arguments")
+
+// An error that carries the exit code of a subcommand that reports its own failures, such as unseal.
+type exitCodeError int
+
+func (e exitCodeError) Error() string {
+	return fmt.Sprintf("exit code %d", int(e))
+}
+
+// Implements pflag.Value for a [log/slog] level.
+type levelFlag struct {
+	level *slog.LevelVar
+}
+
+func (l levelFlag) String() string {
+	if l.level == nil {
+		return slog.LevelInfo.String()
+	}
+	return l.level.Level().String()
+}
+
+func (l levelFlag) Set(value string) error {
+	return l.level.UnmarshalText([]byte(value)) //nolint:wrapcheck // The flag package adds context to the error
+}
+
+func (l levelFlag) Type() string {
+	return "level"
+}
+
+// Execute runs the f5xc command with the command line arguments, excluding the program name, and returns the exit code.
+// The version is reported by the version subcommand; if empty the module version from the build information is used.
+func Execute(version string, args []string) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	cmd := newRootCommand(version, os.Getenv)
+	cmd.SetArgs(args)
+	err := cmd.ExecuteContext(ctx)
+	var exitCode exitCodeError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitCode):
+		return int(exitCode)
+	}
+	slog.Error("Command failed", "error", err)
+	return exitFailure
+}
+
+// Returns the root f5xc command with all subcommands added.
+func newRootCommand(version string, getenv func(string) string) *cobra.Command {
+	if version == "" {
+		version = f5xc.Version()
+	}
+	level := &slog.LevelVar{}
+	cfg := &clientConfig{}
+	cmd := &cobra.Command{
+		Use:           "f5xc",
+		Short:         "Seal, unseal, and inspect F5 Distributed Cloud blindfold secrets",
+		Version:       version,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRun: func(cmd *cobra.Command, _ []string) {
+			slog.SetDefault(slog.New(slog.NewJSONHandler(cmd.ErrOrStderr(), &slog.HandlerOptions{
+				AddSource: true,
+				Level:     level,
+			})))
+		},
+	}
+	cmd.PersistentFlags().Var(levelFlag{level: level}, "log-level", "The logging level; one of DEBUG, INFO, WARN, or ERROR")
+	cfg.addFlags(cmd.PersistentFlags())
+	cmd.AddCommand(
+		newSealCommand(cfg, getenv),
+		newUnsealCommand(),
+		newResealCommand(cfg, getenv),
+		newScanCommand(cfg, getenv),
+		newInspectCommand(cfg, getenv),
+		newPolicyCommand(cfg, getenv),
+		newKeyCommand(cfg, getenv),
+		newSelftestCommand(cfg, getenv),
+		newVersionCommand(version),
+	)
+	return cmd
+}
+
+// The structured output of the version command.
+type versionInfo struct {
+	Version string         `json:"version" yaml:"version"`
+	Module  f5xc.BuildInfo `json:"module" yaml:"module"`
+}
+
+// Returns the version command, which prints the version, or the version and the build of the f5xc module as YAML or
+// JSON.
+func newVersionCommand(version string) *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the version of f5xc",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			switch output {
+			case "":
+			case outputYAML, outputJSON:
+				return writeObject(cmd.OutOrStdout(), output, versionInfo{Version: version, Module: f5xc.ReadBuildInfo()})
+			default:
+				return fmt.Errorf("unsupported output format %q: %w", output, ErrInvalidArguments)
+			}
+			if _, err := fmt.Fprintln(cmd.OutOrStdout(), version); err != nil {
+				return fmt.Errorf("failed to write version: %w", err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&output, "output", "", "Print the version and module build information in this format; one of yaml or json")
+	return cmd
+}
+
+// Writes the value to w as YAML or JSON.
+func writeObject(w io.Writer, format string, value any) error {
+	var err error
+	switch format {
+	case outputYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err = encoder.Encode(value); err == nil {
+			err = encoder.Close()
+		}
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(value)
+	default:
+		return fmt.Errorf("output must be %s or %s: %w", outputYAML, outputJSON, ErrInvalidArguments)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", format, err)
+	}
+	return nil
+}
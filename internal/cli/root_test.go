@@ -0,0 +1,334 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/blindfold"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+// Verify that the subcommands can be executed through the root command, using client flags that are set through the
+// environment.
+func TestRootCommand(t *testing.T) {
+	t.Parallel()
+	var keyQuery string
+	server := httptest.NewTLSServer(testAPIHandler(t, &keyQuery))
+	t.Cleanup(server.Close)
+	env := map[string]string{
+		"VOLT_API_URL":     server.URL + "/api",
+		"VOLTERRA_TOKEN":   "test-token",
+		"VOLT_API_CA_CERT": testCACert(t, server),
+	}
+	getenv := func(name string) string {
+		return env[name]
+	}
+	recipientsFile := filepath.Join(t.TempDir(), "recipients")
+	if err := os.WriteFile(recipientsFile, []byte("# Test tenants\nprod test/shared/test\nstaging staging/shared/test@1\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write recipients file: %v", err)
+	}
+	tests := []struct {
+		name        string
+		args        []string
+		stdin       string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "version",
+			args:     []string{"version"},
+			expected: "1.2.3\n",
+		},
+		{
+			name:     "version-json",
+			args:     []string{"version", "--output", "json"},
+			expected: `"version": "1.2.3",`,
+		},
+		{
+			name:        "version-invalid-output",
+			args:        []string{"version", "--output", "xml"},
+			expectedErr: ErrInvalidArguments,
+		},
+		{
+			name:     "key-get",
+			args:     []string{"key", "get"},
+			expected: "keyVersion: 2\nmodulusBase64: \"\"\npublicExponentBase64: \"\"\ntenant: test\n",
+		},
+		{
+			name:     "policy-get",
+			args:     []string{"--log-level", "DEBUG", "policy", "get", "test", "--output", "json"},
+			expected: `"policy_id": "test-policy"`,
+		},
+		{
+			name:        "policy-get-missing",
+			args:        []string{"policy", "get", "missing"},
+			expectedErr: ErrPolicyNotFound,
+		},
+		{
+			name:        "policy-get-invalid-output",
+			args:        []string{"policy", "get", "test", "--output", "xml"},
+			expectedErr: ErrInvalidArguments,
+		},
+		{
+			name:        "key-get-cert-without-key",
+			args:        []string{"key", "get", "--cert", "cert.pem"},
+			expectedErr: ErrInvalidArguments,
+		},
+		{
+			name: "inspect",
+			// spell-checker: disable-next-line
+			args:     []string{"inspect", "--output", "json", "ZnZ6Y3lyLndmYmE="},
+			expected: `"size": 11`,
+		},
+		{
+			name:     "inspect-stdin",
+			args:     []string{"inspect", "-"},
+			stdin:    "ZnZ6Y3lyLndmYmE=\n",
+			expected: "sealed:\n  format: opaque\n  encodedSize: 16\n  size: 11\n",
+		},
+		{
+			name:     "inspect-policy",
+			args:     []string{"inspect", "--policy", "test"},
+			expected: "policyId: test-policy\n  algorithm: FIRST_MATCH\n  rules:\n    - ALLOW client name is app\n",
+		},
+		{
+			name:     "inspect-sealed-with-policy",
+			args:     []string{"inspect", "--policy", "test", "--output", "json", testSealedHeader("other-policy", 1)},
+			expected: `"sealed with policy ID other-policy, but the policy has ID test-policy"`,
+		},
+		{
+			name:        "inspect-without-arguments",
+			args:        []string{"inspect"},
+			expectedErr: ErrInvalidArguments,
+		},
+		{
+			name:        "inspect-invalid",
+			args:        []string{"inspect", "not base64!"},
+			expectedErr: ErrInvalidSealedData,
+		},
+		{
+			name:        "seal-stdin-without-policy",
+			args:        []string{"seal"},
+			stdin:       "secret",
+			expectedErr: ErrMissingPolicy,
+		},
+		{
+			name:        "seal-without-policy",
+			args:        []string{"seal", "a.txt"},
+			expectedErr: ErrMissingPolicy,
+		},
+		{
+			name:        "seal-recipient-tenant-mismatch",
+			args:        []string{"seal", "--recipients-file", recipientsFile, "--recipient", "staging", "a.txt"},
+			expectedErr: blindfold.ErrRecipientMismatch,
+		},
+		{
+			name:        "seal-recipient-not-selected",
+			args:        []string{"seal", "--recipients-file", recipientsFile, "a.txt"},
+			expectedErr: blindfold.ErrRecipientNotFound,
+		},
+		{
+			name:        "seal-recipient-with-policy",
+			args:        []string{"seal", "--recipient", "test/shared/test", "--policy", "test", "a.txt"},
+			expectedErr: ErrInvalidArguments,
+		},
+		{
+			name:        "seal-invalid-recipient",
+			args:        []string{"seal", "--recipient", "test/shared", "a.txt"},
+			expectedErr: blindfold.ErrInvalidRecipient,
+		},
+		{
+			name:        "selftest-without-policy",
+			args:        []string{"selftest"},
+			expectedErr: ErrMissingPolicy,
+		},
+		{
+			name:        "selftest-missing-policy",
+			args:        []string{"selftest", "--policy", "missing", "--wingman-url", "http://127.0.0.1:1"},
+			expectedErr: ErrSelfTestFailed,
+		},
+		{
+			name:        "selftest-invalid-output",
+			args:        []string{"selftest", "--policy", "test", "--output", "xml"},
+			expectedErr: ErrInvalidArguments,
+		},
+		{
+			name:        "scan-without-paths",
+			args:        []string{"scan"},
+			expectedErr: ErrNoPaths,
+		},
+		{
+			name:        "scan-fix-without-policy",
+			args:        []string{"scan", "--fix", "."},
+			expectedErr: ErrMissingPolicy,
+		},
+		{
+			name:        "scan-policy-without-fix",
+			args:        []string{"scan", "--policy", "test", "."},
+			expectedErr: ErrInvalidArguments,
+		},
+		{
+			name:        "scan-invalid-pattern",
+			args:        []string{"scan", "--pattern", "(", "."},
+			expectedErr: ErrInvalidArguments,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			var stdout bytes.Buffer
+			cmd := newRootCommand("1.2.3", getenv)
+			cmd.SetArgs(tst.args)
+			cmd.SetIn(strings.NewReader(tst.stdin))
+			cmd.SetOut(&stdout)
+			cmd.SetErr(io.Discard)
+			err := cmd.Execute()
+			switch {
+			case tst.expectedErr != nil:
+				if !errors.Is(err, tst.expectedErr) {
+					t.Errorf("Expected Execute to raise %v, got %v", tst.expectedErr, err)
+				}
+			case err != nil:
+				t.Errorf("Execute raised an unexpected error: %v", err)
+			case !strings.Contains(stdout.String(), tst.expected):
+				t.Errorf("Expected output to contain %q, got %q", tst.expected, stdout.String())
+			}
+		})
+	}
+}
+
+// Verify that the seal options are validated.
+func TestSealOptions_Validate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		opts        sealOptions
+		expectedErr error
+	}{
+		{
+			name: "base64",
+			opts: sealOptions{policy: "test", output: outputBase64, inputs: []input{{path: "a.txt"}, {path: "-"}}},
+		},
+		{
+			name: "spec",
+			opts: sealOptions{policy: "test", output: outputSpec, inputs: []input{{path: "a.txt"}, {target: "/etc/b.txt", path: "-"}}},
+		},
+		{
+			name:        "no-inputs",
+			opts:        sealOptions{policy: "test", output: outputBase64},
+			expectedErr: ErrNoInputs,
+		},
+		{
+			name:        "no-policy",
+			opts:        sealOptions{output: outputBase64, inputs: []input{{path: "a.txt"}}},
+			expectedErr: ErrMissingPolicy,
+		},
+		{
+			name:        "target-with-base64-output",
+			opts:        sealOptions{policy: "test", output: outputBase64, inputs: []input{{target: "/etc/a.txt", path: "a.txt"}}},
+			expectedErr: ErrInvalidArguments,
+		},
+		{
+			name:        "stdin-without-target",
+			opts:        sealOptions{policy: "test", output: outputSpec, inputs: []input{{path: "-"}}},
+			expectedErr: ErrInvalidInput,
+		},
+		{
+			name:        "repeated-target",
+			opts:        sealOptions{policy: "test", output: outputSpec, inputs: []input{{target: "/etc/a.txt", path: "a.txt"}, {target: "/etc/a.txt", path: "b.txt"}}},
+			expectedErr: ErrInvalidInput,
+		},
+		{
+			name:        "invalid-output",
+			opts:        sealOptions{policy: "test", output: "yaml", inputs: []input{{path: "a.txt"}}},
+			expectedErr: ErrInvalidArguments,
+		},
+		{
+			name: "terraform-external",
+			opts: sealOptions{policy: "test", output: outputBase64, terraformExternal: true},
+		},
+		{
+			name:        "terraform-external-with-inputs",
+			opts:        sealOptions{policy: "test", output: outputBase64, terraformExternal: true, inputs: []input{{path: "a.txt"}}},
+			expectedErr: ErrInvalidArguments,
+		},
+		{
+			name:        "terraform-external-with-spec",
+			opts:        sealOptions{policy: "test", output: outputSpec, terraformExternal: true},
+			expectedErr: ErrInvalidArguments,
+		},
+		{
+			name: "manifests",
+			opts: sealOptions{policy: "test", output: outputBase64, manifests: true},
+		},
+		{
+			name:        "manifests-with-terraform-external",
+			opts:        sealOptions{policy: "test", output: outputBase64, manifests: true, terraformExternal: true},
+			expectedErr: ErrInvalidArguments,
+		},
+		{
+			name:        "manifests-without-policy",
+			opts:        sealOptions{output: outputBase64, manifests: true},
+			expectedErr: ErrMissingPolicy,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			if err := tst.opts.validate(); !errors.Is(err, tst.expectedErr) {
+				t.Errorf("Expected validate to return %v, got %v", tst.expectedErr, err)
+			}
+		})
+	}
+}
+
+// Verify that [TARGET=]FILE arguments are parsed, and that standard input can only be read once.
+func TestParseInputs(t *testing.T) {
+	t.Parallel()
+	inputs, err := parseInputs([]string{"a.txt", "/etc/b.txt=b.txt", "/etc/c.txt=-"}, nil)
+	if err != nil {
+		t.Fatalf("parseInputs raised an unexpected error: %v", err)
+	}
+	expected := []input{{path: "a.txt"}, {target: "/etc/b.txt", path: "b.txt"}, {target: "/etc/c.txt", path: "-"}}
+	if !slices.Equal(inputs, expected) {
+		t.Errorf("Expected inputs %v, got %v", expected, inputs)
+	}
+	for _, args := range [][]string{{"-", "-"}, {"/etc/a.txt="}} {
+		if _, err := parseInputs(args, nil); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("Expected parseInputs(%v) to raise %v, got %v", args, ErrInvalidInput, err)
+		}
+	}
+	// Without arguments the plaintext is read from a stdin that is not a terminal, whether or not it is a file
+	if inputs, err := parseInputs(nil, strings.NewReader("secret")); err != nil || !slices.Equal(inputs, []input{{path: stdinInput}}) {
+		t.Errorf("Expected parseInputs to read from stdin, got %v: %v", inputs, err)
+	}
+	if inputs, err := parseInputs(nil, nil); err != nil || inputs != nil {
+		t.Errorf("Expected parseInputs without stdin to return no inputs, got %v: %v", inputs, err)
+	}
+}
+
+// Verify that structured output is written as YAML or JSON.
+func TestWriteObject(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	if err := writeObject(&buf, outputJSON, f5xc.PublicKey{KeyVersion: 1}); err != nil {
+		t.Fatalf("writeObject raised an unexpected error: %v", err)
+	}
+	var key f5xc.PublicKey
+	if err := json.Unmarshal(buf.Bytes(), &key); err != nil || key.KeyVersion != 1 {
+		t.Errorf("Unexpected JSON output %q: %v", buf.String(), err)
+	}
+}
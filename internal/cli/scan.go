@@ -0,0 +1,360 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/memes/f5xc/blindfold"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// A line containing this marker is never reported, to allow known false positives.
+	scanAllowMarker = "f5xc:allow"
+	// The name of the rule that matches values marked for sealing.
+	scanRuleMarker = "marker"
+	// A base64 value that decodes to at least this many bytes is assumed to be sealed, even if its header is not
+	// recognized.
+	minSealedSize = 128
+	// The number of leading bytes that are checked for a NUL to identify binary files, which are not scanned.
+	binarySniffSize = 8000
+)
+
+var (
+	// ErrPlaintextSecrets is returned when plaintext secrets are found and have not been sealed.
+	ErrPlaintextSecrets = errors.New("plaintext secrets found")
+	// ErrSecretsSealed is returned when plaintext secrets were sealed and files rewritten, so that the rewritten files
+	// are reviewed before they are committed.
+	ErrSecretsSealed = errors.New("plaintext secrets were sealed; review and stage the rewritten files")
+)
+
+// Matches a marker that requests the value on the line be sealed, and which is replaced by the sealed marker.
+var scanSealMarker = regexp.MustCompile(`f5xc:seal\b`)
+
+// Defines the options of the scan command.
+type scanOptions struct {
+	fix        bool
+	patterns   []string
+	policy     string
+	namespace  string
+	keyVersion int
+	vesctl     string
+	cacheDir   string
+	timeout    time.Duration
+}
+
+// A rule that identifies plaintext secrets; the secret is the value capture group of the pattern if present, or the
+// whole match. Secrets that are not fixable, such as multi-line private keys, are reported but never rewritten.
+type scanRule struct {
+	name    string
+	pattern *regexp.Regexp
+	fixable bool
+}
+
+// Returns the built-in rules; a value marked with f5xc:seal, well-known token formats, private keys, and quoted values
+// assigned to keys that suggest a secret.
+func defaultScanRules() []scanRule {
+	return []scanRule{
+		{
+			name:    scanRuleMarker,
+			pattern: regexp.MustCompile(`^\s*(?:export\s+)?["']?[\w.-]+["']?\s*[:=]\s*["']?(?P<value>[^"'\s#]+)["']?.*f5xc:seal\b`),
+			fixable: true,
+		},
+		{
+			name:    "aws-access-key-id",
+			pattern: regexp.MustCompile(`\b(?P<value>(?:AKIA|ASIA)[0-9A-Z]{16})\b`),
+			fixable: true,
+		},
+		{
+			name:    "github-token",
+			pattern: regexp.MustCompile(`\b(?P<value>(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_\w{22,}))\b`),
+			fixable: true,
+		},
+		{
+			name:    "slack-token",
+			pattern: regexp.MustCompile(`\b(?P<value>xox[abprs]-[A-Za-z0-9-]{10,})\b`),
+			fixable: true,
+		},
+		{
+			name:    "private-key",
+			pattern: regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )*PRIVATE KEY-----`),
+		},
+		{
+			name:    "assigned-secret",
+			pattern: regexp.MustCompile(`(?i)[\w.-]*(?:password|passwd|secret|token|api[_-]?key)[\w.-]*["']?\s*[:=]\s*["'](?P<value>[^"'\s$]{8,})["']`),
+			fixable: true,
+		},
+	}
+}
+
+// Returns the rules made from the custom patterns, which are fixable.
+func customScanRules(patterns []string) ([]scanRule, error) {
+	rules := make([]scanRule, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w: %w", pattern, err, ErrInvalidArguments)
+		}
+		rules = append(rules, scanRule{name: "pattern " + pattern, pattern: re, fixable: true})
+	}
+	return rules, nil
+}
+
+// Describes a plaintext secret found on a line; start and end are the offsets of the secret within the line.
+type scanMatch struct {
+	rule    scanRule
+	start   int
+	end     int
+	fixable bool
+}
+
+// Returns the secrets found on the line, ordered by offset and without overlaps; the first rule to match a region of
+// the line takes precedence. Values that appear to be sealed already are ignored.
+func scanLine(rules []scanRule, line string) []scanMatch {
+	if strings.Contains(line, scanAllowMarker) {
+		return nil
+	}
+	var matches []scanMatch
+	for _, rule := range rules {
+		for _, loc := range rule.pattern.FindAllStringSubmatchIndex(line, -1) {
+			start, end := loc[0], loc[1]
+			if i := rule.pattern.SubexpIndex("value"); i > 0 && loc[2*i] >= 0 {
+				start, end = loc[2*i], loc[2*i+1]
+			}
+			if start == end || looksSealed(line[start:end]) {
+				continue
+			}
+			overlaps := slices.ContainsFunc(matches, func(m scanMatch) bool {
+				return start < m.end && m.start < end
+			})
+			if !overlaps {
+				matches = append(matches, scanMatch{rule: rule, start: start, end: end, fixable: rule.fixable})
+			}
+		}
+	}
+	slices.SortFunc(matches, func(a, b scanMatch) int {
+		return a.start - b.start
+	})
+	return matches
+}
+
+// Returns true if the value is base64 encoded data that has a recognized sealed header, or is too large to be a
+// plausible plaintext secret.
+func looksSealed(value string) bool {
+	info, err := inspectSealed([]byte(value))
+	return err == nil && (info.Format != sealedFormatOpaque || info.Size >= minSealedSize)
+}
+
+// Scans files for plaintext secrets, and optionally seals them in place.
+type scanner struct {
+	rules []scanRule
+	// Seals plaintext; if nil secrets are reported but not sealed.
+	seal func(ctx context.Context, plaintext []byte) ([]byte, error)
+	// The number of secrets that were reported and not sealed.
+	unsealed int
+	// The number of secrets that were sealed.
+	sealed int
+}
+
+// Returns the scan command, which reports plaintext secrets in files and optionally seals them in place.
+func newScanCommand(cfg *clientConfig, getenv func(string) string) *cobra.Command {
+	opts := &scanOptions{}
+	cmd := &cobra.Command{
+		Use:   "scan [--fix --policy NAME] [flags] PATH...",
+		Short: "Find plaintext secrets in files, and optionally seal them in place",
+		Long: `Scan files for plaintext secrets, and fail if any are found, so that sealing can be enforced by a pre-commit hook
+or CI job. Each PATH may be a file, such as the staged files passed by pre-commit, or a directory that is walked;
+hidden directories and binary files are skipped. Secrets are values on a line marked with ` + "`f5xc:seal`" + `, e.g.
+` + "`password: hunter2 # f5xc:seal`" + `, well-known token formats such as AWS access key IDs and GitHub tokens,
+private keys, quoted values assigned to keys named like password, secret, token, or api_key, and the matches of any
+--pattern regular expressions; the value capture group of a pattern is the secret if present. Values that are already
+sealed are ignored, and a line containing ` + "`f5xc:allow`" + ` is never reported.
+
+With --fix every secret except a private key is sealed with the public key and the named secret policy, the plaintext
+is replaced by the base64 encoded sealed data, and the f5xc:seal marker is changed to f5xc:sealed. The command still
+fails after rewriting files, so that the changes are reviewed and staged before they are committed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch {
+			case len(args) == 0:
+				return ErrNoPaths
+			case opts.fix && opts.policy == "":
+				return ErrMissingPolicy
+			case !opts.fix && opts.policy != "":
+				return fmt.Errorf("a policy can only be provided with --fix: %w", ErrInvalidArguments)
+			case opts.timeout < 0:
+				return fmt.Errorf("timeout must not be negative: %w", ErrInvalidArguments)
+			}
+			rules, err := customScanRules(opts.patterns)
+			if err != nil {
+				return err
+			}
+			if opts.fix && opts.cacheDir == "" {
+				if err := cfg.complete(cmd.Flags(), getenv); err != nil {
+					return err
+				}
+			}
+			return opts.run(cmd.Context(), cfg, append(defaultScanRules(), rules...), args, cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().BoolVar(&opts.fix, "fix", false, "Seal the secrets that are found and rewrite the files")
+	cmd.Flags().StringArrayVar(&opts.patterns, "pattern", nil, "An additional regular expression that matches secrets; may be repeated")
+	cmd.Flags().StringVar(&opts.policy, "policy", "", "The name of the secret policy that will be allowed to unseal the secrets sealed by --fix")
+	cmd.Flags().StringVar(&opts.namespace, "namespace", DefaultNamespace, "The namespace of the secret policy")
+	cmd.Flags().IntVar(&opts.keyVersion, "key-version", 0, "The version of the public key to use; 0 to use the current version")
+	cmd.Flags().StringVar(&opts.vesctl, "vesctl", blindfold.VesctlExecutable, "The name or path of the vesctl executable")
+	cmd.Flags().StringVar(&opts.cacheDir, "cache-dir", "", "Seal offline with the public key and policy document in this directory, written by f5xc key pull")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 0, "The maximum time to wait for the scan to complete; 0 to wait indefinitely")
+	return cmd
+}
+
+// Creates a sealer if secrets are to be fixed, then scans every path and reports the result.
+func (o *scanOptions) run(ctx context.Context, cfg *clientConfig, rules []scanRule, paths []string, stdout io.Writer) error {
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+	s := &scanner{rules: rules}
+	if o.fix {
+		sealOpts := &sealOptions{
+			policy:     o.policy,
+			namespace:  o.namespace,
+			keyVersion: o.keyVersion,
+			vesctl:     o.vesctl,
+			cacheDir:   o.cacheDir,
+		}
+		var sealer *sealer
+		var err error
+		if o.cacheDir != "" {
+			sealer, err = newCachedSealer(sealOpts)
+		} else {
+			sealer, err = sealOpts.fetchSealer(ctx, cfg)
+		}
+		if err != nil {
+			return err
+		}
+		s.seal = sealer.seal
+	}
+	if err := s.scanPaths(ctx, paths, stdout); err != nil {
+		return err
+	}
+	return s.result()
+}
+
+// Returns an error if secrets were found, whether or not they were sealed.
+func (s *scanner) result() error {
+	switch {
+	case s.unsealed > 0:
+		return fmt.Errorf("%d secrets were not sealed: %w", s.unsealed, ErrPlaintextSecrets)
+	case s.sealed > 0:
+		return fmt.Errorf("%d secrets: %w", s.sealed, ErrSecretsSealed)
+	}
+	return nil
+}
+
+// Scans each path, walking directories, and writes a line for each secret found to w.
+func (s *scanner) scanPaths(ctx context.Context, paths []string, w io.Writer) error {
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			switch {
+			case err != nil:
+				return err
+			case entry.IsDir():
+				if path != root && strings.HasPrefix(entry.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			case !entry.Type().IsRegular():
+				return nil
+			}
+			return s.scanFile(ctx, path, w)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", root, err)
+		}
+	}
+	return nil
+}
+
+// Scans the file for secrets, reporting each to w, and rewrites the file if any were sealed. Binary files are skipped.
+func (s *scanner) scanFile(ctx context.Context, path string, w io.Writer) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	if bytes.IndexByte(data[:min(len(data), binarySniffSize)], 0) >= 0 {
+		slog.Debug("Skipping binary file", "path", path)
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	rewritten := false
+	for i, line := range lines {
+		matches := scanLine(s.rules, line)
+		if len(matches) == 0 {
+			continue
+		}
+		fixed, err := s.fixLine(ctx, line, matches)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+		for _, m := range matches {
+			status := "not sealed"
+			if s.seal != nil && m.fixable {
+				status = "sealed"
+			}
+			if _, err := fmt.Fprintf(w, "%s:%d: %s (%s)\n", path, i+1, m.rule.name, status); err != nil {
+				return fmt.Errorf("failed to write scan result: %w", err)
+			}
+		}
+		if fixed != line {
+			lines[i] = fixed
+			rewritten = true
+		}
+	}
+	if !rewritten {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "")), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to rewrite file: %w", err)
+	}
+	slog.Debug("Rewrote file with sealed secrets", "path", path)
+	return nil
+}
+
+// Counts the matches, and returns the line with each fixable secret replaced by its sealed data if sealing is enabled.
+// Sealed markers replace the seal markers of fixed values.
+func (s *scanner) fixLine(ctx context.Context, line string, matches []scanMatch) (string, error) {
+	fixed := line
+	// Replace from the end of the line so that the offsets of earlier matches remain valid.
+	for _, m := range slices.Backward(matches) {
+		if s.seal == nil || !m.fixable {
+			s.unsealed++
+			continue
+		}
+		sealed, err := s.seal(ctx, []byte(line[m.start:m.end]))
+		if err != nil {
+			return "", fmt.Errorf("failed to seal %s secret: %w", m.rule.name, err)
+		}
+		fixed = fixed[:m.start] + string(bytes.TrimSpace(sealed)) + fixed[m.end:]
+		if loc := scanSealMarker.FindStringIndex(fixed); m.rule.name == scanRuleMarker && loc != nil {
+			fixed = fixed[:loc[0]] + "f5xc:sealed" + fixed[loc[1]:]
+		}
+		s.sealed++
+	}
+	return fixed, nil
+}
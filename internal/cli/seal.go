@@ -0,0 +1,528 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/blindfold"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// The input name that indicates the plaintext should be read from standard input.
+	stdinInput = "-"
+	// Write each sealed value as a line of base64 encoded data.
+	outputBase64 = "base64"
+	// Write the sealed values as a JSON unseal specification.
+	outputSpec = "spec"
+)
+
+var (
+	// ErrInvalidInput is returned when an input argument cannot be sealed as requested.
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrNoInputs is returned when no files are provided to seal.
+	ErrNoInputs = errors.New("no files provided")
+	// ErrMissingPolicy is returned when the secret policy name is not provided.
+	ErrMissingPolicy = errors.New("a secret policy name must be provided")
+)
+
+// Defines the options of the seal command.
+type sealOptions struct {
+	policy            string
+	namespace         string
+	keyVersion        int
+	vesctl            string
+	cacheDir          string
+	output            string
+	timeout           time.Duration
+	terraformExternal bool
+	manifests         bool
+	resources         *manifests
+	recipientsFile    string
+	recipient         string
+	selected          *blindfold.Recipient
+	provenance        string
+	provenanceKey     string
+	inputs            []input
+}
+
+// Returns the seal command, which seals files or standard input with the public key and a named secret policy.
+func newSealCommand(cfg *clientConfig, getenv func(string) string) *cobra.Command {
+	opts := &sealOptions{}
+	cmd := &cobra.Command{
+		Use:   "seal --policy NAME [flags] [TARGET=]FILE...",
+		Short: "Seal files or standard input with the public key and a secret policy",
+		Long: `Seal files, or standard input, with the public key and a named secret policy, and write the base64 encoded
+sealed data to standard output; one value per line in the order given, or as a JSON unseal specification that maps
+each TARGET to the sealed data of FILE when --output=spec. If TARGET is omitted the absolute path of FILE is used.
+Sealing is performed offline by vesctl, but the public key and policy document are retrieved from the API unless
+--cache-dir names a directory populated by f5xc key pull, in which case no API calls are made.
+
+With --terraform-external the command implements the Terraform external data source protocol; the JSON object query
+is read from standard input, and a JSON object that maps each query key to the sealed data of its value is written to
+standard output. Sealing is not deterministic, so the result will change on every plan and should not be used where a
+stable value is expected.
+
+With --manifests the command transforms Kubernetes manifests read from standard input, as a Helm post-renderer or a
+kustomize KRM function, and writes them to standard output. Each v1 Secret annotated with ` + annotationSeal + ` has
+its values sealed; every value if the annotation is true, or the keys given as a comma-separated list. Sealed values
+are written to stringData, replacing the plaintext, and the annotation is replaced by ` + annotationSealed + ` so that
+the Secret is not sealed again; other manifests are unchanged. When the input is a KRM ResourceList the data of its
+functionConfig may set the policy, namespace, key-version, cache-dir, and vesctl flags.
+
+Instead of --policy, --namespace, and --key-version a recipient can be given with --recipient, in the form
+TENANT/NAMESPACE/POLICY[@KEY_VERSION], or selected by name from a recipients file given with --recipients-file. Each
+line of the file holds a recipient, optionally preceded by a name, and # starts a comment; --recipient may be omitted
+if the file holds a single recipient. Sealing fails if the public key or policy document belong to a different tenant
+than the recipient, so that a pipeline cannot seal production secrets with staging credentials, or the reverse.
+
+  # NAME   RECIPIENT
+  prod     acme/shared/app-secrets@3
+  staging  acme-staging/shared/app-secrets
+
+With --provenance and --provenance-key a signed attestation of the sealed files is written to a file; a DSSE envelope
+holding an in-toto statement whose subjects are the SHA-256 digests of the sealed data of each file, named by TARGET
+in spec output or FILE otherwise, and whose predicate records the tool version, time, tenant, key version, and secret
+policy. Nothing derived from the plaintext is recorded. The key must be a PEM encoded ECDSA, Ed25519, or RSA private
+key.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.applyRecipient(cmd.Flags()); err != nil {
+				return err
+			}
+			var err error
+			switch {
+			case (opts.terraformExternal || opts.manifests) && len(args) > 0:
+				return fmt.Errorf("files cannot be provided with terraform external or manifests: %w", ErrInvalidArguments)
+			case opts.manifests:
+				if opts.resources, err = readManifests(cmd.InOrStdin()); err != nil {
+					return err
+				}
+				if err := opts.applyFunctionConfig(cmd.Flags()); err != nil {
+					return err
+				}
+			case !opts.terraformExternal:
+				if opts.inputs, err = parseInputs(args, cmd.InOrStdin()); err != nil {
+					return err
+				}
+			}
+			if err := opts.validate(); err != nil {
+				return err
+			}
+			if opts.cacheDir == "" {
+				if err := cfg.complete(cmd.Flags(), getenv); err != nil {
+					return err
+				}
+			}
+			return opts.run(cmd.Context(), cfg, cmd.Root().Version, cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().StringVar(&opts.policy, "policy", "", "The name of the secret policy that will be allowed to unseal the data")
+	cmd.Flags().StringVar(&opts.namespace, "namespace", DefaultNamespace, "The namespace of the secret policy")
+	cmd.Flags().StringVar(&opts.recipient, "recipient", "", "Seal for this TENANT/NAMESPACE/POLICY[@KEY_VERSION], or the recipient with this name in the recipients file")
+	cmd.Flags().StringVar(&opts.recipientsFile, "recipients-file", "", "Select the recipient to seal for from this file")
+	cmd.Flags().IntVar(&opts.keyVersion, "key-version", 0, "The version of the public key to use; 0 to use the current version")
+	cmd.Flags().StringVar(&opts.vesctl, "vesctl", blindfold.VesctlExecutable, "The name or path of the vesctl executable")
+	cmd.Flags().StringVar(&opts.cacheDir, "cache-dir", "", "Seal offline with the public key and policy document in this directory, written by f5xc key pull")
+	cmd.Flags().StringVar(&opts.output, "output", outputBase64, "The output format; one of base64 or spec")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 0, "The maximum time to wait for the API and vesctl; 0 to wait indefinitely")
+	cmd.Flags().BoolVar(&opts.terraformExternal, "terraform-external", false, "Seal the values of a Terraform external data source query read from standard input")
+	cmd.Flags().StringVar(&opts.provenance, "provenance", "", "Write a signed in-toto provenance attestation of the sealed files to this file")
+	cmd.Flags().StringVar(&opts.provenanceKey, "provenance-key", "", "The PEM encoded private key that signs the provenance attestation")
+	cmd.Flags().BoolVar(&opts.manifests, "manifests", false, "Seal annotated Secrets in Kubernetes manifests read from standard input")
+	return cmd
+}
+
+// Returns an error if the options are incomplete or inconsistent.
+func (o *sealOptions) validate() error {
+	switch {
+	case o.terraformExternal && o.manifests:
+		return fmt.Errorf("terraform external cannot be combined with manifests: %w", ErrInvalidArguments)
+	case (o.terraformExternal || o.manifests) && (len(o.inputs) > 0 || o.output != outputBase64):
+		return fmt.Errorf("terraform external and manifests cannot be combined with files or an output format: %w", ErrInvalidArguments)
+	case len(o.inputs) == 0 && !o.terraformExternal && !o.manifests:
+		return ErrNoInputs
+	case o.policy == "":
+		return ErrMissingPolicy
+	case o.output != outputBase64 && o.output != outputSpec:
+		return fmt.Errorf("output must be base64 or spec: %w", ErrInvalidArguments)
+	case o.keyVersion < 0:
+		return fmt.Errorf("key version must not be negative: %w", ErrInvalidArguments)
+	case o.timeout < 0:
+		return fmt.Errorf("timeout must not be negative: %w", ErrInvalidArguments)
+	case (o.provenance == "") != (o.provenanceKey == ""):
+		return fmt.Errorf("a provenance file and signing key must be provided together: %w", ErrInvalidArguments)
+	case o.provenance != "" && (o.terraformExternal || o.manifests):
+		return fmt.Errorf("provenance cannot be combined with terraform external or manifests: %w", ErrInvalidArguments)
+	}
+	if o.output == outputSpec {
+		_, err := specTargets(o.inputs)
+		return err
+	}
+	for _, in := range o.inputs {
+		if in.target != "" {
+			return fmt.Errorf("a target can only be provided for spec output: %w", ErrInvalidArguments)
+		}
+	}
+	return nil
+}
+
+// Sets the policy, namespace, and key version from the recipient selected by the recipient flags, if any. A recipient
+// cannot be combined with the flags that it replaces.
+func (o *sealOptions) applyRecipient(flags *pflag.FlagSet) error {
+	if o.recipient == "" && o.recipientsFile == "" {
+		return nil
+	}
+	for _, name := range []string{"policy", "namespace", "key-version"} {
+		if flags.Changed(name) {
+			return fmt.Errorf("a recipient cannot be combined with --%s: %w", name, ErrInvalidArguments)
+		}
+	}
+	var err error
+	if o.recipientsFile == "" {
+		o.selected, err = blindfold.ParseRecipient(o.recipient)
+	} else {
+		var recipients []*blindfold.Recipient
+		if recipients, err = blindfold.ReadRecipientsFile(o.recipientsFile); err == nil {
+			o.selected, err = blindfold.SelectRecipient(recipients, o.recipient)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", err, ErrInvalidArguments)
+	}
+	slog.Debug("Sealing for recipient", "name", o.selected.Name, "recipient", o.selected.String())
+	for name, value := range map[string]string{
+		"policy":      o.selected.Policy,
+		"namespace":   o.selected.Namespace,
+		"key-version": strconv.Itoa(o.selected.KeyVersion),
+	} {
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("invalid recipient value for %s: %w: %w", name, err, ErrInvalidArguments)
+		}
+	}
+	return nil
+}
+
+// Returns an error if a recipient has been selected and the public key or policy document do not match it.
+func (o *sealOptions) verifyRecipient(pubKey *f5xc.PublicKey, policyDoc *f5xc.SecretPolicyDocument) error {
+	if o.selected == nil {
+		return nil
+	}
+	return o.selected.Verify(pubKey, policyDoc) //nolint:wrapcheck // The error describes the mismatch
+}
+
+// Retrieves the sealing parameters from the API, then returns a sealer that will use them.
+func (o *sealOptions) fetchSealer(ctx context.Context, cfg *clientConfig) (*sealer, error) {
+	client, err := cfg.newClient()
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseIdleConnections()
+	return newSealer(ctx, client, o)
+}
+
+// Retrieves the sealing parameters from the API or cache directory, then seals the inputs and writes the output, and
+// the provenance attestation if requested. The tool version is recorded in the attestation.
+func (o *sealOptions) run(ctx context.Context, cfg *clientConfig, version string, stdin io.Reader, stdout io.Writer) error {
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+	var s *sealer
+	var err error
+	if o.cacheDir != "" {
+		s, err = newCachedSealer(o)
+	} else {
+		s, err = o.fetchSealer(ctx, cfg)
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case o.terraformExternal:
+		return s.sealTerraformQuery(ctx, stdin, stdout)
+	case o.manifests:
+		return s.sealManifests(ctx, o.resources, o.namespace+"/"+o.policy, stdout)
+	}
+	var signer crypto.Signer
+	if o.provenanceKey != "" {
+		// Fail before sealing if the attestation cannot be signed
+		if signer, err = blindfold.ReadSigningKey(o.provenanceKey); err != nil {
+			return fmt.Errorf("%w: %w", err, ErrInvalidArguments)
+		}
+	}
+	sealed, err := s.sealInputs(ctx, o.inputs, stdin)
+	if err != nil {
+		return err
+	}
+	if err := writeOutput(stdout, o.output, o.inputs, sealed); err != nil {
+		return err
+	}
+	if signer == nil {
+		return nil
+	}
+	return s.writeProvenance(o.provenance, signer, blindfold.Tool{Name: "f5xc", Version: version}, o.subjectNames(), sealed)
+}
+
+// Returns the name of each input in a provenance attestation; the unseal target in spec output, or the file otherwise.
+func (o *sealOptions) subjectNames() []string {
+	names := make([]string, 0, len(o.inputs))
+	for _, in := range o.inputs {
+		name := in.path
+		if o.output == outputSpec {
+			// Targets have been validated
+			name, _ = in.targetPath()
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// Describes a plaintext file to seal, and the unseal target path to use in spec output.
+type input struct {
+	target string
+	path   string
+}
+
+// Parses the [TARGET=]FILE arguments into inputs; if no arguments were provided and stdin is not a terminal the
+// plaintext will be read from stdin. Only a stdin that is a file can be a terminal.
+func parseInputs(args []string, stdin io.Reader) ([]input, error) {
+	if len(args) == 0 {
+		if stdin == nil {
+			return nil, nil
+		}
+		if file, ok := stdin.(*os.File); ok {
+			if stat, err := file.Stat(); err != nil || stat.Mode()&os.ModeCharDevice != 0 {
+				return nil, nil
+			}
+		}
+		slog.Debug("No files provided, reading from piped stdin")
+		return []input{{path: stdinInput}}, nil
+	}
+	inputs := make([]input, 0, len(args))
+	stdinCount := 0
+	for _, arg := range args {
+		in := input{path: arg}
+		if target, path, ok := strings.Cut(arg, "="); ok {
+			in = input{target: target, path: path}
+		}
+		switch {
+		case in.path == "":
+			return nil, fmt.Errorf("argument %q does not name a file: %w", arg, ErrInvalidInput)
+		case in.path == stdinInput:
+			stdinCount++
+		}
+		inputs = append(inputs, in)
+	}
+	if stdinCount > 1 {
+		return nil, fmt.Errorf("standard input can only be sealed once: %w", ErrInvalidInput)
+	}
+	return inputs, nil
+}
+
+// Returns the unseal target path of the input, which is the absolute path of the file if a target was not provided.
+func (in input) targetPath() (string, error) {
+	switch {
+	case in.target != "":
+		return in.target, nil
+	case in.path == stdinInput:
+		return "", fmt.Errorf("a target must be provided for standard input: %w", ErrInvalidInput)
+	}
+	path, err := filepath.Abs(in.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to determine absolute path of %s: %w", in.path, err)
+	}
+	return path, nil
+}
+
+// Returns the unseal target path of each input, or an error if a target is repeated.
+func specTargets(inputs []input) ([]string, error) {
+	targets := make([]string, 0, len(inputs))
+	for _, in := range inputs {
+		target, err := in.targetPath()
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(targets, target) {
+			return nil, fmt.Errorf("target %s is repeated: %w", target, ErrInvalidInput)
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// Seals plaintext with a public key and policy document.
+type sealer struct {
+	seal func(ctx context.Context, plaintext []byte) ([]byte, error)
+	// The public key used by seal, if known.
+	pubKey *f5xc.PublicKey
+	// The secret policy document used by seal, if known.
+	policyDoc *f5xc.SecretPolicyDocument
+}
+
+// Retrieves the public key and secret policy document from the API, and returns a sealer that will use vesctl to seal
+// plaintext with them.
+func newSealer(ctx context.Context, client *http.Client, opts *sealOptions) (*sealer, error) {
+	pubKey, err := fetchPublicKey(ctx, client, opts.keyVersion)
+	if err != nil {
+		return nil, err
+	}
+	policyDoc, err := fetchPolicyDocument(ctx, client, opts.namespace, opts.policy)
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("Retrieved sealing parameters", "keyVersion", pubKey.KeyVersion, "fingerprint", keyFingerprint(pubKey), "policyID", policyDoc.PolicyID)
+	if err := opts.verifyRecipient(pubKey, policyDoc); err != nil {
+		return nil, err
+	}
+	return newVesctlSealer(opts.vesctl, pubKey, policyDoc), nil
+}
+
+// Reads the public key and secret policy document from the cache directory, and returns a sealer that will use vesctl
+// to seal plaintext with them without calling the API.
+func newCachedSealer(opts *sealOptions) (*sealer, error) {
+	pubKey, err := blindfold.ReadCachedPublicKey(opts.cacheDir, opts.keyVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key from cache: %w", err)
+	}
+	policyDoc, err := blindfold.ReadCachedPolicyDocument(opts.cacheDir, opts.namespace, opts.policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret policy document from cache: %w", err)
+	}
+	slog.Debug("Read sealing parameters from cache", "keyVersion", pubKey.KeyVersion, "fingerprint", keyFingerprint(pubKey), "policyID", policyDoc.PolicyID)
+	if err := opts.verifyRecipient(pubKey, policyDoc); err != nil {
+		return nil, err
+	}
+	return newVesctlSealer(opts.vesctl, pubKey, policyDoc), nil
+}
+
+// Returns the fingerprint of the public key for logging, or an empty string if the key is not valid.
+func keyFingerprint(pubKey *f5xc.PublicKey) string {
+	fingerprint, _ := pubKey.Fingerprint()
+	return fingerprint
+}
+
+// Returns a sealer that will use vesctl to seal plaintext with the public key and policy document.
+func newVesctlSealer(vesctl string, pubKey *f5xc.PublicKey, policyDoc *f5xc.SecretPolicyDocument) *sealer {
+	return &sealer{
+		seal: func(ctx context.Context, plaintext []byte) ([]byte, error) {
+			return blindfold.Seal(ctx, vesctl, plaintext, pubKey, policyDoc) //nolint:wrapcheck // Error is wrapped by caller
+		},
+		pubKey:    pubKey,
+		policyDoc: policyDoc,
+	}
+}
+
+// Writes a provenance attestation to path that describes the sealed data of each named subject, signed by the signer.
+func (s *sealer) writeProvenance(path string, signer crypto.Signer, tool blindfold.Tool, names []string, sealed [][]byte) error {
+	results := make(map[string]*blindfold.SealResult, len(names))
+	for i, name := range names {
+		results[name] = blindfold.NewSealResult(sealed[i], s.pubKey, s.policyDoc)
+	}
+	statement, err := blindfold.NewStatement(tool, results)
+	if err != nil {
+		return fmt.Errorf("failed to create provenance statement: %w", err)
+	}
+	attestation, err := statement.Sign(signer)
+	if err != nil {
+		return fmt.Errorf("failed to sign provenance statement: %w", err)
+	}
+	data, err := json.MarshalIndent(attestation, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal provenance attestation: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil { //nolint:gosec // Attestations are public
+		return fmt.Errorf("failed to write provenance attestation: %w", err)
+	}
+	slog.Debug("Wrote provenance attestation", "path", path, "subjects", len(names))
+	return nil
+}
+
+// Reads and seals each of the inputs in order, returning the base64 encoded sealed data of each.
+func (s *sealer) sealInputs(ctx context.Context, inputs []input, stdin io.Reader) ([][]byte, error) {
+	sealed := make([][]byte, 0, len(inputs))
+	for _, in := range inputs {
+		var plaintext []byte
+		var err error
+		if in.path == stdinInput {
+			plaintext, err = io.ReadAll(stdin)
+		} else {
+			plaintext, err = os.ReadFile(in.path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", in.path, err)
+		}
+		data, err := s.seal(ctx, plaintext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to seal %s: %w", in.path, err)
+		}
+		slog.Debug("Sealed input", "path", in.path)
+		sealed = append(sealed, bytes.TrimSpace(data))
+	}
+	return sealed, nil
+}
+
+// Writes the sealed data in the output format; either a line of base64 encoded data for each input, or a JSON unseal
+// specification that maps the target path of each input to its sealed data.
+func writeOutput(w io.Writer, format string, inputs []input, sealed [][]byte) error {
+	if format == outputBase64 {
+		for _, data := range sealed {
+			if _, err := fmt.Fprintf(w, "%s\n", data); err != nil {
+				return fmt.Errorf("failed to write sealed data: %w", err)
+			}
+		}
+		return nil
+	}
+	targets, err := specTargets(inputs)
+	if err != nil {
+		return err
+	}
+	spec := make(map[string]string, len(inputs))
+	for i, target := range targets {
+		spec[target] = string(sealed[i])
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(spec); err != nil {
+		return fmt.Errorf("failed to write specification: %w", err)
+	}
+	return nil
+}
+
+// Implements the Terraform external data source protocol; reads a JSON object of string values from stdin, seals each
+// value, and writes a JSON object that maps each key to the base64 encoded sealed data of its value.
+func (s *sealer) sealTerraformQuery(ctx context.Context, stdin io.Reader, stdout io.Writer) error {
+	var query map[string]string
+	if err := json.NewDecoder(stdin).Decode(&query); err != nil {
+		return fmt.Errorf("failed to parse terraform query: %w: %w", err, ErrInvalidInput)
+	}
+	result := make(map[string]string, len(query))
+	for _, key := range slices.Sorted(maps.Keys(query)) {
+		data, err := s.seal(ctx, []byte(query[key]))
+		if err != nil {
+			return fmt.Errorf("failed to seal %s: %w", key, err)
+		}
+		slog.Debug("Sealed terraform query value", "key", key)
+		result[key] = string(bytes.TrimSpace(data))
+	}
+	if err := json.NewEncoder(stdout).Encode(result); err != nil {
+		return fmt.Errorf("failed to write terraform result: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,360 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/blindfold"
+)
+
+// Implements a fake F5 Distributed Cloud API that returns a public key and a single secret policy document, recording
+// the query of the most recent public key request.
+func testAPIHandler(t *testing.T, keyQuery *string) http.Handler {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+f5xc.PublicKeyURL, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "APIToken test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		*keyQuery = r.URL.RawQuery
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{
+			Data: f5xc.PublicKey{KeyVersion: 2, Tenant: "test"},
+		})
+	})
+	mux.HandleFunc("GET /api/secret_management/namespaces/shared/secret_policys/test/get_policy_document", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.SecretPolicyDocument]{
+			Data: f5xc.SecretPolicyDocument{
+				Metadata: &f5xc.Metadata{Name: "test", Namespace: "shared", Tenant: "test"},
+				PolicyID: "test-policy",
+				PolicyInfo: f5xc.SecretPolicyInfo{
+					Algo: "FIRST_MATCH",
+					Rules: []f5xc.SecretPolicyRule{
+						{Action: "ALLOW", ClientName: "app"},
+					},
+				},
+			},
+		})
+	})
+	return mux
+}
+
+// Writes the certificate of the test server to a PEM file, returning the path.
+func testCACert(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600); err != nil {
+		t.Fatalf("Failed to write CA certificate: %v", err)
+	}
+	return caCert
+}
+
+// Verify that the public key and policy document are retrieved with the client built from the options.
+func TestNewSealer(t *testing.T) {
+	t.Parallel()
+	var keyQuery string
+	server := httptest.NewTLSServer(testAPIHandler(t, &keyQuery))
+	t.Cleanup(server.Close)
+	caCert := testCACert(t, server)
+	cfg := &clientConfig{
+		apiURL:   server.URL + "/api",
+		apiToken: "test-token",
+		caCert:   caCert,
+	}
+	client, err := cfg.newClient()
+	if err != nil {
+		t.Fatalf("newClient raised an unexpected error: %v", err)
+	}
+	t.Cleanup(client.CloseIdleConnections)
+	opts := &sealOptions{
+		policy:    "test",
+		namespace: DefaultNamespace,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if _, err := newSealer(ctx, client, opts); err != nil {
+		t.Errorf("newSealer raised an unexpected error: %v", err)
+	}
+	if keyQuery != "" {
+		t.Errorf("Expected the current public key to be requested, got query %q", keyQuery)
+	}
+	opts.keyVersion = 2
+	if _, err := newSealer(ctx, client, opts); err != nil {
+		t.Errorf("newSealer raised an unexpected error: %v", err)
+	}
+	if keyQuery != "key_version=2" {
+		t.Errorf("Expected public key version 2 to be requested, got query %q", keyQuery)
+	}
+	opts.policy = "missing"
+	if _, err := newSealer(ctx, client, opts); !errors.Is(err, ErrPolicyNotFound) {
+		t.Errorf("Expected newSealer to raise %v, got %v", ErrPolicyNotFound, err)
+	}
+	opts.policy = "test"
+	opts.selected = &blindfold.Recipient{Tenant: "test", Namespace: DefaultNamespace, Policy: "test", KeyVersion: 2}
+	if _, err := newSealer(ctx, client, opts); err != nil {
+		t.Errorf("newSealer raised an unexpected error: %v", err)
+	}
+	opts.selected.Tenant = "other"
+	if _, err := newSealer(ctx, client, opts); !errors.Is(err, blindfold.ErrRecipientMismatch) {
+		t.Errorf("Expected newSealer to raise %v, got %v", blindfold.ErrRecipientMismatch, err)
+	}
+}
+
+// Verify that files and standard input are sealed and written in each output format.
+func TestSealInputs(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "a.txt")
+	if err := os.WriteFile(path, []byte("file"), 0o600); err != nil {
+		t.Fatalf("Failed to write plaintext: %v", err)
+	}
+	s := &sealer{
+		seal: func(_ context.Context, plaintext []byte) ([]byte, error) {
+			return []byte("sealed-" + string(plaintext) + "\n"), nil
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	inputs := []input{{path: path}, {target: "/etc/app/b.txt", path: stdinInput}}
+	sealed, err := s.sealInputs(ctx, inputs, strings.NewReader("stdin"))
+	if err != nil {
+		t.Fatalf("sealInputs raised an unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := writeOutput(&buf, outputBase64, inputs, sealed); err != nil {
+		t.Errorf("writeOutput raised an unexpected error: %v", err)
+	}
+	if buf.String() != "sealed-file\nsealed-stdin\n" {
+		t.Errorf("Unexpected base64 output %q", buf.String())
+	}
+	buf.Reset()
+	if err := writeOutput(&buf, outputSpec, inputs, sealed); err != nil {
+		t.Errorf("writeOutput raised an unexpected error: %v", err)
+	}
+	var spec map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &spec); err != nil {
+		t.Fatalf("Failed to parse spec output: %v", err)
+	}
+	if len(spec) != 2 || spec[path] != "sealed-file" || spec["/etc/app/b.txt"] != "sealed-stdin" {
+		t.Errorf("Unexpected spec output %v", spec)
+	}
+	if _, err := s.sealInputs(ctx, []input{{path: filepath.Join(tmpDir, "missing")}}, nil); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected sealInputs to raise %v, got %v", os.ErrNotExist, err)
+	}
+}
+
+// Verify that a provenance attestation of the sealed inputs is written, signed, and names each subject.
+func TestWriteProvenance(t *testing.T) {
+	t.Parallel()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate signing key: %v", err)
+	}
+	s := &sealer{
+		pubKey: &f5xc.PublicKey{KeyVersion: 2, Tenant: "test"},
+		policyDoc: &f5xc.SecretPolicyDocument{
+			Metadata: &f5xc.Metadata{Name: "test", Namespace: "shared", Tenant: "test"},
+			PolicyID: "test-policy",
+		},
+	}
+	opts := &sealOptions{output: outputSpec, inputs: []input{{path: "a.txt"}, {target: "/etc/app/b.txt", path: stdinInput}}}
+	path := filepath.Join(t.TempDir(), "provenance.json")
+	tool := blindfold.Tool{Name: "f5xc", Version: "1.2.3"}
+	if err := s.writeProvenance(path, key, tool, opts.subjectNames(), [][]byte{[]byte("sealed-a"), []byte("sealed-b")}); err != nil {
+		t.Fatalf("writeProvenance raised an unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read provenance: %v", err)
+	}
+	var attestation blindfold.Attestation
+	if err := json.Unmarshal(data, &attestation); err != nil {
+		t.Fatalf("Failed to parse provenance: %v", err)
+	}
+	statement, err := attestation.Verify(key.Public())
+	if err != nil {
+		t.Fatalf("Verify raised an unexpected error: %v", err)
+	}
+	absPath, _ := filepath.Abs("a.txt")
+	predicate := statement.Predicate
+	switch {
+	case len(statement.Subject) != 2:
+		t.Errorf("Expected 2 subjects, got %v", statement.Subject)
+	case statement.Subject[0].Name != "/etc/app/b.txt" || statement.Subject[1].Name != absPath:
+		t.Errorf("Unexpected subject names %v", statement.Subject)
+	case predicate.Tool != tool || predicate.Tenant != "test" || predicate.KeyVersion != 2 || predicate.PolicyID != "test-policy":
+		t.Errorf("Unexpected predicate %+v", predicate)
+	}
+}
+
+// Verify that a Terraform external data source query is sealed and returned as a JSON object.
+func TestSealTerraformQuery(t *testing.T) {
+	t.Parallel()
+	s := &sealer{
+		seal: func(_ context.Context, plaintext []byte) ([]byte, error) {
+			return []byte("sealed-" + string(plaintext) + "\n"), nil
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var buf bytes.Buffer
+	if err := s.sealTerraformQuery(ctx, strings.NewReader(`{"password":"secret","token":""}`), &buf); err != nil {
+		t.Fatalf("sealTerraformQuery raised an unexpected error: %v", err)
+	}
+	var result map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse terraform result: %v", err)
+	}
+	if len(result) != 2 || result["password"] != "sealed-secret" || result["token"] != "sealed-" {
+		t.Errorf("Unexpected terraform result %v", result)
+	}
+	for _, query := range []string{"", `{"count":1}`, `["a"]`} {
+		if err := s.sealTerraformQuery(ctx, strings.NewReader(query), io.Discard); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("Expected sealTerraformQuery(%q) to raise %v, got %v", query, ErrInvalidInput, err)
+		}
+	}
+}
+
+// Verify that annotated Secrets in a manifest stream or KRM ResourceList are sealed, and other manifests are unchanged.
+func TestSealManifests(t *testing.T) {
+	t.Parallel()
+	s := &sealer{
+		seal: func(_ context.Context, plaintext []byte) ([]byte, error) {
+			return []byte("sealed-" + string(plaintext) + "\n"), nil
+		},
+	}
+	tests := []struct {
+		name          string
+		input         string
+		expected      []string
+		unexpected    []string
+		expectedError error
+	}{
+		{
+			name: "stream",
+			input: `apiVersion: v1
+kind: Secret
+metadata:
+  name: all
+  annotations:
+    seal.f5xc/seal: "true"
+data:
+  password: c2VjcmV0
+stringData:
+  token: abc
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: some
+  annotations:
+    seal.f5xc/seal: username
+stringData:
+  username: admin
+  host: db
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  annotations:
+    seal.f5xc/seal: "true"
+data:
+  key: value
+`,
+			expected: []string{
+				"password: sealed-secret",
+				"token: sealed-abc",
+				"username: sealed-admin",
+				"host: db",
+				"seal.f5xc/sealed: shared/test",
+				"key: value",
+			},
+			unexpected: []string{"c2VjcmV0", "seal.f5xc/seal: username"},
+		},
+		{
+			name: "resource-list",
+			input: `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: all
+      annotations:
+        seal.f5xc/seal: "true"
+    stringData:
+      password: secret
+`,
+			expected: []string{"kind: ResourceList", "password: sealed-secret"},
+		},
+		{
+			name: "missing-key",
+			input: `apiVersion: v1
+kind: Secret
+metadata:
+  annotations:
+    seal.f5xc/seal: password
+stringData:
+  token: abc
+`,
+			expectedError: ErrInvalidInput,
+		},
+		{
+			name: "invalid-data",
+			input: `apiVersion: v1
+kind: Secret
+metadata:
+  annotations:
+    seal.f5xc/seal: "true"
+data:
+  token: "&&&"
+`,
+			expectedError: ErrInvalidInput,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			m, err := readManifests(strings.NewReader(tst.input))
+			if err != nil {
+				t.Fatalf("readManifests raised an unexpected error: %v", err)
+			}
+			var buf bytes.Buffer
+			err = s.sealManifests(ctx, m, "shared/test", &buf)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Fatalf("sealManifests raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Fatalf("Expected sealManifests to raise %v, got %v", tst.expectedError, err)
+			}
+			output := buf.String()
+			for _, expected := range tst.expected {
+				if !strings.Contains(output, expected) {
+					t.Errorf("Expected output to contain %q, got %s", expected, output)
+				}
+			}
+			for _, unexpected := range tst.unexpected {
+				if strings.Contains(output, unexpected) {
+					t.Errorf("Expected output not to contain %q, got %s", unexpected, output)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/blindfold"
+	"github.com/memes/f5xc/internal/unseal"
+	"github.com/memes/f5xc/wingman"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// The stage that retrieves the public key, which verifies that the API is reachable and accepts the credentials.
+	stageAPI = "api"
+	// The stage that retrieves the secret policy document.
+	stagePolicy = "policy"
+	// The stage that seals the nonce with vesctl.
+	stageSeal = "seal"
+	// The stage that checks that Wingman reports it is ready.
+	stageWingman = "wingman"
+	// The stage that unseals the sealed nonce through Wingman.
+	stageUnseal = "unseal"
+	// The stage that decodes the unsealed data and compares it with the nonce.
+	stageDecode = "decode"
+
+	// The status of a stage that succeeded.
+	stageOK = "ok"
+	// The status of a stage that failed.
+	stageFailed = "failed"
+	// The status of a stage that was not attempted because an earlier stage failed.
+	stageSkipped = "skipped"
+
+	// The number of random bytes in the nonce that is sealed.
+	selfTestNonceSize = 16
+)
+
+var (
+	// ErrSelfTestFailed is returned when a stage of the self-test fails; the error names the stage.
+	ErrSelfTestFailed = errors.New("self-test failed")
+	// ErrRoundTripMismatch is returned when the data unsealed by Wingman is not the nonce that was sealed.
+	ErrRoundTripMismatch = errors.New("unsealed data does not match the sealed nonce")
+)
+
+// Defines the options of the selftest command.
+type selfTestOptions struct {
+	policy     string
+	namespace  string
+	keyVersion int
+	vesctl     string
+	wingmanURL string
+	output     string
+	timeout    time.Duration
+}
+
+// The result of a stage of the self-test.
+type selfTestStage struct {
+	Name   string `json:"name" yaml:"name"`
+	Status string `json:"status" yaml:"status"`
+	// The time taken by the stage, omitted if it was skipped.
+	Duration string `json:"duration,omitempty" yaml:"duration,omitempty"`
+	Error    string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// The output of the selftest command.
+type selfTestReport struct {
+	Passed bool            `json:"passed" yaml:"passed"`
+	Stages []selfTestStage `json:"stages" yaml:"stages"`
+}
+
+// Performs each stage of a round trip through the API, vesctl, and Wingman, in order.
+type selfTest struct {
+	client     *http.Client
+	wingman    wingman.Client
+	policy     string
+	namespace  string
+	keyVersion int
+	// Returns a function that seals plaintext with the public key and policy document.
+	newSeal func(pubKey *f5xc.PublicKey, policyDoc *f5xc.SecretPolicyDocument) func(ctx context.Context, plaintext []byte) ([]byte, error)
+}
+
+// Returns the selftest command, which seals a random nonce and unseals it through Wingman to verify that every part of
+// the sealing and unsealing path is working.
+func newSelftestCommand(cfg *clientConfig, getenv func(string) string) *cobra.Command {
+	opts := &selfTestOptions{}
+	cmd := &cobra.Command{
+		Use:   "selftest --policy NAME [flags]",
+		Short: "Verify that data can be sealed with the API and unsealed through Wingman",
+		Long: `Seal a random nonce with the tenant public key and the named secret policy, unseal it through Wingman, and verify
+that the unsealed data is the nonce. Each stage is reported in order, and stages after a failure are skipped, so that
+the broken part of an environment can be identified:
+
+  api      retrieve the public key, which checks the API URL and credentials
+  policy   retrieve the secret policy document
+  seal     seal the nonce with vesctl
+  wingman  check that Wingman reports it is ready
+  unseal   unseal the sealed nonce through Wingman, which checks that the policy allows this workload
+  decode   decode the unsealed data and compare it with the nonce
+
+The command fails if any stage fails. Run it where the workload runs, so that Wingman identifies the same client.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			switch {
+			case opts.policy == "":
+				return ErrMissingPolicy
+			case opts.timeout < 0:
+				return fmt.Errorf("timeout must not be negative: %w", ErrInvalidArguments)
+			case opts.output != outputYAML && opts.output != outputJSON:
+				return fmt.Errorf("unsupported output format %q: %w", opts.output, ErrInvalidArguments)
+			}
+			if !cmd.Flags().Changed("wingman-url") {
+				if value := getenv(unseal.EnvWingmanURL); value != "" {
+					opts.wingmanURL = value
+				}
+			}
+			if err := cfg.complete(cmd.Flags(), getenv); err != nil {
+				return err
+			}
+			return opts.run(cmd.Context(), cfg, cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().StringVar(&opts.policy, "policy", "", "The name of the secret policy that will be allowed to unseal the nonce")
+	cmd.Flags().StringVar(&opts.namespace, "namespace", DefaultNamespace, "The namespace of the secret policy")
+	cmd.Flags().IntVar(&opts.keyVersion, "key-version", 0, "The version of the public key to use; 0 to use the current version")
+	cmd.Flags().StringVar(&opts.vesctl, "vesctl", blindfold.VesctlExecutable, "The name or path of the vesctl executable")
+	cmd.Flags().StringVar(&opts.wingmanURL, "wingman-url", wingman.DefaultWingmanURL, "The base URL of the Wingman service that will unseal the nonce; defaults to "+unseal.EnvWingmanURL+" if set")
+	cmd.Flags().StringVar(&opts.output, "output", outputYAML, "The output format; one of yaml or json")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 30*time.Second, "The maximum time to wait for the self-test to complete; 0 to wait indefinitely")
+	return cmd
+}
+
+// Creates the API and Wingman clients, runs the self-test, and writes the report.
+func (o *selfTestOptions) run(ctx context.Context, cfg *clientConfig, stdout io.Writer) error {
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+	client, err := cfg.newClient()
+	if err != nil {
+		return err
+	}
+	defer client.CloseIdleConnections()
+	wingmanClient, err := wingman.NewClient(o.wingmanURL)
+	if err != nil {
+		return fmt.Errorf("failed to create wingman client: %w", err)
+	}
+	defer wingmanClient.Close()
+	st := &selfTest{
+		client:     client,
+		wingman:    wingmanClient,
+		policy:     o.policy,
+		namespace:  o.namespace,
+		keyVersion: o.keyVersion,
+		newSeal: func(pubKey *f5xc.PublicKey, policyDoc *f5xc.SecretPolicyDocument) func(context.Context, []byte) ([]byte, error) {
+			return newVesctlSealer(o.vesctl, pubKey, policyDoc).seal
+		},
+	}
+	report := st.run(ctx)
+	if err := writeObject(stdout, o.output, report); err != nil {
+		return err
+	}
+	return report.result()
+}
+
+// Runs each stage in order, skipping the stages that follow a failure, and returns the report.
+func (st *selfTest) run(ctx context.Context) *selfTestReport {
+	var (
+		pubKey    *f5xc.PublicKey
+		policyDoc *f5xc.SecretPolicyDocument
+		plaintext []byte
+		sealed    []byte
+		unsealed  []byte
+	)
+	stages := []struct {
+		name string
+		fn   func() error
+	}{
+		{
+			name: stageAPI,
+			fn: func() (err error) {
+				pubKey, err = fetchPublicKey(ctx, st.client, st.keyVersion)
+				return err
+			},
+		},
+		{
+			name: stagePolicy,
+			fn: func() (err error) {
+				policyDoc, err = fetchPolicyDocument(ctx, st.client, st.namespace, st.policy)
+				return err
+			},
+		},
+		{
+			name: stageSeal,
+			fn: func() (err error) {
+				nonce := make([]byte, selfTestNonceSize)
+				if _, err = rand.Read(nonce); err != nil {
+					return fmt.Errorf("failed to generate nonce: %w", err)
+				}
+				plaintext = []byte(hex.EncodeToString(nonce))
+				sealed, err = st.newSeal(pubKey, policyDoc)(ctx, plaintext)
+				return err
+			},
+		},
+		{
+			name: stageWingman,
+			fn: func() error {
+				return st.wingman.Ready(ctx) //nolint:wrapcheck // The stage name identifies the source of the error
+			},
+		},
+		{
+			name: stageUnseal,
+			fn: func() (err error) {
+				unsealed, err = st.wingman.UnsealEncoded(ctx, bytes.TrimSpace(sealed))
+				return err //nolint:wrapcheck // The stage name identifies the source of the error
+			},
+		},
+		{
+			name: stageDecode,
+			fn: func() error {
+				if !bytes.Equal(bytes.TrimSpace(unsealed), plaintext) {
+					return ErrRoundTripMismatch
+				}
+				return nil
+			},
+		},
+	}
+	report := &selfTestReport{Passed: true, Stages: make([]selfTestStage, 0, len(stages))}
+	for _, stage := range stages {
+		result := selfTestStage{Name: stage.name, Status: stageSkipped}
+		if report.Passed {
+			start := time.Now()
+			err := stage.fn()
+			result.Duration = time.Since(start).Round(time.Millisecond).String()
+			result.Status = stageOK
+			if err != nil {
+				result.Status = stageFailed
+				result.Error = err.Error()
+				report.Passed = false
+			}
+			slog.Debug("Self-test stage complete", "stage", stage.name, "status", result.Status, "error", err)
+		}
+		report.Stages = append(report.Stages, result)
+	}
+	return report
+}
+
+// Returns an error that names the first failed stage, or nil if every stage succeeded.
+func (r *selfTestReport) result() error {
+	for _, stage := range r.Stages {
+		if stage.Status == stageFailed {
+			return fmt.Errorf("stage %s: %w", stage.Name, ErrSelfTestFailed)
+		}
+	}
+	return nil
+}
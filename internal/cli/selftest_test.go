@@ -0,0 +1,257 @@
+package cli
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/f5xctest"
+	"github.com/memes/f5xc/wingman"
+)
+
+// Implements a fake Wingman that reports the status, and unseals by decoding base64, applying ROT13, and encoding the
+// result as base64.
+func testWingmanHandler(t *testing.T, status string) http.Handler {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+wingman.StatusEndpoint, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(status))
+	})
+	mux.HandleFunc("POST "+wingman.UnsealEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Location string `json:"location"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(payload.Location, "string:///"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(base64.StdEncoding.EncodeToString([]byte(rot13(string(data))))))
+	})
+	return mux
+}
+
+// Returns the ROT13 transformation of s.
+func rot13(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			return (r-'A'+13)%26 + 'A'
+		case r >= 'a' && r <= 'z':
+			return (r-'a'+13)%26 + 'a'
+		}
+		return r
+	}, s)
+}
+
+// Verify that each stage is reported, and that the stages after a failure are skipped.
+func TestSelfTest_Run(t *testing.T) {
+	t.Parallel()
+	var keyQuery string
+	apiServer := httptest.NewTLSServer(testAPIHandler(t, &keyQuery))
+	t.Cleanup(apiServer.Close)
+	caCert := testCACert(t, apiServer)
+	readyServer := httptest.NewServer(testWingmanHandler(t, "READY"))
+	t.Cleanup(readyServer.Close)
+	notReadyServer := httptest.NewServer(testWingmanHandler(t, "NOT_READY"))
+	t.Cleanup(notReadyServer.Close)
+	// Seals by applying ROT13 so that the fake Wingman returns the plaintext.
+	rot13Seal := func(_ context.Context, plaintext []byte) ([]byte, error) {
+		return []byte(base64.StdEncoding.EncodeToString([]byte(rot13(string(plaintext))))), nil
+	}
+	errSeal := errors.New("vesctl failed")
+	tests := []struct {
+		name       string
+		apiToken   string
+		policy     string
+		wingmanURL string
+		seal       func(ctx context.Context, plaintext []byte) ([]byte, error)
+		expected   []string
+	}{
+		{
+			name:       "passed",
+			apiToken:   "test-token",
+			policy:     "test",
+			wingmanURL: readyServer.URL,
+			seal:       rot13Seal,
+			expected:   []string{stageOK, stageOK, stageOK, stageOK, stageOK, stageOK},
+		},
+		{
+			name:       "unauthorized",
+			apiToken:   "bad-token",
+			policy:     "test",
+			wingmanURL: readyServer.URL,
+			seal:       rot13Seal,
+			expected:   []string{stageFailed, stageSkipped, stageSkipped, stageSkipped, stageSkipped, stageSkipped},
+		},
+		{
+			name:       "missing-policy",
+			apiToken:   "test-token",
+			policy:     "missing",
+			wingmanURL: readyServer.URL,
+			seal:       rot13Seal,
+			expected:   []string{stageOK, stageFailed, stageSkipped, stageSkipped, stageSkipped, stageSkipped},
+		},
+		{
+			name:       "seal-failed",
+			apiToken:   "test-token",
+			policy:     "test",
+			wingmanURL: readyServer.URL,
+			seal: func(context.Context, []byte) ([]byte, error) {
+				return nil, errSeal
+			},
+			expected: []string{stageOK, stageOK, stageFailed, stageSkipped, stageSkipped, stageSkipped},
+		},
+		{
+			name:       "wingman-not-ready",
+			apiToken:   "test-token",
+			policy:     "test",
+			wingmanURL: notReadyServer.URL,
+			seal:       rot13Seal,
+			expected:   []string{stageOK, stageOK, stageOK, stageFailed, stageSkipped, stageSkipped},
+		},
+		{
+			name:       "unseal-failed",
+			apiToken:   "test-token",
+			policy:     "test",
+			wingmanURL: readyServer.URL,
+			seal: func(context.Context, []byte) ([]byte, error) {
+				return []byte("not base64!"), nil
+			},
+			expected: []string{stageOK, stageOK, stageOK, stageOK, stageFailed, stageSkipped},
+		},
+		{
+			name:       "mismatch",
+			apiToken:   "test-token",
+			policy:     "test",
+			wingmanURL: readyServer.URL,
+			seal: func(_ context.Context, plaintext []byte) ([]byte, error) {
+				return []byte(base64.StdEncoding.EncodeToString(plaintext)), nil
+			},
+			expected: []string{stageOK, stageOK, stageOK, stageOK, stageOK, stageFailed},
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &clientConfig{
+				apiURL:   apiServer.URL + "/api",
+				apiToken: tst.apiToken,
+				caCert:   caCert,
+			}
+			client, err := cfg.newClient()
+			if err != nil {
+				t.Fatalf("newClient raised an unexpected error: %v", err)
+			}
+			t.Cleanup(client.CloseIdleConnections)
+			wingmanClient, err := wingman.NewClient(tst.wingmanURL)
+			if err != nil {
+				t.Fatalf("NewClient raised an unexpected error: %v", err)
+			}
+			t.Cleanup(func() { _ = wingmanClient.Close() })
+			st := &selfTest{
+				client:    client,
+				wingman:   wingmanClient,
+				policy:    tst.policy,
+				namespace: DefaultNamespace,
+				newSeal: func(*f5xc.PublicKey, *f5xc.SecretPolicyDocument) func(context.Context, []byte) ([]byte, error) {
+					return tst.seal
+				},
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			report := st.run(ctx)
+			names := []string{stageAPI, stagePolicy, stageSeal, stageWingman, stageUnseal, stageDecode}
+			if len(report.Stages) != len(names) {
+				t.Fatalf("Expected %d stages, got %d", len(names), len(report.Stages))
+			}
+			for i, stage := range report.Stages {
+				if stage.Name != names[i] || stage.Status != tst.expected[i] {
+					t.Errorf("Expected stage %d to be %s %s, got %s %s", i, names[i], tst.expected[i], stage.Name, stage.Status)
+				}
+				if (stage.Status == stageFailed) != (stage.Error != "") {
+					t.Errorf("Expected only a failed stage to have an error, got %s %s %q", stage.Name, stage.Status, stage.Error)
+				}
+			}
+			err = report.result()
+			switch {
+			case report.Passed && err != nil:
+				t.Errorf("result raised an unexpected error: %v", err)
+			case !report.Passed && !errors.Is(err, ErrSelfTestFailed):
+				t.Errorf("Expected result to raise %v, got %v", ErrSelfTestFailed, err)
+			}
+		})
+	}
+}
+
+// Verify that the self test passes against a fake API and Wingman that share a key pair, with data sealed by the
+// public key and policy retrieved from the fake API.
+func TestSelfTest_Run_RoundTrip(t *testing.T) {
+	t.Parallel()
+	server := f5xctest.NewServer(t)
+	wingmanClient, err := wingman.NewClient(server.WingmanURL())
+	if err != nil {
+		t.Fatalf("NewClient raised an unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = wingmanClient.Close() })
+	st := &selfTest{
+		client:    server.Client(t),
+		wingman:   wingmanClient,
+		policy:    f5xctest.DefaultPolicy,
+		namespace: f5xctest.DefaultNamespace,
+		newSeal: func(pubKey *f5xc.PublicKey, policyDoc *f5xc.SecretPolicyDocument) func(context.Context, []byte) ([]byte, error) {
+			return func(ctx context.Context, plaintext []byte) ([]byte, error) {
+				return f5xctest.Seal(ctx, plaintext, pubKey, policyDoc)
+			}
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := st.run(ctx).result(); err != nil {
+		t.Errorf("Expected the self test to pass, got %v", err)
+	}
+}
+
+// Verify that the report is written in the requested format and names the failed stage.
+func TestSelfTestOptions_Run(t *testing.T) {
+	t.Parallel()
+	var keyQuery string
+	apiServer := httptest.NewTLSServer(testAPIHandler(t, &keyQuery))
+	t.Cleanup(apiServer.Close)
+	wingmanServer := httptest.NewServer(testWingmanHandler(t, "READY"))
+	t.Cleanup(wingmanServer.Close)
+	cfg := &clientConfig{
+		apiURL:   apiServer.URL + "/api",
+		apiToken: "test-token",
+		caCert:   testCACert(t, apiServer),
+	}
+	opts := &selfTestOptions{
+		policy:     "missing",
+		namespace:  DefaultNamespace,
+		wingmanURL: wingmanServer.URL,
+		output:     outputJSON,
+	}
+	var stdout strings.Builder
+	err := opts.run(context.Background(), cfg, &stdout)
+	if !errors.Is(err, ErrSelfTestFailed) || !strings.Contains(err.Error(), "stage policy") {
+		t.Errorf("Expected run to raise %v for the policy stage, got %v", ErrSelfTestFailed, err)
+	}
+	var report selfTestReport
+	if err := json.NewDecoder(strings.NewReader(stdout.String())).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if report.Passed || len(report.Stages) != 6 || report.Stages[1].Status != stageFailed {
+		t.Errorf("Expected the report to show a failed policy stage, got %+v", report)
+	}
+}
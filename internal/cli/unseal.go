@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"github.com/memes/f5xc/internal/unseal"
+	"github.com/spf13/cobra"
+)
+
+// Returns the unseal command, which passes every argument to the unseal utility unchanged so that it behaves
+// identically to the standalone unseal binary; it does not use the client flags.
+func newUnsealCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:                "unseal [flags] FILE...",
+		Short:              "Unseal specifications of sealed data through Wingman",
+		Long:               "Unseal specifications of sealed data through Wingman; run f5xc unseal --help for the flags.",
+		DisableFlagParsing: true,
+		RunE: func(_ *cobra.Command, args []string) error {
+			if code := unseal.Run(args); code != 0 {
+				return exitCodeError(code)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,293 @@
+// Package credhelper implements the Docker credential helper protocol with registry credentials stored as blindfold
+// sealed data, which are unsealed through Wingman on request. It backs the docker-credential-f5xc binary; see
+// cmd/docker-credential-f5xc for usage.
+package credhelper
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/memes/f5xc"
+	// Registers the f5xc keeper URL scheme.
+	_ "github.com/memes/f5xc/f5xcsecrets"
+	"gocloud.dev/secrets"
+)
+
+const (
+	// The environment variable that can be set to change the directory where sealed credentials are stored.
+	EnvDir = envPrefix + "DIR"
+	// The environment variable that sets the gocloud.dev secrets keeper URL used to seal and unseal credentials.
+	EnvKeeperURL = envPrefix + "KEEPER_URL"
+	// The environment variable that sets the maximum time to wait for sealing or unsealing.
+	EnvTimeout = envPrefix + "TIMEOUT"
+	// The default keeper URL, which can unseal credentials through the default Wingman endpoint but not seal them.
+	DefaultKeeperURL = "f5xc://"
+	// The default maximum time to wait for sealing or unsealing.
+	DefaultTimeout = 30 * time.Second
+	// The message that tells Docker that a registry does not have stored credentials.
+	notFoundMessage = "credentials not found in native keychain"
+)
+
+// The prefix of environment variables that configure the helper.
+const envPrefix = "DOCKER_CREDENTIAL_F5XC_"
+
+// The exit code returned when an action fails.
+const exitFailure = 1
+
+var (
+	// ErrCredentialsNotFound is returned when there are no stored credentials for a registry.
+	ErrCredentialsNotFound = errors.New(notFoundMessage)
+	// ErrInvalidCredentials is returned when credentials to store are incomplete.
+	ErrInvalidCredentials = errors.New("invalid registry credentials")
+	// ErrUnknownAction is returned when the helper is invoked with an unsupported action.
+	ErrUnknownAction = errors.New("unknown credential helper action")
+)
+
+// The credentials exchanged with Docker.
+type credentials struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// The credentials as stored in a file, where the secret has been sealed by the keeper.
+type sealedCredentials struct {
+	ServerURL string `json:"serverURL"`
+	Username  string `json:"username"`
+	Sealed    []byte `json:"sealed"`
+}
+
+// Stores sealed registry credentials as JSON files in a directory.
+type helper struct {
+	dir    string
+	keeper func(ctx context.Context) (*secrets.Keeper, error)
+}
+
+// Returns the path of the file containing the credentials for the registry.
+func (h *helper) path(serverURL string) string {
+	digest := sha256.Sum256([]byte(serverURL))
+	return filepath.Join(h.dir, hex.EncodeToString(digest[:])+".json")
+}
+
+// Seals the secret of the credentials and writes them to the directory, replacing any existing credentials for the
+// registry.
+func (h *helper) store(ctx context.Context, creds *credentials) error {
+	if creds.ServerURL == "" || creds.Secret == "" {
+		return fmt.Errorf("server URL and secret are required: %w", ErrInvalidCredentials)
+	}
+	keeper, err := h.keeper(ctx)
+	if err != nil {
+		return err
+	}
+	defer keeper.Close()
+	sealed, err := keeper.Encrypt(ctx, []byte(creds.Secret))
+	if err != nil {
+		return fmt.Errorf("failed to seal secret: %w", err)
+	}
+	data, err := json.Marshal(&sealedCredentials{
+		ServerURL: creds.ServerURL,
+		Username:  creds.Username,
+		Sealed:    sealed,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+	if err := os.MkdirAll(h.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create credentials directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(h.dir, ".credentials")
+	if err != nil {
+		return fmt.Errorf("failed to create credentials file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close credentials file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), h.path(creds.ServerURL)); err != nil {
+		return fmt.Errorf("failed to replace credentials file: %w", err)
+	}
+	return nil
+}
+
+// Reads the stored credentials for the registry, without unsealing the secret.
+func (h *helper) read(serverURL string) (*sealedCredentials, error) {
+	data, err := os.ReadFile(h.path(serverURL))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, ErrCredentialsNotFound
+	case err != nil:
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	var creds sealedCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials file: %w", err)
+	}
+	return &creds, nil
+}
+
+// Returns the credentials for the registry with the secret unsealed.
+func (h *helper) get(ctx context.Context, serverURL string) (*credentials, error) {
+	creds, err := h.read(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	keeper, err := h.keeper(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer keeper.Close()
+	secret, err := keeper.Decrypt(ctx, creds.Sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unseal secret: %w", err)
+	}
+	return &credentials{
+		ServerURL: creds.ServerURL,
+		Username:  creds.Username,
+		Secret:    string(secret),
+	}, nil
+}
+
+// Removes the stored credentials for the registry.
+func (h *helper) erase(serverURL string) error {
+	err := os.Remove(h.path(serverURL))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrCredentialsNotFound
+	case err != nil:
+		return fmt.Errorf("failed to remove credentials file: %w", err)
+	}
+	return nil
+}
+
+// Returns a map of registry server URL to username for all stored credentials.
+func (h *helper) list() (map[string]string, error) {
+	result := map[string]string{}
+	paths, err := filepath.Glob(filepath.Join(h.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials files: %w", err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read credentials file: %w", err)
+		}
+		var creds sealedCredentials
+		if err := json.Unmarshal(data, &creds); err != nil {
+			return nil, fmt.Errorf("failed to parse credentials file %s: %w", path, err)
+		}
+		result[creds.ServerURL] = creds.Username
+	}
+	return result, nil
+}
+
+// Executes the action with the input read from stdin, writing the result to stdout.
+func (h *helper) execute(ctx context.Context, action string, stdin io.Reader, stdout io.Writer) error {
+	input, err := io.ReadAll(stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	serverURL := strings.TrimSpace(string(input))
+	var result any
+	switch action {
+	case "store":
+		var creds credentials
+		if err := json.NewDecoder(bytes.NewReader(input)).Decode(&creds); err != nil {
+			return fmt.Errorf("failed to parse credentials: %w: %w", err, ErrInvalidCredentials)
+		}
+		return h.store(ctx, &creds)
+	case "get":
+		if result, err = h.get(ctx, serverURL); err != nil {
+			return err
+		}
+	case "erase":
+		return h.erase(serverURL)
+	case "list":
+		if result, err = h.list(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%q: %w", action, ErrUnknownAction)
+	}
+	if err := json.NewEncoder(stdout).Encode(result); err != nil {
+		return fmt.Errorf("failed to write result: %w", err)
+	}
+	return nil
+}
+
+// Returns the directory where credentials are stored by default.
+func defaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".docker", "f5xc-credentials"), nil
+}
+
+// Run executes the credential helper action given as the only argument, reading input from stdin and writing the
+// result to stdout, and returns the exit code. As the protocol requires, errors are written to stdout. The version
+// action reports the version; if empty the version of the f5xc module is used.
+func Run(version string, args []string, stdin io.Reader, stdout io.Writer, getenv func(string) string) int {
+	if version == "" {
+		version = f5xc.Version()
+	}
+	if len(args) != 1 {
+		fmt.Fprintln(stdout, "Usage: docker-credential-f5xc <store|get|erase|list|version>")
+		return exitFailure
+	}
+	if args[0] == "version" {
+		fmt.Fprintln(stdout, "docker-credential-f5xc", version)
+		return 0
+	}
+	dir := getenv(EnvDir)
+	if dir == "" {
+		var err error
+		if dir, err = defaultDir(); err != nil {
+			fmt.Fprintln(stdout, err)
+			return exitFailure
+		}
+	}
+	keeperURL := getenv(EnvKeeperURL)
+	if keeperURL == "" {
+		keeperURL = DefaultKeeperURL
+	}
+	timeout := DefaultTimeout
+	if value := getenv(EnvTimeout); value != "" {
+		var err error
+		if timeout, err = time.ParseDuration(value); err != nil || timeout <= 0 {
+			fmt.Fprintf(stdout, "%s must be a positive duration\n", EnvTimeout)
+			return exitFailure
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	h := &helper{
+		dir: dir,
+		keeper: func(ctx context.Context) (*secrets.Keeper, error) {
+			keeper, err := secrets.OpenKeeper(ctx, keeperURL)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open keeper: %w", err)
+			}
+			return keeper, nil
+		},
+	}
+	if err := h.execute(ctx, args[0], stdin, stdout); err != nil {
+		fmt.Fprintln(stdout, err)
+		return exitFailure
+	}
+	return 0
+}
@@ -0,0 +1,214 @@
+package credhelper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc/f5xctest"
+	"go.uber.org/goleak"
+	"gocloud.dev/secrets"
+	"gocloud.dev/secrets/localsecrets"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m, f5xctest.IgnoreOpenCensus())
+}
+
+// Returns a helper that stores credentials in a temporary directory, using a local keeper in place of blindfold and
+// Wingman.
+func testHelper(t *testing.T) *helper {
+	t.Helper()
+	key, err := localsecrets.NewRandomKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return &helper{
+		dir: t.TempDir(),
+		keeper: func(_ context.Context) (*secrets.Keeper, error) {
+			return localsecrets.NewKeeper(key), nil
+		},
+	}
+}
+
+// Verify the store, get, list, and erase actions in sequence.
+func TestExecute(t *testing.T) {
+	t.Parallel()
+	h := testHelper(t)
+	steps := []struct {
+		name           string
+		action         string
+		input          string
+		expectedOutput string
+		expectedError  error
+	}{
+		{
+			name:           "empty-list",
+			action:         "list",
+			expectedOutput: `{}`,
+		},
+		{
+			name:          "missing-get",
+			action:        "get",
+			input:         "registry.example.com",
+			expectedError: ErrCredentialsNotFound,
+		},
+		{
+			name:          "invalid-store",
+			action:        "store",
+			input:         `{"ServerURL":"registry.example.com","Username":"user"}`,
+			expectedError: ErrInvalidCredentials,
+		},
+		{
+			name:          "malformed-store",
+			action:        "store",
+			input:         "registry.example.com",
+			expectedError: ErrInvalidCredentials,
+		},
+		{
+			name:   "store",
+			action: "store",
+			input:  `{"ServerURL":"registry.example.com","Username":"user","Secret":"password"}`,
+		},
+		{
+			name:   "store-other",
+			action: "store",
+			input:  `{"ServerURL":"https://index.docker.io/v1/","Username":"other","Secret":"token"}`,
+		},
+		{
+			name:           "get",
+			action:         "get",
+			input:          "registry.example.com\n",
+			expectedOutput: `{"ServerURL":"registry.example.com","Username":"user","Secret":"password"}`,
+		},
+		{
+			name:           "list",
+			action:         "list",
+			expectedOutput: `{"https://index.docker.io/v1/":"other","registry.example.com":"user"}`,
+		},
+		{
+			name:   "erase",
+			action: "erase",
+			input:  "registry.example.com",
+		},
+		{
+			name:          "erase-again",
+			action:        "erase",
+			input:         "registry.example.com",
+			expectedError: ErrCredentialsNotFound,
+		},
+		{
+			name:           "list-after-erase",
+			action:         "list",
+			expectedOutput: `{"https://index.docker.io/v1/":"other"}`,
+		},
+		{
+			name:          "unknown",
+			action:        "lookup",
+			expectedError: ErrUnknownAction,
+		},
+	}
+	for _, step := range steps {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var stdout bytes.Buffer
+		err := h.execute(ctx, step.action, strings.NewReader(step.input), &stdout)
+		cancel()
+		switch {
+		case step.expectedError == nil && err != nil:
+			t.Fatalf("%s: execute raised an unexpected error: %v", step.name, err)
+		case step.expectedError != nil && !errors.Is(err, step.expectedError):
+			t.Fatalf("%s: Expected execute to raise %v, got %v", step.name, step.expectedError, err)
+		}
+		if output := strings.TrimSpace(stdout.String()); output != step.expectedOutput {
+			t.Errorf("%s: Expected output %q, got %q", step.name, step.expectedOutput, output)
+		}
+	}
+}
+
+// Verify that secrets are not stored in plaintext.
+func TestStoreSealed(t *testing.T) {
+	t.Parallel()
+	h := testHelper(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.store(ctx, &credentials{ServerURL: "registry.example.com", Username: "user", Secret: "password"}); err != nil {
+		t.Fatalf("store raised an unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(h.path("registry.example.com"))
+	if err != nil {
+		t.Fatalf("failed to read credentials file: %v", err)
+	}
+	var creds sealedCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		t.Fatalf("failed to parse credentials file: %v", err)
+	}
+	if len(creds.Sealed) == 0 || bytes.Contains(creds.Sealed, []byte("password")) {
+		t.Errorf("Expected a sealed secret, got %q", string(creds.Sealed))
+	}
+}
+
+// Verify the exit codes and output of Run for actions that do not require a keeper.
+func TestRun(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	tests := []struct {
+		name           string
+		args           []string
+		env            map[string]string
+		expectedCode   int
+		expectedOutput string
+	}{
+		{
+			name:           "version",
+			args:           []string{"version"},
+			expectedOutput: "docker-credential-f5xc test",
+		},
+		{
+			name:           "no-action",
+			expectedCode:   exitFailure,
+			expectedOutput: "Usage: docker-credential-f5xc <store|get|erase|list|version>",
+		},
+		{
+			name:           "not-found",
+			args:           []string{"get"},
+			expectedCode:   exitFailure,
+			expectedOutput: notFoundMessage,
+		},
+		{
+			name:           "list",
+			args:           []string{"list"},
+			expectedOutput: "{}",
+		},
+		{
+			name:           "invalid-timeout",
+			args:           []string{"list"},
+			env:            map[string]string{EnvTimeout: "-1s"},
+			expectedCode:   exitFailure,
+			expectedOutput: EnvTimeout + " must be a positive duration",
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			getenv := func(key string) string {
+				if key == EnvDir {
+					return dir
+				}
+				return tst.env[key]
+			}
+			var stdout bytes.Buffer
+			code := Run("test", tst.args, strings.NewReader("registry.example.com"), &stdout, getenv)
+			if code != tst.expectedCode {
+				t.Errorf("Expected exit code %d, got %d", tst.expectedCode, code)
+			}
+			if output := strings.TrimSpace(stdout.String()); output != tst.expectedOutput {
+				t.Errorf("Expected output %q, got %q", tst.expectedOutput, output)
+			}
+		})
+	}
+}
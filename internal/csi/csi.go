@@ -0,0 +1,116 @@
+// Package csi implements a Kubernetes Secrets Store CSI driver provider that mounts blindfold sealed objects, unsealed
+// through Wingman, into pods. It backs the blindfold-csi-provider binary; see cmd/blindfold-csi-provider for usage.
+package csi
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/internal/grpcwire"
+	"github.com/memes/f5xc/wingman"
+	"google.golang.org/grpc"
+)
+
+const (
+	// The default path of the unix socket that the Secrets Store CSI driver will use to contact the provider.
+	DefaultEndpoint = "/etc/kubernetes/secrets-store-csi-providers/" + ProviderName + ".sock"
+	// The environment variable name that can be set to override the default wingman base URL.
+	EnvWingmanURL = "CSI_WINGMAN_URL"
+)
+
+// The exit code returned when the provider fails to start or exits with an error.
+const exitFailure = 1
+
+// Defines the command line options for the provider.
+type options struct {
+	endpoint   string
+	wingmanURL string
+	logLevel   slog.Level
+}
+
+// Parses the command line arguments into options.
+func parseArgs(args []string, getenv func(string) string) (*options, error) {
+	opts := &options{}
+	defaultWingmanURL := getenv(EnvWingmanURL)
+	if defaultWingmanURL == "" {
+		defaultWingmanURL = wingman.DefaultWingmanURL
+	}
+	flags := flag.NewFlagSet(ProviderName+"-csi-provider", flag.ContinueOnError)
+	flags.StringVar(&opts.endpoint, "endpoint", DefaultEndpoint, "The path of the unix socket to listen on for driver requests")
+	flags.StringVar(&opts.wingmanURL, "wingman-url", defaultWingmanURL, "The default base URL of the Wingman service; http(s) or grpc(s)")
+	flags.TextVar(&opts.logLevel, "log-level", slog.LevelInfo, "The logging level; one of DEBUG, INFO, WARN, or ERROR")
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("failed to parse arguments: %w", err)
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments %v: %w", flags.Args(), flag.ErrHelp)
+	}
+	if opts.endpoint == "" {
+		return nil, fmt.Errorf("endpoint must not be empty: %w", flag.ErrHelp)
+	}
+	return opts, nil
+}
+
+// Run executes the provider with the command line arguments, excluding the program name, and returns the exit code.
+// The provider will serve driver requests until interrupted, reporting the version as its runtime version; if empty the
+// version of the f5xc module is used.
+func Run(version string, args []string) int {
+	if version == "" {
+		version = f5xc.Version()
+	}
+	level := slog.LevelVar{}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     &level,
+	})))
+	opts, err := parseArgs(args, os.Getenv)
+	if err != nil {
+		slog.Error("Invalid arguments", "error", err)
+		return exitFailure
+	}
+	level.Set(opts.logLevel)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := serve(ctx, opts.endpoint, newProvider(opts.wingmanURL, version)); err != nil {
+		slog.Error("Provider failed", "error", err)
+		return exitFailure
+	}
+	return 0
+}
+
+// Listens on the unix socket endpoint and serves driver requests with the provider until the context is done, at
+// which point in-flight requests are allowed to complete. A stale socket left by a previous instance is removed.
+func serve(ctx context.Context, endpoint string, p *provider) error {
+	logger := slog.With("endpoint", endpoint, "wingmanURL", p.wingmanURL)
+	if err := os.Remove(endpoint); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "unix", endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to listen for driver requests: %w", err)
+	}
+	server := grpc.NewServer(grpc.ForceServerCodec(grpcwire.Codec{}))
+	p.register(server)
+	errs := make(chan error, 1)
+	go func() {
+		logger.Info("Serving provider requests")
+		errs <- server.Serve(listener)
+	}()
+	select {
+	case <-ctx.Done():
+		logger.Info("Stopping provider")
+		server.GracefulStop()
+		return <-errs //nolint:wrapcheck // Serve returns nil after a graceful stop
+	case err := <-errs:
+		return fmt.Errorf("provider server failed: %w", err)
+	}
+}
@@ -0,0 +1,251 @@
+package csi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc/f5xctest"
+	"github.com/memes/f5xc/internal/grpcwire"
+	"go.uber.org/goleak"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+// Starts the provider on a unix socket in a temporary directory and returns a client connection to it.
+func testProviderConn(t *testing.T, wingmanURL string) *grpc.ClientConn {
+	t.Helper()
+	endpoint := filepath.Join(t.TempDir(), ProviderName+".sock")
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- serve(ctx, endpoint, newProvider(wingmanURL, "test"))
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-errs; err != nil {
+			t.Errorf("serve returned an unexpected error: %v", err)
+		}
+	})
+	conn, err := grpc.NewClient("unix://"+endpoint,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(grpcwire.Codec{})),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return conn
+}
+
+// Verify that the provider reports the expected API version.
+func TestVersion(t *testing.T) {
+	t.Parallel()
+	conn := testProviderConn(t, "http://localhost:8070")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp := &VersionResponse{}
+	if err := conn.Invoke(ctx, "/"+serviceName+"/Version", &VersionRequest{Version: APIVersion}, resp, grpc.WaitForReady(true)); err != nil {
+		t.Fatalf("Version raised an unexpected error: %v", err)
+	}
+	if resp.Version != APIVersion || resp.RuntimeName != ProviderName || resp.RuntimeVersion != "test" {
+		t.Errorf("Unexpected version response: %+v", resp)
+	}
+}
+
+// Verify that the provider unseals the objects from the SecretProviderClass parameters.
+func TestMount(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(f5xctest.ROT13WingmanHandler(t))
+	t.Cleanup(server.Close)
+	conn := testProviderConn(t, "http://localhost:1")
+	tests := []struct {
+		name         string
+		attributes   map[string]string
+		permission   string
+		expected     []*File
+		expectedCode codes.Code
+	}{
+		// spell-checker: disable
+		{
+			name: "single",
+			attributes: map[string]string{
+				ParamWingmanURL: server.URL,
+				ParamObjects:    "- objectName: simple.json\n  sealed: ZnZ6Y3lyLndmYmE=\n",
+			},
+			permission: "420",
+			expected: []*File{
+				{Path: "simple.json", Mode: 0o644, Contents: []byte("simple.json")},
+			},
+		},
+		{
+			name: "json-with-mode",
+			attributes: map[string]string{
+				ParamWingmanURL: server.URL,
+				ParamObjects:    `[{"objectName":"a/test","sealed":"R3V2ZiB2ZiBuIGdyZmc=","mode":"0400"},{"objectName":"b","sealed":"ZnZ6Y3lyLndmYmE="}]`,
+			},
+			expected: []*File{
+				{Path: "a/test", Mode: 0o400, Contents: []byte("This is a test")},
+				{Path: "b", Mode: int32(DefaultFileMode), Contents: []byte("simple.json")},
+			},
+		},
+		// spell-checker: enable
+		{
+			name:         "missing-objects",
+			attributes:   map[string]string{ParamWingmanURL: server.URL},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "escaping-path",
+			attributes: map[string]string{
+				ParamWingmanURL: server.URL,
+				ParamObjects:    "- objectName: ../escape\n  sealed: ZnZ6Y3lyLndmYmE=\n",
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "duplicate",
+			attributes: map[string]string{
+				ParamWingmanURL: server.URL,
+				ParamObjects:    "- objectName: a\n  sealed: ZnZ6Y3lyLndmYmE=\n- objectName: a\n  sealed: ZnZ6Y3lyLndmYmE=\n",
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "invalid-mode",
+			attributes: map[string]string{
+				ParamWingmanURL: server.URL,
+				ParamObjects:    "- objectName: a\n  sealed: ZnZ6Y3lyLndmYmE=\n  mode: \"0999\"\n",
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "invalid-permission",
+			attributes: map[string]string{
+				ParamWingmanURL: server.URL,
+				ParamObjects:    "- objectName: a\n  sealed: ZnZ6Y3lyLndmYmE=\n",
+			},
+			permission:   "rw-r--r--",
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "unseal-failure",
+			attributes: map[string]string{
+				ParamWingmanURL: server.URL,
+				ParamObjects:    "- objectName: a\n  sealed: not-base64\n",
+			},
+			expectedCode: codes.Internal,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			attributes, err := json.Marshal(tst.attributes)
+			if err != nil {
+				t.Fatalf("failed to marshal attributes: %v", err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			req := &MountRequest{
+				Attributes: string(attributes),
+				Secrets:    "{}",
+				TargetPath: "/var/lib/kubelet/pods/test/volumes/csi/mount",
+				Permission: tst.permission,
+			}
+			resp := &MountResponse{}
+			err = conn.Invoke(ctx, "/"+serviceName+"/Mount", req, resp, grpc.WaitForReady(true))
+			if code := status.Code(err); code != tst.expectedCode {
+				t.Fatalf("Expected status %s, got %s: %v", tst.expectedCode, code, err)
+			}
+			if err != nil {
+				return
+			}
+			if len(resp.Files) != len(tst.expected) || len(resp.ObjectVersion) != len(tst.expected) {
+				t.Fatalf("Expected %d files and versions, got %+v", len(tst.expected), resp)
+			}
+			for i, expected := range tst.expected {
+				file := resp.Files[i]
+				if file.Path != expected.Path || file.Mode != expected.Mode || string(file.Contents) != string(expected.Contents) {
+					t.Errorf("Expected file %+v, got %+v", expected, file)
+				}
+				if resp.ObjectVersion[i].ID != expected.Path || len(resp.ObjectVersion[i].Version) != 64 {
+					t.Errorf("Unexpected object version %+v", resp.ObjectVersion[i])
+				}
+			}
+		})
+	}
+}
+
+// Verify that the command line arguments are parsed as expected.
+func TestParseArgs(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name               string
+		args               []string
+		env                map[string]string
+		expectedEndpoint   string
+		expectedWingmanURL string
+		expectedError      error
+	}{
+		{
+			name:               "defaults",
+			expectedEndpoint:   DefaultEndpoint,
+			expectedWingmanURL: "http://localhost:8070",
+		},
+		{
+			name:               "env",
+			env:                map[string]string{EnvWingmanURL: "grpc://localhost:8071"},
+			expectedEndpoint:   DefaultEndpoint,
+			expectedWingmanURL: "grpc://localhost:8071",
+		},
+		{
+			name:               "flags",
+			args:               []string{"--endpoint", "/tmp/test.sock", "--wingman-url", "https://wingman:8070"},
+			env:                map[string]string{EnvWingmanURL: "grpc://localhost:8071"},
+			expectedEndpoint:   "/tmp/test.sock",
+			expectedWingmanURL: "https://wingman:8070",
+		},
+		{
+			name:          "extra-args",
+			args:          []string{"unexpected"},
+			expectedError: flag.ErrHelp,
+		},
+		{
+			name:          "empty-endpoint",
+			args:          []string{"--endpoint", ""},
+			expectedError: flag.ErrHelp,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			opts, err := parseArgs(tst.args, func(key string) string {
+				return tst.env[key]
+			})
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Fatalf("parseArgs raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Fatalf("Expected parseArgs to raise %v, got %v", tst.expectedError, err)
+			case err != nil:
+				return
+			}
+			if opts.endpoint != tst.expectedEndpoint || opts.wingmanURL != tst.expectedWingmanURL {
+				t.Errorf("Unexpected options: %+v", opts)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package csi
+
+import (
+	"bytes"
+
+	"github.com/memes/f5xc/internal/grpcwire"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// VersionRequest is sent by the Secrets Store CSI driver to discover the provider's API version.
+type VersionRequest struct {
+	Version string
+}
+
+// Implements grpcwire.Marshaler.
+func (r *VersionRequest) MarshalWire() ([]byte, error) {
+	return grpcwire.AppendString(nil, 1, r.Version), nil
+}
+
+// Implements grpcwire.Unmarshaler.
+func (r *VersionRequest) UnmarshalWire(data []byte) error {
+	return grpcwire.RangeFields(data, func(field grpcwire.Field) error {
+		if field.Number == 1 && field.Type == protowire.BytesType {
+			r.Version = string(field.Bytes)
+		}
+		return nil
+	})
+}
+
+// VersionResponse describes the provider API version and the runtime implementing it.
+type VersionResponse struct {
+	Version        string
+	RuntimeName    string
+	RuntimeVersion string
+}
+
+// Implements grpcwire.Marshaler.
+func (r *VersionResponse) MarshalWire() ([]byte, error) {
+	var b []byte
+	b = grpcwire.AppendString(b, 1, r.Version)
+	b = grpcwire.AppendString(b, 2, r.RuntimeName)
+	b = grpcwire.AppendString(b, 3, r.RuntimeVersion)
+	return b, nil
+}
+
+// Implements grpcwire.Unmarshaler.
+func (r *VersionResponse) UnmarshalWire(data []byte) error {
+	return grpcwire.RangeFields(data, func(field grpcwire.Field) error {
+		if field.Type != protowire.BytesType {
+			return nil
+		}
+		switch field.Number {
+		case 1:
+			r.Version = string(field.Bytes)
+		case 2:
+			r.RuntimeName = string(field.Bytes)
+		case 3:
+			r.RuntimeVersion = string(field.Bytes)
+		}
+		return nil
+	})
+}
+
+// MountRequest is sent by the Secrets Store CSI driver when a volume is mounted, and periodically when rotation is
+// enabled. Attributes, Secrets, and Permission are JSON encoded by the driver.
+type MountRequest struct {
+	Attributes           string
+	Secrets              string
+	TargetPath           string
+	Permission           string
+	CurrentObjectVersion []*ObjectVersion
+}
+
+// Implements grpcwire.Marshaler.
+func (r *MountRequest) MarshalWire() ([]byte, error) {
+	var b []byte
+	b = grpcwire.AppendString(b, 1, r.Attributes)
+	b = grpcwire.AppendString(b, 2, r.Secrets)
+	b = grpcwire.AppendString(b, 3, r.TargetPath)
+	b = grpcwire.AppendString(b, 4, r.Permission)
+	for _, version := range r.CurrentObjectVersion {
+		data, _ := version.MarshalWire()
+		b = grpcwire.AppendMessage(b, 5, data)
+	}
+	return b, nil
+}
+
+// Implements grpcwire.Unmarshaler.
+func (r *MountRequest) UnmarshalWire(data []byte) error {
+	return grpcwire.RangeFields(data, func(field grpcwire.Field) error {
+		if field.Type != protowire.BytesType {
+			return nil
+		}
+		switch field.Number {
+		case 1:
+			r.Attributes = string(field.Bytes)
+		case 2:
+			r.Secrets = string(field.Bytes)
+		case 3:
+			r.TargetPath = string(field.Bytes)
+		case 4:
+			r.Permission = string(field.Bytes)
+		case 5:
+			version := &ObjectVersion{}
+			if err := version.UnmarshalWire(field.Bytes); err != nil {
+				return err
+			}
+			r.CurrentObjectVersion = append(r.CurrentObjectVersion, version)
+		}
+		return nil
+	})
+}
+
+// MountResponse returns the files to be written to the volume, with the versions of the objects they were created
+// from so that the driver can detect rotation.
+type MountResponse struct {
+	ObjectVersion []*ObjectVersion
+	Error         *Error
+	Files         []*File
+}
+
+// Implements grpcwire.Marshaler.
+func (r *MountResponse) MarshalWire() ([]byte, error) {
+	var b []byte
+	for _, version := range r.ObjectVersion {
+		data, _ := version.MarshalWire()
+		b = grpcwire.AppendMessage(b, 1, data)
+	}
+	if r.Error != nil {
+		b = grpcwire.AppendMessage(b, 2, grpcwire.AppendString(nil, 1, r.Error.Code))
+	}
+	for _, file := range r.Files {
+		data, _ := file.MarshalWire()
+		b = grpcwire.AppendMessage(b, 3, data)
+	}
+	return b, nil
+}
+
+// Implements grpcwire.Unmarshaler.
+func (r *MountResponse) UnmarshalWire(data []byte) error {
+	return grpcwire.RangeFields(data, func(field grpcwire.Field) error {
+		if field.Type != protowire.BytesType {
+			return nil
+		}
+		switch field.Number {
+		case 1:
+			version := &ObjectVersion{}
+			if err := version.UnmarshalWire(field.Bytes); err != nil {
+				return err
+			}
+			r.ObjectVersion = append(r.ObjectVersion, version)
+		case 2:
+			r.Error = &Error{}
+			return grpcwire.RangeFields(field.Bytes, func(field grpcwire.Field) error {
+				if field.Number == 1 && field.Type == protowire.BytesType {
+					r.Error.Code = string(field.Bytes)
+				}
+				return nil
+			})
+		case 3:
+			file := &File{}
+			if err := file.UnmarshalWire(field.Bytes); err != nil {
+				return err
+			}
+			r.Files = append(r.Files, file)
+		}
+		return nil
+	})
+}
+
+// ObjectVersion identifies the version of a mounted object.
+type ObjectVersion struct {
+	ID      string
+	Version string
+}
+
+// Implements grpcwire.Marshaler.
+func (v *ObjectVersion) MarshalWire() ([]byte, error) {
+	var b []byte
+	b = grpcwire.AppendString(b, 1, v.ID)
+	b = grpcwire.AppendString(b, 2, v.Version)
+	return b, nil
+}
+
+// Implements grpcwire.Unmarshaler.
+func (v *ObjectVersion) UnmarshalWire(data []byte) error {
+	return grpcwire.RangeFields(data, func(field grpcwire.Field) error {
+		if field.Type != protowire.BytesType {
+			return nil
+		}
+		switch field.Number {
+		case 1:
+			v.ID = string(field.Bytes)
+		case 2:
+			v.Version = string(field.Bytes)
+		}
+		return nil
+	})
+}
+
+// Error reports a provider specific error code to the driver.
+type Error struct {
+	Code string
+}
+
+// File is a single file to be written by the driver to the mounted volume; Path is relative to the volume root.
+type File struct {
+	Path     string
+	Mode     int32
+	Contents []byte
+}
+
+// Implements grpcwire.Marshaler.
+func (f *File) MarshalWire() ([]byte, error) {
+	var b []byte
+	b = grpcwire.AppendString(b, 1, f.Path)
+	b = grpcwire.AppendVarint(b, 2, uint64(f.Mode)) //nolint:gosec // Negative int32 values are sign-extended as protobuf requires
+	b = grpcwire.AppendBytes(b, 3, f.Contents)
+	return b, nil
+}
+
+// Implements grpcwire.Unmarshaler.
+func (f *File) UnmarshalWire(data []byte) error {
+	return grpcwire.RangeFields(data, func(field grpcwire.Field) error {
+		switch {
+		case field.Number == 1 && field.Type == protowire.BytesType:
+			f.Path = string(field.Bytes)
+		case field.Number == 2 && field.Type == protowire.VarintType:
+			f.Mode = int32(field.Varint) //nolint:gosec // Protobuf int32 values are truncated from the varint
+		case field.Number == 3 && field.Type == protowire.BytesType:
+			f.Contents = bytes.Clone(field.Bytes)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,218 @@
+package csi
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+
+	"github.com/memes/f5xc/internal/grpcwire"
+	"github.com/memes/f5xc/wingman"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// The name of the provider, which must match the provider field of a SecretProviderClass.
+	ProviderName = "blindfold"
+	// The version of the Secrets Store CSI driver provider API implemented.
+	APIVersion = "v1alpha1"
+	// The SecretProviderClass parameter that contains a YAML or JSON list of sealed objects to mount.
+	ParamObjects = "objects"
+	// The SecretProviderClass parameter that can override the Wingman URL used to unseal objects.
+	ParamWingmanURL = "wingmanURL"
+	// The default permissions of mounted files when the driver does not provide any.
+	DefaultFileMode = fs.FileMode(0o644)
+)
+
+// The fully-qualified name of the gRPC service expected by the Secrets Store CSI driver.
+const serviceName = APIVersion + ".CSIDriverProvider"
+
+// Pod attributes added to the SecretProviderClass parameters by the driver; used for logging only.
+const (
+	podNameAttribute      = "csi.storage.k8s.io/pod.name"
+	podNamespaceAttribute = "csi.storage.k8s.io/pod.namespace"
+)
+
+// ErrInvalidParameters is returned when the SecretProviderClass parameters cannot be used to mount objects.
+var ErrInvalidParameters = errors.New("invalid SecretProviderClass parameters")
+
+// Describes a single blindfold sealed object to be unsealed and mounted.
+type object struct {
+	// The path of the file to write, relative to the mounted volume.
+	ObjectName string `json:"objectName" yaml:"objectName"`
+	// The base64 encoded blindfold sealed data.
+	Sealed string `json:"sealed" yaml:"sealed"`
+	// Optional octal permissions for the file, overriding the permissions requested by the driver.
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty"`
+}
+
+// Implements the Secrets Store CSI driver provider service by unsealing blindfold objects through Wingman.
+type provider struct {
+	wingmanURL string
+	version    string
+	newClient  func(endpoint string) (wingman.Client, error)
+}
+
+// Returns a new provider that will unseal objects through Wingman at the default endpoint unless overridden by a
+// SecretProviderClass.
+func newProvider(wingmanURL, version string) *provider {
+	return &provider{
+		wingmanURL: wingmanURL,
+		version:    version,
+		newClient: func(endpoint string) (wingman.Client, error) {
+			return wingman.NewClient(endpoint)
+		},
+	}
+}
+
+// Register the provider service with the gRPC server; the server must use the grpcwire codec.
+func (p *provider) register(server *grpc.Server) {
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: serviceName,
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{
+			{
+				MethodName: "Version",
+				Handler:    grpcwire.UnaryHandler("/"+serviceName+"/Version", p.Version),
+			},
+			{
+				MethodName: "Mount",
+				Handler:    grpcwire.UnaryHandler("/"+serviceName+"/Mount", p.Mount),
+			},
+		},
+	}, p)
+}
+
+// Version returns the provider API version and the version of the runtime.
+func (p *provider) Version(_ context.Context, req *VersionRequest) (*VersionResponse, error) {
+	slog.Debug("Received version request", "version", req.Version)
+	return &VersionResponse{
+		Version:        APIVersion,
+		RuntimeName:    ProviderName,
+		RuntimeVersion: p.version,
+	}, nil
+}
+
+// Mount unseals the objects declared in the SecretProviderClass parameters and returns them as files for the driver to
+// write to the volume. Objects are versioned by a digest of their sealed data, so the driver will only report a
+// rotation when the SecretProviderClass is changed.
+func (p *provider) Mount(ctx context.Context, req *MountRequest) (*MountResponse, error) {
+	var attributes map[string]string
+	if err := json.Unmarshal([]byte(req.Attributes), &attributes); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to parse attributes: %v", err)
+	}
+	logger := slog.With("namespace", attributes[podNamespaceAttribute], "pod", attributes[podNameAttribute], "targetPath", req.TargetPath)
+	logger.Debug("Received mount request")
+	defaultMode, err := parsePermission(req.Permission)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	objects, err := parseObjects(attributes[ParamObjects])
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	wingmanURL := cmp.Or(attributes[ParamWingmanURL], p.wingmanURL)
+	client, err := p.newClient(wingmanURL)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to create wingman client for %s: %v", wingmanURL, err)
+	}
+	defer client.Close()
+	resp := &MountResponse{}
+	for _, obj := range objects {
+		contents, err := client.UnsealEncoded(ctx, []byte(obj.Sealed))
+		if err != nil {
+			logger.Error("Failed to unseal object", "objectName", obj.ObjectName, "error", err)
+			return nil, status.Errorf(unsealCode(err), "failed to unseal %s: %v", obj.ObjectName, err)
+		}
+		mode := defaultMode
+		if obj.Mode != "" {
+			// The mode has been validated by parseObjects.
+			value, _ := strconv.ParseUint(obj.Mode, 8, 32)
+			mode = fs.FileMode(value)
+		}
+		digest := sha256.Sum256([]byte(obj.Sealed))
+		resp.ObjectVersion = append(resp.ObjectVersion, &ObjectVersion{
+			ID:      obj.ObjectName,
+			Version: hex.EncodeToString(digest[:]),
+		})
+		resp.Files = append(resp.Files, &File{
+			Path:     obj.ObjectName,
+			Mode:     int32(mode), //nolint:gosec // Permissions are limited to 0777
+			Contents: contents,
+		})
+	}
+	logger.Debug("Mount request complete", "objects", len(objects))
+	return resp, nil
+}
+
+// Returns the gRPC status code that best describes an unseal error.
+func unsealCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, wingman.ErrDeniedByPolicy):
+		return codes.PermissionDenied
+	case errors.Is(err, wingman.ErrNotReady):
+		return codes.Unavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	}
+	return codes.Internal
+}
+
+// Parses the JSON encoded file permission sent by the driver, returning DefaultFileMode if it is empty.
+func parsePermission(permission string) (fs.FileMode, error) {
+	if permission == "" {
+		return DefaultFileMode, nil
+	}
+	var mode uint32
+	if err := json.Unmarshal([]byte(permission), &mode); err != nil {
+		return 0, fmt.Errorf("failed to parse permission %q: %w: %w", permission, err, ErrInvalidParameters)
+	}
+	return fs.FileMode(mode).Perm(), nil
+}
+
+// Parses and validates the YAML or JSON list of objects from the SecretProviderClass parameters.
+func parseObjects(data string) ([]object, error) {
+	if data == "" {
+		return nil, fmt.Errorf("the %s parameter is required: %w", ParamObjects, ErrInvalidParameters)
+	}
+	var objects []object
+	if err := yaml.Unmarshal([]byte(data), &objects); err != nil {
+		return nil, fmt.Errorf("failed to parse %s parameter: %w: %w", ParamObjects, err, ErrInvalidParameters)
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("the %s parameter must contain at least one object: %w", ParamObjects, ErrInvalidParameters)
+	}
+	names := make(map[string]struct{}, len(objects))
+	for _, obj := range objects {
+		switch {
+		case obj.ObjectName == "":
+			return nil, fmt.Errorf("objectName is required: %w", ErrInvalidParameters)
+		case !filepath.IsLocal(obj.ObjectName):
+			return nil, fmt.Errorf("objectName %q must be a relative path within the volume: %w", obj.ObjectName, ErrInvalidParameters)
+		case obj.Sealed == "":
+			return nil, fmt.Errorf("sealed is required for objectName %q: %w", obj.ObjectName, ErrInvalidParameters)
+		}
+		if _, ok := names[obj.ObjectName]; ok {
+			return nil, fmt.Errorf("objectName %q is duplicated: %w", obj.ObjectName, ErrInvalidParameters)
+		}
+		names[obj.ObjectName] = struct{}{}
+		if obj.Mode != "" {
+			if value, err := strconv.ParseUint(obj.Mode, 8, 32); err != nil || value > 0o777 {
+				return nil, fmt.Errorf("mode %q for objectName %q must be octal permissions: %w", obj.Mode, obj.ObjectName, ErrInvalidParameters)
+			}
+		}
+	}
+	return objects, nil
+}
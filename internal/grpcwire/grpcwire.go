@@ -0,0 +1,159 @@
+// Package grpcwire provides a minimal gRPC codec and protobuf wire-format helpers so that the module can exchange
+// messages with gRPC services without depending on generated code.
+package grpcwire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// The name of the codec; this matches the standard protobuf codec so that requests are indistinguishable from those
+// sent by generated clients.
+const Name = "proto"
+
+// ErrUnsupportedMessage is returned by the codec when asked to handle a type that it cannot marshal or unmarshal.
+var ErrUnsupportedMessage = errors.New("message type is not supported by codec")
+
+// ErrMalformedMessage is returned when a protobuf message cannot be parsed from the wire-format.
+var ErrMalformedMessage = errors.New("malformed protobuf message")
+
+// Marshaler is implemented by hand-written messages that can encode themselves to protobuf wire-format.
+type Marshaler interface {
+	MarshalWire() ([]byte, error)
+}
+
+// Unmarshaler is implemented by hand-written messages that can decode themselves from protobuf wire-format.
+type Unmarshaler interface {
+	UnmarshalWire(data []byte) error
+}
+
+// Codec implements the gRPC encoding.Codec interface for hand-written messages, falling back to the standard protobuf
+// implementation for generated messages.
+type Codec struct{}
+
+// Marshal returns the protobuf wire-format encoding of v.
+func (Codec) Marshal(v any) ([]byte, error) {
+	switch msg := v.(type) {
+	case Marshaler:
+		return msg.MarshalWire()
+	case proto.Message:
+		return proto.Marshal(msg) //nolint:wrapcheck // Errors from protobuf library are returned as-is
+	}
+	return nil, fmt.Errorf("marshal %T: %w", v, ErrUnsupportedMessage)
+}
+
+// Unmarshal parses the protobuf wire-format data into v.
+func (Codec) Unmarshal(data []byte, v any) error {
+	switch msg := v.(type) {
+	case Unmarshaler:
+		return msg.UnmarshalWire(data)
+	case proto.Message:
+		return proto.Unmarshal(data, msg) //nolint:wrapcheck // Errors from protobuf library are returned as-is
+	}
+	return fmt.Errorf("unmarshal %T: %w", v, ErrUnsupportedMessage)
+}
+
+// Name returns the name of the codec.
+func (Codec) Name() string {
+	return Name
+}
+
+// AppendString appends a string field to the buffer, omitting empty values as proto3 would.
+func AppendString(b []byte, num protowire.Number, value string) []byte {
+	if value == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+// AppendBytes appends a bytes field to the buffer, omitting empty values as proto3 would.
+func AppendBytes(b []byte, num protowire.Number, value []byte) []byte {
+	if len(value) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, value)
+}
+
+// AppendVarint appends a varint field to the buffer, omitting zero values as proto3 would.
+func AppendVarint(b []byte, num protowire.Number, value uint64) []byte {
+	if value == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, value)
+}
+
+// AppendMessage appends an embedded message field to the buffer; unlike scalar fields an empty message is encoded.
+func AppendMessage(b []byte, num protowire.Number, value []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, value)
+}
+
+// Field represents a single decoded protobuf field; Bytes is populated for length-delimited fields and Varint for
+// varint fields. Other wire types are skipped during decoding.
+type Field struct {
+	Number protowire.Number
+	Type   protowire.Type
+	Bytes  []byte
+	Varint uint64
+}
+
+// RangeFields decodes the protobuf wire-format data and calls fn for each length-delimited or varint field, in order.
+// Iteration stops at the first error returned by fn.
+func RangeFields(data []byte, fn func(Field) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return fmt.Errorf("failed to parse tag: %w: %w", protowire.ParseError(n), ErrMalformedMessage)
+		}
+		data = data[n:]
+		field := Field{Number: num, Type: typ}
+		switch typ {
+		case protowire.BytesType:
+			field.Bytes, n = protowire.ConsumeBytes(data)
+		case protowire.VarintType:
+			field.Varint, n = protowire.ConsumeVarint(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return fmt.Errorf("failed to parse field %d: %w: %w", num, protowire.ParseError(n), ErrMalformedMessage)
+		}
+		data = data[n:]
+		if typ != protowire.BytesType && typ != protowire.VarintType {
+			continue
+		}
+		if err := fn(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnaryHandler returns a gRPC method handler for a hand-registered service that decodes the request message and passes
+// it to fn, through the server interceptor if one is configured.
+func UnaryHandler[T any, R any](fullMethod string, fn func(context.Context, *T) (R, error)) grpc.MethodHandler {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) { //nolint:revive // Signature is defined by grpc
+		req := new(T)
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return fn(ctx, req)
+		}
+		info := &grpc.UnaryServerInfo{
+			Server:     srv,
+			FullMethod: fullMethod,
+		}
+		return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+			return fn(ctx, req.(*T)) //nolint:forcetypeassert // The request was created by this handler
+		})
+	}
+}
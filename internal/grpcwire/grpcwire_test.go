@@ -0,0 +1,67 @@
+package grpcwire_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/memes/f5xc/internal/grpcwire"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Verify that fields appended by the helpers can be decoded by RangeFields.
+func TestRangeFields(t *testing.T) {
+	t.Parallel()
+	var b []byte
+	b = grpcwire.AppendString(b, 1, "blindfold")
+	b = grpcwire.AppendString(b, 2, "")
+	b = grpcwire.AppendBytes(b, 3, []byte{0x00, 0x01})
+	b = grpcwire.AppendVarint(b, 4, 42)
+	b = grpcwire.AppendMessage(b, 5, nil)
+	b = protowire.AppendTag(b, 6, protowire.Fixed32Type)
+	b = protowire.AppendFixed32(b, 7)
+	fields := []grpcwire.Field{}
+	if err := grpcwire.RangeFields(b, func(field grpcwire.Field) error {
+		fields = append(fields, field)
+		return nil
+	}); err != nil {
+		t.Fatalf("RangeFields raised an unexpected error: %v", err)
+	}
+	if len(fields) != 4 {
+		t.Fatalf("Expected 4 fields, got %d", len(fields))
+	}
+	switch {
+	case fields[0].Number != 1 || string(fields[0].Bytes) != "blindfold":
+		t.Errorf("Unexpected field: %+v", fields[0])
+	case fields[1].Number != 3 || !bytes.Equal(fields[1].Bytes, []byte{0x00, 0x01}):
+		t.Errorf("Unexpected field: %+v", fields[1])
+	case fields[2].Number != 4 || fields[2].Varint != 42:
+		t.Errorf("Unexpected field: %+v", fields[2])
+	case fields[3].Number != 5 || len(fields[3].Bytes) != 0:
+		t.Errorf("Unexpected field: %+v", fields[3])
+	}
+	if err := grpcwire.RangeFields([]byte{0xff}, func(grpcwire.Field) error { return nil }); !errors.Is(err, grpcwire.ErrMalformedMessage) {
+		t.Errorf("Expected RangeFields to raise %v, got %v", grpcwire.ErrMalformedMessage, err)
+	}
+}
+
+// Verify that the codec falls back to protobuf for generated messages and rejects unsupported types.
+func TestCodec(t *testing.T) {
+	t.Parallel()
+	codec := grpcwire.Codec{}
+	data, err := codec.Marshal(&grpc_health_v1.HealthCheckRequest{Service: "wingman"})
+	if err != nil {
+		t.Fatalf("Marshal raised an unexpected error: %v", err)
+	}
+	msg := &grpc_health_v1.HealthCheckRequest{}
+	if err := codec.Unmarshal(data, msg); err != nil {
+		t.Fatalf("Unmarshal raised an unexpected error: %v", err)
+	}
+	if msg.GetService() != "wingman" {
+		t.Errorf("Expected service to be wingman, got %q", msg.GetService())
+	}
+	if _, err := codec.Marshal("string"); !errors.Is(err, grpcwire.ErrUnsupportedMessage) {
+		t.Errorf("Expected Marshal to raise %v, got %v", grpcwire.ErrUnsupportedMessage, err)
+	}
+}
@@ -0,0 +1,96 @@
+// Package keepers opens and caches f5xc secrets keepers for servers that seal with more than one secret policy.
+package keepers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/memes/f5xc/f5xcsecrets"
+	"gocloud.dev/secrets"
+)
+
+// Options configures how keepers seal and unseal.
+type Options struct {
+	// The version of the public key to seal with; the current version is used if zero.
+	KeyVersion int
+	// Read the public key and policy documents from this directory, written by f5xc key pull, instead of the API.
+	CacheDir string
+	// The name or path of the vesctl executable; the default is used if empty.
+	Vesctl string
+	// The Wingman endpoint used to unseal; the default is used if empty.
+	WingmanURL string
+}
+
+// Cache opens keepers on demand and reuses them for later requests; it is safe for concurrent use.
+type Cache struct {
+	opener *f5xcsecrets.URLOpener
+	query  url.Values
+	// Guards keepers.
+	mu sync.Mutex
+	// Keepers keyed by namespace/policy; the unseal only keeper has an empty key.
+	keepers map[string]*secrets.Keeper
+}
+
+// New returns a Cache that opens keepers with the options. The API client used to retrieve sealing parameters is
+// created from the environment variables used by vesctl, unless the options name a cache directory.
+func New(opts Options) *Cache {
+	query := url.Values{}
+	if opts.KeyVersion > 0 {
+		query.Set("key_version", strconv.Itoa(opts.KeyVersion))
+	}
+	if opts.CacheDir != "" {
+		query.Set("cache_dir", opts.CacheDir)
+	}
+	if opts.Vesctl != "" {
+		query.Set("vesctl", opts.Vesctl)
+	}
+	if opts.WingmanURL != "" {
+		query.Set("wingman_url", opts.WingmanURL)
+	}
+	return &Cache{
+		opener: &f5xcsecrets.URLOpener{},
+		query:  query,
+	}
+}
+
+// Keeper returns the keeper that seals with the secret policy in the namespace, opening it if necessary. If the policy
+// is empty the keeper can only unseal.
+func (c *Cache) Keeper(ctx context.Context, namespace, policy string) (*secrets.Keeper, error) {
+	id := ""
+	u := &url.URL{Scheme: f5xcsecrets.Scheme, RawQuery: c.query.Encode()}
+	if policy != "" {
+		id = namespace + "/" + policy
+		u.Host = namespace
+		u.Path = "/" + policy
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if keeper, ok := c.keepers[id]; ok {
+		return keeper, nil
+	}
+	keeper, err := c.opener.OpenKeeperURL(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keeper for %q: %w", id, err)
+	}
+	if c.keepers == nil {
+		c.keepers = map[string]*secrets.Keeper{}
+	}
+	c.keepers[id] = keeper
+	return keeper, nil
+}
+
+// Close closes every keeper that has been opened.
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var errs []error
+	for _, keeper := range c.keepers {
+		errs = append(errs, keeper.Close())
+	}
+	c.keepers = nil
+	return errors.Join(errs...)
+}
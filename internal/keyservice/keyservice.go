@@ -0,0 +1,160 @@
+// Package keyservice implements a SOPS keyservice that seals data keys with the F5 Distributed Cloud tenant public key
+// and a secret policy, and unseals them through Wingman, so that SOPS can use blindfold as a key management service.
+// It backs the blindfold-keyservice binary; see cmd/blindfold-keyservice for usage.
+package keyservice
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/memes/f5xc/f5xcsecrets"
+	"github.com/memes/f5xc/internal/grpcwire"
+	"github.com/memes/f5xc/internal/keepers"
+	"github.com/memes/f5xc/wingman"
+	"google.golang.org/grpc"
+)
+
+// The default address that the keyservice listens on, which matches the SOPS keyservice default.
+const DefaultAddress = "tcp://127.0.0.1:5000"
+
+// The exit code returned when the keyservice fails to start or exits with an error.
+const exitFailure = 1
+
+// ErrInvalidAddress is returned when the listen address is not a tcp or unix URL.
+var ErrInvalidAddress = errors.New("address must be a tcp://HOST:PORT or unix://PATH URL")
+
+// Defines the command line options for the keyservice.
+type options struct {
+	address    string
+	namespace  string
+	policy     string
+	keyVersion int
+	cacheDir   string
+	vesctl     string
+	wingmanURL string
+	logLevel   slog.Level
+}
+
+// Parses the command line arguments into options.
+func parseArgs(args []string) (*options, error) {
+	opts := &options{}
+	flags := flag.NewFlagSet("blindfold-keyservice", flag.ContinueOnError)
+	flags.StringVar(&opts.address, "address", DefaultAddress, "The tcp://HOST:PORT or unix://PATH address to listen on")
+	flags.StringVar(&opts.namespace, "namespace", f5xcsecrets.DefaultNamespace, "The default namespace of secret policies")
+	flags.StringVar(&opts.policy, "policy", "", "The secret policy to seal with when a request does not name one")
+	flags.IntVar(&opts.keyVersion, "key-version", 0, "The version of the public key to seal with; 0 to use the current version")
+	flags.StringVar(&opts.cacheDir, "cache-dir", "", "Seal with the public key and policy documents in this directory, written by f5xc key pull")
+	flags.StringVar(&opts.vesctl, "vesctl", "", "The name or path of the vesctl executable")
+	flags.StringVar(&opts.wingmanURL, "wingman-url", wingman.DefaultWingmanURL, "The base URL of the Wingman service; http(s) or grpc(s)")
+	flags.TextVar(&opts.logLevel, "log-level", slog.LevelInfo, "The logging level; one of DEBUG, INFO, WARN, or ERROR")
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("failed to parse arguments: %w", err)
+	}
+	switch {
+	case flags.NArg() > 0:
+		return nil, fmt.Errorf("unexpected arguments %v: %w", flags.Args(), flag.ErrHelp)
+	case opts.keyVersion < 0:
+		return nil, fmt.Errorf("key version must not be negative: %w", flag.ErrHelp)
+	}
+	if _, _, err := parseAddress(opts.address); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// Returns the network and address to listen on from a tcp:// or unix:// URL.
+func parseAddress(address string) (string, string, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", "", fmt.Errorf("%q: %w: %w", address, err, ErrInvalidAddress)
+	}
+	switch {
+	case u.Scheme == "tcp" && u.Host != "":
+		return u.Scheme, u.Host, nil
+	case u.Scheme == "unix" && u.Path != "":
+		return u.Scheme, u.Path, nil
+	}
+	return "", "", fmt.Errorf("%q: %w", address, ErrInvalidAddress)
+}
+
+// Returns the keyservice configured by the options.
+func newService(opts *options) *service {
+	return &service{
+		keepers: keepers.New(keepers.Options{
+			KeyVersion: opts.keyVersion,
+			CacheDir:   opts.cacheDir,
+			Vesctl:     opts.vesctl,
+			WingmanURL: opts.wingmanURL,
+		}),
+		namespace: opts.namespace,
+		policy:    opts.policy,
+	}
+}
+
+// Run executes the keyservice with the command line arguments, excluding the program name, and returns the exit code.
+// The keyservice will serve SOPS requests until interrupted.
+func Run(args []string) int {
+	level := slog.LevelVar{}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     &level,
+	})))
+	opts, err := parseArgs(args)
+	if err != nil {
+		slog.Error("Invalid arguments", "error", err)
+		return exitFailure
+	}
+	level.Set(opts.logLevel)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	s := newService(opts)
+	defer s.keepers.Close()
+	if err := serve(ctx, opts.address, s); err != nil {
+		slog.Error("Keyservice failed", "error", err)
+		return exitFailure
+	}
+	return 0
+}
+
+// Listens on the address and serves SOPS requests until the context is done, at which point in-flight requests are
+// allowed to complete. A stale unix socket left by a previous instance is removed.
+func serve(ctx context.Context, address string, s *service) error {
+	logger := slog.With("address", address)
+	network, addr, err := parseAddress(address)
+	if err != nil {
+		return err
+	}
+	if network == "unix" {
+		if err := os.Remove(addr); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+	listener, err := (&net.ListenConfig{}).Listen(ctx, network, addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for keyservice requests: %w", err)
+	}
+	server := grpc.NewServer(grpc.ForceServerCodec(grpcwire.Codec{}))
+	s.register(server)
+	errs := make(chan error, 1)
+	go func() {
+		logger.Info("Serving keyservice requests")
+		errs <- server.Serve(listener)
+	}()
+	select {
+	case <-ctx.Done():
+		logger.Info("Stopping keyservice")
+		server.GracefulStop()
+		return <-errs //nolint:wrapcheck // Serve returns nil after a graceful stop
+	case err := <-errs:
+		return fmt.Errorf("keyservice server failed: %w", err)
+	}
+}
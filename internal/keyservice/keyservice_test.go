@@ -0,0 +1,215 @@
+package keyservice
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc/f5xctest"
+	"github.com/memes/f5xc/internal/grpcwire"
+	"go.uber.org/goleak"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m, f5xctest.IgnoreOpenCensus())
+}
+
+// Starts the keyservice on a unix socket in a temporary directory and returns a client connection to it.
+func testServiceConn(t *testing.T, args ...string) *grpc.ClientConn {
+	t.Helper()
+	address := "unix://" + filepath.Join(t.TempDir(), "keyservice.sock")
+	opts, err := parseArgs(append(args, "--address", address))
+	if err != nil {
+		t.Fatalf("parseArgs raised an unexpected error: %v", err)
+	}
+	s := newService(opts)
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- serve(ctx, address, s)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-errs; err != nil {
+			t.Errorf("serve returned an unexpected error: %v", err)
+		}
+		_ = s.keepers.Close()
+	})
+	conn, err := grpc.NewClient(address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(grpcwire.Codec{})),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return conn
+}
+
+// Verify that data keys are unsealed through wingman, whatever the master key.
+func TestDecrypt(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(f5xctest.ROT13WingmanHandler(t))
+	t.Cleanup(server.Close)
+	conn := testServiceConn(t, "--wingman-url", server.URL)
+	tests := []struct {
+		name         string
+		req          *DecryptRequest
+		expected     string
+		expectedCode codes.Code
+	}{
+		{
+			name: "vault",
+			// spell-checker: disable-next-line
+			req:      &DecryptRequest{Key: &Key{Vault: &VaultKey{VaultAddress: "https://f5xc.invalid", EnginePath: "shared", KeyName: "test"}}, Ciphertext: []byte("ZnZ6Y3lyLndmYmE=")},
+			expected: "simple.json",
+		},
+		{
+			name: "pgp",
+			// spell-checker: disable-next-line
+			req:      &DecryptRequest{Key: &Key{Type: 1}, Ciphertext: []byte("ZnZ6Y3lyLndmYmE=")},
+			expected: "simple.json",
+		},
+		{
+			name:         "invalid",
+			req:          &DecryptRequest{Ciphertext: []byte("&&&&")},
+			expectedCode: codes.Internal,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			resp := &DecryptResponse{}
+			err := conn.Invoke(ctx, "/"+serviceName+"/Decrypt", tst.req, resp, grpc.WaitForReady(true))
+			switch {
+			case status.Code(err) != tst.expectedCode:
+				t.Fatalf("Expected Decrypt to return %v, got %v", tst.expectedCode, err)
+			case err == nil && string(resp.Plaintext) != tst.expected:
+				t.Errorf("Expected plaintext %q, got %q", tst.expected, string(resp.Plaintext))
+			}
+		})
+	}
+}
+
+// Verify that the secret policy is selected by a vault key, or the default policy.
+func TestEncrypt(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		args         []string
+		key          *Key
+		expectedCode codes.Code
+	}{
+		{
+			name:         "no-default-policy",
+			key:          &Key{Type: 1},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "missing-key-name",
+			key:          &Key{Vault: &VaultKey{VaultAddress: "https://f5xc.invalid", EnginePath: "shared"}},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "vault",
+			args:         []string{"--cache-dir", t.TempDir()},
+			key:          &Key{Vault: &VaultKey{VaultAddress: "https://f5xc.invalid", EnginePath: "shared", KeyName: "test"}},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name:         "default-policy",
+			args:         []string{"--cache-dir", t.TempDir(), "--policy", "test"},
+			expectedCode: codes.NotFound,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			conn := testServiceConn(t, tst.args...)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			resp := &EncryptResponse{}
+			err := conn.Invoke(ctx, "/"+serviceName+"/Encrypt", &EncryptRequest{Key: tst.key, Plaintext: []byte("data key")}, resp, grpc.WaitForReady(true))
+			if status.Code(err) != tst.expectedCode {
+				t.Errorf("Expected Encrypt to return %v, got %v", tst.expectedCode, err)
+			}
+		})
+	}
+}
+
+// Verify that encrypt and decrypt requests survive a round trip through the wire-format.
+func TestMessages(t *testing.T) {
+	t.Parallel()
+	req := &EncryptRequest{
+		Key:       &Key{Vault: &VaultKey{VaultAddress: "https://f5xc.invalid", EnginePath: "shared", KeyName: "test"}},
+		Plaintext: []byte("data key"),
+	}
+	data, err := req.MarshalWire()
+	if err != nil {
+		t.Fatalf("MarshalWire raised an unexpected error: %v", err)
+	}
+	decoded := &EncryptRequest{}
+	if err := decoded.UnmarshalWire(data); err != nil {
+		t.Fatalf("UnmarshalWire raised an unexpected error: %v", err)
+	}
+	if decoded.Key == nil || decoded.Key.Type != keyTypeVault || *decoded.Key.Vault != *req.Key.Vault || string(decoded.Plaintext) != "data key" {
+		t.Errorf("Unexpected decoded request %+v", decoded)
+	}
+}
+
+// Verify that the command line arguments are parsed as expected.
+func TestParseArgs(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError error
+	}{
+		{
+			name: "default",
+		},
+		{
+			name: "unix",
+			args: []string{"--address", "unix:///run/sops/keyservice.sock", "--policy", "test"},
+		},
+		{
+			name:          "invalid-address",
+			args:          []string{"--address", "localhost:5000"},
+			expectedError: ErrInvalidAddress,
+		},
+		{
+			name:          "negative-key-version",
+			args:          []string{"--key-version", "-1"},
+			expectedError: flag.ErrHelp,
+		},
+		{
+			name:          "extra-args",
+			args:          []string{"unexpected"},
+			expectedError: flag.ErrHelp,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := parseArgs(tst.args)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("parseArgs raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected parseArgs to raise %v, got %v", tst.expectedError, err)
+			}
+		})
+	}
+}
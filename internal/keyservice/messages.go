@@ -0,0 +1,190 @@
+package keyservice
+
+import (
+	"github.com/memes/f5xc/internal/grpcwire"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The field number of the Vault key type in the SOPS Key message oneof.
+const keyTypeVault = protowire.Number(5)
+
+// Key identifies the master key that SOPS asks the keyservice to use. Only a Vault key is decoded, as its engine path
+// and key name select the secret policy; any other key type is recorded by its oneof field number.
+type Key struct {
+	Type  protowire.Number
+	Vault *VaultKey
+}
+
+// Implements grpcwire.Marshaler.
+func (k *Key) MarshalWire() ([]byte, error) {
+	if k.Vault != nil {
+		vault, err := k.Vault.MarshalWire()
+		if err != nil {
+			return nil, err
+		}
+		return grpcwire.AppendMessage(nil, keyTypeVault, vault), nil
+	}
+	if k.Type == 0 {
+		return nil, nil
+	}
+	return grpcwire.AppendMessage(nil, k.Type, nil), nil
+}
+
+// Implements grpcwire.Unmarshaler.
+func (k *Key) UnmarshalWire(data []byte) error {
+	return grpcwire.RangeFields(data, func(field grpcwire.Field) error {
+		if field.Type != protowire.BytesType {
+			return nil
+		}
+		k.Type = field.Number
+		k.Vault = nil
+		if field.Number != keyTypeVault {
+			return nil
+		}
+		k.Vault = &VaultKey{}
+		return k.Vault.UnmarshalWire(field.Bytes)
+	})
+}
+
+// VaultKey is a HashiCorp Vault transit key as parsed by SOPS from a URI of the form ADDRESS/v1/ENGINE/keys/NAME.
+type VaultKey struct {
+	VaultAddress string
+	EnginePath   string
+	KeyName      string
+}
+
+// Implements grpcwire.Marshaler.
+func (k *VaultKey) MarshalWire() ([]byte, error) {
+	var b []byte
+	b = grpcwire.AppendString(b, 1, k.VaultAddress)
+	b = grpcwire.AppendString(b, 2, k.EnginePath)
+	b = grpcwire.AppendString(b, 3, k.KeyName)
+	return b, nil
+}
+
+// Implements grpcwire.Unmarshaler.
+func (k *VaultKey) UnmarshalWire(data []byte) error {
+	return grpcwire.RangeFields(data, func(field grpcwire.Field) error {
+		if field.Type != protowire.BytesType {
+			return nil
+		}
+		switch field.Number {
+		case 1:
+			k.VaultAddress = string(field.Bytes)
+		case 2:
+			k.EnginePath = string(field.Bytes)
+		case 3:
+			k.KeyName = string(field.Bytes)
+		}
+		return nil
+	})
+}
+
+// EncryptRequest is sent by SOPS to encrypt a data key with a master key.
+type EncryptRequest struct {
+	Key       *Key
+	Plaintext []byte
+}
+
+// Implements grpcwire.Marshaler.
+func (r *EncryptRequest) MarshalWire() ([]byte, error) {
+	return marshalKeyRequest(r.Key, r.Plaintext)
+}
+
+// Implements grpcwire.Unmarshaler.
+func (r *EncryptRequest) UnmarshalWire(data []byte) error {
+	var err error
+	r.Key, r.Plaintext, err = unmarshalKeyRequest(data)
+	return err
+}
+
+// EncryptResponse contains the encrypted data key.
+type EncryptResponse struct {
+	Ciphertext []byte
+}
+
+// Implements grpcwire.Marshaler.
+func (r *EncryptResponse) MarshalWire() ([]byte, error) {
+	return grpcwire.AppendBytes(nil, 1, r.Ciphertext), nil
+}
+
+// Implements grpcwire.Unmarshaler.
+func (r *EncryptResponse) UnmarshalWire(data []byte) error {
+	return grpcwire.RangeFields(data, func(field grpcwire.Field) error {
+		if field.Number == 1 && field.Type == protowire.BytesType {
+			r.Ciphertext = field.Bytes
+		}
+		return nil
+	})
+}
+
+// DecryptRequest is sent by SOPS to decrypt a data key with a master key.
+type DecryptRequest struct {
+	Key        *Key
+	Ciphertext []byte
+}
+
+// Implements grpcwire.Marshaler.
+func (r *DecryptRequest) MarshalWire() ([]byte, error) {
+	return marshalKeyRequest(r.Key, r.Ciphertext)
+}
+
+// Implements grpcwire.Unmarshaler.
+func (r *DecryptRequest) UnmarshalWire(data []byte) error {
+	var err error
+	r.Key, r.Ciphertext, err = unmarshalKeyRequest(data)
+	return err
+}
+
+// DecryptResponse contains the decrypted data key.
+type DecryptResponse struct {
+	Plaintext []byte
+}
+
+// Implements grpcwire.Marshaler.
+func (r *DecryptResponse) MarshalWire() ([]byte, error) {
+	return grpcwire.AppendBytes(nil, 1, r.Plaintext), nil
+}
+
+// Implements grpcwire.Unmarshaler.
+func (r *DecryptResponse) UnmarshalWire(data []byte) error {
+	return grpcwire.RangeFields(data, func(field grpcwire.Field) error {
+		if field.Number == 1 && field.Type == protowire.BytesType {
+			r.Plaintext = field.Bytes
+		}
+		return nil
+	})
+}
+
+// Encrypt and decrypt requests share a layout; the key is field 1 and the data to transform is field 2.
+func marshalKeyRequest(key *Key, data []byte) ([]byte, error) {
+	var b []byte
+	if key != nil {
+		encoded, err := key.MarshalWire()
+		if err != nil {
+			return nil, err
+		}
+		b = grpcwire.AppendMessage(b, 1, encoded)
+	}
+	return grpcwire.AppendBytes(b, 2, data), nil
+}
+
+// Decodes the key and data of an encrypt or decrypt request.
+func unmarshalKeyRequest(data []byte) (*Key, []byte, error) {
+	var key *Key
+	var value []byte
+	err := grpcwire.RangeFields(data, func(field grpcwire.Field) error {
+		if field.Type != protowire.BytesType {
+			return nil
+		}
+		switch field.Number {
+		case 1:
+			key = &Key{}
+			return key.UnmarshalWire(field.Bytes)
+		case 2:
+			value = field.Bytes
+		}
+		return nil
+	})
+	return key, value, err //nolint:wrapcheck // Errors from RangeFields are descriptive
+}
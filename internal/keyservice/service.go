@@ -0,0 +1,124 @@
+package keyservice
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+
+	"github.com/memes/f5xc/internal/grpcwire"
+	"github.com/memes/f5xc/internal/keepers"
+	"gocloud.dev/gcerrors"
+	"gocloud.dev/secrets"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The fully-qualified name of the SOPS keyservice gRPC service, which is not in a protobuf package.
+const serviceName = "KeyService"
+
+// Implements the SOPS keyservice by sealing data keys with the tenant public key and a secret policy, and unsealing
+// them through Wingman.
+type service struct {
+	keepers *keepers.Cache
+	// The namespace and name of the secret policy used when the requested key does not select one.
+	namespace string
+	policy    string
+}
+
+// Register the keyservice with the gRPC server; the server must use the grpcwire codec.
+func (s *service) register(server *grpc.Server) {
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: serviceName,
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{
+			{
+				MethodName: "Encrypt",
+				Handler:    grpcwire.UnaryHandler("/"+serviceName+"/Encrypt", s.Encrypt),
+			},
+			{
+				MethodName: "Decrypt",
+				Handler:    grpcwire.UnaryHandler("/"+serviceName+"/Decrypt", s.Decrypt),
+			},
+		},
+	}, s)
+}
+
+// Returns the namespace and name of the secret policy to seal with; a Vault key selects the policy by its key name,
+// and the namespace by its engine path, otherwise the default policy is used.
+func (s *service) policyFor(key *Key) (string, string, error) {
+	if key != nil && key.Vault != nil {
+		if key.Vault.KeyName == "" {
+			return "", "", status.Error(codes.InvalidArgument, "vault key name must be the secret policy name")
+		}
+		return cmp.Or(key.Vault.EnginePath, s.namespace), key.Vault.KeyName, nil
+	}
+	if s.policy == "" {
+		return "", "", status.Error(codes.InvalidArgument, "a vault key naming the secret policy is required as no default policy is configured")
+	}
+	return s.namespace, s.policy, nil
+}
+
+// Returns the keeper that seals with the secret policy, opening it if necessary; if the policy is empty the keeper can
+// only unseal.
+func (s *service) keeper(ctx context.Context, namespace, policy string) (*secrets.Keeper, error) {
+	keeper, err := s.keepers.Keeper(ctx, namespace, policy)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return keeper, nil
+}
+
+// Encrypt seals the data key with the tenant public key and the secret policy selected by the request key.
+func (s *service) Encrypt(ctx context.Context, req *EncryptRequest) (*EncryptResponse, error) {
+	namespace, policy, err := s.policyFor(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	logger := slog.With("namespace", namespace, "policy", policy)
+	logger.Debug("Received encrypt request")
+	keeper, err := s.keeper(ctx, namespace, policy)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := keeper.Encrypt(ctx, req.Plaintext)
+	if err != nil {
+		logger.Error("Failed to seal data key", "error", err)
+		return nil, status.Errorf(statusCode(err), "failed to seal data key: %v", err)
+	}
+	return &EncryptResponse{Ciphertext: ciphertext}, nil
+}
+
+// Decrypt unseals the data key through Wingman; the request key is ignored as the sealed data identifies its policy.
+func (s *service) Decrypt(ctx context.Context, req *DecryptRequest) (*DecryptResponse, error) {
+	slog.Debug("Received decrypt request")
+	keeper, err := s.keeper(ctx, "", "")
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := keeper.Decrypt(ctx, req.Ciphertext)
+	if err != nil {
+		slog.Error("Failed to unseal data key", "error", err)
+		return nil, status.Errorf(statusCode(err), "failed to unseal data key: %v", err)
+	}
+	return &DecryptResponse{Plaintext: plaintext}, nil
+}
+
+// Returns the gRPC status code that best describes a keeper error.
+func statusCode(err error) codes.Code {
+	switch gcerrors.Code(err) {
+	case gcerrors.NotFound:
+		return codes.NotFound
+	case gcerrors.InvalidArgument:
+		return codes.InvalidArgument
+	case gcerrors.FailedPrecondition:
+		return codes.FailedPrecondition
+	case gcerrors.PermissionDenied:
+		return codes.PermissionDenied
+	case gcerrors.DeadlineExceeded:
+		return codes.DeadlineExceeded
+	case gcerrors.Canceled:
+		return codes.Canceled
+	}
+	return codes.Internal
+}
@@ -0,0 +1,132 @@
+// Package tracing creates OpenTelemetry client spans for HTTP requests and gRPC calls made to F5 Distributed Cloud and
+// Wingman, and propagates the trace context to the called service with the global OpenTelemetry propagator.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// The name of the instrumentation library reported with every span.
+const instrumentationName = "github.com/memes/f5xc"
+
+// Transport implements a RoundTripper that records a client span for every request sent by the base RoundTripper.
+type Transport struct {
+	base   http.RoundTripper
+	tracer trace.Tracer
+}
+
+// NewTransport returns a Transport that records spans with a tracer from the provider around requests sent by base.
+func NewTransport(base http.RoundTripper, provider trace.TracerProvider) *Transport {
+	return &Transport{
+		base:   base,
+		tracer: provider.Tracer(instrumentationName),
+	}
+}
+
+// RoundTrip starts a client span as a child of any span in the request context, adds the trace context headers to a
+// copy of the request, and sends it with the base RoundTripper. The span records the method, path, host, and response
+// status code, and is marked as an error if the request fails or the status code is 400 or greater.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLPath(req.URL.Path),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err //nolint:wrapcheck // Errors are returned unchanged by the tracing transport
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// CloseIdleConnections forwards to the base RoundTripper.
+func (t *Transport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// Unwrap returns the base RoundTripper.
+func (t *Transport) Unwrap() http.RoundTripper {
+	return t.base
+}
+
+// UnaryClientInterceptor returns a gRPC interceptor that records a client span with a tracer from the provider around
+// every unary call, and adds the trace context to the outgoing metadata. The span records the service, method, target,
+// and gRPC status code, and is marked as an error if the call does not succeed.
+func UnaryClientInterceptor(provider trace.TracerProvider) grpc.UnaryClientInterceptor {
+	tracer := provider.Tracer(instrumentationName)
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		name := strings.TrimPrefix(method, "/")
+		service, rpcMethod, _ := strings.Cut(name, "/")
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.RPCSystemGRPC,
+				semconv.RPCService(service),
+				semconv.RPCMethod(rpcMethod),
+				semconv.ServerAddress(cc.Target()),
+			),
+		)
+		defer span.End()
+		md, _ := metadata.FromOutgoingContext(ctx)
+		md = md.Copy()
+		otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+		err := invoker(metadata.NewOutgoingContext(ctx, md), method, req, reply, cc, opts...)
+		code := status.Code(err)
+		span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, code.String())
+		}
+		return err //nolint:wrapcheck // Errors are returned unchanged by the interceptor
+	}
+}
+
+// Adapts gRPC metadata to the OpenTelemetry TextMapCarrier interface.
+type metadataCarrier metadata.MD
+
+// Get returns the first value of the key.
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Set replaces the values of the key.
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys returns the keys of the metadata.
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,208 @@
+package transit
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/memes/f5xc/internal/keepers"
+	"gocloud.dev/gcerrors"
+)
+
+const (
+	// The prefix added to sealed data so that ciphertexts have the form expected by Vault clients. Blindfold does not
+	// expose the public key version of sealed data, so the prefix always declares version 1.
+	ciphertextPrefix = "vault:v1:"
+	// The key version reported in encrypt responses, matching the ciphertext prefix.
+	keyVersion = 1
+	// The header used by Vault Enterprise clients to select a namespace, which selects the F5 Distributed Cloud
+	// namespace of the secret policy.
+	namespaceHeader = "X-Vault-Namespace"
+	// The maximum size of an encrypt or decrypt request that will be accepted.
+	maxRequestSize = 4 << 20
+)
+
+var (
+	// ErrInvalidPlaintext is returned when an encrypt request does not contain base64 encoded plaintext.
+	ErrInvalidPlaintext = errors.New("plaintext must be base64 encoded")
+	// ErrInvalidCiphertext is returned when a decrypt request does not contain a ciphertext issued by the server.
+	ErrInvalidCiphertext = errors.New("ciphertext must have the form vault:vN:SEALED")
+)
+
+// An item of an encrypt or decrypt request or response; a request without a batch is treated as a batch of one.
+type item struct {
+	Plaintext  string `json:"plaintext,omitempty"`
+	Ciphertext string `json:"ciphertext,omitempty"`
+	KeyVersion int    `json:"key_version,omitempty"`
+	Reference  string `json:"reference,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// The body of an encrypt or decrypt request. Vault options that do not apply to blindfold, such as a key derivation
+// context, are ignored.
+type request struct {
+	item
+	BatchInput []item `json:"batch_input,omitempty"`
+}
+
+// The data of a batch response.
+type batchResults struct {
+	BatchResults []item `json:"batch_results"`
+}
+
+// Implements a subset of the Vault transit secrets engine API; the key name selects the secret policy used to seal.
+type server struct {
+	keepers *keepers.Cache
+	// The default namespace of secret policies, used when a request does not set a Vault namespace.
+	namespace string
+}
+
+// Returns the handler for the transit and health endpoints, with the transit engine served under the mount path.
+func (s *server) handler(mount string) http.Handler {
+	mux := http.NewServeMux()
+	for _, method := range []string{http.MethodPost, http.MethodPut} {
+		mux.HandleFunc(method+" /v1/"+mount+"/encrypt/{name}", s.serve(s.encrypt))
+		mux.HandleFunc(method+" /v1/"+mount+"/decrypt/{name}", s.serve(s.decrypt))
+	}
+	mux.HandleFunc("GET "+HealthPath, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"initialized": true,
+			"sealed":      false,
+			"standby":     false,
+		})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		writeErrors(w, http.StatusNotFound, "unsupported path")
+	})
+	return mux
+}
+
+// Returns a handler that decodes a request, applies the transform to each item, and writes the response in the form
+// used by Vault; a single item is returned as the response data, and a batch as a list of results with per-item errors.
+func (s *server) serve(transform func(context.Context, string, string, *item) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		namespace := cmp.Or(strings.Trim(r.Header.Get(namespaceHeader), "/"), s.namespace)
+		policy := r.PathValue("name")
+		logger := slog.With("namespace", namespace, "policy", policy, "path", r.URL.Path)
+		var req request
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+			logger.Warn("Invalid transit request", "error", err)
+			writeErrors(w, http.StatusBadRequest, "failed to parse request: "+err.Error())
+			return
+		}
+		if req.BatchInput == nil {
+			if err := transform(r.Context(), namespace, policy, &req.item); err != nil {
+				logger.Error("Transit request failed", "error", err)
+				writeErrors(w, httpStatus(err), err.Error())
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"data": &req.item})
+			return
+		}
+		logger.Debug("Processing batch", "items", len(req.BatchInput))
+		for i := range req.BatchInput {
+			if err := transform(r.Context(), namespace, policy, &req.BatchInput[i]); err != nil {
+				logger.Error("Transit batch item failed", "index", i, "error", err)
+				req.BatchInput[i] = item{Reference: req.BatchInput[i].Reference, Error: err.Error()}
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"data": &batchResults{BatchResults: req.BatchInput}})
+	}
+}
+
+// Seals the base64 encoded plaintext of the item with the secret policy, replacing it with a Vault style ciphertext.
+func (s *server) encrypt(ctx context.Context, namespace, policy string, it *item) error {
+	plaintext, err := base64.StdEncoding.DecodeString(it.Plaintext)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidPlaintext, err)
+	}
+	keeper, err := s.keepers.Keeper(ctx, namespace, policy)
+	if err != nil {
+		return err //nolint:wrapcheck // Errors from the cache are descriptive
+	}
+	sealed, err := keeper.Encrypt(ctx, plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to seal plaintext: %w", err)
+	}
+	*it = item{
+		Ciphertext: ciphertextPrefix + string(sealed),
+		KeyVersion: keyVersion,
+		Reference:  it.Reference,
+	}
+	return nil
+}
+
+// Unseals the Vault style ciphertext of the item through Wingman, replacing it with base64 encoded plaintext. The key
+// name is ignored as the sealed data identifies its secret policy.
+func (s *server) decrypt(ctx context.Context, _, _ string, it *item) error {
+	sealed, err := parseCiphertext(it.Ciphertext)
+	if err != nil {
+		return err
+	}
+	keeper, err := s.keepers.Keeper(ctx, "", "")
+	if err != nil {
+		return err //nolint:wrapcheck // Errors from the cache are descriptive
+	}
+	plaintext, err := keeper.Decrypt(ctx, []byte(sealed))
+	if err != nil {
+		return fmt.Errorf("failed to unseal ciphertext: %w", err)
+	}
+	*it = item{
+		Plaintext: base64.StdEncoding.EncodeToString(plaintext),
+		Reference: it.Reference,
+	}
+	return nil
+}
+
+// Returns the sealed data from a ciphertext of the form vault:vN:SEALED.
+func parseCiphertext(ciphertext string) (string, error) {
+	rest, ok := strings.CutPrefix(ciphertext, "vault:v")
+	if !ok {
+		return "", ErrInvalidCiphertext
+	}
+	version, sealed, ok := strings.Cut(rest, ":")
+	if !ok || version == "" || strings.Trim(version, "0123456789") != "" || sealed == "" {
+		return "", ErrInvalidCiphertext
+	}
+	return sealed, nil
+}
+
+// Returns the HTTP status that best describes an encrypt or decrypt error.
+func httpStatus(err error) int {
+	if errors.Is(err, ErrInvalidPlaintext) || errors.Is(err, ErrInvalidCiphertext) {
+		return http.StatusBadRequest
+	}
+	switch gcerrors.Code(err) {
+	case gcerrors.NotFound:
+		return http.StatusNotFound
+	case gcerrors.InvalidArgument:
+		return http.StatusBadRequest
+	case gcerrors.PermissionDenied:
+		return http.StatusForbidden
+	case gcerrors.FailedPrecondition:
+		return http.StatusServiceUnavailable
+	case gcerrors.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// Writes the errors in the form used by Vault.
+func writeErrors(w http.ResponseWriter, status int, errs ...string) {
+	writeJSON(w, status, map[string]any{"errors": errs})
+}
+
+// Writes the value as a JSON response with the status.
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		slog.Warn("Failed to write transit response", "error", err)
+	}
+}
@@ -0,0 +1,173 @@
+// Package transit implements an HTTP server that exposes a minimal HashiCorp Vault transit secrets engine API, mapping
+// encrypt requests to blindfold sealing and decrypt requests to Wingman unsealing, so that applications written against
+// the Vault API can run unmodified in F5 Distributed Cloud. It backs the blindfold-transit binary; see
+// cmd/blindfold-transit for usage.
+package transit
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/memes/f5xc/f5xcsecrets"
+	"github.com/memes/f5xc/internal/keepers"
+	"github.com/memes/f5xc/wingman"
+)
+
+const (
+	// The default address to listen on, which matches the Vault default.
+	DefaultAddress = "127.0.0.1:8200"
+	// The default mount path of the transit secrets engine.
+	DefaultMount = "transit"
+	// The path that reports the health of the server, in the form of the Vault health endpoint.
+	HealthPath = "/v1/sys/health"
+	// The time allowed for reading request headers.
+	readHeaderTimeout = 10 * time.Second
+	// The time allowed for in-flight requests to complete during shutdown.
+	shutdownTimeout = 10 * time.Second
+)
+
+// The exit code returned when the server fails to start or exits with an error.
+const exitFailure = 1
+
+// Defines the command line options for the transit server.
+type options struct {
+	address    string
+	mount      string
+	tlsCert    string
+	tlsKey     string
+	namespace  string
+	keyVersion int
+	cacheDir   string
+	vesctl     string
+	wingmanURL string
+	logLevel   slog.Level
+}
+
+// Parses the command line arguments into options.
+func parseArgs(args []string) (*options, error) {
+	opts := &options{}
+	flags := flag.NewFlagSet("blindfold-transit", flag.ContinueOnError)
+	flags.StringVar(&opts.address, "address", DefaultAddress, "The address to listen on for transit requests")
+	flags.StringVar(&opts.mount, "mount", DefaultMount, "The mount path of the transit secrets engine")
+	flags.StringVar(&opts.tlsCert, "tls-cert", "", "The PEM encoded TLS certificate file; requests are served over plain HTTP if unset")
+	flags.StringVar(&opts.tlsKey, "tls-key", "", "The PEM encoded private key file for the TLS certificate")
+	flags.StringVar(&opts.namespace, "namespace", f5xcsecrets.DefaultNamespace, "The namespace of secret policies when a request does not set a Vault namespace")
+	flags.IntVar(&opts.keyVersion, "key-version", 0, "The version of the public key to seal with; 0 to use the current version")
+	flags.StringVar(&opts.cacheDir, "cache-dir", "", "Seal with the public key and policy documents in this directory, written by f5xc key pull")
+	flags.StringVar(&opts.vesctl, "vesctl", "", "The name or path of the vesctl executable")
+	flags.StringVar(&opts.wingmanURL, "wingman-url", wingman.DefaultWingmanURL, "The base URL of the Wingman service; http(s) or grpc(s)")
+	flags.TextVar(&opts.logLevel, "log-level", slog.LevelInfo, "The logging level; one of DEBUG, INFO, WARN, or ERROR")
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("failed to parse arguments: %w", err)
+	}
+	opts.mount = strings.Trim(opts.mount, "/")
+	switch {
+	case flags.NArg() > 0:
+		return nil, fmt.Errorf("unexpected arguments %v: %w", flags.Args(), flag.ErrHelp)
+	case opts.mount == "" || opts.mount == "sys" || strings.ContainsAny(opts.mount, "{}"):
+		return nil, fmt.Errorf("invalid mount path %q: %w", opts.mount, flag.ErrHelp)
+	case (opts.tlsCert == "") != (opts.tlsKey == ""):
+		return nil, fmt.Errorf("a TLS certificate and key must be provided together: %w", flag.ErrHelp)
+	case opts.keyVersion < 0:
+		return nil, fmt.Errorf("key version must not be negative: %w", flag.ErrHelp)
+	}
+	return opts, nil
+}
+
+// Returns the transit server configured by the options.
+func newServer(opts *options) *server {
+	return &server{
+		keepers: keepers.New(keepers.Options{
+			KeyVersion: opts.keyVersion,
+			CacheDir:   opts.cacheDir,
+			Vesctl:     opts.vesctl,
+			WingmanURL: opts.wingmanURL,
+		}),
+		namespace: opts.namespace,
+	}
+}
+
+// Run executes the transit server with the command line arguments, excluding the program name, and returns the exit
+// code. The server will serve transit requests until interrupted.
+func Run(args []string) int {
+	level := slog.LevelVar{}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     &level,
+	})))
+	opts, err := parseArgs(args)
+	if err != nil {
+		slog.Error("Invalid arguments", "error", err)
+		return exitFailure
+	}
+	level.Set(opts.logLevel)
+	var tlsConfig *tls.Config
+	if opts.tlsCert != "" {
+		cert, err := tls.LoadX509KeyPair(opts.tlsCert, opts.tlsKey)
+		if err != nil {
+			slog.Error("Failed to load TLS certificate", "error", err)
+			return exitFailure
+		}
+		tlsConfig = &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	s := newServer(opts)
+	defer s.keepers.Close()
+	if err := serve(ctx, opts.address, tlsConfig, s.handler(opts.mount)); err != nil {
+		slog.Error("Transit server failed", "error", err)
+		return exitFailure
+	}
+	return 0
+}
+
+// Serves the handler on the address, over TLS if a configuration is provided, until the context is done, then allows
+// in-flight requests to complete.
+func serve(ctx context.Context, address string, tlsConfig *tls.Config, handler http.Handler) error {
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen for transit requests: %w", err)
+	}
+	logger := slog.With("address", listener.Addr().String(), "tls", tlsConfig != nil)
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		logger.Info("Serving transit requests")
+		errs <- server.Serve(listener)
+	}()
+	select {
+	case <-ctx.Done():
+		logger.Info("Stopping transit server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("failed to shutdown transit server: %w", err)
+		}
+		if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("transit server failed: %w", err)
+		}
+		return nil
+	case err := <-errs:
+		return fmt.Errorf("transit server failed: %w", err)
+	}
+}
@@ -0,0 +1,282 @@
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/memes/f5xc/f5xctest"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m, f5xctest.IgnoreOpenCensus())
+}
+
+// Starts a transit server with the arguments and returns its base URL.
+func testTransitServer(t *testing.T, args ...string) string {
+	t.Helper()
+	opts, err := parseArgs(args)
+	if err != nil {
+		t.Fatalf("parseArgs raised an unexpected error: %v", err)
+	}
+	s := newServer(opts)
+	server := httptest.NewServer(s.handler(opts.mount))
+	t.Cleanup(func() {
+		server.Close()
+		_ = s.keepers.Close()
+	})
+	return server.URL
+}
+
+// Posts the body to the transit server and returns the status code and decoded response.
+func testPost(t *testing.T, url, body string, header http.Header) (int, map[string]any) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var result map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return resp.StatusCode, result
+}
+
+// Verify that Vault style ciphertexts are unsealed through wingman, singly and in batches.
+func TestDecrypt(t *testing.T) {
+	t.Parallel()
+	wingmanServer := httptest.NewServer(f5xctest.ROT13WingmanHandler(t))
+	t.Cleanup(wingmanServer.Close)
+	baseURL := testTransitServer(t, "--wingman-url", wingmanServer.URL)
+	// spell-checker: disable-next-line
+	plaintext := base64.StdEncoding.EncodeToString([]byte("simple.json"))
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedData   string
+	}{
+		{
+			name: "single",
+			// spell-checker: disable-next-line
+			body:           `{"ciphertext":"vault:v1:ZnZ6Y3lyLndmYmE="}`,
+			expectedStatus: http.StatusOK,
+			expectedData:   `{"plaintext":"` + plaintext + `"}`,
+		},
+		{
+			name: "batch",
+			// spell-checker: disable-next-line
+			body:           `{"batch_input":[{"ciphertext":"vault:v2:ZnZ6Y3lyLndmYmE=","reference":"a"},{"ciphertext":"invalid","reference":"b"}]}`,
+			expectedStatus: http.StatusOK,
+			expectedData:   `{"batch_results":[{"plaintext":"` + plaintext + `","reference":"a"},{"reference":"b","error":"` + ErrInvalidCiphertext.Error() + `"}]}`,
+		},
+		{
+			name:           "missing-prefix",
+			body:           `{"ciphertext":"ZnZ6Y3lyLndmYmE="}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid-json",
+			body:           `{"ciphertext":`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			status, result := testPost(t, baseURL+"/v1/transit/decrypt/test", tst.body, nil)
+			if status != tst.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %v", tst.expectedStatus, status, result)
+			}
+			if tst.expectedData == "" {
+				if _, ok := result["errors"]; !ok {
+					t.Errorf("Expected errors in response, got %v", result)
+				}
+				return
+			}
+			data, err := json.Marshal(result["data"])
+			if err != nil {
+				t.Fatalf("failed to marshal data: %v", err)
+			}
+			var expected any
+			if err := json.Unmarshal([]byte(tst.expectedData), &expected); err != nil {
+				t.Fatalf("failed to unmarshal expected data: %v", err)
+			}
+			want, _ := json.Marshal(expected)
+			if string(data) != string(want) {
+				t.Errorf("Expected data %s, got %s", want, data)
+			}
+		})
+	}
+}
+
+// Verify that encrypt requests are validated, and that the key name and namespace header select the secret policy.
+func TestEncrypt(t *testing.T) {
+	t.Parallel()
+	baseURL := testTransitServer(t, "--cache-dir", t.TempDir(), "--mount", "/blindfold/")
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		header         http.Header
+		expectedStatus int
+	}{
+		{
+			name:           "invalid-plaintext",
+			path:           "/v1/blindfold/encrypt/test",
+			body:           `{"plaintext":"&&&&"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "uncached-policy",
+			path:           "/v1/blindfold/encrypt/test",
+			body:           `{"plaintext":"ZGF0YQ=="}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "namespace",
+			path:           "/v1/blindfold/encrypt/test",
+			body:           `{"plaintext":"ZGF0YQ=="}`,
+			header:         http.Header{namespaceHeader: []string{"app/"}},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "wrong-mount",
+			path:           "/v1/transit/encrypt/test",
+			body:           `{"plaintext":"ZGF0YQ=="}`,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			status, result := testPost(t, baseURL+tst.path, tst.body, tst.header)
+			if status != tst.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %v", tst.expectedStatus, status, result)
+			}
+			if _, ok := result["errors"]; !ok {
+				t.Errorf("Expected errors in response, got %v", result)
+			}
+		})
+	}
+}
+
+// Verify that the health endpoint reports an unsealed server.
+func TestHealth(t *testing.T) {
+	t.Parallel()
+	baseURL := testTransitServer(t)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, baseURL+HealthPath, nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var health struct {
+		Sealed bool `json:"sealed"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil || resp.StatusCode != http.StatusOK || health.Sealed {
+		t.Errorf("Unexpected health response %d %+v: %v", resp.StatusCode, health, err)
+	}
+}
+
+// Verify that Vault style ciphertexts are parsed as expected.
+func TestParseCiphertext(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		ciphertext    string
+		expected      string
+		expectedError error
+	}{
+		{ciphertext: "vault:v1:sealed", expected: "sealed"},
+		{ciphertext: "vault:v12:sealed", expected: "sealed"},
+		{ciphertext: "vault:v1:", expectedError: ErrInvalidCiphertext},
+		{ciphertext: "vault:vx:sealed", expectedError: ErrInvalidCiphertext},
+		{ciphertext: "vault:v:sealed", expectedError: ErrInvalidCiphertext},
+		{ciphertext: "sealed", expectedError: ErrInvalidCiphertext},
+	}
+	for _, tst := range tests {
+		t.Run(tst.ciphertext, func(t *testing.T) {
+			t.Parallel()
+			sealed, err := parseCiphertext(tst.ciphertext)
+			switch {
+			case !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected parseCiphertext to raise %v, got %v", tst.expectedError, err)
+			case sealed != tst.expected:
+				t.Errorf("Expected %q, got %q", tst.expected, sealed)
+			}
+		})
+	}
+}
+
+// Verify that the command line arguments are parsed as expected.
+func TestParseArgs(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError error
+	}{
+		{
+			name: "default",
+		},
+		{
+			name: "tls",
+			args: []string{"--tls-cert", "tls.crt", "--tls-key", "tls.key", "--mount", "secrets/transit"},
+		},
+		{
+			name:          "cert-without-key",
+			args:          []string{"--tls-cert", "tls.crt"},
+			expectedError: flag.ErrHelp,
+		},
+		{
+			name:          "empty-mount",
+			args:          []string{"--mount", "/"},
+			expectedError: flag.ErrHelp,
+		},
+		{
+			name:          "sys-mount",
+			args:          []string{"--mount", "sys"},
+			expectedError: flag.ErrHelp,
+		},
+		{
+			name:          "negative-key-version",
+			args:          []string{"--key-version", "-1"},
+			expectedError: flag.ErrHelp,
+		},
+		{
+			name:          "extra-args",
+			args:          []string{"unexpected"},
+			expectedError: flag.ErrHelp,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := parseArgs(tst.args)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("parseArgs raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected parseArgs to raise %v, got %v", tst.expectedError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package unseal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The suffix appended to the names of backup files when --backup is given without a value.
+const defaultBackupSuffix = ".bak"
+
+// ErrInvalidBackupSuffix is returned when the backup suffix cannot be used to name a backup file.
+var ErrInvalidBackupSuffix = errors.New("invalid backup suffix")
+
+// The suffix to append to the name of an existing file to make a backup before it is replaced; an empty suffix disables
+// backups. Implements flag.Value as a boolean flag so that --backup enables backups with the default suffix, while
+// --backup=SUFFIX uses a custom suffix.
+type backupSuffix string
+
+// Implements flag.Value.
+func (b *backupSuffix) String() string {
+	if b == nil {
+		return ""
+	}
+	return string(*b)
+}
+
+// Implements flag.Value; true and false enable and disable backups with the default suffix, any other value is used as
+// the suffix.
+func (b *backupSuffix) Set(value string) error {
+	if enabled, err := strconv.ParseBool(value); err == nil {
+		*b = ""
+		if enabled {
+			*b = defaultBackupSuffix
+		}
+		return nil
+	}
+	if strings.ContainsAny(value, "/"+string(filepath.Separator)) {
+		return fmt.Errorf("backup suffix %q must not contain a path separator: %w", value, ErrInvalidBackupSuffix)
+	}
+	*b = backupSuffix(value)
+	return nil
+}
+
+// Allows the flag to be given without a value.
+func (b *backupSuffix) IsBoolFlag() bool {
+	return true
+}
+
+// Preserves the current content of the file at path by linking, or if that fails copying, it to a backup file with the
+// configured suffix, replacing any previous backup. Does nothing if backups are disabled or the file does not exist.
+func (p *processor) backup(path string) error {
+	if p.backupSuffix == "" {
+		return nil
+	}
+	info, err := os.Lstat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to stat file for backup: %w", err)
+	case !info.Mode().IsRegular():
+		return nil
+	}
+	backupPath := path + p.backupSuffix
+	logger := slog.With("path", path, "backupPath", backupPath)
+	if p.dryRun {
+		logger.Info("Dry run: existing file would be backed up")
+		return nil
+	}
+	logger.Debug("Backing up existing file")
+	if err := os.Remove(backupPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove previous backup: %w", err)
+	}
+	// The target will be replaced by a rename, so a hard link keeps the original content without copying it
+	if err := os.Link(path, backupPath); err == nil {
+		return nil
+	}
+	return copyFile(path, backupPath, info.Mode().Perm())
+}
+
+// Copies the content of the source file to a new file at target with the permissions.
+func copyFile(source, target string, mode fs.FileMode) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open file for backup: %w", err)
+	}
+	defer in.Close()
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to close backup file: %w", err)
+	}
+	return nil
+}
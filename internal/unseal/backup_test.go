@@ -0,0 +1,70 @@
+package unseal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Verify that the backup flag accepts a boolean or a suffix.
+func TestBackupSuffix(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		value         string
+		expected      string
+		expectedError error
+	}{
+		{value: "true", expected: defaultBackupSuffix},
+		{value: "false", expected: ""},
+		{value: ".orig", expected: ".orig"},
+		{value: "/tmp/", expectedError: ErrInvalidBackupSuffix},
+	}
+	for _, tst := range tests {
+		t.Run(tst.value, func(t *testing.T) {
+			t.Parallel()
+			suffix := backupSuffix("initial")
+			err := suffix.Set(tst.value)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("Set raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected Set to raise %v, got %v", tst.expectedError, err)
+			case tst.expectedError == nil && suffix.String() != tst.expected:
+				t.Errorf("Expected suffix %q, got %q", tst.expected, suffix.String())
+			}
+		})
+	}
+}
+
+// Verify that write preserves the previous content of a replaced file, and does not make a backup if the content is
+// unchanged or the file is new.
+func TestWrite_Backup(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "foo.ini")
+	p := &processor{
+		dirMode:      defaultDirMode,
+		fileMode:     defaultFileMode,
+		backupSuffix: defaultBackupSuffix,
+	}
+	for _, content := range []string{"first", "second", "second", "third"} {
+		if _, err := p.write(path, &entry{}, []byte(content)); err != nil {
+			t.Fatalf("write raised an unexpected error: %v", err)
+		}
+	}
+	data, err := os.ReadFile(path + defaultBackupSuffix)
+	switch {
+	case err != nil:
+		t.Errorf("Failed to read backup file: %v", err)
+	case string(data) != "second":
+		t.Errorf("Expected backup to contain %q, got %q", "second", data)
+	}
+	newPath := filepath.Join(tmpDir, "new.ini")
+	if _, err := p.write(newPath, &entry{}, []byte("new")); err != nil {
+		t.Fatalf("write raised an unexpected error: %v", err)
+	}
+	if _, err := os.Stat(newPath + defaultBackupSuffix); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected no backup of a new file, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package unseal
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The prefix of environment variables that provide values for command line flags.
+const envPrefix = "UNSEAL_"
+
+// ErrInvalidConfig is returned when a configuration file cannot be parsed or contains unknown settings.
+var ErrInvalidConfig = errors.New("invalid configuration file")
+
+// Returns the environment variable name that can be used to set the named flag, e.g. UNSEAL_WINGMAN_URL for
+// wingman-url.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// Reads a JSON or YAML configuration file that maps flag names to values, e.g. {"wingman-url": "grpc://wingman:8071",
+// "interval": "1m"}. Values must be scalars, and are returned as written in the file so that octal modes such as 0750
+// are preserved.
+func loadConfig(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file %s: %w", path, err)
+	}
+	var nodes map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &nodes); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file %s: %w: %w", path, err, ErrInvalidConfig)
+	}
+	config := make(map[string]string, len(nodes))
+	for name, node := range nodes {
+		if node.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("value of %s must be a scalar: %w", name, ErrInvalidConfig)
+		}
+		config[name] = node.Value
+	}
+	return config, nil
+}
+
+// Sets the value of each flag that was not given on the command line from the matching environment variable, or from
+// the configuration file, in that order of precedence.
+func applyDefaults(flags *flag.FlagSet, config map[string]string, getenv func(string) string) error {
+	explicit := map[string]struct{}{}
+	flags.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = struct{}{}
+	})
+	for name := range config {
+		if name == "config" || flags.Lookup(name) == nil {
+			return fmt.Errorf("unknown setting %q: %w", name, ErrInvalidConfig)
+		}
+	}
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		if _, ok := explicit[f.Name]; ok || err != nil {
+			return
+		}
+		source := envName(f.Name)
+		value := getenv(source)
+		if value == "" {
+			var ok bool
+			if value, ok = config[f.Name]; !ok {
+				return
+			}
+			source = "configuration file"
+		}
+		if setErr := flags.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s from %s: %w", value, f.Name, source, setErr)
+		}
+	})
+	return err
+}
@@ -0,0 +1,134 @@
+package unseal
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Verify that flags are set from the command line, environment, and configuration file in order of precedence.
+func TestParseArgs_Config(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	writeConfig := func(name, content string) string {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write configuration file: %v", err)
+		}
+		return path
+	}
+	config := writeConfig("unseal.yaml", `
+wingman-url: grpc://localhost:8071
+daemon: true
+interval: 1m
+dir-mode: 0700
+log-level: WARN
+`)
+	tests := []struct {
+		name             string
+		args             []string
+		env              map[string]string
+		expectedURL      string
+		expectedInterval time.Duration
+		expectedDirMode  fs.FileMode
+		expectedLogLevel slog.Level
+		expectedDaemon   bool
+		expectedError    error
+	}{
+		{
+			name:             "config-flag",
+			args:             []string{"--config", config, "a.json"},
+			expectedURL:      "grpc://localhost:8071",
+			expectedInterval: time.Minute,
+			expectedDirMode:  0o700,
+			expectedLogLevel: slog.LevelWarn,
+			expectedDaemon:   true,
+		},
+		{
+			name: "config-env",
+			args: []string{"a.json"},
+			env: map[string]string{
+				EnvConfig: config,
+			},
+			expectedURL:      "grpc://localhost:8071",
+			expectedInterval: time.Minute,
+			expectedDirMode:  0o700,
+			expectedLogLevel: slog.LevelWarn,
+			expectedDaemon:   true,
+		},
+		{
+			name: "env-overrides-config",
+			args: []string{"a.json"},
+			env: map[string]string{
+				EnvConfig:           config,
+				EnvWingmanURL:       "https://wingman:8070",
+				EnvLogLevel:         "DEBUG",
+				envName("interval"): "2m",
+			},
+			expectedURL:      "https://wingman:8070",
+			expectedInterval: 2 * time.Minute,
+			expectedDirMode:  0o700,
+			expectedLogLevel: slog.LevelDebug,
+			expectedDaemon:   true,
+		},
+		{
+			name: "flags-override-env",
+			args: []string{"--config", config, "--wingman-url=http://127.0.0.1:8070", "--dir-mode=0755", "a.json"},
+			env: map[string]string{
+				EnvWingmanURL: "https://wingman:8070",
+				EnvDirMode:    "0750",
+			},
+			expectedURL:      "http://127.0.0.1:8070",
+			expectedInterval: time.Minute,
+			expectedDirMode:  0o755,
+			expectedLogLevel: slog.LevelWarn,
+			expectedDaemon:   true,
+		},
+		{
+			name:          "unknown-setting",
+			args:          []string{"--config", writeConfig("unknown.json", `{"unknown": true}`), "a.json"},
+			expectedError: ErrInvalidConfig,
+		},
+		{
+			name:          "non-scalar-setting",
+			args:          []string{"--config", writeConfig("list.yaml", "interval: [1m]\n"), "a.json"},
+			expectedError: ErrInvalidConfig,
+		},
+		{
+			name:          "invalid-env-value",
+			args:          []string{"a.json"},
+			env:           map[string]string{EnvDirMode: "rwx"},
+			expectedError: ErrInvalidMode,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			opts, err := parseArgs(tst.args, nil, func(name string) string {
+				return tst.env[name]
+			})
+			switch {
+			case tst.expectedError != nil:
+				if !errors.Is(err, tst.expectedError) {
+					t.Errorf("Expected parseArgs to raise %v, got %v", tst.expectedError, err)
+				}
+			case err != nil:
+				t.Errorf("parseArgs raised an unexpected error: %v", err)
+			case opts.wingmanURL != tst.expectedURL:
+				t.Errorf("Expected wingman URL to be %q, got %q", tst.expectedURL, opts.wingmanURL)
+			case opts.interval != tst.expectedInterval:
+				t.Errorf("Expected interval to be %v, got %v", tst.expectedInterval, opts.interval)
+			case fs.FileMode(opts.dirMode) != tst.expectedDirMode:
+				t.Errorf("Expected dir mode to be %v, got %v", tst.expectedDirMode, fs.FileMode(opts.dirMode))
+			case opts.logLevel != tst.expectedLogLevel:
+				t.Errorf("Expected log level to be %v, got %v", tst.expectedLogLevel, opts.logLevel)
+			case opts.daemon != tst.expectedDaemon:
+				t.Errorf("Expected daemon to be %t, got %t", tst.expectedDaemon, opts.daemon)
+			}
+		})
+	}
+}
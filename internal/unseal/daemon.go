@@ -0,0 +1,240 @@
+package unseal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// The initial delay before retrying a failed refresh in daemon or watch mode.
+	initialRetryDelay = 1 * time.Second
+	// The maximum delay between retries of a failed refresh in daemon or watch mode.
+	maxRetryDelay = 5 * time.Minute
+)
+
+// Returns a timer that has been stopped and will not fire until reset.
+func newStoppedTimer() *time.Timer {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	return timer
+}
+
+// Processes the specification sources immediately, and then again whenever the daemon interval elapses, when a reload
+// signal (SIGHUP) is received or, in watch mode, when the specification or command line template files change. Failures
+// are logged and retried with exponential backoff. If reloaded is not nil each reload signal is sent to it once the
+// triggered refresh has completed, so that a child process can be told to reload the refreshed files. If a health
+// address has been set the health and metrics endpoints are served until the function returns. The function returns
+// when the context is canceled, or if the file watcher or health listener cannot be created; a refresh in progress when
+// the context is canceled is given the shutdown timeout to complete, so that files are not left partially updated.
+func (p *processor) daemon(ctx context.Context, opts *options, stdin func() ([]byte, error), reloaded chan<- os.Signal) error {
+	logger := slog.With("daemon", opts.daemon, "interval", opts.interval, "watch", opts.watch, "debounce", opts.debounce)
+	logger.Info("Starting daemon mode")
+
+	if opts.healthAddress != "" {
+		shutdown, err := p.serveHealth(opts.healthAddress)
+		if err != nil {
+			return err
+		}
+		defer shutdown()
+	}
+
+	var tick <-chan time.Time
+	if opts.daemon {
+		ticker := time.NewTicker(opts.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var events <-chan fsnotify.Event
+	var watchErrors <-chan error
+	var watched *watchSet
+	if opts.watch {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("failed to create file watcher: %w", err)
+		}
+		defer watcher.Close()
+		watched, err = watchSources(watcher, append(slices.Clone(opts.sources), opts.templates.sources()...))
+		if err != nil {
+			return err
+		}
+		events = watcher.Events
+		watchErrors = watcher.Errors
+	}
+
+	reload := make(chan os.Signal, 1)
+	if signals := reloadSignals(); len(signals) > 0 {
+		signal.Notify(reload, signals...)
+		defer signal.Stop(reload)
+	}
+
+	debounce := newStoppedTimer()
+	defer debounce.Stop()
+	retry := newStoppedTimer()
+	defer retry.Stop()
+	retryDelay := initialRetryDelay
+	workCtx, cancel := drainContext(ctx, opts.shutdownTimeout)
+	defer cancel()
+
+	refresh := func(reason string) {
+		if ctx.Err() != nil {
+			// Do not start new work once stopping, even if another event was ready at the same time
+			return
+		}
+		logger := logger.With("reason", reason)
+		logger.Debug("Refreshing unsealed files")
+		if err := p.processSources(workCtx, opts.sources, stdin); err != nil {
+			logger.Error("Processing failed, will retry", "error", err, "retryDelay", retryDelay)
+			retry.Reset(retryDelay)
+			retryDelay = min(2*retryDelay, maxRetryDelay)
+			return
+		}
+		logger.Debug("Processing complete")
+		retry.Stop()
+		retryDelay = initialRetryDelay
+	}
+
+	refresh("startup")
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Daemon mode is exiting")
+			return nil
+		case <-tick:
+			refresh("interval")
+		case sig := <-reload:
+			refresh("signal")
+			if reloaded != nil {
+				select {
+				case reloaded <- sig:
+				default:
+					logger.Warn("Reload signal was dropped", "signal", sig)
+				}
+			}
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				break
+			}
+			if isWatchedEvent(watched, event) {
+				logger.Debug("Specification change detected", "event", event)
+				debounce.Reset(opts.debounce)
+			}
+		case err, ok := <-watchErrors:
+			if !ok {
+				watchErrors = nil
+				break
+			}
+			logger.Warn("File watcher reported an error", "error", err)
+		case <-debounce.C:
+			refresh("watch")
+		case <-retry.C:
+			refresh("retry")
+		}
+	}
+}
+
+// The set of paths and patterns that will trigger a refresh in watch mode.
+type watchSet struct {
+	// Cleaned paths of specification files.
+	files map[string]struct{}
+	// Directories that have been added to the watcher.
+	dirs map[string]struct{}
+	// Directory sources; a change to any specification file within will trigger a refresh.
+	specDirs map[string]struct{}
+	// Cleaned glob pattern sources.
+	patterns []string
+}
+
+// Adds the directory to the watcher if it has not already been added.
+func (w *watchSet) watchDir(watcher *fsnotify.Watcher, dir string) error {
+	if _, ok := w.dirs[dir]; ok {
+		return nil
+	}
+	slog.Debug("Watching directory", "dir", dir)
+	if err := watcher.Add(dir); err != nil {
+		return fmt.Errorf("failed to watch directory %s: %w", dir, err)
+	}
+	w.dirs[dir] = struct{}{}
+	return nil
+}
+
+// Adds the parent directory of each file source to the watcher, returning the set of watched sources. Directories are
+// watched rather than the files themselves because tools such as Kubernetes replace files atomically by swapping
+// symlinks, which would otherwise remove the watch. Directory sources are watched directly, and glob patterns are
+// watched through the directory containing the pattern. Standard input, and glob patterns with meta characters in the
+// directory, cannot be watched and are ignored; as are URLs, which are only refreshed in daemon mode.
+func watchSources(watcher *fsnotify.Watcher, sources []string) (*watchSet, error) {
+	watched := &watchSet{
+		files:    map[string]struct{}{},
+		dirs:     map[string]struct{}{},
+		specDirs: map[string]struct{}{},
+	}
+	for _, source := range sources {
+		if source == stdinSource || isURLSource(source) {
+			slog.Warn("Standard input and URLs cannot be watched for changes", "source", source)
+			continue
+		}
+		path := filepath.Clean(source)
+		if isGlob(path) {
+			dir := filepath.Dir(path)
+			if isGlob(dir) {
+				slog.Warn("Glob patterns with wildcard directories cannot be watched for changes", "pattern", source)
+				continue
+			}
+			if err := watched.watchDir(watcher, dir); err != nil {
+				return nil, err
+			}
+			watched.patterns = append(watched.patterns, path)
+			continue
+		}
+		if stat, err := os.Stat(path); err == nil && stat.IsDir() {
+			if err := watched.watchDir(watcher, path); err != nil {
+				return nil, err
+			}
+			watched.specDirs[path] = struct{}{}
+			continue
+		}
+		if err := watched.watchDir(watcher, filepath.Dir(path)); err != nil {
+			return nil, err
+		}
+		watched.files[path] = struct{}{}
+	}
+	return watched, nil
+}
+
+// Returns true if the event affects a watched source file, a specification file in a watched directory or matching a
+// watched pattern, or is a Kubernetes atomic writer update in a watched directory (the ..data symlink and timestamped
+// directories).
+func isWatchedEvent(watched *watchSet, event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	path := filepath.Clean(event.Name)
+	if _, ok := watched.files[path]; ok {
+		return true
+	}
+	dir, base := filepath.Split(path)
+	dir = filepath.Clean(dir)
+	if _, ok := watched.dirs[dir]; ok && strings.HasPrefix(base, "..") {
+		return true
+	}
+	if _, ok := watched.specDirs[dir]; ok && isSpecFile(base) {
+		return true
+	}
+	for _, pattern := range watched.patterns {
+		if matched, _ := filepath.Match(pattern, path); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package unseal
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Verify that daemon and watch modes refresh files when the specification changes.
+func TestDaemon(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		opts options
+		// If true, the directory containing the specification is used as the source.
+		dirSource bool
+	}{
+		{
+			name: "daemon",
+			opts: options{
+				daemon:   true,
+				interval: 50 * time.Millisecond,
+			},
+		},
+		{
+			name: "watch",
+			opts: options{
+				watch:    true,
+				debounce: 10 * time.Millisecond,
+			},
+		},
+		{
+			name: "watch-dir",
+			opts: options{
+				watch:    true,
+				debounce: 10 * time.Millisecond,
+			},
+			dirSource: true,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			specFile := filepath.Join(tmpDir, "spec.json")
+			target := filepath.Join(tmpDir, "target")
+			writeSpec := func(sealed string) {
+				if err := os.WriteFile(specFile, []byte(`{"`+target+`":"`+sealed+`"}`), 0o600); err != nil {
+					t.Fatalf("Failed to write spec file: %v", err)
+				}
+			}
+			waitForContent := func(expected []byte) {
+				deadline := time.Now().Add(3 * time.Second)
+				for time.Now().Before(deadline) {
+					if data, err := os.ReadFile(target); err == nil && bytes.Equal(data, expected) {
+						return
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+				t.Errorf("Timed out waiting for target to contain %q", expected)
+			}
+			// spell-checker: disable
+			writeSpec("ZnZ6Y3lyLndmYmE=")
+			p := testProcessor(t)
+			opts := tst.opts
+			opts.sources = []string{specFile}
+			if tst.dirSource {
+				opts.sources = []string{tmpDir}
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() {
+				done <- p.daemon(ctx, &opts, nil, nil)
+			}()
+			waitForContent([]byte("simple.json"))
+			writeSpec("dXJ5eWI=")
+			waitForContent([]byte("hello"))
+			// spell-checker: enable
+			cancel()
+			if err := <-done; err != nil {
+				t.Errorf("daemon raised an unexpected error: %v", err)
+			}
+		})
+	}
+}
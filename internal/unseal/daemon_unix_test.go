@@ -0,0 +1,71 @@
+//go:build !windows
+
+package unseal
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Verify that SIGHUP triggers an immediate refresh in daemon mode, and is passed on once the refresh is complete. The
+// signal is delivered to the whole process so this test must not run in parallel with others.
+//
+//nolint:paralleltest // See above
+func TestDaemon_SIGHUP(t *testing.T) {
+	tmpDir := t.TempDir()
+	specFile := filepath.Join(tmpDir, "spec.json")
+	target := filepath.Join(tmpDir, "target")
+	writeSpec := func(sealed string) {
+		if err := os.WriteFile(specFile, []byte(`{"`+target+`":"`+sealed+`"}`), 0o600); err != nil {
+			t.Fatalf("Failed to write spec file: %v", err)
+		}
+	}
+	waitForContent := func(expected []byte) {
+		deadline := time.Now().Add(3 * time.Second)
+		for time.Now().Before(deadline) {
+			if data, err := os.ReadFile(target); err == nil && bytes.Equal(data, expected) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("Timed out waiting for target to contain %q", expected)
+	}
+	// spell-checker: disable
+	writeSpec("ZnZ6Y3lyLndmYmE=")
+	p := testProcessor(t)
+	opts := options{
+		daemon:   true,
+		interval: time.Hour,
+		sources:  []string{specFile},
+	}
+	reloaded := make(chan os.Signal, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- p.daemon(ctx, &opts, nil, reloaded)
+	}()
+	waitForContent([]byte("simple.json"))
+	writeSpec("dXJ5eWI=")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("Failed to send SIGHUP: %v", err)
+	}
+	waitForContent([]byte("hello"))
+	// spell-checker: enable
+	select {
+	case sig := <-reloaded:
+		if sig != syscall.SIGHUP {
+			t.Errorf("Expected %v, got %v", syscall.SIGHUP, sig)
+		}
+	case <-time.After(3 * time.Second):
+		t.Errorf("Timed out waiting for reload signal")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("daemon raised an unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package unseal
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ErrMissingCommand is returned when exec mode is requested without a command to execute.
+var ErrMissingCommand = errors.New("a command must be provided after -- in exec mode")
+
+// Splits the arguments at the first "--", returning the arguments before it and the command that follows it. If there
+// is no "--" the command will be nil.
+func splitCommand(args []string) ([]string, []string) {
+	idx := slices.Index(args, "--")
+	if idx < 0 {
+		return args, nil
+	}
+	return args[:idx], args[idx+1:]
+}
+
+// Records an unsealed value that will be exported to the environment of the executed command.
+func (p *processor) setEnv(name string, value []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.env == nil {
+		p.env = map[string]string{}
+	}
+	p.env[name] = string(value)
+}
+
+// Returns the environment for the executed command; the current process environment with any exported values added,
+// replacing existing variables with the same name.
+func (p *processor) environ() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	environ := make([]string, 0, len(os.Environ())+len(p.env))
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if _, ok := p.env[name]; !ok {
+			environ = append(environ, kv)
+		}
+	}
+	names := make([]string, 0, len(p.env))
+	for name := range p.env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		environ = append(environ, name+"="+p.env[name])
+	}
+	return environ
+}
+
+// Starts the command as a child process with the supplied environment, forwarding signals received by this process to
+// the child, and returns the exit code of the child once it completes. If reloaded is not nil, reload signals are not
+// forwarded directly; instead any signal received from reloaded is forwarded, so that the child is only told to reload
+// after the files have been refreshed.
+func runChild(command, environ []string, reloaded <-chan os.Signal) (int, error) {
+	path, err := exec.LookPath(command[0])
+	if err != nil {
+		return 1, fmt.Errorf("failed to find command %q: %w", command[0], err)
+	}
+	logger := slog.With("path", path, "args", command[1:])
+	cmd := exec.Command(path, command[1:]...) //nolint:gosec // Executing a user provided command is the purpose of exec mode
+	cmd.Env = environ
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	forwarded := forwardedSignals()
+	if reloaded != nil {
+		forwarded = slices.DeleteFunc(forwarded, func(sig os.Signal) bool {
+			return slices.Contains(reloadSignals(), sig)
+		})
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwarded...)
+	defer signal.Stop(signals)
+	logger.Debug("Starting child process")
+	if err := cmd.Start(); err != nil {
+		return 1, fmt.Errorf("failed to start command: %w", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	for {
+		select {
+		case sig := <-reloaded:
+			logger.Debug("Forwarding reload signal to child process", "signal", sig)
+			if err := cmd.Process.Signal(sig); err != nil {
+				logger.Warn("Failed to forward signal to child process", "signal", sig, "error", err)
+			}
+		case sig := <-signals:
+			logger.Debug("Forwarding signal to child process", "signal", sig)
+			if err := cmd.Process.Signal(sig); err != nil {
+				logger.Warn("Failed to forward signal to child process", "signal", sig, "error", err)
+			}
+		case err := <-done:
+			var exitErr *exec.ExitError
+			switch {
+			case errors.As(err, &exitErr):
+				logger.Debug("Child process exited", "exitCode", exitErr.ExitCode())
+				return exitErr.ExitCode(), nil
+			case err != nil:
+				return 1, fmt.Errorf("failed waiting for command: %w", err)
+			}
+			logger.Debug("Child process exited successfully")
+			return 0, nil
+		}
+	}
+}
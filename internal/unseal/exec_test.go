@@ -0,0 +1,90 @@
+package unseal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Verify that splitCommand separates arguments at the first "--".
+func TestSplitCommand(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name            string
+		args            []string
+		expectedArgs    []string
+		expectedCommand []string
+	}{
+		{
+			name:         "no-command",
+			args:         []string{"--exec", "spec.json"},
+			expectedArgs: []string{"--exec", "spec.json"},
+		},
+		{
+			name:            "command",
+			args:            []string{"--exec", "spec.json", "--", "nginx", "-g", "daemon off;"},
+			expectedArgs:    []string{"--exec", "spec.json"},
+			expectedCommand: []string{"nginx", "-g", "daemon off;"},
+		},
+		{
+			name:            "nested-separator",
+			args:            []string{"--exec", "--", "sh", "-c", "echo", "--", "foo"},
+			expectedArgs:    []string{"--exec"},
+			expectedCommand: []string{"sh", "-c", "echo", "--", "foo"},
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			args, command := splitCommand(tst.args)
+			if !slices.Equal(args, tst.expectedArgs) {
+				t.Errorf("Expected args %v, got %v", tst.expectedArgs, args)
+			}
+			if !slices.Equal(command, tst.expectedCommand) {
+				t.Errorf("Expected command %v, got %v", tst.expectedCommand, command)
+			}
+		})
+	}
+}
+
+// Verify that env entries are exported rather than written in exec mode, and that a child process receives them.
+func TestRunChild(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Test requires a POSIX shell")
+	}
+	t.Parallel()
+	tmpDir := t.TempDir()
+	p := testProcessor(t)
+	p.exportEnv = true
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	// spell-checker: disable
+	spec := map[string]entry{
+		"UNSEAL_TEST_VALUE":                  {Data: "dXJ5eWI=", Env: "UNSEAL_TEST_VALUE"},
+		filepath.Join(tmpDir, "simple.json"): {Data: "ZnZ6Y3lyLndmYmE="},
+	}
+	// spell-checker: enable
+	if err := p.process(ctx, spec); err != nil {
+		t.Fatalf("process raised an unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "simple.json")); err != nil {
+		t.Errorf("Expected file entry to be written: %v", err)
+	}
+	if p.env["UNSEAL_TEST_VALUE"] != "hello" {
+		t.Errorf("Expected env entry to be recorded for export, got %q", p.env["UNSEAL_TEST_VALUE"])
+	}
+	retCode, err := runChild([]string{"sh", "-c", `test "$UNSEAL_TEST_VALUE" = hello && exit 3`}, p.environ(), nil)
+	switch {
+	case err != nil:
+		t.Errorf("runChild raised an unexpected error: %v", err)
+	case retCode != 3:
+		t.Errorf("Expected exit code 3, got %d", retCode)
+	}
+	if _, err := runChild([]string{"unseal-test-command-does-not-exist"}, p.environ(), nil); err == nil {
+		t.Errorf("Expected runChild to raise an error for a missing command")
+	}
+}
@@ -0,0 +1,68 @@
+//go:build !windows
+
+package unseal
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// Replaces the current process with the command, using the supplied environment, so that the command inherits the
+// process id and receives signals directly. The function only returns if the command could not be executed.
+func execCommand(command, environ []string) (int, error) {
+	path, err := exec.LookPath(command[0])
+	if err != nil {
+		return 1, fmt.Errorf("failed to find command %q: %w", command[0], err)
+	}
+	slog.Debug("Replacing process with command", "path", path, "args", command[1:])
+	if err := syscall.Exec(path, command, environ); err != nil { //nolint:gosec // Executing a user provided command is the purpose of exec mode
+		return 1, fmt.Errorf("failed to execute command: %w", err)
+	}
+	return 0, nil
+}
+
+// Returns the signals that will be forwarded to a child process.
+func forwardedSignals() []os.Signal {
+	return []os.Signal{
+		syscall.SIGHUP,
+		syscall.SIGINT,
+		syscall.SIGQUIT,
+		syscall.SIGTERM,
+		syscall.SIGUSR1,
+		syscall.SIGUSR2,
+		syscall.SIGWINCH,
+	}
+}
+
+// Returns the signals that trigger an immediate refresh in daemon and watch modes.
+func reloadSignals() []os.Signal {
+	return []os.Signal{syscall.SIGHUP}
+}
+
+// Returns the command and arguments that will run the command line with the system shell.
+func shellCommand(command string) []string {
+	return []string{"/bin/sh", "-c", command}
+}
+
+// Returns the signal with the name, with or without the SIG prefix, that a hook can send to a process.
+func parseSignal(name string) (os.Signal, error) {
+	switch strings.TrimPrefix(name, "SIG") {
+	case "HUP":
+		return syscall.SIGHUP, nil
+	case "INT":
+		return syscall.SIGINT, nil
+	case "QUIT":
+		return syscall.SIGQUIT, nil
+	case "TERM":
+		return syscall.SIGTERM, nil
+	case "USR1":
+		return syscall.SIGUSR1, nil
+	case "USR2":
+		return syscall.SIGUSR2, nil
+	}
+	return nil, fmt.Errorf("unsupported signal %q: %w", name, ErrInvalidEntry)
+}
@@ -0,0 +1,34 @@
+//go:build windows
+
+package unseal
+
+import (
+	"fmt"
+	"os"
+)
+
+// Windows does not support replacing the current process, so execute the command as a child process and return its
+// exit code.
+func execCommand(command, environ []string) (int, error) {
+	return runChild(command, environ, nil)
+}
+
+// Returns the signals that will be forwarded to a child process.
+func forwardedSignals() []os.Signal {
+	return []os.Signal{os.Interrupt}
+}
+
+// Windows does not have a conventional reload signal.
+func reloadSignals() []os.Signal {
+	return nil
+}
+
+// Returns the command and arguments that will run the command line with the system shell.
+func shellCommand(command string) []string {
+	return []string{"cmd.exe", "/C", command}
+}
+
+// Windows cannot send signals to other processes.
+func parseSignal(name string) (os.Signal, error) {
+	return nil, fmt.Errorf("signal %q cannot be sent on windows: %w", name, ErrInvalidEntry)
+}
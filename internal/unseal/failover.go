@@ -0,0 +1,106 @@
+package unseal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/memes/f5xc/wingman"
+)
+
+// Implements wingman.Client by trying each of a list of Wingman clients in order until one succeeds.
+type failoverClient struct {
+	endpoints []string
+	clients   []wingman.Client
+}
+
+// Returns a Wingman client for the endpoint, which may be a comma-separated list of endpoint URLs to be tried in order.
+func newWingmanClient(endpoint string) (wingman.Client, error) {
+	var endpoints []string
+	for _, value := range strings.Split(endpoint, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			endpoints = append(endpoints, value)
+		}
+	}
+	if len(endpoints) == 1 {
+		return wingman.NewClient(endpoints[0]) //nolint:wrapcheck // Error is logged by caller
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no endpoints in %q: %w", endpoint, wingman.ErrInvalidEndpoint)
+	}
+	client := &failoverClient{
+		endpoints: endpoints,
+		clients:   make([]wingman.Client, 0, len(endpoints)),
+	}
+	for _, value := range endpoints {
+		c, err := wingman.NewClient(value)
+		if err != nil {
+			_ = client.Close()
+			return nil, fmt.Errorf("failed to create client for %s: %w", value, err)
+		}
+		client.clients = append(client.clients, c)
+	}
+	return client, nil
+}
+
+// Calls fn with each client in turn, returning the result of the first call that succeeds. A policy denial is returned
+// immediately as another endpoint would give the same answer, as is any error once the context is done; otherwise the
+// errors from every endpoint are joined.
+func failover[T any](ctx context.Context, c *failoverClient, fn func(wingman.Client) (T, error)) (T, error) {
+	var errs []error
+	for i, client := range c.clients {
+		result, err := fn(client)
+		switch {
+		case err == nil:
+			return result, nil
+		case errors.Is(err, wingman.ErrDeniedByPolicy), ctx.Err() != nil:
+			return result, err
+		}
+		slog.Debug("Wingman endpoint failed, trying next endpoint", "endpoint", c.endpoints[i], "error", err)
+		errs = append(errs, fmt.Errorf("%s: %w", c.endpoints[i], err))
+	}
+	var zero T
+	return zero, errors.Join(errs...)
+}
+
+// Ready returns nil if any of the Wingman endpoints report ready.
+func (c *failoverClient) Ready(ctx context.Context) error {
+	_, err := failover(ctx, c, func(client wingman.Client) (struct{}, error) {
+		return struct{}{}, client.Ready(ctx)
+	})
+	return err
+}
+
+// Unseal a byte slice of blindfold data with the first Wingman endpoint that succeeds.
+func (c *failoverClient) Unseal(ctx context.Context, sealed []byte) ([]byte, error) {
+	return failover(ctx, c, func(client wingman.Client) ([]byte, error) {
+		return client.Unseal(ctx, sealed)
+	})
+}
+
+// Unseal a byte slice of base64 encoded blindfold data with the first Wingman endpoint that succeeds.
+func (c *failoverClient) UnsealEncoded(ctx context.Context, sealed []byte) ([]byte, error) {
+	return failover(ctx, c, func(client wingman.Client) ([]byte, error) {
+		return client.UnsealEncoded(ctx, sealed)
+	})
+}
+
+// Close releases the connections held by every client.
+func (c *failoverClient) Close() error {
+	var errs []error
+	for _, client := range c.clients {
+		errs = append(errs, client.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown waits for the unseal requests in progress on every client to complete, then releases their connections.
+func (c *failoverClient) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, client := range c.clients {
+		errs = append(errs, client.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
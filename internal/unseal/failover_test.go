@@ -0,0 +1,86 @@
+package unseal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc/wingman"
+)
+
+// Verify that a comma-separated list of endpoints is tried in order, and that policy denials are not retried.
+func TestNewWingmanClient_Failover(t *testing.T) {
+	t.Parallel()
+	statusServer := func(code int) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(code)
+		}))
+		t.Cleanup(server.Close)
+		return server.URL
+	}
+	unavailable := statusServer(http.StatusServiceUnavailable)
+	denied := statusServer(http.StatusForbidden)
+	working := httptest.NewServer(testWingmanUnsealHandler(t))
+	t.Cleanup(working.Close)
+	tests := []struct {
+		name          string
+		endpoint      string
+		expectedError error
+	}{
+		{
+			name:     "single",
+			endpoint: working.URL,
+		},
+		{
+			name:     "failover",
+			endpoint: unavailable + ", " + working.URL,
+		},
+		{
+			name:          "all-unavailable",
+			endpoint:      unavailable + "," + unavailable,
+			expectedError: wingman.ErrNotReady,
+		},
+		{
+			name:          "denied",
+			endpoint:      denied + "," + working.URL,
+			expectedError: wingman.ErrDeniedByPolicy,
+		},
+		{
+			name:          "invalid",
+			endpoint:      working.URL + ",ftp://localhost",
+			expectedError: wingman.ErrInvalidEndpoint,
+		},
+		{
+			name:          "empty",
+			endpoint:      " , ",
+			expectedError: wingman.ErrInvalidEndpoint,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			client, err := newWingmanClient(tst.endpoint)
+			if err == nil {
+				t.Cleanup(func() {
+					_ = client.Close()
+				})
+				err = client.Ready(ctx)
+				if err == nil {
+					// spell-checker: disable-next-line
+					_, err = client.Unseal(ctx, []byte("fvzcyr"))
+				}
+			}
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("Unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected error %v, got %v", tst.expectedError, err)
+			}
+		})
+	}
+}
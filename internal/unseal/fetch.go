@@ -0,0 +1,101 @@
+package unseal
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// The maximum size of a specification that will be fetched from a URL.
+const maxFetchSize = 10 << 20
+
+var (
+	// ErrChecksumMismatch is returned when a specification fetched from a URL does not match the expected checksum.
+	ErrChecksumMismatch = errors.New("specification checksum mismatch")
+	// ErrFetchFailed is returned when a specification cannot be fetched from a URL.
+	ErrFetchFailed = errors.New("failed to fetch specification")
+)
+
+// Returns true if the source is an http or https URL.
+func isURLSource(source string) bool {
+	lower := strings.ToLower(source)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// Fetches the specification from an http(s) URL, returning the content and the URL path so that the format can be
+// determined from the extension. If a bearer token or token file has been configured it is sent in the Authorization
+// header; a token file is re-read on every fetch so that rotated tokens are used. A URL fragment of the form
+// sha256=HEX declares the expected SHA-256 checksum of the content, which will be verified.
+func (p *processor) fetchSpec(ctx context.Context, source string) ([]byte, string, error) {
+	specURL, err := url.Parse(source)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid URL: %w: %w", err, ErrFetchFailed)
+	}
+	var checksum []byte
+	if specURL.Fragment != "" {
+		value, ok := strings.CutPrefix(specURL.Fragment, "sha256=")
+		if !ok {
+			return nil, "", fmt.Errorf("unsupported checksum %q: %w", specURL.Fragment, ErrFetchFailed)
+		}
+		if checksum, err = hex.DecodeString(value); err != nil || len(checksum) != sha256.Size {
+			return nil, "", fmt.Errorf("invalid sha256 checksum %q: %w", value, ErrFetchFailed)
+		}
+		specURL.Fragment = ""
+	}
+	logger := slog.With("url", specURL.Redacted())
+	logger.Debug("Fetching specification")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, specURL.String(), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w: %w", err, ErrFetchFailed)
+	}
+	token, err := p.specBearerToken()
+	if err != nil {
+		return nil, "", err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("request failed: %w: %w", err, ErrFetchFailed)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected HTTP status code %d: %w", resp.StatusCode, ErrFetchFailed)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchSize+1))
+	switch {
+	case err != nil:
+		return nil, "", fmt.Errorf("failed to read response body: %w: %w", err, ErrFetchFailed)
+	case len(data) > maxFetchSize:
+		return nil, "", fmt.Errorf("specification exceeds %d bytes: %w", maxFetchSize, ErrFetchFailed)
+	}
+	if checksum != nil {
+		actual := sha256.Sum256(data)
+		if !bytes.Equal(checksum, actual[:]) {
+			return nil, "", fmt.Errorf("expected sha256 %x, got %x: %w", checksum, actual, ErrChecksumMismatch)
+		}
+	}
+	return data, specURL.Path, nil
+}
+
+// Returns the bearer token to use when fetching specifications; the token file takes precedence over a token.
+func (p *processor) specBearerToken() (string, error) {
+	if p.specTokenFile == "" {
+		return p.specToken, nil
+	}
+	data, err := os.ReadFile(p.specTokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read bearer token file: %w", err)
+	}
+	return string(bytes.TrimSpace(data)), nil
+}
@@ -0,0 +1,93 @@
+package unseal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Verify that specifications can be fetched from URLs with bearer tokens and checksum verification.
+func TestLoadSpec_URL(t *testing.T) {
+	t.Parallel()
+	spec := []byte("/etc/app/secret: ZnZ6Y3lyLndmYmE=\n")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/private.yaml" && r.Header.Get("Authorization") != "Bearer s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write(spec)
+	}))
+	t.Cleanup(server.Close)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("s3cr3t\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+	digest := sha256.Sum256(spec)
+	checksum := hex.EncodeToString(digest[:])
+	tests := []struct {
+		name          string
+		source        string
+		specToken     string
+		specTokenFile string
+		expectedError error
+	}{
+		{
+			name:   "public",
+			source: server.URL + "/public.yaml",
+		},
+		{
+			name:          "unauthorized",
+			source:        server.URL + "/private.yaml",
+			expectedError: ErrFetchFailed,
+		},
+		{
+			name:      "token",
+			source:    server.URL + "/private.yaml",
+			specToken: "s3cr3t",
+		},
+		{
+			name:          "token-file",
+			source:        server.URL + "/private.yaml",
+			specToken:     "ignored",
+			specTokenFile: tokenFile,
+		},
+		{
+			name:   "checksum",
+			source: server.URL + "/public.yaml#sha256=" + checksum,
+		},
+		{
+			name:          "checksum-mismatch",
+			source:        server.URL + "/public.yaml#sha256=" + checksum[1:] + "0",
+			expectedError: ErrChecksumMismatch,
+		},
+		{
+			name:          "unsupported-checksum",
+			source:        server.URL + "/public.yaml#md5=" + checksum,
+			expectedError: ErrFetchFailed,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			p := &processor{specToken: tst.specToken, specTokenFile: tst.specTokenFile}
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			result, err := p.loadSpec(ctx, tst.source, nil)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("loadSpec raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected loadSpec to raise %v, got %v", tst.expectedError, err)
+			case err == nil && result["/etc/app/secret"].Data != "ZnZ6Y3lyLndmYmE=":
+				t.Errorf("Unexpected specification %v", result)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package unseal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// The maximum time to wait for Wingman to respond to a readiness check made by the health endpoint.
+	healthReadyTimeout = 5 * time.Second
+	// The maximum time to wait for in-flight health and metrics requests when the daemon exits.
+	healthShutdownTimeout = 5 * time.Second
+)
+
+// Records counters and the outcome of the most recent run for the health and metrics endpoints. The zero value is
+// ready to use.
+type metrics struct {
+	// The number of values successfully unsealed by Wingman.
+	unseals atomic.Uint64
+	// The number of values that Wingman failed to unseal, after any retries.
+	unsealFailures atomic.Uint64
+	// The number of entries, or specification sources, that could not be processed.
+	entryFailures atomic.Uint64
+	// The number of files written with changed content.
+	filesWritten atomic.Uint64
+	// The number of bytes written to files.
+	bytesWritten atomic.Uint64
+	// The number of completed runs, and the number of those that failed.
+	runs        atomic.Uint64
+	runFailures atomic.Uint64
+	// Guards the fields that describe the most recent run.
+	mu          sync.Mutex
+	lastRun     time.Time
+	lastSuccess time.Time
+	lastError   error
+}
+
+// Records the outcome of a run.
+func (m *metrics) recordRun(err error) {
+	m.runs.Add(1)
+	if err != nil {
+		m.runFailures.Add(1)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastRun = time.Now()
+	m.lastError = err
+	if err == nil {
+		m.lastSuccess = m.lastRun
+	}
+}
+
+// Records that a file was written.
+func (m *metrics) recordWrite(size int) {
+	m.filesWritten.Add(1)
+	m.bytesWritten.Add(uint64(size)) //nolint:gosec // Size of a byte slice cannot be negative
+}
+
+// Returns the time and error of the most recent run, and the time of the most recent successful run.
+func (m *metrics) lastRunStatus() (time.Time, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastRun, m.lastSuccess, m.lastError
+}
+
+// Writes the metrics in the Prometheus text exposition format.
+func (m *metrics) write(w io.Writer) error {
+	lastRun, lastSuccess, _ := m.lastRunStatus()
+	timestamp := func(t time.Time) float64 {
+		if t.IsZero() {
+			return 0
+		}
+		return float64(t.UnixMilli()) / 1000
+	}
+	var errs []error
+	for _, metric := range []struct {
+		name      string
+		help      string
+		kind      string
+		value     any
+		valueSpec string
+	}{
+		{"unseal_unseals_total", "Values successfully unsealed by Wingman.", "counter", m.unseals.Load(), "%d"},
+		{"unseal_unseal_failures_total", "Values that Wingman failed to unseal after retries.", "counter", m.unsealFailures.Load(), "%d"},
+		{"unseal_entry_failures_total", "Entries or specification sources that could not be processed.", "counter", m.entryFailures.Load(), "%d"},
+		{"unseal_files_written_total", "Files written with changed content.", "counter", m.filesWritten.Load(), "%d"},
+		{"unseal_bytes_written_total", "Bytes written to files.", "counter", m.bytesWritten.Load(), "%d"},
+		{"unseal_runs_total", "Completed processing runs.", "counter", m.runs.Load(), "%d"},
+		{"unseal_run_failures_total", "Processing runs that failed.", "counter", m.runFailures.Load(), "%d"},
+		{"unseal_last_run_timestamp_seconds", "Time of the most recent run.", "gauge", timestamp(lastRun), "%g"},
+		{"unseal_last_success_timestamp_seconds", "Time of the most recent successful run.", "gauge", timestamp(lastSuccess), "%g"},
+	} {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s "+metric.valueSpec+"\n",
+			metric.name, metric.help, metric.name, metric.kind, metric.name, metric.value)
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
+
+// The JSON body returned by the health endpoint.
+type healthStatus struct {
+	Healthy      bool       `json:"healthy"`
+	WingmanReady bool       `json:"wingmanReady"`
+	LastRun      *time.Time `json:"lastRun,omitempty"`
+	LastSuccess  *time.Time `json:"lastSuccess,omitempty"`
+	LastError    string     `json:"lastError,omitempty"`
+}
+
+// Returns a handler that serves /healthz and /metrics. The health endpoint returns 200 if the most recent run succeeded
+// and Wingman reports ready, and 503 otherwise, including before the first run completes; the body describes the status
+// as JSON.
+func (p *processor) healthHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		lastRun, lastSuccess, lastErr := p.metrics.lastRunStatus()
+		ctx, cancel := context.WithTimeout(r.Context(), healthReadyTimeout)
+		defer cancel()
+		status := healthStatus{
+			WingmanReady: p.client.Ready(ctx) == nil,
+		}
+		if !lastRun.IsZero() {
+			status.LastRun = &lastRun
+		}
+		if !lastSuccess.IsZero() {
+			status.LastSuccess = &lastSuccess
+		}
+		if lastErr != nil {
+			status.LastError = lastErr.Error()
+		}
+		status.Healthy = status.WingmanReady && !lastRun.IsZero() && lastErr == nil
+		w.Header().Set("Content-Type", "application/json")
+		if !status.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			slog.Warn("Failed to write health status", "error", err)
+		}
+	})
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := p.metrics.write(w); err != nil {
+			slog.Warn("Failed to write metrics", "error", err)
+		}
+	})
+	return mux
+}
+
+// Starts an HTTP server for the health and metrics endpoints on the address, returning a function that will shut the
+// server down.
+func (p *processor) serveHealth(address string) (func(), error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for health requests: %w", err)
+	}
+	logger := slog.With("address", listener.Addr().String())
+	server := &http.Server{
+		Handler:           p.healthHandler(),
+		ReadHeaderTimeout: healthReadyTimeout,
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		logger.Info("Serving health and metrics endpoints")
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Health server failed", "error", err)
+		}
+	}()
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), healthShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Warn("Failed to shutdown health server", "error", err)
+		}
+		<-done
+	}, nil
+}
@@ -0,0 +1,82 @@
+package unseal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Verify that the health endpoint reflects the outcome of the most recent run, and that the metrics endpoint reports
+// the counters.
+func TestHealthHandler(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	target := filepath.Join(tmpDir, "target")
+	source := filepath.Join(tmpDir, "spec.json")
+	// spell-checker: disable-next-line
+	if err := os.WriteFile(source, []byte(`{"`+target+`": "ZnZ6Y3lyLndmYmE="}`), 0o600); err != nil {
+		t.Fatalf("Failed to write specification: %v", err)
+	}
+	p := testProcessor(t)
+	server := httptest.NewServer(p.healthHandler())
+	t.Cleanup(server.Close)
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := server.Client().Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Get %s raised an unexpected error: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to read %s response: %v", path, err)
+		}
+		return resp.StatusCode, string(body)
+	}
+	if code, _ := get("/healthz"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d before the first run, got %d", http.StatusServiceUnavailable, code)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := p.processSources(ctx, []string{source}, nil); err != nil {
+		t.Fatalf("processSources raised an unexpected error: %v", err)
+	}
+	code, body := get("/healthz")
+	status := healthStatus{}
+	if err := json.Unmarshal([]byte(body), &status); err != nil {
+		t.Fatalf("Failed to decode health status: %v", err)
+	}
+	switch {
+	case code != http.StatusOK:
+		t.Errorf("Expected status %d after a successful run, got %d: %s", http.StatusOK, code, body)
+	case !status.Healthy || !status.WingmanReady || status.LastRun == nil || status.LastError != "":
+		t.Errorf("Unexpected health status: %s", body)
+	}
+	if err := p.processSources(ctx, []string{filepath.Join(tmpDir, "missing.json")}, nil); err == nil {
+		t.Fatal("Expected processSources to raise an error")
+	}
+	if code, body := get("/healthz"); code != http.StatusServiceUnavailable || !strings.Contains(body, "missing.json") {
+		t.Errorf("Expected status %d after a failed run, got %d: %s", http.StatusServiceUnavailable, code, body)
+	}
+	_, body = get("/metrics")
+	for _, expected := range []string{
+		"unseal_unseals_total 1\n",
+		"unseal_entry_failures_total 1\n",
+		"unseal_files_written_total 1\n",
+		"unseal_bytes_written_total 11\n",
+		"unseal_runs_total 2\n",
+		"unseal_run_failures_total 1\n",
+		"# TYPE unseal_last_success_timestamp_seconds gauge\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected metrics to contain %q, got %s", expected, body)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package unseal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The default maximum time a hook command may run.
+const DefaultHookTimeout = 30 * time.Second
+
+// ErrHookFailed is returned when a hook command fails and failures are not ignored.
+var ErrHookFailed = errors.New("hook command failed")
+
+// Describes a command to run, or a signal to send to a running process, after one or more files have been written, e.g.
+// to tell a daemon to reload.
+type hook struct {
+	// The command and arguments to execute; the command is not interpreted by a shell.
+	Command []string `json:"command,omitempty" yaml:"command,omitempty"`
+	// Optional name of a signal, e.g. "SIGHUP", to send to the process identified by the pid file instead of running a
+	// command.
+	Signal string `json:"signal,omitempty" yaml:"signal,omitempty"`
+	// The file containing the id of the process that will receive the signal.
+	PidFile string `json:"pidFile,omitempty" yaml:"pidFile,omitempty"`
+	// Optional maximum duration the command may run, e.g. "10s"; the default is the processor hook timeout.
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// If true a failure of the command will be logged but will not cause processing to fail.
+	IgnoreFailure bool `json:"ignoreFailure,omitempty" yaml:"ignoreFailure,omitempty"`
+}
+
+// Returns an error if the hook is not valid.
+func (h *hook) validate() error {
+	switch {
+	case h.Signal != "" && len(h.Command) > 0:
+		return fmt.Errorf("hook command and signal cannot both be set: %w", ErrInvalidEntry)
+	case (h.Signal == "") != (h.PidFile == ""):
+		return fmt.Errorf("hook signal and pid file must be set together: %w", ErrInvalidEntry)
+	case h.Signal != "":
+		if _, err := parseSignal(h.Signal); err != nil {
+			return err
+		}
+	case len(h.Command) == 0 || h.Command[0] == "":
+		return fmt.Errorf("hook command must not be empty: %w", ErrInvalidEntry)
+	}
+	if h.Timeout != "" {
+		if timeout, err := time.ParseDuration(h.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("hook timeout %q is not a positive duration: %w", h.Timeout, ErrInvalidEntry)
+		}
+	}
+	return nil
+}
+
+// Returns a key that identifies hooks with the same command, or the same signal and pid file.
+func (h *hook) key() string {
+	if h.Signal != "" {
+		return "\x00" + h.Signal + "\x00" + h.PidFile
+	}
+	return strings.Join(h.Command, "\x00")
+}
+
+// Returns a logger with attributes that describe the hook.
+func (h *hook) logger() *slog.Logger {
+	if h.Signal != "" {
+		return slog.With("signal", h.Signal, "pidFile", h.PidFile)
+	}
+	return slog.With("command", h.Command)
+}
+
+// Records that the hook should be run once processing is complete; a hook that is queued several times, e.g. because it
+// is shared by a certificate and key, will only be run once.
+func (p *processor) queueHook(h *hook) {
+	if h == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pendingHooks == nil {
+		p.pendingHooks = map[string]*hook{}
+	}
+	p.pendingHooks[h.key()] = h
+}
+
+// Runs each queued hook in turn, then clears the queue. In dry-run mode the hooks are logged but not executed. Returns
+// the joined errors of any hooks that failed and do not ignore failure.
+func (p *processor) runHooks(ctx context.Context) error {
+	p.mu.Lock()
+	pending := p.pendingHooks
+	p.pendingHooks = nil
+	p.mu.Unlock()
+	keys := make([]string, 0, len(pending))
+	for key := range pending {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	var errs []error
+	for _, key := range keys {
+		h := pending[key]
+		logger := h.logger()
+		if p.dryRun {
+			logger.Info("Dry run: hook would be run")
+			continue
+		}
+		if err := p.runHook(ctx, h); err != nil {
+			if h.IgnoreFailure {
+				logger.Warn("Hook failed, ignoring", "error", err)
+				continue
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Runs the hook command with a timeout, or sends the hook signal; command output is sent to stderr so that it does not
+// interfere with any output written to stdout.
+func (p *processor) runHook(ctx context.Context, h *hook) error {
+	if h.Signal != "" {
+		return signalHook(h)
+	}
+	timeout := p.hookTimeout
+	if h.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(h.Timeout); err != nil {
+			return fmt.Errorf("invalid hook timeout %q: %w", h.Timeout, ErrHookFailed)
+		}
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	slog.Info("Running hook", "command", h.Command, "timeout", timeout)
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...) //nolint:gosec // Executing a user provided command is the purpose of hooks
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("hook %q: %w: %w", h.Command, err, ErrHookFailed)
+	}
+	return nil
+}
+
+// Sends the hook signal to the process whose id is read from the hook pid file.
+func signalHook(h *hook) error {
+	sig, err := parseSignal(h.Signal)
+	if err != nil {
+		return fmt.Errorf("%w: %w", err, ErrHookFailed)
+	}
+	data, err := os.ReadFile(h.PidFile)
+	if err != nil {
+		return fmt.Errorf("failed to read pid file: %w: %w", err, ErrHookFailed)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return fmt.Errorf("pid file %s does not contain a process id: %w", h.PidFile, ErrHookFailed)
+	}
+	slog.Info("Signaling process", "signal", h.Signal, "pid", pid)
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return fmt.Errorf("failed to find process %d: %w: %w", pid, err, ErrHookFailed)
+	}
+	if err := process.Signal(sig); err != nil {
+		return fmt.Errorf("failed to send %s to process %d: %w: %w", h.Signal, pid, err, ErrHookFailed)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package unseal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Verify that hooks are run once per command after files change, and that failures are handled as configured.
+func TestRunHooks(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("Hook commands in this test require a POSIX shell")
+	}
+	tests := []struct {
+		name          string
+		hooks         []*hook
+		dryRun        bool
+		expectedRuns  int
+		expectedError error
+	}{
+		{
+			name:         "deduplicated",
+			hooks:        []*hook{{Command: []string{"sh", "-c", "echo run >> $0", "{{log}}"}}, {Command: []string{"sh", "-c", "echo run >> $0", "{{log}}"}}},
+			expectedRuns: 1,
+		},
+		{
+			name:          "failure",
+			hooks:         []*hook{{Command: []string{"sh", "-c", "echo run >> $0; exit 1", "{{log}}"}}},
+			expectedRuns:  1,
+			expectedError: ErrHookFailed,
+		},
+		{
+			name:         "ignored-failure",
+			hooks:        []*hook{{Command: []string{"sh", "-c", "echo run >> $0; exit 1", "{{log}}"}, IgnoreFailure: true}},
+			expectedRuns: 1,
+		},
+		{
+			name:          "timeout",
+			hooks:         []*hook{{Command: []string{"sh", "-c", "echo run >> $0; exec sleep 5", "{{log}}"}, Timeout: "100ms"}},
+			expectedRuns:  1,
+			expectedError: ErrHookFailed,
+		},
+		{
+			name:   "dry-run",
+			hooks:  []*hook{{Command: []string{"sh", "-c", "echo run >> $0", "{{log}}"}}},
+			dryRun: true,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			log := filepath.Join(t.TempDir(), "hook.log")
+			p := &processor{dryRun: tst.dryRun, hookTimeout: DefaultHookTimeout}
+			for _, h := range tst.hooks {
+				for i, arg := range h.Command {
+					h.Command[i] = strings.ReplaceAll(arg, "{{log}}", log)
+				}
+				p.queueHook(h)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			err := p.runHooks(ctx)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("runHooks raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected runHooks to raise %v, got %v", tst.expectedError, err)
+			}
+			data, _ := os.ReadFile(log)
+			if runs := strings.Count(string(data), "run"); runs != tst.expectedRuns {
+				t.Errorf("Expected %d hook runs, got %d", tst.expectedRuns, runs)
+			}
+			if len(p.pendingHooks) != 0 {
+				t.Errorf("Expected pending hooks to be cleared")
+			}
+		})
+	}
+}
+
+// Verify that processing queues hooks only for entries whose content changed.
+func TestProcessSources_Hooks(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("Hook commands in this test require a POSIX shell")
+	}
+	tmpDir := t.TempDir()
+	log := filepath.Join(tmpDir, "hook.log")
+	target := filepath.Join(tmpDir, "target")
+	source := filepath.Join(tmpDir, "spec.json")
+	// spell-checker: disable-next-line
+	spec := `{"` + target + `": {"data": "ZnZ6Y3lyLndmYmE=", "onChange": {"command": ["sh", "-c", "echo run >> ` + log + `"]}}}`
+	if err := os.WriteFile(source, []byte(spec), 0o600); err != nil {
+		t.Fatalf("Failed to write specification: %v", err)
+	}
+	p := testProcessor(t)
+	p.hookTimeout = DefaultHookTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	for range 2 {
+		if err := p.processSources(ctx, []string{source}, nil); err != nil {
+			t.Fatalf("processSources raised an unexpected error: %v", err)
+		}
+	}
+	data, _ := os.ReadFile(log)
+	if runs := strings.Count(string(data), "run"); runs != 1 {
+		t.Errorf("Expected hook to run once, got %d", runs)
+	}
+}
+
+// Verify that hooks must declare either a command, or a signal and pid file.
+func TestHookValidate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		hook          hook
+		expectedError error
+	}{
+		{
+			name: "command",
+			hook: hook{Command: []string{"true"}},
+		},
+		{
+			name:          "empty",
+			hook:          hook{},
+			expectedError: ErrInvalidEntry,
+		},
+		{
+			name:          "command-and-signal",
+			hook:          hook{Command: []string{"true"}, Signal: "SIGHUP", PidFile: "/run/app.pid"},
+			expectedError: ErrInvalidEntry,
+		},
+		{
+			name:          "signal-without-pid-file",
+			hook:          hook{Signal: "SIGHUP"},
+			expectedError: ErrInvalidEntry,
+		},
+		{
+			name:          "pid-file-without-signal",
+			hook:          hook{PidFile: "/run/app.pid"},
+			expectedError: ErrInvalidEntry,
+		},
+		{
+			name:          "unsupported-signal",
+			hook:          hook{Signal: "SIGKILL", PidFile: "/run/app.pid"},
+			expectedError: ErrInvalidEntry,
+		},
+		{
+			name:          "invalid-timeout",
+			hook:          hook{Command: []string{"true"}, Timeout: "soon"},
+			expectedError: ErrInvalidEntry,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			err := tst.hook.validate()
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("validate raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected validate to raise %v, got %v", tst.expectedError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+//go:build !windows
+
+package unseal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Verify that signal hooks deliver the signal to the process identified by the pid file. The signal is delivered to
+// the test process, so SIGUSR1 must not be used by any other test.
+func TestRunHooks_Signal(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	pidFile := filepath.Join(tmpDir, "app.pid")
+	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write pid file: %v", err)
+	}
+	invalidPidFile := filepath.Join(tmpDir, "invalid.pid")
+	if err := os.WriteFile(invalidPidFile, []byte("app"), 0o600); err != nil {
+		t.Fatalf("Failed to write pid file: %v", err)
+	}
+	tests := []struct {
+		name          string
+		hook          hook
+		expectSignal  bool
+		expectedError error
+	}{
+		{
+			name:         "signaled",
+			hook:         hook{Signal: "SIGUSR1", PidFile: pidFile},
+			expectSignal: true,
+		},
+		{
+			name:          "missing-pid-file",
+			hook:          hook{Signal: "USR1", PidFile: filepath.Join(tmpDir, "missing.pid")},
+			expectedError: ErrHookFailed,
+		},
+		{
+			name:          "invalid-pid-file",
+			hook:          hook{Signal: "USR1", PidFile: invalidPidFile},
+			expectedError: ErrHookFailed,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			signals := make(chan os.Signal, 1)
+			if tst.expectSignal {
+				signal.Notify(signals, syscall.SIGUSR1)
+				defer signal.Stop(signals)
+			}
+			if err := tst.hook.validate(); err != nil {
+				t.Fatalf("validate raised an unexpected error: %v", err)
+			}
+			p := &processor{hookTimeout: DefaultHookTimeout}
+			p.queueHook(&tst.hook)
+			err := p.runHooks(context.Background())
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Fatalf("runHooks raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Fatalf("Expected runHooks to raise %v, got %v", tst.expectedError, err)
+			case !tst.expectSignal:
+				return
+			}
+			select {
+			case sig := <-signals:
+				if sig != syscall.SIGUSR1 {
+					t.Errorf("Expected SIGUSR1, got %v", sig)
+				}
+			case <-time.After(3 * time.Second):
+				t.Errorf("Timed out waiting for signal")
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+package unseal
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// The directory where Kubernetes mounts the service account token and cluster CA certificate in a pod.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+var (
+	// ErrInvalidSecretTarget is returned when a Kubernetes Secret target, label, or owner reference cannot be parsed.
+	ErrInvalidSecretTarget = errors.New("invalid kubernetes secret target")
+	// ErrKubernetesAPI is returned when the Kubernetes API server rejects a request.
+	ErrKubernetesAPI = errors.New("kubernetes API request failed")
+	// ErrNotInCluster is returned when the in-cluster Kubernetes API server cannot be located.
+	ErrNotInCluster = errors.New("unable to locate the in-cluster kubernetes API server")
+)
+
+// Matches a valid Kubernetes Secret data key.
+var secretKeyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// Identifies the Kubernetes Secret that will receive the unsealed entries, with the labels and owner references that
+// will be applied to it. Implements flag.Value to parse a namespace/name target.
+type secretTarget struct {
+	Namespace string
+	Name      string
+	Labels    secretLabels
+	OwnerRefs ownerReferences
+}
+
+// Implements flag.Value.
+func (t *secretTarget) String() string {
+	if t == nil || t.Name == "" {
+		return ""
+	}
+	return t.Namespace + "/" + t.Name
+}
+
+// Implements flag.Value; the value must be of the form namespace/name.
+func (t *secretTarget) Set(value string) error {
+	namespace, name, ok := strings.Cut(value, "/")
+	if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("expected namespace/name, got %q: %w", value, ErrInvalidSecretTarget)
+	}
+	t.Namespace = namespace
+	t.Name = name
+	return nil
+}
+
+// A set of labels to apply to the Secret. Implements flag.Value to accept repeated key=value pairs.
+type secretLabels map[string]string
+
+// Implements flag.Value.
+func (l *secretLabels) String() string {
+	if l == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(*l))
+	for _, key := range slices.Sorted(maps.Keys(*l)) {
+		pairs = append(pairs, key+"="+(*l)[key])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Implements flag.Value; the value must be of the form key=value.
+func (l *secretLabels) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value label, got %q: %w", value, ErrInvalidSecretTarget)
+	}
+	if *l == nil {
+		*l = secretLabels{}
+	}
+	(*l)[key] = val
+	return nil
+}
+
+// Describes an owner of the Secret, so that it is garbage collected with the owning object.
+type ownerReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	UID        string `json:"uid"`
+	// Preserved from existing owner references.
+	Controller         *bool `json:"controller,omitempty"`
+	BlockOwnerDeletion *bool `json:"blockOwnerDeletion,omitempty"`
+}
+
+// A list of owner references to apply to the Secret. Implements flag.Value to accept repeated values.
+type ownerReferences []ownerReference
+
+// Implements flag.Value.
+func (o *ownerReferences) String() string {
+	if o == nil {
+		return ""
+	}
+	refs := make([]string, 0, len(*o))
+	for _, ref := range *o {
+		refs = append(refs, strings.Join([]string{ref.APIVersion, ref.Kind, ref.Name, ref.UID}, "/"))
+	}
+	return strings.Join(refs, ",")
+}
+
+// Implements flag.Value; the value must be of the form apiVersion/kind/name/uid, where apiVersion may include a group,
+// e.g. apps/v1/Deployment/app/0e4c9a9e-5a5b-4c1e-9a8f-2f1f3b3c4d5e.
+func (o *ownerReferences) Set(value string) error {
+	parts := strings.Split(value, "/")
+	n := len(parts)
+	if n < 4 || n > 5 || slices.Contains(parts, "") {
+		return fmt.Errorf("expected apiVersion/kind/name/uid owner reference, got %q: %w", value, ErrInvalidSecretTarget)
+	}
+	*o = append(*o, ownerReference{
+		APIVersion: strings.Join(parts[:n-3], "/"),
+		Kind:       parts[n-3],
+		Name:       parts[n-2],
+		UID:        parts[n-1],
+	})
+	return nil
+}
+
+// The subset of a Kubernetes Secret object that unseal reads and creates; an existing Secret is updated with a merge
+// patch of the managed fields, so fields that are not listed here are preserved.
+type k8sSecret struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   k8sObjectMeta     `json:"metadata"`
+	Type       string            `json:"type,omitempty"`
+	Data       map[string][]byte `json:"data"`
+}
+
+// The subset of Kubernetes object metadata that unseal manages.
+type k8sObjectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	Finalizers      []string          `json:"finalizers,omitempty"`
+	OwnerReferences []ownerReference  `json:"ownerReferences,omitempty"`
+}
+
+// A minimal client for the Kubernetes API server that authenticates with the pod's service account.
+type k8sClient struct {
+	client *http.Client
+	// The base URL of the API server.
+	host string
+	// The service account token file; it is re-read for every request as Kubernetes rotates projected tokens.
+	tokenFile string
+}
+
+// Returns a Kubernetes API client for the cluster that the pod is running in, using the service account token and
+// cluster CA certificate mounted into the pod.
+func newInClusterClient(getenv func(string) string) (*k8sClient, error) {
+	host, port := getenv("KUBERNETES_SERVICE_HOST"), getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set: %w", ErrNotInCluster)
+	}
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster CA certificate: %w: %w", err, ErrNotInCluster)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("cluster CA certificate is invalid: %w", ErrNotInCluster)
+	}
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		transport = &http.Transport{}
+	}
+	transport = transport.Clone()
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    pool,
+	}
+	return &k8sClient{
+		client:    &http.Client{Transport: transport},
+		host:      "https://" + net.JoinHostPort(host, port),
+		tokenFile: filepath.Join(serviceAccountDir, "token"),
+	}, nil
+}
+
+// Sends a request to the API server, decoding a JSON response into out if it is not nil. Returns the HTTP status code
+// so that callers can handle expected failures, such as a missing object.
+func (c *k8sClient) do(ctx context.Context, method, path string, in, out any) (int, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.host+path, body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w: %w", err, ErrKubernetesAPI)
+	}
+	token, err := os.ReadFile(c.tokenFile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+string(bytes.TrimSpace(token)))
+	req.Header.Set("Accept", "application/json")
+	switch {
+	case in != nil && method == http.MethodPatch:
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+	case in != nil:
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w: %w", err, ErrKubernetesAPI)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, nil
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode response: %w: %w", err, ErrKubernetesAPI)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// Returns the API path of the Secret, or the collection of Secrets in the namespace if name is empty.
+func secretPath(namespace, name string) string {
+	path := "/api/v1/namespaces/" + url.PathEscape(namespace) + "/secrets"
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	return path
+}
+
+// Records the unsealed value of an entry for the Secret. The data key is the entry's env name, if set, or the base
+// name of the entry path.
+func (p *processor) setSecretData(path string, e *entry, value []byte) error {
+	key := e.Env
+	if key == "" {
+		key = filepath.Base(path)
+	}
+	if !secretKeyPattern.MatchString(key) {
+		return fmt.Errorf("entry %s has an invalid secret key %q: %w", path, key, ErrInvalidEntry)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.secretData == nil {
+		p.secretData = map[string][]byte{}
+	}
+	p.secretData[key] = value
+	return nil
+}
+
+// Creates or updates the Kubernetes Secret with the unsealed entries, if a target has been configured. The Secret is
+// only updated if the data, labels, or owner references differ, and a change will trigger the global hook. In dry-run
+// mode the Secret is read but not modified.
+func (p *processor) writeSecret(ctx context.Context) error {
+	if p.secret == nil {
+		return nil
+	}
+	p.mu.Lock()
+	data := maps.Clone(p.secretData)
+	p.mu.Unlock()
+	if data == nil {
+		data = map[string][]byte{}
+	}
+	logger := slog.With("secret", p.secret.String())
+	existing := &k8sSecret{}
+	status, err := p.k8s.do(ctx, http.MethodGet, secretPath(p.secret.Namespace, p.secret.Name), nil, existing)
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to get secret %s: %w", p.secret, err)
+	case status == http.StatusNotFound:
+		existing = nil
+	case status != http.StatusOK:
+		return fmt.Errorf("failed to get secret %s: unexpected HTTP status code %d: %w", p.secret, status, ErrKubernetesAPI)
+	}
+	secret, changed := p.secret.apply(existing, data)
+	if !changed {
+		logger.Debug("Secret is unchanged, skipping update")
+		p.summary.wrote(p.secret.String(), false)
+		return nil
+	}
+	if p.dryRun {
+		logger.Info("Dry run: secret would be written", "keys", slices.Sorted(maps.Keys(data)))
+		p.summary.wrote(p.secret.String(), true)
+		return nil
+	}
+	var body any = secret
+	method, path, expected := http.MethodPost, secretPath(p.secret.Namespace, ""), http.StatusCreated
+	if existing != nil {
+		body, method, path, expected = p.secret.patch(existing, secret), http.MethodPatch, secretPath(p.secret.Namespace, p.secret.Name), http.StatusOK
+	}
+	logger.Debug("Writing secret", "method", method)
+	status, err = p.k8s.do(ctx, method, path, body, nil)
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to write secret %s: %w", p.secret, err)
+	case status != expected:
+		return fmt.Errorf("failed to write secret %s: unexpected HTTP status code %d: %w", p.secret, status, ErrKubernetesAPI)
+	}
+	p.summary.wrote(p.secret.String(), true)
+	p.queueHook(p.onChange)
+	return nil
+}
+
+// Returns the Secret that should be written to the API server, based on the existing Secret if not nil, and true if it
+// differs from the existing Secret. Existing labels and owner references are preserved.
+func (t *secretTarget) apply(existing *k8sSecret, data map[string][]byte) (*k8sSecret, bool) {
+	if existing == nil {
+		return &k8sSecret{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Metadata: k8sObjectMeta{
+				Name:            t.Name,
+				Namespace:       t.Namespace,
+				Labels:          maps.Clone(t.Labels),
+				OwnerReferences: slices.Clone(t.OwnerRefs),
+			},
+			Type: "Opaque",
+			Data: data,
+		}, true
+	}
+	secret := *existing
+	secret.APIVersion = "v1"
+	secret.Kind = "Secret"
+	changed := !maps.EqualFunc(existing.Data, data, bytes.Equal)
+	secret.Data = data
+	secret.Metadata.Labels = maps.Clone(existing.Metadata.Labels)
+	for key, value := range t.Labels {
+		if current, ok := secret.Metadata.Labels[key]; !ok || current != value {
+			if secret.Metadata.Labels == nil {
+				secret.Metadata.Labels = map[string]string{}
+			}
+			secret.Metadata.Labels[key] = value
+			changed = true
+		}
+	}
+	secret.Metadata.OwnerReferences = slices.Clone(existing.Metadata.OwnerReferences)
+	for _, ref := range t.OwnerRefs {
+		if !slices.ContainsFunc(secret.Metadata.OwnerReferences, func(current ownerReference) bool {
+			return current.UID == ref.UID
+		}) {
+			secret.Metadata.OwnerReferences = append(secret.Metadata.OwnerReferences, ref)
+			changed = true
+		}
+	}
+	return &secret, changed
+}
+
+// Returns a JSON merge patch that changes the existing Secret to the data, labels, and owner references of the secret,
+// without touching fields that unseal does not manage. Only the labels of the target are sent, data keys that are not
+// in the secret are removed, and the resource version of the existing Secret makes the API server reject the patch if
+// the Secret has been changed since it was read; merge patches replace lists, so every owner reference is sent.
+func (t *secretTarget) patch(existing, secret *k8sSecret) map[string]any {
+	data := map[string]any{}
+	for key := range existing.Data {
+		data[key] = nil
+	}
+	for key, value := range secret.Data {
+		data[key] = value
+	}
+	metadata := map[string]any{}
+	if existing.Metadata.ResourceVersion != "" {
+		metadata["resourceVersion"] = existing.Metadata.ResourceVersion
+	}
+	if len(t.Labels) > 0 {
+		metadata["labels"] = maps.Clone(t.Labels)
+	}
+	if len(t.OwnerRefs) > 0 {
+		metadata["ownerReferences"] = secret.Metadata.OwnerReferences
+	}
+	return map[string]any{
+		"metadata": metadata,
+		"data":     data,
+	}
+}
+
+// Clears the unsealed values recorded for the Secret before a new run.
+func (p *processor) resetSecretData() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.secretData = nil
+}
@@ -0,0 +1,223 @@
+package unseal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Implements a fake Kubernetes API server that stores a single Secret in memory as untyped JSON, so that fields unseal
+// does not model are kept, and counts the writes. Updates must be merge patches with the current resource version.
+type testSecretServer struct {
+	mu      sync.Mutex
+	secret  map[string]any
+	writes  int
+	version int
+}
+
+func (s *testSecretServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/namespaces/test/secrets/creds":
+		if s.secret == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(s.secret)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/namespaces/test/secrets" && s.secret == nil:
+		secret := map[string]any{}
+		if err := json.NewDecoder(r.Body).Decode(&secret); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.secret = secret
+		s.written()
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPatch && r.URL.Path == "/api/v1/namespaces/test/secrets/creds" && s.secret != nil:
+		patch := map[string]any{}
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || r.Header.Get("Content-Type") != "application/merge-patch+json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if metadata, _ := patch["metadata"].(map[string]any); metadata["resourceVersion"] != s.metadata()["resourceVersion"] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		testMergePatch(s.secret, patch)
+		s.written()
+	default:
+		w.WriteHeader(http.StatusConflict)
+	}
+}
+
+// Returns the metadata of the stored Secret.
+func (s *testSecretServer) metadata() map[string]any {
+	metadata, _ := s.secret["metadata"].(map[string]any)
+	return metadata
+}
+
+// Counts a write and sets a new resource version on the stored Secret.
+func (s *testSecretServer) written() {
+	s.writes++
+	s.version++
+	s.metadata()["resourceVersion"] = strconv.Itoa(s.version)
+}
+
+// Returns the stored Secret decoded as the subset of fields that unseal models.
+func (s *testSecretServer) typed(t *testing.T) *k8sSecret {
+	t.Helper()
+	data, err := json.Marshal(s.secret)
+	if err != nil {
+		t.Fatalf("Failed to marshal secret: %v", err)
+	}
+	secret := &k8sSecret{}
+	if err := json.Unmarshal(data, secret); err != nil {
+		t.Fatalf("Failed to unmarshal secret: %v", err)
+	}
+	return secret
+}
+
+// Applies a JSON merge patch to the target, as described in RFC 7386.
+func testMergePatch(target, patch map[string]any) {
+	for key, value := range patch {
+		switch value := value.(type) {
+		case nil:
+			delete(target, key)
+		case map[string]any:
+			child, ok := target[key].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				target[key] = child
+			}
+			testMergePatch(child, value)
+		default:
+			target[key] = value
+		}
+	}
+}
+
+// Verify that the secret target, label, and owner reference flags are parsed as expected.
+func TestSecretTargetFlags(t *testing.T) {
+	t.Parallel()
+	target := secretTarget{}
+	if err := target.Set("test/creds"); err != nil {
+		t.Errorf("Set raised an unexpected error: %v", err)
+	}
+	for _, value := range []string{"creds", "test/", "/creds", "test/creds/extra"} {
+		if err := (&secretTarget{}).Set(value); !errors.Is(err, ErrInvalidSecretTarget) {
+			t.Errorf("Expected Set(%q) to raise %v, got %v", value, ErrInvalidSecretTarget, err)
+		}
+	}
+	if err := target.Labels.Set("app=test"); err != nil {
+		t.Errorf("Set raised an unexpected error: %v", err)
+	}
+	if err := target.Labels.Set("app"); !errors.Is(err, ErrInvalidSecretTarget) {
+		t.Errorf("Expected Set to raise %v, got %v", ErrInvalidSecretTarget, err)
+	}
+	for _, value := range []string{"apps/v1/Deployment/app/uid-1", "v1/ConfigMap/config/uid-2"} {
+		if err := target.OwnerRefs.Set(value); err != nil {
+			t.Errorf("Set raised an unexpected error: %v", err)
+		}
+	}
+	for _, value := range []string{"v1/ConfigMap/config", "v1//config/uid"} {
+		if err := target.OwnerRefs.Set(value); !errors.Is(err, ErrInvalidSecretTarget) {
+			t.Errorf("Expected Set(%q) to raise %v, got %v", value, ErrInvalidSecretTarget, err)
+		}
+	}
+	switch {
+	case target.String() != "test/creds":
+		t.Errorf("Unexpected target %q", target.String())
+	case target.Labels.String() != "app=test":
+		t.Errorf("Unexpected labels %q", target.Labels.String())
+	case target.OwnerRefs.String() != "apps/v1/Deployment/app/uid-1,v1/ConfigMap/config/uid-2":
+		t.Errorf("Unexpected owner references %q", target.OwnerRefs.String())
+	}
+}
+
+// Verify that processing creates the Secret, leaves it untouched when unchanged, and updates it when the labels or
+// owner references change without removing existing ones.
+func TestProcessSources_Secret(t *testing.T) {
+	t.Parallel()
+	api := &testSecretServer{}
+	server := httptest.NewTLSServer(api)
+	t.Cleanup(server.Close)
+	tmpDir := t.TempDir()
+	tokenFile := filepath.Join(tmpDir, "token")
+	if err := os.WriteFile(tokenFile, []byte("test-token\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write token: %v", err)
+	}
+	source := filepath.Join(tmpDir, "spec.json")
+	// spell-checker: disable-next-line
+	spec := `{"/etc/app/simple.json": "ZnZ6Y3lyLndmYmE=", "password": {"data": "ZnZ6Y3lyLndmYmE=", "env": "DB_PASSWORD"}}`
+	if err := os.WriteFile(source, []byte(spec), 0o600); err != nil {
+		t.Fatalf("Failed to write specification: %v", err)
+	}
+	p := testProcessor(t)
+	p.k8s = &k8sClient{
+		client:    server.Client(),
+		host:      server.URL,
+		tokenFile: tokenFile,
+	}
+	p.secret = &secretTarget{
+		Namespace: "test",
+		Name:      "creds",
+		Labels:    secretLabels{"app": "test"},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	for range 2 {
+		if err := p.processSources(ctx, []string{source}, nil); err != nil {
+			t.Fatalf("processSources raised an unexpected error: %v", err)
+		}
+	}
+	if api.writes != 1 {
+		t.Errorf("Expected the secret to be written once, got %d", api.writes)
+	}
+	if api.secret == nil {
+		t.Fatal("Expected the secret to be created")
+	}
+	switch secret := api.typed(t); {
+	case len(secret.Data) != 2 || string(secret.Data["simple.json"]) != "simple.json" ||
+		string(secret.Data["DB_PASSWORD"]) != "simple.json":
+		t.Errorf("Unexpected secret data: %v", secret.Data)
+	case secret.Metadata.Labels["app"] != "test":
+		t.Errorf("Unexpected secret labels: %v", secret.Metadata.Labels)
+	}
+	// Fields and values that unseal does not manage, and a data key that is no longer unsealed
+	api.secret["immutable"] = false
+	api.metadata()["uid"] = "secret-uid"
+	api.metadata()["labels"].(map[string]any)["existing"] = "true"
+	api.metadata()["ownerReferences"] = []any{map[string]any{"apiVersion": "v1", "kind": "ConfigMap", "name": "config", "uid": "uid-1"}}
+	api.secret["data"].(map[string]any)["stale"] = "c3RhbGU="
+	if err := p.secret.OwnerRefs.Set("apps/v1/Deployment/app/uid-2"); err != nil {
+		t.Fatalf("Set raised an unexpected error: %v", err)
+	}
+	if err := p.processSources(ctx, []string{source}, nil); err != nil {
+		t.Fatalf("processSources raised an unexpected error: %v", err)
+	}
+	switch secret := api.typed(t); {
+	case api.writes != 2:
+		t.Errorf("Expected the secret to be updated, got %d writes", api.writes)
+	case len(secret.Metadata.OwnerReferences) != 2:
+		t.Errorf("Unexpected owner references: %v", secret.Metadata.OwnerReferences)
+	case secret.Metadata.Labels["existing"] != "true":
+		t.Errorf("Expected existing labels to be preserved: %v", secret.Metadata.Labels)
+	case len(secret.Data) != 2 || secret.Data["stale"] != nil:
+		t.Errorf("Expected data keys that are not unsealed to be removed: %v", secret.Data)
+	case api.secret["immutable"] != false || api.metadata()["uid"] != "secret-uid":
+		t.Errorf("Expected unmanaged fields to be preserved: %v", api.secret)
+	}
+}
@@ -0,0 +1,48 @@
+package unseal
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// The supported log formats.
+const (
+	logFormatJSON = "json"
+	logFormatText = "text"
+)
+
+// The log output value that writes to standard error.
+const logOutputStderr = "stderr"
+
+// Replaces the default logger with one that writes in the format to the output, which is either stderr or the path of a
+// file that will be appended to. JSON logs include the source location, text logs are intended for interactive use and
+// do not. The returned function will close the log file, if any.
+func configureLogging(level slog.Leveler, format, output string) (func(), error) {
+	var w io.Writer = os.Stderr
+	closeFn := func() {}
+	if output != "" && output != logOutputStderr {
+		f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		w = f
+		closeFn = func() {
+			_ = f.Close()
+		}
+	}
+	var handler slog.Handler
+	if format == logFormatText {
+		handler = slog.NewTextHandler(w, &slog.HandlerOptions{
+			Level: level,
+		})
+	} else {
+		handler = slog.NewJSONHandler(w, &slog.HandlerOptions{
+			AddSource: true,
+			Level:     level,
+		})
+	}
+	slog.SetDefault(slog.New(handler))
+	return closeFn, nil
+}
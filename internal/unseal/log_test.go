@@ -0,0 +1,64 @@
+package unseal
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Verify that logs are appended to a file in the requested format.
+//
+//nolint:paralleltest // Changes the default logger
+func TestConfigureLogging(t *testing.T) {
+	original := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(original)
+	})
+	tests := []struct {
+		name   string
+		format string
+		check  func(line string) bool
+	}{
+		{
+			name:   "json",
+			format: logFormatJSON,
+			check: func(line string) bool {
+				var record map[string]any
+				return json.Unmarshal([]byte(line), &record) == nil && record["msg"] == "test message" && record["source"] != nil
+			},
+		},
+		{
+			name:   "text",
+			format: logFormatText,
+			check: func(line string) bool {
+				return strings.Contains(line, `msg="test message"`) && !strings.Contains(line, "source=")
+			},
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "unseal.log")
+			closeLog, err := configureLogging(slog.LevelInfo, tst.format, output)
+			if err != nil {
+				t.Fatalf("configureLogging raised an unexpected error: %v", err)
+			}
+			slog.Debug("filtered message")
+			slog.Info("test message")
+			closeLog()
+			data, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatalf("Failed to read log file: %v", err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			if len(lines) != 1 || !tst.check(lines[0]) {
+				t.Errorf("Unexpected log output: %s", data)
+			}
+		})
+	}
+	if _, err := configureLogging(slog.LevelInfo, logFormatText, filepath.Join(t.TempDir(), "missing", "unseal.log")); err == nil {
+		t.Error("Expected configureLogging to raise an error")
+	}
+}
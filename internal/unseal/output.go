@@ -0,0 +1,72 @@
+package unseal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Returns the names of the exported environment variables in sorted order, and a copy of the values.
+func (p *processor) exportedEnv() ([]string, map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := make([]string, 0, len(p.env))
+	values := make(map[string]string, len(p.env))
+	for name, value := range p.env {
+		names = append(names, name)
+		values[name] = value
+	}
+	slices.Sort(names)
+	return names, values
+}
+
+// Renders the exported environment variables as KEY="value" lines suitable for a dotenv file.
+func (p *processor) renderDotenv() []byte {
+	names, values := p.exportedEnv()
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s=%s\n", name, dotenvQuote(values[name]))
+	}
+	return buf.Bytes()
+}
+
+// Writes the exported environment variables as shell export statements that can be evaluated, e.g.
+// eval "$(unseal --export spec.json)".
+func (p *processor) writeExports(w io.Writer) error {
+	names, values := p.exportedEnv()
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "export %s=%s\n", name, shellQuote(values[name])); err != nil {
+			return fmt.Errorf("failed to write export statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// Writes the exported environment variables to the dotenv file, if one has been configured. The file is written with
+// the same atomic and unchanged content semantics as other entries, and a change will trigger the global hook.
+func (p *processor) writeEnvFile() error {
+	if p.envFile == "" {
+		return nil
+	}
+	written, err := p.write(p.envFile, &entry{}, p.renderDotenv())
+	if err != nil {
+		return fmt.Errorf("failed to write env file: %w", err)
+	}
+	if written {
+		p.queueHook(p.onChange)
+	}
+	return nil
+}
+
+// Returns the value as a double-quoted dotenv string, escaping backslashes, double quotes, dollar signs, and newlines.
+func dotenvQuote(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// Returns the value as a single-quoted POSIX shell string.
+func shellQuote(value string) string {
+	return `'` + strings.ReplaceAll(value, `'`, `'\''`) + `'`
+}
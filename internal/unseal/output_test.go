@@ -0,0 +1,94 @@
+package unseal
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Verify that values are quoted correctly for dotenv files and shell evaluation.
+func TestQuote(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name           string
+		value          string
+		expectedDotenv string
+		expectedShell  string
+	}{
+		{
+			name:           "empty",
+			expectedDotenv: `""`,
+			expectedShell:  `''`,
+		},
+		{
+			name:           "simple",
+			value:          "secret",
+			expectedDotenv: `"secret"`,
+			expectedShell:  `'secret'`,
+		},
+		{
+			name:           "special",
+			value:          "it's a \"$secret\"\\\n",
+			expectedDotenv: `"it's a \"\$secret\"\\\n"`,
+			expectedShell:  `'it'\''s a "$secret"\` + "\n'",
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			if result := dotenvQuote(tst.value); result != tst.expectedDotenv {
+				t.Errorf("Expected dotenvQuote to return %s, got %s", tst.expectedDotenv, result)
+			}
+			if result := shellQuote(tst.value); result != tst.expectedShell {
+				t.Errorf("Expected shellQuote to return %s, got %s", tst.expectedShell, result)
+			}
+		})
+	}
+}
+
+// Verify that env entries are written to the dotenv file and as export statements, and not to their own files.
+func TestEnvOutput(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	source := filepath.Join(tmpDir, "spec.json")
+	// spell-checker: disable
+	spec := []byte(`{
+		"` + tmpDir + `/quoted": {"data": "dmcnZiBuICJmcnBlcmci", "env": "QUOTED"},
+		"` + tmpDir + `/simple": {"data": "ZjNwZTNnJA==", "env": "SIMPLE"}
+	}`)
+	// spell-checker: enable
+	if err := os.WriteFile(source, spec, 0o600); err != nil {
+		t.Fatalf("Failed to write specification: %v", err)
+	}
+	envFile := filepath.Join(tmpDir, "secrets.env")
+	p := testProcessor(t)
+	p.exportEnv = true
+	p.envFile = envFile
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := p.processSources(ctx, []string{source}, nil); err != nil {
+		t.Fatalf("processSources raised an unexpected error: %v", err)
+	}
+	for _, name := range []string{"quoted", "simple"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to not be written, got %v", name, err)
+		}
+	}
+	expectedDotenv := []byte("QUOTED=\"it's a \\\"secret\\\"\"\nSIMPLE=\"s3cr3t\\$\"\n")
+	if result, err := os.ReadFile(envFile); err != nil {
+		t.Errorf("Failed to read env file: %v", err)
+	} else if !bytes.Equal(expectedDotenv, result) {
+		t.Errorf("Expected env file to contain %q, got %q", expectedDotenv, result)
+	}
+	var buf bytes.Buffer
+	if err := p.writeExports(&buf); err != nil {
+		t.Fatalf("writeExports raised an unexpected error: %v", err)
+	}
+	expectedExports := "export QUOTED='it'\\''s a \"secret\"'\nexport SIMPLE='s3cr3t$'\n"
+	if result := buf.String(); result != expectedExports {
+		t.Errorf("Expected exports %q, got %q", expectedExports, result)
+	}
+}
@@ -0,0 +1,54 @@
+package unseal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// Returns the base64 encoded sealed value for raw mode. The source may be "-" to read from stdin, the path to a file
+// containing the sealed value, or the base64 encoded sealed value itself. Surrounding whitespace is removed. A source
+// that cannot be read as a file is returned as inline sealed data, unless the file exists but cannot be read and the
+// source is not valid base64.
+func readRawValue(source string, stdin func() ([]byte, error)) (string, error) {
+	if source == stdinSource {
+		data, err := stdin()
+		if err != nil {
+			return "", fmt.Errorf("failed to read from stdin: %w", err)
+		}
+		return string(bytes.TrimSpace(data)), nil
+	}
+	data, err := os.ReadFile(source)
+	switch {
+	case err == nil:
+		return string(bytes.TrimSpace(data)), nil
+	case errors.Is(err, fs.ErrPermission) && checkBase64(source) != nil:
+		return "", fmt.Errorf("failed to read from file %s: %w", source, err)
+	}
+	// Not a readable file, e.g. the path does not exist or a sealed value is too long to be a file name; treat the value
+	// as inline sealed data
+	return source, nil
+}
+
+// Unseals a single sealed value and writes the plaintext to w without modification.
+func (p *processor) unsealRaw(ctx context.Context, source string, stdin func() ([]byte, error), w io.Writer) error {
+	sealed, err := readRawValue(source, stdin)
+	if err != nil {
+		return err
+	}
+	if err := checkBase64(sealed); err != nil {
+		return err
+	}
+	unsealed, err := p.unsealValue(ctx, sealed)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(unsealed); err != nil {
+		return fmt.Errorf("failed to write unsealed value: %w", err)
+	}
+	return nil
+}
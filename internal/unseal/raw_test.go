@@ -0,0 +1,80 @@
+package unseal
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Verify that raw mode unseals values from files, stdin, and arguments, writing the plaintext unchanged.
+func TestUnsealRaw(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sealedFile := filepath.Join(tmpDir, "db_pass.b64")
+	// spell-checker: disable
+	if err := os.WriteFile(sealedFile, []byte("dWhhZ3JlMg==\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write sealed file: %v", err)
+	}
+	// A sealed value of several KB is longer than the maximum length of a file name
+	longPlaintext := bytes.Repeat([]byte("hunter2 "), 512)
+	longSealed := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("uhagre2 "), 512))
+	tests := []struct {
+		name        string
+		source      string
+		stdin       string
+		expected    []byte
+		expectError bool
+	}{
+		{
+			name:     "file",
+			source:   sealedFile,
+			expected: []byte("hunter2"),
+		},
+		{
+			name:     "stdin",
+			source:   stdinSource,
+			stdin:    " dWhhZ3JlMg==\n",
+			expected: []byte("hunter2"),
+		},
+		{
+			name:     "inline",
+			source:   "dWhhZ3JlMg==",
+			expected: []byte("hunter2"),
+		},
+		{
+			name:     "inline-long",
+			source:   longSealed,
+			expected: longPlaintext,
+		},
+		{
+			name:        "invalid",
+			source:      filepath.Join(tmpDir, "missing.b64"),
+			expectError: true,
+		},
+	}
+	// spell-checker: enable
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			p := testProcessor(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			var buf bytes.Buffer
+			err := p.unsealRaw(ctx, tst.source, func() ([]byte, error) {
+				return []byte(tst.stdin), nil
+			}, &buf)
+			switch {
+			case tst.expectError && err == nil:
+				t.Errorf("Expected unsealRaw to raise an error")
+			case !tst.expectError && err != nil:
+				t.Errorf("unsealRaw raised an unexpected error: %v", err)
+			case !bytes.Equal(tst.expected, buf.Bytes()):
+				t.Errorf("Expected %q, got %q", tst.expected, buf.Bytes())
+			}
+		})
+	}
+}
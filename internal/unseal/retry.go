@@ -0,0 +1,56 @@
+package unseal
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/wingman"
+)
+
+// Returns true if the unseal error is likely to be transient and the request should be retried; i.e. Wingman reports
+// that it is not ready, the connection was refused or reset, the request timed out while the parent context is still
+// active, or the error reports that it is retryable, such as a gateway failure. Policy denials and malformed requests
+// are never retried.
+func isTransient(ctx context.Context, err error) bool {
+	var netErr net.Error
+	switch {
+	case err == nil || ctx.Err() != nil:
+		return false
+	case errors.Is(err, wingman.ErrNotReady),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.As(err, &netErr), f5xc.IsRetryable(err):
+		return true
+	}
+	return false
+}
+
+// Calls fn until it succeeds, returns an error that is not transient, or the retry budget is exhausted. The delay
+// between attempts starts at the processor's retry delay and doubles after each attempt, up to maxRetryDelay.
+func (p *processor) retry(ctx context.Context, fn func(context.Context) error) error {
+	delay := p.retryDelay
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if attempt >= p.retries || !isTransient(ctx, err) {
+			return err
+		}
+		slog.Warn("Transient unseal failure, will retry", "error", err, "attempt", attempt+1, "retries", p.retries, "delay", delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay = min(2*delay, maxRetryDelay)
+	}
+}
@@ -0,0 +1,95 @@
+package unseal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc/wingman"
+)
+
+// Verify that transient unseal failures are retried up to the configured limit, and other failures are not.
+func TestUnsealValue_Retry(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name             string
+		failures         int32
+		failureStatus    int
+		retries          int
+		expectedRequests int32
+		expectedError    error
+	}{
+		{
+			name:             "no-retries",
+			failures:         1,
+			failureStatus:    http.StatusServiceUnavailable,
+			expectedRequests: 1,
+			expectedError:    wingman.ErrNotReady,
+		},
+		{
+			name:             "recovered",
+			failures:         2,
+			failureStatus:    http.StatusServiceUnavailable,
+			retries:          2,
+			expectedRequests: 3,
+		},
+		{
+			name:             "exhausted",
+			failures:         5,
+			failureStatus:    http.StatusServiceUnavailable,
+			retries:          2,
+			expectedRequests: 3,
+			expectedError:    wingman.ErrNotReady,
+		},
+		{
+			name:             "denied",
+			failures:         5,
+			failureStatus:    http.StatusForbidden,
+			retries:          2,
+			expectedRequests: 1,
+			expectedError:    wingman.ErrDeniedByPolicy,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			var requests atomic.Int32
+			handler := testWingmanUnsealHandler(t)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) <= tst.failures {
+					w.WriteHeader(tst.failureStatus)
+					return
+				}
+				handler.ServeHTTP(w, r)
+			}))
+			t.Cleanup(server.Close)
+			client, err := wingman.NewClient(server.URL, wingman.WithHTTPClient(server.Client()))
+			if err != nil {
+				t.Fatalf("NewClient raised an unexpected error: %v", err)
+			}
+			t.Cleanup(func() {
+				_ = client.Close()
+			})
+			p := &processor{client: client, retries: tst.retries, retryDelay: time.Millisecond}
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			// spell-checker: disable-next-line
+			result, err := p.unsealValue(ctx, "ZnZ6Y3lyLndmYmE=")
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("unsealValue raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected unsealValue to raise %v, got %v", tst.expectedError, err)
+			case tst.expectedError == nil && string(result) != "simple.json":
+				t.Errorf("Expected %q, got %q", "simple.json", result)
+			}
+			if count := requests.Load(); count != tst.expectedRequests {
+				t.Errorf("Expected %d requests, got %d", tst.expectedRequests, count)
+			}
+		})
+	}
+}
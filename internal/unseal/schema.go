@@ -0,0 +1,114 @@
+package unseal
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"gopkg.in/yaml.v3"
+)
+
+// The JSON Schema that describes the specification format; it is also useful for editor validation of specifications.
+//
+//go:embed schema.json
+var specSchema []byte
+
+// Returns the compiled specification schema.
+func compileSpecSchema() (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(specSchema))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse embedded schema: %w", err)
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertContent()
+	if err := compiler.AddResource("schema.json", doc); err != nil {
+		return nil, fmt.Errorf("failed to add embedded schema: %w", err)
+	}
+	schema, err := compiler.Compile("schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile embedded schema: %w", err)
+	}
+	return schema, nil
+}
+
+// Validates the specification data against the schema, returning an error that wraps [ErrInvalidEntry] and describes
+// every problem along with the entry it was found in. YAML data is converted to the equivalent JSON before validation.
+func validateSpecSchema(source string, data []byte) error {
+	var doc any
+	if isJSONSpec(source, data) {
+		var err error
+		if doc, err = jsonschema.UnmarshalJSON(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("failed to parse as JSON: %w", err)
+		}
+	} else {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("failed to parse as YAML: %w", err)
+		}
+		// Round trip through JSON so that the document has the same types as a JSON document
+		converted, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("failed to convert YAML to JSON: %w", err)
+		}
+		if doc, err = jsonschema.UnmarshalJSON(bytes.NewReader(converted)); err != nil {
+			return fmt.Errorf("failed to convert YAML to JSON: %w", err)
+		}
+	}
+	if doc == nil {
+		// An empty document is an empty specification
+		return nil
+	}
+	schema, err := compileSpecSchema()
+	if err != nil {
+		return err
+	}
+	var validationErr *jsonschema.ValidationError
+	if err := schema.Validate(doc); errors.As(err, &validationErr) {
+		problems := schemaProblems(validationErr)
+		slices.Sort(problems)
+		return fmt.Errorf("%s: %w", strings.Join(problems, "; "), ErrInvalidEntry)
+	} else if err != nil {
+		return fmt.Errorf("failed to validate specification: %w", err)
+	}
+	return nil
+}
+
+// Returns a description of each of the most specific problems in the validation error, prefixed with the entry and
+// field that has the problem.
+func schemaProblems(err *jsonschema.ValidationError) []string {
+	if len(err.Causes) > 0 {
+		var problems []string
+		for _, cause := range err.Causes {
+			problems = append(problems, schemaProblems(cause)...)
+		}
+		return problems
+	}
+	message := err.Error()
+	if output := err.BasicOutput(); output.Error != nil {
+		message = output.Error.String()
+	}
+	switch len(err.InstanceLocation) {
+	case 0:
+		return []string{message}
+	case 1:
+		return []string{fmt.Sprintf("entry %s: %s", err.InstanceLocation[0], message)}
+	}
+	return []string{fmt.Sprintf("entry %s: %s: %s", err.InstanceLocation[0], strings.Join(err.InstanceLocation[1:], "."), message)}
+}
+
+// Returns an error if an entry that will be written to a file does not have an absolute path. Entries that declare an
+// env name may be exported instead, so their keys are not checked.
+func checkAbsolutePaths(spec map[string]entry) error {
+	var errs []error
+	for path, e := range spec {
+		if e.Env == "" && !filepath.IsAbs(path) {
+			errs = append(errs, fmt.Errorf("entry %s: path must be absolute: %w", path, ErrInvalidEntry))
+		}
+	}
+	return errors.Join(errs...)
+}
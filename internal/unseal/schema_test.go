@@ -0,0 +1,121 @@
+package unseal
+
+import (
+	"errors"
+	"testing"
+)
+
+// spell-checker: disable
+func TestValidateSpecSchema(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		source   string
+		data     string
+		expected error
+	}{
+		{
+			name:   "json",
+			source: "spec.json",
+			data:   `{"/tmp/simple.json": "ZnZ6Y3lyLndmYmE=", "/tmp/attrs.json": {"data": "ZnZ6Y3lyLndmYmE=", "mode": "0600"}}`,
+		},
+		{
+			name:   "yaml",
+			source: "spec.yaml",
+			data:   "/tmp/simple.json:\n  data: ZnZ6Y3lyLndmYmE=\n  mode: 384\n  onChange:\n    command: [\"true\"]\n    timeout: 5s\n",
+		},
+		{
+			name:   "empty",
+			source: "spec.yaml",
+			data:   "",
+		},
+		{
+			name:   "template",
+			source: "spec.json",
+			data:   `{"/tmp/config": {"template": "file:///tmp/config.tmpl", "inputs": {"password": "ZnZ6Y3lyLndmYmE="}}}`,
+		},
+		{
+			name:     "unknown-field",
+			source:   "spec.json",
+			data:     `{"/tmp/simple.json": {"data": "ZnZ6Y3lyLndmYmE=", "mod": "0600"}}`,
+			expected: ErrInvalidEntry,
+		},
+		{
+			name:     "invalid-base64",
+			source:   "spec.json",
+			data:     `{"/tmp/simple.json": "not base64!"}`,
+			expected: ErrInvalidEntry,
+		},
+		{
+			name:     "data-and-template",
+			source:   "spec.json",
+			data:     `{"/tmp/simple.json": {"data": "ZnZ6Y3lyLndmYmE=", "template": "file:///tmp/config.tmpl"}}`,
+			expected: ErrInvalidEntry,
+		},
+		{
+			name:     "inputs-without-template",
+			source:   "spec.json",
+			data:     `{"/tmp/simple.json": {"data": "ZnZ6Y3lyLndmYmE=", "inputs": {"password": "ZnZ6Y3lyLndmYmE="}}}`,
+			expected: ErrInvalidEntry,
+		},
+		{
+			name:     "invalid-mode",
+			source:   "spec.yaml",
+			data:     "/tmp/simple.json:\n  data: ZnZ6Y3lyLndmYmE=\n  mode: \"0800\"\n",
+			expected: ErrInvalidEntry,
+		},
+		{
+			name:     "invalid-hook-timeout",
+			source:   "spec.yaml",
+			data:     "/tmp/simple.json:\n  data: ZnZ6Y3lyLndmYmE=\n  onChange:\n    command: [\"true\"]\n    timeout: soon\n",
+			expected: ErrInvalidEntry,
+		},
+		{
+			name:   "signal-hook",
+			source: "spec.yaml",
+			data:   "/tmp/simple.json:\n  data: ZnZ6Y3lyLndmYmE=\n  onChange:\n    signal: SIGHUP\n    pidFile: /run/app.pid\n",
+		},
+		{
+			name:     "signal-hook-without-pid-file",
+			source:   "spec.yaml",
+			data:     "/tmp/simple.json:\n  data: ZnZ6Y3lyLndmYmE=\n  onChange:\n    signal: SIGHUP\n",
+			expected: ErrInvalidEntry,
+		},
+		{
+			name:     "command-and-signal-hook",
+			source:   "spec.json",
+			data:     `{"/tmp/simple.json": {"data": "ZnZ6Y3lyLndmYmE=", "onChange": {"command": ["true"], "signal": "HUP", "pidFile": "/run/app.pid"}}}`,
+			expected: ErrInvalidEntry,
+		},
+		{
+			name:     "invalid-env",
+			source:   "spec.json",
+			data:     `{"password": {"data": "ZnZ6Y3lyLndmYmE=", "env": "1PASSWORD"}}`,
+			expected: ErrInvalidEntry,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateSpecSchema(test.source, []byte(test.data))
+			switch {
+			case test.expected == nil && err != nil:
+				t.Errorf("validateSpecSchema raised an unexpected error: %v", err)
+			case !errors.Is(err, test.expected):
+				t.Errorf("Expected validateSpecSchema to raise %v, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+// spell-checker: enable
+
+func TestCheckAbsolutePaths(t *testing.T) {
+	t.Parallel()
+	if err := checkAbsolutePaths(map[string]entry{"/tmp/simple.json": {}, "password": {Env: "PASSWORD"}}); err != nil {
+		t.Errorf("checkAbsolutePaths raised an unexpected error: %v", err)
+	}
+	if err := checkAbsolutePaths(map[string]entry{"simple.json": {}}); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("Expected checkAbsolutePaths to raise %v, got %v", ErrInvalidEntry, err)
+	}
+}
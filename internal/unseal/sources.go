@@ -0,0 +1,98 @@
+package unseal
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Returns true if the file name has one of the extensions recognized as a specification when a directory is given as a
+// source.
+func isSpecFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// Returns true if the source contains glob meta characters.
+func isGlob(source string) bool {
+	return strings.ContainsAny(source, `*?[`)
+}
+
+// Expands the sources into an ordered list of specification files; directories are replaced by the .json, .yaml, and
+// .yml files they contain, sorted by name, and glob patterns are replaced by their sorted matches. Standard input and
+// URLs are returned unchanged. Patterns that do not match any files are ignored so that an empty drop-in directory is
+// not an error. Duplicate files are only returned once, at the position of their first occurrence. Sources are
+// expanded on every refresh so that new fragments are picked up in daemon and watch modes.
+func expandSources(sources []string) ([]string, error) {
+	expanded := make([]string, 0, len(sources))
+	seen := map[string]struct{}{}
+	add := func(paths ...string) {
+		for _, path := range paths {
+			if _, ok := seen[path]; ok {
+				continue
+			}
+			seen[path] = struct{}{}
+			expanded = append(expanded, path)
+		}
+	}
+	for _, source := range sources {
+		switch {
+		case source == stdinSource, isURLSource(source):
+			add(source)
+		case isGlob(source):
+			matches, err := filepath.Glob(source)
+			if err != nil {
+				return nil, fmt.Errorf("invalid glob pattern %s: %w", source, err)
+			}
+			if len(matches) == 0 {
+				slog.Debug("Glob pattern did not match any files", "pattern", source)
+			}
+			slices.Sort(matches)
+			add(matches...)
+		default:
+			stat, err := os.Stat(source)
+			if err != nil || !stat.IsDir() {
+				// Let readSpec report any error for a missing file
+				add(source)
+				break
+			}
+			paths, err := dirSpecFiles(source)
+			if err != nil {
+				return nil, err
+			}
+			add(paths...)
+		}
+	}
+	return expanded, nil
+}
+
+// Returns the sorted specification files in the directory; subdirectories are not traversed.
+func dirSpecFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !isSpecFile(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		// Follow symlinks, e.g. Kubernetes ConfigMap projections
+		if stat, err := os.Stat(path); err != nil || stat.IsDir() {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	// ReadDir returns entries sorted by filename
+	return paths, nil
+}
@@ -0,0 +1,84 @@
+package unseal
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// Verify that directories and glob patterns are expanded to sorted specification files.
+func TestExpandSources(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	for _, name := range []string{"20-b.yaml", "10-a.json", "30-c.yml", "notes.txt", ".hidden.json"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("{}"), 0o600); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(tmpDir, "sub.json"), 0o700); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	tests := []struct {
+		name     string
+		sources  []string
+		expected []string
+	}{
+		{
+			name:     "file",
+			sources:  []string{filepath.Join(tmpDir, "notes.txt"), stdinSource},
+			expected: []string{filepath.Join(tmpDir, "notes.txt"), stdinSource},
+		},
+		{
+			name:     "missing-file",
+			sources:  []string{filepath.Join(tmpDir, "missing.json")},
+			expected: []string{filepath.Join(tmpDir, "missing.json")},
+		},
+		{
+			name:    "directory",
+			sources: []string{tmpDir},
+			expected: []string{
+				filepath.Join(tmpDir, "10-a.json"),
+				filepath.Join(tmpDir, "20-b.yaml"),
+				filepath.Join(tmpDir, "30-c.yml"),
+			},
+		},
+		{
+			name:    "glob",
+			sources: []string{filepath.Join(tmpDir, "*.y*ml")},
+			expected: []string{
+				filepath.Join(tmpDir, "20-b.yaml"),
+				filepath.Join(tmpDir, "30-c.yml"),
+			},
+		},
+		{
+			name:     "no-match",
+			sources:  []string{filepath.Join(tmpDir, "*.toml")},
+			expected: []string{},
+		},
+		{
+			name:    "duplicates",
+			sources: []string{filepath.Join(tmpDir, "30-c.yml"), tmpDir},
+			expected: []string{
+				filepath.Join(tmpDir, "30-c.yml"),
+				filepath.Join(tmpDir, "10-a.json"),
+				filepath.Join(tmpDir, "20-b.yaml"),
+			},
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := expandSources(tst.sources)
+			switch {
+			case err != nil:
+				t.Errorf("expandSources raised an unexpected error: %v", err)
+			case !slices.Equal(tst.expected, result):
+				t.Errorf("Expected %v, got %v", tst.expected, result)
+			}
+		})
+	}
+	if _, err := expandSources([]string{"[invalid"}); err == nil {
+		t.Errorf("Expected expandSources to raise an error for an invalid pattern")
+	}
+}
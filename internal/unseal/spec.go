@@ -0,0 +1,247 @@
+package unseal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The default permissions for unsealed files.
+const defaultFileMode = fs.FileMode(0o640)
+
+// The prefix of a sealed value that references a file containing the base64 encoded sealed data.
+const fileRefPrefix = "file://"
+
+// ErrInvalidMode is returned when a file or directory mode in the specification cannot be parsed.
+var ErrInvalidMode = errors.New("invalid file mode")
+
+// ErrInvalidEntry is returned when an entry in the specification has conflicting or missing fields.
+var ErrInvalidEntry = errors.New("invalid specification entry")
+
+// Describes a single file that will be written from sealed data. In the specification an entry can be a plain string
+// of base64 encoded sealed data, or an object with a data field and optional attributes.
+type entry struct {
+	// The base64 encoded sealed data.
+	Data string `json:"data" yaml:"data"`
+	// Optional permissions to apply to the file; default is 0640, unless changed by --file-mode.
+	Mode *fileMode `json:"mode,omitempty" yaml:"mode,omitempty"`
+	// Optional user name or numeric uid that will own the file.
+	Owner string `json:"owner,omitempty" yaml:"owner,omitempty"`
+	// Optional group name or numeric gid that will own the file.
+	Group string `json:"group,omitempty" yaml:"group,omitempty"`
+	// Optional permissions to use when creating missing parent directories.
+	DirMode *fileMode `json:"dirMode,omitempty" yaml:"dirMode,omitempty"`
+	// Optional environment variable name; in exec mode the unsealed value will be exported to the command environment
+	// instead of being written to a file.
+	Env string `json:"env,omitempty" yaml:"env,omitempty"`
+	// Optional path to a Go text/template file that will be rendered with the unsealed inputs to produce the content;
+	// mutually exclusive with data.
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+	// Named base64 encoded sealed inputs that are unsealed and made available to the template, e.g. {{ .password }}.
+	Inputs map[string]string `json:"inputs,omitempty" yaml:"inputs,omitempty"`
+	// Optional command to run after the file has been written with changed content.
+	OnChange *hook `json:"onChange,omitempty" yaml:"onChange,omitempty"`
+}
+
+// Returns an error if the entry fields are inconsistent.
+func (e *entry) validate() error {
+	switch {
+	case e.Template != "" && e.Data != "":
+		return fmt.Errorf("data and template cannot both be set: %w", ErrInvalidEntry)
+	case e.Template == "" && len(e.Inputs) > 0:
+		return fmt.Errorf("inputs require a template: %w", ErrInvalidEntry)
+	case e.OnChange != nil:
+		return e.OnChange.validate()
+	}
+	return nil
+}
+
+// Returns the permissions declared by the entry, or the fallback if the entry does not declare a mode.
+func (e *entry) fileMode(fallback fs.FileMode) fs.FileMode {
+	if e.Mode == nil {
+		return fallback
+	}
+	return fs.FileMode(*e.Mode)
+}
+
+// Implements json.Unmarshaler to accept a plain string or an object.
+func (e *entry) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '"' {
+		return json.Unmarshal(data, &e.Data) //nolint:wrapcheck // Error will be wrapped by caller
+	}
+	type plain entry
+	return json.Unmarshal(data, (*plain)(e)) //nolint:wrapcheck // Error will be wrapped by caller
+}
+
+// Implements yaml.Unmarshaler to accept a plain string or a mapping.
+func (e *entry) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&e.Data) //nolint:wrapcheck // Error will be wrapped by caller
+	}
+	type plain entry
+	return node.Decode((*plain)(e)) //nolint:wrapcheck // Error will be wrapped by caller
+}
+
+// A file permission value that can be expressed in the specification as an octal string (e.g. "0600"), or as a number.
+type fileMode fs.FileMode
+
+// Parse an octal string as permission bits.
+func parseFileMode(value string) (fileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	switch {
+	case err != nil:
+		return 0, fmt.Errorf("failed to parse %q as octal: %w: %w", value, err, ErrInvalidMode)
+	case mode&^uint64(fs.ModePerm) != 0:
+		return 0, fmt.Errorf("mode %q has bits outside of permissions: %w", value, ErrInvalidMode)
+	}
+	return fileMode(mode), nil
+}
+
+// Implements flag.Value.
+func (m *fileMode) String() string {
+	if m == nil {
+		return ""
+	}
+	return fmt.Sprintf("%#o", uint32(*m))
+}
+
+// Implements flag.Value.
+func (m *fileMode) Set(value string) error {
+	mode, err := parseFileMode(value)
+	if err != nil {
+		return err
+	}
+	*m = mode
+	return nil
+}
+
+// Implements json.Unmarshaler.
+func (m *fileMode) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("%w: %w", err, ErrInvalidMode)
+	}
+	switch v := value.(type) {
+	case string:
+		mode, err := parseFileMode(v)
+		if err != nil {
+			return err
+		}
+		*m = mode
+		return nil
+	case float64:
+		if v < 0 || v > float64(fs.ModePerm) || v != float64(int(v)) {
+			return fmt.Errorf("mode %v is not valid: %w", v, ErrInvalidMode)
+		}
+		*m = fileMode(v)
+		return nil
+	}
+	return fmt.Errorf("unexpected mode type %T: %w", value, ErrInvalidMode)
+}
+
+// Implements yaml.Unmarshaler; YAML integers such as 0600 are octal whereas strings are always parsed as octal.
+func (m *fileMode) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("mode must be a scalar: %w", ErrInvalidMode)
+	}
+	if node.Tag == "!!int" {
+		var v int
+		if err := node.Decode(&v); err != nil {
+			return fmt.Errorf("%w: %w", err, ErrInvalidMode)
+		}
+		if v < 0 || v > int(fs.ModePerm) {
+			return fmt.Errorf("mode %d is not valid: %w", v, ErrInvalidMode)
+		}
+		*m = fileMode(v)
+		return nil
+	}
+	mode, err := parseFileMode(node.Value)
+	if err != nil {
+		return err
+	}
+	*m = mode
+	return nil
+}
+
+// Parses the specification data as JSON or YAML, returning a map of file paths to entries. The format is determined
+// from the source extension if it is one of .json, .yaml, or .yml, otherwise the content is examined; data that begins
+// with '{' is treated as JSON and anything else as YAML.
+func parseSpec(source string, data []byte) (map[string]entry, error) {
+	logger := slog.With("source", source)
+	var spec map[string]entry
+	if isJSONSpec(source, data) {
+		logger.Debug("Parsing specification as JSON")
+		if err := json.Unmarshal(data, &spec); err != nil {
+			return nil, fmt.Errorf("failed to parse as JSON: %w", err)
+		}
+		return spec, nil
+	}
+	logger.Debug("Parsing specification as YAML")
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse as YAML: %w", err)
+	}
+	return spec, nil
+}
+
+// Returns true if the specification should be parsed as JSON.
+func isJSONSpec(source string, data []byte) bool {
+	switch strings.ToLower(filepath.Ext(source)) {
+	case ".json":
+		return true
+	case ".yaml", ".yml":
+		return false
+	}
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) == 0 || trimmed[0] == '{'
+}
+
+// Replaces any sealed values, including template inputs, that are file references with the content of the referenced
+// file. References may be absolute, e.g. file:///etc/unseal/db.b64, or relative to the directory containing the
+// specification source, e.g. file://db.b64; relative references in a specification read from stdin or a URL are
+// resolved against the working directory.
+func resolveFileRefs(source string, spec map[string]entry) error {
+	baseDir := ""
+	if source != stdinSource && !isURLSource(source) {
+		baseDir = filepath.Dir(source)
+	}
+	for path, e := range spec {
+		var err error
+		if e.Data, err = readFileRef(baseDir, e.Data); err != nil {
+			return fmt.Errorf("entry %s: %w", path, err)
+		}
+		for name, sealed := range e.Inputs {
+			if e.Inputs[name], err = readFileRef(baseDir, sealed); err != nil {
+				return fmt.Errorf("entry %s input %s: %w", path, name, err)
+			}
+		}
+		spec[path] = e
+	}
+	return nil
+}
+
+// Returns the content of the referenced file, without surrounding whitespace, if the value is a file reference, or the
+// value unchanged otherwise.
+func readFileRef(baseDir, value string) (string, error) {
+	ref, ok := strings.CutPrefix(value, fileRefPrefix)
+	if !ok {
+		return value, nil
+	}
+	if !filepath.IsAbs(ref) {
+		ref = filepath.Join(baseDir, ref)
+	}
+	slog.Debug("Reading sealed data from file reference", "path", ref)
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to read sealed data from %s: %w", ref, err)
+	}
+	return string(bytes.TrimSpace(data)), nil
+}
@@ -0,0 +1,147 @@
+package unseal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Verify that parseSpec handles JSON and YAML specifications.
+func TestParseSpec(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		source      string
+		data        []byte
+		expected    map[string]entry
+		expectError bool
+	}{
+		{
+			name:     "json-extension",
+			source:   "spec.json",
+			data:     []byte(`{"/etc/foo.ini":"c2VhbGVk"}`),
+			expected: map[string]entry{"/etc/foo.ini": {Data: "c2VhbGVk"}},
+		},
+		{
+			name:     "yaml-extension",
+			source:   "spec.yaml",
+			data:     []byte("/etc/foo.ini: c2VhbGVk\n"),
+			expected: map[string]entry{"/etc/foo.ini": {Data: "c2VhbGVk"}},
+		},
+		{
+			name:     "yml-extension-json-content",
+			source:   "spec.yml",
+			data:     []byte(`{"/etc/foo.ini":"c2VhbGVk"}`),
+			expected: map[string]entry{"/etc/foo.ini": {Data: "c2VhbGVk"}},
+		},
+		{
+			name:     "detect-json",
+			source:   "-",
+			data:     []byte("  \n{\"/etc/foo.ini\":\"c2VhbGVk\"}"),
+			expected: map[string]entry{"/etc/foo.ini": {Data: "c2VhbGVk"}},
+		},
+		{
+			name:     "detect-yaml",
+			source:   "-",
+			data:     []byte("---\n/etc/foo.ini: c2VhbGVk\n/var/lib/foo/bar.yaml: YmFy\n"),
+			expected: map[string]entry{"/etc/foo.ini": {Data: "c2VhbGVk"}, "/var/lib/foo/bar.yaml": {Data: "YmFy"}},
+		},
+		{
+			name:   "json-v2",
+			source: "spec.json",
+			data:   []byte(`{"/etc/foo.ini":"c2VhbGVk","/etc/bar.key":{"data":"YmFy","mode":"0600","owner":"nginx","group":"101","dirMode":488}}`),
+			expected: map[string]entry{
+				"/etc/foo.ini": {Data: "c2VhbGVk"},
+				"/etc/bar.key": {Data: "YmFy", Mode: testFileMode(0o600), Owner: "nginx", Group: "101", DirMode: testFileMode(0o750)},
+			},
+		},
+		{
+			name:   "yaml-v2",
+			source: "spec.yaml",
+			data:   []byte("/etc/foo.ini: c2VhbGVk\n/etc/bar.key:\n  data: YmFy\n  mode: 0600\n  dirMode: \"750\"\n"),
+			expected: map[string]entry{
+				"/etc/foo.ini": {Data: "c2VhbGVk"},
+				"/etc/bar.key": {Data: "YmFy", Mode: testFileMode(0o600), DirMode: testFileMode(0o750)},
+			},
+		},
+		{
+			name:        "invalid-mode",
+			source:      "spec.json",
+			data:        []byte(`{"/etc/bar.key":{"data":"YmFy","mode":"0999"}}`),
+			expectError: true,
+		},
+		{
+			name:        "empty",
+			source:      "-",
+			expectError: true,
+		},
+		{
+			name:        "invalid-json",
+			source:      "spec.json",
+			data:        []byte(`/etc/foo.ini: c2VhbGVk`),
+			expectError: true,
+		},
+		{
+			name:        "invalid-yaml",
+			source:      "spec.yaml",
+			data:        []byte("- c2VhbGVk\n"),
+			expectError: true,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := parseSpec(tst.source, tst.data)
+			switch {
+			case tst.expectError && err == nil:
+				t.Errorf("Expected parseSpec to raise an error")
+			case !tst.expectError && err != nil:
+				t.Errorf("parseSpec raised an unexpected error: %v", err)
+			case !reflect.DeepEqual(tst.expected, result):
+				t.Errorf("Expected %v, got %v", tst.expected, result)
+			}
+		})
+	}
+}
+
+// Helper to return a pointer to a fileMode.
+func testFileMode(mode fileMode) *fileMode {
+	return &mode
+}
+
+// Verify that file references in sealed values are replaced by the content of the referenced files.
+func TestResolveFileRefs(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "relative.b64"), []byte("cmVsYXRpdmU=\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write sealed file: %v", err)
+	}
+	absolute := filepath.Join(tmpDir, "absolute.b64")
+	if err := os.WriteFile(absolute, []byte("YWJzb2x1dGU="), 0o600); err != nil {
+		t.Fatalf("Failed to write sealed file: %v", err)
+	}
+	source := filepath.Join(tmpDir, "spec.json")
+	spec := map[string]entry{
+		"inline":   {Data: "aW5saW5l"},
+		"relative": {Data: "file://relative.b64"},
+		"absolute": {Data: "file://" + absolute},
+		"template": {Template: "app.tmpl", Inputs: map[string]string{"input": "file://relative.b64"}},
+	}
+	if err := resolveFileRefs(source, spec); err != nil {
+		t.Fatalf("resolveFileRefs raised an unexpected error: %v", err)
+	}
+	expected := map[string]entry{
+		"inline":   {Data: "aW5saW5l"},
+		"relative": {Data: "cmVsYXRpdmU="},
+		"absolute": {Data: "YWJzb2x1dGU="},
+		"template": {Template: "app.tmpl", Inputs: map[string]string{"input": "cmVsYXRpdmU="}},
+	}
+	if !reflect.DeepEqual(expected, spec) {
+		t.Errorf("Expected %v, got %v", expected, spec)
+	}
+	if err := resolveFileRefs(source, map[string]entry{"missing": {Data: "file://missing.b64"}}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected resolveFileRefs to raise %v, got %v", os.ErrNotExist, err)
+	}
+}
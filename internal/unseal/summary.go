@@ -0,0 +1,102 @@
+package unseal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// Exit codes that distinguish between complete and partial failure when --keep-going is set.
+const (
+	// No entries could be processed, or a fatal error occurred.
+	exitFailure = 1
+	// Some entries were processed successfully, but at least one failed.
+	exitPartialFailure = 2
+)
+
+// Describes an entry, or specification source, that could not be processed.
+type failure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// Records the outcome of processing each entry.
+type summary struct {
+	mu sync.Mutex
+	// Files that were written, or would be written in dry-run mode.
+	Written []string `json:"written"`
+	// Files that were skipped because the content was unchanged.
+	Unchanged []string `json:"unchanged"`
+	// Environment variable names that were exported.
+	Exported []string `json:"exported"`
+	// Entries and sources that failed.
+	Failed []failure `json:"failed"`
+}
+
+// Clears the recorded outcomes before a new run.
+func (s *summary) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Written = []string{}
+	s.Unchanged = []string{}
+	s.Exported = []string{}
+	s.Failed = []failure{}
+}
+
+// Records that the file at path was written, or was unchanged.
+func (s *summary) wrote(path string, written bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if written {
+		s.Written = append(s.Written, path)
+	} else {
+		s.Unchanged = append(s.Unchanged, path)
+	}
+}
+
+// Records that the environment variable was exported.
+func (s *summary) exported(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Exported = append(s.Exported, name)
+}
+
+// Records that the entry or source at path failed with err.
+func (s *summary) fail(path string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Failed = append(s.Failed, failure{Path: path, Error: err.Error()})
+}
+
+// Returns the number of entries that were processed successfully.
+func (s *summary) succeeded() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.Written) + len(s.Unchanged) + len(s.Exported)
+}
+
+// Writes the summary as JSON to w, with each list sorted for stable output.
+func (s *summary) write(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slices.Sort(s.Written)
+	slices.Sort(s.Unchanged)
+	slices.Sort(s.Exported)
+	slices.SortFunc(s.Failed, func(a, b failure) int {
+		if a.Path < b.Path {
+			return -1
+		}
+		if a.Path > b.Path {
+			return 1
+		}
+		return 0
+	})
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(s); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	return nil
+}
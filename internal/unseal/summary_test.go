@@ -0,0 +1,94 @@
+package unseal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Verify that keep-going mode processes every entry and records the outcomes in the summary.
+func TestProcessSources_KeepGoing(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		parallel int
+	}{
+		{
+			name:     "sequential",
+			parallel: 1,
+		},
+		{
+			name:     "parallel",
+			parallel: 4,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			unchanged := filepath.Join(tmpDir, "unchanged")
+			if err := os.WriteFile(unchanged, []byte("simple.json"), 0o600); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			source := filepath.Join(tmpDir, "spec.json")
+			// spell-checker: disable
+			spec := `{
+				"` + filepath.Join(tmpDir, "a") + `": "ZnZ6Y3lyLndmYmE=",
+				"` + filepath.Join(tmpDir, "b") + `": "ZnZ6Y3lyLndmYmE=",
+				"` + unchanged + `": "ZnZ6Y3lyLndmYmE=",
+				"` + filepath.Join(tmpDir, "invalid") + `": {"template": "` + filepath.Join(tmpDir, "missing.tmpl") + `"},
+				"ENV": {"data": "ZnZ6Y3lyLndmYmE=", "env": "ENV"}
+			}`
+			// spell-checker: enable
+			if err := os.WriteFile(source, []byte(spec), 0o600); err != nil {
+				t.Fatalf("Failed to write specification: %v", err)
+			}
+			missing := filepath.Join(tmpDir, "missing.json")
+			p := testProcessor(t)
+			p.keepGoing = true
+			p.exportEnv = true
+			p.parallel = tst.parallel
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			err := p.processSources(ctx, []string{missing, source}, nil)
+			if !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("Expected processSources to raise %v, got %v", os.ErrNotExist, err)
+			}
+			var buf bytes.Buffer
+			if err := p.summary.write(&buf); err != nil {
+				t.Fatalf("Failed to write summary: %v", err)
+			}
+			var result struct {
+				Written   []string  `json:"written"`
+				Unchanged []string  `json:"unchanged"`
+				Exported  []string  `json:"exported"`
+				Failed    []failure `json:"failed"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+				t.Fatalf("Failed to parse summary %q: %v", buf.String(), err)
+			}
+			failed := make([]string, 0, len(result.Failed))
+			for _, f := range result.Failed {
+				failed = append(failed, f.Path)
+			}
+			switch {
+			case !slices.Equal(result.Written, []string{filepath.Join(tmpDir, "a"), filepath.Join(tmpDir, "b")}):
+				t.Errorf("Unexpected written files: %v", result.Written)
+			case !slices.Equal(result.Unchanged, []string{unchanged}):
+				t.Errorf("Unexpected unchanged files: %v", result.Unchanged)
+			case !slices.Equal(result.Exported, []string{"ENV"}):
+				t.Errorf("Unexpected exported variables: %v", result.Exported)
+			case !slices.Equal(failed, []string{filepath.Join(tmpDir, "invalid"), missing}):
+				t.Errorf("Unexpected failures: %v", result.Failed)
+			case p.summary.succeeded() != 4:
+				t.Errorf("Expected 4 successful entries, got %d", p.summary.succeeded())
+			}
+		})
+	}
+}
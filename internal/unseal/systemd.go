@@ -0,0 +1,63 @@
+package unseal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/memes/f5xc/systemdcreds"
+)
+
+// The directory of a systemd credential store that will receive the unsealed entries; an empty value disables systemd
+// credentials mode. Implements flag.Value as a boolean flag so that --systemd-creds uses the default store, while
+// --systemd-creds=DIR uses another directory.
+type credentialStore string
+
+// Implements flag.Value.
+func (c *credentialStore) String() string {
+	if c == nil {
+		return ""
+	}
+	return string(*c)
+}
+
+// Implements flag.Value; true and false enable and disable systemd credentials mode with the default store, any other
+// value must be an absolute directory path.
+func (c *credentialStore) Set(value string) error {
+	if enabled, err := strconv.ParseBool(value); err == nil {
+		*c = ""
+		if enabled {
+			*c = systemdcreds.DefaultStore
+		}
+		return nil
+	}
+	if !filepath.IsAbs(value) {
+		return fmt.Errorf("credential store %q must be an absolute path: %w", value, ErrInvalidEntry)
+	}
+	*c = credentialStore(filepath.Clean(value))
+	return nil
+}
+
+// Allows the flag to be given without a value.
+func (c *credentialStore) IsBoolFlag() bool {
+	return true
+}
+
+// Returns a copy of the specification with each entry keyed by the path of a credential in the store. The credential
+// is named by the base name of the entry key, which must be a valid systemd credential name, and each name may only be
+// used once.
+func credentialSpec(store string, spec map[string]entry) (map[string]entry, error) {
+	result := make(map[string]entry, len(spec))
+	for key, e := range spec {
+		path, err := systemdcreds.Path(store, filepath.Base(key))
+		if err != nil {
+			return nil, fmt.Errorf("entry %s: %w: %w", key, err, ErrInvalidEntry)
+		}
+		if _, ok := result[path]; ok {
+			return nil, fmt.Errorf("entry %s: credential %s is declared more than once: %w", key, filepath.Base(path),
+				ErrInvalidEntry)
+		}
+		result[path] = e
+	}
+	return result, nil
+}
@@ -0,0 +1,129 @@
+package unseal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// Verify that the systemd credentials flag accepts booleans and absolute directories.
+func TestCredentialStoreFlag(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		value         string
+		expected      string
+		expectedError error
+	}{
+		{value: "true", expected: "/run/credstore"},
+		{value: "false"},
+		{value: "/etc/credstore/", expected: "/etc/credstore"},
+		{value: "credstore", expectedError: ErrInvalidEntry},
+	}
+	for _, tst := range tests {
+		t.Run(tst.value, func(t *testing.T) {
+			t.Parallel()
+			var store credentialStore
+			err := store.Set(tst.value)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("Set raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected Set to raise %v, got %v", tst.expectedError, err)
+			case store.String() != tst.expected:
+				t.Errorf("Expected store %q, got %q", tst.expected, store.String())
+			}
+		})
+	}
+}
+
+// Verify that specification entries are mapped to credentials in the store.
+func TestCredentialSpec(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		spec          map[string]entry
+		expected      []string
+		expectedError error
+	}{
+		{
+			name:     "names",
+			spec:     map[string]entry{"db-password": {}, "/etc/app/tls.key": {}},
+			expected: []string{"/run/credstore/db-password", "/run/credstore/tls.key"},
+		},
+		{
+			name:          "duplicate",
+			spec:          map[string]entry{"/etc/a/tls.key": {}, "/etc/b/tls.key": {}},
+			expectedError: ErrInvalidEntry,
+		},
+		{
+			name:          "invalid",
+			spec:          map[string]entry{"..": {}},
+			expectedError: ErrInvalidEntry,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			spec, err := credentialSpec("/run/credstore", tst.spec)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Fatalf("credentialSpec raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Fatalf("Expected credentialSpec to raise %v, got %v", tst.expectedError, err)
+			}
+			for _, path := range tst.expected {
+				if _, ok := spec[path]; !ok {
+					t.Errorf("Expected credential %s in %v", path, spec)
+				}
+			}
+		})
+	}
+}
+
+// Verify that entries are written to the credential store with owner read-only permissions.
+func TestProcessSources_SystemdCreds(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	store := filepath.Join(tmpDir, "credstore")
+	source := filepath.Join(tmpDir, "spec.json")
+	// spell-checker: disable-next-line
+	spec := `{"db-password": "ZnZ6Y3lyLndmYmE=", "/etc/app/tls.key": {"data": "ZnZ6Y3lyLndmYmE=", "mode": "0644"}}`
+	if err := os.WriteFile(source, []byte(spec), 0o600); err != nil {
+		t.Fatalf("Failed to write specification: %v", err)
+	}
+	p, err := newProcessor(testProcessor(t).client, &options{
+		dirMode:   fileMode(defaultDirMode),
+		fileMode:  fileMode(defaultFileMode),
+		parallel:  1,
+		credStore: credentialStore(store),
+	})
+	if err != nil {
+		t.Fatalf("newProcessor raised an unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := p.processSources(ctx, []string{source}, nil); err != nil {
+		t.Fatalf("processSources raised an unexpected error: %v", err)
+	}
+	for _, name := range []string{"db-password", "tls.key"} {
+		path := filepath.Join(store, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Errorf("Failed to stat credential %s: %v", name, err)
+			continue
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm() != 0o400 {
+			t.Errorf("Expected credential %s to have mode 0400, got %s", name, info.Mode().Perm())
+		}
+		if data, _ := os.ReadFile(path); string(data) != "simple.json" {
+			t.Errorf("Expected credential %s to contain simple.json, got %q", name, data)
+		}
+	}
+	if info, err := os.Stat(store); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0o700 {
+		t.Errorf("Expected credential store to have mode 0700, got %s", info.Mode().Perm())
+	}
+}
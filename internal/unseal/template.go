@@ -0,0 +1,182 @@
+package unseal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// The name of the template function that unseals the base64 encoded sealed data in a file, e.g.
+// {{ blindfold "db.b64" }}.
+const blindfoldFunc = "blindfold"
+
+// ErrInvalidTemplate is returned when a command line template declaration cannot be parsed.
+var ErrInvalidTemplate = errors.New("invalid template declaration")
+
+// Returns the unsealed content for the entry; either the unsealed data or, if the entry has a template, the result of
+// rendering the template with the unsealed inputs.
+func (p *processor) unseal(ctx context.Context, e *entry) ([]byte, error) {
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+	if e.Template == "" {
+		return p.unsealValue(ctx, e.Data)
+	}
+	inputs := make(map[string]string, len(e.Inputs))
+	for name, sealed := range e.Inputs {
+		slog.Debug("Unsealing template input", "template", e.Template, "input", name)
+		unsealed, err := p.unsealValue(ctx, sealed)
+		if err != nil {
+			return nil, fmt.Errorf("input %s: %w", name, err)
+		}
+		inputs[name] = string(unsealed)
+	}
+	return renderTemplate(e.Template, inputs, p.templateFuncs(ctx, e.Template))
+}
+
+// Returns the functions available to a template. The blindfold function reads base64 encoded sealed data from a file,
+// resolved against the directory containing the template unless the path is absolute, and returns the unsealed value;
+// each file is unsealed at most once per render.
+func (p *processor) templateFuncs(ctx context.Context, templatePath string) template.FuncMap {
+	baseDir := filepath.Dir(templatePath)
+	unsealed := map[string]string{}
+	return template.FuncMap{
+		blindfoldFunc: func(path string) (string, error) {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(baseDir, path)
+			}
+			if value, ok := unsealed[path]; ok {
+				return value, nil
+			}
+			slog.Debug("Unsealing template file reference", "template", templatePath, "path", path)
+			sealed, err := os.ReadFile(path)
+			if err != nil {
+				return "", fmt.Errorf("failed to read sealed data: %w", err)
+			}
+			value, err := p.unsealValue(ctx, string(bytes.TrimSpace(sealed)))
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", path, err)
+			}
+			unsealed[path] = string(value)
+			return unsealed[path], nil
+		},
+	}
+}
+
+// Template functions that can be used to parse, but not render, a template.
+func parseOnlyFuncs() template.FuncMap {
+	return template.FuncMap{
+		blindfoldFunc: func(string) (string, error) {
+			return "", nil
+		},
+	}
+}
+
+// Unseals a single base64 encoded sealed value, applying the processor timeout to each attempt and retrying transient
+// failures as configured.
+func (p *processor) unsealValue(ctx context.Context, sealed string) ([]byte, error) {
+	var unsealed []byte
+	err := p.retry(ctx, func(ctx context.Context) error {
+		if p.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, p.timeout)
+			defer cancel()
+		}
+		var err error
+		unsealed, err = p.client.UnsealEncoded(ctx, []byte(sealed))
+		return err //nolint:wrapcheck // Error will be wrapped below
+	})
+	if err != nil {
+		p.metrics.unsealFailures.Add(1)
+		return nil, fmt.Errorf("wingman unseal error: %w", err)
+	}
+	p.metrics.unseals.Add(1)
+	return unsealed, nil
+}
+
+// Parses the template file and executes it with the unsealed inputs as data. Referencing an input that was not declared
+// in the specification is an error.
+func renderTemplate(path string, inputs map[string]string, funcs template.FuncMap) ([]byte, error) {
+	tmpl, err := parseTemplate(path, funcs)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, inputs); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Reads and parses the template file with the functions.
+func parseTemplate(path string, funcs template.FuncMap) (*template.Template, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Funcs(funcs).Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Templates declared on the command line in the consul-template form SRC:DEST[:COMMAND], keyed by the absolute path
+// of the rendered file. Each becomes a template entry without inputs, whose optional command is run with the system
+// shell after the rendered file changes.
+type templateSpecs map[string]entry
+
+// Implements flag.Value.
+func (t *templateSpecs) String() string {
+	if t == nil {
+		return ""
+	}
+	decls := make([]string, 0, len(*t))
+	for dest, e := range *t {
+		decls = append(decls, e.Template+":"+dest)
+	}
+	return strings.Join(decls, ",")
+}
+
+// Implements flag.Value.
+func (t *templateSpecs) Set(value string) error {
+	parts := strings.SplitN(value, ":", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("%q must be in the form SRC:DEST[:COMMAND]: %w", value, ErrInvalidTemplate)
+	}
+	src, err := filepath.Abs(parts[0])
+	if err != nil {
+		return fmt.Errorf("failed to resolve template %s: %w", parts[0], err)
+	}
+	dest, err := filepath.Abs(parts[1])
+	if err != nil {
+		return fmt.Errorf("failed to resolve destination %s: %w", parts[1], err)
+	}
+	if *t == nil {
+		*t = templateSpecs{}
+	}
+	if _, ok := (*t)[dest]; ok {
+		return fmt.Errorf("destination %s is declared more than once: %w", dest, ErrInvalidTemplate)
+	}
+	e := entry{Template: src}
+	if len(parts) == 3 && parts[2] != "" {
+		e.OnChange = &hook{Command: shellCommand(parts[2])}
+	}
+	(*t)[dest] = e
+	return nil
+}
+
+// Returns the paths of the template files, so that they can be watched for changes.
+func (t templateSpecs) sources() []string {
+	sources := make([]string, 0, len(t))
+	for _, e := range t {
+		sources = append(sources, e.Template)
+	}
+	return sources
+}
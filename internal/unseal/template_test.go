@@ -0,0 +1,231 @@
+package unseal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Verify that template entries render the unsealed inputs.
+func TestUnsealTemplate(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	tmpl := filepath.Join(tmpDir, "app.yaml.tmpl")
+	if err := os.WriteFile(tmpl, []byte("database: postgres://app:{{ .password }}@db:5432/app\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	blindfoldTmpl := filepath.Join(tmpDir, "blindfold.tmpl")
+	if err := os.WriteFile(blindfoldTmpl, []byte(`{{ blindfold "db.b64" }}/{{ blindfold "db.b64" }}`), 0o600); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	missingTmpl := filepath.Join(tmpDir, "missing-ref.tmpl")
+	if err := os.WriteFile(missingTmpl, []byte(`{{ blindfold "missing.b64" }}`), 0o600); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	// spell-checker: disable-next-line
+	if err := os.WriteFile(filepath.Join(tmpDir, "db.b64"), []byte("dWhhZ3JlMg==\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write sealed data: %v", err)
+	}
+	tests := []struct {
+		name          string
+		entry         entry
+		expected      []byte
+		expectError   bool
+		expectedError error
+	}{
+		// spell-checker: disable
+		{
+			name: "rendered",
+			entry: entry{
+				Template: tmpl,
+				Inputs:   map[string]string{"password": "dWhhZ3JlMg=="},
+			},
+			expected: []byte("database: postgres://app:hunter2@db:5432/app\n"),
+		},
+		{
+			name: "missing-input",
+			entry: entry{
+				Template: tmpl,
+				Inputs:   map[string]string{"username": "dWhhZ3JlMg=="},
+			},
+			expectError: true,
+		},
+		{
+			name:     "blindfold-func",
+			entry:    entry{Template: blindfoldTmpl},
+			expected: []byte("hunter2/hunter2"),
+		},
+		{
+			name:          "blindfold-func-missing-file",
+			entry:         entry{Template: missingTmpl},
+			expectedError: os.ErrNotExist,
+		},
+		{
+			name: "missing-template",
+			entry: entry{
+				Template: filepath.Join(tmpDir, "missing.tmpl"),
+			},
+			expectedError: os.ErrNotExist,
+		},
+		{
+			name: "data-and-template",
+			entry: entry{
+				Data:     "dWhhZ3JlMg==",
+				Template: tmpl,
+			},
+			expectedError: ErrInvalidEntry,
+		},
+		{
+			name: "inputs-without-template",
+			entry: entry{
+				Data:   "dWhhZ3JlMg==",
+				Inputs: map[string]string{"password": "dWhhZ3JlMg=="},
+			},
+			expectedError: ErrInvalidEntry,
+		},
+		// spell-checker: enable
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			p := testProcessor(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			result, err := p.unseal(ctx, &tst.entry)
+			switch {
+			case !tst.expectError && tst.expectedError == nil && err != nil:
+				t.Errorf("unseal raised an unexpected error: %v", err)
+			case tst.expectError && err == nil:
+				t.Errorf("Expected unseal to raise an error")
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected unseal to raise %v, got %v", tst.expectedError, err)
+			case !bytes.Equal(tst.expected, result):
+				t.Errorf("Expected %q, got %q", tst.expected, result)
+			}
+		})
+	}
+}
+
+// Verify that command line template declarations are parsed into template entries.
+func TestTemplateSpecs(t *testing.T) {
+	t.Parallel()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	tests := []struct {
+		name          string
+		values        []string
+		expected      map[string]string
+		expectCommand bool
+		expectedError error
+	}{
+		{
+			name:     "source-dest",
+			values:   []string{"app.tmpl:app.yaml"},
+			expected: map[string]string{filepath.Join(wd, "app.yaml"): filepath.Join(wd, "app.tmpl")},
+		},
+		{
+			name:          "command",
+			values:        []string{"app.tmpl:app.yaml:systemctl reload app"},
+			expected:      map[string]string{filepath.Join(wd, "app.yaml"): filepath.Join(wd, "app.tmpl")},
+			expectCommand: true,
+		},
+		{
+			name:   "repeated",
+			values: []string{"a.tmpl:a", "b.tmpl:b"},
+			expected: map[string]string{
+				filepath.Join(wd, "a"): filepath.Join(wd, "a.tmpl"),
+				filepath.Join(wd, "b"): filepath.Join(wd, "b.tmpl"),
+			},
+		},
+		{
+			name:          "missing-dest",
+			values:        []string{"app.tmpl"},
+			expectedError: ErrInvalidTemplate,
+		},
+		{
+			name:          "empty-source",
+			values:        []string{":app.yaml"},
+			expectedError: ErrInvalidTemplate,
+		},
+		{
+			name:          "duplicate-dest",
+			values:        []string{"a.tmpl:app.yaml", "b.tmpl:app.yaml"},
+			expectedError: ErrInvalidTemplate,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			var specs templateSpecs
+			var err error
+			for _, value := range tst.values {
+				if err = specs.Set(value); err != nil {
+					break
+				}
+			}
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Fatalf("Set raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Fatalf("Expected Set to raise %v, got %v", tst.expectedError, err)
+			case err != nil:
+				return
+			case len(specs) != len(tst.expected):
+				t.Fatalf("Expected %d templates, got %d", len(tst.expected), len(specs))
+			}
+			for dest, src := range tst.expected {
+				e, ok := specs[dest]
+				switch {
+				case !ok:
+					t.Errorf("Expected a template for %s", dest)
+				case e.Template != src:
+					t.Errorf("Expected template %s for %s, got %s", src, dest, e.Template)
+				case tst.expectCommand && e.OnChange == nil:
+					t.Errorf("Expected an onChange hook for %s", dest)
+				case !tst.expectCommand && e.OnChange != nil:
+					t.Errorf("Expected no onChange hook for %s, got %v", dest, e.OnChange.Command)
+				}
+			}
+		})
+	}
+}
+
+// Verify that command line templates are rendered by processSources without any specification sources.
+func TestProcessSources_Templates(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	tmpl := filepath.Join(tmpDir, "app.tmpl")
+	target := filepath.Join(tmpDir, "app.conf")
+	if err := os.WriteFile(tmpl, []byte(`password={{ blindfold "db.b64" }}`), 0o600); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	// spell-checker: disable-next-line
+	if err := os.WriteFile(filepath.Join(tmpDir, "db.b64"), []byte("dWhhZ3JlMg=="), 0o600); err != nil {
+		t.Fatalf("Failed to write sealed data: %v", err)
+	}
+	p := testProcessor(t)
+	if err := p.templates.Set(tmpl + ":" + target); err != nil {
+		t.Fatalf("Set raised an unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := p.processSources(ctx, nil, nil); err != nil {
+		t.Fatalf("processSources raised an unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(target)
+	switch {
+	case err != nil:
+		t.Errorf("Failed to read rendered template: %v", err)
+	case string(data) != "password=hunter2":
+		t.Errorf("Expected rendered template to be %q, got %q", "password=hunter2", data)
+	}
+	if err := p.validateSources(ctx, nil, nil); err != nil {
+		t.Errorf("validateSources raised an unexpected error: %v", err)
+	}
+}
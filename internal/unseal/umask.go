@@ -0,0 +1,34 @@
+package unseal
+
+import (
+	"io/fs"
+)
+
+// An optional umask override. Implements flag.Value, recording whether a value has been set.
+type umaskFlag struct {
+	mode fileMode
+	set  bool
+}
+
+// Implements flag.Value.
+func (u *umaskFlag) String() string {
+	if u == nil || !u.set {
+		return ""
+	}
+	return u.mode.String()
+}
+
+// Implements flag.Value.
+func (u *umaskFlag) Set(value string) error {
+	if err := u.mode.Set(value); err != nil {
+		return err
+	}
+	u.set = true
+	return nil
+}
+
+// Returns the permissions to apply to the file written for the entry; the entry's mode, or the processor default if
+// the entry does not declare one, with the bits in the umask override cleared.
+func (p *processor) fileModeFor(e *entry) fs.FileMode {
+	return e.fileMode(p.fileMode) &^ p.umask
+}
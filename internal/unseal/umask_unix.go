@@ -0,0 +1,16 @@
+//go:build !windows
+
+package unseal
+
+import (
+	"io/fs"
+	"log/slog"
+	"syscall"
+)
+
+// Replaces the process umask, which is applied when parent directories are created and is inherited by hooks and
+// executed commands.
+func setUmask(mask fs.FileMode) {
+	previous := syscall.Umask(int(mask))
+	slog.Debug("Replaced process umask", "umask", mask, "previous", fs.FileMode(previous)) //nolint:gosec // Umask is always a small positive value
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package unseal
+
+import (
+	"io/fs"
+	"log/slog"
+)
+
+// Windows does not have a process umask; the override is only applied to the permissions of written files.
+func setUmask(mask fs.FileMode) {
+	slog.Debug("Process umask is not supported on Windows", "umask", mask)
+}
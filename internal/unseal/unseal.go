@@ -0,0 +1,780 @@
+// Package unseal implements the unseal command, which reads JSON or YAML specifications of blindfold sealed data, has
+// Wingman unseal each value, and writes the unsealed data to files, environment variables, a Kubernetes Secret, or
+// systemd credentials. It is shared by the unseal binary and the unseal subcommand of f5xc; see cmd/unseal for usage.
+package unseal
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/systemdcreds"
+	"github.com/memes/f5xc/wingman"
+)
+
+const (
+	// The environment variable name that can be set to override the default wingman base URL.
+	EnvWingmanURL = envPrefix + "WINGMAN_URL"
+	// The environment variable name that can be set to change the default [log/slog] logging level.
+	EnvLogLevel = envPrefix + "LOG_LEVEL"
+	// The environment variable name that can be set to change the permissions of created parent directories.
+	EnvDirMode = envPrefix + "DIR_MODE"
+	// The environment variable name that can be set to the path of a configuration file.
+	EnvConfig = envPrefix + "CONFIG"
+	// The default interval between refreshes in daemon mode.
+	DefaultInterval = 5 * time.Minute
+	// The default time to wait for specification file changes to settle in watch mode.
+	DefaultDebounce = 1 * time.Second
+	// The default initial delay between retries of a transient unseal failure.
+	DefaultRetryDelay = 1 * time.Second
+	// The default interval between Wingman readiness checks.
+	DefaultReadyInterval = 10 * time.Second
+	// The default time allowed for an in-progress refresh and unseal requests to complete when stopping.
+	DefaultShutdownTimeout = 10 * time.Second
+)
+
+// The exit code returned when Wingman does not report ready before the readiness deadline.
+const exitNotReady = 3
+
+// ErrNoSources is returned when no specification sources are provided.
+var ErrNoSources = errors.New("no specification files provided")
+
+// Defines the command line options for unseal.
+type options struct {
+	config          string
+	wingmanURL      string
+	logLevel        slog.Level
+	logFormat       string
+	logOutput       string
+	timeout         time.Duration
+	dirMode         fileMode
+	fileMode        fileMode
+	umask           umaskFlag
+	backup          backupSuffix
+	daemon          bool
+	interval        time.Duration
+	watch           bool
+	debounce        time.Duration
+	exec            bool
+	command         []string
+	envFile         string
+	export          bool
+	raw             bool
+	validate        bool
+	dryRun          bool
+	verify          bool
+	parallel        int
+	retries         int
+	retryDelay      time.Duration
+	keepGoing       bool
+	onChange        string
+	hookTimeout     time.Duration
+	specToken       string
+	specTokenFile   string
+	noWait          bool
+	readyTimeout    time.Duration
+	readyInterval   time.Duration
+	shutdownTimeout time.Duration
+	secret          secretTarget
+	credStore       credentialStore
+	healthAddress   string
+	templates       templateSpecs
+	version         bool
+	sources         []string
+}
+
+// Parses the command line arguments into options. Flags that are not given on the command line are set from the
+// matching UNSEAL_ environment variable or the configuration file, if either is present.
+func parseArgs(args []string, stdin *os.File, getenv func(string) string) (*options, error) {
+	opts := &options{
+		dirMode:  fileMode(defaultDirMode),
+		fileMode: fileMode(defaultFileMode),
+	}
+	flags := flag.NewFlagSet("unseal", flag.ContinueOnError)
+	flags.StringVar(&opts.config, "config", "", "Read default flag values from this JSON or YAML configuration file")
+	flags.StringVar(&opts.wingmanURL, "wingman-url", wingman.DefaultWingmanURL, "The base URL of the Wingman service; http(s) or grpc(s), or a comma-separated list to try in order")
+	flags.TextVar(&opts.logLevel, "log-level", slog.LevelInfo, "The logging level; one of DEBUG, INFO, WARN, or ERROR")
+	flags.StringVar(&opts.logFormat, "log-format", logFormatJSON, "The logging format; one of json or text")
+	flags.StringVar(&opts.logOutput, "log-output", logOutputStderr, "Write logs to stderr, or append them to this file")
+	flags.IntVar(&opts.parallel, "parallel", 1, "The maximum number of entries to unseal concurrently")
+	flags.IntVar(&opts.retries, "retries", 0, "The number of times to retry an entry after a transient unseal failure")
+	flags.DurationVar(&opts.retryDelay, "retry-delay", DefaultRetryDelay, "The initial delay between retries, doubling after each attempt")
+	flags.BoolVar(&opts.keepGoing, "keep-going", false, "Continue after entry failures and print a JSON summary to standard output")
+	flags.StringVar(&opts.specToken, "spec-token", "", "A bearer token to send when fetching specifications from URLs")
+	flags.StringVar(&opts.specTokenFile, "spec-token-file", "", "A file containing a bearer token to send when fetching specifications from URLs")
+	flags.Var(&opts.templates, "template", "Render the SRC template to DEST, then run the optional COMMAND, given as SRC:DEST[:COMMAND]; may be repeated")
+	flags.StringVar(&opts.onChange, "on-change", "", "A shell command to run after any file has been written with changed content")
+	flags.DurationVar(&opts.hookTimeout, "hook-timeout", DefaultHookTimeout, "The default maximum time a hook command may run")
+	flags.BoolVar(&opts.noWait, "no-wait", false, "Do not wait for Wingman to report ready before unsealing")
+	flags.DurationVar(&opts.readyTimeout, "ready-timeout", 0, "The maximum time to wait for Wingman to report ready; 0 to wait indefinitely")
+	flags.DurationVar(&opts.readyInterval, "ready-interval", DefaultReadyInterval, "The interval between Wingman readiness checks")
+	flags.DurationVar(&opts.timeout, "timeout", 0, "The maximum time to wait for each unseal request; 0 to wait indefinitely")
+	flags.Var(&opts.backup, "backup", "Preserve the previous content of replaced files with this suffix, or .bak if no suffix is given")
+	flags.Var(&opts.dirMode, "dir-mode", "The permissions to use when creating missing parent directories")
+	flags.Var(&opts.fileMode, "file-mode", "The permissions to use for files when an entry does not declare a mode")
+	flags.Var(&opts.umask, "umask", "Replace the process umask, and clear these permission bits from every written file")
+	flags.BoolVar(&opts.daemon, "daemon", false, "Continue running and refresh the unsealed files periodically")
+	flags.DurationVar(&opts.interval, "interval", DefaultInterval, "The interval between refreshes in daemon mode")
+	flags.StringVar(&opts.healthAddress, "health-address", "", "Serve /healthz and /metrics on this address in daemon or watch mode, e.g. :8080")
+	flags.DurationVar(&opts.shutdownTimeout, "shutdown-timeout", DefaultShutdownTimeout, "The time allowed for an in-progress refresh and unseal requests to complete when stopping")
+	flags.BoolVar(&opts.watch, "watch", false, "Continue running and refresh the unsealed files when specification files change")
+	flags.DurationVar(&opts.debounce, "debounce", DefaultDebounce, "The time to wait for specification file changes to settle in watch mode")
+	flags.BoolVar(&opts.exec, "exec", false, "Execute the command following -- after unsealing, exporting env entries to it")
+	flags.StringVar(&opts.envFile, "env-file", "", "Write env entries as KEY=\"value\" lines to this dotenv file")
+	flags.BoolVar(&opts.export, "export", false, "Print env entries as shell export statements to standard output")
+	flags.BoolVar(&opts.raw, "raw", false, "Unseal a single value from a file, standard input, or the argument and write it to standard output")
+	flags.BoolVar(&opts.validate, "validate", false, "Validate the specifications, targets, and Wingman availability without unsealing")
+	flags.BoolVar(&opts.verify, "verify", false, "Unseal the specifications and report files that differ from the unsealed content without writing")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "Unseal the specifications but do not write any files")
+	flags.Var(&opts.secret, "to-k8s-secret", "Write the unsealed entries to this namespace/name Kubernetes Secret instead of files")
+	flags.Var(&opts.secret.Labels, "k8s-secret-label", "A key=value label to apply to the Kubernetes Secret; may be repeated")
+	flags.Var(&opts.secret.OwnerRefs, "k8s-owner-ref", "An apiVersion/kind/name/uid owner reference to apply to the Kubernetes Secret; may be repeated")
+	flags.Var(&opts.credStore, "systemd-creds", "Write the unsealed entries as systemd credentials to /run/credstore, or to this directory")
+	flags.BoolVar(&opts.version, "version", false, "Print the version of unseal and exit")
+	args, opts.command = splitCommand(args)
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("failed to parse arguments: %w", err)
+	}
+	if opts.version {
+		return opts, nil
+	}
+	if opts.config == "" {
+		opts.config = getenv(EnvConfig)
+	}
+	var config map[string]string
+	if opts.config != "" {
+		var err error
+		if config, err = loadConfig(opts.config); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyDefaults(flags, config, getenv); err != nil {
+		return nil, err
+	}
+	opts.sources = flags.Args()
+	if len(opts.templates) == 0 {
+		opts.sources = specSources(opts.sources, stdin)
+	}
+	if err := opts.validateModes(); err != nil {
+		return nil, err
+	}
+	if err := opts.validateOneShot(); err != nil {
+		return nil, err
+	}
+	if err := opts.validateValues(); err != nil {
+		return nil, err
+	}
+	if err := opts.validateSecret(); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// Returns true if a mode that keeps unseal running, or replaces it with a command, has been requested.
+func (o *options) continuous() bool {
+	return o.daemon || o.watch || o.exec
+}
+
+// Returns true if a mode that changes how the results of a single run are reported has been requested.
+func (o *options) reporting() bool {
+	return o.export || o.envFile != "" || o.validate || o.dryRun || o.verify || o.keepGoing
+}
+
+// Returns an error if the requested modes conflict.
+func (o *options) validateModes() error {
+	switch {
+	case len(o.sources) == 0 && len(o.templates) == 0:
+		return ErrNoSources
+	case o.raw && (len(o.sources) != 1 || len(o.templates) > 0 || o.continuous() || o.reporting()):
+		return fmt.Errorf("raw mode requires a single value and cannot be combined with other modes: %w", flag.ErrHelp)
+	case o.exec && len(o.command) == 0:
+		return ErrMissingCommand
+	case !o.exec && len(o.command) > 0:
+		return fmt.Errorf("a command can only be provided in exec mode: %w", flag.ErrHelp)
+	case o.healthAddress != "" && !o.daemon && !o.watch:
+		return fmt.Errorf("a health address can only be provided in daemon or watch mode: %w", flag.ErrHelp)
+	}
+	return nil
+}
+
+// Returns an error if modes that only apply to a single run conflict with each other, or with continuous modes.
+func (o *options) validateOneShot() error {
+	switch {
+	case o.export && o.continuous():
+		return fmt.Errorf("export cannot be combined with daemon, watch, or exec modes: %w", flag.ErrHelp)
+	case (o.validate || o.dryRun || o.verify) && o.continuous():
+		return fmt.Errorf("validate, verify, and dry-run cannot be combined with daemon, watch, or exec modes: %w", flag.ErrHelp)
+	case o.validate && o.export:
+		return fmt.Errorf("validate cannot be combined with export: %w", flag.ErrHelp)
+	case o.verify && (o.validate || o.dryRun || o.export || o.envFile != ""):
+		return fmt.Errorf("verify cannot be combined with validate, dry-run, export, or env-file modes: %w", flag.ErrHelp)
+	case o.keepGoing && (o.exec || o.export || o.validate):
+		return fmt.Errorf("keep-going cannot be combined with exec, export, or validate modes: %w", flag.ErrHelp)
+	}
+	return nil
+}
+
+// Returns an error if a numeric, duration, or enumerated option is out of range.
+func (o *options) validateValues() error {
+	switch {
+	case o.logFormat != logFormatJSON && o.logFormat != logFormatText:
+		return fmt.Errorf("log format must be json or text: %w", flag.ErrHelp)
+	case o.daemon && o.interval <= 0:
+		return fmt.Errorf("interval must be greater than zero: %w", flag.ErrHelp)
+	case o.watch && o.debounce < 0:
+		return fmt.Errorf("debounce must not be negative: %w", flag.ErrHelp)
+	case o.parallel < 1:
+		return fmt.Errorf("parallel must be at least 1: %w", flag.ErrHelp)
+	case o.hookTimeout < 0:
+		return fmt.Errorf("hook timeout must not be negative: %w", flag.ErrHelp)
+	case o.retries < 0:
+		return fmt.Errorf("retries must not be negative: %w", flag.ErrHelp)
+	case o.retries > 0 && o.retryDelay <= 0:
+		return fmt.Errorf("retry delay must be greater than zero: %w", flag.ErrHelp)
+	case o.readyTimeout < 0:
+		return fmt.Errorf("ready timeout must not be negative: %w", flag.ErrHelp)
+	case !o.noWait && o.readyInterval <= 0:
+		return fmt.Errorf("ready interval must be greater than zero: %w", flag.ErrHelp)
+	case o.timeout < 0:
+		return fmt.Errorf("timeout must not be negative: %w", flag.ErrHelp)
+	case o.shutdownTimeout < 0:
+		return fmt.Errorf("shutdown timeout must not be negative: %w", flag.ErrHelp)
+	}
+	return nil
+}
+
+// Returns an error if the Kubernetes Secret or systemd credentials options are inconsistent with each other or the
+// requested modes.
+func (o *options) validateSecret() error {
+	switch {
+	case o.secret.Name == "" && (len(o.secret.Labels) > 0 || len(o.secret.OwnerRefs) > 0):
+		return fmt.Errorf("secret labels and owner references require a kubernetes secret target: %w", flag.ErrHelp)
+	case o.secret.Name != "" && (o.raw || o.exec || o.export || o.envFile != "" || o.validate || o.verify):
+		return fmt.Errorf("a kubernetes secret target cannot be combined with raw, exec, export, env-file, validate, or verify modes: %w", flag.ErrHelp)
+	case o.credStore != "" && (o.raw || o.secret.Name != ""):
+		return fmt.Errorf("systemd credentials cannot be combined with raw mode or a kubernetes secret target: %w", flag.ErrHelp)
+	case len(o.templates) > 0 && (o.secret.Name != "" || o.credStore != ""):
+		return fmt.Errorf("templates cannot be combined with a kubernetes secret target or systemd credentials: %w", flag.ErrHelp)
+	}
+	return nil
+}
+
+// Run executes unseal with the command line arguments, excluding the program name, and returns the exit code.
+func Run(args []string) int {
+	level := slog.LevelVar{}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     &level,
+	})))
+	opts, err := parseArgs(args, os.Stdin, os.Getenv)
+	if err != nil {
+		slog.Error("Invalid arguments", "error", err)
+		return exitFailure
+	}
+	if opts.version {
+		fmt.Fprintln(os.Stdout, "unseal", f5xc.Version())
+		return 0
+	}
+	level.Set(opts.logLevel)
+	if opts.umask.set {
+		setUmask(fs.FileMode(opts.umask.mode))
+	}
+	closeLog, err := configureLogging(&level, opts.logFormat, opts.logOutput)
+	if err != nil {
+		slog.Error("Failed to configure logging", "error", err)
+		return exitFailure
+	}
+	defer closeLog()
+	slog.SetDefault(slog.Default().With("wingmanURL", opts.wingmanURL))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	client, err := newWingmanClient(opts.wingmanURL)
+	if err != nil {
+		slog.Error("Failed to create wingman client", "error", err)
+		return exitFailure
+	}
+	defer shutdownClient(client, opts.shutdownTimeout)
+	p, err := newProcessor(client, opts)
+	if err != nil {
+		slog.Error("Failed to configure processor", "error", err)
+		return exitFailure
+	}
+	// Standard input can only be consumed once, so cache the content for daemon mode.
+	stdin := sync.OnceValues(func() ([]byte, error) {
+		return io.ReadAll(os.Stdin)
+	})
+	if opts.validate {
+		if err := p.validateSources(ctx, opts.sources, stdin); err != nil {
+			slog.Error("Validation failed", "error", err)
+			return exitFailure
+		}
+		slog.Info("Validation succeeded")
+		return 0
+	}
+	if err := waitForReady(ctx, client, opts); err != nil {
+		slog.Error("Wingman failed to reach ready status", "readyTimeout", opts.readyTimeout, "error", err)
+		return exitNotReady
+	}
+	switch {
+	case opts.verify:
+		return p.verifySources(ctx, opts.sources, stdin)
+	case opts.raw:
+		if err := p.unsealRaw(ctx, opts.sources[0], stdin, os.Stdout); err != nil {
+			slog.Error("Failed to unseal raw value", "error", err)
+			return exitFailure
+		}
+		return 0
+	case opts.exec && (opts.daemon || opts.watch):
+		return p.execDaemon(ctx, opts, stdin)
+	case opts.exec:
+		if err := p.processSources(ctx, opts.sources, stdin); err != nil {
+			slog.Error("Processing failed", "error", err)
+			return exitFailure
+		}
+		// Deferred functions will not be called if the process is replaced.
+		stop()
+		shutdownClient(client, opts.shutdownTimeout)
+		retCode, err := execCommand(opts.command, p.environ())
+		if err != nil {
+			slog.Error("Failed to execute command", "error", err)
+		}
+		return retCode
+	case opts.daemon || opts.watch:
+		if err := p.daemon(ctx, opts, stdin, nil); err != nil {
+			slog.Error("Daemon failed", "error", err)
+			return exitFailure
+		}
+		return 0
+	}
+	if err := p.processSources(ctx, opts.sources, stdin); err != nil {
+		slog.Error("Processing failed", "error", err)
+		if !opts.keepGoing {
+			return exitFailure
+		}
+		if err := p.summary.write(os.Stdout); err != nil {
+			slog.Error("Failed to write summary", "error", err)
+		}
+		if p.summary.succeeded() > 0 {
+			return exitPartialFailure
+		}
+		return exitFailure
+	}
+	if opts.keepGoing {
+		if err := p.summary.write(os.Stdout); err != nil {
+			slog.Error("Failed to write summary", "error", err)
+			return exitFailure
+		}
+	}
+	if opts.export {
+		if err := p.writeExports(os.Stdout); err != nil {
+			slog.Error("Failed to write exports", "error", err)
+			return exitFailure
+		}
+	}
+	return 0
+}
+
+// Returns a processor configured from the options that will use the Wingman client.
+func newProcessor(client wingman.Client, opts *options) (*processor, error) {
+	p := &processor{
+		client:        client,
+		dirMode:       fs.FileMode(opts.dirMode),
+		fileMode:      fs.FileMode(opts.fileMode),
+		umask:         fs.FileMode(opts.umask.mode),
+		timeout:       opts.timeout,
+		parallel:      opts.parallel,
+		retries:       opts.retries,
+		retryDelay:    opts.retryDelay,
+		exportEnv:     opts.exec || opts.export || opts.envFile != "",
+		envFile:       opts.envFile,
+		dryRun:        opts.dryRun,
+		keepGoing:     opts.keepGoing || opts.verify,
+		verify:        opts.verify,
+		specToken:     opts.specToken,
+		specTokenFile: opts.specTokenFile,
+		hookTimeout:   opts.hookTimeout,
+		backupSuffix:  string(opts.backup),
+		templates:     opts.templates,
+	}
+	if opts.onChange != "" {
+		p.onChange = &hook{Command: shellCommand(opts.onChange)}
+	}
+	if opts.credStore != "" {
+		// Credentials are read-only and only readable by their owner, whatever the specification declares
+		p.credStore = string(opts.credStore)
+		p.dirMode = systemdcreds.DirMode
+		p.umask |= 0o277
+	}
+	if opts.secret.Name != "" {
+		var err error
+		if p.k8s, err = newInClusterClient(os.Getenv); err != nil {
+			return nil, err
+		}
+		p.secret = &opts.secret
+	}
+	return p, nil
+}
+
+// Waits for the Wingman client to report ready, polling at the ready interval until the ready timeout, if set, has
+// elapsed. Returns immediately if waiting has been disabled.
+func waitForReady(ctx context.Context, client wingman.Client, opts *options) error {
+	if opts.noWait {
+		slog.Debug("Not waiting for wingman to be ready")
+		return nil
+	}
+	if opts.readyTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.readyTimeout)
+		defer cancel()
+	}
+	return wingman.WaitForClientReady(ctx, client, opts.readyInterval) //nolint:wrapcheck // Error is logged by caller
+}
+
+// Waits up to the timeout for unseal requests in progress to complete, then releases the connections of the client.
+func shutdownClient(client wingman.Client, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := client.Shutdown(ctx); err != nil {
+		slog.Warn("Wingman client did not shutdown cleanly", "shutdownTimeout", timeout, "error", err)
+	}
+}
+
+// Returns a context that is not canceled with the parent, but is canceled once the grace period has elapsed after the
+// parent is done, or when the returned cancel function is called. Work started with the context before the parent is
+// done can complete, as long as it does not take longer than the grace period.
+func drainContext(parent context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(parent))
+	stop := context.AfterFunc(parent, func() {
+		timer := time.AfterFunc(grace, cancel)
+		context.AfterFunc(ctx, func() { timer.Stop() })
+	})
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// Processes the specification sources, then starts the command as a child process while continuing to refresh files in
+// daemon or watch mode. Returns the exit code of the command.
+func (p *processor) execDaemon(ctx context.Context, opts *options, stdin func() ([]byte, error)) int {
+	if err := p.processSources(ctx, opts.sources, stdin); err != nil {
+		slog.Error("Processing failed", "error", err)
+		return exitFailure
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	reloaded := make(chan os.Signal, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := p.daemon(ctx, opts, stdin, reloaded); err != nil {
+			slog.Error("Daemon failed", "error", err)
+		}
+	}()
+	retCode, err := runChild(opts.command, p.environ(), reloaded)
+	if err != nil {
+		slog.Error("Failed to execute command", "error", err)
+	}
+	cancel()
+	wg.Wait()
+	return retCode
+}
+
+// Reads, parses, and processes each of the specification sources in order, followed by any templates declared on the
+// command line, stopping at the first error unless the processor is set to keep going. Directories and glob patterns
+// are expanded to the files they contain. If an env file
+// or Kubernetes Secret has been configured it will be written once all sources have been processed.
+func (p *processor) processSources(ctx context.Context, sources []string, stdin func() ([]byte, error)) (err error) {
+	defer func() {
+		p.metrics.recordRun(err)
+	}()
+	p.summary.reset()
+	p.resetSecretData()
+	files, err := expandSources(sources)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, sourceFile := range files {
+		if err := p.processSource(ctx, sourceFile, stdin); err != nil {
+			if !p.keepGoing {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+	if len(p.templates) > 0 {
+		if err := p.process(ctx, p.templates); err != nil {
+			if !p.keepGoing {
+				return fmt.Errorf("error processing templates: %w", err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	if err := p.writeEnvFile(); err != nil {
+		errs = append(errs, err)
+	}
+	// A partially populated secret would remove the keys of failed entries, so it is only written on success
+	if len(errs) == 0 {
+		if err := p.writeSecret(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// Hooks are run even if some entries failed, so that the changed files are picked up
+	if err := p.runHooks(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Reads, parses, and processes a single specification source. Failures to read or parse the source are recorded in the
+// summary against the source name.
+func (p *processor) processSource(ctx context.Context, sourceFile string, stdin func() ([]byte, error)) error {
+	spec, err := p.loadSpec(ctx, sourceFile, stdin)
+	if err != nil {
+		p.summary.fail(sourceFile, err)
+		p.metrics.entryFailures.Add(1)
+		return err
+	}
+	if err := p.process(ctx, spec); err != nil {
+		return fmt.Errorf("error processing specification from %s: %w", sourceFile, err)
+	}
+	return nil
+}
+
+// Reads the specification from a file, standard input, or URL, validates it against the schema, then parses it and
+// resolves any file references. Unless the entries are written to a Kubernetes Secret, target paths must be absolute;
+// in systemd credentials mode each entry is instead keyed by the path of its credential in the store.
+func (p *processor) loadSpec(ctx context.Context, source string, stdin func() ([]byte, error)) (map[string]entry, error) {
+	slog.Debug("Attempting to retrieve specification", "source", source)
+	var data []byte
+	var err error
+	name := source
+	if isURLSource(source) {
+		data, name, err = p.fetchSpec(ctx, source)
+	} else {
+		data, err = readSpec(source, stdin)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading specification from %s: %w", source, err)
+	}
+	if err := validateSpecSchema(name, data); err != nil {
+		return nil, fmt.Errorf("error validating specification from %s: %w", source, err)
+	}
+	spec, err := parseSpec(name, data)
+	switch {
+	case err != nil:
+	case p.credStore != "":
+		// Entries are named credentials rather than paths
+		spec, err = credentialSpec(p.credStore, spec)
+	case p.secret == nil:
+		// Entries become keys of a Kubernetes Secret rather than files
+		err = checkAbsolutePaths(spec)
+	}
+	if err == nil {
+		err = resolveFileRefs(source, spec)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing specification from %s: %w", source, err)
+	}
+	return spec, nil
+}
+
+// The source name that indicates the specification should be read from standard input.
+const stdinSource = "-"
+
+// Returns the list of specification sources to process; if no arguments were provided and stdin is not a terminal
+// the specification will be read from stdin.
+func specSources(args []string, stdin *os.File) []string {
+	if len(args) > 0 {
+		return args
+	}
+	if stat, err := stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice == 0 {
+		slog.Debug("No files provided, reading from piped stdin")
+		return []string{stdinSource}
+	}
+	return nil
+}
+
+// Reads the specification from the source file, or from stdin if the source is "-".
+func readSpec(source string, stdin func() ([]byte, error)) ([]byte, error) {
+	if source == stdinSource {
+		data, err := stdin()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from stdin: %w", err)
+		}
+		return data, nil
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from file %s: %w", source, err)
+	}
+	return data, nil
+}
+
+// Encapsulates the settings used to unseal and write specification entries.
+type processor struct {
+	client wingman.Client
+	// Permissions to use when creating missing parent directories, unless overridden by an entry.
+	dirMode fs.FileMode
+	// Permissions to use for files, unless overridden by an entry.
+	fileMode fs.FileMode
+	// Permission bits that are cleared from every file written.
+	umask fs.FileMode
+	// The maximum time to wait for each unseal request, if greater than zero.
+	timeout time.Duration
+	// If true, entries are unsealed but files are not written.
+	dryRun bool
+	// The maximum number of entries to process concurrently.
+	parallel int
+	// The number of times a transient unseal failure will be retried.
+	retries int
+	// The initial delay between retries.
+	retryDelay time.Duration
+	// If true, entries are unsealed and compared with the existing files instead of being written.
+	verify bool
+	// If true, processing continues after an entry fails.
+	keepGoing bool
+	// An optional bearer token to send when fetching specifications from URLs.
+	specToken string
+	// An optional file containing the bearer token to send when fetching specifications from URLs.
+	specTokenFile string
+	// The outcome of the most recent run.
+	summary summary
+	// If true, entries with an env name will be recorded for export rather than written to a file.
+	exportEnv bool
+	// Optional path of a dotenv file to write exported entries to.
+	envFile string
+	// Guards env and secretData.
+	mu sync.Mutex
+	// Unsealed values to export to the environment of an executed command.
+	env map[string]string
+	// An optional hook to run after any file has been written.
+	onChange *hook
+	// The default maximum time a hook may run.
+	hookTimeout time.Duration
+	// Hooks to run once processing is complete, keyed by command.
+	pendingHooks map[string]*hook
+	// An optional Kubernetes Secret that will receive the unsealed entries instead of files.
+	secret *secretTarget
+	// The client used to write the Kubernetes Secret.
+	k8s *k8sClient
+	// Unsealed values to write to the Kubernetes Secret, keyed by data key.
+	secretData map[string][]byte
+	// If not empty, the directory of the systemd credential store that will receive the unsealed entries.
+	credStore string
+	// If not empty, the suffix of the backup file that preserves the previous content of a replaced file.
+	backupSuffix string
+	// Template entries declared on the command line, keyed by the path of the rendered file.
+	templates templateSpecs
+	// Counters and status reported by the health and metrics endpoints.
+	metrics metrics
+}
+
+// Unseals and writes, or exports, each entry in the specification. Entries are processed sequentially unless the
+// processor allows parallelism, in which case up to that many entries are processed concurrently. Processing stops at
+// the first error, which is returned, unless the processor is set to keep going; in that case every entry is attempted
+// and the errors are joined. The outcome of each entry is recorded in the processor summary.
+func (p *processor) process(ctx context.Context, spec map[string]entry) error {
+	slog.Debug("Processing specification", "parallel", p.parallel, "keepGoing", p.keepGoing)
+	var mu sync.Mutex
+	var errs []error
+	record := func(path string, err error) {
+		p.summary.fail(path, err)
+		p.metrics.entryFailures.Add(1)
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}
+	if p.parallel <= 1 {
+		for path, e := range spec {
+			if err := p.processEntry(ctx, path, &e); err != nil {
+				record(path, err)
+				if !p.keepGoing {
+					return err
+				}
+			}
+		}
+		return errors.Join(errs...)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sem := make(chan struct{}, p.parallel)
+	var wg sync.WaitGroup
+	for path, e := range spec {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := p.processEntry(ctx, path, &e); err != nil {
+				record(path, err)
+				if !p.keepGoing {
+					cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if len(errs) == 0 && ctx.Err() != nil {
+		// The parent context was canceled before all entries were started
+		return fmt.Errorf("processing was interrupted: %w", context.Cause(ctx))
+	}
+	if !p.keepGoing && len(errs) > 0 {
+		// Entries canceled after the first failure are not interesting
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// Unseals a single entry and writes it to path, or records it for export or for the Kubernetes Secret.
+func (p *processor) processEntry(ctx context.Context, path string, e *entry) error {
+	slog.Debug("Processing entry", "path", path, "sealed", e.Data)
+	unsealed, err := p.unseal(ctx, e)
+	if err != nil {
+		return fmt.Errorf("failed to process %s: %w", path, err)
+	}
+	if p.verify {
+		return p.verifyEntry(path, e, unsealed)
+	}
+	if p.secret != nil {
+		slog.Debug("Adding entry to kubernetes secret", "path", path, "secret", p.secret)
+		return p.setSecretData(path, e, unsealed)
+	}
+	if p.exportEnv && e.Env != "" {
+		slog.Debug("Exporting entry to environment", "path", path, "env", e.Env)
+		p.setEnv(e.Env, unsealed)
+		p.summary.exported(e.Env)
+		return nil
+	}
+	written, err := p.write(path, e, unsealed)
+	if err != nil {
+		return err
+	}
+	p.summary.wrote(path, written)
+	if written {
+		p.queueHook(e.OnChange)
+		p.queueHook(p.onChange)
+	}
+	return nil
+}
@@ -0,0 +1,545 @@
+package unseal
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc/wingman"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+// Implements a dummy wingman endpoint for unsealing a blindfolded secret; for the purposes of testing the steps are
+// de-base64 encode => rot13 => base64 encode payload for return. Any request that deviates from expected content
+// structure will return 500 or 400 status. The status endpoint always reports READY.
+func testWingmanUnsealHandler(t *testing.T) http.Handler {
+	t.Helper()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == wingman.StatusEndpoint {
+			_, _ = w.Write([]byte("READY"))
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Logf("unexpected error reading request body: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var payload struct {
+			Location string `json:"location"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Logf("failed to unmarshal request JSON: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		scheme, b64, ok := strings.Cut(payload.Location, ":///")
+		switch {
+		case !ok:
+			t.Logf("payload location did not match expected scheme: %s", payload.Location)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		case scheme != "string":
+			t.Logf("expected payload scheme to be 'string', got %q", scheme)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			t.Logf("failed to decode base64 data: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// ROT13 to decode the sealed data
+		for i, b := range data {
+			switch {
+			case b >= 'A' && b <= 'Z':
+				data[i] = (b-'A'+13)%26 + 'A'
+			case b >= 'a' && b <= 'z':
+				data[i] = (b-'a'+13)%26 + 'a'
+			}
+		}
+		encoder := base64.NewEncoder(base64.StdEncoding, w)
+		if _, err := encoder.Write(data); err != nil {
+			t.Logf("failed to write base64 data to response: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err := encoder.Close(); err != nil {
+			t.Logf("error closing bas64 encoder: %v", err)
+		}
+	})
+}
+
+// Verify that the unexported process function behaves as expected.
+func TestProcess(t *testing.T) {
+	tmpDir := t.TempDir()
+	fmt.Println(tmpDir)
+	tests := []struct {
+		name          string
+		spec          []byte
+		expected      map[string][]byte
+		expectedError any
+	}{
+		// spell-checker: disable
+		{
+			name:          "empty",
+			expectedError: json.SyntaxError{},
+		},
+		{
+			name: "simple",
+			spec: []byte(`{"` + tmpDir + `/simple.json":"ZnZ6Y3lyLndmYmE="}`),
+			expected: map[string][]byte{
+				tmpDir + "/simple.json": []byte("simple.json"),
+			},
+		},
+		{
+			name:          "invalid",
+			spec:          []byte(`{"` + tmpDir + `/invalid.json":"&&&&&&&"}`),
+			expectedError: wingman.ErrUnexpectedHTTPStatus,
+		},
+		// spell-checker: enable
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(testWingmanUnsealHandler(t))
+			t.Cleanup(server.Close)
+			client, err := wingman.NewClient(server.URL, wingman.WithHTTPClient(server.Client()))
+			if err != nil {
+				t.Fatalf("NewClient raised an unexpected error: %v", err)
+			}
+			t.Cleanup(func() {
+				_ = client.Close()
+			})
+			ctx, cancel := context.WithTimeout(context.Background(), 3600*time.Second)
+			defer cancel()
+			spec, err := parseSpec("", tst.spec)
+			if err == nil {
+				p := &processor{client: client, dirMode: defaultDirMode, fileMode: defaultFileMode}
+				err = p.process(ctx, spec)
+			}
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("process raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.As(err, &tst.expectedError):
+				t.Errorf("Expected process to raise %v, got %v", tst.expectedError, err)
+			default:
+				for path, expected := range tst.expected {
+					result, err := os.ReadFile(path)
+					if err != nil {
+						t.Errorf("Failed to open file: %v", err)
+						break
+					}
+					if !bytes.Equal(expected, result) {
+						t.Errorf("Expected file to contain %v, got %v", expected, result)
+					}
+				}
+			}
+		})
+	}
+}
+
+// Verify that process unseals entries concurrently when parallelism is enabled, and stops on failure.
+func TestProcess_Parallel(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	var inFlight, maxInFlight atomic.Int32
+	handler := testWingmanUnsealHandler(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			peak := maxInFlight.Load()
+			if current <= peak || maxInFlight.CompareAndSwap(peak, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	client, err := wingman.NewClient(server.URL, wingman.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatalf("NewClient raised an unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	spec := map[string]entry{}
+	for i := range 8 {
+		// spell-checker: disable-next-line
+		spec[fmt.Sprintf("%s/file-%d", tmpDir, i)] = entry{Data: "ZnZ6Y3lyLndmYmE="}
+	}
+	p := &processor{client: client, dirMode: defaultDirMode, fileMode: defaultFileMode, parallel: 4}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := p.process(ctx, spec); err != nil {
+		t.Fatalf("process raised an unexpected error: %v", err)
+	}
+	for path := range spec {
+		if data, err := os.ReadFile(path); err != nil || string(data) != "simple.json" {
+			t.Errorf("Expected %s to contain %q, got %q: %v", path, "simple.json", data, err)
+		}
+	}
+	if peak := maxInFlight.Load(); peak < 2 || peak > 4 {
+		t.Errorf("Expected between 2 and 4 concurrent requests, got %d", peak)
+	}
+	spec[tmpDir+"/invalid"] = entry{Data: "&&&&&&&"}
+	if err := p.process(ctx, spec); !errors.Is(err, wingman.ErrUnexpectedHTTPStatus) {
+		t.Errorf("Expected process to raise %v, got %v", wingman.ErrUnexpectedHTTPStatus, err)
+	}
+}
+
+// Verify that readSpec reads from files and stdin as expected.
+func TestReadSpec(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	specFile := tmpDir + "/spec.json"
+	if err := os.WriteFile(specFile, []byte(`{"file":"data"}`), 0o600); err != nil {
+		t.Fatalf("Failed to write spec file: %v", err)
+	}
+	tests := []struct {
+		name          string
+		source        string
+		stdin         string
+		expected      []byte
+		expectedError error
+	}{
+		{
+			name:     "file",
+			source:   specFile,
+			stdin:    `{"stdin":"data"}`,
+			expected: []byte(`{"file":"data"}`),
+		},
+		{
+			name:     "stdin",
+			source:   "-",
+			stdin:    `{"stdin":"data"}`,
+			expected: []byte(`{"stdin":"data"}`),
+		},
+		{
+			name:          "missing",
+			source:        tmpDir + "/missing.json",
+			expectedError: os.ErrNotExist,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := readSpec(tst.source, func() ([]byte, error) {
+				return []byte(tst.stdin), nil
+			})
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("readSpec raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected readSpec to raise %v, got %v", tst.expectedError, err)
+			case !bytes.Equal(tst.expected, result):
+				t.Errorf("Expected %q, got %q", tst.expected, result)
+			}
+		})
+	}
+}
+
+// Helper to create a processor that uses a dummy wingman endpoint.
+func testProcessor(t *testing.T) *processor {
+	t.Helper()
+	server := httptest.NewServer(testWingmanUnsealHandler(t))
+	t.Cleanup(server.Close)
+	client, err := wingman.NewClient(server.URL, wingman.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatalf("NewClient raised an unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return &processor{
+		client:   client,
+		dirMode:  defaultDirMode,
+		fileMode: defaultFileMode,
+	}
+}
+
+// Verify that parseArgs handles flags and sources as expected.
+func TestParseArgs(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name             string
+		args             []string
+		expectedDaemon   bool
+		expectedInterval time.Duration
+		expectedSources  []string
+		expectError      bool
+	}{
+		{
+			name:             "sources",
+			args:             []string{"a.json", "b.yaml"},
+			expectedInterval: DefaultInterval,
+			expectedSources:  []string{"a.json", "b.yaml"},
+		},
+		{
+			name:             "version",
+			args:             []string{"--version"},
+			expectedInterval: DefaultInterval,
+		},
+		{
+			name:             "daemon",
+			args:             []string{"--daemon", "--interval=1m", "a.json"},
+			expectedDaemon:   true,
+			expectedInterval: 1 * time.Minute,
+			expectedSources:  []string{"a.json"},
+		},
+		{
+			name:        "invalid-interval",
+			args:        []string{"--daemon", "--interval=0s", "a.json"},
+			expectError: true,
+		},
+		{
+			name:             "exec",
+			args:             []string{"--exec", "a.json", "--", "env"},
+			expectedInterval: DefaultInterval,
+			expectedSources:  []string{"a.json"},
+		},
+		{
+			name:        "exec-without-command",
+			args:        []string{"--exec", "a.json"},
+			expectError: true,
+		},
+		{
+			name:        "command-without-exec",
+			args:        []string{"a.json", "--", "env"},
+			expectError: true,
+		},
+		{
+			name:             "env-file",
+			args:             []string{"--env-file=secrets.env", "--daemon", "a.json"},
+			expectedDaemon:   true,
+			expectedInterval: DefaultInterval,
+			expectedSources:  []string{"a.json"},
+		},
+		{
+			name:        "export-with-daemon",
+			args:        []string{"--export", "--daemon", "a.json"},
+			expectError: true,
+		},
+		{
+			name:             "raw",
+			args:             []string{"--raw", "db_pass.b64"},
+			expectedInterval: DefaultInterval,
+			expectedSources:  []string{"db_pass.b64"},
+		},
+		{
+			name:        "raw-multiple",
+			args:        []string{"--raw", "a.b64", "b.b64"},
+			expectError: true,
+		},
+		{
+			name:             "k8s-secret",
+			args:             []string{"--to-k8s-secret", "test/creds", "--k8s-secret-label", "app=test", "a.json"},
+			expectedInterval: DefaultInterval,
+			expectedSources:  []string{"a.json"},
+		},
+		{
+			name:        "k8s-secret-with-export",
+			args:        []string{"--to-k8s-secret", "test/creds", "--export", "a.json"},
+			expectError: true,
+		},
+		{
+			name:        "k8s-label-without-secret",
+			args:        []string{"--k8s-secret-label", "app=test", "a.json"},
+			expectError: true,
+		},
+		{
+			name:        "health-address-without-daemon",
+			args:        []string{"--health-address", ":8080", "a.json"},
+			expectError: true,
+		},
+		{
+			name:        "invalid-log-format",
+			args:        []string{"--log-format", "xml", "a.json"},
+			expectError: true,
+		},
+		{
+			name:        "verify-with-daemon",
+			args:        []string{"--verify", "--daemon", "a.json"},
+			expectError: true,
+		},
+		{
+			name:        "invalid-umask",
+			args:        []string{"--umask", "0999", "a.json"},
+			expectError: true,
+		},
+		{
+			name:             "systemd-creds",
+			args:             []string{"--systemd-creds", "a.json"},
+			expectedInterval: DefaultInterval,
+			expectedSources:  []string{"a.json"},
+		},
+		{
+			name:        "systemd-creds-relative",
+			args:        []string{"--systemd-creds=credstore", "a.json"},
+			expectError: true,
+		},
+		{
+			name:        "systemd-creds-k8s-secret",
+			args:        []string{"--systemd-creds", "--to-k8s-secret", "test/creds", "a.json"},
+			expectError: true,
+		},
+		{
+			name:             "template",
+			args:             []string{"--daemon", "--template", "app.tmpl:app.yaml:systemctl reload app"},
+			expectedDaemon:   true,
+			expectedInterval: DefaultInterval,
+			expectedSources:  []string{},
+		},
+		{
+			name:        "template-raw",
+			args:        []string{"--raw", "--template", "app.tmpl:app.yaml", "a.b64"},
+			expectError: true,
+		},
+		{
+			name:        "template-k8s-secret",
+			args:        []string{"--to-k8s-secret", "test/creds", "--template", "app.tmpl:app.yaml"},
+			expectError: true,
+		},
+		{
+			name:        "invalid-template",
+			args:        []string{"--template", "app.tmpl"},
+			expectError: true,
+		},
+		{
+			name:        "negative-shutdown-timeout",
+			args:        []string{"--daemon", "--shutdown-timeout=-1s", "a.json"},
+			expectError: true,
+		},
+		{
+			name:        "unknown-flag",
+			args:        []string{"--unknown", "a.json"},
+			expectError: true,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			opts, err := parseArgs(tst.args, nil, func(string) string { return "" })
+			switch {
+			case tst.expectError && err == nil:
+				t.Errorf("Expected parseArgs to raise an error")
+			case !tst.expectError && err != nil:
+				t.Errorf("parseArgs raised an unexpected error: %v", err)
+			case err != nil:
+				return
+			case opts.daemon != tst.expectedDaemon:
+				t.Errorf("Expected daemon to be %t, got %t", tst.expectedDaemon, opts.daemon)
+			case opts.interval != tst.expectedInterval:
+				t.Errorf("Expected interval to be %v, got %v", tst.expectedInterval, opts.interval)
+			case !slices.Equal(opts.sources, tst.expectedSources):
+				t.Errorf("Expected sources to be %v, got %v", tst.expectedSources, opts.sources)
+			}
+		})
+	}
+}
+
+// Verify that waitForReady honors the ready timeout and no-wait options.
+func TestWaitForReady(t *testing.T) {
+	t.Parallel()
+	notReady := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(notReady.Close)
+	ready := httptest.NewServer(testWingmanUnsealHandler(t))
+	t.Cleanup(ready.Close)
+	tests := []struct {
+		name          string
+		endpoint      string
+		opts          options
+		expectedError error
+	}{
+		{
+			name:     "ready",
+			endpoint: ready.URL,
+			opts: options{
+				readyInterval: 10 * time.Millisecond,
+				readyTimeout:  time.Second,
+			},
+		},
+		{
+			name:     "not-ready",
+			endpoint: notReady.URL,
+			opts: options{
+				readyInterval: 10 * time.Millisecond,
+				readyTimeout:  100 * time.Millisecond,
+			},
+			expectedError: wingman.ErrNotReady,
+		},
+		{
+			name:     "no-wait",
+			endpoint: notReady.URL,
+			opts: options{
+				noWait: true,
+			},
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			client, err := wingman.NewClient(tst.endpoint)
+			if err != nil {
+				t.Fatalf("NewClient raised an unexpected error: %v", err)
+			}
+			t.Cleanup(func() {
+				_ = client.Close()
+			})
+			err = waitForReady(context.Background(), client, &tst.opts)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("waitForReady raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected waitForReady to raise %v, got %v", tst.expectedError, err)
+			}
+		})
+	}
+}
+
+// Verify that a drain context outlives its parent for the grace period, and is canceled when the grace period elapses
+// or the returned cancel function is called.
+func TestDrainContext(t *testing.T) {
+	t.Parallel()
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := drainContext(parent, 50*time.Millisecond)
+	defer cancel()
+	cancelParent()
+	if err := ctx.Err(); err != nil {
+		t.Errorf("Expected drain context to remain active during the grace period, got %v", err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Error("Expected drain context to be canceled after the grace period")
+	}
+
+	ctx, cancel = drainContext(context.Background(), time.Hour)
+	cancel()
+	if err := ctx.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected drain context to be canceled, got %v", err)
+	}
+}
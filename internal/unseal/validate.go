@@ -0,0 +1,129 @@
+package unseal
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The maximum time to wait for Wingman to report ready in validate mode.
+const validateReadyTimeout = 10 * time.Second
+
+// ErrNotWritable is returned in validate mode when a target file cannot be written.
+var ErrNotWritable = errors.New("target is not writable")
+
+// Validates every entry in the specification sources without unsealing, and confirms that Wingman is ready. Unlike
+// processing, validation continues after a failure so that all problems are reported together.
+func (p *processor) validateSources(ctx context.Context, sources []string, stdin func() ([]byte, error)) error {
+	var errs []error
+	readyCtx, cancel := context.WithTimeout(ctx, validateReadyTimeout)
+	defer cancel()
+	if err := p.client.Ready(readyCtx); err != nil {
+		errs = append(errs, fmt.Errorf("wingman is not reachable: %w", err))
+	}
+	files, err := expandSources(sources)
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	for _, sourceFile := range files {
+		spec, err := p.loadSpec(ctx, sourceFile, stdin)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for path, e := range spec {
+			if err := p.validateEntry(path, &e); err != nil {
+				errs = append(errs, fmt.Errorf("invalid entry %s in %s: %w", path, sourceFile, err))
+			}
+		}
+	}
+	for path, e := range p.templates {
+		if err := p.validateEntry(path, &e); err != nil {
+			errs = append(errs, fmt.Errorf("invalid template %s: %w", path, err))
+		}
+	}
+	if p.envFile != "" {
+		if err := checkWritable(p.envFile); err != nil {
+			errs = append(errs, fmt.Errorf("env file: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validates an entry without unsealing it; the sealed values must be valid base64, any template must parse, the owner
+// and group must resolve, and the target file must be writable unless the entry will be exported to the environment.
+func (p *processor) validateEntry(path string, e *entry) error {
+	logger := slog.With("path", path)
+	logger.Debug("Validating entry")
+	if err := e.validate(); err != nil {
+		return err
+	}
+	if e.Template == "" {
+		if err := checkBase64(e.Data); err != nil {
+			return err
+		}
+	} else {
+		for name, sealed := range e.Inputs {
+			if err := checkBase64(sealed); err != nil {
+				return fmt.Errorf("input %s: %w", name, err)
+			}
+		}
+		if _, err := parseTemplate(e.Template, parseOnlyFuncs()); err != nil {
+			return err
+		}
+	}
+	if p.exportEnv && e.Env != "" {
+		return nil
+	}
+	if _, _, err := lookupOwnership(e.Owner, e.Group); err != nil {
+		return err
+	}
+	return checkWritable(path)
+}
+
+// Returns an error if the value is empty or is not valid base64.
+func checkBase64(value string) error {
+	if value == "" {
+		return fmt.Errorf("sealed data is empty: %w", ErrInvalidEntry)
+	}
+	if _, err := base64.StdEncoding.DecodeString(value); err != nil {
+		return fmt.Errorf("sealed data is not valid base64: %w: %w", err, ErrInvalidEntry)
+	}
+	return nil
+}
+
+// Verifies that the file at path could be replaced, by creating and removing a temporary file in the nearest existing
+// ancestor directory; this accounts for permissions, ownership, and read-only filesystems.
+func checkWritable(path string) error {
+	dir := filepath.Dir(path)
+	for {
+		stat, err := os.Stat(dir)
+		switch {
+		case err == nil && !stat.IsDir():
+			return fmt.Errorf("%s is not a directory: %w", dir, ErrNotWritable)
+		case err == nil:
+			tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+			if err != nil {
+				return fmt.Errorf("%w: %w", err, ErrNotWritable)
+			}
+			_ = tmp.Close()
+			if err := os.Remove(tmp.Name()); err != nil {
+				return fmt.Errorf("failed to remove temporary file: %w", err)
+			}
+			return nil
+		case !errors.Is(err, fs.ErrNotExist):
+			return fmt.Errorf("%w: %w", err, ErrNotWritable)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return fmt.Errorf("no existing ancestor directory for %s: %w", path, ErrNotWritable)
+		}
+		dir = parent
+	}
+}
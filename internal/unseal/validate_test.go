@@ -0,0 +1,95 @@
+package unseal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Verify that validateSources reports every invalid entry without unsealing or writing files.
+func TestValidateSources(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	blocker := filepath.Join(tmpDir, "blocker")
+	if err := os.WriteFile(blocker, []byte("file"), 0o600); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	writeSpec := func(name, content string) string {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write specification: %v", err)
+		}
+		return path
+	}
+	// spell-checker: disable
+	valid := writeSpec("valid.json", `{
+		"`+filepath.Join(tmpDir, "a", "b", "valid")+`": "ZnZ6Y3lyLndmYmE=",
+		"`+filepath.Join(tmpDir, "env")+`": {"data": "ZnZ6Y3lyLndmYmE=", "env": "VALID"}
+	}`)
+	tests := []struct {
+		name          string
+		sources       []string
+		exportEnv     bool
+		expectedError error
+	}{
+		{
+			name:      "valid",
+			sources:   []string{valid},
+			exportEnv: true,
+		},
+		{
+			name:          "invalid-base64",
+			sources:       []string{writeSpec("base64.json", `{"`+filepath.Join(tmpDir, "base64")+`": "&&&&"}`)},
+			expectedError: ErrInvalidEntry,
+		},
+		{
+			name:          "empty-data",
+			sources:       []string{writeSpec("empty.json", `{"`+filepath.Join(tmpDir, "empty")+`": ""}`)},
+			expectedError: ErrInvalidEntry,
+		},
+		{
+			name:          "not-writable",
+			sources:       []string{writeSpec("blocked.json", `{"`+filepath.Join(blocker, "target")+`": "ZnZ6Y3lyLndmYmE="}`)},
+			expectedError: ErrNotWritable,
+		},
+		{
+			name:          "missing-template",
+			sources:       []string{writeSpec("template.json", `{"`+filepath.Join(tmpDir, "template")+`": {"template": "`+filepath.Join(tmpDir, "missing.tmpl")+`"}}`)},
+			expectedError: os.ErrNotExist,
+		},
+		{
+			name:          "missing-source",
+			sources:       []string{valid, filepath.Join(tmpDir, "missing.json")},
+			exportEnv:     true,
+			expectedError: os.ErrNotExist,
+		},
+	}
+	// spell-checker: enable
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			p := testProcessor(t)
+			p.exportEnv = tst.exportEnv
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			err := p.validateSources(ctx, tst.sources, nil)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("validateSources raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected validateSources to raise %v, got %v", tst.expectedError, err)
+			}
+		})
+	}
+	// Parallel subtests complete before cleanup functions are called.
+	t.Cleanup(func() {
+		for _, name := range []string{"a", "env", "base64"} {
+			if _, err := os.Stat(filepath.Join(tmpDir, name)); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("Expected %s to not exist, got %v", name, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package unseal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"runtime"
+)
+
+// ErrDrift is returned when a file on disk does not match the unsealed content of its entry.
+var ErrDrift = errors.New("file has drifted from the specification")
+
+// Compares the unsealed data with the content and permissions of the file at path, returning an error that wraps
+// [ErrDrift] if they differ. Permissions are not compared on Windows. A matching file is recorded in the summary as
+// unchanged.
+func (p *processor) verifyEntry(path string, e *entry, data []byte) error {
+	logger := slog.With("path", path)
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		logger.Warn("File is missing")
+		return fmt.Errorf("file is missing: %w", ErrDrift)
+	case err != nil:
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	same, err := sameContent(path, data)
+	switch {
+	case err != nil:
+		return err
+	case !same:
+		logger.Warn("File content differs from the unsealed content")
+		return fmt.Errorf("content differs: %w", ErrDrift)
+	case runtime.GOOS != "windows" && info.Mode().Perm() != p.fileModeFor(e).Perm():
+		logger.Warn("File permissions differ", "mode", info.Mode().Perm(), "expected", p.fileModeFor(e))
+		return fmt.Errorf("mode is %s, expected %s: %w", info.Mode().Perm(), p.fileModeFor(e), ErrDrift)
+	}
+	logger.Debug("File matches the unsealed content")
+	p.summary.wrote(path, false)
+	return nil
+}
+
+// Unseals every entry in the specification sources and compares them with the files on disk, printing the summary to
+// standard output. Returns the exit code; 0 if every file matches.
+func (p *processor) verifySources(ctx context.Context, sources []string, stdin func() ([]byte, error)) int {
+	err := p.processSources(ctx, sources, stdin)
+	if writeErr := p.summary.write(os.Stdout); writeErr != nil {
+		slog.Error("Failed to write summary", "error", writeErr)
+		return exitFailure
+	}
+	if err != nil {
+		slog.Error("Verification failed", "error", err)
+		return exitFailure
+	}
+	slog.Info("Verification succeeded")
+	return 0
+}
@@ -0,0 +1,64 @@
+package unseal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// Verify that verify mode reports missing and drifted files without writing them.
+func TestProcessSources_Verify(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	matching := filepath.Join(tmpDir, "matching")
+	drifted := filepath.Join(tmpDir, "drifted")
+	missing := filepath.Join(tmpDir, "missing")
+	wrongMode := filepath.Join(tmpDir, "wrong-mode")
+	for path, content := range map[string]string{
+		matching:  "simple.json",
+		drifted:   "old content",
+		wrongMode: "simple.json",
+	} {
+		if err := os.WriteFile(path, []byte(content), defaultFileMode); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		// Ignore the umask
+		if err := os.Chmod(path, defaultFileMode); err != nil {
+			t.Fatalf("Failed to set mode of %s: %v", path, err)
+		}
+	}
+	source := filepath.Join(tmpDir, "spec.json")
+	// spell-checker: disable
+	spec := `{"` + matching + `": "ZnZ6Y3lyLndmYmE=", "` + drifted + `": "ZnZ6Y3lyLndmYmE=", "` + missing + `": "ZnZ6Y3lyLndmYmE=",
+"` + wrongMode + `": {"data": "ZnZ6Y3lyLndmYmE=", "mode": "0600"}}`
+	// spell-checker: enable
+	if err := os.WriteFile(source, []byte(spec), 0o600); err != nil {
+		t.Fatalf("Failed to write specification: %v", err)
+	}
+	p := testProcessor(t)
+	p.verify = true
+	p.keepGoing = true
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := p.processSources(ctx, []string{source}, nil)
+	if !errors.Is(err, ErrDrift) {
+		t.Errorf("Expected processSources to raise %v, got %v", ErrDrift, err)
+	}
+	expectedFailures := 3
+	if runtime.GOOS == "windows" {
+		expectedFailures = 2
+	}
+	if len(p.summary.Unchanged) != 4-expectedFailures || len(p.summary.Failed) != expectedFailures {
+		t.Errorf("Unexpected summary: unchanged %v, failed %v", p.summary.Unchanged, p.summary.Failed)
+	}
+	if data, _ := os.ReadFile(drifted); string(data) != "old content" {
+		t.Errorf("Expected drifted file to be unchanged, got %q", data)
+	}
+	if _, err := os.Stat(missing); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected missing file to remain missing, got %v", err)
+	}
+}
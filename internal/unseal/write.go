@@ -0,0 +1,182 @@
+package unseal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// The default permissions for parent directories that are created when writing unsealed files.
+const defaultDirMode = fs.FileMode(0o750)
+
+// Writes the unsealed data to path, applying the permissions and ownership declared in the entry. The data is written
+// to a temporary file in the same directory which is renamed over the target once complete, so that readers never
+// observe a partially written file. Missing parent directories will be created with the entry's dirMode, if present,
+// or with the processor's default directory mode.
+//
+// If the file already exists with identical content it will not be rewritten, preserving the modification time, though
+// permissions and ownership will still be applied. If backups are enabled, existing content that is about to be
+// replaced is preserved in a backup file first. The returned boolean will be true only if the file was written. In
+// dry-run mode nothing is written; the outcome is logged, the target is checked for writability, and the returned
+// boolean reports whether the file would have been written.
+func (p *processor) write(path string, e *entry, data []byte) (bool, error) {
+	logger := slog.With("path", path)
+	uid, gid, err := lookupOwnership(e.Owner, e.Group)
+	if err != nil {
+		return false, err
+	}
+	unchanged, err := sameContent(path, data)
+	if err != nil {
+		return false, err
+	}
+	if p.dryRun {
+		if unchanged {
+			logger.Info("Dry run: file content is unchanged")
+			return false, nil
+		}
+		logger.Info("Dry run: file would be written", "bytes", len(data), "mode", p.fileModeFor(e))
+		if err := p.backup(path); err != nil {
+			return false, err
+		}
+		return true, checkWritable(path)
+	}
+	if unchanged {
+		logger.Debug("File content is unchanged, skipping write")
+		return false, applyAttributes(path, p.fileModeFor(e), uid, gid)
+	}
+	dir := filepath.Dir(path)
+	dirMode := p.dirMode
+	if e.DirMode != nil {
+		dirMode = fs.FileMode(*e.DirMode)
+	}
+	logger.Debug("Ensuring parent directories exist", "dirMode", dirMode)
+	if err := os.MkdirAll(dir, dirMode); err != nil {
+		return false, fmt.Errorf("failed to create parent directories: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return false, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	logger = logger.With("tmpPath", tmpPath)
+	logger.Debug("Writing unsealed data to temporary file")
+	if err := writeTempFile(tmp, data, p.fileModeFor(e), uid, gid); err != nil {
+		_ = os.Remove(tmpPath)
+		return false, err
+	}
+	if err := p.backup(path); err != nil {
+		_ = os.Remove(tmpPath)
+		return false, err
+	}
+	logger.Debug("Renaming temporary file to target")
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return false, fmt.Errorf("failed to rename temporary file: %w", err)
+	}
+	p.metrics.recordWrite(len(data))
+	return true, nil
+}
+
+// Returns true if the file at path exists and has a SHA-256 digest identical to that of data.
+func sameContent(path string, data []byte) (bool, error) {
+	f, err := os.Open(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to open existing file: %w", err)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return false, fmt.Errorf("failed to read existing file: %w", err)
+	}
+	expected := sha256.Sum256(data)
+	return bytes.Equal(hash.Sum(nil), expected[:]), nil
+}
+
+// Applies the permissions and ownership to an existing file.
+func applyAttributes(path string, mode fs.FileMode, uid, gid int) error {
+	if err := os.Chmod(path, mode); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if uid == -1 && gid == -1 {
+		return nil
+	}
+	if err := os.Chown(path, uid, gid); err != nil {
+		return fmt.Errorf("failed to change file ownership: %w", err)
+	}
+	return nil
+}
+
+// Writes the data to the open temporary file, sets permissions and ownership, and closes the file.
+func writeTempFile(tmp *os.File, data []byte, mode fs.FileMode, uid, gid int) error {
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if uid != -1 || gid != -1 {
+		slog.Debug("Changing file ownership", "uid", uid, "gid", gid)
+		if err := tmp.Chown(uid, gid); err != nil {
+			_ = tmp.Close()
+			return fmt.Errorf("failed to change file ownership: %w", err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	return nil
+}
+
+// Resolves the owner and group, which may be names or numeric ids, to a uid and gid. An empty owner or group will
+// return -1 so that os.Chown leaves that value unchanged.
+func lookupOwnership(owner, group string) (int, int, error) {
+	uid, gid := -1, -1
+	if owner != "" {
+		id := owner
+		if _, err := strconv.Atoi(owner); err != nil {
+			u, err := user.Lookup(owner)
+			if err != nil {
+				return uid, gid, fmt.Errorf("failed to lookup user %q: %w", owner, err)
+			}
+			id = u.Uid
+		}
+		value, err := strconv.Atoi(id)
+		if err != nil {
+			return uid, gid, fmt.Errorf("failed to parse uid %q: %w", id, err)
+		}
+		uid = value
+	}
+	if group != "" {
+		id := group
+		if _, err := strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return uid, gid, fmt.Errorf("failed to lookup group %q: %w", group, err)
+			}
+			id = g.Gid
+		}
+		value, err := strconv.Atoi(id)
+		if err != nil {
+			return uid, gid, fmt.Errorf("failed to parse gid %q: %w", id, err)
+		}
+		gid = value
+	}
+	return uid, gid, nil
+}
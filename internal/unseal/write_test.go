@@ -0,0 +1,177 @@
+package unseal
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Verify that write applies permissions and ownership as expected.
+func TestWrite(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "file"), []byte("file"), 0o600); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	tests := []struct {
+		name          string
+		path          string
+		entry         entry
+		fileMode      fs.FileMode
+		umask         fs.FileMode
+		expectedMode  fs.FileMode
+		expectedError bool
+	}{
+		{
+			name:         "default",
+			path:         filepath.Join(tmpDir, "default"),
+			expectedMode: defaultFileMode,
+		},
+		{
+			name:         "mode",
+			path:         filepath.Join(tmpDir, "mode"),
+			entry:        entry{Mode: testFileMode(0o600)},
+			expectedMode: 0o600,
+		},
+		{
+			name:         "dir-mode",
+			path:         filepath.Join(tmpDir, "a", "b", "dir-mode"),
+			entry:        entry{DirMode: testFileMode(0o700)},
+			expectedMode: defaultFileMode,
+		},
+		{
+			name:         "missing-parent",
+			path:         filepath.Join(tmpDir, "missing", "missing-parent"),
+			expectedMode: defaultFileMode,
+		},
+		{
+			name:          "parent-is-file",
+			path:          filepath.Join(tmpDir, "file", "parent-is-file"),
+			expectedError: true,
+		},
+		{
+			name:         "owner",
+			path:         filepath.Join(tmpDir, "owner"),
+			entry:        entry{Owner: strconv.Itoa(os.Getuid()), Group: strconv.Itoa(os.Getgid())},
+			expectedMode: defaultFileMode,
+		},
+		{
+			name:         "file-mode",
+			path:         filepath.Join(tmpDir, "file-mode"),
+			fileMode:     0o600,
+			expectedMode: 0o600,
+		},
+		{
+			name:         "umask",
+			path:         filepath.Join(tmpDir, "umask"),
+			entry:        entry{Mode: testFileMode(0o644)},
+			umask:        0o077,
+			expectedMode: 0o600,
+		},
+		{
+			name:          "unknown-owner",
+			path:          filepath.Join(tmpDir, "unknown-owner"),
+			entry:         entry{Owner: "unseal-test-user-does-not-exist"},
+			expectedError: true,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			p := &processor{dirMode: defaultDirMode, fileMode: defaultFileMode, umask: tst.umask}
+			if tst.fileMode != 0 {
+				p.fileMode = tst.fileMode
+			}
+			written, err := p.write(tst.path, &tst.entry, []byte(tst.name))
+			switch {
+			case tst.expectedError && err == nil:
+				t.Errorf("Expected write to raise an error")
+			case !tst.expectedError && err != nil:
+				t.Errorf("write raised an unexpected error: %v", err)
+			case err != nil:
+				return
+			case !written:
+				t.Errorf("Expected write to report file was written")
+			}
+			info, err := os.Stat(tst.path)
+			if err != nil {
+				t.Fatalf("Failed to stat file: %v", err)
+			}
+			if info.Mode().Perm() != tst.expectedMode {
+				t.Errorf("Expected mode %o, got %o", tst.expectedMode, info.Mode().Perm())
+			}
+			if data, err := os.ReadFile(tst.path); err != nil || !bytes.Equal(data, []byte(tst.name)) {
+				t.Errorf("Expected file to contain %q, got %q: %v", tst.name, data, err)
+			}
+			if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(tst.path), "."+filepath.Base(tst.path)+".*")); len(matches) != 0 {
+				t.Errorf("Expected temporary files to be removed, found %v", matches)
+			}
+		})
+	}
+}
+
+// Verify that write does not rewrite files that have unchanged content.
+func TestWrite_Unchanged(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "unchanged")
+	p := &processor{dirMode: defaultDirMode, fileMode: defaultFileMode}
+	if _, err := p.write(path, &entry{}, []byte("unchanged")); err != nil {
+		t.Fatalf("write raised an unexpected error: %v", err)
+	}
+	mtime := time.Now().Add(-1 * time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("Failed to set file times: %v", err)
+	}
+	written, err := p.write(path, &entry{Mode: testFileMode(0o600)}, []byte("unchanged"))
+	switch {
+	case err != nil:
+		t.Fatalf("write raised an unexpected error: %v", err)
+	case written:
+		t.Errorf("Expected write to skip unchanged file")
+	}
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		t.Fatalf("Failed to stat file: %v", err)
+	case !info.ModTime().Equal(mtime):
+		t.Errorf("Expected modification time to be %v, got %v", mtime, info.ModTime())
+	case info.Mode().Perm() != 0o600:
+		t.Errorf("Expected mode %o, got %o", 0o600, info.Mode().Perm())
+	}
+	written, err = p.write(path, &entry{}, []byte("changed"))
+	switch {
+	case err != nil:
+		t.Fatalf("write raised an unexpected error: %v", err)
+	case !written:
+		t.Errorf("Expected write to replace changed file")
+	}
+}
+
+// Verify that write does not create or modify files in dry-run mode.
+func TestWrite_DryRun(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	existing := filepath.Join(tmpDir, "existing")
+	if err := os.WriteFile(existing, []byte("existing"), 0o600); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	p := &processor{dirMode: defaultDirMode, fileMode: defaultFileMode, dryRun: true}
+	for _, path := range []string{existing, filepath.Join(tmpDir, "a", "new")} {
+		if _, err := p.write(path, &entry{}, []byte("changed")); err != nil {
+			t.Errorf("write raised an unexpected error: %v", err)
+		}
+	}
+	if data, err := os.ReadFile(existing); err != nil || string(data) != "existing" {
+		t.Errorf("Expected existing file to be unchanged, got %q: %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "a")); !os.IsNotExist(err) {
+		t.Errorf("Expected parent directory to not be created, got %v", err)
+	}
+	if _, err := p.write(filepath.Join(existing, "child"), &entry{}, []byte("changed")); err == nil {
+		t.Errorf("Expected write to raise an error for a target below a file")
+	}
+}
@@ -0,0 +1,287 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/memes/f5xc/internal/unseal"
+)
+
+const (
+	// The prefix shared by all annotations that control injection.
+	AnnotationPrefix = "unseal.f5xc/"
+	// An inline JSON or YAML unseal specification; the entries should write files beneath the mount path.
+	AnnotationSpec = AnnotationPrefix + "spec"
+	// The name of a ConfigMap in the pod's namespace whose keys are unseal specifications.
+	AnnotationSpecConfigMap = AnnotationPrefix + "spec-configmap"
+	// A comma-separated list of http(s) URLs of unseal specifications.
+	AnnotationSpecURL = AnnotationPrefix + "spec-url"
+	// Overrides the Wingman URL configured for the webhook.
+	AnnotationWingmanURL = AnnotationPrefix + "wingman-url"
+	// Overrides the path where the shared volume of unsealed files is mounted in every container.
+	AnnotationMountPath = AnnotationPrefix + "mount-path"
+	// A duration that, when present, adds a sidecar that refreshes the unsealed files at this interval.
+	AnnotationRefresh = AnnotationPrefix + "refresh"
+	// Added to pods by the webhook so that a pod is only injected once.
+	AnnotationInjected = AnnotationPrefix + "injected"
+	// The default path where the shared volume of unsealed files is mounted.
+	DefaultMountPath = "/var/run/secrets/unseal"
+)
+
+const (
+	// The names of the injected volumes and containers.
+	secretsVolume        = "unseal-secrets"
+	specVolume           = "unseal-spec"
+	specConfigMapVolume  = "unseal-spec-configmap"
+	initContainerName    = "unseal"
+	sidecarContainerName = "unseal-refresh"
+	// The paths where specification volumes are mounted in the injected containers.
+	specMountPath          = "/etc/unseal/spec"
+	specConfigMapMountPath = "/etc/unseal/spec.d"
+	// The file name of the inline specification projected from the pod annotation.
+	specFileName = "spec.yaml"
+)
+
+// ErrInvalidAnnotation is returned when a pod annotation that controls injection has an unacceptable value.
+var ErrInvalidAnnotation = errors.New("invalid unseal annotation")
+
+// The subset of a Kubernetes Pod that the webhook inspects; the webhook only adds to a pod, so fields that are not
+// needed to build the patch are ignored.
+type pod struct {
+	Metadata struct {
+		Annotations map[string]string `json:"annotations,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		InitContainers []container `json:"initContainers,omitempty"`
+		Containers     []container `json:"containers"`
+		Volumes        []struct {
+			Name string `json:"name"`
+		} `json:"volumes,omitempty"`
+	} `json:"spec"`
+}
+
+// The subset of a Kubernetes Container that the webhook inspects or injects.
+type container struct {
+	Name         string        `json:"name"`
+	Image        string        `json:"image,omitempty"`
+	Args         []string      `json:"args,omitempty"`
+	Env          []envVar      `json:"env,omitempty"`
+	VolumeMounts []volumeMount `json:"volumeMounts,omitempty"`
+}
+
+// A Kubernetes container environment variable with a literal value.
+type envVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// A Kubernetes container volume mount.
+type volumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+	ReadOnly  bool   `json:"readOnly,omitempty"`
+}
+
+// A Kubernetes Volume using one of the sources that the webhook injects.
+type volume struct {
+	Name        string             `json:"name"`
+	EmptyDir    *emptyDirSource    `json:"emptyDir,omitempty"`
+	DownwardAPI *downwardAPISource `json:"downwardAPI,omitempty"`
+	ConfigMap   *configMapSource   `json:"configMap,omitempty"`
+}
+
+// An emptyDir volume source; unsealed files are kept in memory.
+type emptyDirSource struct {
+	Medium string `json:"medium,omitempty"`
+}
+
+// A downwardAPI volume source that projects pod fields into files.
+type downwardAPISource struct {
+	Items []downwardAPIItem `json:"items"`
+}
+
+// A single file projected from a pod field.
+type downwardAPIItem struct {
+	Path     string `json:"path"`
+	FieldRef struct {
+		FieldPath string `json:"fieldPath"`
+	} `json:"fieldRef"`
+}
+
+// A configMap volume source.
+type configMapSource struct {
+	Name string `json:"name"`
+}
+
+// A single RFC 6902 JSON Patch operation.
+type patchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+}
+
+// Returns true if the pod has requested injection and has not already been injected.
+func wantsInjection(p *pod) bool {
+	annotations := p.Metadata.Annotations
+	if _, ok := annotations[AnnotationInjected]; ok {
+		return false
+	}
+	for _, key := range []string{AnnotationSpec, AnnotationSpecConfigMap, AnnotationSpecURL} {
+		if annotations[key] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Configures the containers injected into pods.
+type injector struct {
+	// The container image that provides the unseal binary.
+	image string
+	// The Wingman URL to use unless a pod overrides it.
+	wingmanURL string
+}
+
+// Returns the JSON Patch operations that will add the unseal init container, an optional refresh sidecar, and the
+// shared volume of unsealed files to the pod, or nil if the pod has not requested injection.
+func (i *injector) patch(p *pod) ([]patchOperation, error) {
+	if !wantsInjection(p) {
+		return nil, nil
+	}
+	annotations := p.Metadata.Annotations
+	mountPath := DefaultMountPath
+	if value, ok := annotations[AnnotationMountPath]; ok {
+		if !path.IsAbs(value) || path.Clean(value) == "/" {
+			return nil, fmt.Errorf("%s must be an absolute path below /, got %q: %w", AnnotationMountPath, value, ErrInvalidAnnotation)
+		}
+		mountPath = path.Clean(value)
+	}
+	var refresh time.Duration
+	if value, ok := annotations[AnnotationRefresh]; ok {
+		var err error
+		if refresh, err = time.ParseDuration(value); err != nil || refresh <= 0 {
+			return nil, fmt.Errorf("%s must be a positive duration, got %q: %w", AnnotationRefresh, value, ErrInvalidAnnotation)
+		}
+	}
+	for _, existing := range p.Spec.Volumes {
+		switch existing.Name {
+		case secretsVolume, specVolume, specConfigMapVolume:
+			return nil, fmt.Errorf("volume name %q is reserved for injection: %w", existing.Name, ErrInvalidAnnotation)
+		}
+	}
+	volumes, mounts, sources, err := specVolumes(annotations)
+	if err != nil {
+		return nil, err
+	}
+	volumes = append([]volume{{Name: secretsVolume, EmptyDir: &emptyDirSource{Medium: "Memory"}}}, volumes...)
+	mounts = append([]volumeMount{{Name: secretsVolume, MountPath: mountPath}}, mounts...)
+	wingmanURL := i.wingmanURL
+	if value := annotations[AnnotationWingmanURL]; value != "" {
+		wingmanURL = value
+	}
+	unsealContainer := container{
+		Name:         initContainerName,
+		Image:        i.image,
+		Args:         sources,
+		Env:          []envVar{{Name: unseal.EnvWingmanURL, Value: wingmanURL}},
+		VolumeMounts: mounts,
+	}
+
+	ops := []patchOperation{{
+		Op:    "add",
+		Path:  "/metadata/annotations/" + escapePointer(AnnotationInjected),
+		Value: "true",
+	}}
+	ops = appendItems(ops, "/spec/volumes", len(p.Spec.Volumes) == 0, volumes...)
+	// Existing containers must be patched before the init container is inserted, as that will change the indices.
+	appMount := volumeMount{Name: secretsVolume, MountPath: mountPath, ReadOnly: true}
+	ops = appendMounts(ops, "/spec/initContainers", p.Spec.InitContainers, appMount)
+	ops = appendMounts(ops, "/spec/containers", p.Spec.Containers, appMount)
+	if len(p.Spec.InitContainers) == 0 {
+		ops = append(ops, patchOperation{Op: "add", Path: "/spec/initContainers", Value: []container{unsealContainer}})
+	} else {
+		ops = append(ops, patchOperation{Op: "add", Path: "/spec/initContainers/0", Value: unsealContainer})
+	}
+	if refresh > 0 {
+		sidecar := unsealContainer
+		sidecar.Name = sidecarContainerName
+		sidecar.Args = append([]string{"--daemon", "--interval", refresh.String()}, sources...)
+		ops = append(ops, patchOperation{Op: "add", Path: "/spec/containers/-", Value: sidecar})
+	}
+	return ops, nil
+}
+
+// Returns the volumes that supply unseal specifications to the injected containers with their mounts, and the sources
+// that should be passed to unseal, in the order inline specification, ConfigMap, and then URLs.
+func specVolumes(annotations map[string]string) ([]volume, []volumeMount, []string, error) {
+	var volumes []volume
+	var mounts []volumeMount
+	var sources []string
+	if annotations[AnnotationSpec] != "" {
+		item := downwardAPIItem{Path: specFileName}
+		item.FieldRef.FieldPath = "metadata.annotations['" + AnnotationSpec + "']"
+		volumes = append(volumes, volume{
+			Name:        specVolume,
+			DownwardAPI: &downwardAPISource{Items: []downwardAPIItem{item}},
+		})
+		mounts = append(mounts, volumeMount{Name: specVolume, MountPath: specMountPath, ReadOnly: true})
+		sources = append(sources, path.Join(specMountPath, specFileName))
+	}
+	if name := annotations[AnnotationSpecConfigMap]; name != "" {
+		volumes = append(volumes, volume{
+			Name:      specConfigMapVolume,
+			ConfigMap: &configMapSource{Name: name},
+		})
+		mounts = append(mounts, volumeMount{Name: specConfigMapVolume, MountPath: specConfigMapMountPath, ReadOnly: true})
+		sources = append(sources, specConfigMapMountPath)
+	}
+	if value := annotations[AnnotationSpecURL]; value != "" {
+		for _, url := range strings.Split(value, ",") {
+			url = strings.TrimSpace(url)
+			lower := strings.ToLower(url)
+			if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+				return nil, nil, nil, fmt.Errorf("%s must contain http(s) URLs, got %q: %w", AnnotationSpecURL, url, ErrInvalidAnnotation)
+			}
+			sources = append(sources, url)
+		}
+	}
+	return volumes, mounts, sources, nil
+}
+
+// Appends operations that add the values to the array at the JSON pointer, creating the array if it does not exist.
+func appendItems[T any](ops []patchOperation, pointer string, create bool, values ...T) []patchOperation {
+	if create {
+		return append(ops, patchOperation{Op: "add", Path: pointer, Value: values})
+	}
+	for _, value := range values {
+		ops = append(ops, patchOperation{Op: "add", Path: pointer + "/-", Value: value})
+	}
+	return ops
+}
+
+// Appends operations that add the volume mount to each container, unless the container already has a volume mounted
+// at the same path.
+func appendMounts(ops []patchOperation, pointer string, containers []container, mount volumeMount) []patchOperation {
+	for i, c := range containers {
+		conflict := false
+		for _, existing := range c.VolumeMounts {
+			if path.Clean(existing.MountPath) == mount.MountPath {
+				conflict = true
+				break
+			}
+		}
+		if conflict {
+			continue
+		}
+		ops = appendItems(ops, fmt.Sprintf("%s/%d/volumeMounts", pointer, i), len(c.VolumeMounts) == 0, mount)
+	}
+	return ops
+}
+
+// Escapes a string for use as a JSON pointer reference token, as described in RFC 6901.
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,253 @@
+// Package webhook implements a Kubernetes mutating admission webhook that injects the unseal init container, an
+// optional refresh sidecar, and a shared volume of unsealed files into annotated pods. It backs the unseal-webhook
+// binary; see cmd/unseal-webhook for usage.
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/memes/f5xc/wingman"
+)
+
+const (
+	// The default address to listen on for admission requests.
+	DefaultAddress = ":8443"
+	// The path that serves admission requests.
+	MutatePath = "/mutate"
+	// The path that reports the health of the webhook.
+	HealthPath = "/healthz"
+	// The maximum size of an admission review that will be accepted.
+	maxReviewSize = 3 << 20
+	// The time allowed for reading request headers.
+	readHeaderTimeout = 10 * time.Second
+	// The time allowed for in-flight requests to complete during shutdown.
+	shutdownTimeout = 10 * time.Second
+)
+
+// The exit code returned when the webhook fails to start or exits with an error.
+const exitFailure = 1
+
+// The admission.k8s.io/v1 AdmissionReview envelope for requests and responses.
+type admissionReview struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Request    *admissionRequest  `json:"request,omitempty"`
+	Response   *admissionResponse `json:"response,omitempty"`
+}
+
+// The subset of an admission request that the webhook inspects.
+type admissionRequest struct {
+	UID  string `json:"uid"`
+	Kind struct {
+		Group   string `json:"group"`
+		Version string `json:"version"`
+		Kind    string `json:"kind"`
+	} `json:"kind"`
+	Namespace string          `json:"namespace,omitempty"`
+	Operation string          `json:"operation,omitempty"`
+	Object    json.RawMessage `json:"object,omitempty"`
+}
+
+// An admission response; the patch is base64 encoded by encoding/json.
+type admissionResponse struct {
+	UID       string           `json:"uid"`
+	Allowed   bool             `json:"allowed"`
+	Result    *admissionStatus `json:"status,omitempty"`
+	PatchType string           `json:"patchType,omitempty"`
+	Patch     []byte           `json:"patch,omitempty"`
+}
+
+// The status returned with a rejected admission request.
+type admissionStatus struct {
+	Message string `json:"message,omitempty"`
+	Code    int    `json:"code,omitempty"`
+}
+
+// Returns the response to an admission request; pods that request injection are patched, pods with invalid annotations
+// are rejected, and everything else is allowed unchanged.
+func (i *injector) review(req *admissionRequest) *admissionResponse {
+	logger := slog.With("uid", req.UID, "namespace", req.Namespace, "operation", req.Operation)
+	resp := &admissionResponse{
+		UID:     req.UID,
+		Allowed: true,
+	}
+	if req.Kind.Group != "" || req.Kind.Kind != "Pod" {
+		logger.Debug("Ignoring admission request for unsupported kind", "kind", req.Kind.Kind)
+		return resp
+	}
+	var p pod
+	if err := json.Unmarshal(req.Object, &p); err != nil {
+		logger.Error("Failed to parse pod", "error", err)
+		resp.Allowed = false
+		resp.Result = &admissionStatus{Message: "failed to parse pod: " + err.Error(), Code: http.StatusBadRequest}
+		return resp
+	}
+	ops, err := i.patch(&p)
+	if err != nil {
+		logger.Warn("Rejecting pod with invalid annotations", "error", err)
+		resp.Allowed = false
+		resp.Result = &admissionStatus{Message: err.Error(), Code: http.StatusBadRequest}
+		return resp
+	}
+	if len(ops) == 0 {
+		return resp
+	}
+	patch, err := json.Marshal(ops)
+	if err != nil {
+		logger.Error("Failed to marshal patch", "error", err)
+		resp.Allowed = false
+		resp.Result = &admissionStatus{Message: "failed to marshal patch: " + err.Error(), Code: http.StatusInternalServerError}
+		return resp
+	}
+	logger.Info("Injecting unseal containers", "operations", len(ops))
+	resp.PatchType = "JSONPatch"
+	resp.Patch = patch
+	return resp
+}
+
+// Implements http.Handler to serve AdmissionReview requests.
+func (i *injector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var review admissionReview
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReviewSize)).Decode(&review); err != nil || review.Request == nil {
+		slog.Warn("Invalid admission review", "error", err)
+		http.Error(w, "invalid admission review", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&admissionReview{
+		APIVersion: review.APIVersion,
+		Kind:       review.Kind,
+		Response:   i.review(review.Request),
+	}); err != nil {
+		slog.Warn("Failed to write admission review", "error", err)
+	}
+}
+
+// Returns the handler for the webhook and health endpoints.
+func (i *injector) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(MutatePath, i)
+	mux.HandleFunc("GET "+HealthPath, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	return mux
+}
+
+// Defines the command line options for the webhook.
+type options struct {
+	address    string
+	tlsCert    string
+	tlsKey     string
+	image      string
+	wingmanURL string
+	logLevel   slog.Level
+}
+
+// Parses the command line arguments into options.
+func parseArgs(args []string) (*options, error) {
+	opts := &options{}
+	flags := flag.NewFlagSet("unseal-webhook", flag.ContinueOnError)
+	flags.StringVar(&opts.address, "address", DefaultAddress, "The address to listen on for admission requests")
+	flags.StringVar(&opts.tlsCert, "tls-cert", "", "The PEM encoded TLS certificate file presented to the API server")
+	flags.StringVar(&opts.tlsKey, "tls-key", "", "The PEM encoded private key file for the TLS certificate")
+	flags.StringVar(&opts.image, "image", "", "The container image that provides the unseal binary")
+	flags.StringVar(&opts.wingmanURL, "wingman-url", wingman.DefaultWingmanURL, "The Wingman URL used by injected containers unless overridden by a pod annotation")
+	flags.TextVar(&opts.logLevel, "log-level", slog.LevelInfo, "The logging level; one of DEBUG, INFO, WARN, or ERROR")
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("failed to parse arguments: %w", err)
+	}
+	switch {
+	case flags.NArg() > 0:
+		return nil, fmt.Errorf("unexpected arguments %v: %w", flags.Args(), flag.ErrHelp)
+	case opts.image == "":
+		return nil, fmt.Errorf("an unseal image is required: %w", flag.ErrHelp)
+	case opts.tlsCert == "" || opts.tlsKey == "":
+		return nil, fmt.Errorf("a TLS certificate and key are required: %w", flag.ErrHelp)
+	}
+	return opts, nil
+}
+
+// Run executes the webhook with the command line arguments, excluding the program name, and returns the exit code.
+// The webhook will serve admission requests until interrupted.
+func Run(args []string) int {
+	level := slog.LevelVar{}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     &level,
+	})))
+	opts, err := parseArgs(args)
+	if err != nil {
+		slog.Error("Invalid arguments", "error", err)
+		return exitFailure
+	}
+	level.Set(opts.logLevel)
+	cert, err := tls.LoadX509KeyPair(opts.tlsCert, opts.tlsKey)
+	if err != nil {
+		slog.Error("Failed to load TLS certificate", "error", err)
+		return exitFailure
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	i := &injector{
+		image:      opts.image,
+		wingmanURL: opts.wingmanURL,
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if err := serve(ctx, opts.address, tlsConfig, i.handler()); err != nil {
+		slog.Error("Webhook failed", "error", err)
+		return exitFailure
+	}
+	return 0
+}
+
+// Serves the handler over TLS on the address until the context is done, then allows in-flight requests to complete.
+func serve(ctx context.Context, address string, tlsConfig *tls.Config, handler http.Handler) error {
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen for admission requests: %w", err)
+	}
+	logger := slog.With("address", listener.Addr().String())
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		logger.Info("Serving admission requests")
+		errs <- server.Serve(tls.NewListener(listener, tlsConfig))
+	}()
+	select {
+	case <-ctx.Done():
+		logger.Info("Stopping webhook")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("failed to shutdown webhook: %w", err)
+		}
+		if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("webhook server failed: %w", err)
+		}
+		return nil
+	case err := <-errs:
+		return fmt.Errorf("webhook server failed: %w", err)
+	}
+}
@@ -0,0 +1,256 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+// Verify that the expected patch operations are generated for annotated pods.
+func TestPatch(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name            string
+		pod             string
+		expectedPaths   []string
+		expectedArgs    []string
+		expectedSidecar []string
+		expectedError   error
+	}{
+		{
+			name: "not-annotated",
+			pod:  `{"metadata":{"annotations":{"app":"test"}},"spec":{"containers":[{"name":"app"}]}}`,
+		},
+		{
+			name: "already-injected",
+			pod:  `{"metadata":{"annotations":{"unseal.f5xc/spec":"{}","unseal.f5xc/injected":"true"}},"spec":{"containers":[{"name":"app"}]}}`,
+		},
+		{
+			name: "inline",
+			pod:  `{"metadata":{"annotations":{"unseal.f5xc/spec":"{}"}},"spec":{"containers":[{"name":"app"}]}}`,
+			expectedPaths: []string{
+				"/metadata/annotations/unseal.f5xc~1injected",
+				"/spec/volumes",
+				"/spec/containers/0/volumeMounts",
+				"/spec/initContainers",
+			},
+			expectedArgs: []string{"/etc/unseal/spec/spec.yaml"},
+		},
+		{
+			name: "existing",
+			pod: `{"metadata":{"annotations":{"unseal.f5xc/spec-configmap":"specs","unseal.f5xc/spec-url":"https://example.com/a.json, http://example.com/b.yaml"}},` +
+				`"spec":{"initContainers":[{"name":"setup","volumeMounts":[{"name":"data","mountPath":"/data"}]}],` +
+				`"containers":[{"name":"app"},{"name":"other","volumeMounts":[{"name":"data","mountPath":"/var/run/secrets/unseal/"}]}],` +
+				`"volumes":[{"name":"data","emptyDir":{}}]}}`,
+			expectedPaths: []string{
+				"/metadata/annotations/unseal.f5xc~1injected",
+				"/spec/volumes/-",
+				"/spec/volumes/-",
+				"/spec/initContainers/0/volumeMounts/-",
+				"/spec/containers/0/volumeMounts",
+				"/spec/initContainers/0",
+			},
+			expectedArgs: []string{"/etc/unseal/spec.d", "https://example.com/a.json", "http://example.com/b.yaml"},
+		},
+		{
+			name: "refresh",
+			pod:  `{"metadata":{"annotations":{"unseal.f5xc/spec":"{}","unseal.f5xc/refresh":"5m","unseal.f5xc/mount-path":"/secrets/"}},"spec":{"containers":[{"name":"app"}]}}`,
+			expectedPaths: []string{
+				"/metadata/annotations/unseal.f5xc~1injected",
+				"/spec/volumes",
+				"/spec/containers/0/volumeMounts",
+				"/spec/initContainers",
+				"/spec/containers/-",
+			},
+			expectedArgs:    []string{"/etc/unseal/spec/spec.yaml"},
+			expectedSidecar: []string{"--daemon", "--interval", "5m0s", "/etc/unseal/spec/spec.yaml"},
+		},
+		{
+			name:          "invalid-refresh",
+			pod:           `{"metadata":{"annotations":{"unseal.f5xc/spec":"{}","unseal.f5xc/refresh":"soon"}},"spec":{"containers":[{"name":"app"}]}}`,
+			expectedError: ErrInvalidAnnotation,
+		},
+		{
+			name:          "invalid-mount-path",
+			pod:           `{"metadata":{"annotations":{"unseal.f5xc/spec":"{}","unseal.f5xc/mount-path":"secrets"}},"spec":{"containers":[{"name":"app"}]}}`,
+			expectedError: ErrInvalidAnnotation,
+		},
+		{
+			name:          "invalid-url",
+			pod:           `{"metadata":{"annotations":{"unseal.f5xc/spec-url":"file:///etc/spec.json"}},"spec":{"containers":[{"name":"app"}]}}`,
+			expectedError: ErrInvalidAnnotation,
+		},
+		{
+			name:          "reserved-volume",
+			pod:           `{"metadata":{"annotations":{"unseal.f5xc/spec":"{}"}},"spec":{"containers":[{"name":"app"}],"volumes":[{"name":"unseal-secrets"}]}}`,
+			expectedError: ErrInvalidAnnotation,
+		},
+	}
+	i := &injector{image: "unseal:test", wingmanURL: "http://localhost:8070"}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			var p pod
+			if err := json.Unmarshal([]byte(tst.pod), &p); err != nil {
+				t.Fatalf("failed to unmarshal pod: %v", err)
+			}
+			ops, err := i.patch(&p)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Fatalf("patch raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Fatalf("Expected patch to raise %v, got %v", tst.expectedError, err)
+			case err != nil:
+				return
+			}
+			paths := make([]string, 0, len(ops))
+			for _, op := range ops {
+				paths = append(paths, op.Path)
+				switch value := op.Value.(type) {
+				case container:
+					if value.Name == sidecarContainerName && !slices.Equal(value.Args, tst.expectedSidecar) {
+						t.Errorf("Expected sidecar args %v, got %v", tst.expectedSidecar, value.Args)
+					}
+					if value.Name == initContainerName && !slices.Equal(value.Args, tst.expectedArgs) {
+						t.Errorf("Expected init container args %v, got %v", tst.expectedArgs, value.Args)
+					}
+				case []container:
+					if !slices.Equal(value[0].Args, tst.expectedArgs) {
+						t.Errorf("Expected init container args %v, got %v", tst.expectedArgs, value[0].Args)
+					}
+				}
+			}
+			if !slices.Equal(paths, tst.expectedPaths) {
+				t.Errorf("Expected patch paths %v, got %v", tst.expectedPaths, paths)
+			}
+		})
+	}
+}
+
+// Verify that admission reviews are answered with a patch, a rejection, or an unchanged response.
+func TestServeHTTP(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer((&injector{image: "unseal:test", wingmanURL: "http://localhost:8070"}).handler())
+	t.Cleanup(server.Close)
+	tests := []struct {
+		name            string
+		body            string
+		expectedStatus  int
+		expectedAllowed bool
+		expectedPatch   bool
+	}{
+		{
+			name:           "invalid",
+			body:           `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:            "not-pod",
+			body:            `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{"uid":"1","kind":{"group":"apps","version":"v1","kind":"Deployment"},"object":{}}}`,
+			expectedStatus:  http.StatusOK,
+			expectedAllowed: true,
+		},
+		{
+			name:            "injected",
+			body:            `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{"uid":"1","kind":{"group":"","version":"v1","kind":"Pod"},"object":{"metadata":{"annotations":{"unseal.f5xc/spec":"{}"}},"spec":{"containers":[{"name":"app"}]}}}}`,
+			expectedStatus:  http.StatusOK,
+			expectedAllowed: true,
+			expectedPatch:   true,
+		},
+		{
+			name:           "rejected",
+			body:           `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{"uid":"1","kind":{"group":"","version":"v1","kind":"Pod"},"object":{"metadata":{"annotations":{"unseal.f5xc/spec":"{}","unseal.f5xc/refresh":"0s"}},"spec":{"containers":[{"name":"app"}]}}}}`,
+			expectedStatus: http.StatusOK,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL+MutatePath, bytes.NewBufferString(tst.body))
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatalf("request raised an unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tst.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tst.expectedStatus, resp.StatusCode)
+			}
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+			var review admissionReview
+			if err := json.NewDecoder(resp.Body).Decode(&review); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			switch {
+			case review.Response == nil:
+				t.Fatal("Expected a response")
+			case review.Response.UID != "1":
+				t.Errorf("Expected response UID 1, got %q", review.Response.UID)
+			case review.Response.Allowed != tst.expectedAllowed:
+				t.Errorf("Expected allowed %t, got %t", tst.expectedAllowed, review.Response.Allowed)
+			case tst.expectedPatch && (review.Response.PatchType != "JSONPatch" || !json.Valid(review.Response.Patch)):
+				t.Errorf("Expected a JSON patch, got %q %q", review.Response.PatchType, string(review.Response.Patch))
+			case !tst.expectedPatch && len(review.Response.Patch) > 0:
+				t.Errorf("Unexpected patch %q", string(review.Response.Patch))
+			}
+		})
+	}
+}
+
+// Verify that the command line arguments are parsed as expected.
+func TestParseArgs(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError error
+	}{
+		{
+			name: "valid",
+			args: []string{"--image", "unseal:test", "--tls-cert", "tls.crt", "--tls-key", "tls.key"},
+		},
+		{
+			name:          "missing-image",
+			args:          []string{"--tls-cert", "tls.crt", "--tls-key", "tls.key"},
+			expectedError: flag.ErrHelp,
+		},
+		{
+			name:          "missing-key",
+			args:          []string{"--image", "unseal:test", "--tls-cert", "tls.crt"},
+			expectedError: flag.ErrHelp,
+		},
+		{
+			name:          "extra-args",
+			args:          []string{"--image", "unseal:test", "--tls-cert", "tls.crt", "--tls-key", "tls.key", "unexpected"},
+			expectedError: flag.ErrHelp,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := parseArgs(tst.args)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("parseArgs raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected parseArgs to raise %v, got %v", tst.expectedError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package f5xc
+
+import (
+	"fmt"
+	"io"
+)
+
+// DefaultMaxResponseSize is the maximum number of bytes of a response body that are read by the API functions of the
+// package, unless changed with [WithMaxResponseSize].
+const DefaultMaxResponseSize = 4 << 20
+
+// Limit the number of bytes of a response body that are read by the API functions of the package and the caches of
+// the client, so that a misbehaving endpoint cannot exhaust memory; a larger response is returned as an [*Error] that
+// wraps [ErrResponseTooLarge]. The default is [DefaultMaxResponseSize].
+func WithMaxResponseSize(size int64) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding maximum response size", "size", size)
+		if size <= 0 {
+			return fmt.Errorf("maximum response size must be positive: %w", ErrInvalidOption)
+		}
+		c.maxResponseSize = size
+		return nil
+	}
+}
+
+// Returns the maximum response size of a client from NewClient or NewAPIClient, or the default for any other client.
+func clientMaxResponseSize(client Doer) int64 {
+	if t := clientTransport(client); t != nil && t.maxResponseSize > 0 {
+		return t.maxResponseSize
+	}
+	return DefaultMaxResponseSize
+}
+
+// Reads the reader to the end, returning an error that wraps ErrResponseTooLarge if there are more than limit bytes.
+func readAllLimit(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	switch {
+	case err != nil:
+		return nil, err //nolint:wrapcheck // Read errors are returned unchanged
+	case int64(len(data)) > limit:
+		return nil, fmt.Errorf("response body exceeds %d bytes: %w", limit, ErrResponseTooLarge)
+	}
+	return data, nil
+}
@@ -0,0 +1,67 @@
+package f5xc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+)
+
+// Verify that a response body larger than the maximum response size is rejected by the API functions and caches.
+func TestWithMaxResponseSize(t *testing.T) {
+	t.Parallel()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{Data: f5xc.PublicKey{Tenant: "test", ModulusBase64: strings.Repeat("QUFB", 256)}})
+	})
+	tests := []struct {
+		name          string
+		options       []f5xc.Option
+		expectedError error
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:          "too-large",
+			options:       []f5xc.Option{f5xc.WithMaxResponseSize(1024)},
+			expectedError: f5xc.ErrResponseTooLarge,
+		},
+		{
+			name:    "within-limit",
+			options: []f5xc.Option{f5xc.WithMaxResponseSize(2048)},
+		},
+		{
+			name:          "memory-cache",
+			options:       []f5xc.Option{f5xc.WithMaxResponseSize(1024), f5xc.WithCache(time.Minute)},
+			expectedError: f5xc.ErrResponseTooLarge,
+		},
+		{
+			name:          "disk-cache",
+			options:       []f5xc.Option{f5xc.WithMaxResponseSize(1024), f5xc.WithDiskCache(t.TempDir(), time.Minute)},
+			expectedError: f5xc.ErrResponseTooLarge,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			client, _ := testAPIClient(t, handler, tst.options...)
+			publicKey, err := f5xc.GetPublicKey(context.Background(), client, nil)
+			switch {
+			case !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected GetPublicKey to raise %v, got %v", tst.expectedError, err)
+			case err == nil && (publicKey == nil || publicKey.Tenant != "test"):
+				t.Errorf("Expected public key for tenant test, got %+v", publicKey)
+			case err != nil && f5xc.IsRetryable(err):
+				t.Errorf("Expected a response that is too large not to be retryable")
+			}
+		})
+	}
+	if _, err := f5xc.NewClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"), f5xc.WithMaxResponseSize(0)); !errors.Is(err, f5xc.ErrInvalidOption) {
+		t.Errorf("Expected NewClient to raise %v, got %v", f5xc.ErrInvalidOption, err)
+	}
+}
@@ -0,0 +1,109 @@
+package f5xc
+
+import (
+	"fmt"
+	"iter"
+	"net/http"
+)
+
+// The query parameter that requests the next page of a list from F5XC endpoints.
+const ListPageTokenParam = "page_token"
+
+// Many F5XC list endpoints return the resources in an items array, with a token to request the next page of resources
+// when the list is larger than a single response.
+type ListEnvelope[T any] struct {
+	Items []T `json:"items" yaml:"items"`
+	// The token to request the next page of items, or empty if this is the last page.
+	NextPageToken string `json:"next_page_token,omitempty" yaml:"nextPageToken,omitempty"`
+}
+
+// Helper method to make a single F5XC list API request, returning the page of
+// items in the ListEnvelope of the response or an error. As with
+// [StrictEnvelopeAPICall], an HTTP status code of 404 returns an [*Error] that
+// wraps [ErrNotFound]; use [ListAll] to walk every page of the list.
+func ListAPICall[T any](client Doer, req *http.Request) (*ListEnvelope[T], error) {
+	return apiCall[ListEnvelope[T]](client, req, true)
+}
+
+// ListIterator walks every page of an F5XC list endpoint; see [ListAll].
+type ListIterator[T any] struct {
+	client Doer
+	req    *http.Request
+	err    error
+}
+
+// ListAll returns a ListIterator that requests every page of the list endpoint
+// of the request, adding the [ListPageTokenParam] query parameter to a copy of
+// the request for each page after the first. The request must not have a body
+// that cannot be replayed.
+//
+//	items := f5xc.ListAll[Resource](client, req)
+//	for item := range items.All() {
+//		...
+//	}
+//	if err := items.Err(); err != nil {
+//		...
+//	}
+func ListAll[T any](client Doer, req *http.Request) *ListIterator[T] {
+	return &ListIterator[T]{
+		client: client,
+		req:    req,
+	}
+}
+
+// All returns an iterator over the items of every page of the list, requesting
+// pages as they are needed. The iterator stops at the first error, which is
+// then returned by [ListIterator.Err]. Each call of All walks the list from the
+// first page.
+func (it *ListIterator[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		it.err = nil
+		var token string
+		for page := 0; ; page++ {
+			req, err := pageRequest(it.req, token)
+			if err != nil {
+				it.err = err
+				return
+			}
+			clientLogger(it.client).Debug("Requesting list page", "page", page)
+			list, err := ListAPICall[T](it.client, req)
+			if err != nil {
+				it.err = err
+				return
+			}
+			for _, item := range list.Items {
+				if !yield(item) {
+					return
+				}
+			}
+			if list.NextPageToken == "" || list.NextPageToken == token {
+				return
+			}
+			token = list.NextPageToken
+		}
+	}
+}
+
+// Err returns the error that stopped the last walk of the list, or nil if every page was retrieved.
+func (it *ListIterator[T]) Err() error {
+	return it.err
+}
+
+// Returns the request for the page of the list with the token; the first page uses the original request.
+func pageRequest(req *http.Request, token string) (*http.Request, error) {
+	if token == "" {
+		return req, nil
+	}
+	clone := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay request body: %w", err)
+		}
+		clone.Body = body
+	}
+	query := clone.URL.Query()
+	query.Set(ListPageTokenParam, token)
+	clone.URL.RawQuery = query.Encode()
+	return clone, nil
+}
@@ -0,0 +1,130 @@
+package f5xc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/memes/f5xc"
+)
+
+// The path of the list endpoint used in tests.
+const testListPath = "/api/web/namespaces"
+
+// Returns a handler that lists the items in pages of two, using the index of the next item as the page token; a page
+// token of fail returns a 500 status. Every request is counted.
+func testListHandler(requests *atomic.Int32, items []f5xc.Metadata) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		token := r.URL.Query().Get(f5xc.ListPageTokenParam)
+		if token == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		start, _ := strconv.Atoi(token)
+		end := min(start+2, len(items))
+		list := f5xc.ListEnvelope[f5xc.Metadata]{Items: items[start:end]}
+		if end < len(items) {
+			list.NextPageToken = strconv.Itoa(end)
+		}
+		_ = json.NewEncoder(w).Encode(list)
+	})
+}
+
+// Verify that ListAll walks every page of a list.
+func TestListAll(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name             string
+		items            []f5xc.Metadata
+		expectedRequests int32
+	}{
+		{
+			name:             "empty",
+			expectedRequests: 1,
+		},
+		{
+			name:             "single-page",
+			items:            []f5xc.Metadata{{Name: "one"}, {Name: "two"}},
+			expectedRequests: 1,
+		},
+		{
+			name:             "multiple-pages",
+			items:            []f5xc.Metadata{{Name: "one"}, {Name: "two"}, {Name: "three"}, {Name: "four"}, {Name: "five"}},
+			expectedRequests: 3,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			var requests atomic.Int32
+			client, serverURL := testAPIClient(t, testListHandler(&requests, tst.items))
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, serverURL+testListPath, nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			items := f5xc.ListAll[f5xc.Metadata](client, req)
+			result := slices.Collect(items.All())
+			if err := items.Err(); err != nil {
+				t.Fatalf("ListAll raised an unexpected error: %v", err)
+			}
+			if !slices.Equal(result, tst.items) {
+				t.Errorf("Expected %v, got %v", tst.items, result)
+			}
+			if count := requests.Load(); count != tst.expectedRequests {
+				t.Errorf("Expected %d requests, got %d", tst.expectedRequests, count)
+			}
+		})
+	}
+}
+
+// Verify that ListAll stops requesting pages when the caller stops iterating.
+func TestListAll_Break(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	client, serverURL := testAPIClient(t, testListHandler(&requests, []f5xc.Metadata{{Name: "one"}, {Name: "two"}, {Name: "three"}}))
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, serverURL+testListPath, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	items := f5xc.ListAll[f5xc.Metadata](client, req)
+	var result []f5xc.Metadata
+	for item := range items.All() {
+		result = append(result, item)
+		if len(result) == 1 {
+			break
+		}
+	}
+	if len(result) != 1 || result[0].Name != "one" {
+		t.Errorf("Expected only the first item, got %v", result)
+	}
+	if err := items.Err(); err != nil {
+		t.Errorf("ListAll raised an unexpected error: %v", err)
+	}
+	if count := requests.Load(); count != 1 {
+		t.Errorf("Expected 1 request, got %d", count)
+	}
+}
+
+// Verify that ListAll reports the error that stopped the walk of the list.
+func TestListAll_Error(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	client, serverURL := testAPIClient(t, testListHandler(&requests, nil))
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, serverURL+testListPath+"?"+f5xc.ListPageTokenParam+"=fail", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	items := f5xc.ListAll[f5xc.Metadata](client, req)
+	if result := slices.Collect(items.All()); len(result) != 0 {
+		t.Errorf("Expected no items, got %v", result)
+	}
+	if err := items.Err(); !errors.Is(err, f5xc.ErrUnexpectedHTTPStatus) {
+		t.Errorf("Expected ListAll to raise %v, got %v", f5xc.ErrUnexpectedHTTPStatus, err)
+	}
+}
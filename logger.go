@@ -0,0 +1,37 @@
+package f5xc
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// Use the logger for the messages of the client and the functions that are called with it, e.g. [EnvelopeAPICall],
+// instead of the default logger, so that an application or library can route or silence the messages of each client.
+// Options are applied in order, so the messages of options that are given before this option use the default logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) error {
+		if logger == nil {
+			return fmt.Errorf("logger must not be nil: %w", ErrInvalidOption)
+		}
+		c.logger = logger
+		c.log().Debug("Adding logger")
+		return nil
+	}
+}
+
+// Returns the logger set by WithLogger, or the default logger.
+func (c *config) log() *slog.Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+	return slog.Default()
+}
+
+// Returns the logger of a client from NewClient or NewAPIClient, found by unwrapping the RoundTrippers of its
+// transport, or the default logger for any other client.
+func clientLogger(client Doer) *slog.Logger {
+	if t := clientTransport(client); t != nil {
+		return t.logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,59 @@
+package f5xc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Verify that the messages of a client, and of the functions called with it, are written to the logger of the client.
+func TestWithLogger(t *testing.T) {
+	t.Parallel()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{Data: f5xc.PublicKey{KeyVersion: 1}})
+	})
+	tests := []struct {
+		name    string
+		options []f5xc.Option
+	}{
+		{
+			name: "transport",
+		},
+		{
+			name:    "tracing",
+			options: []f5xc.Option{f5xc.WithTracing(sdktrace.NewTracerProvider())},
+		},
+		{
+			name:    "retry",
+			options: []f5xc.Option{f5xc.WithRetryPolicy(f5xc.RetryPolicy{MaxRetries: 1}), f5xc.WithCircuitBreaker(5, time.Minute)},
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			client, _ := testAPIClient(t, handler, append(tst.options, f5xc.WithLogger(logger))...)
+			if _, err := f5xc.GetPublicKey(context.Background(), client, nil); err != nil {
+				t.Fatalf("GetPublicKey raised an unexpected error: %v", err)
+			}
+			for _, expected := range []string{"Adding logger", "Retrieving Public Key", "Calling API", "Adding authToken header"} {
+				if !strings.Contains(logs.String(), expected) {
+					t.Errorf("Expected logs to contain %q, got %s", expected, logs.String())
+				}
+			}
+		})
+	}
+	if _, err := f5xc.NewClient(f5xc.WithLogger(nil)); !errors.Is(err, f5xc.ErrInvalidOption) {
+		t.Errorf("Expected NewClient to raise %v for a nil logger, got %v", f5xc.ErrInvalidOption, err)
+	}
+}
@@ -0,0 +1,131 @@
+package f5xc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Keep public keys and secret policy documents fetched by the client in memory, and return them without calling the
+// API until they are older than ttl; e.g. so that sealing many secrets with the same policy makes one API call for the
+// public key and one for the policy document. Concurrent requests for an entry that is being fetched wait for the
+// first request to complete. Failed responses and conditional requests are not cached, and entries are separated by the
+// headers of the request and its context, e.g. the tenant set with [ContextWithHeaders]. The cache can be combined
+// with [WithDiskCache], which is consulted when an entry is not in memory.
+func WithCache(ttl time.Duration) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding memory cache", "ttl", ttl)
+		if ttl <= 0 {
+			return fmt.Errorf("cache ttl must be positive: %w", ErrInvalidOption)
+		}
+		c.memoryCacheTTL = ttl
+		return nil
+	}
+}
+
+// Implements a RoundTripper that returns public keys and secret policy documents from memory when possible.
+type memoryCache struct {
+	// The RoundTripper that calls the API.
+	base http.RoundTripper
+	// The time an entry is returned without calling the API.
+	ttl time.Duration
+	// The maximum number of bytes of a response body that are stored.
+	maxResponseSize int64
+	// The logger of the client.
+	logger *slog.Logger
+	// Guards the entries, which are keyed by request URI and headers; see cacheKey.
+	mu      sync.Mutex
+	entries map[string]*memoryCacheEntry
+}
+
+// A response body in the memory cache, or a response that is being fetched.
+type memoryCacheEntry struct {
+	// Closed when the response has been fetched, after which the other fields do not change.
+	ready chan struct{}
+	// The body of a successful response, or nil if the response was not successful.
+	body []byte
+	// The time the response was stored.
+	storedAt time.Time
+}
+
+// Implements RoundTripper by returning a fresh entry from memory, waiting for an entry that is being fetched by another
+// request, or by calling the API and storing a successful response in memory.
+func (c *memoryCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isCacheable(req) {
+		return c.base.RoundTrip(req) //nolint:wrapcheck // Errors are returned unchanged by the cache
+	}
+	name := cacheKey(req)
+	for {
+		c.mu.Lock()
+		entry, ok := c.entries[name]
+		if !ok || (isClosed(entry.ready) && (entry.body == nil || time.Since(entry.storedAt) >= c.ttl)) {
+			entry = &memoryCacheEntry{ready: make(chan struct{})}
+			c.entries[name] = entry
+			c.mu.Unlock()
+			return c.fetch(req, name, entry)
+		}
+		c.mu.Unlock()
+		select {
+		case <-req.Context().Done():
+			return nil, fmt.Errorf("request canceled while waiting for cache: %w", req.Context().Err())
+		case <-entry.ready:
+		}
+		if entry.body != nil && time.Since(entry.storedAt) < c.ttl {
+			c.logger.Debug("Returning response from memory cache", "name", name)
+			return cachedResponse(req, entry.body, cacheHit), nil
+		}
+	}
+}
+
+// Calls the API and completes the entry, which is removed from the cache unless the response is successful.
+func (c *memoryCache) fetch(req *http.Request, name string, entry *memoryCacheEntry) (*http.Response, error) {
+	defer close(entry.ready)
+	resp, err := c.base.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusOK {
+		body, readErr := readAllLimit(resp.Body, c.maxResponseSize)
+		_ = resp.Body.Close()
+		if readErr != nil {
+			err = readErr
+			resp = nil
+		} else {
+			entry.body = body
+			entry.storedAt = time.Now()
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			resp.Header.Set(CacheHeader, cacheMiss)
+		}
+	}
+	if entry.body == nil {
+		c.mu.Lock()
+		if c.entries[name] == entry {
+			delete(c.entries, name)
+		}
+		c.mu.Unlock()
+	}
+	return resp, err //nolint:wrapcheck // Errors are returned unchanged by the cache
+}
+
+// Forward CloseIdleConnections to the base RoundTripper.
+func (c *memoryCache) CloseIdleConnections() {
+	if closer, ok := c.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// Returns the base RoundTripper, so that the transport of the client can be found.
+func (c *memoryCache) Unwrap() http.RoundTripper {
+	return c.base
+}
+
+// Returns true if the channel is closed.
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
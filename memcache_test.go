@@ -0,0 +1,87 @@
+package f5xc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+)
+
+// Verify that concurrent requests for a public key are served by one API call, and that entries expire after the ttl.
+func TestWithCache(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	client, _ := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{Data: f5xc.PublicKey{KeyVersion: 1, Tenant: "test"}})
+	}), f5xc.WithCache(500*time.Millisecond))
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			publicKey, err := f5xc.GetPublicKey(context.Background(), client, nil)
+			switch {
+			case err != nil:
+				t.Errorf("GetPublicKey raised an unexpected error: %v", err)
+			case publicKey == nil || publicKey.Tenant != "test":
+				t.Errorf("Expected public key for tenant test, got %+v", publicKey)
+			}
+		}()
+	}
+	wg.Wait()
+	if count := requests.Load(); count != 1 {
+		t.Errorf("Expected 1 request, got %d", count)
+	}
+	version := 2
+	if _, err := f5xc.GetPublicKey(context.Background(), client, &version); err != nil {
+		t.Fatalf("GetPublicKey raised an unexpected error: %v", err)
+	}
+	if count := requests.Load(); count != 2 {
+		t.Errorf("Expected a request for a different version, got %d requests", count)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if _, err := f5xc.GetPublicKey(context.Background(), client, nil); err != nil {
+		t.Fatalf("GetPublicKey raised an unexpected error: %v", err)
+	}
+	if count := requests.Load(); count != 3 {
+		t.Errorf("Expected a request after the entry expired, got %d requests", count)
+	}
+}
+
+// Verify that entries are separated by the headers of the request context, and that conditional requests are not
+// answered from memory.
+func TestWithCache_Tenants(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	client, serverURL := testAPIClient(t, testTenantCacheHandler(&requests), f5xc.WithCache(time.Hour))
+	testCacheTenants(t, client, serverURL, &requests)
+}
+
+// Verify that failed responses are not cached, and that an invalid ttl is rejected.
+func TestWithCache_Failure(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	client, _ := testAPIClient(t, testRetryHandler(&requests, 1, http.StatusServiceUnavailable, ""), f5xc.WithCache(time.Minute))
+	if _, err := f5xc.GetSecretPolicyDocument(context.Background(), client, "test", "shared"); !errors.Is(err, f5xc.ErrUnexpectedHTTPStatus) {
+		t.Errorf("Expected GetSecretPolicyDocument to raise %v, got %v", f5xc.ErrUnexpectedHTTPStatus, err)
+	}
+	for range 2 {
+		if _, err := f5xc.GetSecretPolicyDocument(context.Background(), client, "test", "shared"); err != nil {
+			t.Errorf("GetSecretPolicyDocument raised an unexpected error: %v", err)
+		}
+	}
+	if count := requests.Load(); count != 2 {
+		t.Errorf("Expected 2 requests, got %d", count)
+	}
+	if _, err := f5xc.NewClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"), f5xc.WithCache(0)); !errors.Is(err, f5xc.ErrInvalidOption) {
+		t.Errorf("Expected NewClient to raise %v, got %v", f5xc.ErrInvalidOption, err)
+	}
+}
@@ -0,0 +1,151 @@
+package f5xc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// The namespace and subsystem of the metrics recorded by a client created with [WithMetrics].
+	metricsNamespace = "f5xc"
+	metricsSubsystem = "api"
+	// The endpoint label of requests to paths that do not match a known API endpoint.
+	otherEndpoint = "other"
+	// The class label of a request that failed without a response.
+	transportErrorClass = "transport"
+)
+
+// Record Prometheus metrics for every request sent by the client, including each retry, and register them with the
+// registerer. The metrics are a counter of requests, a counter of failed requests by class, i.e. 4xx, 5xx, or
+// transport if a response was not received, and a histogram of request latency in seconds. Each metric is labeled by
+// the HTTP method and the endpoint path template, e.g. /api/secret_management/get_public_key; requests to paths that
+// are not a known endpoint are labeled as other. Clients can share a registerer, in which case they share the metrics.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding metrics")
+		if registerer == nil {
+			return fmt.Errorf("metrics registerer must not be nil: %w", ErrInvalidOption)
+		}
+		metrics, err := newClientMetrics(registerer)
+		if err != nil {
+			return err
+		}
+		c.metrics = metrics
+		return nil
+	}
+}
+
+// The Prometheus metrics recorded for a client.
+type clientMetrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// Returns the client metrics registered with the registerer, reusing metrics that have already been registered by
+// another client.
+func newClientMetrics(registerer prometheus.Registerer) (*clientMetrics, error) {
+	labels := []string{"method", "endpoint"}
+	requests, err := register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "requests_total",
+		Help:      "The number of requests sent to the F5 Distributed Cloud API.",
+	}, labels))
+	if err != nil {
+		return nil, err
+	}
+	failures, err := register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "request_errors_total",
+		Help:      "The number of requests to the F5 Distributed Cloud API that failed, by class of failure.",
+	}, append(labels, "class")))
+	if err != nil {
+		return nil, err
+	}
+	duration, err := register(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "request_duration_seconds",
+		Help:      "The time taken to receive the response to a request sent to the F5 Distributed Cloud API.",
+		Buckets:   prometheus.DefBuckets,
+	}, labels))
+	if err != nil {
+		return nil, err
+	}
+	return &clientMetrics{
+		requests: requests,
+		errors:   failures,
+		duration: duration,
+	}, nil
+}
+
+// Registers the collector, returning the collector that is already registered if it is the same metric.
+func register[T prometheus.Collector](registerer prometheus.Registerer, collector T) (T, error) {
+	err := registerer.Register(collector)
+	var registered prometheus.AlreadyRegisteredError
+	switch {
+	case err == nil:
+		return collector, nil
+	case errors.As(err, &registered):
+		if existing, ok := registered.ExistingCollector.(T); ok {
+			return existing, nil
+		}
+	}
+	return collector, fmt.Errorf("failed to register metrics: %w", err)
+}
+
+// Returns the API endpoint path template that matches the request path, or other if the path is not a known endpoint.
+func endpointTemplate(requestPath string) string {
+	if requestPath == PublicKeyURL || requestPath == WhoAmIURL {
+		return requestPath
+	}
+	if matched, _ := path.Match(fmt.Sprintf(SecretPolicyDocumentURL, "*", "*"), requestPath); matched {
+		return fmt.Sprintf(SecretPolicyDocumentURL, "{namespace}", "{name}")
+	}
+	return otherEndpoint
+}
+
+// Implements a RoundTripper that records metrics for every request sent by the base RoundTripper.
+type metricsTransport struct {
+	// The RoundTripper that calls the API.
+	base http.RoundTripper
+	// The metrics to record.
+	metrics *clientMetrics
+}
+
+// Implements RoundTripper by sending the request with the base RoundTripper and recording the outcome and latency.
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := endpointTemplate(req.URL.Path)
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	t.metrics.requests.WithLabelValues(req.Method, endpoint).Inc()
+	t.metrics.duration.WithLabelValues(req.Method, endpoint).Observe(time.Since(start).Seconds())
+	switch {
+	case err != nil:
+		t.metrics.errors.WithLabelValues(req.Method, endpoint, transportErrorClass).Inc()
+		return nil, err //nolint:wrapcheck // Errors are returned unchanged by the metrics transport
+	case resp.StatusCode >= http.StatusBadRequest:
+		t.metrics.errors.WithLabelValues(req.Method, endpoint, strconv.Itoa(resp.StatusCode/100)+"xx").Inc()
+	}
+	return resp, nil
+}
+
+// Forward CloseIdleConnections to the base RoundTripper.
+func (t *metricsTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// Returns the base RoundTripper, so that the transport of the client can be found.
+func (t *metricsTransport) Unwrap() http.RoundTripper {
+	return t.base
+}
@@ -0,0 +1,75 @@
+package f5xc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/memes/f5xc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Verify that a client with metrics counts requests and failures by endpoint template and class, and that clients can
+// share a registry.
+func TestWithMetrics(t *testing.T) {
+	t.Parallel()
+	registry := prometheus.NewRegistry()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case f5xc.PublicKeyURL:
+			_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{Data: f5xc.PublicKey{KeyVersion: 1}})
+		case fmt.Sprintf(f5xc.SecretPolicyDocumentURL, "shared", "denied"):
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	client, _ := testAPIClient(t, handler, f5xc.WithMetrics(registry))
+	other, _ := testAPIClient(t, handler, f5xc.WithMetrics(registry))
+	ctx := context.Background()
+	if _, err := f5xc.GetPublicKey(ctx, client, nil); err != nil {
+		t.Fatalf("GetPublicKey raised an unexpected error: %v", err)
+	}
+	if _, err := f5xc.GetPublicKey(ctx, other, nil); err != nil {
+		t.Fatalf("GetPublicKey raised an unexpected error: %v", err)
+	}
+	if _, err := f5xc.GetSecretPolicyDocument(ctx, client, "denied", "shared"); !errors.Is(err, f5xc.ErrForbidden) {
+		t.Fatalf("Expected GetSecretPolicyDocument to raise %v, got %v", f5xc.ErrForbidden, err)
+	}
+	if _, err := f5xc.GetSecretPolicyDocument(ctx, client, "unavailable", "shared"); !errors.Is(err, f5xc.ErrUnexpectedHTTPStatus) {
+		t.Fatalf("Expected GetSecretPolicyDocument to raise %v, got %v", f5xc.ErrUnexpectedHTTPStatus, err)
+	}
+	expected := `
+# HELP f5xc_api_request_errors_total The number of requests to the F5 Distributed Cloud API that failed, by class of failure.
+# TYPE f5xc_api_request_errors_total counter
+f5xc_api_request_errors_total{class="4xx",endpoint="/api/secret_management/namespaces/{namespace}/secret_policys/{name}/get_policy_document",method="GET"} 1
+f5xc_api_request_errors_total{class="5xx",endpoint="/api/secret_management/namespaces/{namespace}/secret_policys/{name}/get_policy_document",method="GET"} 1
+# HELP f5xc_api_requests_total The number of requests sent to the F5 Distributed Cloud API.
+# TYPE f5xc_api_requests_total counter
+f5xc_api_requests_total{endpoint="/api/secret_management/get_public_key",method="GET"} 2
+f5xc_api_requests_total{endpoint="/api/secret_management/namespaces/{namespace}/secret_policys/{name}/get_policy_document",method="GET"} 2
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "f5xc_api_requests_total", "f5xc_api_request_errors_total"); err != nil {
+		t.Error(err)
+	}
+	if count := testutil.CollectAndCount(registry, "f5xc_api_request_duration_seconds"); count != 2 {
+		t.Errorf("Expected 2 latency histograms, got %d", count)
+	}
+}
+
+// Verify that a nil registerer, and a registerer with conflicting metrics, are rejected.
+func TestWithMetrics_Invalid(t *testing.T) {
+	t.Parallel()
+	conflicting := prometheus.NewRegistry()
+	conflicting.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "f5xc_api_requests_total", Help: "Conflicting"}))
+	for name, registerer := range map[string]prometheus.Registerer{"nil": nil, "conflicting": conflicting} {
+		if _, err := f5xc.NewClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"), f5xc.WithMetrics(registerer)); err == nil {
+			t.Errorf("Expected NewClient to raise an error for %s registerer", name)
+		}
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 // The partial URL to fetch PolicyDocument from F5 Distributed Cloud.
@@ -33,9 +36,20 @@ type SecretPolicyDocument struct {
 	PolicyInfo SecretPolicyInfo `json:"policy_info" yaml:"policyInfo"`
 }
 
-// Returns a SecretPolicyDocument from the F5 Distributed Cloud API endpoint for Secrets Management, or an error.
-func GetSecretPolicyDocument(ctx context.Context, client *http.Client, name, namespace string) (*SecretPolicyDocument, error) {
-	logger := slog.With("name", name, "namespace", namespace)
+// Returns a SecretPolicyDocument from the F5 Distributed Cloud API endpoint for Secrets Management, or an error. If
+// namespace is empty the namespace set on the context with [WithNamespace] is used.
+func GetSecretPolicyDocument(ctx context.Context, client Doer, name, namespace string) (*SecretPolicyDocument, error) {
+	logger := clientLogger(client)
+	req, err := newSecretPolicyDocumentRequest(ctx, logger, name, resolveNamespace(ctx, logger, namespace))
+	if err != nil {
+		return nil, err
+	}
+	return EnvelopeAPICall[SecretPolicyDocument](client, req)
+}
+
+// Returns a request for the named SecretPolicyDocument in the namespace.
+func newSecretPolicyDocumentRequest(ctx context.Context, logger *slog.Logger, name, namespace string) (*http.Request, error) {
+	logger = logger.With("name", name, "namespace", namespace)
 	logger.Debug("Retrieving Policy Document")
 	url := fmt.Sprintf(SecretPolicyDocumentURL, namespace, name)
 	logger.Debug("Generated API URL", "url", url)
@@ -43,5 +57,108 @@ func GetSecretPolicyDocument(ctx context.Context, client *http.Client, name, nam
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request for PolicyDocument: %w", err)
 	}
-	return EnvelopeAPICall[SecretPolicyDocument](client, req)
+	return req, nil
+}
+
+// PolicyChange describes a field that differs between two secret policy documents. Field is the path of the field,
+// using the JSON names of the API, e.g. policy_info.rules[0].action.
+type PolicyChange struct {
+	Field string `json:"field" yaml:"field"`
+	Old   string `json:"old" yaml:"old"`
+	New   string `json:"new" yaml:"new"`
+}
+
+// String returns a description of the change in the form FIELD: "OLD" -> "NEW".
+func (c PolicyChange) String() string {
+	return c.Field + ": " + strconv.Quote(c.Old) + " -> " + strconv.Quote(c.New)
+}
+
+// Equal returns true if the secret policy documents have the same metadata, policy ID, and rendered policy. A nil
+// document is equal to an empty document.
+func (d *SecretPolicyDocument) Equal(other *SecretPolicyDocument) bool {
+	return len(d.Diff(other)) == 0
+}
+
+// Diff returns the changes that turn the secret policy document into other, in a stable order, or an empty slice if the
+// documents are equal. A nil document is treated as an empty document, so the changes from a nil document describe
+// every field that is set in other. Nil and empty matchers and selectors are equal.
+func (d *SecretPolicyDocument) Diff(other *SecretPolicyDocument) []PolicyChange {
+	var changes policyChanges
+	old, updated := d.metadata(), other.metadata()
+	changes.add("metadata.name", old.Name, updated.Name)
+	changes.add("metadata.namespace", old.Namespace, updated.Namespace)
+	changes.add("metadata.tenant", old.Tenant, updated.Tenant)
+	oldDoc, updatedDoc := d.orEmpty(), other.orEmpty()
+	changes.add("policy_id", oldDoc.PolicyID, updatedDoc.PolicyID)
+	changes.add("policy_info.algo", oldDoc.PolicyInfo.Algo, updatedDoc.PolicyInfo.Algo)
+	oldRules, updatedRules := oldDoc.PolicyInfo.Rules, updatedDoc.PolicyInfo.Rules
+	changes.add("policy_info.rules.length", strconv.Itoa(len(oldRules)), strconv.Itoa(len(updatedRules)))
+	for i := range max(len(oldRules), len(updatedRules)) {
+		var oldRule, updatedRule SecretPolicyRule
+		if i < len(oldRules) {
+			oldRule = oldRules[i]
+		}
+		if i < len(updatedRules) {
+			updatedRule = updatedRules[i]
+		}
+		changes.addRule("policy_info.rules["+strconv.Itoa(i)+"]", oldRule, updatedRule)
+	}
+	return changes
+}
+
+// Returns the metadata of the document, or empty metadata if the document or its metadata is nil.
+func (d *SecretPolicyDocument) metadata() Metadata {
+	if d == nil || d.Metadata == nil {
+		return Metadata{}
+	}
+	return *d.Metadata
+}
+
+// Returns the document, or an empty document if it is nil.
+func (d *SecretPolicyDocument) orEmpty() *SecretPolicyDocument {
+	if d == nil {
+		return &SecretPolicyDocument{}
+	}
+	return d
+}
+
+// Accumulates the changes between two secret policy documents.
+type policyChanges []PolicyChange
+
+// Records a change to the field if the old and new values differ.
+func (c *policyChanges) add(field, old, updated string) {
+	if old != updated {
+		*c = append(*c, PolicyChange{Field: field, Old: old, New: updated})
+	}
+}
+
+// Records a change to the field if the old and new lists of values differ.
+func (c *policyChanges) addList(field string, old, updated []string) {
+	if !slices.Equal(old, updated) {
+		*c = append(*c, PolicyChange{Field: field, Old: strings.Join(old, ","), New: strings.Join(updated, ",")})
+	}
+}
+
+// Records the changes between two secret policy rules.
+func (c *policyChanges) addRule(field string, old, updated SecretPolicyRule) {
+	c.add(field+".action", old.Action, updated.Action)
+	c.add(field+".client_name", old.ClientName, updated.ClientName)
+	oldMatcher, updatedMatcher := MatcherType{}, MatcherType{}
+	if old.ClientNameMatcher != nil {
+		oldMatcher = *old.ClientNameMatcher
+	}
+	if updated.ClientNameMatcher != nil {
+		updatedMatcher = *updated.ClientNameMatcher
+	}
+	c.addList(field+".client_name_matcher.exact_values", oldMatcher.ExactValues, updatedMatcher.ExactValues)
+	c.addList(field+".client_name_matcher.regex_values", oldMatcher.RegexValues, updatedMatcher.RegexValues)
+	c.addList(field+".client_name_matcher.transformers", oldMatcher.Transformers, updatedMatcher.Transformers)
+	var oldExpressions, updatedExpressions []string
+	if old.ClientSelector != nil {
+		oldExpressions = old.ClientSelector.Expressions
+	}
+	if updated.ClientSelector != nil {
+		updatedExpressions = updated.ClientSelector.Expressions
+	}
+	c.addList(field+".client_selector.expressions", oldExpressions, updatedExpressions)
 }
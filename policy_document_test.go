@@ -3,6 +3,7 @@ package f5xc_test
 import (
 	"context"
 	"os"
+	"slices"
 	"testing"
 	"time"
 
@@ -37,3 +38,115 @@ func TestGetSecretPolicyDocument(t *testing.T) {
 		t.Errorf("GetSecretPolicyDocument returned nil")
 	}
 }
+
+// Verify that Diff reports the fields that differ between secret policy documents, and that Equal agrees.
+func TestSecretPolicyDocument_Diff(t *testing.T) {
+	t.Parallel()
+	base := &f5xc.SecretPolicyDocument{
+		Metadata: &f5xc.Metadata{Name: "app", Namespace: "shared", Tenant: "acme"},
+		PolicyID: "policy-1",
+		PolicyInfo: f5xc.SecretPolicyInfo{
+			Algo: "FIRST_MATCH",
+			Rules: []f5xc.SecretPolicyRule{
+				{
+					Action:            "ALLOW",
+					ClientNameMatcher: &f5xc.MatcherType{ExactValues: []string{"app"}},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		previous *f5xc.SecretPolicyDocument
+		current  *f5xc.SecretPolicyDocument
+		expected []f5xc.PolicyChange
+	}{
+		{
+			name:     "equal",
+			previous: base,
+			current:  base,
+		},
+		{
+			name: "nil",
+		},
+		{
+			name:     "empty-matchers",
+			previous: &f5xc.SecretPolicyDocument{PolicyInfo: f5xc.SecretPolicyInfo{Rules: []f5xc.SecretPolicyRule{{Action: "DENY"}}}},
+			current: &f5xc.SecretPolicyDocument{
+				Metadata: &f5xc.Metadata{},
+				PolicyInfo: f5xc.SecretPolicyInfo{
+					Rules: []f5xc.SecretPolicyRule{
+						{
+							Action:            "DENY",
+							ClientNameMatcher: &f5xc.MatcherType{ExactValues: []string{}},
+							ClientSelector:    &f5xc.LabelSelectorType{},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:     "deleted",
+			previous: base,
+			expected: []f5xc.PolicyChange{
+				{Field: "metadata.name", Old: "app"},
+				{Field: "metadata.namespace", Old: "shared"},
+				{Field: "metadata.tenant", Old: "acme"},
+				{Field: "policy_id", Old: "policy-1"},
+				{Field: "policy_info.algo", Old: "FIRST_MATCH"},
+				{Field: "policy_info.rules.length", Old: "1", New: "0"},
+				{Field: "policy_info.rules[0].action", Old: "ALLOW"},
+				{Field: "policy_info.rules[0].client_name_matcher.exact_values", Old: "app"},
+			},
+		},
+		{
+			name:     "rule-added",
+			previous: base,
+			current: &f5xc.SecretPolicyDocument{
+				Metadata: base.Metadata,
+				PolicyID: "policy-2",
+				PolicyInfo: f5xc.SecretPolicyInfo{
+					Algo: "FIRST_MATCH",
+					Rules: []f5xc.SecretPolicyRule{
+						{
+							Action:            "ALLOW",
+							ClientNameMatcher: &f5xc.MatcherType{ExactValues: []string{"app", "worker"}},
+						},
+						{
+							Action:         "DENY",
+							ClientSelector: &f5xc.LabelSelectorType{Expressions: []string{"env in (dev)"}},
+						},
+					},
+				},
+			},
+			expected: []f5xc.PolicyChange{
+				{Field: "policy_id", Old: "policy-1", New: "policy-2"},
+				{Field: "policy_info.rules.length", Old: "1", New: "2"},
+				{Field: "policy_info.rules[0].client_name_matcher.exact_values", Old: "app", New: "app,worker"},
+				{Field: "policy_info.rules[1].action", New: "DENY"},
+				{Field: "policy_info.rules[1].client_selector.expressions", New: "env in (dev)"},
+			},
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			changes := tst.previous.Diff(tst.current)
+			if !slices.Equal(changes, tst.expected) {
+				t.Errorf("Expected changes %v, got %v", tst.expected, changes)
+			}
+			if equal := tst.previous.Equal(tst.current); equal != (len(tst.expected) == 0) {
+				t.Errorf("Expected Equal to return %t, got %t", len(tst.expected) == 0, equal)
+			}
+		})
+	}
+}
+
+// Verify that a change is described with quoted values.
+func TestPolicyChange_String(t *testing.T) {
+	t.Parallel()
+	change := f5xc.PolicyChange{Field: "policy_id", Old: "policy-1", New: "policy-2"}
+	if expected := `policy_id: "policy-1" -> "policy-2"`; change.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, change.String())
+	}
+}
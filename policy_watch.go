@@ -0,0 +1,73 @@
+package f5xc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The interval between requests made by WatchPolicyDocument when one is not given.
+const DefaultPolicyWatchInterval = time.Minute
+
+// PolicyChangeFunc is called by [WatchPolicyDocument] when the secret policy document changes, with the previous and
+// current documents and the changes between them. The current document is nil if the policy has been deleted.
+type PolicyChangeFunc func(previous, current *SecretPolicyDocument, changes []PolicyChange)
+
+// WatchPolicyDocument retrieves the named secret policy document, and then retrieves it again whenever the interval
+// elapses, calling onChange each time the document differs from the previous one; e.g. so that a pipeline can
+// invalidate cached policy documents and reseal data when a policy is edited. If interval is not positive
+// [DefaultPolicyWatchInterval] is used. An error is returned if the document cannot be retrieved at first; later
+// failures are logged and retried at the next interval. Requests are conditional on the ETag of the previous response,
+// so that an unchanged document is not downloaded again. The function returns nil when the context is canceled.
+func WatchPolicyDocument(ctx context.Context, client Doer, name, namespace string, interval time.Duration, onChange PolicyChangeFunc) error {
+	if interval <= 0 {
+		interval = DefaultPolicyWatchInterval
+	}
+	logger := clientLogger(client).With("name", name, "namespace", namespace, "interval", interval)
+	logger.Debug("Watching policy document")
+	namespace = resolveNamespace(ctx, logger, namespace)
+	previous, etag, err := getPolicyDocumentIfChanged(ctx, client, name, namespace, "")
+	if err != nil {
+		return fmt.Errorf("failed to retrieve policy document: %w", err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Debug("Policy document watch is exiting")
+			return nil
+		case <-ticker.C:
+			current, currentETag, err := getPolicyDocumentIfChanged(ctx, client, name, namespace, etag)
+			switch {
+			case errors.Is(err, ErrNotModified):
+				logger.Debug("Policy document has not been modified")
+				continue
+			case err != nil:
+				if ctx.Err() == nil {
+					logger.Warn("Failed to retrieve policy document, will retry", "error", err)
+				}
+				continue
+			}
+			etag = currentETag
+			changes := previous.Diff(current)
+			if len(changes) == 0 {
+				continue
+			}
+			logger.Info("Policy document has changed", "changes", len(changes))
+			onChange(previous, current, changes)
+			previous = current
+		}
+	}
+}
+
+// Returns the named secret policy document and its ETag, or an error that wraps ErrNotModified if the document has not
+// changed since the version identified by etag.
+func getPolicyDocumentIfChanged(ctx context.Context, client Doer, name, namespace, etag string) (*SecretPolicyDocument, string, error) {
+	req, err := newSecretPolicyDocumentRequest(ctx, clientLogger(client), name, namespace)
+	if err != nil {
+		return nil, "", err
+	}
+	return ConditionalEnvelopeAPICall[SecretPolicyDocument](client, req, etag)
+}
@@ -0,0 +1,114 @@
+package f5xc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+)
+
+// Verify that WatchPolicyDocument reports each change to a policy document, ignores unchanged responses and transient
+// failures, and returns when the context is canceled.
+func TestWatchPolicyDocument(t *testing.T) {
+	t.Parallel()
+	// The policy ID returned by each request; an empty ID is returned as a server error.
+	responses := []string{"policy-1", "policy-1", "", "policy-2", "policy-2"}
+	var requests atomic.Int32
+	client, _ := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		policyID := responses[min(int(requests.Add(1))-1, len(responses)-1)]
+		if policyID == "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.SecretPolicyDocument]{
+			Data: f5xc.SecretPolicyDocument{PolicyID: policyID},
+		})
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	type change struct {
+		previous string
+		current  string
+		changes  []f5xc.PolicyChange
+	}
+	changes := make(chan change, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- f5xc.WatchPolicyDocument(ctx, client, "test", "shared", 10*time.Millisecond, func(previous, current *f5xc.SecretPolicyDocument, c []f5xc.PolicyChange) {
+			changes <- change{previous: previous.PolicyID, current: current.PolicyID, changes: c}
+		})
+	}()
+	select {
+	case c := <-changes:
+		expected := []f5xc.PolicyChange{{Field: "policy_id", Old: "policy-1", New: "policy-2"}}
+		if c.previous != "policy-1" || c.current != "policy-2" || len(c.changes) != 1 || c.changes[0] != expected[0] {
+			t.Errorf("Unexpected change %+v", c)
+		}
+	case err := <-done:
+		t.Fatalf("WatchPolicyDocument returned before a change was reported: %v", err)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("WatchPolicyDocument raised an unexpected error: %v", err)
+	}
+	select {
+	case c := <-changes:
+		t.Errorf("Unexpected additional change %+v", c)
+	default:
+	}
+}
+
+// Verify that WatchPolicyDocument sends the ETag of the previous response, so that an unchanged document is not
+// downloaded again, and reports a change after unchanged responses.
+func TestWatchPolicyDocument_ETag(t *testing.T) {
+	t.Parallel()
+	var version atomic.Value
+	var notModified atomic.Int32
+	version.Store("policy-1")
+	client, _ := testAPIClient(t, testETagHandler(&version, &notModified))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	changes := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- f5xc.WatchPolicyDocument(ctx, client, "test", "shared", 10*time.Millisecond, func(_, current *f5xc.SecretPolicyDocument, _ []f5xc.PolicyChange) {
+			changes <- current.PolicyID
+		})
+	}()
+	// The document is only changed once the watch has received unchanged responses
+	for notModified.Load() < 2 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	version.Store("policy-2")
+	select {
+	case current := <-changes:
+		if current != "policy-2" {
+			t.Errorf("Expected change to policy-2, got %s", current)
+		}
+	case err := <-done:
+		t.Fatalf("WatchPolicyDocument returned before a change was reported: %v", err)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("WatchPolicyDocument raised an unexpected error: %v", err)
+	}
+}
+
+// Verify that WatchPolicyDocument returns an error if the policy document cannot be retrieved at first.
+func TestWatchPolicyDocument_Error(t *testing.T) {
+	t.Parallel()
+	client, _ := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	err := f5xc.WatchPolicyDocument(context.Background(), client, "test", "shared", time.Millisecond, func(_, _ *f5xc.SecretPolicyDocument, _ []f5xc.PolicyChange) {
+		t.Error("Unexpected call to onChange")
+	})
+	if !errors.Is(err, f5xc.ErrForbidden) {
+		t.Errorf("Expected WatchPolicyDocument to raise %v, got %v", f5xc.ErrForbidden, err)
+	}
+}
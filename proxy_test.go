@@ -0,0 +1,75 @@
+package f5xc_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/memes/f5xc"
+)
+
+// Returns a proxy server that tunnels CONNECT requests to their target, and sends the Proxy-Authorization header of
+// each CONNECT request to the channel.
+func testProxy(t *testing.T, authorizations chan<- string) *httptest.Server {
+	t.Helper()
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		authorizations <- r.Header.Get("Proxy-Authorization")
+		target, err := (&net.Dialer{}).DialContext(r.Context(), "tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			_ = target.Close()
+			return
+		}
+		go func() {
+			_, _ = io.Copy(target, conn)
+			_ = target.Close()
+		}()
+		_, _ = io.Copy(conn, target)
+		_ = conn.Close()
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy
+}
+
+// Verify that a client with a proxy URL tunnels requests through the proxy, sending credentials from the URL.
+func TestWithProxyURL(t *testing.T) {
+	t.Parallel()
+	authorizations := make(chan string, 1)
+	proxy := testProxy(t, authorizations)
+	proxyURL := "http://user:pass@" + proxy.Listener.Addr().String()
+	client, _ := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{Data: f5xc.PublicKey{KeyVersion: 1}})
+	}), f5xc.WithProxyURL(proxyURL))
+	if _, err := f5xc.GetPublicKey(context.Background(), client, nil); err != nil {
+		t.Fatalf("GetPublicKey raised an unexpected error: %v", err)
+	}
+	expected := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	if authorization := <-authorizations; authorization != expected {
+		t.Errorf("Expected Proxy-Authorization %q, got %q", expected, authorization)
+	}
+}
+
+// Verify that invalid proxy URLs are rejected.
+func TestWithProxyURL_Invalid(t *testing.T) {
+	t.Parallel()
+	for _, proxy := range []string{"", "://proxy", "ftp://proxy:21", "http://"} {
+		if _, err := f5xc.NewClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"), f5xc.WithProxyURL(proxy)); !errors.Is(err, f5xc.ErrInvalidOption) {
+			t.Errorf("Expected NewClient to raise %v for %q, got %v", f5xc.ErrInvalidOption, proxy, err)
+		}
+	}
+}
@@ -2,14 +2,27 @@ package f5xc
 
 import (
 	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math/big"
 	"net/http"
 )
 
 // The partial URL to fetch a PublicKey from F5 Distributed Cloud.
 const PublicKeyURL = "/api/secret_management/get_public_key"
 
+// The minimum size, in bits, of the RSA modulus of a valid PublicKey.
+const MinPublicKeyBits = 2048
+
+// Returned when a PublicKey does not hold a usable RSA public key.
+var ErrInvalidPublicKey = errors.New("invalid public key")
+
 // Represents an F5XC Public Key for authenticated account, as described at
 // https://docs.cloud.f5.com/docs/api/secret-management#operation/ves.io.schema.secret_management.CustomAPI.GetPublicKey.
 type PublicKey struct {
@@ -20,17 +33,86 @@ type PublicKey struct {
 }
 
 // Returns a PublicKey from the F5 Distributed Cloud API endpoint for Secrets Management, or an error.
-func GetPublicKey(ctx context.Context, client *http.Client, version *int) (*PublicKey, error) {
-	logger := slog.With("version", version)
+func GetPublicKey(ctx context.Context, client Doer, version *int) (*PublicKey, error) {
+	req, err := newPublicKeyRequest(ctx, clientLogger(client), version)
+	if err != nil {
+		return nil, err
+	}
+	return EnvelopeAPICall[PublicKey](client, req)
+}
+
+// Returns a request for the PublicKey with the version, or the current PublicKey if version is nil.
+func newPublicKeyRequest(ctx context.Context, logger *slog.Logger, version *int) (*http.Request, error) {
+	logger = logger.With("version", version)
 	logger.Debug("Retrieving Public Key")
 	url := PublicKeyURL
 	if version != nil {
-		url = fmt.Sprintf("%s?key_version=%d", PublicKeyURL, version)
+		url = fmt.Sprintf("%s?key_version=%d", PublicKeyURL, *version)
 	}
 	logger.Debug("Generated API URL", "url", url)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request for PublicKey: %w", err)
 	}
-	return EnvelopeAPICall[PublicKey](client, req)
+	return req, nil
+}
+
+// Returns the RSA public key described by the base64 encoded modulus and public exponent of the PublicKey.
+func (k *PublicKey) RSAPublicKey() (*rsa.PublicKey, error) {
+	modulus, err := decodeBase64(k.ModulusBase64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode modulus: %w: %w", err, ErrInvalidPublicKey)
+	}
+	exponent, err := decodeBase64(k.PublicExponentBase64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public exponent: %w: %w", err, ErrInvalidPublicKey)
+	}
+	e := new(big.Int).SetBytes(exponent)
+	switch {
+	case len(modulus) == 0:
+		return nil, fmt.Errorf("modulus is empty: %w", ErrInvalidPublicKey)
+	case !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 || e.Bit(0) == 0:
+		return nil, fmt.Errorf("public exponent must be an odd integer between 3 and 2^31-1: %w", ErrInvalidPublicKey)
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(modulus),
+		E: int(e.Int64()),
+	}, nil
+}
+
+// Validate returns an error that wraps [ErrInvalidPublicKey] if the PublicKey does not hold an RSA public key with a
+// modulus of at least [MinPublicKeyBits] bits, e.g. because the API response was corrupted or truncated.
+func (k *PublicKey) Validate() error {
+	pub, err := k.RSAPublicKey()
+	if err != nil {
+		return err
+	}
+	if bits := pub.N.BitLen(); bits < MinPublicKeyBits {
+		return fmt.Errorf("modulus has %d bits, at least %d are required: %w", bits, MinPublicKeyBits, ErrInvalidPublicKey)
+	}
+	return nil
+}
+
+// Fingerprint returns the hex encoded SHA-256 digest of the DER encoded PKIX form of the RSA public key, prefixed with
+// SHA256:, which identifies the key regardless of how the modulus and exponent were encoded by the API.
+func (k *PublicKey) Fingerprint() (string, error) {
+	pub, err := k.RSAPublicKey()
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal public key: %w: %w", err, ErrInvalidPublicKey)
+	}
+	digest := sha256.Sum256(der)
+	return "SHA256:" + hex.EncodeToString(digest[:]), nil
+}
+
+// Decodes base64 data with or without padding.
+func decodeBase64(s string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		data, err = base64.RawStdEncoding.DecodeString(s)
+	}
+	return data, err //nolint:wrapcheck // Error is wrapped by caller
 }
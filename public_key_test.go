@@ -2,7 +2,13 @@ package f5xc_test
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -37,3 +43,113 @@ func TestGetPublicKey(t *testing.T) {
 		t.Errorf("GetPublicKey returned nil")
 	}
 }
+
+// Returns a PublicKey that holds a new RSA public key with a modulus of the given size.
+func testRSAPublicKey(t *testing.T, bits int) *f5xc.PublicKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	return &f5xc.PublicKey{
+		KeyVersion:           1,
+		ModulusBase64:        base64.StdEncoding.EncodeToString(key.N.Bytes()),
+		PublicExponentBase64: base64.StdEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		Tenant:               "test",
+	}
+}
+
+// Verify that Validate accepts RSA public keys of a usable size and rejects corrupt or small keys.
+func TestPublicKey_Validate(t *testing.T) {
+	t.Parallel()
+	valid := testRSAPublicKey(t, 2048)
+	tests := []struct {
+		name          string
+		pubKey        *f5xc.PublicKey
+		expectedError error
+	}{
+		{
+			name:   "valid",
+			pubKey: valid,
+		},
+		{
+			name: "unpadded",
+			pubKey: &f5xc.PublicKey{
+				ModulusBase64:        strings.TrimRight(valid.ModulusBase64, "="),
+				PublicExponentBase64: "AQAB",
+			},
+		},
+		{
+			name:          "empty",
+			pubKey:        &f5xc.PublicKey{},
+			expectedError: f5xc.ErrInvalidPublicKey,
+		},
+		{
+			name:          "small",
+			pubKey:        testRSAPublicKey(t, 1024),
+			expectedError: f5xc.ErrInvalidPublicKey,
+		},
+		{
+			name: "invalid-modulus",
+			pubKey: &f5xc.PublicKey{
+				ModulusBase64:        "not base64!",
+				PublicExponentBase64: "AQAB",
+			},
+			expectedError: f5xc.ErrInvalidPublicKey,
+		},
+		{
+			name: "even-exponent",
+			pubKey: &f5xc.PublicKey{
+				ModulusBase64:        valid.ModulusBase64,
+				PublicExponentBase64: "AQAA",
+			},
+			expectedError: f5xc.ErrInvalidPublicKey,
+		},
+		{
+			name: "missing-exponent",
+			pubKey: &f5xc.PublicKey{
+				ModulusBase64: valid.ModulusBase64,
+			},
+			expectedError: f5xc.ErrInvalidPublicKey,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			err := tst.pubKey.Validate()
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("Validate raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected Validate to raise %v, got %v", tst.expectedError, err)
+			}
+		})
+	}
+}
+
+// Verify that Fingerprint is stable across encodings of the same key and differs between keys.
+func TestPublicKey_Fingerprint(t *testing.T) {
+	t.Parallel()
+	pubKey := testRSAPublicKey(t, 2048)
+	fingerprint, err := pubKey.Fingerprint()
+	if err != nil {
+		t.Fatalf("Fingerprint raised an unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(fingerprint, "SHA256:") || len(fingerprint) != len("SHA256:")+64 {
+		t.Errorf("Unexpected fingerprint %q", fingerprint)
+	}
+	unpadded := &f5xc.PublicKey{
+		KeyVersion:           2,
+		ModulusBase64:        strings.TrimRight(pubKey.ModulusBase64, "="),
+		PublicExponentBase64: strings.TrimRight(pubKey.PublicExponentBase64, "="),
+	}
+	if other, err := unpadded.Fingerprint(); err != nil || other != fingerprint {
+		t.Errorf("Expected fingerprint %q for the unpadded key, got %q: %v", fingerprint, other, err)
+	}
+	if other, err := testRSAPublicKey(t, 2048).Fingerprint(); err != nil || other == fingerprint {
+		t.Errorf("Expected a different fingerprint for a different key, got %q: %v", other, err)
+	}
+	if _, err := (&f5xc.PublicKey{}).Fingerprint(); !errors.Is(err, f5xc.ErrInvalidPublicKey) {
+		t.Errorf("Expected Fingerprint to raise %v, got %v", f5xc.ErrInvalidPublicKey, err)
+	}
+}
@@ -0,0 +1,227 @@
+package f5xc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// The delay before the first retry of a request, unless changed in the [RetryPolicy].
+	DefaultRetryInitialDelay = 500 * time.Millisecond
+	// The maximum delay between retries of a request, unless changed in the [RetryPolicy].
+	DefaultRetryMaxDelay = 30 * time.Second
+	// The maximum number of bytes of a failed response body that are read so that the connection can be reused.
+	maxDrainBytes = 4096
+)
+
+// RetryPolicy defines how a client created by [NewClient] retries idempotent API requests that fail with a retryable
+// error or status; see [WithRetryPolicy].
+type RetryPolicy struct {
+	// The maximum number of times a request is retried after the first attempt; must be positive.
+	MaxRetries int
+	// The delay before the first retry, which doubles after each attempt; the default is [DefaultRetryInitialDelay].
+	InitialDelay time.Duration
+	// The maximum delay between attempts, including a delay requested by a Retry-After header; the default is
+	// [DefaultRetryMaxDelay].
+	MaxDelay time.Duration
+}
+
+// Retry idempotent requests, i.e. GET, HEAD, OPTIONS, PUT, and DELETE requests, and other requests that have an
+// Idempotency-Key header, that fail with a retryable error or a retryable status as reported by [IsRetryable] and
+// [RetryableStatus]. The delay between attempts grows exponentially from the initial delay of the policy, unless the
+// response has a longer Retry-After header, and is limited to the maximum delay of the policy. A request is not retried
+// once its context is done, or if its body cannot be replayed.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding retry policy", "maxRetries", policy.MaxRetries, "initialDelay", policy.InitialDelay, "maxDelay", policy.MaxDelay)
+		policy, err := policy.withDefaults()
+		if err != nil {
+			return err
+		}
+		c.retryPolicy = &policy
+		return nil
+	}
+}
+
+// Returns a copy of the policy with the default delays, or an error if the policy is invalid.
+func (policy RetryPolicy) withDefaults() (RetryPolicy, error) {
+	switch {
+	case policy.MaxRetries < 1:
+		return policy, fmt.Errorf("retry policy max retries must be positive: %w", ErrInvalidOption)
+	case policy.InitialDelay < 0 || policy.MaxDelay < 0:
+		return policy, fmt.Errorf("retry policy delays must not be negative: %w", ErrInvalidOption)
+	}
+	if policy.InitialDelay == 0 {
+		policy.InitialDelay = DefaultRetryInitialDelay
+	}
+	if policy.MaxDelay == 0 {
+		policy.MaxDelay = DefaultRetryMaxDelay
+	}
+	return policy, nil
+}
+
+// EnvelopeAPICallWithRetry is the same as [EnvelopeAPICall], except that an idempotent request is repeated according
+// to the policy when it fails with a temporary [*Error], e.g. a dropped connection or a 502, 503, or 504 response,
+// so that a client that does not use [WithRetryPolicy] can still tolerate transient failures of individual calls. The
+// delay between attempts grows exponentially from the initial delay of the policy with random jitter, and waiting
+// stops when the request context is done.
+func EnvelopeAPICallWithRetry[T EnvelopeAllowed](client Doer, req *http.Request, policy RetryPolicy) (*T, error) {
+	policy, err := policy.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	if !isIdempotent(req) || !replayable {
+		return EnvelopeAPICall[T](client, req)
+	}
+	ctx := req.Context()
+	logger := clientLogger(client)
+	delay := policy.InitialDelay
+	for attempt := 0; ; attempt++ {
+		attemptReq, err := cloneRequest(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+		result, err := EnvelopeAPICall[T](client, attemptReq)
+		if err == nil || attempt >= policy.MaxRetries || !retryableCallError(err) {
+			return result, err
+		}
+		// Equal jitter keeps at least half of the delay, so that concurrent callers do not retry in lockstep
+		wait := delay/2 + rand.N(delay/2+1) //nolint:gosec // The jitter does not need a secure source of randomness
+		logger.Debug("Retrying API call", "url", req.URL.Redacted(), "attempt", attempt+1, "delay", wait, "error", err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("request canceled while waiting to retry: %w", ctx.Err())
+		case <-timer.C:
+		}
+		delay = min(2*delay, policy.MaxDelay)
+	}
+}
+
+// Implements a RoundTripper that retries idempotent requests according to a RetryPolicy.
+type retryTransport struct {
+	// The RoundTripper that calls the API.
+	base http.RoundTripper
+	// The policy that decides how often and how long to wait.
+	policy RetryPolicy
+	// The logger of the client.
+	logger *slog.Logger
+}
+
+// Returns true if the request can be sent more than once without changing the result.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// Implements RoundTripper by sending the request, and repeating it after a delay while the request is idempotent, the
+// attempt failed with a retryable error or status, and the retry policy allows another attempt.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	if !isIdempotent(req) || !replayable {
+		return t.base.RoundTrip(req) //nolint:wrapcheck // Errors are returned unchanged by the retry transport
+	}
+	ctx := req.Context()
+	delay := t.policy.InitialDelay
+	for attempt := 0; ; attempt++ {
+		attemptReq, err := cloneRequest(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(attemptReq)
+		switch {
+		case attempt >= t.policy.MaxRetries:
+			return resp, err //nolint:wrapcheck // Errors are returned unchanged by the retry transport
+		case err != nil && !IsRetryable(err):
+			return nil, err //nolint:wrapcheck // Errors are returned unchanged by the retry transport
+		case err == nil && !RetryableStatus(resp.StatusCode):
+			return resp, nil
+		}
+		wait := delay
+		if err == nil {
+			wait = max(wait, retryAfter(resp.Header.Get("Retry-After")))
+			drainBody(resp)
+		}
+		wait = min(wait, t.policy.MaxDelay)
+		t.logger.Debug("Retrying API request", "url", req.URL.Redacted(), "attempt", attempt+1, "delay", wait, "error", err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("request canceled while waiting to retry: %w", ctx.Err())
+		case <-timer.C:
+		}
+		delay = min(2*delay, t.policy.MaxDelay)
+	}
+}
+
+// Forward CloseIdleConnections to the base RoundTripper.
+func (t *retryTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// Returns the base RoundTripper, so that the transport of the client can be found.
+func (t *retryTransport) Unwrap() http.RoundTripper {
+	return t.base
+}
+
+// Returns true if an API call of an idempotent request failed with a temporary error, or because the connection was
+// closed before a response was received, which is safe to repeat for an idempotent request.
+func retryableCallError(err error) bool {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Temporary || (apiErr.StatusCode == 0 && errors.Is(err, io.EOF))
+}
+
+// Returns the request for an attempt; the first attempt uses the original request, and later attempts a copy with a new
+// body so that the base RoundTripper can consume and close it.
+func cloneRequest(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 {
+		return req, nil
+	}
+	clone := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay request body: %w", err)
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+// Returns the delay requested by a Retry-After header value, which may be a number of seconds or an HTTP date, or zero
+// if the value is empty or invalid.
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+// Reads and discards a limited amount of the response body before closing it, so that the connection can be reused.
+func drainBody(resp *http.Response) {
+	_, _ = io.CopyN(io.Discard, resp.Body, maxDrainBytes)
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,288 @@
+package f5xc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+)
+
+// Returns a handler that fails the first failures requests with the status code and Retry-After header, then returns a
+// public key; every request is counted.
+func testRetryHandler(requests *atomic.Int32, failures int32, status int, retryAfter string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if requests.Add(1) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{
+			Data: f5xc.PublicKey{KeyVersion: 1, Tenant: "test"},
+		})
+	})
+}
+
+// Verify that a client with a retry policy repeats idempotent requests that fail with a retryable status, honoring the
+// Retry-After header, and gives up when the policy is exhausted.
+func TestWithRetryPolicy(t *testing.T) {
+	t.Parallel()
+	policy := f5xc.RetryPolicy{MaxRetries: 2, InitialDelay: time.Millisecond, MaxDelay: 2 * time.Second}
+	tests := []struct {
+		name             string
+		method           string
+		failures         int32
+		status           int
+		retryAfter       string
+		expectedStatus   int
+		expectedRequests int32
+		minElapsed       time.Duration
+	}{
+		{
+			name:             "success",
+			method:           http.MethodGet,
+			expectedStatus:   http.StatusOK,
+			expectedRequests: 1,
+		},
+		{
+			name:             "retried",
+			method:           http.MethodGet,
+			failures:         2,
+			status:           http.StatusServiceUnavailable,
+			expectedStatus:   http.StatusOK,
+			expectedRequests: 3,
+		},
+		{
+			name:             "retry-after",
+			method:           http.MethodGet,
+			failures:         1,
+			status:           http.StatusTooManyRequests,
+			retryAfter:       "1",
+			expectedStatus:   http.StatusOK,
+			expectedRequests: 2,
+			minElapsed:       time.Second,
+		},
+		{
+			name:             "exhausted",
+			method:           http.MethodGet,
+			failures:         3,
+			status:           http.StatusBadGateway,
+			expectedStatus:   http.StatusBadGateway,
+			expectedRequests: 3,
+		},
+		{
+			name:             "not-retryable-status",
+			method:           http.MethodGet,
+			failures:         1,
+			status:           http.StatusBadRequest,
+			expectedStatus:   http.StatusBadRequest,
+			expectedRequests: 1,
+		},
+		{
+			name:             "not-idempotent",
+			method:           http.MethodPost,
+			failures:         1,
+			status:           http.StatusServiceUnavailable,
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedRequests: 1,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			var requests atomic.Int32
+			client, serverURL := testAPIClient(t, testRetryHandler(&requests, tst.failures, tst.status, tst.retryAfter), f5xc.WithRetryPolicy(policy))
+			req, err := http.NewRequestWithContext(context.Background(), tst.method, serverURL+f5xc.PublicKeyURL, strings.NewReader("{}"))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			start := time.Now()
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Request raised an unexpected error: %v", err)
+			}
+			_ = resp.Body.Close()
+			if elapsed := time.Since(start); elapsed < tst.minElapsed {
+				t.Errorf("Expected request to take at least %v, took %v", tst.minElapsed, elapsed)
+			}
+			if resp.StatusCode != tst.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tst.expectedStatus, resp.StatusCode)
+			}
+			if count := requests.Load(); count != tst.expectedRequests {
+				t.Errorf("Expected %d requests, got %d", tst.expectedRequests, count)
+			}
+		})
+	}
+}
+
+// Verify that a retried request gives up when its context is done while waiting to retry.
+func TestWithRetryPolicy_Canceled(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	client, serverURL := testAPIClient(t, testRetryHandler(&requests, 1, http.StatusServiceUnavailable, "60"),
+		f5xc.WithRetryPolicy(f5xc.RetryPolicy{MaxRetries: 1, MaxDelay: time.Minute}))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, serverURL+f5xc.PublicKeyURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if _, err := f5xc.EnvelopeAPICall[f5xc.PublicKey](client, req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected EnvelopeAPICall to raise %v, got %v", context.DeadlineExceeded, err)
+	}
+	if count := requests.Load(); count != 1 {
+		t.Errorf("Expected 1 request, got %d", count)
+	}
+}
+
+// Verify that invalid retry policies are rejected.
+func TestWithRetryPolicy_Invalid(t *testing.T) {
+	t.Parallel()
+	for _, policy := range []f5xc.RetryPolicy{
+		{},
+		{MaxRetries: 1, InitialDelay: -time.Second},
+		{MaxRetries: 1, MaxDelay: -time.Second},
+	} {
+		_, err := f5xc.NewClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"), f5xc.WithRetryPolicy(policy))
+		if !errors.Is(err, f5xc.ErrInvalidOption) {
+			t.Errorf("Expected NewClient to raise %v for %+v, got %v", f5xc.ErrInvalidOption, policy, err)
+		}
+	}
+}
+
+// Returns a handler that closes the connection of the first failures requests without a response, then returns a
+// public key; every request is counted.
+func testDropHandler(t *testing.T, requests *atomic.Int32, failures int32) http.Handler {
+	t.Helper()
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if requests.Add(1) > failures {
+			_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{
+				Data: f5xc.PublicKey{KeyVersion: 1, Tenant: "test"},
+			})
+			return
+		}
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("Failed to hijack connection: %v", err)
+			return
+		}
+		_ = conn.Close()
+	})
+}
+
+// Verify that EnvelopeAPICallWithRetry repeats idempotent requests that fail with a temporary error, and returns other
+// errors immediately.
+func TestEnvelopeAPICallWithRetry(t *testing.T) {
+	t.Parallel()
+	policy := f5xc.RetryPolicy{MaxRetries: 2, InitialDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	tests := []struct {
+		name             string
+		method           string
+		handler          func(*atomic.Int32) http.Handler
+		expectedRequests int32
+		expectedErr      error
+	}{
+		{
+			name:             "success",
+			method:           http.MethodGet,
+			handler:          func(requests *atomic.Int32) http.Handler { return testRetryHandler(requests, 0, http.StatusOK, "") },
+			expectedRequests: 1,
+		},
+		{
+			name:   "gateway-errors",
+			method: http.MethodGet,
+			handler: func(requests *atomic.Int32) http.Handler {
+				return testRetryHandler(requests, 2, http.StatusBadGateway, "")
+			},
+			expectedRequests: 3,
+		},
+		{
+			name:             "dropped-connection",
+			method:           http.MethodGet,
+			handler:          func(requests *atomic.Int32) http.Handler { return testDropHandler(t, requests, 1) },
+			expectedRequests: 2,
+		},
+		{
+			name:   "exhausted",
+			method: http.MethodGet,
+			handler: func(requests *atomic.Int32) http.Handler {
+				return testRetryHandler(requests, 3, http.StatusGatewayTimeout, "")
+			},
+			expectedRequests: 3,
+			expectedErr:      f5xc.ErrUnexpectedHTTPStatus,
+		},
+		{
+			name:   "not-temporary",
+			method: http.MethodGet,
+			handler: func(requests *atomic.Int32) http.Handler {
+				return testRetryHandler(requests, 1, http.StatusInternalServerError, "")
+			},
+			expectedRequests: 1,
+			expectedErr:      f5xc.ErrUnexpectedHTTPStatus,
+		},
+		{
+			name:   "not-idempotent",
+			method: http.MethodPost,
+			handler: func(requests *atomic.Int32) http.Handler {
+				return testRetryHandler(requests, 1, http.StatusServiceUnavailable, "")
+			},
+			expectedRequests: 1,
+			expectedErr:      f5xc.ErrUnexpectedHTTPStatus,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			var requests atomic.Int32
+			client, serverURL := testAPIClient(t, tst.handler(&requests))
+			req, err := http.NewRequestWithContext(context.Background(), tst.method, serverURL+f5xc.PublicKeyURL, nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			publicKey, err := f5xc.EnvelopeAPICallWithRetry[f5xc.PublicKey](client, req, policy)
+			switch {
+			case tst.expectedErr != nil:
+				if !errors.Is(err, tst.expectedErr) {
+					t.Errorf("Expected EnvelopeAPICallWithRetry to raise %v, got %v", tst.expectedErr, err)
+				}
+			case err != nil:
+				t.Errorf("EnvelopeAPICallWithRetry raised an unexpected error: %v", err)
+			case publicKey == nil || publicKey.Tenant != "test":
+				t.Errorf("Expected public key for tenant test, got %+v", publicKey)
+			}
+			if count := requests.Load(); count != tst.expectedRequests {
+				t.Errorf("Expected %d requests, got %d", tst.expectedRequests, count)
+			}
+		})
+	}
+}
+
+// Verify that EnvelopeAPICallWithRetry stops waiting to retry when the request context is done, and rejects an invalid
+// policy.
+func TestEnvelopeAPICallWithRetry_Canceled(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	client, serverURL := testAPIClient(t, testRetryHandler(&requests, 1, http.StatusServiceUnavailable, ""))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, serverURL+f5xc.PublicKeyURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if _, err := f5xc.EnvelopeAPICallWithRetry[f5xc.PublicKey](client, req, f5xc.RetryPolicy{MaxRetries: 1, InitialDelay: time.Minute}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected EnvelopeAPICallWithRetry to raise %v, got %v", context.DeadlineExceeded, err)
+	}
+	if count := requests.Load(); count != 1 {
+		t.Errorf("Expected 1 request, got %d", count)
+	}
+	if _, err := f5xc.EnvelopeAPICallWithRetry[f5xc.PublicKey](client, req, f5xc.RetryPolicy{}); !errors.Is(err, f5xc.ErrInvalidOption) {
+		t.Errorf("Expected EnvelopeAPICallWithRetry to raise %v, got %v", f5xc.ErrInvalidOption, err)
+	}
+}
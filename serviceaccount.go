@@ -0,0 +1,46 @@
+package f5xc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+)
+
+const (
+	// The path of the service account token that Kubernetes, including F5XC vk8s, mounts into a pod.
+	KubernetesServiceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token" //nolint:gosec // Path, not a credential
+	// The authorization scheme of Kubernetes service account tokens.
+	bearerScheme = "Bearer"
+)
+
+// Implements an option that sets client authentication to use the service account token that is mounted into a pod
+// running in an F5XC vk8s namespace, disabling certificate based authentication, so that workloads can call the tenant
+// APIs without a PKCS#12 bundle or API token; see [WithKubernetesServiceAccountTokenFile].
+func WithKubernetesServiceAccount() Option {
+	return WithKubernetesServiceAccountTokenFile(KubernetesServiceAccountTokenFile)
+}
+
+// Implements an option that sets client authentication to use the Kubernetes service account token in the file as a
+// bearer token, disabling certificate based authentication. The file is read for every request, as Kubernetes rotates
+// projected service account tokens, and must be readable when the client is created.
+func WithKubernetesServiceAccountTokenFile(path string) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding Kubernetes service account token as authenticator", "path", path)
+		source := func(_ context.Context) (string, error) {
+			token, err := os.ReadFile(path)
+			if err != nil {
+				return "", fmt.Errorf("failed to read service account token %s: %w", path, err)
+			}
+			return string(bytes.TrimSpace(token)), nil
+		}
+		if _, err := source(context.Background()); err != nil {
+			return err
+		}
+		c.tokenSource = source
+		c.tokenScheme = bearerScheme
+		c.AuthToken = ""
+		c.Cert = nil
+		return nil
+	}
+}
@@ -0,0 +1,52 @@
+package f5xc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/memes/f5xc"
+)
+
+// Verify that a client with a Kubernetes service account sends the current token from the file as a bearer token.
+func TestWithKubernetesServiceAccountTokenFile(t *testing.T) {
+	t.Parallel()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("first-token\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write token: %v", err)
+	}
+	authorization := make(chan string, 1)
+	client, serverURL := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization <- r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}), f5xc.WithKubernetesServiceAccountTokenFile(tokenFile))
+	for _, token := range []string{"first-token", "rotated-token"} {
+		if err := os.WriteFile(tokenFile, []byte(token), 0o600); err != nil {
+			t.Fatalf("Failed to write token: %v", err)
+		}
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, serverURL+"/api/test", nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Request raised an unexpected error: %v", err)
+		}
+		_ = resp.Body.Close()
+		if header := <-authorization; header != "Bearer "+token {
+			t.Errorf("Expected Authorization header %q, got %q", "Bearer "+token, header)
+		}
+	}
+}
+
+// Verify that a client cannot be created if the service account token is missing, e.g. outside of a cluster.
+func TestWithKubernetesServiceAccountTokenFile_Missing(t *testing.T) {
+	t.Parallel()
+	_, err := f5xc.NewClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithKubernetesServiceAccountTokenFile(filepath.Join(t.TempDir(), "token")))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected NewClient to raise %v, got %v", os.ErrNotExist, err)
+	}
+}
@@ -0,0 +1,136 @@
+// Package systemdcreds writes unsealed blindfold secrets as systemd credentials, and reads the credentials that systemd
+// has passed to a service, so that bare-metal CE services can consume secrets through LoadCredential= and
+// $CREDENTIALS_DIRECTORY instead of ad hoc files.
+//
+// The credentials directory that systemd provides to a service is read-only, so unsealed secrets are written to a
+// credential store that LoadCredential= searches when a credential is declared without a path; /run/credstore by
+// default, which does not persist across reboots. A unit that runs before the consuming service can populate the
+// store, and the service declares the credential by name:
+//
+//	[Service]
+//	LoadCredential=db-password
+//	ExecStart=/usr/bin/app --password-file=${CREDENTIALS_DIRECTORY}/db-password
+//
+// Credentials are written with 0400 permissions in a 0700 directory, matching the expectations of systemd-creds.
+package systemdcreds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/memes/f5xc/wingman"
+)
+
+const (
+	// The environment variable that systemd sets to the directory of credentials passed to a service.
+	EnvCredentialsDirectory = "CREDENTIALS_DIRECTORY"
+	// The volatile credential store searched by LoadCredential= when a credential is declared without a path.
+	DefaultStore = "/run/credstore"
+	// The permissions of credential files.
+	FileMode = fs.FileMode(0o400)
+	// The permissions of a created credential store directory.
+	DirMode = fs.FileMode(0o700)
+	// The maximum length of a credential name, which must be a valid file name.
+	maxNameLength = 255
+)
+
+var (
+	// ErrInvalidName is returned when a credential name is not acceptable to systemd.
+	ErrInvalidName = errors.New("invalid credential name")
+	// ErrNoCredentialsDirectory is returned when reading a credential from a process that has not been passed any
+	// credentials by systemd.
+	ErrNoCredentialsDirectory = errors.New(EnvCredentialsDirectory + " is not set")
+)
+
+// ValidateName returns an error that wraps [ErrInvalidName] if the name cannot be used as a systemd credential name; a
+// name must be a valid file name that is not . or .., and cannot contain a path separator.
+func ValidateName(name string) error {
+	switch {
+	case name == "", name == ".", name == "..":
+		return fmt.Errorf("%q: %w", name, ErrInvalidName)
+	case len(name) > maxNameLength:
+		return fmt.Errorf("name is longer than %d bytes: %w", maxNameLength, ErrInvalidName)
+	case strings.ContainsAny(name, "/\x00"):
+		return fmt.Errorf("%q contains a path separator or NUL: %w", name, ErrInvalidName)
+	}
+	return nil
+}
+
+// Path returns the path of the named credential in the directory.
+func Path(dir, name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// Write atomically writes the data as the named credential in the store directory, creating the directory if
+// necessary. If dir is empty [DefaultStore] is used.
+func Write(dir, name string, data []byte) error {
+	if dir == "" {
+		dir = DefaultStore
+	}
+	path, err := Path(dir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, DirMode); err != nil {
+		return fmt.Errorf("failed to create credential store: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+name+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(FileMode); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to set credential permissions: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write credential: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close credential: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace credential: %w", err)
+	}
+	return nil
+}
+
+// Unseal has the Wingman client unseal the base64 encoded sealed data, and writes the result as the named credential
+// in the store directory. If dir is empty [DefaultStore] is used.
+func Unseal(ctx context.Context, client wingman.Client, dir, name string, sealed []byte) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	data, err := client.UnsealEncoded(ctx, sealed)
+	if err != nil {
+		return fmt.Errorf("failed to unseal credential %s: %w", name, err)
+	}
+	return Write(dir, name, data)
+}
+
+// Read returns the content of the named credential passed to the current process by systemd, equivalent to
+// systemd-creds cat. The getenv function is used to find the credentials directory, and should usually be [os.Getenv].
+func Read(getenv func(string) string, name string) ([]byte, error) {
+	dir := getenv(EnvCredentialsDirectory)
+	if dir == "" {
+		return nil, ErrNoCredentialsDirectory
+	}
+	path, err := Path(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credential: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,103 @@
+package systemdcreds_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc/systemdcreds"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+// Implements a wingman.Client that unseals by decoding base64 data.
+type testClient struct{}
+
+func (testClient) Ready(context.Context) error { return nil }
+
+func (testClient) Unseal(_ context.Context, sealed []byte) ([]byte, error) { return sealed, nil }
+
+func (testClient) UnsealEncoded(_ context.Context, sealed []byte) ([]byte, error) {
+	return base64.StdEncoding.AppendDecode(nil, sealed) //nolint:wrapcheck // Test client
+}
+
+func (testClient) Close() error { return nil }
+
+func (testClient) Shutdown(context.Context) error { return nil }
+
+// Verify that credential names are validated as systemd expects.
+func TestValidateName(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		expectedError error
+	}{
+		{name: "db-password"},
+		{name: "app.tls.key"},
+		{name: "", expectedError: systemdcreds.ErrInvalidName},
+		{name: ".", expectedError: systemdcreds.ErrInvalidName},
+		{name: "..", expectedError: systemdcreds.ErrInvalidName},
+		{name: "a/b", expectedError: systemdcreds.ErrInvalidName},
+		{name: "a\x00b", expectedError: systemdcreds.ErrInvalidName},
+		{name: strings.Repeat("a", 256), expectedError: systemdcreds.ErrInvalidName},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			err := systemdcreds.ValidateName(tst.name)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("ValidateName raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected ValidateName to raise %v, got %v", tst.expectedError, err)
+			}
+		})
+	}
+}
+
+// Verify that unsealed credentials are written to the store and can be read back through the credentials directory.
+func TestUnsealAndRead(t *testing.T) {
+	t.Parallel()
+	store := filepath.Join(t.TempDir(), "credstore")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// spell-checker: disable-next-line
+	if err := systemdcreds.Unseal(ctx, testClient{}, store, "db-password", []byte("cGFzc3dvcmQ=")); err != nil {
+		t.Fatalf("Unseal raised an unexpected error: %v", err)
+	}
+	if err := systemdcreds.Unseal(ctx, testClient{}, store, "../escape", []byte("")); !errors.Is(err, systemdcreds.ErrInvalidName) {
+		t.Errorf("Expected Unseal to raise %v, got %v", systemdcreds.ErrInvalidName, err)
+	}
+	info, err := os.Stat(filepath.Join(store, "db-password"))
+	if err != nil {
+		t.Fatalf("Failed to stat credential: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != systemdcreds.FileMode {
+		t.Errorf("Expected credential mode %s, got %s", systemdcreds.FileMode, info.Mode().Perm())
+	}
+	getenv := func(key string) string {
+		if key == systemdcreds.EnvCredentialsDirectory {
+			return store
+		}
+		return ""
+	}
+	data, err := systemdcreds.Read(getenv, "db-password")
+	if err != nil {
+		t.Fatalf("Read raised an unexpected error: %v", err)
+	}
+	if string(data) != "password" {
+		t.Errorf("Expected credential password, got %q", data)
+	}
+	if _, err := systemdcreds.Read(func(string) string { return "" }, "db-password"); !errors.Is(err, systemdcreds.ErrNoCredentialsDirectory) {
+		t.Errorf("Expected Read to raise %v, got %v", systemdcreds.ErrNoCredentialsDirectory, err)
+	}
+}
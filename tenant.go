@@ -0,0 +1,61 @@
+package f5xc
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+)
+
+// Use the tenant name in the methods of [Client], instead of discovering it from the credential; see [Client.Tenant].
+// The option has no effect on clients returned by [NewClient].
+func WithTenant(tenant string) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding tenant", "tenant", tenant)
+		if tenant == "" {
+			return fmt.Errorf("tenant must not be empty: %w", ErrInvalidOption)
+		}
+		c.tenant = tenant
+		return nil
+	}
+}
+
+// TenantFromCertificate returns the tenant name from the subject of an F5XC API certificate, which is the first
+// organization of the subject, or an empty string if the subject does not have an organization.
+func TenantFromCertificate(cert *x509.Certificate) string {
+	if cert == nil || len(cert.Subject.Organization) == 0 {
+		return ""
+	}
+	return cert.Subject.Organization[0]
+}
+
+// Tenant returns the name of the tenant of the client. The tenant set with [WithTenant] is returned if present,
+// otherwise it is discovered from the subject of the client certificate or, for an API token, from the authenticated
+// user returned by [WhoAmI]. A discovered tenant is cached for the lifetime of the client.
+func (c *Client) Tenant(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tenant != "" {
+		return c.tenant, nil
+	}
+	if cert := c.credentials.certificate(); cert != nil {
+		leaf, err := certificateLeaf(cert)
+		if err != nil {
+			return "", err
+		}
+		if tenant := TenantFromCertificate(leaf); tenant != "" {
+			c.credentials.logger.Debug("Discovered tenant from client certificate", "tenant", tenant)
+			c.tenant = tenant
+			return tenant, nil
+		}
+	}
+	user, err := WhoAmI(ctx, c.Client)
+	if err != nil {
+		return "", fmt.Errorf("failed to discover tenant: %w", err)
+	}
+	if user.Tenant == "" {
+		return "", fmt.Errorf("authenticated user does not have a tenant: %w", ErrNotFound)
+	}
+	c.credentials.logger.Debug("Discovered tenant from authenticated user", "tenant", user.Tenant)
+	c.tenant = user.Tenant
+	return user.Tenant, nil
+}
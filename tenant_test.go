@@ -0,0 +1,104 @@
+package f5xc_test
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/memes/f5xc"
+)
+
+// Verify that the tenant is returned from the first organization of the certificate subject.
+func TestTenantFromCertificate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		cert     *x509.Certificate
+		expected string
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "no-organization",
+			cert: &x509.Certificate{Subject: pkix.Name{CommonName: "test-user"}},
+		},
+		{
+			name:     "organization",
+			cert:     &x509.Certificate{Subject: pkix.Name{CommonName: "test-user", Organization: []string{"test-tenant", "other"}}},
+			expected: "test-tenant",
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			if tenant := f5xc.TenantFromCertificate(tst.cert); tenant != tst.expected {
+				t.Errorf("Expected tenant %q, got %q", tst.expected, tenant)
+			}
+		})
+	}
+}
+
+// Verify that the tenant of a Client is taken from the option, the client certificate, or the authenticated user, and
+// that a discovered tenant is cached.
+func TestClient_Tenant(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		_, _ = io.WriteString(w, `{"name": "test-user", "tenant": "api-tenant"}`)
+	})
+	tests := []struct {
+		name             string
+		options          []f5xc.Option
+		expected         string
+		expectedRequests int32
+	}{
+		{
+			name:     "option",
+			options:  []f5xc.Option{f5xc.WithTenant("option-tenant")},
+			expected: "option-tenant",
+		},
+		{
+			name:     "certificate",
+			options:  []f5xc.Option{f5xc.WithCertKeyPair(TestX509Certificate, TestX509Key)},
+			expected: "Matthew Emes",
+		},
+		{
+			name:             "whoami",
+			expected:         "api-tenant",
+			expectedRequests: 1,
+		},
+	}
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+	for _, tst := range tests {
+		requests.Store(0)
+		client, err := f5xc.NewAPIClient(append(testServerOptions(t, server), tst.options...)...)
+		if err != nil {
+			t.Fatalf("%s: NewAPIClient raised an unexpected error: %v", tst.name, err)
+		}
+		for range 2 {
+			tenant, err := client.Tenant(context.Background())
+			if err != nil {
+				t.Errorf("%s: Tenant raised an unexpected error: %v", tst.name, err)
+			}
+			if tenant != tst.expected {
+				t.Errorf("%s: expected tenant %q, got %q", tst.name, tst.expected, tenant)
+			}
+		}
+		if count := requests.Load(); count != tst.expectedRequests {
+			t.Errorf("%s: expected %d requests, got %d", tst.name, tst.expectedRequests, count)
+		}
+		client.CloseIdleConnections()
+	}
+	if _, err := f5xc.NewAPIClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"), f5xc.WithTenant("")); !errors.Is(err, f5xc.ErrInvalidOption) {
+		t.Errorf("Expected NewAPIClient to raise %v for an empty tenant, got %v", f5xc.ErrInvalidOption, err)
+	}
+}
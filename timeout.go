@@ -0,0 +1,72 @@
+package f5xc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Apply a deadline to each request sent by the client when the request context does not already have one, so that a
+// stalled API gateway cannot block the caller indefinitely. The deadline covers every retry of the request and reading
+// the response body; a deadline set by the caller is always respected, even if it is longer.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding request timeout", "timeout", timeout)
+		if timeout <= 0 {
+			return fmt.Errorf("request timeout must be positive: %w", ErrInvalidOption)
+		}
+		c.requestTimeout = timeout
+		return nil
+	}
+}
+
+// Implements a RoundTripper that adds a deadline to requests that do not have one.
+type timeoutTransport struct {
+	// The RoundTripper that calls the API.
+	base http.RoundTripper
+	// The time allowed for a request that does not have a deadline.
+	timeout time.Duration
+}
+
+// Implements RoundTripper by sending the request with a deadline if the request context does not have one. The deadline
+// is released when the response body is closed.
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, ok := req.Context().Deadline(); ok {
+		return t.base.RoundTrip(req) //nolint:wrapcheck // Errors are returned unchanged by the timeout transport
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err //nolint:wrapcheck // Errors are returned unchanged by the timeout transport
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// Forward CloseIdleConnections to the base RoundTripper.
+func (t *timeoutTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// Returns the base RoundTripper, so that the transport of the client can be found.
+func (t *timeoutTransport) Unwrap() http.RoundTripper {
+	return t.base
+}
+
+// Wraps a response body to release the request context when the body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close the body and release the request context.
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err //nolint:wrapcheck // Errors are returned unchanged from the wrapped body
+}
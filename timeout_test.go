@@ -0,0 +1,59 @@
+package f5xc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+)
+
+// Verify that a client with a request timeout applies it to requests without a deadline, and respects a deadline set
+// by the caller.
+func TestWithRequestTimeout(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	client, _ := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key_version") != "" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{Data: f5xc.PublicKey{KeyVersion: 1}})
+	}), f5xc.WithRequestTimeout(100*time.Millisecond))
+
+	if _, err := f5xc.GetPublicKey(context.Background(), client, nil); err != nil {
+		t.Errorf("GetPublicKey raised an unexpected error: %v", err)
+	}
+	version := 2
+	start := time.Now()
+	if _, err := f5xc.GetPublicKey(context.Background(), client, &version); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected GetPublicKey to raise %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected request to time out after 100ms, took %v", elapsed)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if _, err := f5xc.GetPublicKey(ctx, client, &version); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected GetPublicKey to raise %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("Expected caller deadline of 300ms to be respected, took %v", elapsed)
+	}
+}
+
+// Verify that a request timeout must be positive.
+func TestWithRequestTimeout_Invalid(t *testing.T) {
+	t.Parallel()
+	if _, err := f5xc.NewClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"), f5xc.WithRequestTimeout(0)); !errors.Is(err, f5xc.ErrInvalidOption) {
+		t.Errorf("Expected NewClient to raise %v, got %v", f5xc.ErrInvalidOption, err)
+	}
+}
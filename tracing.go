@@ -0,0 +1,22 @@
+package f5xc
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Record an OpenTelemetry client span with a tracer from the provider for every request sent by the client, including
+// each retry, and propagate the trace context to the API in request headers using the global propagator set with
+// otel.SetTextMapPropagator. A span records the method, path, host, and response status code, and is marked as an
+// error if the request fails or the API responds with a status code of 400 or greater.
+func WithTracing(provider trace.TracerProvider) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding tracing")
+		if provider == nil {
+			return fmt.Errorf("tracer provider must not be nil: %w", ErrInvalidOption)
+		}
+		c.tracerProvider = provider
+		return nil
+	}
+}
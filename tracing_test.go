@@ -0,0 +1,82 @@
+package f5xc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/memes/f5xc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Verify that a client with tracing records a span for each request, with the status code of the response, and sends
+// the trace context to the API.
+func TestWithTracing(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	traceparents := make(chan string, 2)
+	client, _ := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents <- r.Header.Get("Traceparent")
+		if r.URL.Query().Get("key_version") != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{Data: f5xc.PublicKey{KeyVersion: 1}})
+	}), f5xc.WithTracing(provider))
+
+	if _, err := f5xc.GetPublicKey(context.Background(), client, nil); err != nil {
+		t.Fatalf("GetPublicKey raised an unexpected error: %v", err)
+	}
+	version := 2
+	if _, err := f5xc.GetPublicKey(context.Background(), client, &version); !errors.Is(err, f5xc.ErrUnexpectedHTTPStatus) {
+		t.Fatalf("Expected GetPublicKey to raise %v, got %v", f5xc.ErrUnexpectedHTTPStatus, err)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	for i, expected := range []struct {
+		statusCode int64
+		code       codes.Code
+	}{
+		{statusCode: http.StatusOK, code: codes.Unset},
+		{statusCode: http.StatusInternalServerError, code: codes.Error},
+	} {
+		span := spans[i]
+		if traceparent := <-traceparents; traceparent == "" || traceparent[3:35] != span.SpanContext().TraceID().String() {
+			t.Errorf("Expected traceparent header for trace %s, got %q", span.SpanContext().TraceID(), traceparent)
+		}
+		if span.Name() != "HTTP GET" {
+			t.Errorf("Expected span name HTTP GET, got %s", span.Name())
+		}
+		var statusCode int64
+		for _, attr := range span.Attributes() {
+			if attr.Key == attribute.Key("http.response.status_code") {
+				statusCode = attr.Value.AsInt64()
+			}
+		}
+		if statusCode != expected.statusCode {
+			t.Errorf("Expected span status code %d, got %d", expected.statusCode, statusCode)
+		}
+		if span.Status().Code != expected.code {
+			t.Errorf("Expected span status %v, got %v", expected.code, span.Status().Code)
+		}
+	}
+}
+
+// Verify that a nil tracer provider is rejected.
+func TestWithTracing_Invalid(t *testing.T) {
+	t.Parallel()
+	if _, err := f5xc.NewClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"), f5xc.WithTracing(nil)); !errors.Is(err, f5xc.ErrInvalidOption) {
+		t.Errorf("Expected NewClient to raise %v, got %v", f5xc.ErrInvalidOption, err)
+	}
+}
@@ -0,0 +1,72 @@
+package f5xc
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// TransportTuning defines the connection pool and protocol settings of the transport of a client created by
+// [NewClient]; see [WithTransportTuning]. A zero value keeps the setting of [http.DefaultTransport].
+type TransportTuning struct {
+	// The maximum number of idle connections across all hosts.
+	MaxIdleConns int
+	// The maximum number of idle connections kept for each host, which should be raised to the expected number of
+	// concurrent requests to reuse connections; [http.DefaultMaxIdleConnsPerHost] is used if unset.
+	MaxIdleConnsPerHost int
+	// The maximum number of connections to each host, including connections that are in use; unlimited if unset.
+	MaxConnsPerHost int
+	// The time an idle connection is kept open before it is closed.
+	IdleConnTimeout time.Duration
+	// The time allowed for a TLS handshake.
+	TLSHandshakeTimeout time.Duration
+	// The time allowed to receive the response headers after the request has been written.
+	ResponseHeaderTimeout time.Duration
+	// True if requests must use HTTP/1.1, instead of attempting HTTP/2 when the API server supports it.
+	DisableHTTP2 bool
+}
+
+// Tune the connection pool and protocol settings of the client transport, e.g. to raise the number of idle connections
+// kept for the API server when many requests are made concurrently. Settings that are not given in the tuning keep the
+// defaults of [http.DefaultTransport], which attempts HTTP/2 for all requests unless disabled.
+func WithTransportTuning(tuning TransportTuning) Option {
+	return func(c *config) error {
+		c.log().Debug("Adding transport tuning", "maxIdleConns", tuning.MaxIdleConns, "maxIdleConnsPerHost", tuning.MaxIdleConnsPerHost, "maxConnsPerHost", tuning.MaxConnsPerHost, "idleConnTimeout", tuning.IdleConnTimeout, "tlsHandshakeTimeout", tuning.TLSHandshakeTimeout, "responseHeaderTimeout", tuning.ResponseHeaderTimeout, "disableHTTP2", tuning.DisableHTTP2)
+		switch {
+		case tuning.MaxIdleConns < 0 || tuning.MaxIdleConnsPerHost < 0 || tuning.MaxConnsPerHost < 0:
+			return fmt.Errorf("transport connection limits must not be negative: %w", ErrInvalidOption)
+		case tuning.IdleConnTimeout < 0 || tuning.TLSHandshakeTimeout < 0 || tuning.ResponseHeaderTimeout < 0:
+			return fmt.Errorf("transport timeouts must not be negative: %w", ErrInvalidOption)
+		}
+		c.transportTuning = &tuning
+		return nil
+	}
+}
+
+// Applies the non-zero settings of the tuning to the transport.
+func (tuning *TransportTuning) apply(transport *http.Transport) {
+	if tuning.MaxIdleConns > 0 {
+		transport.MaxIdleConns = tuning.MaxIdleConns
+	}
+	if tuning.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = tuning.MaxIdleConnsPerHost
+	}
+	if tuning.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = tuning.MaxConnsPerHost
+	}
+	if tuning.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = tuning.IdleConnTimeout
+	}
+	if tuning.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = tuning.TLSHandshakeTimeout
+	}
+	if tuning.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = tuning.ResponseHeaderTimeout
+	}
+	if tuning.DisableHTTP2 {
+		// An empty, non-nil map of protocol upgrades is required to disable HTTP/2 with a custom TLS configuration
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+}
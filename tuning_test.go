@@ -0,0 +1,104 @@
+package f5xc_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc"
+)
+
+// Verify that the transport tuning limits the connections to the API server, and selects the HTTP protocol.
+func TestWithTransportTuning(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name                string
+		tuning              *f5xc.TransportTuning
+		expectedProtoMajor  int
+		expectedConnections int32
+	}{
+		{
+			name:               "default",
+			expectedProtoMajor: 2,
+		},
+		{
+			name:                "http1-single-connection",
+			tuning:              &f5xc.TransportTuning{MaxConnsPerHost: 1, MaxIdleConnsPerHost: 1, IdleConnTimeout: time.Minute, DisableHTTP2: true},
+			expectedProtoMajor:  1,
+			expectedConnections: 1,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			var connections atomic.Int32
+			protoMajor := make(chan int, 5)
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				protoMajor <- r.ProtoMajor
+				time.Sleep(10 * time.Millisecond)
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			server.EnableHTTP2 = true
+			server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					connections.Add(1)
+				}
+			}
+			server.StartTLS()
+			t.Cleanup(server.Close)
+			options := testServerOptions(t, server)
+			if tst.tuning != nil {
+				options = append(options, f5xc.WithTransportTuning(*tst.tuning))
+			}
+			client, err := f5xc.NewClient(options...)
+			if err != nil {
+				t.Fatalf("NewClient raised an unexpected error: %v", err)
+			}
+			t.Cleanup(client.CloseIdleConnections)
+			var wg sync.WaitGroup
+			for range cap(protoMajor) {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/api/test", nil)
+					if err != nil {
+						t.Errorf("Failed to create request: %v", err)
+						return
+					}
+					resp, err := client.Do(req)
+					if err != nil {
+						t.Errorf("Request raised an unexpected error: %v", err)
+						return
+					}
+					_ = resp.Body.Close()
+				}()
+			}
+			wg.Wait()
+			close(protoMajor)
+			for proto := range protoMajor {
+				if proto != tst.expectedProtoMajor {
+					t.Errorf("Expected HTTP/%d request, got HTTP/%d", tst.expectedProtoMajor, proto)
+				}
+			}
+			if count := connections.Load(); tst.expectedConnections > 0 && count != tst.expectedConnections {
+				t.Errorf("Expected %d connections, got %d", tst.expectedConnections, count)
+			}
+		})
+	}
+}
+
+// Verify that negative transport settings are rejected.
+func TestWithTransportTuning_Invalid(t *testing.T) {
+	t.Parallel()
+	for _, tuning := range []f5xc.TransportTuning{{MaxIdleConns: -1}, {MaxConnsPerHost: -1}, {IdleConnTimeout: -time.Second}, {ResponseHeaderTimeout: -time.Second}} {
+		if _, err := f5xc.NewClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"), f5xc.WithTransportTuning(tuning)); !errors.Is(err, f5xc.ErrInvalidOption) {
+			t.Errorf("Expected NewClient to raise %v for %+v, got %v", f5xc.ErrInvalidOption, tuning, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package f5xc
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+const (
+	// The import path of this module, used to find its version in the build information of a program that imports it.
+	modulePath = "github.com/memes/f5xc"
+	// The version reported when the module version is not known.
+	develVersion = "(devel)"
+)
+
+// The version of the module, which can be set at build time with
+// -ldflags "-X github.com/memes/f5xc.version=VERSION" to override the version reported by the build information.
+var version = "" //nolint:gochecknoglobals // Set by the linker at build time
+
+// BuildInfo identifies the build of the f5xc module that is linked into the running program.
+type BuildInfo struct {
+	// The version of the module; the value set at build time, the module version recorded in the build information,
+	// or (devel) if neither is known.
+	Version string `json:"version" yaml:"version"`
+	// The VCS revision the program was built from, if known.
+	Revision string `json:"revision,omitempty" yaml:"revision,omitempty"`
+	// True if the working tree had uncommitted changes when the program was built.
+	Modified bool `json:"modified,omitempty" yaml:"modified,omitempty"`
+	// The version of Go that built the program.
+	GoVersion string `json:"go_version" yaml:"goVersion"`
+}
+
+// Reads the build information once; it cannot change while the program is running.
+var readBuildInfo = sync.OnceValue(func() BuildInfo { //nolint:gochecknoglobals // Cached build information
+	info := BuildInfo{
+		Version:   version,
+		GoVersion: runtime.Version(),
+	}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		if info.Version == "" {
+			info.Version = develVersion
+		}
+		return info
+	}
+	if info.Version == "" {
+		info.Version = moduleVersion(build)
+	}
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+})
+
+// Returns the version of this module from the build information, whether it is the main module or a dependency, or
+// (devel) if it is not recorded.
+func moduleVersion(build *debug.BuildInfo) string {
+	module := build.Main
+	if module.Path != modulePath {
+		module = debug.Module{}
+		for _, dep := range build.Deps {
+			if dep.Path == modulePath {
+				module = *dep
+				break
+			}
+		}
+	}
+	if module.Replace != nil {
+		module = *module.Replace
+	}
+	if module.Version == "" {
+		return develVersion
+	}
+	return module.Version
+}
+
+// ReadBuildInfo returns a description of the build of the f5xc module that is linked into the running program.
+func ReadBuildInfo() BuildInfo {
+	return readBuildInfo()
+}
+
+// Version returns the version of the f5xc module that is linked into the running program; the value set at build time
+// with -ldflags, the module version from the build information, or (devel) if neither is known.
+func Version() string {
+	return readBuildInfo().Version
+}
+
+// UserAgent returns the User-Agent header value that is added to API requests made by a client from [NewClient],
+// after any product set with [WithUserAgent].
+func UserAgent() string {
+	return "f5xc/" + Version()
+}
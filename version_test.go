@@ -0,0 +1,118 @@
+package f5xc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime"
+	"testing"
+
+	"github.com/memes/f5xc"
+)
+
+// Verify that the build information of the module is reported.
+func TestReadBuildInfo(t *testing.T) {
+	t.Parallel()
+	info := f5xc.ReadBuildInfo()
+	if info.Version == "" {
+		t.Error("Expected a version to be reported")
+	}
+	if info.Version != f5xc.Version() {
+		t.Errorf("Expected Version to return %q, got %q", info.Version, f5xc.Version())
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("Expected Go version %q, got %q", runtime.Version(), info.GoVersion)
+	}
+	if expected := "f5xc/" + info.Version; f5xc.UserAgent() != expected {
+		t.Errorf("Expected user agent %q, got %q", expected, f5xc.UserAgent())
+	}
+}
+
+// Verify that API requests identify the module version in the User-Agent header, unless the request sets its own.
+func TestNewClient_UserAgent(t *testing.T) {
+	t.Parallel()
+	userAgents := make(chan string, 1)
+	client, serverURL := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents <- r.UserAgent()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	tests := []struct {
+		name      string
+		userAgent string
+		expected  string
+	}{
+		{
+			name:     "default",
+			expected: f5xc.UserAgent(),
+		},
+		{
+			name:      "override",
+			userAgent: "custom/1.0",
+			expected:  "custom/1.0",
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, serverURL+"/api/test", nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			if tst.userAgent != "" {
+				req.Header.Set("User-Agent", tst.userAgent)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Request raised an unexpected error: %v", err)
+			}
+			_ = resp.Body.Close()
+			if userAgent := <-userAgents; userAgent != tst.expected {
+				t.Errorf("Expected User-Agent %q, got %q", tst.expected, userAgent)
+			}
+		})
+	}
+}
+
+// Verify that a client with a user agent product and extra headers adds them to requests that do not set them.
+func TestNewClient_WithUserAgentAndHeader(t *testing.T) {
+	t.Parallel()
+	headers := make(chan http.Header, 1)
+	client, serverURL := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}), f5xc.WithUserAgent("mytool/1.2"), f5xc.WithHeader("x-team", "platform"), f5xc.WithHeader("X-Team", "security"), f5xc.WithHeader("X-Env", "prod"))
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, serverURL+"/api/test", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("X-Env", "dev")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request raised an unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	header := <-headers
+	if expected := "mytool/1.2 " + f5xc.UserAgent(); header.Get("User-Agent") != expected {
+		t.Errorf("Expected User-Agent %q, got %q", expected, header.Get("User-Agent"))
+	}
+	if team := header.Values("X-Team"); len(team) != 2 || team[0] != "platform" || team[1] != "security" {
+		t.Errorf("Expected X-Team headers platform and security, got %q", team)
+	}
+	if env := header.Get("X-Env"); env != "dev" {
+		t.Errorf("Expected request X-Env header dev to be kept, got %q", env)
+	}
+}
+
+// Verify that empty user agents and headers set by the client are rejected.
+func TestNewClient_WithUserAgentAndHeader_Invalid(t *testing.T) {
+	t.Parallel()
+	for name, option := range map[string]f5xc.Option{
+		"empty-user-agent": f5xc.WithUserAgent(" "),
+		"empty-header":     f5xc.WithHeader("", "value"),
+		"authorization":    f5xc.WithHeader("authorization", "Bearer token"),
+		"content-type":     f5xc.WithHeader("Content-Type", "text/plain"),
+	} {
+		if _, err := f5xc.NewClient(f5xc.WithAPIEndpoint("https://f5xc.invalid/api"), f5xc.WithAuthToken("test-token"), option); !errors.Is(err, f5xc.ErrInvalidOption) {
+			t.Errorf("Expected NewClient to raise %v for %s, got %v", f5xc.ErrInvalidOption, name, err)
+		}
+	}
+}
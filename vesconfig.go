@@ -0,0 +1,142 @@
+package f5xc
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// The name of the vesctl configuration file in the home directory of the user.
+	VesConfigFile = ".vesconfig"
+	// The environment variable that holds the passphrase of the PKCS#12 bundle in a vesctl configuration file.
+	EnvVesP12Password = "VES_P12_PASSWORD"
+)
+
+// ErrInvalidVesConfig is returned by [NewClientFromVesConfig] when the vesctl configuration file cannot be parsed or
+// does not have a server URL.
+var ErrInvalidVesConfig = errors.New("invalid vesctl configuration")
+
+// VesConfig holds the settings of a vesctl YAML configuration file that are used to create a client.
+type VesConfig struct {
+	// The API URLs; vesctl accepts a single URL or a list, and only the first is used.
+	ServerURLs ServerURLs `yaml:"server-urls"`
+	// The path of a PEM client certificate; requires Key.
+	Cert string `yaml:"cert"`
+	// The path of the PEM private key of Cert.
+	Key string `yaml:"key"`
+	// The path of a PEM CA certificate to trust in addition to the system CA certificates.
+	CACert string `yaml:"cacert"`
+	// The path of a PKCS#12 bundle; the passphrase is read from the VES_P12_PASSWORD environment variable.
+	P12Bundle string `yaml:"p12-bundle"`
+	// An API token.
+	APIToken string `yaml:"api-token"`
+}
+
+// ServerURLs is the server-urls value of a vesctl configuration file, which may be a string or a list of strings.
+type ServerURLs []string
+
+// UnmarshalYAML implements yaml.Unmarshaler for a string or a list of strings.
+func (s *ServerURLs) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*s = ServerURLs{node.Value}
+		return nil
+	}
+	var urls []string
+	if err := node.Decode(&urls); err != nil {
+		return fmt.Errorf("server-urls must be a string or a list of strings: %w", err)
+	}
+	*s = urls
+	return nil
+}
+
+// ReadVesConfig reads the vesctl configuration file at the path, or ~/.vesconfig if path is empty. Paths in the file
+// that start with ~/ are expanded to the home directory of the user.
+func ReadVesConfig(path string) (*VesConfig, error) {
+	return readVesConfig(path, slog.Default())
+}
+
+// Reads the vesctl configuration file, logging to the logger.
+func readVesConfig(path string, logger *slog.Logger) (*VesConfig, error) {
+	home, err := os.UserHomeDir()
+	if path == "" {
+		if err != nil {
+			return nil, fmt.Errorf("failed to find home directory: %w", err)
+		}
+		path = filepath.Join(home, VesConfigFile)
+	}
+	logger.Debug("Reading vesctl configuration", "path", path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vesctl configuration %s: %w", path, err)
+	}
+	cfg := &VesConfig{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse vesctl configuration %s: %w: %w", path, err, ErrInvalidVesConfig)
+	}
+	for _, value := range []*string{&cfg.Cert, &cfg.Key, &cfg.CACert, &cfg.P12Bundle} {
+		if rest, ok := strings.CutPrefix(*value, "~/"); ok && home != "" {
+			*value = filepath.Join(home, rest)
+		}
+	}
+	return cfg, nil
+}
+
+// Options returns the options that configure a client with the API URL, CA certificate, and credentials of the vesctl
+// configuration. If more than one form of authentication is configured the preferred form is used; see
+// [WithCredentials].
+func (v *VesConfig) Options() ([]Option, error) {
+	if len(v.ServerURLs) == 0 || v.ServerURLs[0] == "" {
+		return nil, fmt.Errorf("server-urls must be present: %w", ErrInvalidVesConfig)
+	}
+	options := []Option{
+		WithAPIEndpoint(v.ServerURLs[0]),
+	}
+	if v.CACert != "" {
+		options = append(options, WithCACert(v.CACert))
+	}
+	options = append(options, WithCredentials(Credentials{
+		APIToken:      v.APIToken,
+		P12Bundle:     v.P12Bundle,
+		P12Passphrase: os.Getenv(EnvVesP12Password),
+		Cert:          v.Cert,
+		Key:           v.Key,
+	}))
+	return options, nil
+}
+
+// Configure the client from the vesctl configuration file at the path, or ~/.vesconfig if path is empty; see
+// [VesConfig.Options]. The file is read with the logger of any [WithLogger] option that precedes this option, and
+// options that follow it can add to or override the configuration.
+func WithVesConfig(path string) Option {
+	return func(c *config) error {
+		vesConfig, err := readVesConfig(path, c.log())
+		if err != nil {
+			return err
+		}
+		vesOptions, err := vesConfig.Options()
+		if err != nil {
+			return err
+		}
+		for _, option := range vesOptions {
+			if err := option(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// NewClientFromVesConfig creates a client configured from the vesctl configuration file at the path, or ~/.vesconfig
+// if path is empty, so that the credentials maintained for vesctl can be reused; see [VesConfig.Options]. Any options
+// are applied after the configuration file, and can add to or override it; use [NewClient] with [WithLogger] followed
+// by [WithVesConfig] to log the reading of the file to a logger.
+func NewClientFromVesConfig(path string, options ...Option) (*http.Client, error) {
+	return NewClient(append([]Option{WithVesConfig(path)}, options...)...)
+}
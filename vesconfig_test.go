@@ -0,0 +1,126 @@
+package f5xc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/memes/f5xc"
+)
+
+// Writes the vesctl configuration to a temporary file and returns the path.
+func testVesConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "vesconfig.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write vesctl configuration: %v", err)
+	}
+	return path
+}
+
+// Verify that a vesctl configuration file is parsed, with a single server URL or a list.
+func TestReadVesConfig(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		content       string
+		expected      f5xc.VesConfig
+		expectedError error
+	}{
+		{
+			name:    "p12",
+			content: "server-urls: https://tenant.console.ves.volterra.io/api\np12-bundle: /creds/tenant.p12\n",
+			expected: f5xc.VesConfig{
+				ServerURLs: f5xc.ServerURLs{"https://tenant.console.ves.volterra.io/api"},
+				P12Bundle:  "/creds/tenant.p12",
+			},
+		},
+		{
+			name:    "cert-list",
+			content: "server-urls:\n  - https://a.example.com/api\n  - https://b.example.com/api\ncert: /creds/cert.pem\nkey: /creds/key.pem\ncacert: /creds/ca.pem\n",
+			expected: f5xc.VesConfig{
+				ServerURLs: f5xc.ServerURLs{"https://a.example.com/api", "https://b.example.com/api"},
+				Cert:       "/creds/cert.pem",
+				Key:        "/creds/key.pem",
+				CACert:     "/creds/ca.pem",
+			},
+		},
+		{
+			name:          "invalid",
+			content:       "server-urls: {url: https://a.example.com/api}\n",
+			expectedError: f5xc.ErrInvalidVesConfig,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			cfg, err := f5xc.ReadVesConfig(testVesConfig(t, tst.content))
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Fatalf("ReadVesConfig raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Fatalf("Expected ReadVesConfig to raise %v, got %v", tst.expectedError, err)
+			case err != nil:
+				return
+			}
+			expected, _ := json.Marshal(tst.expected)
+			actual, _ := json.Marshal(cfg)
+			if string(expected) != string(actual) {
+				t.Errorf("Expected %s, got %s", expected, actual)
+			}
+		})
+	}
+	if _, err := f5xc.ReadVesConfig(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected ReadVesConfig to raise %v, got %v", os.ErrNotExist, err)
+	}
+}
+
+// Verify that a client created from a vesctl configuration file uses its server URL, CA certificate, and token.
+func TestNewClientFromVesConfig(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "APIToken test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f5xc.Envelope[f5xc.PublicKey]{Data: f5xc.PublicKey{KeyVersion: 1}})
+	}))
+	t.Cleanup(server.Close)
+	path := testVesConfig(t, "server-urls: "+server.URL+"/api\napi-token: test-token\n")
+	client, err := f5xc.NewClientFromVesConfig(path, f5xc.WithInsecureSkipVerify())
+	if err != nil {
+		t.Fatalf("NewClientFromVesConfig raised an unexpected error: %v", err)
+	}
+	t.Cleanup(client.CloseIdleConnections)
+	if _, err := f5xc.GetPublicKey(context.Background(), client, nil); err != nil {
+		t.Errorf("GetPublicKey raised an unexpected error: %v", err)
+	}
+	if _, err := f5xc.NewClientFromVesConfig(testVesConfig(t, "api-token: test-token\n")); !errors.Is(err, f5xc.ErrInvalidVesConfig) {
+		t.Errorf("Expected NewClientFromVesConfig to raise %v, got %v", f5xc.ErrInvalidVesConfig, err)
+	}
+}
+
+// Verify that the vesctl configuration file is read with the logger of the client when WithLogger precedes
+// WithVesConfig.
+func TestWithVesConfig_Logger(t *testing.T) {
+	t.Parallel()
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	path := testVesConfig(t, "server-urls: https://f5xc.invalid/api\napi-token: test-token\n")
+	client, err := f5xc.NewClient(f5xc.WithLogger(logger), f5xc.WithVesConfig(path))
+	if err != nil {
+		t.Fatalf("NewClient raised an unexpected error: %v", err)
+	}
+	t.Cleanup(client.CloseIdleConnections)
+	if !strings.Contains(logs.String(), "Reading vesctl configuration") {
+		t.Errorf("Expected logs to contain the vesctl configuration path, got %s", logs.String())
+	}
+}
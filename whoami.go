@@ -0,0 +1,55 @@
+package f5xc
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+)
+
+// The partial URL to fetch the authenticated user from F5 Distributed Cloud.
+const WhoAmIURL = "/api/web/custom/namespaces/system/whoami"
+
+// Represents the authenticated user of an F5XC API credential, as described at
+// https://docs.cloud.f5.com/docs/api/user#operation/ves.io.schema.user.CustomAPI.Get.
+type User struct {
+	Name            string          `json:"name" yaml:"name"`
+	Email           string          `json:"email" yaml:"email"`
+	FirstName       string          `json:"first_name,omitempty" yaml:"firstName,omitempty"`
+	LastName        string          `json:"last_name,omitempty" yaml:"lastName,omitempty"`
+	Tenant          string          `json:"tenant" yaml:"tenant"`
+	TenantID        string          `json:"tenant_id,omitempty" yaml:"tenantId,omitempty"`
+	NamespaceAccess NamespaceAccess `json:"namespace_access" yaml:"namespaceAccess"`
+}
+
+// Represents the roles of a user in each namespace they can access.
+type NamespaceAccess struct {
+	NamespaceRoleMap map[string]NamespaceRoles `json:"namespace_role_map" yaml:"namespaceRoleMap"`
+}
+
+// Represents the roles of a user in a namespace.
+type NamespaceRoles struct {
+	Roles []string `json:"roles" yaml:"roles"`
+}
+
+// Namespaces returns the sorted names of the namespaces that the user can access.
+func (u *User) Namespaces() []string {
+	return slices.Sorted(maps.Keys(u.NamespaceAccess.NamespaceRoleMap))
+}
+
+// Returns the authenticated user of the client's credential, with their tenant and the namespaces they can access, or
+// an error. This is a cheap call that can be used to verify that a credential is valid before using it.
+func WhoAmI(ctx context.Context, client Doer) (*User, error) {
+	clientLogger(client).Debug("Retrieving authenticated user")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, WhoAmIURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for User: %w", err)
+	}
+	return apiCall[User](client, req, true)
+}
+
+// WhoAmI returns the authenticated user of the client's credential; see [WhoAmI].
+func (c *Client) WhoAmI(ctx context.Context) (*User, error) {
+	return WhoAmI(ctx, c.Client)
+}
@@ -0,0 +1,60 @@
+package f5xc_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/memes/f5xc"
+)
+
+// Verify that WhoAmI returns the authenticated user, tenant, and namespaces, and raises an error for an invalid
+// credential.
+func TestWhoAmI(t *testing.T) {
+	t.Parallel()
+	client, serverURL := testAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path != f5xc.WhoAmIURL:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Header.Get("Authorization") != "APIToken test-token":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			_, _ = io.WriteString(w, `{
+				"name": "test-user",
+				"email": "test@example.com",
+				"tenant": "test-tenant",
+				"namespace_access": {
+					"namespace_role_map": {
+						"system": {"roles": ["ves-io-monitor-role"]},
+						"shared": {"roles": ["ves-io-admin-role"]}
+					}
+				}
+			}`)
+		}
+	}))
+	user, err := f5xc.WhoAmI(context.Background(), client)
+	if err != nil {
+		t.Fatalf("WhoAmI raised an unexpected error: %v", err)
+	}
+	if user.Name != "test-user" || user.Email != "test@example.com" || user.Tenant != "test-tenant" {
+		t.Errorf("Unexpected user: %+v", user)
+	}
+	if namespaces := user.Namespaces(); !slices.Equal(namespaces, []string{"shared", "system"}) {
+		t.Errorf("Expected namespaces shared and system, got %v", namespaces)
+	}
+	if roles := user.NamespaceAccess.NamespaceRoleMap["shared"].Roles; !slices.Equal(roles, []string{"ves-io-admin-role"}) {
+		t.Errorf("Expected shared roles ves-io-admin-role, got %v", roles)
+	}
+
+	invalid, err := f5xc.NewAPIClient(f5xc.WithAPIEndpoint(serverURL+"/api"), f5xc.WithAuthToken("invalid-token"), f5xc.WithInsecureSkipVerify())
+	if err != nil {
+		t.Fatalf("NewAPIClient raised an unexpected error: %v", err)
+	}
+	t.Cleanup(invalid.CloseIdleConnections)
+	if _, err := invalid.WhoAmI(context.Background()); !errors.Is(err, f5xc.ErrUnauthorized) {
+		t.Errorf("Expected WhoAmI to raise %v, got %v", f5xc.ErrUnauthorized, err)
+	}
+}
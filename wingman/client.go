@@ -0,0 +1,280 @@
+package wingman
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// ErrInvalidEndpoint is returned by [NewClient] when the endpoint cannot be parsed or uses an unsupported scheme.
+var ErrInvalidEndpoint = errors.New("invalid wingman endpoint")
+
+// Client is implemented by types that can interact with a Wingman service, regardless of the transport used. Use
+// [NewClient] to create a Client appropriate for an endpoint URL. A failure to communicate with Wingman is returned as
+// an [*f5xc.Error] that reports the operation, endpoint, and whether the request may be retried.
+type Client interface {
+	// Ready returns nil if Wingman reports that it is ready to receive requests, or an error that wraps [ErrNotReady].
+	Ready(ctx context.Context) error
+	// Unseal a byte slice of blindfold data, returning the unsealed data.
+	Unseal(ctx context.Context, sealed []byte) ([]byte, error)
+	// Unseal a byte slice of base64 encoded blindfold data, returning the unsealed data.
+	UnsealEncoded(ctx context.Context, sealed []byte) ([]byte, error)
+	// Close releases any connections held by the Client.
+	Close() error
+	// Shutdown refuses new unseal requests with [ErrClientShutdown], waits for the requests in progress to complete or
+	// the context to be done, and then releases any connections held by the Client.
+	Shutdown(ctx context.Context) error
+}
+
+// ErrInvalidOption is returned when an option value is not acceptable; it is the same error as [f5xc.ErrInvalidOption].
+var ErrInvalidOption = f5xc.ErrInvalidOption
+
+// Defines the configuration options for a Wingman Client and polling functions.
+type config struct {
+	httpClient       *http.Client
+	dialOptions      []grpc.DialOption
+	grpcUnsealMethod string
+	readyPredicate   ReadyPredicate
+	jitter           float64
+	tracerProvider   trace.TracerProvider
+	maxResponseSize  int64
+}
+
+// Defines a configuration setting function for NewClient and polling functions.
+type Option func(*config) error
+
+// Returns a new config with defaults applied and then modified by the options.
+func newConfig(options ...Option) (*config, error) {
+	cfg := &config{
+		httpClient:       http.DefaultClient,
+		grpcUnsealMethod: GRPCUnsealMethod,
+		readyPredicate:   IsReady,
+		maxResponseSize:  DefaultMaxResponseSize,
+	}
+	for _, option := range options {
+		if err := option(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// Returns a function that will return the base duration with random jitter applied as configured.
+func (c *config) pollInterval(base time.Duration) func() time.Duration {
+	return func() time.Duration {
+		if c.jitter == 0 || base <= 0 {
+			return base
+		}
+		delta := float64(base) * c.jitter
+		//nolint:gosec // Don't need cryptographically secure pseudo random number generation
+		return base + time.Duration(delta*(2*rand.Float64()-1))
+	}
+}
+
+// Use the supplied http.Client for requests to an HTTP(S) Wingman endpoint; the default is [http.DefaultClient].
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *config) error {
+		c.httpClient = client
+		return nil
+	}
+}
+
+// Add the supplied gRPC dial options when connecting to a gRPC Wingman endpoint. The options are applied after the
+// transport credentials implied by the endpoint scheme, so they can be used to override them.
+func WithGRPCDialOptions(options ...grpc.DialOption) Option {
+	return func(c *config) error {
+		c.dialOptions = append(c.dialOptions, options...)
+		return nil
+	}
+}
+
+// Use the supplied fully-qualified gRPC method name for unseal requests; the default is [GRPCUnsealMethod].
+func WithGRPCUnsealMethod(method string) Option {
+	return func(c *config) error {
+		c.grpcUnsealMethod = method
+		return nil
+	}
+}
+
+// Use the supplied predicate to determine if a response from the Wingman status endpoint indicates readiness; the
+// default is [IsReady]. This option applies to [WaitForReady] and to HTTP(S) clients created by [NewClient].
+func WithReadyPredicate(predicate ReadyPredicate) Option {
+	return func(c *config) error {
+		if predicate == nil {
+			return fmt.Errorf("ready predicate must not be nil: %w", ErrInvalidOption)
+		}
+		c.readyPredicate = predicate
+		return nil
+	}
+}
+
+// Randomly vary the time between polling attempts by up to the given fraction of the interval, in either direction;
+// e.g. a value of 0.2 with a 10 second interval will sleep between 8 and 12 seconds. The fraction must be between 0 and
+// 1 inclusive. This option applies to [WaitForReady] and [WaitForClientReady] and can be used to avoid many instances
+// polling Wingman in lockstep.
+func WithJitter(fraction float64) Option {
+	return func(c *config) error {
+		if fraction < 0 || fraction > 1 {
+			return fmt.Errorf("jitter fraction must be between 0 and 1: %w", ErrInvalidOption)
+		}
+		c.jitter = fraction
+		return nil
+	}
+}
+
+// Record an OpenTelemetry client span with a tracer from the provider for every request sent by a Client created by
+// [NewClient], and propagate the trace context to Wingman using the global propagator set with
+// otel.SetTextMapPropagator. Requests to an HTTP(S) endpoint are traced by wrapping the transport of the http.Client,
+// and calls to a gRPC endpoint by a unary interceptor that is added before any [WithGRPCDialOptions].
+func WithTracing(provider trace.TracerProvider) Option {
+	return func(c *config) error {
+		if provider == nil {
+			return fmt.Errorf("tracer provider must not be nil: %w", ErrInvalidOption)
+		}
+		c.tracerProvider = provider
+		return nil
+	}
+}
+
+// Limit the number of bytes of an unseal response that are read by HTTP(S) and gRPC clients created by [NewClient], so
+// that a misbehaving endpoint cannot exhaust memory. A larger response is returned as an [*f5xc.Error] that wraps
+// [f5xc.ErrResponseTooLarge] from an HTTP(S) endpoint, or [ErrUnexpectedGRPCStatus] with a ResourceExhausted status
+// from a gRPC endpoint. The default is [DefaultMaxResponseSize]; a gRPC limit is capped at math.MaxInt32 bytes.
+func WithMaxResponseSize(size int64) Option {
+	return func(c *config) error {
+		if size <= 0 {
+			return fmt.Errorf("maximum response size must be positive: %w", ErrInvalidOption)
+		}
+		c.maxResponseSize = size
+		return nil
+	}
+}
+
+// NewClient returns a [Client] that will communicate with Wingman at the base endpoint URL. The scheme of the endpoint
+// determines the transport used:
+//
+//   - http and https will use Wingman's REST API, e.g. [DefaultWingmanURL]
+//   - grpc will use Wingman's gRPC API without TLS, e.g. grpc://localhost:8071
+//   - grpcs will use Wingman's gRPC API with TLS verified against the system CA certificates
+func NewClient(endpoint string, options ...Option) (Client, error) {
+	logger := slog.With("endpoint", endpoint)
+	logger.Debug("Creating wingman client")
+	cfg, err := newConfig(options...)
+	if err != nil {
+		return nil, err
+	}
+	baseURL, err := url.Parse(endpoint)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("parsing error: %w: %w", err, ErrInvalidEndpoint)
+	case baseURL.Host == "":
+		return nil, fmt.Errorf("host must be present: %w", ErrInvalidEndpoint)
+	}
+	switch strings.ToLower(baseURL.Scheme) {
+	case "http", "https":
+		client := cfg.httpClient
+		if cfg.tracerProvider != nil {
+			client = tracedHTTPClient(client, cfg.tracerProvider)
+		}
+		return &httpClient{
+			client:          client,
+			endpoint:        strings.TrimSuffix(baseURL.String(), "/"),
+			readyPredicate:  cfg.readyPredicate,
+			maxResponseSize: cfg.maxResponseSize,
+		}, nil
+	case "grpc", "grpcs":
+		return newGRPCClient(baseURL, cfg)
+	}
+	return nil, fmt.Errorf("unsupported scheme %q: %w", baseURL.Scheme, ErrInvalidEndpoint)
+}
+
+// Returns a copy of the http.Client that records a span for every request.
+func tracedHTTPClient(client *http.Client, provider trace.TracerProvider) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	traced := *client
+	traced.Transport = tracing.NewTransport(base, provider)
+	return &traced
+}
+
+// Implements the Client interface using Wingman's REST API.
+type httpClient struct {
+	inflight
+	client          *http.Client
+	endpoint        string
+	readyPredicate  ReadyPredicate
+	maxResponseSize int64
+}
+
+// Ready returns nil if the Wingman status endpoint reports READY.
+func (c *httpClient) Ready(ctx context.Context) error {
+	statusCode, body, err := fetchStatus(ctx, c.client, c.endpoint+StatusEndpoint)
+	switch {
+	case err != nil:
+		err = fmt.Errorf("%w: %w", err, ErrNotReady)
+	case !c.readyPredicate(statusCode, body):
+		err = fmt.Errorf("%q: %w", string(body), ErrNotReady)
+	default:
+		return nil
+	}
+	return &f5xc.Error{Op: readyOp, Endpoint: c.endpoint + StatusEndpoint, StatusCode: statusCode, Temporary: true, Err: err}
+}
+
+// Unseal a byte slice of blindfold data; see [Unseal].
+func (c *httpClient) Unseal(ctx context.Context, sealed []byte) ([]byte, error) {
+	return track(&c.inflight, func() ([]byte, error) {
+		return unseal(ctx, c.client, c.endpoint+UnsealEndpoint, c.maxResponseSize, writeUnencoded(sealed))
+	})
+}
+
+// Unseal a byte slice of base64 encoded blindfold data; see [UnsealEncoded].
+func (c *httpClient) UnsealEncoded(ctx context.Context, sealed []byte) ([]byte, error) {
+	return track(&c.inflight, func() ([]byte, error) {
+		return unseal(ctx, c.client, c.endpoint+UnsealEndpoint, c.maxResponseSize, writeEncoded(sealed))
+	})
+}
+
+// Close any idle connections held by the http.Client.
+func (c *httpClient) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+// Shutdown waits for unseal requests in progress to complete, then closes any idle connections held by the
+// http.Client. Idle connections are closed even if the context is done first.
+func (c *httpClient) Shutdown(ctx context.Context) error {
+	return errors.Join(c.drain(ctx), c.Close())
+}
+
+// WaitForClientReady will poll the Wingman Client until it reports as ready, returning nil, or until the context is
+// canceled or completed, in which case the error will be [ErrNotReady]. All errors returned by the Client are ignored
+// and polling will continue. The [WithJitter] option can be used to add random variance to the time between attempts.
+func WaitForClientReady(ctx context.Context, client Client, sleepBetweenAttempts time.Duration, options ...Option) error {
+	logger := slog.With("sleepBetweenAttempts", sleepBetweenAttempts)
+	logger.Debug("Waiting for wingman client to be ready")
+	cfg, err := newConfig(options...)
+	if err != nil {
+		return err
+	}
+	return poll(ctx, cfg.pollInterval(sleepBetweenAttempts), func(ctx context.Context) (bool, error) {
+		if err := client.Ready(ctx); err != nil {
+			logger.Debug("Wingman is not ready, sleeping", "err", err)
+			return false, nil
+		}
+		logger.Debug("Wingman status is READY")
+		return true, nil
+	})
+}
@@ -0,0 +1,178 @@
+package wingman_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc/internal/grpcwire"
+	"github.com/memes/f5xc/wingman"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// A raw protobuf message for use with the grpcwire codec in test servers.
+type testRawMessage struct {
+	data []byte
+}
+
+func (m *testRawMessage) MarshalWire() ([]byte, error) {
+	return m.data, nil
+}
+
+func (m *testRawMessage) UnmarshalWire(data []byte) error {
+	m.data = bytes.Clone(data)
+	return nil
+}
+
+// Implements a dummy wingman gRPC server that mirrors the behaviour of testWingmanUnsealHandler; the health service
+// will report as serving.
+func testWingmanGRPCServer(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to create listener: %v", err)
+	}
+	server := grpc.NewServer(
+		grpc.ForceServerCodec(grpcwire.Codec{}),
+		grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+			method, _ := grpc.MethodFromServerStream(stream)
+			if method != wingman.GRPCUnsealMethod {
+				return status.Errorf(codes.Unimplemented, "unknown method %s", method)
+			}
+			req := &testRawMessage{}
+			if err := stream.RecvMsg(req); err != nil {
+				return err //nolint:wrapcheck // Test server
+			}
+			var location string
+			if err := grpcwire.RangeFields(req.data, func(field grpcwire.Field) error {
+				if field.Number == 2 {
+					location = string(field.Bytes)
+				}
+				return nil
+			}); err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+			b64, ok := strings.CutPrefix(location, "string:///")
+			if !ok {
+				return status.Errorf(codes.InvalidArgument, "unexpected location %q", location)
+			}
+			data, err := base64.StdEncoding.DecodeString(b64)
+			if err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+			// ROT13 to decode the sealed data
+			for i, b := range data {
+				switch {
+				case b >= 'A' && b <= 'Z':
+					data[i] = (b-'A'+13)%26 + 'A'
+				case b >= 'a' && b <= 'z':
+					data[i] = (b-'a'+13)%26 + 'a'
+				}
+			}
+			return stream.SendMsg(&testRawMessage{data: protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), data)}) //nolint:wrapcheck // Test server
+		}),
+	)
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			t.Logf("gRPC server returned an error: %v", err)
+		}
+	}()
+	t.Cleanup(server.Stop)
+	return "grpc://" + listener.Addr().String()
+}
+
+// Verify that NewClient returns a Client appropriate for the endpoint, and that the clients can unseal data.
+func TestNewClient(t *testing.T) {
+	t.Parallel()
+	httpServer := httptest.NewServer(testWingmanUnsealHandler(t))
+	t.Cleanup(httpServer.Close)
+	grpcEndpoint := testWingmanGRPCServer(t)
+	tests := []struct {
+		name          string
+		endpoint      string
+		sealed        []byte
+		expected      []byte
+		expectedError error
+	}{
+		// spell-checker: disable
+		{
+			name:          "empty",
+			expectedError: wingman.ErrInvalidEndpoint,
+		},
+		{
+			name:          "unsupported-scheme",
+			endpoint:      "ftp://localhost:8070",
+			expectedError: wingman.ErrInvalidEndpoint,
+		},
+		{
+			name:     "http",
+			endpoint: httpServer.URL,
+			sealed:   []byte("Guvf vf n grfg"),
+			expected: []byte("This is a test"),
+		},
+		{
+			name:     "grpc",
+			endpoint: grpcEndpoint,
+			sealed:   []byte("Guvf vf n grfg"),
+			expected: []byte("This is a test"),
+		},
+		// spell-checker: enable
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			client, err := wingman.NewClient(tst.endpoint, wingman.WithHTTPClient(httpServer.Client()))
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Fatalf("NewClient raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Fatalf("Expected NewClient to raise %v, got %v", tst.expectedError, err)
+			case err != nil:
+				return
+			}
+			t.Cleanup(func() {
+				if err := client.Close(); err != nil {
+					t.Errorf("Close raised an unexpected error: %v", err)
+				}
+			})
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			result, err := client.Unseal(ctx, tst.sealed)
+			switch {
+			case err != nil:
+				t.Errorf("Unseal raised an unexpected error: %v", err)
+			case !bytes.Equal(tst.expected, result):
+				t.Errorf("Expected %q, got %q", tst.expected, result)
+			}
+		})
+	}
+}
+
+// Verify that WaitForClientReady returns when a gRPC client reports ready.
+func TestWaitForClientReady(t *testing.T) {
+	t.Parallel()
+	client, err := wingman.NewClient(testWingmanGRPCServer(t))
+	if err != nil {
+		t.Fatalf("NewClient raised an unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := wingman.WaitForClientReady(ctx, client, 100*time.Millisecond); err != nil {
+		t.Errorf("WaitForClientReady raised an unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package wingman
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"strings"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/internal/bufpool"
+	"github.com/memes/f5xc/internal/grpcwire"
+	"github.com/memes/f5xc/internal/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The default fully-qualified gRPC method used to unseal secrets when Wingman exposes a gRPC API.
+const GRPCUnsealMethod = "/ves.io.wingman.Secret/Unseal"
+
+// ErrUnexpectedGRPCStatus is returned by unseal functions when wingman gRPC response status is not OK,
+// PermissionDenied, or Unavailable.
+var ErrUnexpectedGRPCStatus = errors.New("wingman returned an unexpected gRPC status")
+
+// Implements the Client interface using a Wingman gRPC API.
+type grpcClient struct {
+	inflight
+	// The host and port of the Wingman endpoint, used to describe failures.
+	target       string
+	conn         *grpc.ClientConn
+	health       grpc_health_v1.HealthClient
+	unsealMethod string
+}
+
+// Create a new gRPC client connection for the Wingman endpoint.
+func newGRPCClient(endpoint *url.URL, cfg *config) (*grpcClient, error) {
+	var creds credentials.TransportCredentials
+	if strings.EqualFold(endpoint.Scheme, "grpcs") {
+		creds = credentials.NewTLS(&tls.Config{
+			MinVersion: tls.VersionTLS12,
+		})
+	} else {
+		creds = insecure.NewCredentials()
+	}
+	options := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(grpcwire.Codec{}), grpc.MaxCallRecvMsgSize(int(min(cfg.maxResponseSize, math.MaxInt32)))),
+	}
+	if cfg.tracerProvider != nil {
+		options = append(options, grpc.WithChainUnaryInterceptor(tracing.UnaryClientInterceptor(cfg.tracerProvider)))
+	}
+	options = append(options, cfg.dialOptions...)
+	conn, err := grpc.NewClient(endpoint.Host, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC client: %w: %w", err, ErrInvalidEndpoint)
+	}
+	return &grpcClient{
+		target:       endpoint.Host,
+		conn:         conn,
+		health:       grpc_health_v1.NewHealthClient(conn),
+		unsealMethod: cfg.grpcUnsealMethod,
+	}, nil
+}
+
+// Ready returns nil if the Wingman gRPC health service reports SERVING.
+func (c *grpcClient) Ready(ctx context.Context) error {
+	resp, err := c.health.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	switch {
+	case err != nil:
+		err = fmt.Errorf("failure during health check: %w: %w", err, ErrNotReady)
+	case resp.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING:
+		err = fmt.Errorf("health status %s: %w", resp.GetStatus(), ErrNotReady)
+	default:
+		return nil
+	}
+	return &f5xc.Error{Op: readyOp, Endpoint: c.target, Temporary: true, Err: err}
+}
+
+// Unseal a byte slice of blindfold data, returning the unsealed data.
+func (c *grpcClient) Unseal(ctx context.Context, sealed []byte) ([]byte, error) {
+	return track(&c.inflight, func() ([]byte, error) {
+		return c.unseal(ctx, sealed)
+	})
+}
+
+// Base64 encodes the blindfold data and sends it to Wingman.
+func (c *grpcClient) unseal(ctx context.Context, sealed []byte) ([]byte, error) {
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+	buf.Grow(base64.StdEncoding.EncodedLen(len(sealed)))
+	buf.Write(base64.StdEncoding.AppendEncode(buf.AvailableBuffer(), sealed))
+	return c.unsealEncoded(ctx, buf.Bytes())
+}
+
+// Unseal a byte slice of base64 encoded blindfold data, returning the unsealed data.
+func (c *grpcClient) UnsealEncoded(ctx context.Context, sealed []byte) ([]byte, error) {
+	return track(&c.inflight, func() ([]byte, error) {
+		return c.unsealEncoded(ctx, sealed)
+	})
+}
+
+// Sends the base64 encoded blindfold data to Wingman.
+func (c *grpcClient) unsealEncoded(ctx context.Context, sealed []byte) ([]byte, error) {
+	logger := slog.With("method", c.unsealMethod)
+	logger.Debug("Sending gRPC unseal request")
+	var location strings.Builder
+	location.Grow(len(locationPrefix) + len(sealed))
+	location.WriteString(locationPrefix)
+	location.Write(sealed)
+	req := &unsealRequest{
+		Type:     "blindfold",
+		Location: location.String(),
+	}
+	resp := &unsealResponse{}
+	err := c.conn.Invoke(ctx, c.unsealMethod, req, resp)
+	unsealErr := &f5xc.Error{Op: unsealOp, Endpoint: c.target}
+	switch status.Code(err) {
+	case codes.OK:
+		return resp.Data, nil
+	case codes.PermissionDenied:
+		unsealErr.Err = ErrDeniedByPolicy
+	case codes.Unavailable:
+		unsealErr.Err = fmt.Errorf("%s: %w", status.Convert(err).Message(), ErrNotReady)
+		unsealErr.Temporary = true
+	default:
+		unsealErr.Err = fmt.Errorf("unexpected gRPC status %s: message %q: %w", status.Code(err), status.Convert(err).Message(), ErrUnexpectedGRPCStatus)
+		unsealErr.Temporary = retryableCode(status.Code(err))
+	}
+	return nil, unsealErr
+}
+
+// Returns true if a gRPC call that failed with the status code may succeed if it is repeated unchanged.
+func retryableCode(code codes.Code) bool {
+	switch code {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// Close the gRPC connection.
+func (c *grpcClient) Close() error {
+	return c.conn.Close() //nolint:wrapcheck // It is appropriate to return the grpc package error as-is
+}
+
+// Shutdown waits for unseal requests in progress to complete, then closes the gRPC connection. The connection is
+// closed even if the context is done first, which cancels any remaining requests.
+func (c *grpcClient) Shutdown(ctx context.Context) error {
+	return errors.Join(c.drain(ctx), c.Close())
+}
+
+// The gRPC unseal request message; equivalent to the JSON payload sent to the REST unseal endpoint.
+type unsealRequest struct {
+	Type     string
+	Location string
+}
+
+// Implements grpcwire.Marshaler.
+func (r *unsealRequest) MarshalWire() ([]byte, error) {
+	var b []byte
+	b = grpcwire.AppendString(b, 1, r.Type)
+	b = grpcwire.AppendString(b, 2, r.Location)
+	return b, nil
+}
+
+// The gRPC unseal response message; unlike the REST endpoint the unsealed data is not base64 encoded.
+type unsealResponse struct {
+	Data []byte
+}
+
+// Implements grpcwire.Unmarshaler.
+func (r *unsealResponse) UnmarshalWire(data []byte) error {
+	return grpcwire.RangeFields(data, func(field grpcwire.Field) error {
+		if field.Number == 1 && field.Type == protowire.BytesType {
+			r.Data = bytes.Clone(field.Bytes)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,76 @@
+package wingman
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+)
+
+// LivenessHandler returns an [http.Handler] suitable for use as a Kubernetes liveness probe that reflects the state of
+// the Wingman status endpoint. The handler will respond with a 200 status code if Wingman returned any HTTP response to
+// a status request, regardless of the reported status, and a 503 status code if Wingman could not be reached.
+//
+// It is the callers responsibility to ensure that the http.Client and endpoint are suitable for communicating with
+// Wingman; the function [DefaultLivenessHandler] can be used if Wingman is deployed as a sidecar listening on default
+// port.
+func LivenessHandler(client *http.Client, endpoint string) http.Handler {
+	logger := slog.With("endpoint", endpoint)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.Debug("Handling liveness probe")
+		statusCode, body, err := fetchStatus(r.Context(), client, endpoint)
+		if err != nil && statusCode == 0 {
+			logger.Debug("Wingman status request failed", "err", err)
+			http.Error(w, ErrNotReady.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		logger.Debug("Wingman is alive", "statusCode", statusCode, "body", body)
+		writeProbeResponse(w, http.StatusOK, body)
+	})
+}
+
+// ReadinessHandler returns an [http.Handler] suitable for use as a Kubernetes readiness probe that reflects the state
+// of the Wingman status endpoint. The handler will respond with a 200 status code only when Wingman reports a status of
+// READY, and a 503 status code in all other cases.
+//
+// It is the callers responsibility to ensure that the http.Client and endpoint are suitable for communicating with
+// Wingman; the function [DefaultReadinessHandler] can be used if Wingman is deployed as a sidecar listening on default
+// port.
+func ReadinessHandler(client *http.Client, endpoint string) http.Handler {
+	logger := slog.With("endpoint", endpoint)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.Debug("Handling readiness probe")
+		statusCode, body, err := fetchStatus(r.Context(), client, endpoint)
+		switch {
+		case err != nil:
+			logger.Debug("Wingman status request failed", "err", err)
+			http.Error(w, ErrNotReady.Error(), http.StatusServiceUnavailable)
+		case statusCode == http.StatusOK && bytes.Equal(body, []byte("READY")):
+			logger.Debug("Wingman is ready")
+			writeProbeResponse(w, http.StatusOK, body)
+		default:
+			logger.Debug("Wingman is not ready", "statusCode", statusCode, "body", body)
+			writeProbeResponse(w, http.StatusServiceUnavailable, body)
+		}
+	})
+}
+
+// DefaultLivenessHandler returns an [http.Handler] that reports the liveness of a sidecar Wingman listening on HTTP port
+// 8070. See [LivenessHandler] for details.
+func DefaultLivenessHandler() http.Handler {
+	return LivenessHandler(http.DefaultClient, DefaultWingmanURL+StatusEndpoint)
+}
+
+// DefaultReadinessHandler returns an [http.Handler] that reports the readiness of a sidecar Wingman listening on HTTP
+// port 8070. See [ReadinessHandler] for details.
+func DefaultReadinessHandler() http.Handler {
+	return ReadinessHandler(http.DefaultClient, DefaultWingmanURL+StatusEndpoint)
+}
+
+// Helper to write the Wingman status body as a plain text probe response.
+func writeProbeResponse(w http.ResponseWriter, statusCode int, body []byte) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(body); err != nil {
+		slog.Debug("Failed to write probe response", "err", err)
+	}
+}
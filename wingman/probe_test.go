@@ -0,0 +1,67 @@
+package wingman_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc/wingman"
+)
+
+// Verify the LivenessHandler and ReadinessHandler functions return handlers that reflect Wingman status.
+func TestProbeHandlers(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name              string
+		readyAfter        time.Time
+		unreachable       bool
+		expectedLiveness  int
+		expectedReadiness int
+	}{
+		{
+			name:              "ready",
+			readyAfter:        time.Now().Add(-1 * time.Hour),
+			expectedLiveness:  http.StatusOK,
+			expectedReadiness: http.StatusOK,
+		},
+		{
+			name:              "initializing",
+			readyAfter:        time.Now().Add(1 * time.Hour),
+			expectedLiveness:  http.StatusOK,
+			expectedReadiness: http.StatusServiceUnavailable,
+		},
+		{
+			name:              "unreachable",
+			unreachable:       true,
+			expectedLiveness:  http.StatusServiceUnavailable,
+			expectedReadiness: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(testWingmanStatusHandler(t, tst.readyAfter))
+			t.Cleanup(server.Close)
+			client := server.Client()
+			t.Cleanup(client.CloseIdleConnections)
+			endpoint := server.URL + wingman.StatusEndpoint
+			if tst.unreachable {
+				server.Close()
+			}
+			for name, probe := range map[string]struct {
+				handler  http.Handler
+				expected int
+			}{
+				"liveness":  {handler: wingman.LivenessHandler(client, endpoint), expected: tst.expectedLiveness},
+				"readiness": {handler: wingman.ReadinessHandler(client, endpoint), expected: tst.expectedReadiness},
+			} {
+				recorder := httptest.NewRecorder()
+				probe.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+				if recorder.Code != probe.expected {
+					t.Errorf("Expected %s probe to return %d, got %d", name, probe.expected, recorder.Code)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package wingman
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrClientShutdown is returned by a [Client] that has been shut down, or is shutting down, when asked to unseal data.
+var ErrClientShutdown = errors.New("wingman client is shut down")
+
+// Tracks the unseal requests in progress for a Client so that shutdown can wait for them to complete.
+type inflight struct {
+	mu       sync.Mutex
+	active   int
+	draining bool
+	// Closed when draining and there are no active requests.
+	idle chan struct{}
+}
+
+// Records the start of a request, returning false if the Client is shutting down and the request must be refused.
+func (f *inflight) start() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.draining {
+		return false
+	}
+	f.active++
+	return true
+}
+
+// Records the completion of a request that was started.
+func (f *inflight) done() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.active--
+	if f.draining && f.active == 0 {
+		close(f.idle)
+	}
+}
+
+// Refuses new requests and waits for the active requests to complete, or for the context to be done.
+func (f *inflight) drain(ctx context.Context) error {
+	f.mu.Lock()
+	if !f.draining {
+		f.draining = true
+		f.idle = make(chan struct{})
+		if f.active == 0 {
+			close(f.idle)
+		}
+	}
+	idle := f.idle
+	f.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("unseal requests did not complete before shutdown: %w", ctx.Err())
+	}
+}
+
+// Calls fn if the Client is not shutting down, and tracks it until it returns.
+func track[T any](f *inflight, fn func() (T, error)) (T, error) {
+	if !f.start() {
+		var zero T
+		return zero, ErrClientShutdown
+	}
+	defer f.done()
+	return fn()
+}
@@ -0,0 +1,73 @@
+package wingman_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/memes/f5xc/wingman"
+)
+
+// Verify that Shutdown refuses new unseal requests, waits for an unseal request in progress to complete, and reports
+// if the context is done before it completes.
+func TestClient_Shutdown(t *testing.T) {
+	t.Parallel()
+	received := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(received)
+		<-release
+		_, _ = w.Write([]byte(base64.StdEncoding.EncodeToString([]byte("secret"))))
+	}))
+	t.Cleanup(server.Close)
+	client, err := wingman.NewClient(server.URL, wingman.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatalf("NewClient raised an unexpected error: %v", err)
+	}
+	ctx := context.Background()
+	type result struct {
+		data []byte
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		data, err := client.Unseal(ctx, []byte("sealed"))
+		results <- result{data: data, err: err}
+	}()
+	<-received
+
+	expired, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := client.Shutdown(expired); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Shutdown to raise %v, got %v", context.Canceled, err)
+	}
+	if _, err := client.UnsealEncoded(ctx, []byte("c2VhbGVk")); !errors.Is(err, wingman.ErrClientShutdown) {
+		t.Errorf("Expected UnsealEncoded to raise %v, got %v", wingman.ErrClientShutdown, err)
+	}
+	close(release)
+	if result := <-results; result.err != nil || string(result.data) != "secret" {
+		t.Errorf("Expected in-flight Unseal to return secret, got %q: %v", result.data, result.err)
+	}
+	if err := client.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown raised an unexpected error: %v", err)
+	}
+}
+
+// Verify that a gRPC client refuses unseal requests once it has been shut down.
+func TestClient_Shutdown_GRPC(t *testing.T) {
+	t.Parallel()
+	client, err := wingman.NewClient(testWingmanGRPCServer(t))
+	if err != nil {
+		t.Fatalf("NewClient raised an unexpected error: %v", err)
+	}
+	ctx := context.Background()
+	if err := client.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown raised an unexpected error: %v", err)
+	}
+	if _, err := client.Unseal(ctx, []byte("sealed")); !errors.Is(err, wingman.ErrClientShutdown) {
+		t.Errorf("Expected Unseal to raise %v, got %v", wingman.ErrClientShutdown, err)
+	}
+}
@@ -7,6 +7,7 @@ package wingman
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -21,62 +22,116 @@ const DefaultWingmanURL = "http://localhost:8070"
 // Wingman REST status endpoint.
 const StatusEndpoint = "/status"
 
+// The operation of an [f5xc.Error] returned when a client reports that Wingman is not ready.
+const readyOp = "ready"
+
 // ErrNotReady indicates that Wingman service has not reported as ready to receive requests before the context was canceled.
 var ErrNotReady = errors.New("wingman is not ready")
 
+// ReadyPredicate is a function that returns true if the HTTP status code and body received from a Wingman status
+// endpoint indicate that Wingman is ready to receive requests.
+type ReadyPredicate func(statusCode int, body []byte) bool
+
+// IsReady is the default [ReadyPredicate] that returns true if the status code is 200 and the body is READY.
+func IsReady(statusCode int, body []byte) bool {
+	return statusCode == http.StatusOK && bytes.Equal(body, []byte("READY"))
+}
+
+// IsReadyJSON is a [ReadyPredicate] that supports Wingman builds that return a JSON object with a status field, e.g.
+// {"status":"READY"}, as well as the plain text READY body accepted by [IsReady].
+func IsReadyJSON(statusCode int, body []byte) bool {
+	if IsReady(statusCode, body) {
+		return true
+	}
+	var payload struct {
+		Status string `json:"status"`
+	}
+	if statusCode != http.StatusOK || json.Unmarshal(body, &payload) != nil {
+		return false
+	}
+	return payload.Status == "READY"
+}
+
 // WaitForReady will poll the Wingman status endpoint and return nil when the response has a 200 status code and a body
-// that is READY.
+// that is READY. The [WithReadyPredicate] option can be used to change the conditions that determine readiness, and
+// [WithJitter] can be used to add random variance to the time between attempts.
 //
 // All transient connection errors, HTTP errors, and other status codes are silently ignored and polling
-// will continue. An error will only be returned if a valid [http.Request] cannot be created from the endpoint, or if the
-// context is canceled or completed before a successful response is received the error will be [ErrNotReady].
+// will continue. An error will only be returned if a valid [http.Request] cannot be created from the endpoint, if an
+// option is invalid, or if the context is canceled or completed before a successful response is received the error
+// will be [ErrNotReady].
 //
 // It is the callers responsibility to ensure that the http.Client and endpoint are suitable for communicating with
 // Wingman; the function [DefaultWaitForReady] can be used if Wingman is deployed as a sidecar listening on default port.
-func WaitForReady(ctx context.Context, client *http.Client, endpoint string, sleepBetweenAttempts time.Duration) error {
+func WaitForReady(ctx context.Context, client *http.Client, endpoint string, sleepBetweenAttempts time.Duration, options ...Option) error {
 	logger := slog.With("endpoint", endpoint, "sleepBetweenAttempts", sleepBetweenAttempts)
 	logger.Debug("Waiting for wingman to be ready")
+	cfg, err := newConfig(options...)
+	if err != nil {
+		return err
+	}
+	return poll(ctx, cfg.pollInterval(sleepBetweenAttempts), func(ctx context.Context) (bool, error) {
+		logger.Debug("Checking wingman status")
+		statusCode, body, err := fetchStatus(ctx, client, endpoint)
+		switch {
+		case errors.Is(err, errInvalidStatusRequest):
+			return false, err
+		case err != nil:
+			logger.Debug("failure during status request, ignoring", "err", err)
+		case cfg.readyPredicate(statusCode, body):
+			logger.Debug("Wingman status is READY", "statusCode", statusCode, "body", body)
+			return true, nil
+		default:
+			logger.Debug("Wingman is not ready, sleeping", "statusCode", statusCode, "body", body)
+		}
+		return false, nil
+	})
+}
+
+// Calls the check function immediately and then after every interval until it returns true or an error, or the
+// context is canceled or completed, in which case the error will be [ErrNotReady].
+func poll(ctx context.Context, interval func() time.Duration, check func(context.Context) (bool, error)) error {
 	timer := time.NewTimer(1 * time.Millisecond)
 	for {
 		select {
 		case <-ctx.Done():
-			logger.Debug("Context has been canceled")
+			slog.Debug("Context has been canceled")
 			if !timer.Stop() {
-				logger.Debug("Clearing timer channel")
+				slog.Debug("Clearing timer channel")
 				<-timer.C
 			}
 			return ErrNotReady
 		case <-timer.C:
-			logger.Debug("Checking wingman status")
-			req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
-			if err != nil {
-				return fmt.Errorf("failed to create status request: %w", err)
-			}
-			resp, err := client.Do(req)
-			if err != nil {
-				logger.Debug("failure during status request, ignoring", "err", err)
-				timer.Reset(sleepBetweenAttempts)
-				break
-			}
-			logger := logger.With("statusCode", resp.StatusCode)
-			defer resp.Body.Close()
-			body, err := io.ReadAll(resp.Body)
-			if err != nil {
-				logger.Debug("failed to read wingman status response body, ignoring", "err", err)
-			} else {
-				logger := logger.With("body", body)
-				logger.Debug("Wingman response received")
-				if resp.StatusCode == 200 && bytes.Equal(body, []byte("READY")) {
-					logger.Debug("Wingman status is READY")
-					return nil
-				}
-				logger.Debug("Wingman is not ready, sleeping")
-				timer.Reset(sleepBetweenAttempts)
+			done, err := check(ctx)
+			if err != nil || done {
+				return err
 			}
+			timer.Reset(interval())
 		}
 	}
 }
 
+// Internal error used to distinguish a failure to build a status request from transient request failures.
+var errInvalidStatusRequest = errors.New("failed to create status request")
+
+// Makes a single request to the Wingman status endpoint and returns the HTTP status code and body of the response.
+func fetchStatus(ctx context.Context, client *http.Client, endpoint string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %w", errInvalidStatusRequest, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failure during status request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("failed to read wingman status response body: %w", err)
+	}
+	return resp.StatusCode, body, nil
+}
+
 // DefaultWaitForReady will poll the default Wingman sidecar status endpoint every 10 seconds and will return nil once
 // the response has a 200 status code and a body that is READY.
 //
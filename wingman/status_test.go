@@ -103,3 +103,101 @@ func ExampleDefaultWaitForReady() {
 	}
 	// Output: Failure waiting for Wingman to be ready: wingman is not ready
 }
+
+// Verify the ReadyPredicate implementations behave as expected.
+func TestReadyPredicates(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name              string
+		statusCode        int
+		body              []byte
+		expectedIsReady   bool
+		expectedReadyJSON bool
+	}{
+		{
+			name:              "ready",
+			statusCode:        http.StatusOK,
+			body:              []byte("READY"),
+			expectedIsReady:   true,
+			expectedReadyJSON: true,
+		},
+		{
+			name:       "initializing",
+			statusCode: http.StatusOK,
+			body:       []byte("INITIALIZING"),
+		},
+		{
+			name:              "json-ready",
+			statusCode:        http.StatusOK,
+			body:              []byte(`{"status":"READY"}`),
+			expectedReadyJSON: true,
+		},
+		{
+			name:       "json-initializing",
+			statusCode: http.StatusOK,
+			body:       []byte(`{"status":"INITIALIZING"}`),
+		},
+		{
+			name:       "json-unavailable",
+			statusCode: http.StatusServiceUnavailable,
+			body:       []byte(`{"status":"READY"}`),
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			if result := wingman.IsReady(tst.statusCode, tst.body); result != tst.expectedIsReady {
+				t.Errorf("Expected IsReady to return %t, got %t", tst.expectedIsReady, result)
+			}
+			if result := wingman.IsReadyJSON(tst.statusCode, tst.body); result != tst.expectedReadyJSON {
+				t.Errorf("Expected IsReadyJSON to return %t, got %t", tst.expectedReadyJSON, result)
+			}
+		})
+	}
+}
+
+// Verify that WaitForReady accepts a custom predicate and jitter, and rejects invalid options.
+func TestWaitForReady_WithOptions(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		options       []wingman.Option
+		expectedError error
+	}{
+		{
+			name:          "default-predicate",
+			expectedError: wingman.ErrNotReady,
+		},
+		{
+			name:    "json-predicate",
+			options: []wingman.Option{wingman.WithReadyPredicate(wingman.IsReadyJSON), wingman.WithJitter(0.5)},
+		},
+		{
+			name:          "invalid-jitter",
+			options:       []wingman.Option{wingman.WithJitter(2)},
+			expectedError: wingman.ErrInvalidOption,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if _, err := w.Write([]byte(`{"status":"READY"}`)); err != nil {
+					t.Errorf("unexpected error writing response: %v", err)
+				}
+			}))
+			t.Cleanup(server.Close)
+			client := server.Client()
+			t.Cleanup(client.CloseIdleConnections)
+			ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+			defer cancel()
+			err := wingman.WaitForReady(ctx, client, server.URL, 100*time.Millisecond, tst.options...)
+			switch {
+			case tst.expectedError == nil && err != nil:
+				t.Errorf("WaitForReady raised an unexpected error: %v", err)
+			case tst.expectedError != nil && !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected WaitForReady to raise %v, got %v", tst.expectedError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package wingman_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/memes/f5xc/wingman"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Verify that HTTP and gRPC clients created with tracing record a client span for each unseal request.
+func TestWithTracing(t *testing.T) {
+	t.Parallel()
+	httpServer := httptest.NewServer(testWingmanUnsealHandler(t))
+	t.Cleanup(httpServer.Close)
+	tests := []struct {
+		name         string
+		endpoint     string
+		expectedName string
+	}{
+		{
+			name:         "http",
+			endpoint:     httpServer.URL,
+			expectedName: "HTTP POST",
+		},
+		{
+			name:         "grpc",
+			endpoint:     testWingmanGRPCServer(t),
+			expectedName: "ves.io.wingman.Secret/Unseal",
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+			client, err := wingman.NewClient(tst.endpoint, wingman.WithTracing(provider))
+			if err != nil {
+				t.Fatalf("NewClient raised an unexpected error: %v", err)
+			}
+			t.Cleanup(func() { _ = client.Close() })
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			// spell-checker: disable
+			if _, err := client.Unseal(ctx, []byte("Guvf vf n grfg")); err != nil {
+				t.Fatalf("Unseal raised an unexpected error: %v", err)
+			}
+			// spell-checker: enable
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("Expected 1 span, got %d", len(spans))
+			}
+			switch span := spans[0]; {
+			case span.Name() != tst.expectedName:
+				t.Errorf("Expected span name %s, got %s", tst.expectedName, span.Name())
+			case span.SpanKind() != trace.SpanKindClient:
+				t.Errorf("Expected a client span, got %v", span.SpanKind())
+			case span.Status().Code == codes.Error:
+				t.Errorf("Expected span to succeed, got %v", span.Status())
+			}
+		})
+	}
+}
+
+// Verify that a nil tracer provider is rejected.
+func TestWithTracing_Invalid(t *testing.T) {
+	t.Parallel()
+	if _, err := wingman.NewClient(wingman.DefaultWingmanURL, wingman.WithTracing(nil)); !errors.Is(err, wingman.ErrInvalidOption) {
+		t.Errorf("Expected NewClient to raise %v, got %v", wingman.ErrInvalidOption, err)
+	}
+}
@@ -9,11 +9,25 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+
+	"github.com/memes/f5xc"
+	"github.com/memes/f5xc/internal/bufpool"
 )
 
 // The Wingman REST unseal endpoint.
 const UnsealEndpoint = "/secret/unseal"
 
+// DefaultMaxResponseSize is the maximum number of bytes of an unseal response that are read, unless changed with
+// [WithMaxResponseSize].
+const DefaultMaxResponseSize = f5xc.DefaultMaxResponseSize
+
+const (
+	// The operation of an [f5xc.Error] returned by a failed unseal request.
+	unsealOp = "unseal"
+	// The prefix of the location of sealed data in an unseal request.
+	locationPrefix = "string:///"
+)
+
 // ErrDeniedByPolicy is returned by unseal functions when access is denied by security policy used during seal.
 var ErrDeniedByPolicy = errors.New("denied by security policy")
 
@@ -28,63 +42,111 @@ var ErrUnexpectedHTTPStatus = errors.New("wingman returned an unexpected status
 // It is the callers responsibility to ensure that the http.Client and endpoint are suitable for communicating with
 // Wingman; the function [DefaultUnseal] can be used if Wingman is deployed as a sidecar listening on default port.
 func Unseal(ctx context.Context, client *http.Client, endpoint string, sealed []byte) ([]byte, error) {
-	slog.Debug("Building unseal payload from unencoded source")
-	var buf bytes.Buffer
-	encoder := base64.NewEncoder(base64.StdEncoding, &buf)
-	if _, err := encoder.Write(sealed); err != nil {
-		return nil, fmt.Errorf("failed to base64 encode data: %w", err)
-	}
-	if err := encoder.Close(); err != nil {
-		return nil, fmt.Errorf("error closing bas64 encoder: %w", err)
-	}
-	return UnsealEncoded(ctx, client, endpoint, buf.Bytes())
+	return unseal(ctx, client, endpoint, DefaultMaxResponseSize, writeUnencoded(sealed))
 }
 
 // Unseal a byte slice of base64 encoded blindfold data, and returns a byte array of the unsealed data.
 //
 // The sealed bytes will be embedded in the request as-is; use [Unseal] if the sealed bytes must be base64 encoded as
-// required by Wingman's unseal endpoint.
+// required by Wingman's unseal endpoint. A failed request is returned as an [*f5xc.Error] that wraps
+// [ErrDeniedByPolicy], [ErrNotReady], or [ErrUnexpectedHTTPStatus] if Wingman responded; only [ErrNotReady] and gateway
+// failures are retryable.
 //
 // It is the callers responsibility to ensure that the http.Client and endpoint are suitable for communicating with
 // Wingman; the function [DefaultUnsealEncoded] can be used if Wingman is deployed as a sidecar listening on default port.
 func UnsealEncoded(ctx context.Context, client *http.Client, endpoint string, sealed []byte) ([]byte, error) {
+	return unseal(ctx, client, endpoint, DefaultMaxResponseSize, writeEncoded(sealed))
+}
+
+// Returns a function that writes the base64 encoding of the sealed data to the unseal payload.
+func writeUnencoded(sealed []byte) func(*bytes.Buffer) error {
+	return func(buf *bytes.Buffer) error {
+		slog.Debug("Building unseal payload from unencoded source")
+		encoder := base64.NewEncoder(base64.StdEncoding, buf)
+		if _, err := encoder.Write(sealed); err != nil {
+			return fmt.Errorf("failed to base64 encode data: %w", err)
+		}
+		if err := encoder.Close(); err != nil {
+			return fmt.Errorf("error closing bas64 encoder: %w", err)
+		}
+		return nil
+	}
+}
+
+// Returns a function that writes the base64 encoded sealed data to the unseal payload as-is.
+func writeEncoded(sealed []byte) func(*bytes.Buffer) error {
+	return func(buf *bytes.Buffer) error {
+		slog.Debug("Building unseal payload from encoded source")
+		buf.Write(sealed)
+		return nil
+	}
+}
+
+// Sends an unseal request with a payload whose location holds the base64 encoded sealed data written by writeSealed,
+// and returns the unsealed data. The request payload and response body are held in pooled buffers, and the sealed data
+// is encoded directly into the payload, so that the only allocation proportional to the size of the secret is the
+// returned slice. A response body of more than maxResponseSize bytes is not read.
+func unseal(ctx context.Context, client *http.Client, endpoint string, maxResponseSize int64, writeSealed func(*bytes.Buffer) error) ([]byte, error) {
 	logger := slog.With("endpoint", endpoint)
-	logger.Debug("Preparing unseal request from encoded source")
-	var buf bytes.Buffer
-	buf.WriteString(`{"type":"blindfold","location":"string:///`)
-	buf.Write(sealed)
-	buf.WriteString(`"}`)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &buf)
+	logger.Debug("Preparing unseal request")
+	payload := bufpool.Get()
+	payload.WriteString(`{"type":"blindfold","location":"` + locationPrefix)
+	if err := writeSealed(payload); err != nil {
+		bufpool.Put(payload)
+		return nil, err
+	}
+	payload.WriteString(`"}`)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload.Bytes()))
 	if err != nil {
+		bufpool.Put(payload)
 		return nil, fmt.Errorf("failed to create request for unseal: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	logger.Debug("Sending unseal request")
+	unsealErr := &f5xc.Error{Op: unsealOp, Endpoint: endpoint}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failure during unseal request: %w", err)
+		// The transport may still be reading the payload, so it is not returned to the pool
+		unsealErr.Err = fmt.Errorf("failure during unseal request: %w", err)
+		unsealErr.Temporary = f5xc.IsRetryable(err)
+		return nil, unsealErr
 	}
 	slog.Debug("Processing unseal response", "statusCode", resp.StatusCode)
+	// The payload can only be reused once the response body has been closed, so this must be deferred first
+	defer bufpool.Put(payload)
 	defer resp.Body.Close()
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read wingman response body: %w", err)
+	unsealErr.StatusCode = resp.StatusCode
+	respBody := bufpool.Get()
+	defer bufpool.Put(respBody)
+	if _, err := respBody.ReadFrom(io.LimitReader(resp.Body, maxResponseSize+1)); err != nil {
+		unsealErr.Err = fmt.Errorf("failed to read wingman response body: %w", err)
+		unsealErr.Temporary = f5xc.IsRetryable(err)
+		return nil, unsealErr
+	}
+	if int64(respBody.Len()) > maxResponseSize {
+		unsealErr.Err = fmt.Errorf("wingman response body exceeds %d bytes: %w", maxResponseSize, f5xc.ErrResponseTooLarge)
+		return nil, unsealErr
 	}
 	switch resp.StatusCode {
 	case http.StatusOK:
-		result := make([]byte, base64.StdEncoding.DecodedLen(len(respBody)))
-		resultLen, err := base64.StdEncoding.Decode(result, respBody)
+		result := make([]byte, base64.StdEncoding.DecodedLen(respBody.Len()))
+		resultLen, err := base64.StdEncoding.Decode(result, respBody.Bytes())
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode response body: %w", err)
+			unsealErr.Err = fmt.Errorf("failed to decode response body: %w", err)
+			return nil, unsealErr
 		}
 		return result[:resultLen], nil
 	case http.StatusForbidden:
-		return nil, ErrDeniedByPolicy
+		unsealErr.Err = ErrDeniedByPolicy
 	case http.StatusServiceUnavailable:
-		return nil, fmt.Errorf("%s: %w", string(respBody), ErrNotReady)
+		unsealErr.Err = fmt.Errorf("%s: %w", respBody.String(), ErrNotReady)
+		unsealErr.Temporary = true
+	default:
+		unsealErr.Err = fmt.Errorf("message %q: %w", respBody.String(), ErrUnexpectedHTTPStatus)
+		unsealErr.Temporary = f5xc.RetryableStatus(resp.StatusCode)
 	}
-	return nil, fmt.Errorf("unexpected HTTP status code %d: message %q: %w", resp.StatusCode, string(respBody), ErrUnexpectedHTTPStatus)
+	return nil, unsealErr
 }
 
 // Unseal a byte slice of blindfold data, and returns a byte array of the unsealed data, using a sidecar Wingman listening
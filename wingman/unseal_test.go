@@ -15,6 +15,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/memes/f5xc"
 	"github.com/memes/f5xc/wingman"
 	"go.uber.org/goleak"
 )
@@ -172,6 +173,179 @@ func TestUnsealEncoded(t *testing.T) {
 	}
 }
 
+// Benchmarks unsealing a 4KiB secret through a Wingman endpoint that returns a fixed response, to measure the
+// allocations made to build the request payload and decode the response.
+func BenchmarkUnseal(b *testing.B) {
+	plaintext := bytes.Repeat([]byte("s"), 4096)
+	response := []byte(base64.StdEncoding.EncodeToString(plaintext))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = w.Write(response)
+	}))
+	b.Cleanup(server.Close)
+	client := server.Client()
+	b.Cleanup(client.CloseIdleConnections)
+	endpoint := server.URL + wingman.UnsealEndpoint
+	sealed := bytes.Repeat([]byte("x"), 4096)
+	encoded := []byte(base64.StdEncoding.EncodeToString(sealed))
+	ctx := context.Background()
+	b.Run("Unseal", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			if _, err := wingman.Unseal(ctx, client, endpoint, sealed); err != nil {
+				b.Fatalf("Unseal raised an unexpected error: %v", err)
+			}
+		}
+	})
+	b.Run("UnsealEncoded", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			if _, err := wingman.UnsealEncoded(ctx, client, endpoint, encoded); err != nil {
+				b.Fatalf("UnsealEncoded raised an unexpected error: %v", err)
+			}
+		}
+	})
+}
+
+// Verify that unseal failures are returned as an f5xc.Error that reports the status code and whether the request may
+// be retried.
+func TestUnsealEncoded_Error(t *testing.T) {
+	tests := []struct {
+		name              string
+		statusCode        int
+		body              string
+		expectedError     error
+		expectedRetryable bool
+	}{
+		{
+			name:          "malformed",
+			statusCode:    http.StatusOK,
+			body:          "!",
+			expectedError: base64.CorruptInputError(0),
+		},
+		{
+			name:          "denied",
+			statusCode:    http.StatusForbidden,
+			expectedError: wingman.ErrDeniedByPolicy,
+		},
+		{
+			name:              "not-ready",
+			statusCode:        http.StatusServiceUnavailable,
+			expectedError:     wingman.ErrNotReady,
+			expectedRetryable: true,
+		},
+		{
+			name:              "bad-gateway",
+			statusCode:        http.StatusBadGateway,
+			expectedError:     wingman.ErrUnexpectedHTTPStatus,
+			expectedRetryable: true,
+		},
+		{
+			name:          "bad-request",
+			statusCode:    http.StatusBadRequest,
+			expectedError: wingman.ErrUnexpectedHTTPStatus,
+		},
+	}
+	t.Parallel()
+	for _, test := range tests {
+		tst := test
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tst.statusCode)
+				_, _ = io.WriteString(w, tst.body)
+			}))
+			t.Cleanup(server.Close)
+			client := server.Client()
+			t.Cleanup(client.CloseIdleConnections)
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			_, err := wingman.UnsealEncoded(ctx, client, server.URL, []byte("R3V2ZiB2ZiBuIGdyZmc="))
+			var unsealErr *f5xc.Error
+			switch {
+			case !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected UnsealEncoded to raise %v, got %v", tst.expectedError, err)
+			case !errors.As(err, &unsealErr):
+				t.Errorf("Expected UnsealEncoded to raise an f5xc.Error, got %T", err)
+			case unsealErr.StatusCode != tst.statusCode || unsealErr.Endpoint != server.URL:
+				t.Errorf("Expected status %d from %s, got %d from %s", tst.statusCode, server.URL, unsealErr.StatusCode, unsealErr.Endpoint)
+			case f5xc.IsRetryable(err) != tst.expectedRetryable:
+				t.Errorf("Expected IsRetryable to return %t, got %t", tst.expectedRetryable, f5xc.IsRetryable(err))
+			}
+		})
+	}
+}
+
+// Verify that an unseal response larger than the maximum response size of an HTTP or gRPC client is rejected.
+func TestClient_UnsealEncoded_MaxResponseSize(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, strings.Repeat("QUFB", 64))
+	}))
+	t.Cleanup(server.Close)
+	grpcEndpoint := testWingmanGRPCServer(t)
+	tests := []struct {
+		name              string
+		endpoint          string
+		size              int64
+		expectedLen       int
+		expectedError     error
+		expectedRetryable bool
+	}{
+		{
+			name:        "http-within-limit",
+			endpoint:    server.URL,
+			size:        256,
+			expectedLen: 192,
+		},
+		{
+			name:          "http-too-large",
+			endpoint:      server.URL,
+			size:          255,
+			expectedError: f5xc.ErrResponseTooLarge,
+		},
+		{
+			name:        "grpc-within-limit",
+			endpoint:    grpcEndpoint,
+			size:        256,
+			expectedLen: 14,
+		},
+		{
+			name:              "grpc-too-large",
+			endpoint:          grpcEndpoint,
+			size:              8,
+			expectedError:     wingman.ErrUnexpectedGRPCStatus,
+			expectedRetryable: true,
+		},
+	}
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			t.Parallel()
+			client, err := wingman.NewClient(tst.endpoint, wingman.WithHTTPClient(server.Client()), wingman.WithMaxResponseSize(tst.size))
+			if err != nil {
+				t.Fatalf("NewClient raised an unexpected error: %v", err)
+			}
+			t.Cleanup(func() { _ = client.Close() })
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			result, err := client.UnsealEncoded(ctx, []byte("R3V2ZiB2ZiBuIGdyZmc="))
+			switch {
+			case !errors.Is(err, tst.expectedError):
+				t.Errorf("Expected UnsealEncoded to raise %v, got %v", tst.expectedError, err)
+			case err == nil && len(result) != tst.expectedLen:
+				t.Errorf("Expected %d bytes of unsealed data, got %d", tst.expectedLen, len(result))
+			case err != nil && f5xc.IsRetryable(err) != tst.expectedRetryable:
+				t.Errorf("Expected IsRetryable to return %t, got %t", tst.expectedRetryable, f5xc.IsRetryable(err))
+			case err != nil && tst.endpoint == grpcEndpoint && !strings.Contains(err.Error(), "ResourceExhausted"):
+				t.Errorf("Expected a ResourceExhausted status, got %v", err)
+			}
+		})
+	}
+	if _, err := wingman.NewClient(server.URL, wingman.WithMaxResponseSize(0)); !errors.Is(err, wingman.ErrInvalidOption) {
+		t.Errorf("Expected NewClient to raise %v, got %v", wingman.ErrInvalidOption, err)
+	}
+}
+
 func ExampleDefaultUnseal() {
 	// Allow wingman up to 10 seconds to try to unseal a secret
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -183,7 +357,7 @@ func ExampleDefaultUnseal() {
 	}
 	// Use the unsealed secret data
 	fmt.Printf("secretData is %v", secretData)
-	// Output: Failure unsealing blindfold secret: failure during unseal request: Post "http://localhost:8070/secret/unseal": dial tcp 127.0.0.1:8070: connect: connection refused
+	// Output: Failure unsealing blindfold secret: unseal http://localhost:8070/secret/unseal: failure during unseal request: Post "http://localhost:8070/secret/unseal": dial tcp 127.0.0.1:8070: connect: connection refused
 }
 
 func ExampleDefaultUnseal_file() {
@@ -204,7 +378,7 @@ func ExampleDefaultUnseal_file() {
 	}
 	// Use the unsealed secret data
 	fmt.Printf("secretData is %v", secretData)
-	// Output: Failure unsealing blindfold secret: failure during unseal request: Post "http://localhost:8070/secret/unseal": dial tcp 127.0.0.1:8070: connect: connection refused
+	// Output: Failure unsealing blindfold secret: unseal http://localhost:8070/secret/unseal: failure during unseal request: Post "http://localhost:8070/secret/unseal": dial tcp 127.0.0.1:8070: connect: connection refused
 }
 
 func ExampleDefaultUnsealEncoded() {
@@ -218,7 +392,7 @@ func ExampleDefaultUnsealEncoded() {
 	}
 	// Use the unsealed secret data
 	fmt.Printf("secretData is %v", secretData)
-	// Output: Failure unsealing blindfold secret: failure during unseal request: Post "http://localhost:8070/secret/unseal": dial tcp 127.0.0.1:8070: connect: connection refused
+	// Output: Failure unsealing blindfold secret: unseal http://localhost:8070/secret/unseal: failure during unseal request: Post "http://localhost:8070/secret/unseal": dial tcp 127.0.0.1:8070: connect: connection refused
 }
 
 func ExampleDefaultUnsealEncoded_file() {
@@ -239,5 +413,5 @@ func ExampleDefaultUnsealEncoded_file() {
 	}
 	// Use the unsealed secret data
 	fmt.Printf("secretData is %v", secretData)
-	// Output: Failure unsealing blindfold secret: failure during unseal request: Post "http://localhost:8070/secret/unseal": dial tcp 127.0.0.1:8070: connect: connection refused
+	// Output: Failure unsealing blindfold secret: unseal http://localhost:8070/secret/unseal: failure during unseal request: Post "http://localhost:8070/secret/unseal": dial tcp 127.0.0.1:8070: connect: connection refused
 }